@@ -0,0 +1,22 @@
+package s3readerat
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// API is the subset of the S3 API that an S3ReaderAt calls. *s3.Client implements it, and adapters implement it for
+// other clients, such as package s3v1 for aws-sdk-go, so that codebases that have not moved to aws-sdk-go-v2 can
+// still read through an S3ReaderAt.
+//
+// An S3ReaderAt tells errors apart the way aws-sdk-go-v2 reports them, so an adapter should return errors that wrap a
+// *smithyhttp.ResponseError for responses from S3, carrying a smithy.APIError with S3's error code, and a
+// *smithyhttp.RequestSendError when S3 cannot be reached.
+type API interface {
+	HeadObject(ctx context.Context, input *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput,
+		error)
+	GetObject(ctx context.Context, input *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error)
+	GetObjectAttributes(ctx context.Context, input *s3.GetObjectAttributesInput, optFns ...func(*s3.Options)) (
+		*s3.GetObjectAttributesOutput, error)
+}
@@ -0,0 +1,46 @@
+package s3readerat
+
+import (
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// isAccessPoint reports whether bucket is the ARN of an access point, such as
+// arn:aws:s3:us-west-2:123456789012:accesspoint/name, rather than the name of a bucket. s3.Clients route requests for
+// access points to the region their ARN names, so S3ReaderAts never follow redirects for them.
+func isAccessPoint(bucket string) bool {
+	return arn.IsARN(bucket)
+}
+
+// isMultiRegionAccessPointAlias reports whether bucket is the alias of a Multi-Region Access Point, such as
+// mfzwi23gnjvgw.mrap. Bucket names cannot end in .mrap, which is reserved for them.
+func isMultiRegionAccessPointAlias(bucket string) bool {
+	return !arn.IsARN(bucket) && strings.HasSuffix(bucket, ".mrap")
+}
+
+// useARNRegion lets an s3.Client make requests to an access point in a region other than its own, as named by the
+// access point's ARN, rather than failing.
+func useARNRegion(o *s3.Options) {
+	o.UseARNRegion = true
+}
+
+// accessPointEnd returns the length of the access point ARN at the start of s, the bucket part of an S3 URL, which
+// ends at the first slash after its "accesspoint/" resource, or -1 if s does not start with one.
+func accessPointEnd(s string) int {
+	const resource = "accesspoint/"
+	if !strings.HasPrefix(s, "arn:") {
+		return -1
+	}
+
+	i := strings.Index(s, resource)
+	if i < 0 {
+		return -1
+	}
+	i += len(resource)
+	if j := strings.IndexByte(s[i:], '/'); j >= 0 {
+		return i + j
+	}
+	return len(s)
+}
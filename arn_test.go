@@ -0,0 +1,98 @@
+package s3readerat
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// TestAccessPoint tests that an S3ReaderAt whose bucket is an access point ARN makes its requests, including listing,
+// to the access point, even though its ARN names a region other than the client's.
+func TestAccessPoint(t *testing.T) {
+	f := newFakeS3(t)
+	f.put("bucket", "dir/key", []byte("0123456789"))
+	f.hook = func(w http.ResponseWriter, r *http.Request) bool {
+		// Serve the access point's requests from the bucket.
+		if strings.HasPrefix(r.Host, "ap-123456789012.") {
+			r.URL.Path = "/bucket" + r.URL.Path
+		}
+		return false
+	}
+
+	options := accessPointOptions(f)
+	factory := &Factory{Options: Options{Options: &options, Bucket: "arn:aws:s3:eu-west-1:123456789012:accesspoint/ap"}}
+
+	objects, err := factory.OpenPrefix(context.Background(), "dir/")
+	if err != nil || len(objects) != 1 {
+		t.Fatalf("Expected to list 1 object, got %d (%v)", len(objects), err)
+	}
+	s3ReaderAt, err := factory.Open("dir/key")
+	if err != nil {
+		t.Fatalf("Error calling Open: %v", err)
+	}
+	b := make([]byte, 4)
+	if _, err = s3ReaderAt.ReadAt(b, 2); err != nil || string(b) != "2345" {
+		t.Fatalf("Expected to read %q, got %q (%v)", "2345", b, err)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, r := range f.requests {
+		if !strings.HasPrefix(r.Host, "ap-123456789012.") {
+			t.Fatalf("Expected every request to be made to the access point, got %s %s", r.Host, r.URL)
+		}
+	}
+}
+
+// TestMultiRegionAccessPoint tests that an S3ReaderAt whose bucket is a Multi-Region Access Point ARN makes its
+// requests to the global endpoint, signed with SigV4A for every region, and that an alias is rejected.
+func TestMultiRegionAccessPoint(t *testing.T) {
+	f := newFakeS3(t)
+	f.put("bucket", "key", []byte("0123456789"))
+	f.hook = func(w http.ResponseWriter, r *http.Request) bool {
+		r.URL.Path = "/bucket" + r.URL.Path
+		return false
+	}
+
+	options := accessPointOptions(f)
+	s3ReaderAt, err := NewWithOptions(Options{Options: &options,
+		Bucket: "arn:aws:s3::123456789012:accesspoint/mfzwi23gnjvgw.mrap", Key: "key"})
+	if err != nil {
+		t.Fatalf("Error calling NewWithOptions: %v", err)
+	}
+	b := make([]byte, 4)
+	if _, err = s3ReaderAt.ReadAt(b, 2); err != nil || string(b) != "2345" {
+		t.Fatalf("Expected to read %q, got %q (%v)", "2345", b, err)
+	}
+
+	f.mu.Lock()
+	for _, r := range f.requests {
+		if r.Host != "mfzwi23gnjvgw.mrap.accesspoint.s3-global.amazonaws.com" ||
+			!strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-ECDSA-P256-SHA256 ") ||
+			r.Header.Get("X-Amz-Region-Set") != "*" {
+			t.Fatalf("Expected every request to be signed with SigV4A and made to the global endpoint, got %s %s",
+				r.Host, r.Header.Get("Authorization"))
+		}
+	}
+	f.mu.Unlock()
+
+	if _, err = NewWithOptions(Options{Options: &options, Bucket: "mfzwi23gnjvgw.mrap", Key: "key"}); err == nil {
+		t.Fatalf("Expected an error opening a Multi-Region Access Point by its alias")
+	}
+}
+
+// accessPointOptions returns the options of an s3.Client that talks to f whatever host it makes a request to, as
+// requests to access points are made to hosts derived from their ARNs.
+func accessPointOptions(f *fakeS3) s3.Options {
+	options := f.options()
+	options.HTTPClient = &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, network string, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, network, f.server.Listener.Addr().String())
+		},
+	}}
+	return options
+}
@@ -0,0 +1,194 @@
+package s3readerat
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ErrAuditLogTampered is returned by VerifyAuditLog and OpenAuditLog when an audit log's hash chain is broken,
+// meaning a line was altered, removed or inserted after it was written.
+var ErrAuditLogTampered = errors.New("audit log hash chain is broken")
+
+// AuditEntry records one read, as written to an AuditLog.
+type AuditEntry struct {
+	// Time is when the read finished.
+	Time time.Time `json:"time"`
+
+	// Operation is "ReadAt", for reads through ReadAt, ReadAtContext, PrefetchAt and the readers built on them,
+	// "CopyRange" or "ReadIntoFile".
+	Operation string `json:"operation"`
+
+	// Tags identify who the read was made for, as given to WithTags.
+	Tags map[string]string `json:"tags,omitempty"`
+
+	Bucket string `json:"bucket"`
+	Key    string `json:"key"`
+
+	// Offset and Length are the range requested, and Bytes the number of bytes returned.
+	Offset int64 `json:"offset"`
+	Length int64 `json:"length"`
+	Bytes  int64 `json:"bytes"`
+
+	// VersionID and ETag identify the version of the object read, as far as they are known.
+	VersionID string `json:"version_id,omitempty"`
+	ETag      string `json:"etag,omitempty"`
+
+	// Error describes why the read failed, if it did. Reads cut short by the end of the object are not failures.
+	Error string `json:"error,omitempty"`
+
+	// Prev is the hex-encoded SHA-256 hash of the previous line of the log, without its newline, or of nothing for
+	// the first line. It chains the lines together, so that altering, removing or inserting a line is detected by
+	// VerifyAuditLog.
+	Prev string `json:"prev"`
+}
+
+// AuditLog is an append-only, tamper-evident record of reads, for data whose access must be accounted for. Each read
+// is written as one line of JSON, as described by AuditEntry, chained to the line before it by its hash. Pass one to
+// Options.AuditLog of any number of S3ReaderAts to record their reads in one chain. It is safe for concurrent use.
+//
+// The chain shows that no line was altered, removed or inserted, but not that lines were not removed from the end;
+// keep a copy of the last hash, returned by Head, elsewhere to detect that.
+type AuditLog struct {
+	mu     sync.Mutex
+	w      io.Writer
+	closer io.Closer
+	prev   string
+}
+
+// NewAuditLog returns an AuditLog that starts a new chain in w.
+func NewAuditLog(w io.Writer) *AuditLog {
+	return &AuditLog{w: w, prev: auditHash(nil)}
+}
+
+// OpenAuditLog opens the audit log at path for appending, creating it if it does not exist. An existing log is
+// verified first, and new lines continue its chain; if it fails to verify, the error matches ErrAuditLogTampered and
+// nothing is appended. Close the AuditLog to close the file.
+func OpenAuditLog(path string) (*AuditLog, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	_, prev, err := verifyAuditLog(f)
+	if err != nil {
+		_ = f.Close()
+		return nil, errors.Wrapf(err, "unable to verify %s", path)
+	}
+
+	return &AuditLog{w: f, closer: f, prev: prev}, nil
+}
+
+// Record appends entry to the log, setting its Prev field.
+func (l *AuditLog) Record(entry AuditEntry) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	entry.Prev = l.prev
+	b, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	if _, err = l.w.Write(append(b, '\n')); err != nil {
+		return errors.Wrap(err, "unable to write audit log")
+	}
+	l.prev = auditHash(b)
+
+	return nil
+}
+
+// Head returns the hex-encoded SHA-256 hash of the last line written, which the next line's Prev will hold.
+func (l *AuditLog) Head() string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.prev
+}
+
+// Close closes the file opened by OpenAuditLog. It does nothing for an AuditLog made by NewAuditLog.
+func (l *AuditLog) Close() error {
+	if l.closer == nil {
+		return nil
+	}
+	return l.closer.Close()
+}
+
+// VerifyAuditLog reads an audit log from r and checks its hash chain, returning the number of entries. If a line does
+// not follow the one before it, the error matches ErrAuditLogTampered and names the line.
+func VerifyAuditLog(r io.Reader) (int, error) {
+	n, _, err := verifyAuditLog(r)
+	return n, err
+}
+
+// verifyAuditLog implements VerifyAuditLog, also returning the hash of the last line.
+func verifyAuditLog(r io.Reader) (int, string, error) {
+	prev := auditHash(nil)
+	br := bufio.NewReader(r)
+	for n := 0; ; n++ {
+		line, err := br.ReadBytes('\n')
+		if err == io.EOF && len(line) == 0 {
+			return n, prev, nil
+		} else if err == io.EOF {
+			return n, prev, errors.Wrapf(ErrAuditLogTampered, "line %d is incomplete", n+1)
+		} else if err != nil {
+			return n, prev, err
+		}
+		line = bytes.TrimSuffix(line, []byte("\n"))
+
+		var entry AuditEntry
+		if err = json.Unmarshal(line, &entry); err != nil {
+			return n, prev, errors.Wrapf(ErrAuditLogTampered, "line %d is not an audit entry: %v", n+1, err)
+		} else if entry.Prev != prev {
+			return n, prev, errors.Wrapf(ErrAuditLogTampered, "line %d does not follow the line before it", n+1)
+		}
+		prev = auditHash(line)
+	}
+}
+
+// auditHash returns the hex-encoded SHA-256 hash of a line of an audit log.
+func auditHash(line []byte) string {
+	sum := sha256.Sum256(line)
+	return hex.EncodeToString(sum[:])
+}
+
+// audit records a read of length bytes at off, which returned n bytes and err, in the audit log, if there is one,
+// returning n and err. If the read cannot be recorded, it fails instead, so that no read goes unrecorded.
+func (ra *S3ReaderAt) audit(ctx context.Context, operation string, off int64, length int64, n int64,
+	err error) (int64, error) {
+	if ra.auditLog == nil {
+		return n, err
+	}
+
+	ra.etagMu.Lock()
+	entry := AuditEntry{
+		Time:      time.Now(),
+		Operation: operation,
+		Tags:      tagsFromContext(ctx),
+		Bucket:    ra.bucket,
+		Key:       ra.key,
+		Offset:    off,
+		Length:    length,
+		Bytes:     n,
+		VersionID: ra.versionID,
+		ETag:      ra.etag,
+	}
+	ra.etagMu.Unlock()
+	if err != nil && err != io.EOF {
+		entry.Error = err.Error()
+	}
+
+	if recordErr := ra.auditLog.Record(entry); recordErr != nil {
+		return 0, recordErr
+	}
+	return n, err
+}
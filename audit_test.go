@@ -0,0 +1,135 @@
+package s3readerat
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+// failingWriter fails every write.
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) {
+	return 0, errors.New("disk full")
+}
+
+// TestAuditLog tests that reads are recorded in the audit log, including who they were made for and reads that fail,
+// and that VerifyAuditLog detects lines being altered or removed.
+func TestAuditLog(t *testing.T) {
+	f := newFakeS3(t)
+	f.put("bucket", "key", []byte("0123456789"))
+
+	var buf bytes.Buffer
+	auditLog := NewAuditLog(&buf)
+	ra, err := NewWithOptions(Options{Client: f.client(), Bucket: "bucket", Key: "key", AuditLog: auditLog})
+	if err != nil {
+		t.Fatalf("Error calling NewWithOptions: %v", err)
+	}
+
+	ctx := WithTags(context.Background(), Tag{Key: "user", Value: "alice"})
+	if _, err = ra.ReadAtContext(ctx, make([]byte, 4), 2); err != nil {
+		t.Fatalf("Error calling ReadAtContext: %v", err)
+	}
+	if _, err = ra.ReadAt(make([]byte, 4), 8); err != io.EOF {
+		t.Fatalf("Expected io.EOF reading past the end, got %v", err)
+	}
+	if _, err = ra.ReadAt(make([]byte, 4), -1); err == nil {
+		t.Fatalf("Expected reading at a negative offset to fail")
+	}
+	if _, err = ra.CopyRange(ioutil.Discard, 5, -1); err != nil {
+		t.Fatalf("Error calling CopyRange: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("Expected 4 audit entries, got %d", len(lines))
+	}
+	var entries []AuditEntry
+	for _, line := range lines {
+		var entry AuditEntry
+		if err = json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("Error parsing audit entry: %v", err)
+		}
+		entries = append(entries, entry)
+	}
+
+	if e := entries[0]; e.Operation != "ReadAt" || e.Tags["user"] != "alice" || e.Offset != 2 || e.Bytes != 4 ||
+		e.ETag == "" || e.Error != "" {
+		t.Fatalf("Unexpected audit entry for a tagged read: %+v", e)
+	}
+	if e := entries[1]; e.Length != 4 || e.Bytes != 2 || e.Error != "" {
+		t.Fatalf("Unexpected audit entry for a read past the end: %+v", e)
+	}
+	if e := entries[2]; e.Bytes != 0 || e.Error == "" {
+		t.Fatalf("Unexpected audit entry for a failed read: %+v", e)
+	}
+	if e := entries[3]; e.Operation != "CopyRange" || e.Offset != 5 || e.Length != 5 || e.Bytes != 5 {
+		t.Fatalf("Unexpected audit entry for CopyRange: %+v", e)
+	}
+
+	if n, err := VerifyAuditLog(strings.NewReader(buf.String())); err != nil || n != 4 {
+		t.Fatalf("Expected the audit log to verify with 4 entries, got %d (%v)", n, err)
+	}
+
+	altered := strings.Replace(buf.String(), `"user":"alice"`, `"user":"mallory"`, 1)
+	if _, err = VerifyAuditLog(strings.NewReader(altered)); !errors.Is(err, ErrAuditLogTampered) {
+		t.Fatalf("Expected an altered line to be detected, got %v", err)
+	}
+
+	removed := strings.Join(append(lines[:1:1], lines[2:]...), "\n") + "\n"
+	if _, err = VerifyAuditLog(strings.NewReader(removed)); !errors.Is(err, ErrAuditLogTampered) {
+		t.Fatalf("Expected a removed line to be detected, got %v", err)
+	}
+
+	ra, err = NewWithOptions(Options{Client: f.client(), Bucket: "bucket", Key: "key",
+		AuditLog: NewAuditLog(failingWriter{})})
+	if err != nil {
+		t.Fatalf("Error calling NewWithOptions: %v", err)
+	}
+	if n, err := ra.ReadAt(make([]byte, 4), 0); err == nil || n != 0 {
+		t.Fatalf("Expected a read that cannot be audited to fail, got %d bytes (%v)", n, err)
+	}
+}
+
+// TestOpenAuditLog tests that OpenAuditLog continues the chain of an existing log, and refuses to append to one that
+// fails to verify.
+func TestOpenAuditLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+
+	for i := 0; i < 2; i++ {
+		auditLog, err := OpenAuditLog(path)
+		if err != nil {
+			t.Fatalf("Error calling OpenAuditLog: %v", err)
+		}
+		for j := 0; j < 2; j++ {
+			if err = auditLog.Record(AuditEntry{Operation: "ReadAt", Bucket: "bucket", Key: "key"}); err != nil {
+				t.Fatalf("Error calling Record: %v", err)
+			}
+		}
+		if err = auditLog.Close(); err != nil {
+			t.Fatalf("Error closing audit log: %v", err)
+		}
+	}
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Error reading audit log: %v", err)
+	}
+	if n, err := VerifyAuditLog(bytes.NewReader(b)); err != nil || n != 4 {
+		t.Fatalf("Expected the audit log to verify with 4 entries, got %d (%v)", n, err)
+	}
+
+	if err = ioutil.WriteFile(path, bytes.Replace(b, []byte(`"key"`), []byte(`"other"`), 1), 0600); err != nil {
+		t.Fatalf("Error writing audit log: %v", err)
+	}
+	if _, err = OpenAuditLog(path); !errors.Is(err, ErrAuditLogTampered) {
+		t.Fatalf("Expected OpenAuditLog to refuse a tampered log, got %v", err)
+	}
+}
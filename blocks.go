@@ -0,0 +1,165 @@
+package s3readerat
+
+import (
+	"context"
+	"io"
+	"log"
+	"sync"
+
+	"github.com/markandrus/s3readerat/cache"
+)
+
+// minRequestBlocks is the number of blocks of Options.MinRequestSize bytes an S3ReaderAt keeps, besides those read
+// ahead.
+const minRequestBlocks = cache.DefaultBlocks
+
+// memoryBlocks holds the blocks of the object fetched because of Options.MinRequestSize, unless Options.BlockStore is
+// set. Its store is replaced when MinRequestSize or Options.ReadAhead changes, since blocks of one size cannot serve
+// another.
+type memoryBlocks struct {
+	mu     sync.Mutex
+	store  *cache.MemoryStore
+	blocks int
+}
+
+// get returns the store of blocks of blockSize bytes, keeping as many as blocks, replacing a store of another size.
+func (s *memoryBlocks) get(blockSize int64, blocks int) *cache.MemoryStore {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.store == nil || s.store.BlockSize() != blockSize || s.blocks != blocks {
+		s.store = cache.NewMemoryStore(blockSize, blockSize*int64(blocks))
+		s.blocks = blocks
+	}
+	return s.store
+}
+
+// blockSize returns the size of the blocks that reads are rounded out to: that of Options.BlockStore, if set, or else
+// Options.MinRequestSize, which is zero if reads are not rounded.
+func (ra *S3ReaderAt) blockSize() int64 {
+	if ra.blockStore != nil {
+		return ra.blockStore.BlockSize()
+	}
+	return ra.tuning().MinRequestSize
+}
+
+// blocks returns the store of blocks that reads are rounded out to, and the object whose blocks to look up in it.
+// Blocks in Options.BlockStore are looked up by the object's name and ETag, as a rangeproxy.Server stores them, so the
+// ETag is fetched first with ctx if it is not yet known. If the service does not return ETags, the S3ReaderAt's own
+// memory is used instead, since blocks of one version of the object could otherwise be served for another.
+func (ra *S3ReaderAt) blocks(ctx context.Context) (cache.Store, cache.Object, error) {
+	t := ra.tuning()
+	if ra.blockStore == nil {
+		return ra.memoryBlocks.get(t.MinRequestSize, minRequestBlocks+t.ReadAhead), cache.Object{}, nil
+	}
+
+	etag, err := ra.ETagContext(ctx)
+	if err != nil {
+		return nil, cache.Object{}, err
+	}
+	if etag == "" {
+		return ra.memoryBlocks.get(ra.blockStore.BlockSize(), minRequestBlocks+t.ReadAhead), cache.Object{}, nil
+	}
+
+	object := cache.Object{Name: "s3://" + ra.bucket + "/" + ra.key, Version: etag, Size: ra.objectSize()}
+	return ra.blockStore, object, nil
+}
+
+// readRangeBlocks reads the inclusive byte range [first, last] into p, which is shorter than a block, from the blocks,
+// aligned to multiples of their size, that the range falls within. Blocks that are not kept are fetched with one
+// request and kept for later reads, unless they are being read ahead, in which case the read waits for them. A read
+// served entirely from kept blocks is counted as a cache hit.
+func (ra *S3ReaderAt) readRangeBlocks(ctx context.Context, p []byte, first int64, last int64) (int, error) {
+	store, object, err := ra.blocks(ctx)
+	if err != nil {
+		return 0, err
+	}
+	blockSize := store.BlockSize()
+
+	firstIndex := first / blockSize
+	blocks := make([][]byte, last/blockSize-firstIndex+1)
+	missingFirst, missingLast := -1, -1
+	for i := range blocks {
+		if data, ok := store.Get(object, firstIndex+int64(i)); ok {
+			blocks[i] = data
+			continue
+		}
+		if data, ok := ra.waitForReadAhead(ctx, store, object, firstIndex+int64(i)); ok {
+			blocks[i] = data
+			continue
+		}
+		if missingFirst < 0 {
+			missingFirst = i
+		}
+		missingLast = i
+	}
+
+	if missingFirst >= 0 {
+		err = ra.fetchBlocks(ctx, store, object, blocks[missingFirst:missingLast+1], firstIndex+int64(missingFirst))
+	}
+
+	var n int
+	for i, data := range blocks {
+		start := first + int64(n) - (firstIndex+int64(i))*blockSize
+		if data == nil || start >= int64(len(data)) {
+			break
+		}
+		n += copy(p[n:], data[start:])
+	}
+
+	if n > 0 {
+		ra.readAheadOf(store, object, first, first+int64(n)-1)
+	}
+
+	switch {
+	case n == len(p) && missingFirst < 0:
+		ra.recordCacheHit(ctx, p, first)
+		return n, nil
+	case n == len(p):
+		return n, nil
+	case err != nil:
+		return n, err
+	default:
+		return n, io.EOF
+	}
+}
+
+// fetchBlocks fills blocks, consecutive blocks of store starting with the one at index, with one request, and keeps
+// them in store as blocks of object. Blocks past the end of the object are left empty.
+func (ra *S3ReaderAt) fetchBlocks(ctx context.Context, store cache.Store, object cache.Object, blocks [][]byte,
+	index int64) error {
+	blockSize := store.BlockSize()
+	first := index * blockSize
+	last := first + int64(len(blocks))*blockSize - 1
+	if size := ra.objectSize(); size >= 0 && last > size-1 {
+		last = size - 1
+	}
+
+	if ra.debug() {
+		log.Printf("Fetching bytes %d-%d of S3 object s3://%s/%s as blocks", first, last, ra.bucket, ra.key)
+	}
+
+	buf := make([]byte, last-first+1)
+	n, err := ra.readRangeRetrying(ctx, buf, first, last)
+	if err != nil && err != io.EOF {
+		return err
+	}
+
+	buf = buf[:n]
+	for i := range blocks {
+		if len(buf) == 0 {
+			break
+		}
+		data := buf
+		if int64(len(data)) > blockSize {
+			data = buf[:blockSize]
+		}
+		buf = buf[len(data):]
+
+		blocks[i] = data
+		// The store is only a cache, so failing to store a block does not fail the read.
+		_ = store.Put(object, index+int64(i), data)
+	}
+
+	return nil
+}
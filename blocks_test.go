@@ -0,0 +1,127 @@
+package s3readerat
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/markandrus/s3readerat/cache"
+	"github.com/pkg/errors"
+)
+
+// TestMinRequestSize tests that reads shorter than MinRequestSize are rounded out to aligned blocks, which later reads
+// within them are served from without a request.
+func TestMinRequestSize(t *testing.T) {
+	f := newFakeS3(t)
+	f.put("bucket", "key", []byte("0123456789abcdefghij"))
+
+	s3ReaderAt, err := NewWithOptions(Options{Client: f.client(), Bucket: "bucket", Key: "key", MinRequestSize: 8})
+	if err != nil {
+		t.Fatalf("Error calling NewWithOptions: %v", err)
+	}
+
+	for _, read := range []struct {
+		off      int64
+		n        int
+		expected string
+		err      error
+	}{{1, 2, "12", nil}, {5, 2, "56", nil}, {6, 4, "6789", nil}, {17, 4, "hij", io.EOF}, {12, 8, "cdefghij", nil}} {
+		b := make([]byte, read.n)
+		n, err := s3ReaderAt.ReadAt(b, read.off)
+		if err != read.err || string(b[:n]) != read.expected {
+			t.Fatalf("Expected to read %q (%v) at offset %d, got %q (%v)", read.expected, read.err, read.off, b[:n],
+				err)
+		}
+	}
+
+	var ranges []string
+	f.mu.Lock()
+	for _, r := range f.requests {
+		if r.Method == http.MethodGet {
+			ranges = append(ranges, r.Header.Get("Range"))
+		}
+	}
+	f.mu.Unlock()
+	expected := []string{"bytes=0-7", "bytes=8-15", "bytes=16-19", "bytes=12-19"}
+	if !reflect.DeepEqual(ranges, expected) {
+		t.Fatalf("Expected ranges %v, got %v", expected, ranges)
+	}
+	if stats := s3ReaderAt.Stats(); stats.CacheHits != 1 {
+		t.Fatalf("Expected 1 cache hit, got %d", stats.CacheHits)
+	}
+}
+
+// TestBlockStore tests that blocks kept in a BlockStore serve the reads of a later S3ReaderAt of the same object, and
+// not those of an S3ReaderAt of a new version of it.
+func TestBlockStore(t *testing.T) {
+	f := newFakeS3(t)
+	f.put("bucket", "key", []byte("0123456789abcdefghij"))
+
+	store, err := cache.OpenDiskStore(t.TempDir(), 8)
+	if err != nil {
+		t.Fatalf("Error calling OpenDiskStore: %v", err)
+	}
+
+	read := func(off int64, expected string) {
+		s3ReaderAt, err := NewWithOptions(Options{Client: f.client(), Bucket: "bucket", Key: "key", BlockStore: store})
+		if err != nil {
+			t.Fatalf("Error calling NewWithOptions: %v", err)
+		}
+		b := make([]byte, len(expected))
+		if n, err := s3ReaderAt.ReadAt(b, off); err != nil || string(b[:n]) != expected {
+			t.Fatalf("Expected to read %q, got %q (%v)", expected, b[:n], err)
+		}
+	}
+
+	read(1, "12")
+	read(4, "4567")
+	if count := f.requestCount(http.MethodGet); count != 1 {
+		t.Fatalf("Expected 1 GetObject request, got %d", count)
+	}
+
+	f.put("bucket", "key", []byte("ABCDEFGHIJ"))
+	read(4, "EFGH")
+	if count := f.requestCount(http.MethodGet); count != 2 {
+		t.Fatalf("Expected 2 GetObject requests, got %d", count)
+	}
+}
+
+// TestBlockStoreWithoutETag tests that, when the service does not return ETags, reads with Options.BlockStore set ask
+// for the ETag once, with the read's context, and are served from the S3ReaderAt's own memory.
+func TestBlockStoreWithoutETag(t *testing.T) {
+	f := newFakeS3(t)
+	f.put("bucket", "key", []byte("0123456789abcdefghij"))
+	f.mu.Lock()
+	f.objects["bucket/key"].etag = ""
+	f.mu.Unlock()
+
+	store, err := cache.OpenDiskStore(t.TempDir(), 8)
+	if err != nil {
+		t.Fatalf("Error calling OpenDiskStore: %v", err)
+	}
+	s3ReaderAt, err := NewWithOptions(Options{Client: f.client(), Bucket: "bucket", Key: "key", BlockStore: store})
+	if err != nil {
+		t.Fatalf("Error calling NewWithOptions: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err = s3ReaderAt.ReadAtContext(ctx, make([]byte, 2), 1); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected a read with a canceled context to fail with context.Canceled, got %v", err)
+	}
+
+	for _, off := range []int64{1, 4, 9, 12} {
+		b := make([]byte, 2)
+		if n, err := s3ReaderAt.ReadAt(b, off); err != nil || n != 2 {
+			t.Fatalf("Error calling ReadAt: %v", err)
+		}
+	}
+	if count := f.requestCount(http.MethodHead); count != 1 {
+		t.Fatalf("Expected 1 HeadObject request, got %d", count)
+	}
+	if count := f.requestCount(http.MethodGet); count != 2 {
+		t.Fatalf("Expected 2 GetObject requests, got %d", count)
+	}
+}
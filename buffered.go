@@ -0,0 +1,143 @@
+package s3readerat
+
+import (
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultBufferedReadSeekerBufferSize is the number of bytes a BufferedReadSeeker fetches at a time by default.
+const DefaultBufferedReadSeekerBufferSize = 64 << 10
+
+// BufferedReadSeekerOptions configures a BufferedReadSeeker.
+type BufferedReadSeekerOptions struct {
+	// BufferSize is the number of bytes to fetch whenever the buffer is refilled. The default is
+	// DefaultBufferedReadSeekerBufferSize.
+	BufferSize int
+
+	// BypassThreshold is the size at or above which a read that misses the buffer is passed straight to the
+	// underlying ReaderAt, rather than copied through the buffer. The default is BufferSize.
+	BypassThreshold int
+}
+
+// BufferedReadSeeker is an io.ReadSeeker over a remote object that fetches BufferSize bytes at a time, so that
+// parsers making many small sequential reads, and that do not use bufio themselves, do not issue a request per read.
+// Seeks that land in the buffer are served from it. It is not safe for concurrent use.
+type BufferedReadSeeker struct {
+	r               io.ReaderAt
+	size            int64
+	bypassThreshold int
+
+	// buf holds the bytes starting at bufOff; pos is the offset of the next Read.
+	buf    []byte
+	bufLen int
+	bufOff int64
+	pos    int64
+}
+
+var _ io.ReadSeeker = (*BufferedReadSeeker)(nil)
+
+// NewBufferedReadSeeker returns a BufferedReadSeeker over the first size bytes of r, positioned at the start.
+func NewBufferedReadSeeker(r io.ReaderAt, size int64, options BufferedReadSeekerOptions) *BufferedReadSeeker {
+	if options.BufferSize <= 0 {
+		options.BufferSize = DefaultBufferedReadSeekerBufferSize
+	}
+
+	if options.BypassThreshold <= 0 {
+		options.BypassThreshold = options.BufferSize
+	}
+
+	return &BufferedReadSeeker{
+		r:               r,
+		size:            size,
+		bypassThreshold: options.BypassThreshold,
+		buf:             make([]byte, options.BufferSize),
+	}
+}
+
+// Read reads up to len(p) bytes from the current position.
+func (b *BufferedReadSeeker) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+
+	if b.pos >= b.size {
+		return 0, io.EOF
+	}
+
+	if !b.buffered(b.pos) {
+		if len(p) >= b.bypassThreshold {
+			if remaining := b.size - b.pos; int64(len(p)) > remaining {
+				p = p[:remaining]
+			}
+			n, err := b.r.ReadAt(p, b.pos)
+			b.pos += int64(n)
+			if err == io.EOF && n > 0 {
+				err = nil
+			}
+			return n, err
+		}
+
+		if err := b.fill(); err != nil {
+			return 0, err
+		}
+	}
+
+	n := copy(p, b.buf[b.pos-b.bufOff:b.bufLen])
+	b.pos += int64(n)
+	return n, nil
+}
+
+// fill refills the buffer starting at the current position.
+func (b *BufferedReadSeeker) fill() error {
+	n := int64(len(b.buf))
+	if remaining := b.size - b.pos; n > remaining {
+		n = remaining
+	}
+
+	read, err := b.r.ReadAt(b.buf[:n], b.pos)
+	if int64(read) == n {
+		err = nil
+	} else if err == nil || err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+
+	b.bufOff = b.pos
+	b.bufLen = read
+	if read == 0 && err != nil {
+		return err
+	}
+	return nil
+}
+
+// buffered reports whether the byte at off is in the buffer.
+func (b *BufferedReadSeeker) buffered(off int64) bool {
+	return off >= b.bufOff && off < b.bufOff+int64(b.bufLen)
+}
+
+// Seek sets the position of the next Read. The buffer is kept if the new position lies within it, and discarded
+// otherwise; nothing is fetched until the next Read.
+func (b *BufferedReadSeeker) Seek(offset int64, whence int) (int64, error) {
+	var pos int64
+	switch whence {
+	case io.SeekStart:
+		pos = offset
+	case io.SeekCurrent:
+		pos = b.pos + offset
+	case io.SeekEnd:
+		pos = b.size + offset
+	default:
+		return 0, errors.Errorf("invalid whence %d", whence)
+	}
+
+	if pos < 0 {
+		return 0, ErrInvalidOffset
+	}
+
+	if !b.buffered(pos) {
+		b.bufLen = 0
+	}
+
+	b.pos = pos
+	return pos, nil
+}
@@ -0,0 +1,72 @@
+package s3readerat
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+// countingReaderAt wraps an io.ReaderAt, counting calls to ReadAt.
+type countingReaderAt struct {
+	r     io.ReaderAt
+	calls int
+}
+
+func (c *countingReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	c.calls++
+	return c.r.ReadAt(p, off)
+}
+
+// TestBufferedReadSeeker tests that small reads, and seeks within the buffer, are served from the buffer, while seeks
+// outside it and large reads are not.
+func TestBufferedReadSeeker(t *testing.T) {
+	data := strings.Repeat("0123456789", 10)
+	r := &countingReaderAt{r: strings.NewReader(data)}
+	b := NewBufferedReadSeeker(r, int64(len(data)), BufferedReadSeekerOptions{BufferSize: 16, BypassThreshold: 32})
+
+	p := make([]byte, 4)
+	for i := 0; i < 4; i++ {
+		if _, err := io.ReadFull(b, p); err != nil {
+			t.Fatalf("Error calling Read: %v", err)
+		}
+	}
+	if string(p) != "2345" || r.calls != 1 {
+		t.Fatalf("Expected to read \"2345\" with 1 ReadAt call, got %q with %d", p, r.calls)
+	}
+
+	if _, err := b.Seek(2, io.SeekStart); err != nil {
+		t.Fatalf("Error calling Seek: %v", err)
+	}
+	if _, err := io.ReadFull(b, p); err != nil {
+		t.Fatalf("Error calling Read: %v", err)
+	}
+	if string(p) != "2345" || r.calls != 1 {
+		t.Fatalf("Expected to read \"2345\" with 1 ReadAt call, got %q with %d", p, r.calls)
+	}
+
+	if _, err := b.Seek(-3, io.SeekEnd); err != nil {
+		t.Fatalf("Error calling Seek: %v", err)
+	}
+	rest, err := io.ReadAll(b)
+	if err != nil {
+		t.Fatalf("Error calling Read: %v", err)
+	}
+	if string(rest) != "789" || r.calls != 2 {
+		t.Fatalf("Expected to read \"789\" with 2 ReadAt calls, got %q with %d", rest, r.calls)
+	}
+
+	if _, err = b.Seek(50, io.SeekStart); err != nil {
+		t.Fatalf("Error calling Seek: %v", err)
+	}
+	large := make([]byte, 40)
+	if _, err = io.ReadFull(b, large); err != nil {
+		t.Fatalf("Error calling Read: %v", err)
+	}
+	if string(large) != data[50:90] || r.calls != 3 {
+		t.Fatalf("Expected a large read to bypass the buffer with 1 ReadAt call, got %d", r.calls-2)
+	}
+
+	if _, err = b.Seek(-1, io.SeekStart); err != ErrInvalidOffset {
+		t.Fatalf("Expected ErrInvalidOffset seeking before the start, got %v", err)
+	}
+}
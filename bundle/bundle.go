@@ -0,0 +1,332 @@
+// Package bundle records the ranges of an object read through an io.ReaderAt and exports them to a bundle file, from
+// which a ReaderAt can later repeat the same reads without network access. This suits air-gapped debugging and
+// reproducible analyses: record a session against S3 once, then replay it anywhere.
+//
+// A Recorder is a decorator like those in package cache, so it can wrap an S3ReaderAt directly or a stack of other
+// decorators:
+//
+//	rec := bundle.NewRecorder(s3ReaderAt, bundle.Metadata{Name: "s3://bucket/key", Version: etag, Size: size})
+//	// ... read through rec ...
+//	_, err = rec.WriteTo(f)
+//
+// and later:
+//
+//	r, err := bundle.Open("session.bundle")
+package bundle
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	s3readerat "github.com/markandrus/s3readerat"
+	"github.com/pkg/errors"
+)
+
+const (
+	// magic begins every bundle file. The version follows it, so the format can change without old bundles being
+	// misread. The rest of the file is a zstd stream holding the length of the JSON header, the header, and the bytes
+	// of each recorded range in order.
+	magic   = "S3RABNDL"
+	version = 1
+)
+
+var (
+	// ErrNotInBundle is returned by ReaderAt.ReadAt for reads of bytes that were not recorded.
+	ErrNotInBundle = errors.New("range not in bundle")
+
+	// ErrInvalidBundle is returned when reading a file that is not a bundle, or a bundle that is corrupt.
+	ErrInvalidBundle = errors.New("invalid bundle")
+)
+
+// Metadata describes the object a bundle was recorded from.
+type Metadata struct {
+	// Name names the object, such as "s3://bucket/key".
+	Name string `json:"name"`
+
+	// Version distinguishes the contents of successive objects with the same Name, such as an ETag.
+	Version string `json:"version,omitempty"`
+
+	// Size is the size of the object in bytes.
+	Size int64 `json:"size"`
+
+	// Created is when the bundle was written. WriteTo sets it if it is zero.
+	Created time.Time `json:"created"`
+}
+
+// Range is a range of bytes recorded in a bundle.
+type Range struct {
+	Offset int64 `json:"offset"`
+	Length int64 `json:"length"`
+}
+
+// header is the JSON that precedes the recorded bytes in a bundle. The bytes of each range follow in order.
+type header struct {
+	Metadata Metadata `json:"metadata"`
+	Ranges   []Range  `json:"ranges"`
+}
+
+// span is a recorded range and its bytes.
+type span struct {
+	off  int64
+	data []byte
+}
+
+func (s span) end() int64 {
+	return s.off + int64(len(s.data))
+}
+
+// Recorder passes reads through to an underlying io.ReaderAt, recording the bytes they return. Overlapping and
+// adjacent ranges are merged, so each byte is kept once. It is safe for concurrent use if the underlying io.ReaderAt
+// is.
+type Recorder struct {
+	r        io.ReaderAt
+	metadata Metadata
+
+	mu    sync.Mutex
+	spans []span
+}
+
+// NewRecorder returns a Recorder that records reads of r, which reads the object described by metadata.
+func NewRecorder(r io.ReaderAt, metadata Metadata) *Recorder {
+	return &Recorder{r: r, metadata: metadata}
+}
+
+// ReadAt reads from the underlying io.ReaderAt, recording the bytes read.
+func (rec *Recorder) ReadAt(p []byte, off int64) (int, error) {
+	n, err := rec.r.ReadAt(p, off)
+	if n > 0 {
+		rec.record(off, p[:n])
+	}
+	return n, err
+}
+
+// record adds a copy of data, read at off, to the recorded spans.
+func (rec *Recorder) record(off int64, data []byte) {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+
+	// Find the spans that overlap or touch [off, end), and replace them with one span covering them all.
+	end := off + int64(len(data))
+	i := sort.Search(len(rec.spans), func(i int) bool { return rec.spans[i].end() >= off })
+	j := i
+	first, last := off, end
+	for ; j < len(rec.spans) && rec.spans[j].off <= end; j++ {
+		if rec.spans[j].off < first {
+			first = rec.spans[j].off
+		}
+		if rec.spans[j].end() > last {
+			last = rec.spans[j].end()
+		}
+	}
+
+	merged := make([]byte, last-first)
+	for _, s := range rec.spans[i:j] {
+		copy(merged[s.off-first:], s.data)
+	}
+	copy(merged[off-first:], data)
+
+	spans := make([]span, 0, len(rec.spans)-(j-i)+1)
+	spans = append(spans, rec.spans[:i]...)
+	spans = append(spans, span{off: first, data: merged})
+	spans = append(spans, rec.spans[j:]...)
+	rec.spans = spans
+}
+
+// Ranges returns the ranges recorded so far, in order of offset.
+func (rec *Recorder) Ranges() []Range {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+
+	return ranges(rec.spans)
+}
+
+// WriteTo writes a bundle of the ranges recorded so far to w. It returns the number of bytes written.
+func (rec *Recorder) WriteTo(w io.Writer) (int64, error) {
+	rec.mu.Lock()
+	spans := rec.spans
+	rec.mu.Unlock()
+
+	metadata := rec.metadata
+	if metadata.Created.IsZero() {
+		metadata.Created = time.Now().UTC()
+	}
+
+	headerJSON, err := json.Marshal(header{Metadata: metadata, Ranges: ranges(spans)})
+	if err != nil {
+		return 0, err
+	}
+
+	cw := &countingWriter{w: w}
+	bw := bufio.NewWriter(cw)
+	if _, err = bw.WriteString(magic); err != nil {
+		return cw.n, err
+	}
+	if err = bw.WriteByte(version); err != nil {
+		return cw.n, err
+	}
+
+	zw, err := zstd.NewWriter(bw)
+	if err != nil {
+		return cw.n, err
+	}
+	if err = binary.Write(zw, binary.BigEndian, uint32(len(headerJSON))); err != nil {
+		return cw.n, err
+	}
+	if _, err = zw.Write(headerJSON); err != nil {
+		return cw.n, err
+	}
+	for _, s := range spans {
+		if _, err = zw.Write(s.data); err != nil {
+			return cw.n, err
+		}
+	}
+	if err = zw.Close(); err != nil {
+		return cw.n, err
+	}
+
+	err = bw.Flush()
+	return cw.n, err
+}
+
+// ranges returns the ranges of spans.
+func ranges(spans []span) []Range {
+	result := make([]Range, len(spans))
+	for i, s := range spans {
+		result[i] = Range{Offset: s.off, Length: int64(len(s.data))}
+	}
+	return result
+}
+
+// ReaderAt serves reads of the ranges recorded in a bundle, without network access. It is safe for concurrent use.
+type ReaderAt struct {
+	metadata Metadata
+	spans    []span
+}
+
+// Open reads the bundle at path.
+func Open(path string) (*ReaderAt, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return Read(f)
+}
+
+// Read reads a bundle from r, keeping its recorded bytes in memory.
+func Read(r io.Reader) (*ReaderAt, error) {
+	br := bufio.NewReader(r)
+	prefix := make([]byte, len(magic)+1)
+	if _, err := io.ReadFull(br, prefix); err != nil || string(prefix[:len(magic)]) != magic {
+		return nil, errors.Wrap(ErrInvalidBundle, "missing bundle header")
+	}
+	if prefix[len(magic)] != version {
+		return nil, errors.Wrapf(ErrInvalidBundle, "unsupported bundle version %d", prefix[len(magic)])
+	}
+
+	zr, err := zstd.NewReader(br)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+
+	body, err := ioutil.ReadAll(zr)
+	if err != nil {
+		return nil, errors.Wrapf(ErrInvalidBundle, "unable to decompress bundle: %v", err)
+	}
+
+	if len(body) < 4 {
+		return nil, errors.Wrap(ErrInvalidBundle, "truncated bundle")
+	}
+	headerLen := int64(binary.BigEndian.Uint32(body))
+	if headerLen > int64(len(body)-4) {
+		return nil, errors.Wrap(ErrInvalidBundle, "truncated bundle header")
+	}
+
+	var h header
+	if err = json.Unmarshal(body[4:4+headerLen], &h); err != nil {
+		return nil, errors.Wrapf(ErrInvalidBundle, "unable to parse bundle header: %v", err)
+	}
+
+	data := body[4+headerLen:]
+	spans := make([]span, len(h.Ranges))
+	next := int64(-1)
+	for i, rng := range h.Ranges {
+		if rng.Offset <= next || rng.Length <= 0 || rng.Offset+rng.Length > h.Metadata.Size ||
+			rng.Length > int64(len(data)) {
+			return nil, errors.Wrapf(ErrInvalidBundle, "invalid range of %d bytes at offset %d", rng.Length,
+				rng.Offset)
+		}
+		spans[i] = span{off: rng.Offset, data: data[:rng.Length]}
+		data = data[rng.Length:]
+		next = rng.Offset + rng.Length
+	}
+	if len(data) != 0 {
+		return nil, errors.Wrapf(ErrInvalidBundle, "%d bytes follow the last range", len(data))
+	}
+
+	return &ReaderAt{metadata: h.Metadata, spans: spans}, nil
+}
+
+// Metadata returns the metadata of the object the bundle was recorded from.
+func (b *ReaderAt) Metadata() Metadata {
+	return b.metadata
+}
+
+// Size returns the size of the object the bundle was recorded from.
+func (b *ReaderAt) Size() int64 {
+	return b.metadata.Size
+}
+
+// Ranges returns the ranges recorded in the bundle, in order of offset.
+func (b *ReaderAt) Ranges() []Range {
+	return ranges(b.spans)
+}
+
+// ReadAt reads len(p) bytes at off from the recorded ranges. Reads past the end of the object return io.EOF, as
+// reading the object would, and reads of bytes that were not recorded fail with ErrNotInBundle.
+func (b *ReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errors.Wrapf(s3readerat.ErrInvalidOffset, "offset %d is negative", off)
+	}
+	if off >= b.metadata.Size {
+		return 0, io.EOF
+	}
+
+	want := int64(len(p))
+	if want > b.metadata.Size-off {
+		want = b.metadata.Size - off
+	}
+
+	i := sort.Search(len(b.spans), func(i int) bool { return b.spans[i].end() > off })
+	if i == len(b.spans) || b.spans[i].off > off || b.spans[i].end() < off+want {
+		return 0, errors.Wrapf(ErrNotInBundle, "%d bytes at offset %d of %s", want, off, b.metadata.Name)
+	}
+
+	n := copy(p[:want], b.spans[i].data[off-b.spans[i].off:])
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// countingWriter counts the bytes written to w.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
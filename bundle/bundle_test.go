@@ -0,0 +1,68 @@
+package bundle
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	s3readerat "github.com/markandrus/s3readerat"
+	"github.com/pkg/errors"
+)
+
+// TestBundle tests that a bundle written by a Recorder serves the recorded ranges, merged, and nothing else.
+func TestBundle(t *testing.T) {
+	data := []byte("0123456789abcdefghijklmnopqrstuvwxyz")
+	rec := NewRecorder(bytes.NewReader(data), Metadata{Name: "s3://bucket/key", Version: `"etag"`,
+		Size: int64(len(data))})
+
+	b := make([]byte, 4)
+	for _, off := range []int64{0, 2, 10, 34} {
+		if _, err := rec.ReadAt(b, off); err != nil && err != io.EOF {
+			t.Fatalf("Error calling ReadAt: %v", err)
+		}
+	}
+
+	expected := []Range{{Offset: 0, Length: 6}, {Offset: 10, Length: 4}, {Offset: 34, Length: 2}}
+	if ranges := rec.Ranges(); len(ranges) != len(expected) || ranges[0] != expected[0] ||
+		ranges[1] != expected[1] || ranges[2] != expected[2] {
+		t.Fatalf("Expected ranges %v, got %v", expected, ranges)
+	}
+
+	path := filepath.Join(t.TempDir(), "session.bundle")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("Error creating bundle file: %v", err)
+	}
+	if _, err = rec.WriteTo(f); err != nil {
+		t.Fatalf("Error calling WriteTo: %v", err)
+	}
+	f.Close()
+
+	r, err := Open(path)
+	if err != nil {
+		t.Fatalf("Error calling Open: %v", err)
+	}
+	if metadata := r.Metadata(); metadata.Name != "s3://bucket/key" || metadata.Version != `"etag"` ||
+		metadata.Size != int64(len(data)) || metadata.Created.IsZero() {
+		t.Fatalf("Unexpected metadata %+v", metadata)
+	}
+
+	if n, err := r.ReadAt(b, 1); err != nil || !bytes.Equal(b[:n], data[1:5]) {
+		t.Fatalf("Expected %q reading recorded bytes, got %q and %v", data[1:5], b[:n], err)
+	}
+	if n, err := r.ReadAt(b, 34); err != io.EOF || !bytes.Equal(b[:n], data[34:]) {
+		t.Fatalf("Expected %q and io.EOF reading the end of the object, got %q and %v", data[34:], b[:n], err)
+	}
+	if _, err = r.ReadAt(b, 4); !errors.Is(err, ErrNotInBundle) {
+		t.Fatalf("Expected ErrNotInBundle reading bytes partly recorded, got %v", err)
+	}
+	if _, err = r.ReadAt(b, -1); !errors.Is(err, s3readerat.ErrInvalidOffset) {
+		t.Fatalf("Expected ErrInvalidOffset reading at a negative offset, got %v", err)
+	}
+
+	if _, err = Read(bytes.NewReader(data)); !errors.Is(err, ErrInvalidBundle) {
+		t.Fatalf("Expected ErrInvalidBundle reading a file that is not a bundle, got %v", err)
+	}
+}
@@ -0,0 +1,265 @@
+// Package cache provides an io.ReaderAt that keeps recently read blocks of an underlying io.ReaderAt in memory.
+//
+// Like the other decorators in this module (retry, metrics, ratelimit and checksum), it wraps any io.ReaderAt, so
+// layers can be stacked in whichever order suits. For example, to cache verified blocks of an object that is fetched
+// with retries:
+//
+//	var r io.ReaderAt = s3ReaderAt
+//	r = retry.New(r, retry.Options{})
+//	r, err = checksum.New(r, size, checksum.Options{BlockSize: 1 << 20, Sums: sums})
+//	r = cache.New(r, size, cache.Options{})
+package cache
+
+import (
+	"container/list"
+	"io"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// DefaultBlockSize is the number of bytes fetched at a time by default.
+	DefaultBlockSize = 1 << 20
+
+	// DefaultBlocks is the number of blocks kept by default.
+	DefaultBlocks = 8
+)
+
+// Options configures a ReaderAt.
+type Options struct {
+	// BlockSize is the number of bytes fetched at a time. Reads are rounded out to whole blocks. The default is
+	// DefaultBlockSize.
+	BlockSize int64
+
+	// Blocks is the number of blocks kept. When it is exceeded, the least recently used block is dropped. The default
+	// is DefaultBlocks.
+	Blocks int
+
+	// MaxBytes, if set, bounds the bytes kept in place of Blocks: the least recently used blocks are dropped while
+	// more than MaxBytes are kept.
+	MaxBytes int64
+
+	// Store, if set, holds blocks instead of the ReaderAt, so that they are shared with other ReaderAts using the same
+	// Store. Blocks and BlockSize are then ignored, and the Store's block size is used. Reads of any size go through
+	// the Store.
+	Store Store
+
+	// Object identifies the object being read in Store.
+	Object Object
+}
+
+// ErrNotCached is returned by reads through a ReaderAt returned by NewOffline of blocks its Store does not hold.
+var ErrNotCached = errors.New("block not cached")
+
+// Stats counts the blocks a ReaderAt looked up. Reads that bypass its blocks are not counted.
+type Stats struct {
+	// Hits counts blocks that were kept, and Misses blocks that were fetched.
+	Hits   int64
+	Misses int64
+
+	// HitBytes and MissBytes are the sizes of those blocks.
+	HitBytes  int64
+	MissBytes int64
+
+	// Evictions counts blocks dropped to make room for others. Blocks a Store evicts are not counted.
+	Evictions int64
+}
+
+// ReaderAt serves small reads from fixed-size blocks of an underlying io.ReaderAt, keeping the most recently used
+// blocks. Reads of at least a block bypass it. It is safe for concurrent use.
+type ReaderAt struct {
+	r         io.ReaderAt
+	size      int64
+	blockSize int64
+	maxBlocks int
+	maxBytes  int64
+	store     Store
+	object    Object
+
+	mu     sync.Mutex
+	lru    *list.List
+	blocks map[int64]*list.Element
+	bytes  int64
+	stats  Stats
+}
+
+type block struct {
+	index int64
+	data  []byte
+}
+
+// New returns a ReaderAt that caches blocks of the first size bytes of r.
+func New(r io.ReaderAt, size int64, options Options) *ReaderAt {
+	if options.BlockSize <= 0 {
+		options.BlockSize = DefaultBlockSize
+	}
+
+	if options.Blocks <= 0 {
+		options.Blocks = DefaultBlocks
+	}
+
+	if options.Store != nil {
+		options.BlockSize = options.Store.BlockSize()
+	}
+
+	return &ReaderAt{
+		r:         r,
+		size:      size,
+		blockSize: options.BlockSize,
+		maxBlocks: options.Blocks,
+		maxBytes:  options.MaxBytes,
+		store:     options.Store,
+		object:    options.Object,
+		lru:       list.New(),
+		blocks:    make(map[int64]*list.Element),
+	}
+}
+
+// NewOffline returns a ReaderAt that serves reads of object only from the blocks store holds, failing with
+// ErrNotCached for the rest. It suits reading a disk cache while the object itself cannot be reached.
+func NewOffline(store Store, object Object) *ReaderAt {
+	return New(notCached{}, object.Size, Options{Store: store, Object: object})
+}
+
+// notCached is the io.ReaderAt underlying a ReaderAt returned by NewOffline.
+type notCached struct{}
+
+func (notCached) ReadAt(p []byte, off int64) (int, error) {
+	return 0, errors.Wrapf(ErrNotCached, "%d bytes at offset %d", len(p), off)
+}
+
+// ReadAt reads len(p) bytes starting at off, fetching the blocks they fall within if they are not kept.
+func (c *ReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	if (c.store == nil && int64(len(p)) >= c.blockSize) || off < 0 {
+		return c.r.ReadAt(p, off)
+	}
+
+	n := 0
+	for n < len(p) {
+		if off+int64(n) >= c.size {
+			return n, io.EOF
+		}
+
+		index := (off + int64(n)) / c.blockSize
+		data, err := c.block(index)
+		if err != nil {
+			return n, err
+		}
+
+		n += copy(p[n:], data[off+int64(n)-index*c.blockSize:])
+	}
+
+	return n, nil
+}
+
+// block returns the block at index, fetching it if it is not kept.
+func (c *ReaderAt) block(index int64) ([]byte, error) {
+	if c.store != nil {
+		if data, ok := c.store.Get(c.object, index); ok {
+			c.record(data, true)
+			return data, nil
+		}
+
+		data, err := c.fetch(index)
+		if err != nil {
+			return nil, err
+		}
+		c.record(data, false)
+
+		// The Store is only a cache, so failing to store a block does not fail the read.
+		_ = c.store.Put(c.object, index, data)
+		return data, nil
+	}
+
+	c.mu.Lock()
+	if element, ok := c.blocks[index]; ok {
+		c.lru.MoveToFront(element)
+		data := element.Value.(*block).data
+		c.count(data, true)
+		c.mu.Unlock()
+		return data, nil
+	}
+	c.mu.Unlock()
+
+	data, err := c.fetch(index)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.count(data, false)
+	if element, ok := c.blocks[index]; ok {
+		// Another read fetched the same block concurrently.
+		return element.Value.(*block).data, nil
+	}
+
+	c.blocks[index] = c.lru.PushFront(&block{index: index, data: data})
+	c.bytes += int64(len(data))
+	for c.full() {
+		oldest := c.lru.Remove(c.lru.Back()).(*block)
+		delete(c.blocks, oldest.index)
+		c.bytes -= int64(len(oldest.data))
+		c.stats.Evictions++
+	}
+
+	return data, nil
+}
+
+// full reports whether more blocks are kept than allowed by MaxBytes, if it is set, or else by Blocks. c.mu must be
+// held.
+func (c *ReaderAt) full() bool {
+	if c.maxBytes > 0 {
+		return c.bytes > c.maxBytes && c.lru.Len() > 0
+	}
+	return c.lru.Len() > c.maxBlocks
+}
+
+// record counts a lookup of the block data in the Store, which hit if it was kept.
+func (c *ReaderAt) record(data []byte, hit bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.count(data, hit)
+}
+
+// count counts a lookup of the block data, which hit if it was kept. c.mu must be held.
+func (c *ReaderAt) count(data []byte, hit bool) {
+	if hit {
+		c.stats.Hits++
+		c.stats.HitBytes += int64(len(data))
+	} else {
+		c.stats.Misses++
+		c.stats.MissBytes += int64(len(data))
+	}
+}
+
+// Stats returns the blocks looked up so far. It is safe for concurrent use.
+func (c *ReaderAt) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.stats
+}
+
+// fetch reads the block at index from the underlying io.ReaderAt.
+func (c *ReaderAt) fetch(index int64) ([]byte, error) {
+	off := index * c.blockSize
+	n := c.blockSize
+	if n > c.size-off {
+		n = c.size - off
+	}
+
+	data := make([]byte, n)
+	read, err := c.r.ReadAt(data, off)
+	if int64(read) < n {
+		if err == nil || err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+
+	return data, nil
+}
@@ -0,0 +1,103 @@
+package cache
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+// countingReaderAt counts the reads made of r.
+type countingReaderAt struct {
+	r     io.ReaderAt
+	reads int
+}
+
+func (c *countingReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	c.reads++
+	return c.r.ReadAt(p, off)
+}
+
+// TestReaderAt tests that small reads are served from cached blocks, that the least recently used block is dropped,
+// and that reads past the end return io.EOF.
+func TestReaderAt(t *testing.T) {
+	data := []byte("0123456789abcdefghij")
+	counter := &countingReaderAt{r: bytes.NewReader(data)}
+	c := New(counter, int64(len(data)), Options{BlockSize: 8, Blocks: 2})
+
+	b := make([]byte, 4)
+	for _, off := range []int64{0, 2, 4, 6} {
+		if _, err := c.ReadAt(b, off); err != nil {
+			t.Fatalf("Error calling ReadAt: %v", err)
+		}
+		if string(b) != string(data[off:off+4]) {
+			t.Fatalf("Expected %q, got %q", data[off:off+4], b)
+		}
+	}
+	if counter.reads != 2 {
+		t.Fatalf("Expected 2 reads of the first two blocks, got %d", counter.reads)
+	}
+
+	if _, err := c.ReadAt(b, 16); err != nil {
+		t.Fatalf("Error calling ReadAt: %v", err)
+	}
+	if _, err := c.ReadAt(b, 0); err != nil {
+		t.Fatalf("Error calling ReadAt: %v", err)
+	}
+	if counter.reads != 4 {
+		t.Fatalf("Expected the first block to be dropped and read again, got %d reads", counter.reads)
+	}
+
+	n, err := c.ReadAt(b, 18)
+	if err != io.EOF || n != 2 || string(b[:n]) != "ij" {
+		t.Fatalf("Expected 2 bytes and io.EOF, got %d bytes and %v", n, err)
+	}
+}
+
+// TestReaderAtStats tests that MaxBytes bounds the bytes kept, and that Stats counts hits, misses and evictions.
+func TestReaderAtStats(t *testing.T) {
+	data := []byte("0123456789abcdefghij")
+	counter := &countingReaderAt{r: bytes.NewReader(data)}
+	c := New(counter, int64(len(data)), Options{BlockSize: 8, Blocks: 1, MaxBytes: 16})
+
+	b := make([]byte, 2)
+	for _, off := range []int64{0, 2, 16, 4, 8, 0} {
+		if _, err := c.ReadAt(b, off); err != nil {
+			t.Fatalf("Error calling ReadAt: %v", err)
+		}
+	}
+
+	// Reading the second block drops the least recently used 4-byte last block to keep 16 bytes.
+	expected := Stats{Hits: 3, Misses: 3, HitBytes: 24, MissBytes: 20, Evictions: 1}
+	if stats := c.Stats(); stats != expected {
+		t.Fatalf("Expected %+v, got %+v", expected, stats)
+	}
+	if counter.reads != 3 {
+		t.Fatalf("Expected 3 reads, got %d", counter.reads)
+	}
+}
+
+// TestNewOffline tests that an offline ReaderAt serves cached blocks and fails with ErrNotCached for the rest.
+func TestNewOffline(t *testing.T) {
+	store := NewMemoryStore(4, 1<<10)
+	object := Object{Name: "s3://bucket/key", Version: `"v1"`, Size: 10}
+	if err := store.Put(object, 0, []byte("0123")); err != nil {
+		t.Fatalf("Error calling Put: %v", err)
+	}
+	if err := store.Put(object, 2, []byte("89")); err != nil {
+		t.Fatalf("Error calling Put: %v", err)
+	}
+
+	r := NewOffline(store, object)
+	b := make([]byte, 4)
+	if n, err := r.ReadAt(b, 0); err != nil || string(b[:n]) != "0123" {
+		t.Fatalf("Expected \"0123\" reading a cached block, got %q and %v", b[:n], err)
+	}
+	if n, err := r.ReadAt(b, 8); err != io.EOF || string(b[:n]) != "89" {
+		t.Fatalf("Expected \"89\" and io.EOF reading the last block, got %q and %v", b[:n], err)
+	}
+	if _, err := r.ReadAt(b, 2); !errors.Is(err, ErrNotCached) {
+		t.Fatalf("Expected ErrNotCached reading a block that is not cached, got %v", err)
+	}
+}
@@ -0,0 +1,469 @@
+package cache
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	diskStoreFile  = "store.json"
+	diskLockFile   = "store.lock"
+	diskObjectFile = "object.json"
+	diskObjectsDir = "objects"
+	diskBlockExt   = ".block"
+	diskTempPrefix = ".tmp-"
+
+	// diskGCFraction is the fraction of DiskStoreOptions.MaxBytes a DiskStore writes between runs of GC.
+	diskGCFraction = 8
+
+	// diskKeyCheck is sealed with the key of an encrypted DiskStore and recorded in store.json, so that opening the
+	// store with another key fails rather than discarding every block.
+	diskKeyCheck = "s3readerat disk cache key check"
+)
+
+// DiskStore is a Store that keeps blocks in files under a directory, so that they outlive the process and can be
+// shared by processes on the same host. Each block is stored with a SHA-256 checksum of its data, object and index, and
+// Get discards blocks that fail to verify, or that are the wrong size, so that a damaged cache file is fetched again
+// rather than served. DiskStore only evicts blocks itself if opened with MaxBytes; otherwise, see GC.
+//
+// If opened with a Key, the DiskStore instead encrypts each block with AES-GCM, under a random nonce and bound to its
+// object and index, so that blocks of objects from encrypted buckets are not left readable on local disks. The
+// authentication tag then takes the place of the checksum.
+//
+// The directory holds store.json, recording the block size, store.lock, which processes lock so that GC does not remove
+// an object's directory while another process writes to it, and a directory per object version under objects/,
+// holding object.json, describing the object, and a file per block named after its index. Object names are not
+// encrypted. Unless opened with Shared, the files and directories are readable only by the user who created them.
+type DiskStore struct {
+	dir       string
+	blockSize int64
+	aead      cipher.AEAD
+
+	fileMode os.FileMode
+	dirMode  os.FileMode
+
+	maxBytes int64
+
+	mu      sync.Mutex
+	closed  bool
+	writes  sync.WaitGroup
+	corrupt int64
+	written int64
+}
+
+// ErrStoreClosed is returned by Put after a DiskStore is closed.
+var ErrStoreClosed = errors.New("store is closed")
+
+// DiskStoreOptions configures a DiskStore.
+type DiskStoreOptions struct {
+	// BlockSize is the size of the blocks to store. If it is not positive, the block size of the existing store is
+	// used, or else DefaultBlockSize. It is an error to open an existing store with a different block size.
+	BlockSize int64
+
+	// Key, if set, is the AES key, of 16, 24 or 32 bytes, with which to encrypt blocks. A store created with a Key can
+	// only be opened with the same Key, and a store created without one cannot be opened with one. The names and
+	// versions of objects are not encrypted, so an encrypted store is kept private as any other is, unless Shared.
+	Key []byte
+
+	// MaxBytes, if set, bounds the blocks the store holds. Each time the DiskStore has written another eighth of
+	// MaxBytes, Put runs GC with MaxBytes, evicting the least recently used blocks of every process sharing the store,
+	// so the store exceeds MaxBytes by at most an eighth for each process writing to it.
+	MaxBytes int64
+
+	// Shared, if set, makes the files and directories the store creates readable by other users, so that their
+	// processes can share it. By default, they are readable only by the user, since blocks of private objects and the
+	// names of objects, which are not encrypted even with a Key, would otherwise be readable by anyone on the host.
+	Shared bool
+}
+
+type diskStoreInfo struct {
+	BlockSize int64  `json:"block_size"`
+	KeyCheck  []byte `json:"key_check,omitempty"`
+}
+
+type diskObjectInfo struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	Size    int64  `json:"size"`
+}
+
+// DefaultDir returns the directory in which a DiskStore is kept if none is given: s3readerat under the per-user cache
+// directory returned by os.UserCacheDir, such as ~/.cache on Linux, ~/Library/Caches on macOS and %LocalAppData% on
+// Windows.
+func DefaultDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", errors.Wrap(err, "unable to find the user cache directory")
+	}
+
+	return filepath.Join(dir, "s3readerat"), nil
+}
+
+// OpenDiskStore opens the DiskStore in dir, or DefaultDir if dir is empty, creating it if it does not exist. If
+// blockSize is not positive, the block size of the existing store is used, or else DefaultBlockSize. It is an error to
+// open an existing store with a different block size.
+func OpenDiskStore(dir string, blockSize int64) (*DiskStore, error) {
+	return OpenDiskStoreWithOptions(dir, DiskStoreOptions{BlockSize: blockSize})
+}
+
+// OpenDiskStoreWithOptions is like OpenDiskStore, but accepts DiskStoreOptions, such as a Key to encrypt blocks with.
+func OpenDiskStoreWithOptions(dir string, options DiskStoreOptions) (*DiskStore, error) {
+	var aead cipher.AEAD
+	if options.Key != nil {
+		block, err := aes.NewCipher(options.Key)
+		if err != nil {
+			return nil, errors.Errorf("provided Key is invalid: %d bytes", len(options.Key))
+		}
+		if aead, err = cipher.NewGCM(block); err != nil {
+			return nil, err
+		}
+	}
+
+	if dir == "" {
+		var err error
+		if dir, err = DefaultDir(); err != nil {
+			return nil, err
+		}
+	}
+
+	fileMode, dirMode := diskModes(options.Shared)
+	if err := os.MkdirAll(filepath.Join(dir, diskObjectsDir), dirMode); err != nil {
+		return nil, errors.Wrap(err, "unable to create disk cache")
+	}
+
+	// Processes opening a new store at once must agree on its block size and key.
+	unlock, err := lockStore(dir, true, fileMode)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	var info diskStoreInfo
+	b, err := ioutil.ReadFile(filepath.Join(dir, diskStoreFile))
+	switch {
+	case err == nil:
+		if err = json.Unmarshal(b, &info); err != nil {
+			return nil, errors.Wrapf(err, "unable to parse %s", diskStoreFile)
+		}
+		if options.BlockSize > 0 && options.BlockSize != info.BlockSize {
+			return nil, errors.Errorf("disk cache %s has block size %d, not %d", dir, info.BlockSize,
+				options.BlockSize)
+		}
+		if info.KeyCheck == nil && aead != nil {
+			return nil, errors.Errorf("disk cache %s is not encrypted", dir)
+		} else if info.KeyCheck != nil && aead == nil {
+			return nil, errors.Errorf("disk cache %s is encrypted; a key is required", dir)
+		} else if info.KeyCheck != nil {
+			if _, err = open(aead, info.KeyCheck, []byte(diskKeyCheck)); err != nil {
+				return nil, errors.Errorf("disk cache %s is encrypted with another key", dir)
+			}
+		}
+	case os.IsNotExist(err):
+		info.BlockSize = options.BlockSize
+		if info.BlockSize <= 0 {
+			info.BlockSize = DefaultBlockSize
+		}
+		if aead != nil {
+			if info.KeyCheck, err = seal(aead, nil, []byte(diskKeyCheck)); err != nil {
+				return nil, err
+			}
+		}
+		if b, err = json.Marshal(info); err != nil {
+			return nil, err
+		}
+		if err = writeFileAtomic(filepath.Join(dir, diskStoreFile), b, fileMode); err != nil {
+			return nil, errors.Wrap(err, "unable to create disk cache")
+		}
+	default:
+		return nil, errors.Wrap(err, "unable to open disk cache")
+	}
+
+	return &DiskStore{dir: dir, blockSize: info.BlockSize, aead: aead, fileMode: fileMode, dirMode: dirMode,
+		maxBytes: options.MaxBytes}, nil
+}
+
+// diskModes returns the permissions of the files and directories of a DiskStore: readable only by the user, unless
+// shared with other users.
+func diskModes(shared bool) (os.FileMode, os.FileMode) {
+	if shared {
+		return 0644, 0755
+	}
+	return 0600, 0700
+}
+
+// BlockSize returns the size of the blocks the DiskStore holds.
+func (s *DiskStore) BlockSize() int64 {
+	return s.blockSize
+}
+
+// Get returns the block of object at index, if the DiskStore holds it and it verifies.
+func (s *DiskStore) Get(object Object, index int64) ([]byte, bool) {
+	path := s.blockPath(object, index)
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+
+	data, ok := s.decodeBlock(object, index, b)
+	if !ok || !s.validSize(object, index, int64(len(data))) {
+		_ = os.Remove(path)
+		s.mu.Lock()
+		s.corrupt++
+		s.mu.Unlock()
+		return nil, false
+	}
+
+	// Blocks' modification times record when they were last used, so that the least recently used can be evicted.
+	now := time.Now()
+	_ = os.Chtimes(path, now, now)
+
+	return data, true
+}
+
+// Put writes the block of object at index. After Close, it returns ErrStoreClosed.
+func (s *DiskStore) Put(object Object, index int64, data []byte) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return ErrStoreClosed
+	}
+	s.writes.Add(1)
+	s.mu.Unlock()
+	defer s.writes.Done()
+
+	if err := s.put(object, index, data); err != nil {
+		return err
+	}
+	return s.collect(int64(len(data)))
+}
+
+// put writes the block of object at index.
+func (s *DiskStore) put(object Object, index int64, data []byte) error {
+	// Hold the store's lock shared, so that GC does not remove the object's directory while the block is written.
+	unlock, err := lockStore(s.dir, false, s.fileMode)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	dir := s.objectDir(object)
+
+	if _, err := os.Stat(filepath.Join(dir, diskObjectFile)); os.IsNotExist(err) {
+		if err = os.MkdirAll(dir, s.dirMode); err != nil {
+			return errors.Wrap(err, "unable to create object directory")
+		}
+
+		b, err := json.Marshal(diskObjectInfo{Name: object.Name, Version: object.Version, Size: object.Size})
+		if err != nil {
+			return err
+		}
+		if err = writeFileAtomic(filepath.Join(dir, diskObjectFile), b, s.fileMode); err != nil {
+			return errors.Wrap(err, "unable to write object description")
+		}
+	}
+
+	b, err := s.encodeBlock(object, index, data)
+	if err != nil {
+		return err
+	}
+	if err = writeFileAtomic(s.blockPath(object, index), b, s.fileMode); err != nil {
+		return errors.Wrap(err, "unable to write block")
+	}
+
+	return nil
+}
+
+// collect counts n bytes written, running GC once MaxBytes/diskGCFraction bytes have been written since it last ran.
+// GC locks the store exclusively, so it must not be called while writing a block.
+func (s *DiskStore) collect(n int64) error {
+	if s.maxBytes <= 0 {
+		return nil
+	}
+
+	s.mu.Lock()
+	s.written += n
+	due := s.written >= s.maxBytes/diskGCFraction
+	if due {
+		s.written = 0
+	}
+	s.mu.Unlock()
+
+	if !due {
+		return nil
+	}
+	_, err := s.GC(GCOptions{MaxBytes: s.maxBytes})
+	return err
+}
+
+// CorruptBlocks returns the number of blocks Get has discarded since the DiskStore was opened because they failed to
+// verify. Each was a cache miss, and so was fetched again by the caller.
+func (s *DiskStore) CorruptBlocks() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.corrupt
+}
+
+// Close waits for blocks being written to be renamed into place, so that none are left partially written, and stops
+// further writes. Blocks can still be read after Close.
+func (s *DiskStore) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	s.mu.Unlock()
+
+	s.writes.Wait()
+	return nil
+}
+
+// lockStore locks the store in dir, shared or exclusively, against other processes and other DiskStores in this one,
+// returning a function that unlocks it. The lock file is created with mode if it does not exist. It is opened
+// read-only, so that other users' processes sharing the store can lock it too.
+func lockStore(dir string, exclusive bool, mode os.FileMode) (func(), error) {
+	f, err := os.OpenFile(filepath.Join(dir, diskLockFile), os.O_RDONLY|os.O_CREATE, mode)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to open disk cache lock")
+	}
+
+	if err = lockFile(f, exclusive); err != nil {
+		f.Close()
+		return nil, errors.Wrap(err, "unable to lock disk cache")
+	}
+
+	return func() {
+		_ = unlockFile(f)
+		f.Close()
+	}, nil
+}
+
+// objectDir returns the directory holding the blocks of object.
+func (s *DiskStore) objectDir(object Object) string {
+	sum := sha256.Sum256([]byte(object.Name + "\x00" + object.Version))
+	return filepath.Join(s.dir, diskObjectsDir, hex.EncodeToString(sum[:16]))
+}
+
+func (s *DiskStore) blockPath(object Object, index int64) string {
+	return filepath.Join(s.objectDir(object), strconv.FormatInt(index, 10)+diskBlockExt)
+}
+
+// encodeBlock returns the contents of the file holding the block of object at index: its data, prefixed with its
+// checksum or, if the store is encrypted, sealed.
+func (s *DiskStore) encodeBlock(object Object, index int64, data []byte) ([]byte, error) {
+	if s.aead != nil {
+		b, err := seal(s.aead, data, blockAdditionalData(object, index))
+		return b, errors.Wrap(err, "unable to encrypt block")
+	}
+
+	sum := blockChecksum(object, index, data)
+	return append(sum[:], data...), nil
+}
+
+// decodeBlock verifies the contents of the file holding the block of object at index, returning the block's data.
+func (s *DiskStore) decodeBlock(object Object, index int64, b []byte) ([]byte, bool) {
+	if s.aead != nil {
+		data, err := open(s.aead, b, blockAdditionalData(object, index))
+		return data, err == nil
+	}
+
+	return verifyBlock(object, index, b)
+}
+
+// blockOverhead returns the number of bytes a block file holds beyond the block's data.
+func (s *DiskStore) blockOverhead() int64 {
+	if s.aead != nil {
+		return int64(s.aead.NonceSize() + s.aead.Overhead())
+	}
+	return sha256.Size
+}
+
+// verifyBlock checks the checksum at the start of the file holding the block of object at index, returning the
+// block's data.
+func verifyBlock(object Object, index int64, b []byte) ([]byte, bool) {
+	if len(b) < sha256.Size {
+		return nil, false
+	}
+
+	sum := blockChecksum(object, index, b[sha256.Size:])
+	return b[sha256.Size:], bytes.Equal(sum[:], b[:sha256.Size])
+}
+
+// blockChecksum returns the checksum of the block of object at index. It covers the object and index as well as the
+// data, so that a block file in the wrong place fails to verify.
+func blockChecksum(object Object, index int64, data []byte) [sha256.Size]byte {
+	h := sha256.New()
+	h.Write(blockAdditionalData(object, index))
+	h.Write(data)
+
+	var sum [sha256.Size]byte
+	h.Sum(sum[:0])
+	return sum
+}
+
+// blockAdditionalData identifies the block of object at index, binding its checksum or seal to it so that block files
+// cannot be swapped undetected.
+func blockAdditionalData(object Object, index int64) []byte {
+	return []byte(object.Name + "\x00" + object.Version + "\x00" + strconv.FormatInt(index, 10))
+}
+
+// seal encrypts and authenticates plaintext under a random nonce, which it prepends to the result.
+func seal(aead cipher.AEAD, plaintext []byte, additionalData []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+// open authenticates and decrypts the result of seal.
+func open(aead cipher.AEAD, b []byte, additionalData []byte) ([]byte, error) {
+	if len(b) < aead.NonceSize() {
+		return nil, errors.New("sealed data is too short")
+	}
+	return aead.Open(nil, b[:aead.NonceSize()], b[aead.NonceSize():], additionalData)
+}
+
+// writeFileAtomic writes b to a temporary file beside path, with mode, and renames it into place, so that readers,
+// including other processes, never see a partial file.
+func writeFileAtomic(path string, b []byte, mode os.FileMode) error {
+	f, err := ioutil.TempFile(filepath.Dir(path), diskTempPrefix)
+	if err != nil {
+		return err
+	}
+
+	if err = f.Chmod(mode); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+
+	if _, err = f.Write(b); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+
+	if err = f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+
+	if err = renameFile(f.Name(), path); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+
+	return nil
+}
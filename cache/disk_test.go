@@ -0,0 +1,361 @@
+package cache
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestDiskStore tests that blocks written to a DiskStore are read back, including after reopening it, that corrupt
+// blocks are discarded, and that the store cannot be reopened with a different block size.
+func TestDiskStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "diskstore")
+	if err != nil {
+		t.Fatalf("Error calling TempDir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	store, err := OpenDiskStore(dir, 8)
+	if err != nil {
+		t.Fatalf("Error calling OpenDiskStore: %v", err)
+	}
+
+	object := Object{Name: "s3://bucket/key", Version: `"v1"`, Size: 20}
+	if err = store.Put(object, 1, []byte("89abcdef")); err != nil {
+		t.Fatalf("Error calling Put: %v", err)
+	}
+
+	if store, err = OpenDiskStore(dir, 0); err != nil {
+		t.Fatalf("Error calling OpenDiskStore: %v", err)
+	}
+	if store.BlockSize() != 8 {
+		t.Fatalf("Expected block size 8, got %d", store.BlockSize())
+	}
+
+	data, ok := store.Get(object, 1)
+	if !ok || string(data) != "89abcdef" {
+		t.Fatalf("Expected block %q, got %q", "89abcdef", data)
+	}
+	if _, ok = store.Get(Object{Name: object.Name, Version: `"v2"`, Size: 20}, 1); ok {
+		t.Fatalf("Expected no block for another version")
+	}
+
+	path := store.blockPath(object, 1)
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Error calling ReadFile: %v", err)
+	}
+	if err = ioutil.WriteFile(path, bytes.Replace(b, []byte("abc"), []byte("xyz"), 1), 0644); err != nil {
+		t.Fatalf("Error calling WriteFile: %v", err)
+	}
+	if _, ok = store.Get(object, 1); ok {
+		t.Fatalf("Expected a corrupt block to be discarded")
+	}
+	if _, err = os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("Expected the corrupt block file to be removed, got %v", err)
+	}
+
+	if _, err = OpenDiskStore(dir, 16); err == nil {
+		t.Fatalf("Expected an error opening %s with a different block size", filepath.Base(dir))
+	}
+}
+
+// TestDiskStoreMaxBytes tests that a DiskStore opened with MaxBytes evicts the least recently used blocks as it writes.
+func TestDiskStoreMaxBytes(t *testing.T) {
+	store, err := OpenDiskStoreWithOptions(t.TempDir(), DiskStoreOptions{BlockSize: 4, MaxBytes: 8})
+	if err != nil {
+		t.Fatalf("Error calling OpenDiskStoreWithOptions: %v", err)
+	}
+
+	object := Object{Name: "s3://bucket/key", Version: `"v1"`, Size: 12}
+	for index, data := range []string{"0123", "4567", "89ab"} {
+		if err = store.Put(object, int64(index), []byte(data)); err != nil {
+			t.Fatalf("Error calling Put: %v", err)
+		}
+		// Make the order in which blocks were used unambiguous.
+		used := time.Now().Add(time.Duration(index-3) * time.Minute)
+		if err = os.Chtimes(store.blockPath(object, int64(index)), used, used); err != nil {
+			t.Fatalf("Error calling Chtimes: %v", err)
+		}
+	}
+
+	if _, ok := store.Get(object, 0); ok {
+		t.Fatalf("Expected the least recently used block to be evicted")
+	}
+	for _, index := range []int64{1, 2} {
+		if _, ok := store.Get(object, index); !ok {
+			t.Fatalf("Expected block %d to be kept", index)
+		}
+	}
+}
+
+// TestDiskStoreClose tests that blocks cannot be written to a closed DiskStore, but can still be read.
+func TestDiskStoreClose(t *testing.T) {
+	dir, err := ioutil.TempDir("", "diskstore")
+	if err != nil {
+		t.Fatalf("Error calling TempDir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	store, err := OpenDiskStore(dir, 8)
+	if err != nil {
+		t.Fatalf("Error calling OpenDiskStore: %v", err)
+	}
+
+	object := Object{Name: "s3://bucket/key", Version: `"v1"`, Size: 8}
+	if err = store.Put(object, 0, []byte("01234567")); err != nil {
+		t.Fatalf("Error calling Put: %v", err)
+	}
+	if err = store.Close(); err != nil {
+		t.Fatalf("Error calling Close: %v", err)
+	}
+
+	if err = store.Put(object, 0, []byte("01234567")); err != ErrStoreClosed {
+		t.Fatalf("Expected ErrStoreClosed, got %v", err)
+	}
+	if _, ok := store.Get(object, 0); !ok {
+		t.Fatalf("Expected to read a block after Close")
+	}
+}
+
+// TestDiskStoreEncrypted tests that blocks written to an encrypted DiskStore are not stored in the clear and are read
+// back, that blocks moved to another index are discarded, and that the store cannot be opened with another key or
+// without one.
+func TestDiskStoreEncrypted(t *testing.T) {
+	dir, err := ioutil.TempDir("", "diskstore")
+	if err != nil {
+		t.Fatalf("Error calling TempDir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	key := bytes.Repeat([]byte{1}, 32)
+	if _, err = OpenDiskStoreWithOptions(dir, DiskStoreOptions{Key: key[:5]}); err == nil {
+		t.Fatalf("Expected an error calling OpenDiskStoreWithOptions with a 5-byte key")
+	}
+
+	store, err := OpenDiskStoreWithOptions(dir, DiskStoreOptions{BlockSize: 8, Key: key})
+	if err != nil {
+		t.Fatalf("Error calling OpenDiskStoreWithOptions: %v", err)
+	}
+
+	object := Object{Name: "s3://bucket/key", Version: `"v1"`, Size: 16}
+	if err = store.Put(object, 0, []byte("01234567")); err != nil {
+		t.Fatalf("Error calling Put: %v", err)
+	}
+
+	b, err := ioutil.ReadFile(store.blockPath(object, 0))
+	if err != nil {
+		t.Fatalf("Error calling ReadFile: %v", err)
+	}
+	if bytes.Contains(b, []byte("01234567")) {
+		t.Fatalf("Expected the block file to be encrypted")
+	}
+	if runtime.GOOS != "windows" {
+		// The names of objects are not encrypted, so the store is still kept private.
+		checkPrivate(t, dir)
+	}
+
+	if store, err = OpenDiskStoreWithOptions(dir, DiskStoreOptions{Key: key}); err != nil {
+		t.Fatalf("Error calling OpenDiskStoreWithOptions: %v", err)
+	}
+	data, ok := store.Get(object, 0)
+	if !ok || string(data) != "01234567" {
+		t.Fatalf("Expected block %q, got %q", "01234567", data)
+	}
+
+	objects, err := store.Objects()
+	if err != nil {
+		t.Fatalf("Error calling Objects: %v", err)
+	}
+	if len(objects) != 1 || objects[0].CachedBytes() != 8 {
+		t.Fatalf("Expected one object with 8 cached bytes, got %+v", objects)
+	}
+
+	if err = ioutil.WriteFile(store.blockPath(object, 1), b, 0644); err != nil {
+		t.Fatalf("Error calling WriteFile: %v", err)
+	}
+	if _, ok = store.Get(object, 1); ok {
+		t.Fatalf("Expected a block moved to another index to be discarded")
+	}
+
+	if _, err = OpenDiskStore(dir, 0); err == nil {
+		t.Fatalf("Expected an error opening an encrypted store without a key")
+	}
+	if _, err = OpenDiskStoreWithOptions(dir, DiskStoreOptions{Key: bytes.Repeat([]byte{2}, 32)}); err == nil {
+		t.Fatalf("Expected an error opening an encrypted store with another key")
+	}
+}
+
+// TestDiskStorePermissions tests that the files and directories of a DiskStore are readable only by the user, unless
+// it is opened with Shared.
+func TestDiskStorePermissions(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Windows does not have Unix permissions")
+	}
+
+	dir := filepath.Join(t.TempDir(), "private")
+	store, err := OpenDiskStore(dir, 8)
+	if err != nil {
+		t.Fatalf("Error calling OpenDiskStore: %v", err)
+	}
+	if err = store.Put(Object{Name: "s3://bucket/key", Version: `"v1"`, Size: 8}, 0, []byte("01234567")); err != nil {
+		t.Fatalf("Error calling Put: %v", err)
+	}
+	checkPrivate(t, dir)
+
+	dir = filepath.Join(t.TempDir(), "shared")
+	if _, err = OpenDiskStoreWithOptions(dir, DiskStoreOptions{BlockSize: 8, Shared: true}); err != nil {
+		t.Fatalf("Error calling OpenDiskStoreWithOptions: %v", err)
+	}
+	info, err := os.Stat(filepath.Join(dir, diskStoreFile))
+	if err != nil {
+		t.Fatalf("Error calling Stat: %v", err)
+	}
+	if mode := info.Mode().Perm(); mode != 0644 {
+		t.Fatalf("Expected %s of a shared store to have mode 0644, got %#o", diskStoreFile, mode)
+	}
+}
+
+// checkPrivate fails the test if anything under dir, including dir itself, can be accessed by other users.
+func checkPrivate(t *testing.T, dir string) {
+	t.Helper()
+
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if mode := info.Mode().Perm(); mode&0077 != 0 {
+			t.Errorf("Expected %s to be accessible only by the user, got mode %#o", path, mode)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Error walking %s: %v", dir, err)
+	}
+}
+
+// TestDiskStoreRefetchesCorruptBlocks tests that a ReaderAt over a DiskStore serves the right bytes when a cached block
+// has been damaged or replaced with another block's file, fetching the block again and counting it as corrupt.
+func TestDiskStoreRefetchesCorruptBlocks(t *testing.T) {
+	dir, err := ioutil.TempDir("", "diskstore")
+	if err != nil {
+		t.Fatalf("Error calling TempDir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	store, err := OpenDiskStore(dir, 8)
+	if err != nil {
+		t.Fatalf("Error calling OpenDiskStore: %v", err)
+	}
+
+	data := []byte("0123456789abcdefghijklmn")
+	object := Object{Name: "s3://bucket/key", Version: `"v1"`, Size: int64(len(data))}
+	counter := &countingReaderAt{r: bytes.NewReader(data)}
+	c := New(counter, object.Size, Options{Store: store, Object: object})
+
+	b := make([]byte, len(data))
+	if _, err = c.ReadAt(b, 0); err != nil {
+		t.Fatalf("Error calling ReadAt: %v", err)
+	}
+
+	// Damage block 0, and replace block 1 with a valid copy of block 2.
+	path := store.blockPath(object, 0)
+	damaged, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Error calling ReadFile: %v", err)
+	}
+	damaged[len(damaged)-1] ^= 0xff
+	if err = ioutil.WriteFile(path, damaged, 0644); err != nil {
+		t.Fatalf("Error calling WriteFile: %v", err)
+	}
+	if err = os.Rename(store.blockPath(object, 2), store.blockPath(object, 1)); err != nil {
+		t.Fatalf("Error calling Rename: %v", err)
+	}
+
+	counter.reads = 0
+	if _, err = c.ReadAt(b, 0); err != nil {
+		t.Fatalf("Error calling ReadAt: %v", err)
+	}
+	if string(b) != string(data) {
+		t.Fatalf("Expected %q, got %q", data, b)
+	}
+	if counter.reads != 3 {
+		t.Fatalf("Expected all 3 blocks to be fetched again, got %d reads", counter.reads)
+	}
+	if corrupt := store.CorruptBlocks(); corrupt != 2 {
+		t.Fatalf("Expected 2 corrupt blocks, got %d", corrupt)
+	}
+}
+
+// TestDiskStoreDefaultDir tests that a DiskStore opened without a directory is kept in DefaultDir.
+func TestDiskStoreDefaultDir(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"XDG_CACHE_HOME", "HOME", "LocalAppData"} {
+		old, ok := os.LookupEnv(name)
+		if err := os.Setenv(name, dir); err != nil {
+			t.Fatalf("Error calling Setenv: %v", err)
+		}
+		defer func(name string) {
+			if ok {
+				os.Setenv(name, old)
+			} else {
+				os.Unsetenv(name)
+			}
+		}(name)
+	}
+
+	defaultDir, err := DefaultDir()
+	if err != nil {
+		t.Fatalf("Error calling DefaultDir: %v", err)
+	}
+	if !strings.HasPrefix(defaultDir, dir) {
+		t.Fatalf("Expected the default directory to be under %s, got %s", dir, defaultDir)
+	}
+
+	if _, err = OpenDiskStore("", 8); err != nil {
+		t.Fatalf("Error calling OpenDiskStore: %v", err)
+	}
+	if _, err = os.Stat(filepath.Join(defaultDir, diskStoreFile)); err != nil {
+		t.Fatalf("Expected the store to be created in %s, got %v", defaultDir, err)
+	}
+}
+
+// TestDiskStoreLock tests that Put waits while the store is locked exclusively, as GC and other processes opening the
+// store lock it.
+func TestDiskStoreLock(t *testing.T) {
+	dir := t.TempDir()
+	store, err := OpenDiskStore(dir, 8)
+	if err != nil {
+		t.Fatalf("Error calling OpenDiskStore: %v", err)
+	}
+
+	unlock, err := lockStore(dir, true, 0600)
+	if err != nil {
+		t.Fatalf("Error calling lockStore: %v", err)
+	}
+
+	done := make(chan error, 1)
+	object := Object{Name: "s3://bucket/key", Version: `"v1"`, Size: 8}
+	go func() {
+		done <- store.Put(object, 0, []byte("01234567"))
+	}()
+
+	select {
+	case err = <-done:
+		t.Fatalf("Expected Put to wait for the lock, but it returned %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	unlock()
+	if err = <-done; err != nil {
+		t.Fatalf("Error calling Put: %v", err)
+	}
+	if _, ok := store.Get(object, 0); !ok {
+		t.Fatalf("Expected the block to be written once the lock was released")
+	}
+}
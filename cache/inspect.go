@@ -0,0 +1,291 @@
+package cache
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// staleTempFileAge is how old a temporary file must be before GC assumes its writer died and removes it.
+const staleTempFileAge = time.Hour
+
+// DiskObject describes an object version with blocks in a DiskStore.
+type DiskObject struct {
+	Object
+
+	// Dir is the directory holding the object's blocks.
+	Dir string
+
+	// Blocks are the object's blocks, in order of index.
+	Blocks []DiskBlock
+}
+
+// DiskBlock describes a block in a DiskStore.
+type DiskBlock struct {
+	// Index is the index of the block in its object.
+	Index int64
+
+	// Size is the number of bytes of the object the block holds.
+	Size int64
+
+	// LastUsed is when the block was last written or read.
+	LastUsed time.Time
+}
+
+// CachedBytes returns the number of bytes of the object held in its blocks.
+func (o *DiskObject) CachedBytes() int64 {
+	var n int64
+	for _, block := range o.Blocks {
+		n += block.Size
+	}
+	return n
+}
+
+// Objects lists the object versions with blocks in the DiskStore, ordered by name and version.
+func (s *DiskStore) Objects() ([]DiskObject, error) {
+	entries, err := ioutil.ReadDir(filepath.Join(s.dir, diskObjectsDir))
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to list disk cache")
+	}
+
+	var objects []DiskObject
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		dir := filepath.Join(s.dir, diskObjectsDir, entry.Name())
+
+		b, err := ioutil.ReadFile(filepath.Join(dir, diskObjectFile))
+		if os.IsNotExist(err) {
+			// The object's first block is still being written.
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		var info diskObjectInfo
+		if err = json.Unmarshal(b, &info); err != nil {
+			return nil, errors.Wrapf(err, "unable to parse %s", filepath.Join(dir, diskObjectFile))
+		}
+
+		object := DiskObject{Object: Object{Name: info.Name, Version: info.Version, Size: info.Size}, Dir: dir}
+		if object.Blocks, err = listBlocks(dir, s.blockOverhead()); err != nil {
+			return nil, err
+		}
+		objects = append(objects, object)
+	}
+
+	sort.Slice(objects, func(i, j int) bool {
+		if objects[i].Name != objects[j].Name {
+			return objects[i].Name < objects[j].Name
+		}
+		return objects[i].Version < objects[j].Version
+	})
+
+	return objects, nil
+}
+
+// Latest returns the version of the object named name whose blocks were most recently used, which is usually the
+// newest version cached. It returns false if the DiskStore holds no blocks of the object.
+func (s *DiskStore) Latest(name string) (Object, bool, error) {
+	objects, err := s.Objects()
+	if err != nil {
+		return Object{}, false, err
+	}
+
+	var latest Object
+	var latestUse time.Time
+	found := false
+	for _, object := range objects {
+		if object.Name != name {
+			continue
+		}
+		for _, block := range object.Blocks {
+			if !found || block.LastUsed.After(latestUse) {
+				latest, latestUse, found = object.Object, block.LastUsed, true
+			}
+		}
+	}
+
+	return latest, found, nil
+}
+
+// listBlocks lists the blocks in an object directory, in order of index. Block files hold overhead bytes beyond the
+// block's data.
+func listBlocks(dir string, overhead int64) ([]DiskBlock, error) {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var blocks []DiskBlock
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasSuffix(name, diskBlockExt) || entry.Size() < overhead {
+			continue
+		}
+
+		index, err := strconv.ParseInt(strings.TrimSuffix(name, diskBlockExt), 10, 64)
+		if err != nil {
+			continue
+		}
+
+		blocks = append(blocks, DiskBlock{Index: index, Size: entry.Size() - overhead, LastUsed: entry.ModTime()})
+	}
+
+	sort.Slice(blocks, func(i, j int) bool { return blocks[i].Index < blocks[j].Index })
+	return blocks, nil
+}
+
+// Verify reads every block in the DiskStore and removes those that fail to verify, returning how many were removed.
+func (s *DiskStore) Verify() (int, error) {
+	objects, err := s.Objects()
+	if err != nil {
+		return 0, err
+	}
+
+	removed := 0
+	for _, object := range objects {
+		for _, block := range object.Blocks {
+			path := s.blockPath(object.Object, block.Index)
+
+			b, err := ioutil.ReadFile(path)
+			if os.IsNotExist(err) {
+				continue
+			} else if err != nil {
+				return removed, err
+			}
+
+			data, ok := s.decodeBlock(object.Object, block.Index, b)
+			if ok && s.validSize(object.Object, block.Index, int64(len(data))) {
+				continue
+			}
+
+			if err = os.Remove(path); err != nil && !os.IsNotExist(err) {
+				return removed, err
+			}
+			removed++
+		}
+	}
+
+	return removed, nil
+}
+
+// validSize reports whether a block at index of object holds the number of bytes it should.
+func (s *DiskStore) validSize(object Object, index int64, n int64) bool {
+	expected := object.Size - index*s.blockSize
+	if expected > s.blockSize {
+		expected = s.blockSize
+	}
+	return n == expected
+}
+
+// GCOptions configures GC. Zero values mean no limit.
+type GCOptions struct {
+	// MaxAge evicts blocks that have not been used for longer than it.
+	MaxAge time.Duration
+
+	// MaxBytes evicts the least recently used blocks until the blocks held total at most it.
+	MaxBytes int64
+}
+
+// GCResult reports what GC evicted.
+type GCResult struct {
+	Blocks int   `json:"blocks"`
+	Bytes  int64 `json:"bytes"`
+}
+
+// GC evicts blocks from the DiskStore according to options, then removes the directories of objects left without
+// blocks and temporary files abandoned by writers that died. It holds the store's lock exclusively, so writes by this
+// and other processes wait until it completes.
+func (s *DiskStore) GC(options GCOptions) (GCResult, error) {
+	var result GCResult
+
+	unlock, err := lockStore(s.dir, true, s.fileMode)
+	if err != nil {
+		return result, err
+	}
+	defer unlock()
+
+	objects, err := s.Objects()
+	if err != nil {
+		return result, err
+	}
+
+	type candidate struct {
+		path  string
+		block DiskBlock
+	}
+
+	var blocks []candidate
+	var total int64
+	for _, object := range objects {
+		for _, block := range object.Blocks {
+			blocks = append(blocks, candidate{path: s.blockPath(object.Object, block.Index), block: block})
+			total += block.Size
+		}
+	}
+
+	// Evict the least recently used blocks first.
+	sort.Slice(blocks, func(i, j int) bool { return blocks[i].block.LastUsed.Before(blocks[j].block.LastUsed) })
+
+	now := time.Now()
+	for _, c := range blocks {
+		tooOld := options.MaxAge > 0 && now.Sub(c.block.LastUsed) > options.MaxAge
+		tooBig := options.MaxBytes > 0 && total > options.MaxBytes
+		if !tooOld && !tooBig {
+			break
+		}
+
+		if err = os.Remove(c.path); err != nil && !os.IsNotExist(err) {
+			return result, err
+		}
+		result.Blocks++
+		result.Bytes += c.block.Size
+		total -= c.block.Size
+	}
+
+	for _, object := range objects {
+		if err = s.removeIfEmpty(object.Dir, now); err != nil {
+			return result, err
+		}
+	}
+
+	return result, nil
+}
+
+// removeIfEmpty removes stale temporary files from an object directory, and then the directory itself if it holds no
+// blocks.
+func (s *DiskStore) removeIfEmpty(dir string, now time.Time) error {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	empty := true
+	for _, entry := range entries {
+		switch {
+		case strings.HasPrefix(entry.Name(), diskTempPrefix):
+			if now.Sub(entry.ModTime()) > staleTempFileAge {
+				_ = os.Remove(filepath.Join(dir, entry.Name()))
+			} else {
+				empty = false
+			}
+		case strings.HasSuffix(entry.Name(), diskBlockExt):
+			empty = false
+		}
+	}
+
+	if !empty {
+		return nil
+	}
+
+	return os.RemoveAll(dir)
+}
@@ -0,0 +1,85 @@
+package cache
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+// TestDiskStoreInspect tests that a DiskStore lists its objects and blocks, removes corrupt blocks when verified, and
+// evicts the least recently used blocks when collecting garbage.
+func TestDiskStoreInspect(t *testing.T) {
+	dir, err := ioutil.TempDir("", "diskstore")
+	if err != nil {
+		t.Fatalf("Error calling TempDir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	store, err := OpenDiskStore(dir, 4)
+	if err != nil {
+		t.Fatalf("Error calling OpenDiskStore: %v", err)
+	}
+
+	a := Object{Name: "s3://bucket/a", Version: `"v1"`, Size: 10}
+	b := Object{Name: "s3://bucket/b", Version: `"v1"`, Size: 4}
+	for _, put := range []struct {
+		object Object
+		index  int64
+		data   string
+	}{{a, 0, "0123"}, {a, 2, "89"}, {b, 0, "wxyz"}} {
+		if err = store.Put(put.object, put.index, []byte(put.data)); err != nil {
+			t.Fatalf("Error calling Put: %v", err)
+		}
+	}
+
+	// Make block 0 of a the least recently used.
+	old := time.Now().Add(-time.Hour)
+	if err = os.Chtimes(store.blockPath(a, 0), old, old); err != nil {
+		t.Fatalf("Error calling Chtimes: %v", err)
+	}
+
+	objects, err := store.Objects()
+	if err != nil {
+		t.Fatalf("Error calling Objects: %v", err)
+	}
+	if len(objects) != 2 || objects[0].Object != a || objects[1].Object != b {
+		t.Fatalf("Expected objects a and b, got %+v", objects)
+	}
+	if blocks := objects[0].Blocks; len(blocks) != 2 || blocks[0].Index != 0 || blocks[1].Index != 2 {
+		t.Fatalf("Expected blocks 0 and 2 of a, got %+v", blocks)
+	}
+	if n := objects[0].CachedBytes(); n != 6 {
+		t.Fatalf("Expected 6 bytes of a to be cached, got %d", n)
+	}
+
+	// A block of the wrong size fails verification even if its checksum matches.
+	if err = store.Put(b, 1, []byte("!")); err != nil {
+		t.Fatalf("Error calling Put: %v", err)
+	}
+	removed, err := store.Verify()
+	if err != nil {
+		t.Fatalf("Error calling Verify: %v", err)
+	}
+	if removed != 1 {
+		t.Fatalf("Expected Verify to remove 1 block, removed %d", removed)
+	}
+
+	result, err := store.GC(GCOptions{MaxBytes: 7})
+	if err != nil {
+		t.Fatalf("Error calling GC: %v", err)
+	}
+	if result.Blocks != 1 || result.Bytes != 4 {
+		t.Fatalf("Expected GC to evict 1 block of 4 bytes, got %+v", result)
+	}
+	if _, ok := store.Get(a, 0); ok {
+		t.Fatalf("Expected the least recently used block to be evicted")
+	}
+
+	if _, err = store.GC(GCOptions{MaxAge: time.Nanosecond}); err != nil {
+		t.Fatalf("Error calling GC: %v", err)
+	}
+	if objects, err = store.Objects(); err != nil || len(objects) != 0 {
+		t.Fatalf("Expected no objects after evicting every block, got %+v and %v", objects, err)
+	}
+}
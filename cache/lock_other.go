@@ -0,0 +1,21 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd && !windows
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd,!windows
+
+package cache
+
+import "os"
+
+// lockFile does nothing on platforms without flock or LockFileEx, where a DiskStore must not be shared by processes.
+func lockFile(*os.File, bool) error {
+	return nil
+}
+
+// unlockFile does nothing, as lockFile does.
+func unlockFile(*os.File) error {
+	return nil
+}
+
+// renameFile renames oldpath to newpath, replacing any existing file, as os.Rename does.
+func renameFile(oldpath string, newpath string) error {
+	return os.Rename(oldpath, newpath)
+}
@@ -0,0 +1,46 @@
+package cache
+
+import (
+	"os"
+	"time"
+
+	"golang.org/x/sys/windows"
+)
+
+// renameRetryTimeout is how long renameFile retries while another process has the file it replaces open.
+const renameRetryTimeout = 500 * time.Millisecond
+
+// lockFile locks the first byte of f with LockFileEx, which other processes' locks respect, blocking until the lock is
+// held. The byte need not exist.
+func lockFile(f *os.File, exclusive bool) error {
+	var flags uint32
+	if exclusive {
+		flags = windows.LOCKFILE_EXCLUSIVE_LOCK
+	}
+
+	return windows.LockFileEx(windows.Handle(f.Fd()), flags, 0, 1, 0, new(windows.Overlapped))
+}
+
+// unlockFile releases the lock taken by lockFile.
+func unlockFile(f *os.File) error {
+	return windows.UnlockFileEx(windows.Handle(f.Fd()), 0, 1, 0, new(windows.Overlapped))
+}
+
+// renameFile renames oldpath to newpath, replacing any existing file, as os.Rename does. Unlike on other platforms,
+// replacing a file fails while another process has it open, as one reading a block does for a moment, so it retries
+// briefly.
+func renameFile(oldpath string, newpath string) error {
+	deadline := time.Now().Add(renameRetryTimeout)
+	for {
+		err := os.Rename(oldpath, newpath)
+		if err == nil || time.Now().After(deadline) {
+			return err
+		}
+
+		linkErr, ok := err.(*os.LinkError)
+		if !ok || (linkErr.Err != windows.ERROR_ACCESS_DENIED && linkErr.Err != windows.ERROR_SHARING_VIOLATION) {
+			return err
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,85 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+)
+
+// MemoryStore is a Store that keeps blocks in memory, evicting the least recently used block once it holds more than
+// its budget.
+type MemoryStore struct {
+	blockSize int64
+	maxBytes  int64
+
+	mu     sync.Mutex
+	bytes  int64
+	lru    *list.List
+	blocks map[memoryKey]*list.Element
+}
+
+type memoryKey struct {
+	object Object
+	index  int64
+}
+
+type memoryBlock struct {
+	key  memoryKey
+	data []byte
+}
+
+// NewMemoryStore returns a MemoryStore of blocks of blockSize bytes, holding at most maxBytes. If blockSize is not
+// positive, DefaultBlockSize is used.
+func NewMemoryStore(blockSize int64, maxBytes int64) *MemoryStore {
+	if blockSize <= 0 {
+		blockSize = DefaultBlockSize
+	}
+
+	return &MemoryStore{
+		blockSize: blockSize,
+		maxBytes:  maxBytes,
+		lru:       list.New(),
+		blocks:    make(map[memoryKey]*list.Element),
+	}
+}
+
+// BlockSize returns the size of the blocks the MemoryStore holds.
+func (s *MemoryStore) BlockSize() int64 {
+	return s.blockSize
+}
+
+// Get returns the block of object at index, if the MemoryStore holds it.
+func (s *MemoryStore) Get(object Object, index int64) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	element, ok := s.blocks[memoryKey{object: object, index: index}]
+	if !ok {
+		return nil, false
+	}
+
+	s.lru.MoveToFront(element)
+	return element.Value.(*memoryBlock).data, true
+}
+
+// Put stores the block of object at index, evicting the least recently used blocks while the MemoryStore is over its
+// budget.
+func (s *MemoryStore) Put(object Object, index int64, data []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := memoryKey{object: object, index: index}
+	if _, ok := s.blocks[key]; ok {
+		return nil
+	}
+
+	s.blocks[key] = s.lru.PushFront(&memoryBlock{key: key, data: data})
+	s.bytes += int64(len(data))
+
+	for s.bytes > s.maxBytes && s.lru.Len() > 0 {
+		oldest := s.lru.Remove(s.lru.Back()).(*memoryBlock)
+		delete(s.blocks, oldest.key)
+		s.bytes -= int64(len(oldest.data))
+	}
+
+	return nil
+}
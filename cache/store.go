@@ -0,0 +1,27 @@
+package cache
+
+// Object identifies the object a block belongs to in a Store.
+type Object struct {
+	// Name names the object, such as "s3://bucket/key".
+	Name string
+
+	// Version distinguishes the contents of successive objects with the same Name, such as an ETag. Blocks of one
+	// version are never served for another.
+	Version string
+
+	// Size is the size of the object in bytes.
+	Size int64
+}
+
+// Store is a cache of blocks that can be shared by many ReaderAts, and by many objects, so that they share one
+// budget. It must be safe for concurrent use.
+type Store interface {
+	// BlockSize returns the size of the blocks the Store holds. Only the last block of an object may be shorter.
+	BlockSize() int64
+
+	// Get returns the block of object at index, if the Store holds it.
+	Get(object Object, index int64) ([]byte, bool)
+
+	// Put stores the block of object at index. The Store may evict other blocks to make room for it.
+	Put(object Object, index int64, data []byte) error
+}
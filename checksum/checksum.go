@@ -0,0 +1,136 @@
+// Package checksum provides an io.ReaderAt that verifies the data read from an underlying io.ReaderAt against
+// per-block checksums, so that corruption is detected without reading the whole object. See package cache for how
+// decorators compose.
+package checksum
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"hash"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+// ErrChecksumMismatch is returned when a block does not match its checksum.
+var ErrChecksumMismatch = errors.New("checksum mismatch")
+
+// Options configures a ReaderAt.
+type Options struct {
+	// BlockSize is the number of bytes covered by each checksum. It must be positive.
+	BlockSize int64
+
+	// Sums holds the checksum of each block, in order. The last block may be short.
+	Sums [][]byte
+
+	// Hash returns the hash the checksums were computed with. The default is sha256.New.
+	Hash func() hash.Hash
+}
+
+// ReaderAt verifies reads of an underlying io.ReaderAt. Reads are rounded out to whole blocks, each of which is
+// checked before any of its bytes are returned. It is safe for concurrent use if the underlying io.ReaderAt is.
+type ReaderAt struct {
+	r         io.ReaderAt
+	size      int64
+	blockSize int64
+	sums      [][]byte
+	hash      func() hash.Hash
+}
+
+// New returns a ReaderAt that verifies reads of the first size bytes of r.
+func New(r io.ReaderAt, size int64, options Options) (*ReaderAt, error) {
+	if size < 0 {
+		return nil, errors.Errorf("provided size is invalid: %d", size)
+	} else if options.BlockSize <= 0 {
+		return nil, errors.Errorf("provided BlockSize is invalid: %d", options.BlockSize)
+	}
+
+	if blocks := (size + options.BlockSize - 1) / options.BlockSize; int64(len(options.Sums)) != blocks {
+		return nil, errors.Errorf("expected %d checksums for %d bytes, got %d", blocks, size, len(options.Sums))
+	}
+
+	if options.Hash == nil {
+		options.Hash = sha256.New
+	}
+
+	return &ReaderAt{
+		r:         r,
+		size:      size,
+		blockSize: options.BlockSize,
+		sums:      options.Sums,
+		hash:      options.Hash,
+	}, nil
+}
+
+// Sums computes the checksums of the blocks of the first size bytes of r, suitable for Options.Sums.
+func Sums(r io.ReaderAt, size int64, blockSize int64, newHash func() hash.Hash) ([][]byte, error) {
+	if blockSize <= 0 {
+		return nil, errors.Errorf("provided blockSize is invalid: %d", blockSize)
+	}
+
+	if newHash == nil {
+		newHash = sha256.New
+	}
+
+	var sums [][]byte
+	for off := int64(0); off < size; off += blockSize {
+		h := newHash()
+		if _, err := io.Copy(h, io.NewSectionReader(r, off, minInt64(blockSize, size-off))); err != nil {
+			return nil, err
+		}
+		sums = append(sums, h.Sum(nil))
+	}
+
+	return sums, nil
+}
+
+// ReadAt reads len(p) bytes starting at off, returning ErrChecksumMismatch if any block they fall within is corrupt.
+func (c *ReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errors.Errorf("offset %d is negative", off)
+	} else if off >= c.size {
+		return 0, io.EOF
+	}
+
+	end := off + int64(len(p))
+	if end > c.size {
+		end = c.size
+	}
+
+	first := off / c.blockSize * c.blockSize
+	last := (end + c.blockSize - 1) / c.blockSize * c.blockSize
+	if last > c.size {
+		last = c.size
+	}
+
+	buf := make([]byte, last-first)
+	if read, err := c.r.ReadAt(buf, first); int64(read) < last-first {
+		if err == nil || err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return 0, err
+	}
+
+	for blockOff := first; blockOff < last; blockOff += c.blockSize {
+		block := buf[blockOff-first : minInt64(blockOff+c.blockSize, last)-first]
+		h := c.hash()
+		h.Write(block)
+		if index := blockOff / c.blockSize; !bytes.Equal(h.Sum(nil), c.sums[index]) {
+			return 0, errors.Wrapf(ErrChecksumMismatch, "block %d at offset %d", index, blockOff)
+		}
+	}
+
+	n := copy(p, buf[off-first:end-first])
+	if n < len(p) {
+		return n, io.EOF
+	}
+
+	return n, nil
+}
+
+func minInt64(a int64, b int64) int64 {
+	if a < b {
+		return a
+	}
+	return b
+}
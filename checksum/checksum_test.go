@@ -0,0 +1,57 @@
+package checksum
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+// TestReaderAt tests that reads spanning several blocks are verified, and that a corrupt block fails only the reads
+// that touch it.
+func TestReaderAt(t *testing.T) {
+	data := []byte("0123456789abcdefghij")
+	sums, err := Sums(bytes.NewReader(data), int64(len(data)), 8, nil)
+	if err != nil {
+		t.Fatalf("Error calling Sums: %v", err)
+	}
+	if len(sums) != 3 {
+		t.Fatalf("Expected 3 checksums, got %d", len(sums))
+	}
+
+	c, err := New(bytes.NewReader(data), int64(len(data)), Options{BlockSize: 8, Sums: sums})
+	if err != nil {
+		t.Fatalf("Error calling New: %v", err)
+	}
+
+	b := make([]byte, 6)
+	if _, err = c.ReadAt(b, 5); err != nil {
+		t.Fatalf("Error calling ReadAt: %v", err)
+	}
+	if string(b) != "56789a" {
+		t.Fatalf("Expected %q, got %q", "56789a", b)
+	}
+
+	n, err := c.ReadAt(b, 17)
+	if err != io.EOF || string(b[:n]) != "hij" {
+		t.Fatalf("Expected %q and io.EOF, got %q and %v", "hij", b[:n], err)
+	}
+
+	corrupt := append([]byte(nil), data...)
+	corrupt[9] = 'X'
+	c, err = New(bytes.NewReader(corrupt), int64(len(data)), Options{BlockSize: 8, Sums: sums})
+	if err != nil {
+		t.Fatalf("Error calling New: %v", err)
+	}
+	if _, err = c.ReadAt(b, 0); err != nil {
+		t.Fatalf("Error calling ReadAt: %v", err)
+	}
+	if _, err = c.ReadAt(b, 6); !errors.Is(err, ErrChecksumMismatch) {
+		t.Fatalf("Expected ErrChecksumMismatch, got %v", err)
+	}
+
+	if _, err = New(bytes.NewReader(data), int64(len(data)), Options{BlockSize: 8, Sums: sums[:2]}); err == nil {
+		t.Fatalf("Expected an error calling New with too few checksums")
+	}
+}
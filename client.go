@@ -0,0 +1,77 @@
+package s3readerat
+
+import (
+	"log"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/pkg/errors"
+)
+
+// SetClient replaces the client the S3ReaderAt makes requests with, switching it to single-region mode as though
+// client had been passed as Options.Client, or as Options.API if it is not an *s3.Client. Use it when credentials or
+// endpoints change under a long-lived S3ReaderAt, so that it need not be rebuilt and lose its prefetched head and
+// tail, its stats and the object's size and parts. Requests in flight finish with the old client; later requests use
+// the new one. Clients the S3ReaderAt made in multi-region mode are released, as by Close. If the new client reads a
+// different object under the same key, reads fail with ErrObjectChanged. It is safe for concurrent use.
+func (ra *S3ReaderAt) SetClient(client API) error {
+	if client == nil {
+		return errors.New("client is required")
+	}
+	if c, ok := client.(*s3.Client); ok && c == nil {
+		return errors.New("client is required")
+	}
+
+	ra.clientMu.Lock()
+	ra.releaseClients()
+	ra.client, ra.options = client, nil
+	ra.clientMu.Unlock()
+
+	if ra.debug() {
+		log.Printf("Replaced the client for S3 object s3://%s/%s", ra.bucket, ra.key)
+	}
+
+	return nil
+}
+
+// SetOptions is like SetClient, but switches the S3ReaderAt to multi-region mode with options, as though they had
+// been passed as Options.Options. A client is constructed from them when the next request is made. options must not
+// be modified afterwards.
+func (ra *S3ReaderAt) SetOptions(options *s3.Options) error {
+	if options == nil {
+		return errors.New("options are required")
+	}
+
+	ra.clientMu.Lock()
+	ra.releaseClients()
+	ra.client, ra.options = nil, options
+	ra.clientMu.Unlock()
+
+	if ra.debug() {
+		log.Printf("Replaced the client options for S3 object s3://%s/%s", ra.bucket, ra.key)
+	}
+
+	return nil
+}
+
+// OptionsFromConfig returns s3.Options with the settings of cfg, such as one loaded with config.LoadDefaultConfig, for
+// Options.Options or SetOptions. It takes the settings s3.NewFromConfig does, other than custom endpoint resolvers.
+func OptionsFromConfig(cfg aws.Config) *s3.Options {
+	options := &s3.Options{
+		Region:             cfg.Region,
+		DefaultsMode:       cfg.DefaultsMode,
+		RuntimeEnvironment: cfg.RuntimeEnvironment,
+		HTTPClient:         cfg.HTTPClient,
+		Credentials:        cfg.Credentials,
+		APIOptions:         cfg.APIOptions,
+		Logger:             cfg.Logger,
+		ClientLogMode:      cfg.ClientLogMode,
+		RetryMaxAttempts:   cfg.RetryMaxAttempts,
+		RetryMode:          cfg.RetryMode,
+	}
+	if cfg.Retryer != nil {
+		options.Retryer = cfg.Retryer()
+	}
+
+	return options
+}
@@ -0,0 +1,71 @@
+package s3readerat
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+// TestSetClient tests that SetClient and SetOptions redirect later requests without losing state, and that switching
+// to a different object under the same key fails with ErrObjectChanged.
+func TestSetClient(t *testing.T) {
+	data := []byte("0123456789")
+	f := newFakeS3(t)
+	f.put("bucket", "key", data)
+	mirror := newFakeS3(t)
+	mirror.put("bucket", "key", data)
+	mirror.objects["bucket/key"].etag = f.objects["bucket/key"].etag
+
+	s3ReaderAt, err := NewWithOptions(Options{Client: f.client(), Bucket: "bucket", Key: "key"})
+	if err != nil {
+		t.Fatalf("Error calling NewWithOptions: %v", err)
+	}
+
+	b := make([]byte, 2)
+	read := func() error {
+		_, err := s3ReaderAt.ReadAt(b, 4)
+		return err
+	}
+	if err = read(); err != nil {
+		t.Fatalf("Error calling ReadAt: %v", err)
+	}
+
+	if err = s3ReaderAt.SetClient(nil); err == nil {
+		t.Fatalf("Expected an error calling SetClient with nil")
+	}
+	if err = s3ReaderAt.SetClient(mirror.client()); err != nil {
+		t.Fatalf("Error calling SetClient: %v", err)
+	}
+	if err = read(); err != nil {
+		t.Fatalf("Error calling ReadAt: %v", err)
+	}
+	if f.requestCount(http.MethodGet) != 1 || mirror.requestCount(http.MethodGet) != 1 {
+		t.Fatalf("Expected the second read to be served by the new client")
+	}
+	if mirror.requestCount(http.MethodHead) != 0 {
+		t.Fatalf("Expected the size to be kept across SetClient")
+	}
+
+	options := f.options()
+	if err = s3ReaderAt.SetOptions(&options); err != nil {
+		t.Fatalf("Error calling SetOptions: %v", err)
+	}
+	if err = read(); err != nil {
+		t.Fatalf("Error calling ReadAt: %v", err)
+	}
+	if f.requestCount(http.MethodGet) != 2 {
+		t.Fatalf("Expected the third read to be served by the new options")
+	}
+	if stats := s3ReaderAt.Stats(); stats.GetObjectRequests != 3 {
+		t.Fatalf("Expected stats to be kept across SetClient, got %+v", stats)
+	}
+
+	mirror.put("bucket", "key", data)
+	if err = s3ReaderAt.SetClient(mirror.client()); err != nil {
+		t.Fatalf("Error calling SetClient: %v", err)
+	}
+	if err = read(); !errors.Is(err, ErrObjectChanged) {
+		t.Fatalf("Expected ErrObjectChanged, got %v", err)
+	}
+}
@@ -0,0 +1,117 @@
+package s3readerat
+
+import (
+	"log"
+	"net/http"
+	"sync/atomic"
+
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/pkg/errors"
+)
+
+// ErrClosed is returned by reads from an S3ReaderAt after Close.
+var ErrClosed = errors.New("read from closed S3ReaderAt")
+
+// Close releases the s3.Clients the S3ReaderAt made in multi-region mode, one per region it was redirected to,
+// closing their idle connections, and cancels any prefetches in progress. Clients passed as Options.Client or
+// Options.API, and any HTTPClient set in Options.Options, belong to the caller and are left open. Afterwards, reads
+// and any other requests fail with ErrClosed, while requests in flight finish. Closing more than once has no effect.
+func (ra *S3ReaderAt) Close() error {
+	if !atomic.CompareAndSwapInt32(&ra.closed, 0, 1) {
+		return nil
+	}
+
+	ra.clientMu.Lock()
+	released := ra.releaseClients()
+	ra.clientMu.Unlock()
+	ra.CancelPrefetches()
+
+	if ra.debug() {
+		log.Printf("Closed S3 object s3://%s/%s, releasing %d clients", ra.bucket, ra.key, released)
+	}
+
+	return nil
+}
+
+// checkClosed returns ErrClosed if Close has been called.
+func (ra *S3ReaderAt) checkClosed() error {
+	if atomic.LoadInt32(&ra.closed) != 0 {
+		return ErrClosed
+	}
+	return nil
+}
+
+// newClient makes an s3.Client from options, with the clientSettings of the S3ReaderAt. Unless options sets an
+// HTTPClient, the s3.Client is given one of its own that releaseClients can close the idle connections of.
+// ra.clientMu must be held.
+func (ra *S3ReaderAt) newClient(options s3.Options) API {
+	ra.settings.apply(&options)
+	if options.HTTPClient == nil {
+		httpClient := newHTTPClient()
+		options.HTTPClient = httpClient
+		ra.httpClients = append(ra.httpClients, httpClient)
+	}
+
+	return s3.New(options)
+}
+
+// releaseClients forgets the s3.Clients made by newClient, closing the idle connections of their HTTP clients, and
+// returns the number released. Requests in flight with them are unaffected. ra.clientMu must be held.
+func (ra *S3ReaderAt) releaseClients() int {
+	released := len(ra.httpClients)
+	for _, httpClient := range ra.httpClients {
+		if closer, ok := httpClient.(interface{ CloseIdleConnections() }); ok {
+			closer.CloseIdleConnections()
+		}
+	}
+
+	ra.httpClients, ra.regionClients, ra.region = nil, nil, ""
+	if ra.options != nil {
+		ra.client = nil
+	}
+
+	return released
+}
+
+// missingLocation is the Location that redirectTransport gives redirects without one.
+const missingLocation = "https://amazonaws.com/missing-location"
+
+// redirectTransport is the transport of the HTTP clients made by newHTTPClient. S3 redirects requests for a bucket in
+// another region with a 301 response that may have no Location, which net/http fails to follow rather than returning
+// it, so such responses are given missingLocation. Embedding the *http.Transport lets the http.Client close its idle
+// connections, which the client the SDK builds cannot do, since it wraps its transport the same way but hides it.
+type redirectTransport struct {
+	*http.Transport
+}
+
+// RoundTrip makes the request with the transport, giving a 301 or 302 response without a Location missingLocation.
+func (t redirectTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	resp, err := t.Transport.RoundTrip(r)
+	if err != nil {
+		return resp, err
+	}
+	if (resp.StatusCode == http.StatusMovedPermanently || resp.StatusCode == http.StatusFound) &&
+		resp.Header.Get("Location") == "" {
+		resp.Header.Set("Location", missingLocation)
+	}
+	return resp, nil
+}
+
+// newHTTPClient returns an HTTP client like the SDK's default one, which only follows 307 and 308 redirects, returning
+// others, such as S3's region redirects, to the s3.Client.
+func newHTTPClient() *http.Client {
+	return &http.Client{
+		Transport: redirectTransport{awshttp.NewBuildableClient().GetTransport()},
+		CheckRedirect: func(r *http.Request, via []*http.Request) error {
+			switch r.Response.StatusCode {
+			case http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+				return nil
+			}
+			if r.Response.Header.Get("Location") == missingLocation {
+				r.Response.Header.Del("Location")
+			}
+			return http.ErrUseLastResponse
+		},
+	}
+}
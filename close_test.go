@@ -0,0 +1,75 @@
+package s3readerat
+
+import (
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// TestClose tests that Close releases the clients made in multi-region mode, closing their idle connections, and that
+// later reads fail with ErrClosed.
+func TestClose(t *testing.T) {
+	f := newFakeS3(t)
+	f.put("bucket", "key", []byte("0123456789"))
+
+	var closed int32
+	f.server = httptest.NewUnstartedServer(http.HandlerFunc(f.serveHTTP))
+	f.server.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateClosed {
+			atomic.AddInt32(&closed, 1)
+		}
+	}
+	f.server.Start()
+	t.Cleanup(f.server.Close)
+
+	options := f.options()
+	ra, err := NewWithOptions(Options{Options: &options, Bucket: "bucket", Key: "key"})
+	if err != nil {
+		t.Fatalf("Error calling NewWithOptions: %v", err)
+	}
+	if _, err = ra.ReadAt(make([]byte, 4), 0); err != nil {
+		t.Fatalf("Error calling ReadAt: %v", err)
+	}
+	if options.HTTPClient != nil {
+		t.Fatalf("Expected the caller's options not to be modified")
+	}
+
+	ra.clientMu.Lock()
+	made := len(ra.httpClients)
+	ra.clientMu.Unlock()
+	if made != 1 {
+		t.Fatalf("Expected 1 HTTP client to be made, got %d", made)
+	}
+
+	if err = ra.Close(); err != nil {
+		t.Fatalf("Error calling Close: %v", err)
+	}
+	ra.clientMu.Lock()
+	made = len(ra.httpClients)
+	ra.clientMu.Unlock()
+	if made != 0 {
+		t.Fatalf("Expected Close to release the HTTP clients, got %d left", made)
+	}
+	for deadline := time.Now().Add(5 * time.Second); atomic.LoadInt32(&closed) == 0; {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected Close to close the idle connections")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if _, err = ra.ReadAt(make([]byte, 4), 0); !errors.Is(err, ErrClosed) {
+		t.Fatalf("Expected ErrClosed reading after Close, got %v", err)
+	}
+	if _, err = ra.CopyRange(ioutil.Discard, 0, -1); !errors.Is(err, ErrClosed) {
+		t.Fatalf("Expected ErrClosed copying after Close, got %v", err)
+	}
+	if err = ra.Close(); err != nil {
+		t.Fatalf("Expected closing again to have no effect, got %v", err)
+	}
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/pprof"
+
+	"github.com/markandrus/s3readerat/rangeproxy"
+)
+
+// serveAdmin serves debugging endpoints for server on addr in the background:
+//
+//   - /debug/pprof/ serves the runtime profiles of net/http/pprof.
+//   - /debug/stats serves the server's request and cache statistics as JSON.
+//   - /debug/requests serves the requests being served as JSON, oldest first.
+//
+// The admin listener is separate from the server's so that it need not be exposed to clients.
+func serveAdmin(addr string, server *rangeproxy.Server) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.HandleFunc("/debug/stats", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, server.Stats())
+	})
+	mux.HandleFunc("/debug/requests", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, server.InFlight())
+	})
+
+	infof("Serving admin endpoints on %s", addr)
+	go func() {
+		if err := http.ListenAndServe(addr, mux); err != nil {
+			fatalf("Admin server failed: %v", err)
+		}
+	}()
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	_ = encoder.Encode(v)
+}
@@ -0,0 +1,279 @@
+package main
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"encoding/binary"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	zipEndSignature         = 0x06054b50
+	zip64EndSignature       = 0x06064b50
+	zip64LocatorSignature   = 0x07064b50
+	zipDirectorySignature   = 0x02014b50
+	zipEndLen               = 22
+	zip64LocatorLen         = 20
+	zip64EndLen             = 56
+	zipDirectoryHeaderLen   = 46
+	zipMaxCommentLen        = 65535
+	zip64ExtraID            = 0x0001
+	gzipStreamingBufferSize = 1 << 20
+)
+
+// archiveMember describes a member of an archive. For zip archives, Offset is the offset of the member's local file
+// header; for tar archives, it is the offset of the member's data.
+type archiveMember struct {
+	Name   string
+	Size   int64
+	Offset int64
+}
+
+// listArchive implements the list-archive subcommand, which prints the members of a zip, tar or tar.gz archive.
+func listArchive(args []string) {
+	flags := flag.NewFlagSet("list-archive", flag.ExitOnError)
+	common := newCommonFlags(flags)
+	format := flags.String("format", "", "archive format: zip, tar or tar.gz (default is to infer from the key)")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s list-archive [flags] s3://bucket/key\n\n", os.Args[0])
+		fmt.Fprintln(flags.Output(), "Prints the offset, size and name of each archive member. Zip archives are listed")
+		fmt.Fprintln(flags.Output(), "from their central directory and tar archives from their headers alone; tar.gz")
+		fmt.Fprintln(flags.Output(), "archives cannot be seeked, so they are streamed and offsets are uncompressed.")
+		fmt.Fprintln(flags.Output())
+		flags.PrintDefaults()
+	}
+	_ = flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	if *format == "" {
+		*format = inferArchiveFormat(flags.Arg(0))
+	}
+
+	reader := common.open(flags.Arg(0))
+
+	size, err := reader.Size()
+	if err != nil {
+		fatalf("Unable to get size of S3 object: %v", err)
+	}
+
+	var members []archiveMember
+	switch *format {
+	case "zip":
+		members, err = listZip(reader, size)
+	case "tar":
+		members, err = listTar(io.NewSectionReader(reader, 0, size))
+	case "tar.gz":
+		members, err = listTarGz(io.NewSectionReader(reader, 0, size))
+	default:
+		fatalf("Unsupported archive format %q; pass -format zip, tar or tar.gz", *format)
+	}
+	if err != nil {
+		fatalf("Unable to list archive: %v", err)
+	}
+
+	for _, member := range members {
+		fmt.Printf("%d\t%d\t%s\n", member.Offset, member.Size, member.Name)
+	}
+}
+
+// inferArchiveFormat guesses an archive format from the extension of an S3 URL.
+func inferArchiveFormat(rawURL string) string {
+	lower := strings.ToLower(rawURL)
+	switch {
+	case strings.HasSuffix(lower, ".zip"):
+		return "zip"
+	case strings.HasSuffix(lower, ".tar"):
+		return "tar"
+	case strings.HasSuffix(lower, ".tar.gz"), strings.HasSuffix(lower, ".tgz"):
+		return "tar.gz"
+	default:
+		return ""
+	}
+}
+
+// listZip lists a zip archive by reading the end of central directory record and then the central directory itself,
+// which usually takes one or two range requests.
+func listZip(r io.ReaderAt, size int64) ([]archiveMember, error) {
+	tailLen := int64(zipEndLen + zipMaxCommentLen + zip64LocatorLen)
+	if tailLen > size {
+		tailLen = size
+	}
+	tailOffset := size - tailLen
+
+	tail := make([]byte, tailLen)
+	if _, err := r.ReadAt(tail, tailOffset); err != nil && err != io.EOF {
+		return nil, errors.Wrap(err, "reading end of central directory")
+	}
+
+	end := -1
+	for i := len(tail) - zipEndLen; i >= 0; i-- {
+		if binary.LittleEndian.Uint32(tail[i:]) == zipEndSignature {
+			end = i
+			break
+		}
+	}
+	if end < 0 {
+		return nil, errors.New("end of central directory record not found; is this a zip archive?")
+	}
+
+	count := int64(binary.LittleEndian.Uint16(tail[end+10:]))
+	dirSize := int64(binary.LittleEndian.Uint32(tail[end+12:]))
+	dirOffset := int64(binary.LittleEndian.Uint32(tail[end+16:]))
+
+	if count == 0xffff || dirSize == 0xffffffff || dirOffset == 0xffffffff {
+		locator := end - zip64LocatorLen
+		if locator < 0 || binary.LittleEndian.Uint32(tail[locator:]) != zip64LocatorSignature {
+			return nil, errors.New("zip64 end of central directory locator not found")
+		}
+
+		record := make([]byte, zip64EndLen)
+		recordOffset := int64(binary.LittleEndian.Uint64(tail[locator+8:]))
+		if _, err := r.ReadAt(record, recordOffset); err != nil {
+			return nil, errors.Wrap(err, "reading zip64 end of central directory")
+		}
+		if binary.LittleEndian.Uint32(record) != zip64EndSignature {
+			return nil, errors.New("invalid zip64 end of central directory record")
+		}
+
+		count = int64(binary.LittleEndian.Uint64(record[32:]))
+		dirSize = int64(binary.LittleEndian.Uint64(record[40:]))
+		dirOffset = int64(binary.LittleEndian.Uint64(record[48:]))
+	}
+
+	if dirOffset < 0 || dirSize < 0 || dirOffset+dirSize > size {
+		return nil, errors.Errorf("central directory [%d, %d) is out of bounds", dirOffset, dirOffset+dirSize)
+	}
+
+	var dir []byte
+	if dirOffset >= tailOffset {
+		dir = tail[dirOffset-tailOffset : dirOffset-tailOffset+dirSize]
+	} else {
+		dir = make([]byte, dirSize)
+		if _, err := r.ReadAt(dir, dirOffset); err != nil && err != io.EOF {
+			return nil, errors.Wrap(err, "reading central directory")
+		}
+	}
+
+	members := make([]archiveMember, 0, count)
+	for len(dir) > 0 {
+		if len(dir) < zipDirectoryHeaderLen || binary.LittleEndian.Uint32(dir) != zipDirectorySignature {
+			return nil, errors.New("invalid central directory header")
+		}
+
+		memberSize := int64(binary.LittleEndian.Uint32(dir[24:]))
+		nameLen := int(binary.LittleEndian.Uint16(dir[28:]))
+		extraLen := int(binary.LittleEndian.Uint16(dir[30:]))
+		commentLen := int(binary.LittleEndian.Uint16(dir[32:]))
+		offset := int64(binary.LittleEndian.Uint32(dir[42:]))
+
+		entryLen := zipDirectoryHeaderLen + nameLen + extraLen + commentLen
+		if len(dir) < entryLen {
+			return nil, errors.New("truncated central directory header")
+		}
+		name := string(dir[zipDirectoryHeaderLen : zipDirectoryHeaderLen+nameLen])
+		extra := dir[zipDirectoryHeaderLen+nameLen : zipDirectoryHeaderLen+nameLen+extraLen]
+
+		// Zip64 extended information holds, in order, whichever of the uncompressed size, compressed size and local
+		// header offset overflowed their 32-bit fields.
+		for len(extra) >= 4 {
+			id := binary.LittleEndian.Uint16(extra)
+			fieldLen := int(binary.LittleEndian.Uint16(extra[2:]))
+			if len(extra) < 4+fieldLen {
+				break
+			}
+			field := extra[4 : 4+fieldLen]
+			extra = extra[4+fieldLen:]
+
+			if id != zip64ExtraID {
+				continue
+			}
+			if memberSize == 0xffffffff && len(field) >= 8 {
+				memberSize = int64(binary.LittleEndian.Uint64(field))
+				field = field[8:]
+			}
+			if binary.LittleEndian.Uint32(dir[20:]) == 0xffffffff && len(field) >= 8 {
+				field = field[8:]
+			}
+			if offset == 0xffffffff && len(field) >= 8 {
+				offset = int64(binary.LittleEndian.Uint64(field))
+			}
+		}
+
+		members = append(members, archiveMember{Name: name, Size: memberSize, Offset: offset})
+		dir = dir[entryLen:]
+	}
+
+	return members, nil
+}
+
+// listTar lists a tar archive. Because the reader is seekable, archive/tar skips over member data, so only headers (and
+// the final byte of each member) are fetched.
+func listTar(r *io.SectionReader) ([]archiveMember, error) {
+	var members []archiveMember
+
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return members, nil
+		} else if err != nil {
+			return nil, err
+		}
+
+		offset, err := r.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return nil, err
+		}
+
+		members = append(members, archiveMember{Name: header.Name, Size: header.Size, Offset: offset})
+	}
+}
+
+// listTarGz lists a gzip-compressed tar archive. Gzip streams cannot be seeked, so the whole object is streamed and the
+// reported offsets are into the uncompressed tar stream.
+func listTarGz(r *io.SectionReader) ([]archiveMember, error) {
+	gz, err := gzip.NewReader(bufio.NewReaderSize(r, gzipStreamingBufferSize))
+	if err != nil {
+		return nil, err
+	}
+	defer gz.Close()
+
+	counter := &countingReader{r: gz}
+
+	var members []archiveMember
+
+	tr := tar.NewReader(counter)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return members, nil
+		} else if err != nil {
+			return nil, err
+		}
+
+		members = append(members, archiveMember{Name: header.Name, Size: header.Size, Offset: counter.n})
+	}
+}
+
+// countingReader counts the bytes read through it.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
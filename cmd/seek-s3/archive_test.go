@@ -0,0 +1,202 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// archiveFiles are the members of the archives the tests list.
+var archiveFiles = []struct {
+	name string
+	data string
+}{
+	{"a.txt", "hello"},
+	{"dir/b.bin", strings.Repeat("0123456789", 100)},
+	{"dir/empty", ""},
+}
+
+// parseListing parses the output of list-archive.
+func parseListing(t *testing.T, stdout string) []archiveMember {
+	t.Helper()
+
+	var members []archiveMember
+	for _, line := range strings.Split(strings.TrimSuffix(stdout, "\n"), "\n") {
+		var member archiveMember
+		if _, err := fmt.Sscanf(line, "%d\t%d\t%s", &member.Offset, &member.Size, &member.Name); err != nil {
+			t.Fatalf("Unable to parse listing %q: %v", line, err)
+		}
+		members = append(members, member)
+	}
+	return members
+}
+
+// TestListArchiveZip tests that list-archive lists a zip archive from its central directory, past a comment, giving
+// the offset of each member's local header.
+func TestListArchiveZip(t *testing.T) {
+	var b bytes.Buffer
+	zw := zip.NewWriter(&b)
+	for _, file := range archiveFiles {
+		w, err := zw.Create(file.name)
+		if err != nil {
+			t.Fatalf("Error calling Create: %v", err)
+		}
+		if _, err = w.Write([]byte(file.data)); err != nil {
+			t.Fatalf("Error calling Write: %v", err)
+		}
+	}
+	if err := zw.SetComment(strings.Repeat("c", 1000)); err != nil {
+		t.Fatalf("Error calling SetComment: %v", err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("Error calling Close: %v", err)
+	}
+	archive := b.Bytes()
+
+	server := newObjectServer(t, map[string]string{"bucket/archive.zip": string(archive)})
+	stdout, code := runSeekS3(t, "list-archive", "-endpoint", server.URL, "-path-style", "-no-sign-request",
+		"s3://bucket/archive.zip")
+	if code != 0 {
+		t.Fatalf("Expected exit code 0, got %d", code)
+	}
+
+	members := parseListing(t, stdout)
+	if len(members) != len(archiveFiles) {
+		t.Fatalf("Expected %d members, got %+v", len(archiveFiles), members)
+	}
+	for i, member := range members {
+		file := archiveFiles[i]
+		if member.Name != file.name || member.Size != int64(len(file.data)) {
+			t.Fatalf("Expected member %s of %d bytes, got %+v", file.name, len(file.data), member)
+		}
+		header := archive[member.Offset:]
+		if binary.LittleEndian.Uint32(header) != 0x04034b50 || string(header[30:30+len(file.name)]) != file.name {
+			t.Fatalf("Expected the local header of %s at offset %d", file.name, member.Offset)
+		}
+	}
+}
+
+// TestListZip64 tests that listZip reads the zip64 end of central directory record, and the sizes and offsets that
+// overflow a member's central directory header from its zip64 extra field.
+func TestListZip64(t *testing.T) {
+	const size, offset = 5 << 30, 6 << 30
+
+	var dir bytes.Buffer
+	name := "big.bin"
+	header := make([]byte, zipDirectoryHeaderLen)
+	binary.LittleEndian.PutUint32(header, zipDirectorySignature)
+	binary.LittleEndian.PutUint32(header[20:], 0xffffffff)
+	binary.LittleEndian.PutUint32(header[24:], 0xffffffff)
+	binary.LittleEndian.PutUint16(header[28:], uint16(len(name)))
+	binary.LittleEndian.PutUint16(header[30:], 4+24)
+	binary.LittleEndian.PutUint32(header[42:], 0xffffffff)
+	dir.Write(header)
+	dir.WriteString(name)
+	extra := make([]byte, 4+24)
+	binary.LittleEndian.PutUint16(extra, zip64ExtraID)
+	binary.LittleEndian.PutUint16(extra[2:], 24)
+	binary.LittleEndian.PutUint64(extra[4:], size)
+	binary.LittleEndian.PutUint64(extra[12:], size)
+	binary.LittleEndian.PutUint64(extra[20:], offset)
+	dir.Write(extra)
+
+	// The archive's member data is left out, so the central directory starts at the beginning.
+	archive := append([]byte(nil), dir.Bytes()...)
+	record := make([]byte, zip64EndLen)
+	binary.LittleEndian.PutUint32(record, zip64EndSignature)
+	binary.LittleEndian.PutUint64(record[32:], 1)
+	binary.LittleEndian.PutUint64(record[40:], uint64(dir.Len()))
+	binary.LittleEndian.PutUint64(record[48:], 0)
+	recordOffset := len(archive)
+	archive = append(archive, record...)
+
+	locator := make([]byte, zip64LocatorLen)
+	binary.LittleEndian.PutUint32(locator, zip64LocatorSignature)
+	binary.LittleEndian.PutUint64(locator[8:], uint64(recordOffset))
+	archive = append(archive, locator...)
+
+	end := make([]byte, zipEndLen)
+	binary.LittleEndian.PutUint32(end, zipEndSignature)
+	binary.LittleEndian.PutUint16(end[10:], 0xffff)
+	binary.LittleEndian.PutUint32(end[12:], 0xffffffff)
+	binary.LittleEndian.PutUint32(end[16:], 0xffffffff)
+	archive = append(archive, end...)
+
+	members, err := listZip(bytes.NewReader(archive), int64(len(archive)))
+	if err != nil {
+		t.Fatalf("Error calling listZip: %v", err)
+	}
+	if len(members) != 1 || members[0] != (archiveMember{Name: name, Size: size, Offset: offset}) {
+		t.Fatalf("Expected %s of %d bytes at offset %d, got %+v", name, int64(size), int64(offset), members)
+	}
+
+	if _, err = listZip(bytes.NewReader(archive[:len(archive)-zipEndLen]), int64(len(archive)-zipEndLen)); err == nil {
+		t.Fatalf("Expected an error listing an archive without an end of central directory record")
+	}
+}
+
+// TestListArchiveTar tests that list-archive lists tar and tar.gz archives, giving the offset of each member's data,
+// in the uncompressed stream for tar.gz.
+func TestListArchiveTar(t *testing.T) {
+	var b bytes.Buffer
+	tw := tar.NewWriter(&b)
+	for _, file := range archiveFiles {
+		if err := tw.WriteHeader(&tar.Header{Name: file.name, Mode: 0644, Size: int64(len(file.data))}); err != nil {
+			t.Fatalf("Error calling WriteHeader: %v", err)
+		}
+		if _, err := tw.Write([]byte(file.data)); err != nil {
+			t.Fatalf("Error calling Write: %v", err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("Error calling Close: %v", err)
+	}
+	archive := b.Bytes()
+
+	var gz bytes.Buffer
+	gw := gzip.NewWriter(&gz)
+	if _, err := gw.Write(archive); err != nil {
+		t.Fatalf("Error calling Write: %v", err)
+	}
+	if err := gw.Close(); err != nil {
+		t.Fatalf("Error calling Close: %v", err)
+	}
+
+	server := newObjectServer(t, map[string]string{
+		"bucket/archive.tar":    string(archive),
+		"bucket/archive.tar.gz": string(gz.Bytes()),
+		"bucket/archive":        string(archive),
+	})
+	for _, args := range [][]string{
+		{"s3://bucket/archive.tar"},
+		{"s3://bucket/archive.tar.gz"},
+		{"-format", "tar", "s3://bucket/archive"},
+	} {
+		stdout, code := runSeekS3(t, append([]string{"list-archive", "-endpoint", server.URL, "-path-style",
+			"-no-sign-request"}, args...)...)
+		if code != 0 {
+			t.Fatalf("Expected exit code 0 listing %v, got %d", args, code)
+		}
+
+		members := parseListing(t, stdout)
+		if len(members) != len(archiveFiles) {
+			t.Fatalf("Expected %d members listing %v, got %+v", len(archiveFiles), args, members)
+		}
+		for i, member := range members {
+			file := archiveFiles[i]
+			if member.Name != file.name || string(archive[member.Offset:member.Offset+member.Size]) != file.data {
+				t.Fatalf("Expected member %s at its data's offset listing %v, got %+v", file.name, args, member)
+			}
+		}
+	}
+
+	if _, code := runSeekS3(t, "list-archive", "-endpoint", server.URL, "-path-style", "-no-sign-request",
+		"s3://bucket/archive"); code == 0 {
+		t.Fatalf("Expected an error listing an archive of unknown format")
+	}
+}
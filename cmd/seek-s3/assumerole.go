@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/pkg/errors"
+)
+
+// promptMFA prompts for the code of an MFA device. It is a variable so that tests need not prompt.
+var promptMFA = promptMFAToken
+
+// loadConfig loads the AWS config, with optFns, and, given -assume-role, replaces its credentials with those of the
+// role, assumed with the credentials the config would otherwise use.
+func (c *commonFlags) loadConfig(ctx context.Context, optFns ...func(*config.LoadOptions) error) (aws.Config, error) {
+	if c.assumeRole == "" && (c.externalID != "" || c.mfaSerial != "") {
+		return aws.Config{}, errors.New("-external-id and -mfa-serial require -assume-role")
+	} else if c.assumeRole != "" && c.anonymous {
+		return aws.Config{}, errors.New("-assume-role cannot be used with -no-sign-request")
+	}
+
+	cfg, err := config.LoadDefaultConfig(ctx, optFns...)
+	if err != nil || c.assumeRole == "" {
+		return cfg, err
+	}
+
+	provider := stscreds.NewAssumeRoleProvider(sts.NewFromConfig(cfg), c.assumeRole,
+		func(o *stscreds.AssumeRoleOptions) {
+			o.RoleSessionName = c.roleSessionName
+			if c.externalID != "" {
+				o.ExternalID = aws.String(c.externalID)
+			}
+			if c.mfaSerial != "" {
+				o.SerialNumber = aws.String(c.mfaSerial)
+				o.TokenProvider = func() (string, error) {
+					return promptMFA(c.mfaSerial)
+				}
+			}
+		})
+	cfg.Credentials = aws.NewCredentialsCache(provider)
+
+	return cfg, nil
+}
+
+// promptMFAToken prompts for the current code of the MFA device with the given serial number. It prompts on the
+// terminal rather than stdin where it can, since seek-s3 cat may be reading S3 URLs from stdin.
+func promptMFAToken(serial string) (string, error) {
+	var in io.Reader = os.Stdin
+	var out io.Writer = os.Stderr
+	if tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0); err == nil {
+		defer tty.Close()
+		in, out = tty, tty
+	}
+
+	return readMFAToken(in, out, serial)
+}
+
+// readMFAToken prompts on out for the code of the MFA device with the given serial number, and reads it from in.
+func readMFAToken(in io.Reader, out io.Writer, serial string) (string, error) {
+	fmt.Fprintf(out, "Enter MFA code for %s: ", serial)
+	code, err := bufio.NewReader(in).ReadString('\n')
+	if err != nil && (err != io.EOF || code == "") {
+		return "", errors.Wrap(err, "unable to read MFA code")
+	}
+
+	return strings.TrimSpace(code), nil
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+)
+
+// assumeRoleResponse is an STS AssumeRole response holding the role's credentials.
+const assumeRoleResponse = `<AssumeRoleResponse xmlns="https://sts.amazonaws.com/doc/2011-06-15/">
+  <AssumeRoleResult>
+    <Credentials>
+      <AccessKeyId>ASIAROLEACCESSKEY</AccessKeyId>
+      <SecretAccessKey>role-secret</SecretAccessKey>
+      <SessionToken>role-session-token</SessionToken>
+      <Expiration>2100-01-01T00:00:00Z</Expiration>
+    </Credentials>
+    <AssumedRoleUser>
+      <Arn>arn:aws:sts::123456789012:assumed-role/reader/seek-s3</Arn>
+      <AssumedRoleId>AROAEXAMPLE:seek-s3</AssumedRoleId>
+    </AssumedRoleUser>
+  </AssumeRoleResult>
+  <ResponseMetadata>
+    <RequestId>request-id</RequestId>
+  </ResponseMetadata>
+</AssumeRoleResponse>`
+
+// newSTSServer starts a server that answers STS AssumeRole requests, returning it and a function that returns the
+// parameters, less Action and Version, and the Authorization header of the last request.
+func newSTSServer(t *testing.T) (*httptest.Server, func() (url.Values, string)) {
+	var mu sync.Mutex
+	var last url.Values
+	var authorization string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil || r.PostForm.Get("Action") != "AssumeRole" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		mu.Lock()
+		last, authorization = r.PostForm, r.Header.Get("Authorization")
+		last.Del("Action")
+		last.Del("Version")
+		mu.Unlock()
+
+		w.Header().Set("Content-Type", "text/xml")
+		_, _ = w.Write([]byte(assumeRoleResponse))
+	}))
+	t.Cleanup(server.Close)
+
+	return server, func() (url.Values, string) {
+		mu.Lock()
+		defer mu.Unlock()
+		return last, authorization
+	}
+}
+
+// TestLoadConfigAssumeRole tests that, given -assume-role, loadConfig assumes the role with the configured
+// credentials, passing -external-id, -role-session-name and -mfa-serial with the prompted code.
+func TestLoadConfigAssumeRole(t *testing.T) {
+	server, lastRequest := newSTSServer(t)
+	optFns := []func(*config.LoadOptions) error{
+		config.WithSharedConfigFiles([]string{}),
+		config.WithSharedCredentialsFiles([]string{}),
+		config.WithRegion("us-east-1"),
+		config.WithCredentialsProvider(credentials.NewStaticCredentialsProvider("AKIDUSER", "user-secret", "")),
+		config.WithEndpointResolverWithOptions(aws.EndpointResolverWithOptionsFunc(
+			func(service, region string, options ...interface{}) (aws.Endpoint, error) {
+				return aws.Endpoint{URL: server.URL}, nil
+			})),
+	}
+
+	promptMFA = func(serial string) (string, error) {
+		return "123456", nil
+	}
+	defer func() { promptMFA = promptMFAToken }()
+
+	for _, test := range []struct {
+		flags    commonFlags
+		expected url.Values
+	}{
+		{
+			commonFlags{assumeRole: "arn:aws:iam::123456789012:role/reader", roleSessionName: "seek-s3"},
+			url.Values{"RoleArn": {"arn:aws:iam::123456789012:role/reader"}, "RoleSessionName": {"seek-s3"},
+				"DurationSeconds": {"900"}},
+		},
+		{
+			commonFlags{assumeRole: "arn:aws:iam::123456789012:role/reader", roleSessionName: "job",
+				externalID: "external", mfaSerial: "arn:aws:iam::123456789012:mfa/me"},
+			url.Values{"RoleArn": {"arn:aws:iam::123456789012:role/reader"}, "RoleSessionName": {"job"},
+				"DurationSeconds": {"900"},
+				"ExternalId":      {"external"}, "SerialNumber": {"arn:aws:iam::123456789012:mfa/me"},
+				"TokenCode": {"123456"}},
+		},
+	} {
+		cfg, err := test.flags.loadConfig(context.Background(), optFns...)
+		if err != nil {
+			t.Fatalf("Error calling loadConfig: %v", err)
+		}
+
+		creds, err := cfg.Credentials.Retrieve(context.Background())
+		if err != nil {
+			t.Fatalf("Error calling Retrieve: %v", err)
+		}
+		if creds.AccessKeyID != "ASIAROLEACCESSKEY" || creds.SessionToken != "role-session-token" {
+			t.Fatalf("Expected the role's credentials, got %+v", creds)
+		}
+
+		params, authorization := lastRequest()
+		if !reflect.DeepEqual(params, test.expected) {
+			t.Fatalf("Expected AssumeRole with %v, got %v", test.expected, params)
+		}
+		if !strings.Contains(authorization, "Credential=AKIDUSER/") {
+			t.Fatalf("Expected AssumeRole signed with the configured credentials, got %q", authorization)
+		}
+	}
+}
+
+// TestLoadConfigFlags tests that -external-id and -mfa-serial are rejected without -assume-role, and -assume-role with
+// -no-sign-request.
+func TestLoadConfigFlags(t *testing.T) {
+	for _, args := range [][]string{
+		{"-external-id", "external"},
+		{"-mfa-serial", "arn:aws:iam::123456789012:mfa/me"},
+		{"-assume-role", "arn:aws:iam::123456789012:role/reader", "-no-sign-request"},
+	} {
+		cmd := seekS3Command(append(append([]string{"cat"}, args...), "s3://bucket/key")...)
+		_, stderr, code := runCommand(t, cmd)
+		if code != 1 || !strings.Contains(stderr, "Unable to load AWS config") {
+			t.Errorf("Expected exit code 1 and a config error running %v, got %d: %s", args, code, stderr)
+		}
+	}
+}
+
+// TestReadMFAToken tests that the MFA code is read from the first line of input, after prompting for it.
+func TestReadMFAToken(t *testing.T) {
+	for _, input := range []string{"123456\n", " 123456 \r\n", "123456"} {
+		var out bytes.Buffer
+		code, err := readMFAToken(strings.NewReader(input), &out, "arn:aws:iam::123456789012:mfa/me")
+		if err != nil {
+			t.Fatalf("Error calling readMFAToken: %v", err)
+		}
+		if code != "123456" || out.String() != "Enter MFA code for arn:aws:iam::123456789012:mfa/me: " {
+			t.Fatalf("Expected code 123456 after a prompt, got %q after %q", code, out.String())
+		}
+	}
+
+	if _, err := readMFAToken(strings.NewReader(""), &bytes.Buffer{}, "serial"); err == nil {
+		t.Fatalf("Expected an error reading an MFA code from empty input")
+	}
+}
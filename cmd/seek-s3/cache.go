@@ -0,0 +1,188 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/markandrus/s3readerat/cache"
+	"github.com/pkg/errors"
+)
+
+// cacheStats is the JSON printed by cache stats.
+type cacheStats struct {
+	Objects       int        `json:"objects"`
+	Blocks        int        `json:"blocks"`
+	Bytes         int64      `json:"bytes"`
+	BlockSize     int64      `json:"block_size"`
+	LeastRecent   *time.Time `json:"least_recent_use,omitempty"`
+	MostRecent    *time.Time `json:"most_recent_use,omitempty"`
+	CorruptBlocks *int       `json:"corrupt_blocks,omitempty"`
+}
+
+// cacheCommand implements the cache subcommand, which inspects and garbage collects a disk cache used by seek-s3 serve
+// and warm.
+func cacheCommand(args []string) {
+	usage := func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s cache ls|stats|gc [flags]\n\n", os.Args[0])
+		fmt.Fprintln(os.Stderr, "  ls     lists cached objects, their ETags, sizes and cached ranges")
+		fmt.Fprintln(os.Stderr, "  stats  summarizes the cache, optionally verifying every block")
+		fmt.Fprintln(os.Stderr, "  gc     evicts blocks by age or total size")
+	}
+
+	if len(args) == 0 {
+		usage()
+		os.Exit(2)
+	}
+
+	flags := flag.NewFlagSet("cache "+args[0], flag.ExitOnError)
+	cacheDir := flags.String("cache-dir", "",
+		"cache `directory`, as passed to seek-s3 serve (default is the per-user cache directory)")
+	cacheKeyFile := flags.String("cache-key-file", "", "key `file` of an encrypted cache, as passed to seek-s3 serve")
+
+	var run func(store *cache.DiskStore)
+	switch args[0] {
+	case "ls":
+		run = cacheLs
+	case "stats":
+		verify := flags.Bool("verify", false, "read every block, removing any that fail to verify")
+		run = func(store *cache.DiskStore) { cacheStatsCommand(store, *verify) }
+	case "gc":
+		maxAge := flags.Duration("max-age", 0, "evict blocks unused for longer than this (0 is no limit)")
+		maxBytes := flags.Int64("max-bytes", 0,
+			"evict the least recently used blocks beyond this many bytes (0 is no limit)")
+		run = func(store *cache.DiskStore) { cacheGC(store, cache.GCOptions{MaxAge: *maxAge, MaxBytes: *maxBytes}) }
+	default:
+		usage()
+		os.Exit(2)
+	}
+	_ = flags.Parse(args[1:])
+
+	if flags.NArg() != 0 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	if *cacheDir == "" {
+		dir, err := cache.DefaultDir()
+		if err != nil {
+			fatalf("Unable to open cache: %v", err)
+		}
+		*cacheDir = dir
+	}
+
+	if _, err := os.Stat(*cacheDir); err != nil {
+		fatalf("Unable to open cache: %v", err)
+	}
+
+	store, err := openDiskStore(*cacheDir, 0, *cacheKeyFile)
+	if err != nil {
+		fatalf("Unable to open cache: %v", err)
+	}
+
+	run(store)
+}
+
+// cacheLs prints each cached object version as tab-separated name, ETag, size, cached bytes and cached ranges.
+func cacheLs(store *cache.DiskStore) {
+	objects, err := store.Objects()
+	if err != nil {
+		fatalf("Unable to list cache: %v", err)
+	}
+
+	for _, object := range objects {
+		var ranges []string
+		for i := 0; i < len(object.Blocks); {
+			first := object.Blocks[i].Index * store.BlockSize()
+			last := first + object.Blocks[i].Size - 1
+			for i++; i < len(object.Blocks) && object.Blocks[i].Index*store.BlockSize() == last+1; i++ {
+				last += object.Blocks[i].Size
+			}
+			ranges = append(ranges, fmt.Sprintf("%d-%d", first, last))
+		}
+
+		fmt.Printf("%s\t%s\t%d\t%d\t%s\n", object.Name, object.Version, object.Size, object.CachedBytes(),
+			strings.Join(ranges, ","))
+	}
+}
+
+// cacheStatsCommand prints a JSON summary of the cache, first verifying every block if verify is set.
+func cacheStatsCommand(store *cache.DiskStore, verify bool) {
+	stats := cacheStats{BlockSize: store.BlockSize()}
+
+	if verify {
+		removed, err := store.Verify()
+		if err != nil {
+			fatalf("Unable to verify cache: %v", err)
+		}
+		stats.CorruptBlocks = &removed
+		if removed > 0 {
+			infof("Removed %d corrupt blocks", removed)
+		}
+	}
+
+	objects, err := store.Objects()
+	if err != nil {
+		fatalf("Unable to list cache: %v", err)
+	}
+
+	for _, object := range objects {
+		stats.Objects++
+		for _, block := range object.Blocks {
+			stats.Blocks++
+			stats.Bytes += block.Size
+
+			used := block.LastUsed
+			if stats.LeastRecent == nil || used.Before(*stats.LeastRecent) {
+				stats.LeastRecent = &used
+			}
+			if stats.MostRecent == nil || used.After(*stats.MostRecent) {
+				stats.MostRecent = &used
+			}
+		}
+	}
+
+	printJSON(stats)
+}
+
+// cacheGC evicts blocks according to options and prints a JSON summary of what was evicted.
+func cacheGC(store *cache.DiskStore, options cache.GCOptions) {
+	result, err := store.GC(options)
+	if err != nil {
+		fatalf("Unable to collect garbage: %v", err)
+	}
+
+	infof("Evicted %d blocks (%d bytes)", result.Blocks, result.Bytes)
+	printJSON(result)
+}
+
+// openDiskStore opens the disk cache in dir, as cache.OpenDiskStore does, encrypting its blocks with the hex-encoded
+// AES key in keyFile, if one is given.
+func openDiskStore(dir string, blockSize int64, keyFile string) (*cache.DiskStore, error) {
+	options := cache.DiskStoreOptions{BlockSize: blockSize}
+	if keyFile != "" {
+		b, err := ioutil.ReadFile(keyFile)
+		if err != nil {
+			return nil, err
+		}
+		if options.Key, err = hex.DecodeString(strings.TrimSpace(string(b))); err != nil {
+			return nil, errors.Wrapf(err, "unable to parse %s", keyFile)
+		}
+	}
+
+	return cache.OpenDiskStoreWithOptions(dir, options)
+}
+
+// printJSON prints v to stdout as indented JSON, exiting on failure.
+func printJSON(v interface{}) {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(v); err != nil {
+		fatalf("Unable to encode JSON: %v", err)
+	}
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	s3readerat "github.com/markandrus/s3readerat"
+	"github.com/pkg/errors"
+)
+
+// cat implements the cat subcommand, which streams an S3 object, or a range of it, to stdout. Given "-" or
+// -keys-from-stdin, it instead streams each object listed on stdin in turn.
+func cat(args []string) {
+	flags := flag.NewFlagSet("cat", flag.ExitOnError)
+	common := newCommonFlags(flags)
+	offset := flags.Int64("offset", 0, "offset of the first byte to print")
+	length := flags.Int64("length", -1, "number of bytes to print (-1 is the rest of the object)")
+	keysFromStdin := flags.Bool("keys-from-stdin", false, "stream the objects whose S3 URLs are listed on stdin")
+	parallel := flags.Int("parallel", 4, "number of upcoming objects to prefetch with -keys-from-stdin")
+	prefetchBytes := flags.Int64("prefetch-bytes", 1<<20,
+		"number of bytes at the start of each upcoming object to prefetch with -keys-from-stdin")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s cat [flags] s3://bucket/key\n", os.Args[0])
+		fmt.Fprintf(flags.Output(), "       %s cat [flags] -\n\n", os.Args[0])
+		fmt.Fprintln(flags.Output(), "Streams an S3 object to stdout with a single GetObject request, resuming")
+		fmt.Fprintln(flags.Output(), "from the last byte written if the download fails partway through.")
+		fmt.Fprintln(flags.Output())
+		fmt.Fprintln(flags.Output(), "Given - or -keys-from-stdin, streams the objects whose S3 URLs are listed on")
+		fmt.Fprintln(flags.Output(), "stdin, one per line, in order. The first bytes of the next few objects are")
+		fmt.Fprintln(flags.Output(), "fetched while each is streamed.")
+		fmt.Fprintln(flags.Output())
+		flags.PrintDefaults()
+	}
+	_ = flags.Parse(args)
+
+	if flags.NArg() == 1 && flags.Arg(0) == "-" {
+		*keysFromStdin = true
+	} else if *keysFromStdin != (flags.NArg() == 0) || flags.NArg() > 1 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	if *offset < 0 {
+		fatalf("Offset parameter must not be negative")
+	}
+
+	if *keysFromStdin {
+		if *offset != 0 || *length != -1 {
+			fatalf("Offset and length parameters cannot be used with -keys-from-stdin")
+		} else if *parallel <= 0 {
+			fatalf("Parallel parameter must be positive")
+		} else if *prefetchBytes < 0 {
+			fatalf("Prefetch bytes parameter must not be negative")
+		}
+
+		n, err := catKeys(common, os.Stdin, os.Stdout, *parallel, *prefetchBytes)
+		if err != nil {
+			fatalf("Failed to copy S3 objects: %v", err)
+		}
+		infof("Copied %d objects", n)
+		return
+	}
+
+	reader := common.open(flags.Arg(0))
+
+	n, err := reader.CopyRange(os.Stdout, *offset, *length)
+	if err != nil && err != io.EOF {
+		fatalf("Failed to copy S3 object: %v", err)
+	}
+	infof("Copied %d bytes", n)
+}
+
+// catEntry is an object listed on stdin, along with the prefetch of its first bytes. done is closed once size,
+// prefix and err are set.
+type catEntry struct {
+	url    string
+	reader *s3readerat.S3ReaderAt
+	size   int64
+	prefix []byte
+	err    error
+	done   chan struct{}
+}
+
+// catKeys writes the objects whose S3 URLs are listed in r to w, in order, returning the number written. While each
+// object is written, the first prefetchBytes bytes of the next parallel objects are fetched, and the rest of each
+// object is then streamed with CopyRange.
+func catKeys(common *commonFlags, r io.Reader, w io.Writer, parallel int, prefetchBytes int64) (int, error) {
+	common.setup()
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1<<20)
+
+	var pending []*catEntry
+	listed := true
+	written := 0
+	for {
+		for listed && len(pending) < parallel {
+			if !scanner.Scan() {
+				if err := scanner.Err(); err != nil {
+					return written, errors.Wrap(err, "unable to read S3 URLs from stdin")
+				}
+				listed = false
+				break
+			}
+
+			url := strings.TrimSpace(scanner.Text())
+			if url == "" {
+				continue
+			}
+			parsed := common.parseURL(url)
+
+			reader, err := common.newReader(*parsed)
+			if err != nil {
+				return written, errors.Wrapf(err, "unable to open %s", url)
+			}
+			common.readers = append(common.readers, reader)
+
+			entry := &catEntry{url: url, reader: reader, done: make(chan struct{})}
+			go entry.prefetch(prefetchBytes)
+			pending = append(pending, entry)
+		}
+		if len(pending) == 0 {
+			return written, nil
+		}
+
+		entry := pending[0]
+		pending = pending[1:]
+		if err := entry.copy(w); err != nil {
+			return written, errors.Wrapf(err, "unable to copy %s", entry.url)
+		}
+		infof("Copied %s", entry.url)
+		written++
+	}
+}
+
+// prefetch reads the size of the object and up to n bytes from its start.
+func (e *catEntry) prefetch(n int64) {
+	defer close(e.done)
+
+	if e.size, e.err = e.reader.Size(); e.err != nil {
+		return
+	}
+	if n > e.size {
+		n = e.size
+	}
+
+	e.prefix = make([]byte, n)
+	read, err := e.reader.ReadAt(e.prefix, 0)
+	if int64(read) < n {
+		if err == nil {
+			err = io.ErrUnexpectedEOF
+		}
+		e.err = err
+	}
+}
+
+// copy writes the object to w once its prefetch completes, streaming the bytes after the prefetched ones.
+func (e *catEntry) copy(w io.Writer) error {
+	<-e.done
+	if e.err != nil {
+		return e.err
+	}
+
+	if _, err := w.Write(e.prefix); err != nil {
+		return err
+	}
+	if e.size == int64(len(e.prefix)) {
+		return nil
+	}
+
+	if _, err := e.reader.CopyRange(w, int64(len(e.prefix)), -1); err != nil && err != io.EOF {
+		return err
+	}
+	return nil
+}
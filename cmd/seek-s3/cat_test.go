@@ -0,0 +1,83 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestCat tests that the cat subcommand streams an object, or a range of it.
+func TestCat(t *testing.T) {
+	server := newObjectServer(t, map[string]string{"bucket/a": "0123456789"})
+	flags := []string{"cat", "-endpoint", server.URL, "-path-style", "-no-sign-request"}
+
+	for _, test := range []struct {
+		args     []string
+		expected string
+	}{
+		{[]string{"s3://bucket/a"}, "0123456789"},
+		{[]string{"-offset", "2", "-length", "3", "s3://bucket/a"}, "234"},
+		{[]string{"-offset", "7", "s3://bucket/a"}, "789"},
+	} {
+		stdout, code := runSeekS3(t, append(flags, test.args...)...)
+		if code != 0 || stdout != test.expected {
+			t.Fatalf("Expected %q and exit code 0 running %v, got %q and %d", test.expected, test.args, stdout, code)
+		}
+	}
+}
+
+// TestCatKeysFromStdin tests that, given - or -keys-from-stdin, the cat subcommand streams the objects listed on
+// stdin in order, whether or not they fit within the prefetched bytes, and stops at the first that cannot be read.
+func TestCatKeysFromStdin(t *testing.T) {
+	server := newObjectServer(t, map[string]string{
+		"bucket/a":     "0123456789",
+		"bucket/b":     "ab",
+		"bucket/c":     "",
+		"bucket/d e/f": "ABCDEFGHIJ",
+	})
+	flags := []string{"cat", "-endpoint", server.URL, "-path-style", "-no-sign-request"}
+	stdin := "s3://bucket/a\n\n  s3://bucket/b  \ns3://bucket/c\ns3://bucket/d e/f\ns3://bucket/a\n"
+	expected := "0123456789" + "ab" + "ABCDEFGHIJ" + "0123456789"
+
+	for _, args := range [][]string{
+		{"-"},
+		{"-keys-from-stdin"},
+		{"-parallel", "1", "-prefetch-bytes", "0", "-"},
+		{"-parallel", "2", "-prefetch-bytes", "4", "-"},
+		{"-prefetch-bytes", "100", "-"},
+	} {
+		cmd := seekS3Command(append(flags, args...)...)
+		cmd.Stdin = strings.NewReader(stdin)
+		stdout, stderr, code := runCommand(t, cmd)
+		if code != 0 || stdout != expected {
+			t.Fatalf("Expected %q and exit code 0 running %v, got %q and %d: %s", expected, args, stdout, code, stderr)
+		}
+	}
+
+	cmd := seekS3Command(append(flags, "-")...)
+	cmd.Stdin = strings.NewReader("s3://bucket/b\ns3://bucket/missing\ns3://bucket/a\n")
+	stdout, stderr, code := runCommand(t, cmd)
+	if code != 1 || stdout != "ab" || !strings.Contains(stderr, "s3://bucket/missing") {
+		t.Fatalf("Expected the objects before a missing one and exit code 1, got %q and %d: %s", stdout, code, stderr)
+	}
+}
+
+// TestCatFlags tests that the cat subcommand rejects invalid combinations of arguments and flags.
+func TestCatFlags(t *testing.T) {
+	for _, test := range []struct {
+		args []string
+		code int
+	}{
+		{[]string{}, 2},
+		{[]string{"s3://bucket/a", "s3://bucket/b"}, 2},
+		{[]string{"-keys-from-stdin", "s3://bucket/a"}, 2},
+		{[]string{"-offset", "-1", "s3://bucket/a"}, 1},
+		{[]string{"-offset", "1", "-"}, 1},
+		{[]string{"-length", "1", "-keys-from-stdin"}, 1},
+		{[]string{"-parallel", "0", "-"}, 1},
+		{[]string{"-prefetch-bytes", "-1", "-"}, 1},
+	} {
+		if _, code := runSeekS3(t, append([]string{"cat"}, test.args...)...); code != test.code {
+			t.Errorf("Expected exit code %d running %v, got %d", test.code, test.args, code)
+		}
+	}
+}
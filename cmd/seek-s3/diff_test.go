@@ -0,0 +1,24 @@
+package main
+
+import "testing"
+
+// TestDiff tests that the diff subcommand prints the regions in which two objects differ and exits 1, and exits 0 when
+// they are the same.
+func TestDiff(t *testing.T) {
+	server := newObjectServer(t, map[string]string{
+		"bucket/a": "0123456789",
+		"bucket/b": "0123X56789",
+		"bucket/c": "0123456789",
+	})
+	flags := []string{"-endpoint", server.URL, "-path-style", "-no-sign-request"}
+
+	stdout, code := runSeekS3(t, append(append([]string{"diff"}, flags...), "s3://bucket/a", "s3://bucket/b")...)
+	if code != 1 || stdout != "0\t10\tsample\n" {
+		t.Fatalf("Expected one differing region and exit code 1, got %q and %d", stdout, code)
+	}
+
+	stdout, code = runSeekS3(t, append(append([]string{"diff"}, flags...), "s3://bucket/a", "s3://bucket/c")...)
+	if code != 0 || stdout != "" {
+		t.Fatalf("Expected no differing regions and exit code 0, got %q and %d", stdout, code)
+	}
+}
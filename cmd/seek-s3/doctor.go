@@ -0,0 +1,314 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/smithy-go"
+	"github.com/pkg/errors"
+)
+
+const (
+	// clockSkewWarning is the clock skew beyond which doctor warns. S3 rejects requests signed more than 15 minutes
+	// from its own clock.
+	clockSkewWarning = time.Minute
+	clockSkewFailure = 15 * time.Minute
+)
+
+// diagnosis collects the findings of the doctor subcommand.
+type diagnosis struct {
+	failed bool
+}
+
+// ok reports a check that passed.
+func (d *diagnosis) ok(check string, format string, args ...interface{}) {
+	fmt.Printf("[ok]   %s: %s\n", check, fmt.Sprintf(format, args...))
+}
+
+// warn reports a check that passed, but with a finding that may cause problems, and how to address it.
+func (d *diagnosis) warn(check string, hint string, format string, args ...interface{}) {
+	fmt.Printf("[warn] %s: %s\n       %s\n", check, fmt.Sprintf(format, args...), hint)
+}
+
+// fail reports a check that failed, and how to address it.
+func (d *diagnosis) fail(check string, hint string, format string, args ...interface{}) {
+	d.failed = true
+	fmt.Printf("[fail] %s: %s\n       %s\n", check, fmt.Sprintf(format, args...), hint)
+}
+
+// doctor implements the doctor subcommand, which diagnoses the environmental problems that most often stop seek-s3,
+// and the library, from reading an object.
+func doctor(args []string) {
+	flags := flag.NewFlagSet("doctor", flag.ExitOnError)
+	common := newCommonFlags(flags)
+	timeout := flags.Duration("timeout", 30*time.Second, "give up on the checks after this long")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s doctor [flags] s3://bucket[/key]\n\n", os.Args[0])
+		fmt.Fprintln(flags.Output(), "Checks credential resolution, endpoint reachability, clock skew, the bucket's")
+		fmt.Fprintln(flags.Output(), "region and, given a key, HeadObject and GetObject permissions, printing what to")
+		fmt.Fprintln(flags.Output(), "fix for any that fail.")
+		fmt.Fprintln(flags.Output())
+		flags.PrintDefaults()
+	}
+	_ = flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	parsed := common.parseURL(flags.Arg(0))
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	d := &diagnosis{}
+	defer func() {
+		if d.failed {
+			exit(1)
+		}
+	}()
+
+	cfg, err := common.loadConfig(ctx)
+	if err != nil {
+		d.fail("config", "Check AWS_PROFILE, AWS_CONFIG_FILE, the syntax of ~/.aws/config and -assume-role.",
+			"unable to load AWS config: %v", err)
+		return
+	}
+
+	if common.anonymous {
+		d.ok("credentials", "requests are unsigned, as -no-sign-request was given")
+	} else if !checkCredentials(ctx, d, cfg) {
+		return
+	}
+
+	region := cfg.Region
+	if region == "" {
+		region = "us-east-1"
+		d.warn("region", "Set AWS_REGION, or region in ~/.aws/config, to the region you usually use.",
+			"no region is configured; using %s", region)
+	} else {
+		d.ok("region", "configured region is %s", region)
+	}
+
+	endpoint := common.endpoint
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://s3.%s.amazonaws.com/", region)
+	}
+	checkEndpoint(ctx, d, cfg, endpoint)
+
+	// The clients read from the service, and sign their requests, as the other subcommands would.
+	newClient := func(region string) *s3.Client {
+		return s3.NewFromConfig(cfg, func(o *s3.Options) {
+			o.Region = region
+			if common.endpoint != "" {
+				o.EndpointResolver = s3.EndpointResolverFromURL(common.endpoint)
+			}
+			o.UsePathStyle = common.pathStyle
+			if common.anonymous {
+				o.Credentials = aws.AnonymousCredentials{}
+			}
+		})
+	}
+
+	bucketRegion, ok := checkBucket(ctx, d, newClient(region), region, parsed.Bucket)
+	if !ok || parsed.Key == "" {
+		return
+	}
+
+	checkObject(ctx, d, newClient(bucketRegion), parsed.Bucket, parsed.Key)
+}
+
+// checkCredentials checks that credentials can be resolved, reporting where they came from.
+func checkCredentials(ctx context.Context, d *diagnosis, cfg aws.Config) bool {
+	hint := "Set AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY, select a profile with AWS_PROFILE, run aws sso login, " +
+		"or run on an instance or container with a role."
+
+	if cfg.Credentials == nil {
+		d.fail("credentials", hint, "no credential provider is configured")
+		return false
+	}
+
+	creds, err := cfg.Credentials.Retrieve(ctx)
+	if err != nil {
+		d.fail("credentials", hint, "unable to resolve credentials: %v", err)
+		return false
+	}
+
+	keyID := creds.AccessKeyID
+	if len(keyID) > 8 {
+		keyID = keyID[:4] + "..." + keyID[len(keyID)-4:]
+	}
+	d.ok("credentials", "resolved access key %s from %s", keyID, creds.Source)
+
+	if creds.CanExpire {
+		if remaining := time.Until(creds.Expires); remaining < 15*time.Minute {
+			d.warn("credentials", "Refresh them, for example with aws sso login, before starting long-running reads.",
+				"credentials expire in %v", remaining.Round(time.Second))
+		}
+	}
+
+	return true
+}
+
+// checkEndpoint checks that the S3 endpoint is reachable, and compares the clock to the endpoint's, since requests
+// signed with a skewed clock are rejected.
+func checkEndpoint(ctx context.Context, d *diagnosis, cfg aws.Config, endpoint string) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, endpoint, nil)
+	if err != nil {
+		d.fail("endpoint", "Check the configured region and -endpoint.", "invalid endpoint %s: %v", endpoint, err)
+		return
+	}
+
+	var client interface {
+		Do(*http.Request) (*http.Response, error)
+	} = http.DefaultClient
+	if cfg.HTTPClient != nil {
+		client = cfg.HTTPClient
+	}
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		d.fail("endpoint", "Check DNS, firewalls and HTTPS_PROXY/NO_PROXY; S3 must be reachable on port 443.",
+			"unable to reach %s: %v", endpoint, err)
+		return
+	}
+	latency := time.Since(start)
+	_, _ = io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
+	d.ok("endpoint", "reached %s in %v", endpoint, latency.Round(time.Millisecond))
+
+	date, err := http.ParseTime(resp.Header.Get("Date"))
+	if err != nil {
+		d.warn("clock", "Make sure the system clock is synchronized with NTP.",
+			"unable to check clock skew, since the endpoint returned no Date header")
+		return
+	}
+
+	// The Date header has a resolution of a second and was generated during the request.
+	skew := time.Since(date) - latency/2
+	if skew < 0 {
+		skew = -skew
+	}
+
+	switch {
+	case skew >= clockSkewFailure:
+		d.fail("clock", "Synchronize the system clock with NTP; S3 rejects requests with RequestTimeTooSkewed.",
+			"the clock is %v off from S3's", skew.Round(time.Second))
+	case skew >= clockSkewWarning:
+		d.warn("clock", "Synchronize the system clock with NTP before it drifts further.",
+			"the clock is %v off from S3's", skew.Round(time.Second))
+	default:
+		d.ok("clock", "the clock is within %v of S3's", clockSkewWarning)
+	}
+}
+
+// checkBucket checks that the bucket exists with a client for region, and determines its region, returning it.
+func checkBucket(ctx context.Context, d *diagnosis, client *s3.Client, region string, bucket string) (string, bool) {
+	_, err := client.HeadBucket(ctx, &s3.HeadBucketInput{Bucket: aws.String(bucket)})
+	status, header := responseOf(err)
+	bucketRegion := region
+	regionKnown := err == nil
+	if header != nil && header.Get("X-Amz-Bucket-Region") != "" {
+		bucketRegion, regionKnown = header.Get("X-Amz-Bucket-Region"), true
+	}
+
+	switch {
+	case err == nil:
+		d.ok("bucket", "s3://%s exists in %s", bucket, bucketRegion)
+	case status == http.StatusNotFound:
+		d.fail("bucket", "Check the bucket name in the URL.", "s3://%s does not exist", bucket)
+		return "", false
+	case status/100 == 3:
+		d.ok("bucket", "s3://%s exists in %s", bucket, bucketRegion)
+	case status == http.StatusForbidden && regionKnown:
+		d.warn("bucket", "Without s3:ListBucket, reads of missing keys fail with 403 rather than 404. Grant it on "+
+			"arn:aws:s3:::"+bucket+" if you want to tell the two apart.",
+			"s3://%s is in %s, but HeadBucket is forbidden", bucket, bucketRegion)
+	default:
+		d.fail("bucket", "Check network access and that the bucket name is correct.",
+			"unable to check s3://%s: %v", bucket, err)
+		return "", false
+	}
+
+	if bucketRegion != region {
+		hint := "Pass an s3.Options to NewWithOptions (multi-region mode) or set AWS_REGION=" + bucketRegion +
+			", so that requests are not redirected."
+		d.warn("region", hint, "the bucket is in %s, not the configured %s", bucketRegion, region)
+	}
+
+	return bucketRegion, true
+}
+
+// checkObject checks that the object can be read with HeadObject, which Size uses, and with a ranged GetObject, which
+// ReadAt uses. The two can fail differently, for example when only GetObject needs a KMS key.
+func checkObject(ctx context.Context, d *diagnosis, client *s3.Client, bucket string, key string) {
+	url := "s3://" + bucket + "/" + key
+
+	head, err := client.HeadObject(ctx, &s3.HeadObjectInput{Bucket: aws.String(bucket), Key: aws.String(key)})
+	if err != nil {
+		d.fail("head", objectHint(err, bucket, key), "HeadObject of %s failed: %v", url, err)
+	} else {
+		d.ok("head", "%s is %d bytes, ETag %s", url, head.ContentLength, aws.ToString(head.ETag))
+	}
+
+	get, err := client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+		Range:  aws.String("bytes=0-0"),
+	})
+	if err != nil {
+		d.fail("get", objectHint(err, bucket, key), "ranged GetObject of %s failed: %v", url, err)
+		return
+	}
+	_, _ = io.Copy(ioutil.Discard, get.Body)
+	get.Body.Close()
+
+	if get.ContentRange == nil && head != nil && head.ContentLength > 1 {
+		d.warn("get", "The service ignored the Range header, so every read downloads the whole object. Check any "+
+			"proxy or S3-compatible service in between.", "ranged GetObject of %s returned the whole object", url)
+		return
+	}
+	d.ok("get", "ranged GetObject of %s succeeded", url)
+}
+
+// objectHint suggests how to fix a failed HeadObject or GetObject.
+func objectHint(err error, bucket string, key string) string {
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) && strings.HasPrefix(apiErr.ErrorCode(), "KMS.") {
+		return "The object is encrypted with a KMS key; grant kms:Decrypt on it."
+	}
+
+	status, _ := responseOf(err)
+	switch {
+	case status == http.StatusForbidden:
+		return "Grant s3:GetObject on arn:aws:s3:::" + bucket + "/" + key + ", and check bucket policies and SCPs. " +
+			"If the key may not exist, note that missing keys also return 403 without s3:ListBucket."
+	case status == http.StatusNotFound:
+		return "Check the key; keys are case-sensitive, and pass -encoded-keys if the URL is percent-encoded."
+	case status/100 == 3:
+		return "The bucket is in another region; use multi-region mode or set AWS_REGION."
+	default:
+		return "Check network access to S3."
+	}
+}
+
+// responseOf returns the HTTP status and headers of the response that caused err, if any.
+func responseOf(err error) (int, http.Header) {
+	var responseError *awshttp.ResponseError
+	if errors.As(err, &responseError) && responseError.Response != nil {
+		return responseError.Response.StatusCode, responseError.Response.Header
+	}
+	return 0, nil
+}
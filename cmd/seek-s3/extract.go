@@ -0,0 +1,287 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	s3readerat "github.com/markandrus/s3readerat"
+	"github.com/pkg/errors"
+)
+
+// manifestEntry maps a range of an S3 object to an output file. A nil Length means the rest of the object.
+type manifestEntry struct {
+	Source string `json:"source"`
+	Offset int64  `json:"offset"`
+	Length *int64 `json:"length,omitempty"`
+	Output string `json:"output"`
+}
+
+// extractResult reports the outcome of extracting one manifest entry.
+type extractResult struct {
+	manifestEntry
+	Bytes    int64  `json:"bytes"`
+	Attempts int    `json:"attempts"`
+	Verified bool   `json:"verified"`
+	Error    string `json:"error,omitempty"`
+}
+
+// extractReport is printed once all manifest entries have been attempted.
+type extractReport struct {
+	Succeeded int             `json:"succeeded"`
+	Failed    int             `json:"failed"`
+	Results   []extractResult `json:"results"`
+}
+
+// extract implements the extract subcommand, which copies ranges of S3 objects to local files as described by a
+// manifest, then prints a report verifying each one.
+func extract(args []string) {
+	flags := flag.NewFlagSet("extract", flag.ExitOnError)
+	common := newCommonFlags(flags)
+	format := flags.String("format", "", "manifest format: json or csv (default is to infer from the file name)")
+	parallel := flags.Int("parallel", 4, "maximum number of ranges to extract at once")
+	retries := flags.Int("retries", 2, "number of times to retry a failed range")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s extract [flags] manifest\n\n", os.Args[0])
+		fmt.Fprintln(flags.Output(), "Copies ranges of S3 objects to local files. A JSON manifest is an array of")
+		fmt.Fprintln(flags.Output(), "objects with source, offset, length and output fields; a CSV manifest has the")
+		fmt.Fprintln(flags.Output(), "same columns, in that order, with an optional header. An omitted length means")
+		fmt.Fprintln(flags.Output(), "the rest of the object. Outputs must be relative paths within the current")
+		fmt.Fprintln(flags.Output(), "directory. Pass - to read the manifest from stdin. A JSON report of each")
+		fmt.Fprintln(flags.Output(), "range's outcome is printed to stdout.")
+		fmt.Fprintln(flags.Output())
+		flags.PrintDefaults()
+	}
+	_ = flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	if *parallel < 1 {
+		fatalf("Parallel parameter must be positive")
+	}
+
+	if *retries < 0 {
+		fatalf("Retries parameter must not be negative")
+	}
+
+	entries, err := readManifest(flags.Arg(0), *format)
+	if err != nil {
+		fatalf("Unable to read manifest: %v", err)
+	}
+
+	// Open and stat each source up front, so that a bad URL or missing object fails before any output is written.
+	readers := make(map[string]*s3readerat.S3ReaderAt)
+	sizes := make(map[string]int64)
+	for _, entry := range entries {
+		if _, ok := readers[entry.Source]; ok {
+			continue
+		}
+		reader := common.open(entry.Source)
+		size, err := reader.Size()
+		if err != nil {
+			fatalf("Unable to get size of S3 object %s: %v", entry.Source, err)
+		}
+		readers[entry.Source] = reader
+		sizes[entry.Source] = size
+	}
+
+	results := make([]extractResult, len(entries))
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < *parallel; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				entry := entries[i]
+				results[i] = extractEntry(readers[entry.Source], sizes[entry.Source], entry, *retries)
+			}
+		}()
+	}
+	for i := range entries {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	report := extractReport{Results: results}
+	for _, result := range results {
+		if result.Verified {
+			report.Succeeded++
+		} else {
+			report.Failed++
+		}
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err = encoder.Encode(report); err != nil {
+		fatalf("Unable to encode report: %v", err)
+	}
+
+	if report.Failed > 0 {
+		fatalf("Failed to extract %d of %d ranges", report.Failed, len(entries))
+	}
+}
+
+// extractEntry copies one manifest entry to its output file, retrying up to retries times, and verifies the size of
+// the result.
+func extractEntry(reader *s3readerat.S3ReaderAt, size int64, entry manifestEntry, retries int) extractResult {
+	result := extractResult{manifestEntry: entry}
+
+	expected := size - entry.Offset
+	if entry.Length != nil {
+		expected = *entry.Length
+	}
+	if entry.Offset < 0 || expected < 0 || entry.Offset+expected > size {
+		result.Error = fmt.Sprintf("range %d+%d is outside the object, which is %d bytes", entry.Offset, expected, size)
+		return result
+	}
+
+	var err error
+	for result.Attempts = 1; result.Attempts <= retries+1; result.Attempts++ {
+		result.Bytes, err = extractRange(reader, entry.Offset, expected, entry.Output)
+		if err == nil {
+			break
+		}
+		infof("Attempt %d to extract %s failed: %v", result.Attempts, entry.Output, err)
+	}
+	if err != nil {
+		result.Attempts--
+		result.Error = err.Error()
+		return result
+	}
+
+	info, err := os.Stat(entry.Output)
+	switch {
+	case err != nil:
+		result.Error = err.Error()
+	case result.Bytes != expected || info.Size() != expected:
+		result.Error = fmt.Sprintf("expected %d bytes, copied %d and wrote %d", expected, result.Bytes, info.Size())
+	default:
+		result.Verified = true
+		infof("Extracted %d bytes to %s", result.Bytes, entry.Output)
+	}
+
+	return result
+}
+
+// extractRange copies n bytes at off to a new file at path, replacing any existing file.
+func extractRange(reader *s3readerat.S3ReaderAt, off int64, n int64, path string) (int64, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return 0, err
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return 0, err
+	}
+
+	written, err := reader.CopyRange(f, off, n)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+
+	return written, err
+}
+
+// readManifest reads manifest entries from path, or from stdin if path is "-", in the given format.
+func readManifest(path string, format string) ([]manifestEntry, error) {
+	if format == "" {
+		format = "json"
+		if strings.HasSuffix(strings.ToLower(path), ".csv") {
+			format = "csv"
+		}
+	}
+
+	var r io.Reader = os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	var entries []manifestEntry
+	switch format {
+	case "json":
+		if err := json.NewDecoder(r).Decode(&entries); err != nil {
+			return nil, errors.Wrap(err, "invalid JSON manifest")
+		}
+	case "csv":
+		var err error
+		if entries, err = readCSVManifest(r); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, errors.Errorf("unsupported manifest format %q; pass -format json or csv", format)
+	}
+
+	for i, entry := range entries {
+		if entry.Source == "" || entry.Output == "" {
+			return nil, errors.Errorf("manifest entry %d must have a source and an output", i)
+		} else if !isLocalPath(entry.Output) {
+			return nil, errors.Errorf("manifest entry %d has output %q, which is not a relative path within the "+
+				"current directory", i, entry.Output)
+		}
+	}
+
+	return entries, nil
+}
+
+// isLocalPath reports whether path is relative and stays within the current directory, so that a manifest cannot
+// write files elsewhere, such as with an output of ../.bashrc or /etc/passwd.
+func isLocalPath(path string) bool {
+	if filepath.IsAbs(path) || filepath.VolumeName(path) != "" || strings.HasPrefix(path, string(filepath.Separator)) {
+		return false
+	}
+	cleaned := filepath.Clean(path)
+	return cleaned != "." && cleaned != ".." && !strings.HasPrefix(cleaned, ".."+string(filepath.Separator))
+}
+
+// readCSVManifest reads manifest entries from CSV with source, offset, length and output columns, skipping a header
+// row if present.
+func readCSVManifest(r io.Reader) ([]manifestEntry, error) {
+	records, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid CSV manifest")
+	}
+
+	if len(records) > 0 && records[0][0] == "source" {
+		records = records[1:]
+	}
+
+	entries := make([]manifestEntry, 0, len(records))
+	for i, record := range records {
+		if len(record) != 4 {
+			return nil, errors.Errorf("CSV manifest row %d has %d columns, expected 4", i+1, len(record))
+		}
+
+		entry := manifestEntry{Source: record[0], Output: record[3]}
+		if entry.Offset, err = strconv.ParseInt(record[1], 10, 64); err != nil {
+			return nil, errors.Wrapf(err, "invalid offset in CSV manifest row %d", i+1)
+		}
+		if record[2] != "" {
+			length, err := strconv.ParseInt(record[2], 10, 64)
+			if err != nil {
+				return nil, errors.Wrapf(err, "invalid length in CSV manifest row %d", i+1)
+			}
+			entry.Length = &length
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
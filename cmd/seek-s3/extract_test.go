@@ -0,0 +1,135 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// writeManifest writes a manifest named name holding contents to dir, returning its path.
+func writeManifest(t *testing.T, dir string, name string, contents string) string {
+	t.Helper()
+
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, []byte(contents), 0600); err != nil {
+		t.Fatalf("Error calling WriteFile: %v", err)
+	}
+	return path
+}
+
+// TestReadManifest tests that JSON and CSV manifests are read, with or without a CSV header and with omitted lengths,
+// and that malformed manifests are rejected.
+func TestReadManifest(t *testing.T) {
+	dir := t.TempDir()
+	length := int64(4)
+	expected := []manifestEntry{
+		{Source: "s3://bucket/a", Offset: 2, Length: &length, Output: "out/a"},
+		{Source: "s3://bucket/b", Offset: 0, Output: "b"},
+	}
+
+	for _, test := range []struct {
+		name     string
+		contents string
+		format   string
+	}{
+		{"manifest.json", `[{"source": "s3://bucket/a", "offset": 2, "length": 4, "output": "out/a"},
+			{"source": "s3://bucket/b", "output": "b"}]`, ""},
+		{"manifest.csv", "source,offset,length,output\ns3://bucket/a,2,4,out/a\ns3://bucket/b,0,,b\n", ""},
+		{"headerless.csv", "s3://bucket/a,2,4,out/a\ns3://bucket/b,0,,b\n", ""},
+		{"manifest.txt", "s3://bucket/a,2,4,out/a\ns3://bucket/b,0,,b\n", "csv"},
+	} {
+		entries, err := readManifest(writeManifest(t, dir, test.name, test.contents), test.format)
+		if err != nil {
+			t.Fatalf("Error calling readManifest for %s: %v", test.name, err)
+		}
+		if !reflect.DeepEqual(entries, expected) {
+			t.Fatalf("Expected %+v reading %s, got %+v", expected, test.name, entries)
+		}
+	}
+
+	for _, test := range []struct {
+		name     string
+		contents string
+		format   string
+	}{
+		{"invalid.json", `{"source": "s3://bucket/a"}`, ""},
+		{"no-output.json", `[{"source": "s3://bucket/a"}]`, ""},
+		{"columns.csv", "s3://bucket/a,0,out/a\n", ""},
+		{"offset.csv", "s3://bucket/a,zero,,out/a\n", ""},
+		{"length.csv", "s3://bucket/a,0,four,out/a\n", ""},
+		{"manifest.xml", "<manifest/>", "xml"},
+	} {
+		if _, err := readManifest(writeManifest(t, dir, test.name, test.contents), test.format); err == nil {
+			t.Fatalf("Expected an error reading %s", test.name)
+		}
+	}
+}
+
+// TestReadManifestRejectsEscapingOutputs tests that manifests whose outputs are absolute or climb out of the current
+// directory are rejected.
+func TestReadManifestRejectsEscapingOutputs(t *testing.T) {
+	dir := t.TempDir()
+	for _, output := range []string{"/etc/passwd", "../escape", "out/../../escape", "..", ".", "out/.."} {
+		manifest := writeManifest(t, dir, "manifest.csv", "s3://bucket/a,0,,"+output+"\n")
+		if _, err := readManifest(manifest, ""); err == nil {
+			t.Errorf("Expected an error reading a manifest with output %q", output)
+		}
+	}
+
+	for _, output := range []string{"out", "out/a", "./out/../a", "a..b"} {
+		manifest := writeManifest(t, dir, "manifest.csv", "s3://bucket/a,0,,"+output+"\n")
+		if _, err := readManifest(manifest, ""); err != nil {
+			t.Errorf("Error reading a manifest with output %q: %v", output, err)
+		}
+	}
+}
+
+// TestExtract tests that the extract subcommand copies the ranges a manifest selects to their outputs, reports each
+// as verified, and fails ranges outside their objects.
+func TestExtract(t *testing.T) {
+	server := newObjectServer(t, map[string]string{
+		"bucket/a": "0123456789",
+		"bucket/b": "abcdefghij",
+	})
+	dir := t.TempDir()
+	manifest := writeManifest(t, dir, "manifest.csv", "source,offset,length,output\n"+
+		"s3://bucket/a,2,4,out/a\n"+
+		"s3://bucket/b,5,,b\n")
+
+	cmd := seekS3Command("extract", "-endpoint", server.URL, "-path-style", "-no-sign-request", manifest)
+	cmd.Dir = dir
+	stdout, _, code := runCommand(t, cmd)
+	if code != 0 {
+		t.Fatalf("Expected exit code 0, got %d", code)
+	}
+
+	var report extractReport
+	if err := json.Unmarshal([]byte(stdout), &report); err != nil {
+		t.Fatalf("Error decoding report %q: %v", stdout, err)
+	}
+	if report.Succeeded != 2 || report.Failed != 0 || len(report.Results) != 2 ||
+		report.Results[0].Bytes != 4 || report.Results[1].Bytes != 5 {
+		t.Fatalf("Expected 2 verified ranges of 4 and 5 bytes, got %+v", report)
+	}
+	for output, expected := range map[string]string{"out/a": "2345", "b": "fghij"} {
+		b, err := ioutil.ReadFile(filepath.Join(dir, output))
+		if err != nil || string(b) != expected {
+			t.Fatalf("Expected %s to hold %q, got %q (%v)", output, expected, b, err)
+		}
+	}
+
+	manifest = writeManifest(t, dir, "outside.csv", "s3://bucket/a,8,4,outside\n")
+	cmd = seekS3Command("extract", "-endpoint", server.URL, "-path-style", "-no-sign-request", manifest)
+	cmd.Dir = dir
+	stdout, _, code = runCommand(t, cmd)
+	if code == 0 || !strings.Contains(stdout, `"failed": 1`) {
+		t.Fatalf("Expected a failed range and a non-zero exit code, got %q and %d", stdout, code)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "outside")); !os.IsNotExist(err) {
+		t.Fatalf("Expected no output for a range outside the object, got %v", err)
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// newObjectServer starts a minimal S3-compatible server holding objects, keyed by bucket and key, that answers
+// HeadBucket, HeadObject and ranged GetObject requests with path-style addressing.
+func newObjectServer(t *testing.T, objects map[string]string) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := strings.TrimPrefix(r.URL.Path, "/")
+		if r.Method == http.MethodHead && name != "" && !strings.Contains(name, "/") {
+			for key := range objects {
+				if strings.HasPrefix(key, name+"/") {
+					return
+				}
+			}
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		data, ok := objects[name]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("ETag", fmt.Sprintf(`"%x"`, data))
+		if r.Method == http.MethodHead {
+			w.Header().Set("Content-Length", fmt.Sprint(len(data)))
+			return
+		}
+
+		var first, last int
+		if _, err := fmt.Sscanf(r.Header.Get("Range"), "bytes=%d-%d", &first, &last); err != nil {
+			// Other requests, such as GetObjectAttributes, are not supported.
+			w.WriteHeader(http.StatusNotImplemented)
+			return
+		}
+		if last >= len(data) {
+			last = len(data) - 1
+		}
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", first, last, len(data)))
+		w.Header().Set("Content-Length", fmt.Sprint(last-first+1))
+		w.WriteHeader(http.StatusPartialContent)
+		_, _ = w.Write([]byte(data[first : last+1]))
+	}))
+	t.Cleanup(server.Close)
+
+	return server
+}
@@ -0,0 +1,19 @@
+module github.com/markandrus/s3readerat/cmd/seek-s3
+
+go 1.16
+
+require (
+	github.com/aws/aws-sdk-go-v2 v1.15.0
+	github.com/aws/aws-sdk-go-v2/config v1.15.0
+	github.com/aws/aws-sdk-go-v2/credentials v1.10.0
+	github.com/aws/aws-sdk-go-v2/service/s3 v1.26.0
+	github.com/aws/aws-sdk-go-v2/service/sts v1.16.0
+	github.com/aws/smithy-go v1.11.1
+	github.com/markandrus/s3readerat v0.0.0-00010101000000-000000000000
+	github.com/markandrus/s3readerat/sqlitevfs v0.0.0-00010101000000-000000000000
+	github.com/pkg/errors v0.9.1
+)
+
+replace github.com/markandrus/s3readerat => ../../
+
+replace github.com/markandrus/s3readerat/sqlitevfs => ../../sqlitevfs
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// logLevel is the severity of a log message. A message is written if its level is at most the logger's verbosity.
+type logLevel int
+
+const (
+	levelError logLevel = iota
+	levelInfo
+	levelDebug
+)
+
+func (l logLevel) String() string {
+	switch l {
+	case levelError:
+		return "error"
+	case levelInfo:
+		return "info"
+	default:
+		return "debug"
+	}
+}
+
+// cliLogger writes diagnostics to stderr, either as text or as one JSON object per line. Data is only ever written to
+// stdout, so the two can be separated.
+type cliLogger struct {
+	mu        sync.Mutex
+	out       io.Writer
+	verbosity logLevel
+	json      bool
+}
+
+var logger = &cliLogger{out: os.Stderr}
+
+func init() {
+	// Route the library's debug output, which uses the standard logger, through logger.
+	log.SetFlags(0)
+	log.SetOutput(stdLogWriter{})
+}
+
+func (l *cliLogger) enabled(level logLevel) bool {
+	return level <= l.verbosity
+}
+
+func (l *cliLogger) log(level logLevel, msg string) {
+	if !l.enabled(level) {
+		return
+	}
+
+	now := time.Now()
+
+	var line []byte
+	if l.json {
+		line, _ = json.Marshal(struct {
+			Time  time.Time `json:"time"`
+			Level string    `json:"level"`
+			Msg   string    `json:"msg"`
+		}{now, level.String(), msg})
+	} else {
+		line = []byte(now.Format("2006/01/02 15:04:05 ") + msg)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	_, _ = l.out.Write(append(line, '\n'))
+}
+
+// infof logs progress messages, which are shown with -v.
+func infof(format string, args ...interface{}) {
+	logger.log(levelInfo, fmt.Sprintf(format, args...))
+}
+
+// fatalf logs an error and exits.
+func fatalf(format string, args ...interface{}) {
+	logger.log(levelError, fmt.Sprintf(format, args...))
+	exit(1)
+}
+
+// stdLogWriter adapts the standard logger, which calls Write once per message, to logger at debug level.
+type stdLogWriter struct{}
+
+func (stdLogWriter) Write(p []byte) (int, error) {
+	logger.log(levelDebug, strings.TrimSuffix(string(p), "\n"))
+	return len(p), nil
+}
+
+// verbosityFlag is a boolean flag that raises logger's verbosity to the given level when set.
+type verbosityFlag logLevel
+
+func (f verbosityFlag) IsBoolFlag() bool { return true }
+
+func (f verbosityFlag) String() string { return "" }
+
+func (f verbosityFlag) Set(value string) error {
+	enabled, err := strconv.ParseBool(value)
+	if err != nil {
+		return err
+	}
+	if enabled && logger.verbosity < logLevel(f) {
+		logger.verbosity = logLevel(f)
+	}
+	return nil
+}
+
+// logFormatFlag sets logger's output format.
+type logFormatFlag struct{}
+
+func (logFormatFlag) String() string { return "" }
+
+func (logFormatFlag) Set(value string) error {
+	switch value {
+	case "text":
+		logger.json = false
+	case "json":
+		logger.json = true
+	default:
+		return errors.Errorf("unknown log format %q; expected text or json", value)
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestCLILogger tests that messages above the logger's verbosity are dropped, and that the rest are written as text or
+// JSON lines.
+func TestCLILogger(t *testing.T) {
+	var out bytes.Buffer
+	l := &cliLogger{out: &out, verbosity: levelInfo}
+	l.log(levelError, "an error")
+	l.log(levelInfo, "some progress")
+	l.log(levelDebug, "a detail")
+
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	if len(lines) != 2 || !strings.HasSuffix(lines[0], " an error") || !strings.HasSuffix(lines[1], " some progress") {
+		t.Fatalf("Expected the error and info messages as text, got %q", out.String())
+	}
+	if _, err := time.Parse("2006/01/02 15:04:05", strings.TrimSuffix(lines[0], " an error")); err != nil {
+		t.Fatalf("Expected a timestamp before the message, got %q", lines[0])
+	}
+
+	out.Reset()
+	l = &cliLogger{out: &out, verbosity: levelDebug, json: true}
+	l.log(levelDebug, "a \"quoted\" detail")
+
+	var entry struct {
+		Time  time.Time `json:"time"`
+		Level string    `json:"level"`
+		Msg   string    `json:"msg"`
+	}
+	if err := json.Unmarshal(out.Bytes(), &entry); err != nil {
+		t.Fatalf("Error decoding log line %q: %v", out.String(), err)
+	}
+	if entry.Level != "debug" || entry.Msg != "a \"quoted\" detail" || entry.Time.IsZero() {
+		t.Fatalf("Expected a timestamped debug message, got %+v", entry)
+	}
+}
+
+// TestVerbosity tests that -v logs progress, -vv also logs each S3 request, -log-format json logs JSON lines, and that
+// nothing but errors is logged by default.
+func TestVerbosity(t *testing.T) {
+	server := newObjectServer(t, map[string]string{"bucket/a": "0123456789"})
+	flags := []string{"cat", "-endpoint", server.URL, "-path-style", "-no-sign-request"}
+
+	for _, test := range []struct {
+		args     []string
+		expected []string
+		missing  []string
+	}{
+		{nil, nil, []string{"Copied", "GetObject"}},
+		{[]string{"-v"}, []string{"Copied 10 bytes"}, []string{"GetObject"}},
+		{[]string{"-vv"}, []string{"Copied 10 bytes", "GetObject"}, nil},
+		{[]string{"-v=false", "-vv=false"}, nil, []string{"Copied", "GetObject"}},
+		{[]string{"-vv", "-v"}, []string{"Copied 10 bytes", "GetObject"}, nil},
+		{[]string{"-v", "-log-format", "json"}, []string{`"level":"info","msg":"Copied 10 bytes"`}, nil},
+	} {
+		stdout, stderr, code := runCommand(t, seekS3Command(append(append(flags, test.args...), "s3://bucket/a")...))
+		if code != 0 || stdout != "0123456789" {
+			t.Fatalf("Expected the object and exit code 0 running %v, got %q and %d", test.args, stdout, code)
+		}
+		for _, expected := range test.expected {
+			if !strings.Contains(stderr, expected) {
+				t.Fatalf("Expected %q logged running %v, got %q", expected, test.args, stderr)
+			}
+		}
+		for _, missing := range test.missing {
+			if strings.Contains(stderr, missing) {
+				t.Fatalf("Expected %q not logged running %v, got %q", missing, test.args, stderr)
+			}
+		}
+	}
+
+	_, stderr, code := runCommand(t, seekS3Command(append(flags, "-log-format", "json", "s3://bucket/missing")...))
+	var entry struct {
+		Level string `json:"level"`
+		Msg   string `json:"msg"`
+	}
+	if err := json.Unmarshal([]byte(stderr), &entry); err != nil || code != 1 || entry.Level != "error" {
+		t.Fatalf("Expected an error logged as JSON and exit code 1, got %q and %d", stderr, code)
+	}
+
+	if _, code := runSeekS3(t, append(flags, "-log-format", "xml", "s3://bucket/a")...); code != 2 {
+		t.Fatalf("Expected exit code 2 for an unknown log format, got %d", code)
+	}
+}
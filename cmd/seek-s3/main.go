@@ -4,76 +4,81 @@ import (
 	"context"
 	"flag"
 	"io"
-	"log"
-	"net/url"
 	"os"
-	"strings"
+	"time"
 
-	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
 	s3readerat "github.com/markandrus/s3readerat"
 )
 
-var debug = flag.Bool("debug", false, "enable verbose output")
+var common = newCommonFlags(flag.CommandLine)
 var offset = flag.Int64("offset", -8, "offset parameter to seek")
 var whence = flag.Int("whence", 2, "whence parameter to seek (0 is start, 1 is current and 2 is end)")
 var limit = flag.Int64("limit", -1, "limit the bytes to print (-1 is unlimited)")
 
+// subcommands maps the name of each subcommand to its entry point, which receives the arguments following the name.
+// Without a subcommand, seek-s3 seeks within and prints an S3 object.
+var subcommands = map[string]func(args []string){
+	"cache":        cacheCommand,
+	"cat":          cat,
+	"diff":         diff,
+	"doctor":       doctor,
+	"extract":      extract,
+	"list-archive": listArchive,
+	"parquet-meta": parquetMeta,
+	"serve":        serve,
+	"tar":          tarCommand,
+	"warm":         warm,
+}
+
+// exitHooks run before seek-s3 exits, whether or not it succeeded.
+var exitHooks []func()
+
+// exit runs exitHooks and exits with code.
+func exit(code int) {
+	for _, hook := range exitHooks {
+		hook()
+	}
+	os.Exit(code)
+}
+
 func main() {
-	flag.Parse()
-	if flag.NArg() == 0 {
-		log.Fatal("Expected an S3 URL")
+	if len(os.Args) > 1 {
+		if subcommand, ok := subcommands[os.Args[1]]; ok {
+			subcommand(os.Args[2:])
+			exit(0)
+		}
 	}
 
-	parsed, err := url.Parse(flag.Arg(0))
-	if err != nil {
-		log.Fatalf("Failed to parse S3 URL: %v", err)
+	flag.Parse()
+	if flag.NArg() == 0 {
+		fatalf("Expected an S3 URL")
 	}
 
 	if *whence < 0 || *whence > 2 {
-		log.Fatal("Whence parameter must be 0, 1 or 2")
+		fatalf("Whence parameter must be 0, 1 or 2")
 	}
 
 	if *limit < -1 || *limit == 0 {
-		log.Fatal("Limit parameter must be -1 or positive")
+		fatalf("Limit parameter must be -1 or positive")
 	}
 
-	bucket := parsed.Host
-	key := strings.TrimPrefix(parsed.Path, "/")
+	reader := common.open(flag.Arg(0))
 
-	cfg, err := config.LoadDefaultConfig(context.TODO())
+	size, err := reader.Size()
 	if err != nil {
-		log.Fatalf("Unable to load AWS config: %v", err)
-	}
-
-	opts := s3.Options{
-		Region:        cfg.Region,
-		HTTPClient:    cfg.HTTPClient,
-		Credentials:   cfg.Credentials,
-		APIOptions:    cfg.APIOptions,
-		Logger:        cfg.Logger,
-		ClientLogMode: cfg.ClientLogMode,
+		fatalf("Unable to get size of S3 object: %v", err)
 	}
+	infof("Object is %d bytes", size)
 
-	reader, err := s3readerat.NewWithOptions(s3readerat.Options{
-		Options: &opts,
-		Bucket:  bucket,
-		Key:     key,
-	})
+	sectionReader, err := reader.Reader()
 	if err != nil {
-		log.Fatalf("Unable to create ReaderAt instance: %v", err)
+		fatalf("Unable to open S3 object: %v", err)
 	}
-	reader.Debug = *debug
-
-	size, err := reader.Size()
-	if err != nil {
-		log.Fatalf("Unable to get size of S3 object: %v", err)
-	}
-
-	sectionReader := io.NewSectionReader(reader, 0, size)
 	_, err = sectionReader.Seek(*offset, *whence)
 	if err != nil {
-		log.Fatalf("Unable to seek S3 object: %v", err)
+		fatalf("Unable to seek S3 object: %v", err)
 	}
 
 	if *limit == -1 {
@@ -83,6 +88,200 @@ func main() {
 	}
 
 	if err != nil && err != io.EOF {
-		log.Fatalf("Failed to read S3 object: %v", err)
+		fatalf("Failed to read S3 object: %v", err)
+	}
+
+	exit(0)
+}
+
+// commonFlags are the flags accepted by seek-s3 and all of its subcommands, along with the state shared by the
+// S3ReaderAts they open.
+type commonFlags struct {
+	encodedKeys     bool
+	requestLog      string
+	statsJSON       string
+	assumeRole      string
+	externalID      string
+	mfaSerial       string
+	roleSessionName string
+	auditLogPath    string
+	requesterPays   bool
+	expectedOwner   string
+	accelerate      bool
+	dualstack       bool
+	fips            bool
+	endpoint        string
+	pathStyle       bool
+	anonymous       bool
+	hedgeAfter      time.Duration
+
+	opts             *s3.Options
+	requestLogWriter io.Writer
+	auditLog         *s3readerat.AuditLog
+	readers          []*s3readerat.S3ReaderAt
+}
+
+// newCommonFlags registers the common flags with flags.
+func newCommonFlags(flags *flag.FlagSet) *commonFlags {
+	c := &commonFlags{}
+	flags.Var(verbosityFlag(levelInfo), "v", "log progress to stderr")
+	flags.Var(verbosityFlag(levelDebug), "vv", "log progress and debug output, including each S3 request, to stderr")
+	flags.Var(logFormatFlag{}, "log-format", "`format` of log output: text (the default) or json")
+	flags.BoolVar(&c.encodedKeys, "encoded-keys", false,
+		"treat keys in S3 URLs as percent-encoded rather than literal")
+	flags.StringVar(&c.requestLog, "request-log", "",
+		"write one JSON object per S3 request to `file` (- is stderr)")
+	flags.StringVar(&c.statsJSON, "stats-json", "",
+		"write a JSON summary of S3 requests to `file` (- is stderr) on exit")
+	flags.StringVar(&c.auditLogPath, "audit-log", "",
+		"append a tamper-evident record of every read to `file`, continuing its hash chain")
+	flags.StringVar(&c.assumeRole, "assume-role", "", "read with the credentials of the IAM role with `ARN`")
+	flags.StringVar(&c.externalID, "external-id", "", "external `ID` to pass when assuming -assume-role")
+	flags.StringVar(&c.mfaSerial, "mfa-serial", "",
+		"serial number or ARN of the MFA `device` -assume-role requires; its code is prompted for")
+	flags.StringVar(&c.roleSessionName, "role-session-name", "seek-s3", "session `name` to assume -assume-role with")
+	flags.BoolVar(&c.requesterPays, "requester-pays", false,
+		"acknowledge that you pay for requests, as requester-pays buckets require")
+	flags.StringVar(&c.expectedOwner, "expected-bucket-owner", "",
+		"fail unless the bucket is owned by the AWS account with `ID`")
+	flags.BoolVar(&c.accelerate, "accelerate", false,
+		"read through the S3 Transfer Acceleration endpoint, which must be enabled on the bucket")
+	flags.BoolVar(&c.dualstack, "dualstack", false, "read through S3's dual-stack IPv4 and IPv6 endpoints")
+	flags.BoolVar(&c.fips, "fips", false, "read through S3's FIPS endpoints")
+	flags.StringVar(&c.endpoint, "endpoint", "", "read from the S3-compatible service at `URL`, such as MinIO")
+	flags.BoolVar(&c.pathStyle, "path-style", false, "name the bucket in the path of each request, not its host")
+	flags.BoolVar(&c.anonymous, "no-sign-request", false,
+		"make requests unsigned, to read public buckets without AWS credentials")
+	flags.DurationVar(&c.hedgeAfter, "hedge-after", 0,
+		"issue a range request again if it has not responded within `duration`, reading whichever responds first")
+	return c
+}
+
+// setup loads the AWS config and opens the request and audit logs the first time it is called, exiting on failure.
+func (c *commonFlags) setup() {
+	if c.opts != nil {
+		return
+	}
+
+	cfg, err := c.loadConfig(context.TODO())
+	if err != nil {
+		fatalf("Unable to load AWS config: %v", err)
+	}
+
+	c.opts = s3readerat.OptionsFromConfig(cfg)
+
+	switch c.requestLog {
+	case "":
+	case "-":
+		c.requestLogWriter = os.Stderr
+	default:
+		// The file is left open until the process exits, since requests may be logged until then.
+		f, err := os.Create(c.requestLog)
+		if err != nil {
+			fatalf("Unable to create request log: %v", err)
+		}
+		c.requestLogWriter = f
+	}
+
+	if c.auditLogPath != "" {
+		if c.auditLog, err = s3readerat.OpenAuditLog(c.auditLogPath); err != nil {
+			fatalf("Unable to open audit log: %v", err)
+		}
+		exitHooks = append(exitHooks, func() { _ = c.auditLog.Close() })
+	}
+
+	if c.statsJSON != "" {
+		start := time.Now()
+		exitHooks = append(exitHooks, func() {
+			var stats s3readerat.Stats
+			var latencies s3readerat.Latencies
+			for _, reader := range c.readers {
+				stats = stats.Add(reader.Stats())
+				latencies = latencies.Add(reader.Latencies())
+			}
+			writeStats(c.statsJSON, stats, latencies, time.Since(start))
+		})
+	}
+}
+
+// open parses an S3 URL and returns a multi-region S3ReaderAt for it, exiting on failure.
+func (c *commonFlags) open(rawURL string) *s3readerat.S3ReaderAt {
+	return c.openObject(*c.parseURL(rawURL))
+}
+
+// openObject returns a multi-region S3ReaderAt for the object u names, exiting on failure.
+func (c *commonFlags) openObject(u s3readerat.S3URL) *s3readerat.S3ReaderAt {
+	reader, err := c.newReader(u)
+	if err != nil {
+		fatalf("Unable to create ReaderAt instance: %v", err)
+	}
+	c.readers = append(c.readers, reader)
+
+	region := c.opts.Region
+	if u.Region != "" {
+		region = u.Region
+	}
+	infof("Opened %s in region %s", u.String(), region)
+
+	return reader
+}
+
+// parseURL parses an S3 URL according to -encoded-keys, exiting on failure.
+func (c *commonFlags) parseURL(rawURL string) *s3readerat.S3URL {
+	encoding := s3readerat.KeyRaw
+	if c.encodedKeys {
+		encoding = s3readerat.KeyEncoded
 	}
+
+	parsed, err := s3readerat.ParseURL(rawURL, encoding)
+	if err != nil {
+		fatalf("Failed to parse S3 URL: %v", err)
+	}
+
+	return parsed
+}
+
+// newReader returns a multi-region S3ReaderAt for the object u names. Unlike open, it does not exit on failure, and the
+// reader is not included in -stats-json.
+func (c *commonFlags) newReader(u s3readerat.S3URL) (*s3readerat.S3ReaderAt, error) {
+	c.setup()
+
+	reader, err := s3readerat.NewWithOptions(c.readerOptions(u))
+	if err != nil {
+		return nil, err
+	}
+	reader.Debug = logger.enabled(levelDebug)
+
+	return reader, nil
+}
+
+// readerOptions returns the Options to open the object u names with, as set by the common flags and by the version and
+// region u names, if any. setup must have been called.
+func (c *commonFlags) readerOptions(u s3readerat.S3URL) s3readerat.Options {
+	options := s3readerat.Options{
+		Options:             c.opts,
+		Bucket:              u.Bucket,
+		Key:                 u.Key,
+		VersionID:           u.VersionID,
+		RequestLog:          c.requestLogWriter,
+		AuditLog:            c.auditLog,
+		ExpectedBucketOwner: c.expectedOwner,
+		UseAccelerate:       c.accelerate,
+		UseDualstack:        c.dualstack,
+		UseFIPS:             c.fips,
+		Endpoint:            c.endpoint,
+		UsePathStyle:        c.pathStyle,
+		Anonymous:           c.anonymous,
+		HedgeDelay:          c.hedgeAfter,
+	}
+	if c.requesterPays {
+		options.RequestPayer = types.RequestPayerRequester
+	}
+	if u.Region != "" {
+		opts := c.opts.Copy()
+		opts.Region = u.Region
+		options.Options = &opts
+	}
+
+	return options
 }
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"testing"
+)
+
+// TestHelperProcess runs seek-s3 with the arguments following "--" when invoked by runSeekS3.
+func TestHelperProcess(t *testing.T) {
+	if os.Getenv("SEEK_S3_HELPER_PROCESS") != "1" {
+		return
+	}
+
+	args := os.Args
+	for len(args) > 0 && args[0] != "--" {
+		args = args[1:]
+	}
+	os.Args = append([]string{"seek-s3"}, args[1:]...)
+	main()
+	exit(0)
+}
+
+// runSeekS3 runs seek-s3 with args in a separate process, returning its standard output and exit code.
+func runSeekS3(t *testing.T, args ...string) (string, int) {
+	t.Helper()
+
+	stdout, _, code := runCommand(t, seekS3Command(args...))
+	return stdout, code
+}
+
+// seekS3Command returns a command that runs seek-s3 with args, for tests that set its directory or standard input.
+// It does not read the AWS configuration of the user running the tests.
+func seekS3Command(args ...string) *exec.Cmd {
+	cmd := exec.Command(os.Args[0], append([]string{"-test.run=^TestHelperProcess$", "--"}, args...)...)
+	cmd.Env = append(os.Environ(), "SEEK_S3_HELPER_PROCESS=1", "AWS_REGION=us-east-1",
+		"AWS_CONFIG_FILE="+os.DevNull, "AWS_SHARED_CREDENTIALS_FILE="+os.DevNull)
+	return cmd
+}
+
+// runCommand runs cmd, returning its standard output and error and its exit code.
+func runCommand(t *testing.T, cmd *exec.Cmd) (string, string, int) {
+	t.Helper()
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+
+	err := cmd.Run()
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		return stdout.String(), stderr.String(), exitErr.ExitCode()
+	} else if err != nil {
+		t.Fatalf("Error running seek-s3: %v", err)
+	}
+	return stdout.String(), stderr.String(), 0
+}
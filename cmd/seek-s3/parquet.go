@@ -0,0 +1,439 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	parquetMagic       = "PAR1"
+	parquetFooterLen   = 8
+	parquetTailReadLen = 64 << 10
+)
+
+var (
+	parquetTypes = []string{"BOOLEAN", "INT32", "INT64", "INT96", "FLOAT", "DOUBLE", "BYTE_ARRAY",
+		"FIXED_LEN_BYTE_ARRAY"}
+	parquetRepetitionTypes = []string{"REQUIRED", "OPTIONAL", "REPEATED"}
+	parquetConvertedTypes  = []string{"UTF8", "MAP", "MAP_KEY_VALUE", "LIST", "ENUM", "DECIMAL", "DATE", "TIME_MILLIS",
+		"TIME_MICROS", "TIMESTAMP_MILLIS", "TIMESTAMP_MICROS", "UINT_8", "UINT_16", "UINT_32", "UINT_64", "INT_8",
+		"INT_16", "INT_32", "INT_64", "JSON", "BSON", "INTERVAL"}
+	parquetCodecs    = []string{"UNCOMPRESSED", "SNAPPY", "GZIP", "LZO", "BROTLI", "LZ4", "ZSTD", "LZ4_RAW"}
+	parquetEncodings = []string{"PLAIN", "GROUP_VAR_INT", "PLAIN_DICTIONARY", "RLE", "BIT_PACKED",
+		"DELTA_BINARY_PACKED", "DELTA_LENGTH_BYTE_ARRAY", "DELTA_BYTE_ARRAY", "RLE_DICTIONARY", "BYTE_STREAM_SPLIT"}
+)
+
+// parquetMetadata is the JSON representation of a Parquet file's FileMetaData.
+type parquetMetadata struct {
+	Version          int32                  `json:"version"`
+	NumRows          int64                  `json:"num_rows"`
+	CreatedBy        string                 `json:"created_by,omitempty"`
+	KeyValueMetadata map[string]string      `json:"key_value_metadata,omitempty"`
+	Schema           []parquetSchemaElement `json:"schema"`
+	RowGroups        []parquetRowGroup      `json:"row_groups"`
+}
+
+type parquetSchemaElement struct {
+	Name           string `json:"name"`
+	Type           string `json:"type,omitempty"`
+	TypeLength     int32  `json:"type_length,omitempty"`
+	RepetitionType string `json:"repetition_type,omitempty"`
+	NumChildren    int32  `json:"num_children,omitempty"`
+	ConvertedType  string `json:"converted_type,omitempty"`
+	Scale          int32  `json:"scale,omitempty"`
+	Precision      int32  `json:"precision,omitempty"`
+}
+
+type parquetRowGroup struct {
+	NumRows             int64                `json:"num_rows"`
+	TotalByteSize       int64                `json:"total_byte_size"`
+	TotalCompressedSize int64                `json:"total_compressed_size,omitempty"`
+	FileOffset          int64                `json:"file_offset,omitempty"`
+	Columns             []parquetColumnChunk `json:"columns"`
+}
+
+type parquetColumnChunk struct {
+	Path                  string             `json:"path"`
+	FilePath              string             `json:"file_path,omitempty"`
+	Type                  string             `json:"type"`
+	Codec                 string             `json:"codec"`
+	Encodings             []string           `json:"encodings"`
+	NumValues             int64              `json:"num_values"`
+	TotalUncompressedSize int64              `json:"total_uncompressed_size"`
+	TotalCompressedSize   int64              `json:"total_compressed_size"`
+	DataPageOffset        int64              `json:"data_page_offset"`
+	DictionaryPageOffset  *int64             `json:"dictionary_page_offset,omitempty"`
+	Statistics            *parquetStatistics `json:"statistics,omitempty"`
+
+	physicalType int32
+}
+
+type parquetStatistics struct {
+	NullCount     *int64      `json:"null_count,omitempty"`
+	DistinctCount *int64      `json:"distinct_count,omitempty"`
+	Min           interface{} `json:"min,omitempty"`
+	Max           interface{} `json:"max,omitempty"`
+
+	min, max []byte
+}
+
+// parquetMeta implements the parquet-meta subcommand, which prints a Parquet file's footer metadata as JSON.
+func parquetMeta(args []string) {
+	flags := flag.NewFlagSet("parquet-meta", flag.ExitOnError)
+	common := newCommonFlags(flags)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s parquet-meta [flags] s3://bucket/file.parquet\n\n", os.Args[0])
+		fmt.Fprintln(flags.Output(), "Prints the schema, row group layout and column statistics of a Parquet file as")
+		fmt.Fprintln(flags.Output(), "JSON, reading only its footer.")
+		fmt.Fprintln(flags.Output())
+		flags.PrintDefaults()
+	}
+	_ = flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	reader := common.open(flags.Arg(0))
+
+	size, err := reader.Size()
+	if err != nil {
+		fatalf("Unable to get size of S3 object: %v", err)
+	}
+
+	metadata, err := readParquetMetadata(reader, size)
+	if err != nil {
+		fatalf("Unable to read Parquet metadata: %v", err)
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err = encoder.Encode(metadata); err != nil {
+		fatalf("Unable to encode Parquet metadata: %v", err)
+	}
+}
+
+// readParquetMetadata reads and decodes the FileMetaData at the end of a Parquet file. Small footers are fetched with a
+// single range request.
+func readParquetMetadata(r io.ReaderAt, size int64) (*parquetMetadata, error) {
+	if size < int64(len(parquetMagic))+parquetFooterLen {
+		return nil, errors.New("object is too small to be a Parquet file")
+	}
+
+	tailLen := int64(parquetTailReadLen)
+	if tailLen > size {
+		tailLen = size
+	}
+
+	tail := make([]byte, tailLen)
+	if _, err := r.ReadAt(tail, size-tailLen); err != nil && err != io.EOF {
+		return nil, errors.Wrap(err, "reading footer")
+	}
+
+	footer := tail[len(tail)-parquetFooterLen:]
+	if string(footer[4:]) != parquetMagic {
+		return nil, errors.New("missing Parquet magic number; is this a Parquet file?")
+	}
+
+	metadataLen := int64(binary.LittleEndian.Uint32(footer))
+	if metadataLen > size-parquetFooterLen-int64(len(parquetMagic)) {
+		return nil, errors.Errorf("footer length %d is out of bounds", metadataLen)
+	}
+
+	var raw []byte
+	if metadataLen <= tailLen-parquetFooterLen {
+		raw = tail[tailLen-parquetFooterLen-metadataLen : tailLen-parquetFooterLen]
+	} else {
+		raw = make([]byte, metadataLen)
+		if _, err := r.ReadAt(raw, size-parquetFooterLen-metadataLen); err != nil && err != io.EOF {
+			return nil, errors.Wrap(err, "reading footer")
+		}
+	}
+
+	metadata := &parquetMetadata{}
+	tr := &thriftReader{b: raw}
+	err := tr.readStruct(func(id int16, typ byte) error {
+		var err error
+		switch id {
+		case 1:
+			metadata.Version, err = tr.readI32()
+		case 2:
+			err = tr.readList(func(byte) error {
+				element, err := readParquetSchemaElement(tr)
+				metadata.Schema = append(metadata.Schema, element)
+				return err
+			})
+		case 3:
+			metadata.NumRows, err = tr.readVarint()
+		case 4:
+			err = tr.readList(func(byte) error {
+				rowGroup, err := readParquetRowGroup(tr)
+				metadata.RowGroups = append(metadata.RowGroups, rowGroup)
+				return err
+			})
+		case 5:
+			metadata.KeyValueMetadata = make(map[string]string)
+			err = tr.readList(func(byte) error {
+				return readParquetKeyValue(tr, metadata.KeyValueMetadata)
+			})
+		case 6:
+			metadata.CreatedBy, err = tr.readString()
+		default:
+			err = tr.skip(typ)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "decoding FileMetaData")
+	}
+
+	return metadata, nil
+}
+
+func readParquetSchemaElement(tr *thriftReader) (parquetSchemaElement, error) {
+	var element parquetSchemaElement
+	err := tr.readStruct(func(id int16, typ byte) error {
+		var err error
+		var v int32
+		switch id {
+		case 1:
+			v, err = tr.readI32()
+			element.Type = parquetEnum(parquetTypes, v)
+		case 2:
+			element.TypeLength, err = tr.readI32()
+		case 3:
+			v, err = tr.readI32()
+			element.RepetitionType = parquetEnum(parquetRepetitionTypes, v)
+		case 4:
+			element.Name, err = tr.readString()
+		case 5:
+			element.NumChildren, err = tr.readI32()
+		case 6:
+			v, err = tr.readI32()
+			element.ConvertedType = parquetEnum(parquetConvertedTypes, v)
+		case 7:
+			element.Scale, err = tr.readI32()
+		case 8:
+			element.Precision, err = tr.readI32()
+		default:
+			err = tr.skip(typ)
+		}
+		return err
+	})
+	return element, err
+}
+
+func readParquetRowGroup(tr *thriftReader) (parquetRowGroup, error) {
+	var rowGroup parquetRowGroup
+	err := tr.readStruct(func(id int16, typ byte) error {
+		var err error
+		switch id {
+		case 1:
+			err = tr.readList(func(byte) error {
+				column, err := readParquetColumnChunk(tr)
+				rowGroup.Columns = append(rowGroup.Columns, column)
+				return err
+			})
+		case 2:
+			rowGroup.TotalByteSize, err = tr.readVarint()
+		case 3:
+			rowGroup.NumRows, err = tr.readVarint()
+		case 5:
+			rowGroup.FileOffset, err = tr.readVarint()
+		case 6:
+			rowGroup.TotalCompressedSize, err = tr.readVarint()
+		default:
+			err = tr.skip(typ)
+		}
+		return err
+	})
+	return rowGroup, err
+}
+
+func readParquetColumnChunk(tr *thriftReader) (parquetColumnChunk, error) {
+	var column parquetColumnChunk
+	err := tr.readStruct(func(id int16, typ byte) error {
+		var err error
+		switch id {
+		case 1:
+			column.FilePath, err = tr.readString()
+		case 3:
+			err = readParquetColumnMetaData(tr, &column)
+		default:
+			err = tr.skip(typ)
+		}
+		return err
+	})
+	if err != nil {
+		return column, err
+	}
+
+	if column.Statistics != nil {
+		column.Statistics.Min = parquetStatisticsValue(column.physicalType, column.Statistics.min)
+		column.Statistics.Max = parquetStatisticsValue(column.physicalType, column.Statistics.max)
+	}
+
+	return column, nil
+}
+
+func readParquetColumnMetaData(tr *thriftReader, column *parquetColumnChunk) error {
+	return tr.readStruct(func(id int16, typ byte) error {
+		var err error
+		var v int32
+		switch id {
+		case 1:
+			column.physicalType, err = tr.readI32()
+			column.Type = parquetEnum(parquetTypes, column.physicalType)
+		case 2:
+			err = tr.readList(func(byte) error {
+				v, err := tr.readI32()
+				column.Encodings = append(column.Encodings, parquetEnum(parquetEncodings, v))
+				return err
+			})
+		case 3:
+			var path []string
+			err = tr.readList(func(byte) error {
+				element, err := tr.readString()
+				path = append(path, element)
+				return err
+			})
+			column.Path = strings.Join(path, ".")
+		case 4:
+			v, err = tr.readI32()
+			column.Codec = parquetEnum(parquetCodecs, v)
+		case 5:
+			column.NumValues, err = tr.readVarint()
+		case 6:
+			column.TotalUncompressedSize, err = tr.readVarint()
+		case 7:
+			column.TotalCompressedSize, err = tr.readVarint()
+		case 9:
+			column.DataPageOffset, err = tr.readVarint()
+		case 11:
+			var offset int64
+			offset, err = tr.readVarint()
+			column.DictionaryPageOffset = &offset
+		case 12:
+			column.Statistics, err = readParquetStatistics(tr)
+		default:
+			err = tr.skip(typ)
+		}
+		return err
+	})
+}
+
+func readParquetStatistics(tr *thriftReader) (*parquetStatistics, error) {
+	statistics := &parquetStatistics{}
+	err := tr.readStruct(func(id int16, typ byte) error {
+		var err error
+		var v []byte
+		switch id {
+		case 1, 5:
+			// Prefer max_value (5) over the deprecated max (1), whichever comes last.
+			if v, err = tr.readBinary(); id == 5 || statistics.max == nil {
+				statistics.max = v
+			}
+		case 2, 6:
+			if v, err = tr.readBinary(); id == 6 || statistics.min == nil {
+				statistics.min = v
+			}
+		case 3:
+			var count int64
+			count, err = tr.readVarint()
+			statistics.NullCount = &count
+		case 4:
+			var count int64
+			count, err = tr.readVarint()
+			statistics.DistinctCount = &count
+		default:
+			err = tr.skip(typ)
+		}
+		return err
+	})
+	return statistics, err
+}
+
+func readParquetKeyValue(tr *thriftReader, m map[string]string) error {
+	var key, value string
+	err := tr.readStruct(func(id int16, typ byte) error {
+		var err error
+		switch id {
+		case 1:
+			key, err = tr.readString()
+		case 2:
+			value, err = tr.readString()
+		default:
+			err = tr.skip(typ)
+		}
+		return err
+	})
+	m[key] = value
+	return err
+}
+
+// parquetEnum returns the name of an enum value, or its number if unknown.
+func parquetEnum(names []string, v int32) string {
+	if v >= 0 && int(v) < len(names) {
+		return names[v]
+	}
+	return fmt.Sprint(v)
+}
+
+// parquetStatisticsValue decodes a plain-encoded statistics value for display. Byte arrays are shown as strings when
+// they are valid UTF-8 and as base64 otherwise; other opaque values are shown in hex.
+func parquetStatisticsValue(physicalType int32, b []byte) interface{} {
+	if b == nil {
+		return nil
+	}
+
+	switch parquetEnum(parquetTypes, physicalType) {
+	case "BOOLEAN":
+		if len(b) == 1 {
+			return b[0] != 0
+		}
+	case "INT32":
+		if len(b) == 4 {
+			return int32(binary.LittleEndian.Uint32(b))
+		}
+	case "INT64":
+		if len(b) == 8 {
+			return int64(binary.LittleEndian.Uint64(b))
+		}
+	case "FLOAT":
+		if len(b) == 4 {
+			return finiteOrString(float64(math.Float32frombits(binary.LittleEndian.Uint32(b))))
+		}
+	case "DOUBLE":
+		if len(b) == 8 {
+			return finiteOrString(math.Float64frombits(binary.LittleEndian.Uint64(b)))
+		}
+	case "BYTE_ARRAY":
+		if utf8.Valid(b) && !bytes.ContainsRune(b, 0) {
+			return string(b)
+		}
+		return base64.StdEncoding.EncodeToString(b)
+	}
+
+	return hex.EncodeToString(b)
+}
+
+// finiteOrString returns f, or its string form if it cannot be represented in JSON.
+func finiteOrString(f float64) interface{} {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return fmt.Sprint(f)
+	}
+	return f
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// parquetFile returns a Parquet file whose footer holds metadata, after a stand-in for its column chunks.
+func parquetFile(metadata []byte) []byte {
+	var b bytes.Buffer
+	b.WriteString(parquetMagic)
+	b.Write(make([]byte, 100))
+	b.Write(metadata)
+	_ = binary.Write(&b, binary.LittleEndian, uint32(len(metadata)))
+	b.WriteString(parquetMagic)
+	return b.Bytes()
+}
+
+// parquetFooter encodes the FileMetaData of a file with an int64 column and a UTF-8 string column in one row group,
+// as parquet-cpp writes it, including fields parquet-meta does not show. createdBy is padded to make large footers.
+func parquetFooter(createdBy string) []byte {
+	w := &thriftWriter{}
+	w.structValue(func() {
+		w.i32(1, 2)
+		w.list(2, thriftStruct, 3, func() {
+			w.structValue(func() {
+				w.binary(4, []byte("schema"))
+				w.i32(5, 2)
+			})
+			w.structValue(func() {
+				w.i32(1, 2)
+				w.i32(3, 0)
+				w.binary(4, []byte("id"))
+			})
+			w.structValue(func() {
+				w.i32(1, 6)
+				w.i32(3, 1)
+				w.binary(4, []byte("name"))
+				w.i32(6, 0)
+				// LogicalType, which parquet-meta skips.
+				w.structField(10, func() {
+					w.structField(1, func() {})
+				})
+			})
+		})
+		w.i64(3, 3)
+		w.list(4, thriftStruct, 1, func() {
+			w.structValue(func() {
+				w.list(1, thriftStruct, 2, func() {
+					for i, column := range []struct {
+						name          string
+						typ           int32
+						min, max      []byte
+						dictionary    bool
+						dataPageStart int64
+					}{
+						{"id", 2, []byte{1, 0, 0, 0, 0, 0, 0, 0}, []byte{3, 0, 0, 0, 0, 0, 0, 0}, false, 4},
+						{"name", 6, []byte("ada"), []byte("grace"), true, 60},
+					} {
+						column := column
+						w.structValue(func() {
+							w.i64(2, column.dataPageStart)
+							w.structField(3, func() {
+								w.i32(1, column.typ)
+								w.list(2, thriftI32, 2, func() {
+									w.varint(0)
+									w.varint(3)
+								})
+								w.list(3, thriftBinary, 1, func() {
+									w.binaryValue([]byte(column.name))
+								})
+								w.i32(4, 1)
+								w.i64(5, 3)
+								w.i64(6, 50)
+								w.i64(7, 40)
+								w.i64(9, column.dataPageStart)
+								if column.dictionary {
+									w.i64(11, column.dataPageStart-20)
+								}
+								w.structField(12, func() {
+									w.i64(3, int64(i))
+									w.binary(5, column.max)
+									w.binary(6, column.min)
+								})
+							})
+						})
+					}
+				})
+				w.i64(2, 100)
+				w.i64(3, 3)
+				w.i64(5, 4)
+				w.i64(6, 80)
+				w.field(7, thriftI16)
+				w.varint(0)
+			})
+		})
+		w.list(5, thriftStruct, 1, func() {
+			w.structValue(func() {
+				w.binary(1, []byte("ARROW:schema"))
+				w.binary(2, []byte("/////w=="))
+			})
+		})
+		w.binary(6, []byte(createdBy))
+		// ColumnOrders, which parquet-meta skips.
+		w.list(7, thriftStruct, 2, func() {
+			for i := 0; i < 2; i++ {
+				w.structValue(func() {
+					w.structField(1, func() {})
+				})
+			}
+		})
+	})
+	return w.Bytes()
+}
+
+// TestReadParquetMetadata tests that the footers of Parquet files are decoded, including footers too large to be read
+// with the tail of the file.
+func TestReadParquetMetadata(t *testing.T) {
+	nullCounts := []int64{0, 1}
+	dictionaryOffset := int64(40)
+	expected := func(createdBy string) *parquetMetadata {
+		return &parquetMetadata{
+			Version:          2,
+			NumRows:          3,
+			CreatedBy:        createdBy,
+			KeyValueMetadata: map[string]string{"ARROW:schema": "/////w=="},
+			Schema: []parquetSchemaElement{
+				{Name: "schema", NumChildren: 2},
+				{Name: "id", Type: "INT64", RepetitionType: "REQUIRED"},
+				{Name: "name", Type: "BYTE_ARRAY", RepetitionType: "OPTIONAL", ConvertedType: "UTF8"},
+			},
+			RowGroups: []parquetRowGroup{{
+				NumRows:             3,
+				TotalByteSize:       100,
+				TotalCompressedSize: 80,
+				FileOffset:          4,
+				Columns: []parquetColumnChunk{
+					{
+						Path: "id", Type: "INT64", Codec: "SNAPPY", Encodings: []string{"PLAIN", "RLE"},
+						NumValues: 3, TotalUncompressedSize: 50, TotalCompressedSize: 40, DataPageOffset: 4,
+						Statistics: &parquetStatistics{NullCount: &nullCounts[0], Min: int64(1), Max: int64(3),
+							min: []byte{1, 0, 0, 0, 0, 0, 0, 0}, max: []byte{3, 0, 0, 0, 0, 0, 0, 0}},
+						physicalType: 2,
+					},
+					{
+						Path: "name", Type: "BYTE_ARRAY", Codec: "SNAPPY", Encodings: []string{"PLAIN", "RLE"},
+						NumValues: 3, TotalUncompressedSize: 50, TotalCompressedSize: 40, DataPageOffset: 60,
+						DictionaryPageOffset: &dictionaryOffset,
+						Statistics: &parquetStatistics{NullCount: &nullCounts[1], Min: "ada", Max: "grace",
+							min: []byte("ada"), max: []byte("grace")},
+						physicalType: 6,
+					},
+				},
+			}},
+		}
+	}
+
+	for _, createdBy := range []string{
+		"parquet-cpp-arrow version 14.0.1",
+		"parquet-mr version 1.12.3 " + strings.Repeat("x", parquetTailReadLen),
+	} {
+		file := parquetFile(parquetFooter(createdBy))
+		metadata, err := readParquetMetadata(bytes.NewReader(file), int64(len(file)))
+		if err != nil {
+			t.Fatalf("Error calling readParquetMetadata: %v", err)
+		}
+		if !reflect.DeepEqual(metadata, expected(createdBy)) {
+			t.Fatalf("Expected %+v, got %+v", expected(createdBy), metadata)
+		}
+	}
+}
+
+// TestReadParquetMetadataMalformed tests that files that are not Parquet, or whose footers are truncated or malformed,
+// are rejected.
+func TestReadParquetMetadataMalformed(t *testing.T) {
+	footer := parquetFooter("parquet-cpp-arrow version 14.0.1")
+	file := parquetFile(footer)
+
+	outOfBounds := append([]byte(nil), file...)
+	binary.LittleEndian.PutUint32(outOfBounds[len(outOfBounds)-8:], uint32(len(file)))
+
+	hugeList := &thriftWriter{}
+	hugeList.structValue(func() {
+		hugeList.list(2, thriftStruct, 1<<30, func() {})
+	})
+
+	for _, test := range []struct {
+		name string
+		file []byte
+	}{
+		{"too small", []byte("PAR1PAR1")},
+		{"no magic", append(append([]byte(nil), file[:len(file)-4]...), "PAR2"...)},
+		{"length out of bounds", outOfBounds},
+		{"huge list", parquetFile(hugeList.Bytes())},
+	} {
+		if _, err := readParquetMetadata(bytes.NewReader(test.file), int64(len(test.file))); err == nil {
+			t.Errorf("Expected an error reading %s", test.name)
+		}
+	}
+
+	// Every prefix of the footer is missing the end of the FileMetaData struct.
+	for n := 0; n < len(footer); n++ {
+		truncated := parquetFile(footer[:n])
+		if _, err := readParquetMetadata(bytes.NewReader(truncated), int64(len(truncated))); err == nil {
+			t.Fatalf("Expected an error reading a footer truncated to %d of %d bytes", n, len(footer))
+		}
+	}
+}
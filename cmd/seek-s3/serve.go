@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	s3readerat "github.com/markandrus/s3readerat"
+	"github.com/markandrus/s3readerat/cache"
+	"github.com/markandrus/s3readerat/rangeproxy"
+	"github.com/pkg/errors"
+)
+
+// serve implements the serve subcommand, which runs a range proxy that serves S3 objects from a shared cache.
+func serve(args []string) {
+	flags := flag.NewFlagSet("serve", flag.ExitOnError)
+	common := newCommonFlags(flags)
+	listen := flags.String("listen", "127.0.0.1:7070", "`address` to listen on")
+	cacheDir := flags.String("cache-dir", "", "cache blocks in `directory`, rather than in memory")
+	diskCache := flags.Bool("disk-cache", false,
+		"cache blocks on disk in the per-user cache directory, or -cache-dir, rather than in memory")
+	cacheKeyFile := flags.String("cache-key-file", "",
+		"encrypt blocks in -cache-dir with the hex-encoded 16-, 24- or 32-byte AES key in `file`")
+	cacheBytes := flags.Int64("cache-bytes", rangeproxy.DefaultCacheBytes, "size of the memory cache in bytes")
+	blockSize := flags.Int64("block-size", 0,
+		fmt.Sprintf("size of cached blocks in bytes (default %d, or that of an existing -cache-dir)",
+			cache.DefaultBlockSize))
+	adminListen := flags.String("admin-listen", "",
+		"serve pprof, cache statistics and in-flight requests on `address` (default is not to)")
+	objectTTL := flags.Duration("object-ttl", rangeproxy.DefaultObjectTTL,
+		"how long to trust an object's size and ETag before checking them again")
+	tlsCert := flags.String("tls-cert", "", "serve HTTPS with the PEM certificate (chain) in `file`; needs -tls-key")
+	tlsKey := flags.String("tls-key", "", "PEM private key `file` for -tls-cert")
+	authFile := flags.String("auth-file", "",
+		"require the credentials in JSON `file`: a list of {name, username and password or token, prefixes}")
+	shutdownTimeout := flags.Duration("shutdown-timeout", 30*time.Second,
+		"how long to wait for requests in progress to complete on SIGINT or SIGTERM")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s serve [flags]\n\n", os.Args[0])
+		fmt.Fprintln(flags.Output(), "Serves byte ranges of S3 objects over HTTP from one shared cache. Clients")
+		fmt.Fprintln(flags.Output(), "request /bucket/key with a Range header, or use the rangeproxy package's")
+		fmt.Fprintln(flags.Output(), "ReaderAt.")
+		fmt.Fprintln(flags.Output())
+		flags.PrintDefaults()
+	}
+	_ = flags.Parse(args)
+
+	if flags.NArg() != 0 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	if (*tlsCert == "") != (*tlsKey == "") {
+		fatalf("Pass both -tls-cert and -tls-key, or neither")
+	}
+
+	// Load the AWS config up front, since objects are opened concurrently.
+	common.setup()
+
+	var store cache.Store
+	if *cacheDir != "" || *diskCache {
+		diskStore, err := openDiskStore(*cacheDir, *blockSize, *cacheKeyFile)
+		if err != nil {
+			fatalf("Unable to open cache: %v", err)
+		}
+		store = diskStore
+	} else {
+		store = cache.NewMemoryStore(*blockSize, *cacheBytes)
+	}
+
+	server, err := rangeproxy.NewServer(rangeproxy.ServerOptions{
+		Open: func(bucket string, key string) (rangeproxy.Source, error) {
+			return common.newReader(s3readerat.S3URL{Bucket: bucket, Key: key})
+		},
+		Store:     store,
+		ObjectTTL: *objectTTL,
+	})
+	if err != nil {
+		fatalf("Unable to create server: %v", err)
+	}
+
+	if *adminListen != "" {
+		serveAdmin(*adminListen, server)
+	}
+
+	var handler http.Handler = server
+	if *authFile != "" {
+		credentials, err := loadCredentials(*authFile)
+		if err != nil {
+			fatalf("Unable to load credentials: %v", err)
+		}
+		if handler, err = rangeproxy.RequireAuth(server, credentials); err != nil {
+			fatalf("Invalid credentials: %v", err)
+		}
+		if *tlsCert == "" {
+			logger.log(levelError, "Credentials will be sent in the clear; pass -tls-cert and -tls-key to serve HTTPS")
+		}
+	}
+
+	httpServer := &http.Server{Addr: *listen, Handler: handler}
+	served := make(chan error, 1)
+	go func() {
+		if *tlsCert != "" {
+			served <- httpServer.ListenAndServeTLS(*tlsCert, *tlsKey)
+		} else {
+			served <- httpServer.ListenAndServe()
+		}
+	}()
+	infof("Listening on %s", *listen)
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+
+	select {
+	case err = <-served:
+		fatalf("Server failed: %v", err)
+	case sig := <-signals:
+		infof("Received %v; draining connections for up to %v", sig, *shutdownTimeout)
+	}
+
+	// Stop accepting connections and wait for requests in progress to complete, up to the deadline.
+	ctx, cancel := context.WithTimeout(context.Background(), *shutdownTimeout)
+	defer cancel()
+	if err = httpServer.Shutdown(ctx); err != nil {
+		infof("Closing connections still active after %v", *shutdownTimeout)
+		_ = httpServer.Close()
+	}
+
+	if err = server.Close(); err != nil {
+		fatalf("Unable to close cache: %v", err)
+	}
+	infof("Shut down")
+}
+
+// loadCredentials reads the credentials for -auth-file.
+func loadCredentials(path string) ([]rangeproxy.Credential, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var credentials []rangeproxy.Credential
+	if err = json.Unmarshal(b, &credentials); err != nil {
+		return nil, errors.Wrapf(err, "unable to parse %s", path)
+	}
+
+	return credentials, nil
+}
@@ -0,0 +1,98 @@
+//go:build cgo
+// +build cgo
+
+package main
+
+import (
+	"database/sql"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/markandrus/s3readerat/sqlitevfs"
+)
+
+func init() {
+	subcommands["sqlite"] = sqliteQuery
+}
+
+// sqliteQuery implements the sqlite subcommand, which runs a query against a SQLite database stored in S3 and prints
+// the results as tab-separated values.
+func sqliteQuery(args []string) {
+	flags := flag.NewFlagSet("sqlite", flag.ExitOnError)
+	common := newCommonFlags(flags)
+	header := flags.Bool("header", true, "print column names before the results")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s sqlite [flags] s3://bucket/db.sqlite \"SELECT ...\"\n\n", os.Args[0])
+		fmt.Fprintln(flags.Output(), "Runs a read-only query against a remote SQLite database, fetching only the pages")
+		fmt.Fprintln(flags.Output(), "the query touches.")
+		fmt.Fprintln(flags.Output())
+		flags.PrintDefaults()
+	}
+	_ = flags.Parse(args)
+
+	if flags.NArg() != 2 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	reader := common.open(flags.Arg(0))
+
+	err := sqlitevfs.Register("s3readerat", func(name string) (sqlitevfs.ReaderAt, error) {
+		return reader, nil
+	})
+	if err != nil {
+		fatalf("Unable to register SQLite VFS: %v", err)
+	}
+
+	db, err := sql.Open("sqlite3", "db?vfs=s3readerat&mode=ro")
+	if err != nil {
+		fatalf("Unable to open SQLite database: %v", err)
+	}
+	defer db.Close()
+
+	rows, err := db.Query(flags.Arg(1))
+	if err != nil {
+		fatalf("Query failed: %v", err)
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		fatalf("Unable to get result columns: %v", err)
+	}
+
+	if *header {
+		fmt.Println(strings.Join(columns, "\t"))
+	}
+
+	values := make([]interface{}, len(columns))
+	pointers := make([]interface{}, len(columns))
+	for i := range values {
+		pointers[i] = &values[i]
+	}
+
+	fields := make([]string, len(columns))
+	for rows.Next() {
+		if err = rows.Scan(pointers...); err != nil {
+			fatalf("Unable to read result row: %v", err)
+		}
+
+		for i, value := range values {
+			switch v := value.(type) {
+			case nil:
+				fields[i] = "NULL"
+			case []byte:
+				fields[i] = string(v)
+			default:
+				fields[i] = fmt.Sprint(v)
+			}
+		}
+		fmt.Println(strings.Join(fields, "\t"))
+	}
+
+	if err = rows.Err(); err != nil {
+		fatalf("Query failed: %v", err)
+	}
+}
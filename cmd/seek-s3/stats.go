@@ -0,0 +1,70 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"time"
+
+	s3readerat "github.com/markandrus/s3readerat"
+)
+
+// statsSummary is the JSON written by -stats-json.
+type statsSummary struct {
+	Requests                    int64   `json:"requests"`
+	HeadObjectRequests          int64   `json:"head_object_requests"`
+	GetObjectRequests           int64   `json:"get_object_requests"`
+	GetObjectAttributesRequests int64   `json:"get_object_attributes_requests"`
+	FailedRequests              int64   `json:"failed_requests"`
+	HedgedRequests              int64   `json:"hedged_requests"`
+	SlowDowns                   int64   `json:"slow_downs"`
+	BytesDownloaded             int64   `json:"bytes_downloaded"`
+	CacheHits                   int64   `json:"cache_hits"`
+	CacheHitBytes               int64   `json:"cache_hit_bytes"`
+	RequestTimeMs               float64 `json:"request_time_ms"`
+	AverageRequestTimeMs        float64 `json:"average_request_time_ms"`
+	GetObjectP50Ms              float64 `json:"get_object_p50_ms"`
+	GetObjectP99Ms              float64 `json:"get_object_p99_ms"`
+	GetObjectMaxMs              float64 `json:"get_object_max_ms"`
+	ElapsedMs                   float64 `json:"elapsed_ms"`
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// writeStats writes a summary of stats to path, or to stderr if path is "-". Failures are logged rather than fatal,
+// since this runs as seek-s3 exits.
+func writeStats(path string, stats s3readerat.Stats, latencies s3readerat.Latencies, elapsed time.Duration) {
+	out := os.Stderr
+	if path != "-" {
+		f, err := os.Create(path)
+		if err != nil {
+			logger.log(levelError, "Unable to create stats file: "+err.Error())
+			return
+		}
+		defer f.Close()
+		out = f
+	}
+
+	err := json.NewEncoder(out).Encode(statsSummary{
+		Requests:                    stats.Requests(),
+		HeadObjectRequests:          stats.HeadObjectRequests,
+		GetObjectRequests:           stats.GetObjectRequests,
+		GetObjectAttributesRequests: stats.GetObjectAttributesRequests,
+		FailedRequests:              stats.FailedRequests,
+		HedgedRequests:              stats.HedgedRequests,
+		SlowDowns:                   stats.SlowDowns,
+		BytesDownloaded:             stats.BytesDownloaded,
+		CacheHits:                   stats.CacheHits,
+		CacheHitBytes:               stats.CacheHitBytes,
+		RequestTimeMs:               milliseconds(stats.RequestTime),
+		AverageRequestTimeMs:        milliseconds(stats.AverageRequestTime()),
+		GetObjectP50Ms:              milliseconds(latencies.GetObject.Quantile(0.5)),
+		GetObjectP99Ms:              milliseconds(latencies.GetObject.Quantile(0.99)),
+		GetObjectMaxMs:              milliseconds(latencies.GetObject.Max()),
+		ElapsedMs:                   milliseconds(elapsed),
+	})
+	if err != nil {
+		logger.log(levelError, "Unable to write stats: "+err.Error())
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/markandrus/s3readerat"
+)
+
+// tarCommand implements the tar subcommand, which writes the objects under an S3 prefix to stdout as a tar archive.
+func tarCommand(args []string) {
+	flags := flag.NewFlagSet("tar", flag.ExitOnError)
+	common := newCommonFlags(flags)
+	depth := flags.Int("depth", s3readerat.DefaultTarDepth, "number of upcoming objects to prefetch")
+	prefetchBytes := flags.Int64("prefetch-bytes", s3readerat.DefaultTarPrefetchBytes,
+		"number of bytes at the start of each upcoming object to prefetch")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s tar [flags] s3://bucket/prefix\n\n", os.Args[0])
+		fmt.Fprintln(flags.Output(), "Writes the objects whose keys start with prefix to stdout as a tar archive,")
+		fmt.Fprintln(flags.Output(), "named after the part of their keys following the prefix's last slash. The")
+		fmt.Fprintln(flags.Output(), "next few objects are prefetched while each is written.")
+		fmt.Fprintln(flags.Output())
+		flags.PrintDefaults()
+	}
+	_ = flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	if *depth <= 0 {
+		fatalf("Depth parameter must be positive")
+	}
+
+	if *prefetchBytes <= 0 {
+		fatalf("Prefetch bytes parameter must be positive")
+	}
+
+	parsed := common.parseURL(flags.Arg(0))
+	common.setup()
+
+	n, err := s3readerat.WriteTar(context.Background(), os.Stdout, s3readerat.TarOptions{
+		Options:       common.readerOptions(s3readerat.S3URL{Bucket: parsed.Bucket, Region: parsed.Region}),
+		Prefix:        parsed.Key,
+		Depth:         *depth,
+		PrefetchBytes: *prefetchBytes,
+		OnOpen: func(reader *s3readerat.S3ReaderAt) {
+			reader.Debug = logger.enabled(levelDebug)
+			common.readers = append(common.readers, reader)
+		},
+	})
+	if err != nil {
+		fatalf("Failed to write tar archive: %v", err)
+	}
+	infof("Archived %d objects", n)
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"encoding/binary"
+
+	"github.com/pkg/errors"
+)
+
+// Thrift compact protocol type identifiers.
+const (
+	thriftStop         = 0
+	thriftBooleanTrue  = 1
+	thriftBooleanFalse = 2
+	thriftByte         = 3
+	thriftI16          = 4
+	thriftI32          = 5
+	thriftI64          = 6
+	thriftDouble       = 7
+	thriftBinary       = 8
+	thriftList         = 9
+	thriftSet          = 10
+	thriftMap          = 11
+	thriftStruct       = 12
+)
+
+// thriftMaxDepth is the most structs, lists, sets and maps a thriftReader reads nested within each other. Parquet
+// metadata nests a handful deep, so deeper messages are malformed, and are rejected rather than exhausting the stack.
+const thriftMaxDepth = 64
+
+var (
+	// errThriftTruncated is returned when a Thrift message ends unexpectedly.
+	errThriftTruncated = errors.New("truncated Thrift message")
+
+	// errThriftTooDeep is returned when a Thrift message nests values more than thriftMaxDepth deep.
+	errThriftTooDeep = errors.New("Thrift message is nested too deeply")
+)
+
+// thriftReader decodes the subset of the Thrift compact protocol needed to read Parquet metadata.
+type thriftReader struct {
+	b     []byte
+	depth int
+}
+
+// nest enters a struct or collection, returning a function that leaves it, or errThriftTooDeep.
+func (r *thriftReader) nest() (func(), error) {
+	if r.depth >= thriftMaxDepth {
+		return nil, errThriftTooDeep
+	}
+	r.depth++
+	return func() { r.depth-- }, nil
+}
+
+// readStruct reads a struct, calling field for each field. field must consume the field's value, either by reading it
+// or by calling skip.
+func (r *thriftReader) readStruct(field func(id int16, typ byte) error) error {
+	leave, err := r.nest()
+	if err != nil {
+		return err
+	}
+	defer leave()
+
+	var lastID int16
+	for {
+		if len(r.b) == 0 {
+			return errThriftTruncated
+		}
+		header := r.b[0]
+		r.b = r.b[1:]
+
+		typ := header & 0x0f
+		if typ == thriftStop {
+			return nil
+		}
+
+		if delta := int16(header >> 4); delta != 0 {
+			lastID += delta
+		} else {
+			id, err := r.readVarint()
+			if err != nil {
+				return err
+			}
+			lastID = int16(id)
+		}
+
+		if err := field(lastID, typ); err != nil {
+			return err
+		}
+	}
+}
+
+// readList reads a list header, calling elem once for each element with the element type. Every element takes at
+// least a byte, so a list claiming more elements than bytes remain is rejected as truncated before any are read.
+func (r *thriftReader) readList(elem func(typ byte) error) error {
+	leave, err := r.nest()
+	if err != nil {
+		return err
+	}
+	defer leave()
+
+	if len(r.b) == 0 {
+		return errThriftTruncated
+	}
+	header := r.b[0]
+	r.b = r.b[1:]
+
+	size := int64(header >> 4)
+	if size == 15 {
+		if size, err = r.readUvarint(); err != nil {
+			return err
+		}
+	}
+	if size < 0 || size > int64(len(r.b)) {
+		return errThriftTruncated
+	}
+
+	typ := header & 0x0f
+	for i := int64(0); i < size; i++ {
+		if err := elem(typ); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *thriftReader) readUvarint() (int64, error) {
+	v, n := binary.Uvarint(r.b)
+	if n <= 0 {
+		return 0, errThriftTruncated
+	}
+	r.b = r.b[n:]
+	return int64(v), nil
+}
+
+// readVarint reads a zigzag-encoded integer, which is how i16, i32 and i64 values are encoded.
+func (r *thriftReader) readVarint() (int64, error) {
+	v, n := binary.Varint(r.b)
+	if n <= 0 {
+		return 0, errThriftTruncated
+	}
+	r.b = r.b[n:]
+	return v, nil
+}
+
+func (r *thriftReader) readI32() (int32, error) {
+	v, err := r.readVarint()
+	return int32(v), err
+}
+
+func (r *thriftReader) readBinary() ([]byte, error) {
+	size, err := r.readUvarint()
+	if err != nil {
+		return nil, err
+	}
+	if size < 0 || size > int64(len(r.b)) {
+		return nil, errThriftTruncated
+	}
+
+	v := r.b[:size]
+	r.b = r.b[size:]
+	return v, nil
+}
+
+func (r *thriftReader) readString() (string, error) {
+	v, err := r.readBinary()
+	return string(v), err
+}
+
+// skip consumes a value of the given type.
+func (r *thriftReader) skip(typ byte) error {
+	switch typ {
+	case thriftBooleanTrue, thriftBooleanFalse:
+		// Field booleans are encoded in the type; collection booleans occupy a byte, which skipElem consumes.
+		return nil
+	case thriftByte:
+		if len(r.b) == 0 {
+			return errThriftTruncated
+		}
+		r.b = r.b[1:]
+		return nil
+	case thriftI16, thriftI32, thriftI64:
+		_, err := r.readVarint()
+		return err
+	case thriftDouble:
+		if len(r.b) < 8 {
+			return errThriftTruncated
+		}
+		r.b = r.b[8:]
+		return nil
+	case thriftBinary:
+		_, err := r.readBinary()
+		return err
+	case thriftList, thriftSet:
+		return r.readList(r.skipElem)
+	case thriftMap:
+		leave, err := r.nest()
+		if err != nil {
+			return err
+		}
+		defer leave()
+
+		size, err := r.readUvarint()
+		if err != nil || size == 0 {
+			return err
+		}
+		// Each entry takes at least two bytes, after the byte of key and value types.
+		if size < 0 || size > int64(len(r.b)-1)/2 {
+			return errThriftTruncated
+		}
+		types := r.b[0]
+		r.b = r.b[1:]
+		for i := int64(0); i < size; i++ {
+			if err = r.skipElem(types >> 4); err != nil {
+				return err
+			}
+			if err = r.skipElem(types & 0x0f); err != nil {
+				return err
+			}
+		}
+		return nil
+	case thriftStruct:
+		return r.readStruct(func(id int16, typ byte) error {
+			return r.skip(typ)
+		})
+	default:
+		return errors.Errorf("unknown Thrift type %d", typ)
+	}
+}
+
+// skipElem consumes a collection element, where booleans are encoded as a byte rather than in the type.
+func (r *thriftReader) skipElem(typ byte) error {
+	if typ == thriftBooleanTrue || typ == thriftBooleanFalse {
+		return r.skip(thriftByte)
+	}
+	return r.skip(typ)
+}
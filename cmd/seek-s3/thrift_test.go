@@ -0,0 +1,182 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+// thriftWriter encodes messages in the Thrift compact protocol, as Parquet writers encode their metadata.
+type thriftWriter struct {
+	bytes.Buffer
+	lastIDs []int16
+}
+
+func (w *thriftWriter) uvarint(v uint64) {
+	b := make([]byte, binary.MaxVarintLen64)
+	w.Write(b[:binary.PutUvarint(b, v)])
+}
+
+func (w *thriftWriter) varint(v int64) {
+	b := make([]byte, binary.MaxVarintLen64)
+	w.Write(b[:binary.PutVarint(b, v)])
+}
+
+// field writes the header of the field id of type typ of the struct being written.
+func (w *thriftWriter) field(id int16, typ byte) {
+	last := &w.lastIDs[len(w.lastIDs)-1]
+	if delta := id - *last; delta > 0 && delta <= 15 {
+		w.WriteByte(byte(delta)<<4 | typ)
+	} else {
+		w.WriteByte(typ)
+		w.varint(int64(id))
+	}
+	*last = id
+}
+
+func (w *thriftWriter) i32(id int16, v int32) {
+	w.field(id, thriftI32)
+	w.varint(int64(v))
+}
+
+func (w *thriftWriter) i64(id int16, v int64) {
+	w.field(id, thriftI64)
+	w.varint(v)
+}
+
+func (w *thriftWriter) binary(id int16, b []byte) {
+	w.field(id, thriftBinary)
+	w.binaryValue(b)
+}
+
+func (w *thriftWriter) binaryValue(b []byte) {
+	w.uvarint(uint64(len(b)))
+	w.Write(b)
+}
+
+// structValue writes a struct, whose fields fields writes.
+func (w *thriftWriter) structValue(fields func()) {
+	w.lastIDs = append(w.lastIDs, 0)
+	fields()
+	w.WriteByte(thriftStop)
+	w.lastIDs = w.lastIDs[:len(w.lastIDs)-1]
+}
+
+func (w *thriftWriter) structField(id int16, fields func()) {
+	w.field(id, thriftStruct)
+	w.structValue(fields)
+}
+
+// list writes the field id as a list of n elements of type typ, which elems writes.
+func (w *thriftWriter) list(id int16, typ byte, n int, elems func()) {
+	w.field(id, thriftList)
+	w.listHeader(typ, n)
+	elems()
+}
+
+func (w *thriftWriter) listHeader(typ byte, n int) {
+	if n < 15 {
+		w.WriteByte(byte(n)<<4 | typ)
+	} else {
+		w.WriteByte(0xf0 | typ)
+		w.uvarint(uint64(n))
+	}
+}
+
+// TestThriftReaderSkip tests that skip consumes values of every type, so that unknown fields are passed over.
+func TestThriftReaderSkip(t *testing.T) {
+	w := &thriftWriter{}
+	w.structValue(func() {
+		w.field(1, thriftBooleanTrue)
+		w.field(2, thriftByte)
+		w.WriteByte(7)
+		w.i32(3, -5)
+		w.i64(4, 1<<40)
+		w.field(5, thriftDouble)
+		w.Write(make([]byte, 8))
+		w.binary(6, []byte("skipped"))
+		w.list(7, thriftBooleanFalse, 3, func() {
+			w.Write([]byte{1, 0, 1})
+		})
+		w.field(8, thriftMap)
+		w.uvarint(2)
+		w.WriteByte(thriftBinary<<4 | thriftStruct)
+		for _, key := range []string{"a", "b"} {
+			w.binaryValue([]byte(key))
+			w.structValue(func() {
+				w.i32(1, 1)
+			})
+		}
+		w.field(9, thriftMap)
+		w.uvarint(0)
+		w.structField(300, func() {
+			w.binary(1, []byte("nested"))
+		})
+		w.i32(301, 42)
+	})
+	w.WriteByte(0xff)
+
+	r := &thriftReader{b: w.Bytes()}
+	var last int32
+	err := r.readStruct(func(id int16, typ byte) error {
+		if id == 301 {
+			var err error
+			last, err = r.readI32()
+			return err
+		}
+		return r.skip(typ)
+	})
+	if err != nil {
+		t.Fatalf("Error calling readStruct: %v", err)
+	}
+	if last != 42 || !bytes.Equal(r.b, []byte{0xff}) {
+		t.Fatalf("Expected to read 42 and leave one byte, got %d and %x", last, r.b)
+	}
+}
+
+// TestThriftReaderMalformed tests that malformed messages are rejected without reading past their end, allocating
+// for element counts they do not hold, or recursing without bound.
+func TestThriftReaderMalformed(t *testing.T) {
+	nested := &thriftWriter{}
+	var nest func(depth int)
+	nest = func(depth int) {
+		nested.structValue(func() {
+			if depth > 0 {
+				nested.field(1, thriftStruct)
+				nest(depth - 1)
+			}
+		})
+	}
+	nest(10000)
+
+	nestedLists := bytes.Repeat([]byte{0x19}, 10000)
+
+	for _, test := range []struct {
+		name     string
+		b        []byte
+		expected error
+	}{
+		{"empty", nil, errThriftTruncated},
+		{"unterminated struct", []byte{0x15, 0x02}, errThriftTruncated},
+		{"truncated varint", []byte{0x15, 0x80}, errThriftTruncated},
+		{"truncated binary", []byte{0x18, 0x05, 'a', 'b'}, errThriftTruncated},
+		{"huge binary", []byte{0x18, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01}, errThriftTruncated},
+		{"truncated double", []byte{0x17, 0, 0, 0}, errThriftTruncated},
+		{"huge list", []byte{0x19, 0xf5, 0xff, 0xff, 0xff, 0xff, 0x0f, 0x00}, errThriftTruncated},
+		{"negative list", []byte{0x19, 0xf5, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01},
+			errThriftTruncated},
+		{"huge map", []byte{0x1b, 0xff, 0xff, 0xff, 0xff, 0x0f, 0x55, 0x00}, errThriftTruncated},
+		{"deeply nested structs", nested.Bytes(), errThriftTooDeep},
+		{"deeply nested lists", append([]byte{0x19}, nestedLists...), errThriftTooDeep},
+	} {
+		r := &thriftReader{b: test.b}
+		err := r.readStruct(func(id int16, typ byte) error {
+			return r.skip(typ)
+		})
+		if !errors.Is(err, test.expected) {
+			t.Errorf("Expected %q to fail with %v, got %v", test.name, test.expected, err)
+		}
+	}
+}
@@ -0,0 +1,329 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	s3readerat "github.com/markandrus/s3readerat"
+	"github.com/markandrus/s3readerat/cache"
+	"github.com/markandrus/s3readerat/rangeproxy"
+	"github.com/pkg/errors"
+)
+
+// warmRange is an inclusive byte range of an object to warm. A negative first is a suffix of -first bytes, and a
+// negative last means the rest of the object.
+type warmRange struct {
+	first int64
+	last  int64
+}
+
+// warmReport is printed once every block has been warmed or has failed.
+type warmReport struct {
+	Objects       int   `json:"objects"`
+	Blocks        int   `json:"blocks"`
+	CachedBlocks  int   `json:"cached_blocks"`
+	FetchedBlocks int   `json:"fetched_blocks"`
+	FetchedBytes  int64 `json:"fetched_bytes"`
+	FailedBlocks  int   `json:"failed_blocks"`
+}
+
+// warmBlock is a block of an object to fetch into the cache.
+type warmBlock struct {
+	reader *s3readerat.S3ReaderAt
+	object cache.Object
+	index  int64
+}
+
+// rangesFlag collects the ranges given by repeated -range flags.
+type rangesFlag []warmRange
+
+func (f *rangesFlag) String() string {
+	return ""
+}
+
+func (f *rangesFlag) Set(value string) error {
+	r, err := parseWarmRange(value)
+	if err != nil {
+		return err
+	}
+	*f = append(*f, r)
+	return nil
+}
+
+// warm implements the warm subcommand, which fetches ranges of S3 objects into a disk cache shared with seek-s3 serve,
+// so that a later job does not wait on S3.
+func warm(args []string) {
+	flags := flag.NewFlagSet("warm", flag.ExitOnError)
+	common := newCommonFlags(flags)
+	cacheDir := flags.String("cache-dir", "",
+		"cache `directory` to warm, as passed to seek-s3 serve (default is the per-user cache directory)")
+	blockSize := flags.Int64("block-size", 0, "size of cached blocks in bytes, if creating the cache")
+	cacheKeyFile := flags.String("cache-key-file", "", "key `file` of an encrypted cache, as passed to seek-s3 serve")
+	trace := flags.String("trace", "", "warm the ranges read in a -request-log `file` (- is stdin)")
+	parallel := flags.Int("parallel", 8, "maximum number of blocks to fetch at once")
+	var ranges rangesFlag
+	flags.Var(&ranges, "range", "warm only `first-last`, first- or -suffix bytes of each URL (repeatable)")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s warm [flags] [s3://bucket/key ...]\n\n", os.Args[0])
+		fmt.Fprintln(flags.Output(), "Fetches whole objects, the ranges given by -range, or the ranges read in a")
+		fmt.Fprintln(flags.Output(), "recorded -request-log into a disk cache, skipping blocks already cached. A JSON")
+		fmt.Fprintln(flags.Output(), "summary is printed to stdout.")
+		fmt.Fprintln(flags.Output())
+		flags.PrintDefaults()
+	}
+	_ = flags.Parse(args)
+
+	if flags.NArg() == 0 && *trace == "" {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	if *parallel < 1 {
+		fatalf("Parallel parameter must be positive")
+	}
+
+	if len(ranges) == 0 {
+		ranges = rangesFlag{{first: 0, last: -1}}
+	}
+
+	store, err := openDiskStore(*cacheDir, *blockSize, *cacheKeyFile)
+	if err != nil {
+		fatalf("Unable to open cache: %v", err)
+	}
+	defer store.Close()
+
+	// Ranges to warm, by object, in the order the objects were first seen.
+	var objects []s3readerat.S3URL
+	wanted := make(map[s3readerat.S3URL][]warmRange)
+	want := func(object s3readerat.S3URL, ranges []warmRange) {
+		if _, ok := wanted[object]; !ok {
+			objects = append(objects, object)
+		}
+		wanted[object] = append(wanted[object], ranges...)
+	}
+
+	for _, rawURL := range flags.Args() {
+		want(*common.parseURL(rawURL), ranges)
+	}
+
+	if *trace != "" {
+		traced, err := readTrace(*trace)
+		if err != nil {
+			fatalf("Unable to read trace: %v", err)
+		}
+		for _, t := range traced {
+			want(t.object, t.ranges)
+		}
+	}
+
+	var report warmReport
+	var blocks []warmBlock
+	for _, o := range objects {
+		reader := common.openObject(o)
+
+		size, err := reader.Size()
+		if err != nil {
+			fatalf("Unable to get size of S3 object %s: %v", o.String(), err)
+		}
+
+		etag, err := reader.ETag()
+		if err != nil {
+			fatalf("Unable to get ETag of S3 object %s: %v", o.String(), err)
+		}
+		object := rangeproxy.CacheObject(o.Bucket, o.Key, etag, size)
+
+		report.Objects++
+		for _, index := range blockIndexes(wanted[o], size, store.BlockSize()) {
+			blocks = append(blocks, warmBlock{reader: reader, object: object, index: index})
+		}
+	}
+	report.Blocks = len(blocks)
+
+	var mu sync.Mutex
+	work := make(chan warmBlock)
+	var wg sync.WaitGroup
+	for i := 0; i < *parallel; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for block := range work {
+				cached, n, err := warmOne(store, block)
+
+				mu.Lock()
+				switch {
+				case err != nil:
+					report.FailedBlocks++
+					infof("Unable to warm block %d of %s: %v", block.index, block.object.Name, err)
+				case cached:
+					report.CachedBlocks++
+				default:
+					report.FetchedBlocks++
+					report.FetchedBytes += n
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, block := range blocks {
+		work <- block
+	}
+	close(work)
+	wg.Wait()
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err = encoder.Encode(report); err != nil {
+		fatalf("Unable to encode report: %v", err)
+	}
+
+	if report.FailedBlocks > 0 {
+		fatalf("Failed to warm %d of %d blocks", report.FailedBlocks, report.Blocks)
+	}
+}
+
+// warmOne fetches block into store, unless it is already cached, returning the number of bytes fetched.
+func warmOne(store *cache.DiskStore, block warmBlock) (bool, int64, error) {
+	if _, ok := store.Get(block.object, block.index); ok {
+		return true, 0, nil
+	}
+
+	off := block.index * store.BlockSize()
+	n := store.BlockSize()
+	if n > block.object.Size-off {
+		n = block.object.Size - off
+	}
+
+	data := make([]byte, n)
+	if read, err := block.reader.ReadAt(data, off); int64(read) < n {
+		if err == nil || err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return false, 0, err
+	}
+
+	if err := store.Put(block.object, block.index, data); err != nil {
+		return false, 0, err
+	}
+
+	return false, n, nil
+}
+
+// blockIndexes returns the indexes of the blocks of blockSize bytes that ranges of an object of size bytes fall
+// within, in order and without duplicates.
+func blockIndexes(ranges []warmRange, size int64, blockSize int64) []int64 {
+	seen := make(map[int64]bool)
+	var indexes []int64
+	for _, r := range ranges {
+		first, last := r.first, r.last
+		if first < 0 {
+			first, last = size+first, size-1
+		}
+		if first < 0 {
+			first = 0
+		}
+		if last < 0 || last >= size {
+			last = size - 1
+		}
+
+		for index := first / blockSize; first <= last && index <= last/blockSize; index++ {
+			if !seen[index] {
+				seen[index] = true
+				indexes = append(indexes, index)
+			}
+		}
+	}
+
+	sort.Slice(indexes, func(i, j int) bool { return indexes[i] < indexes[j] })
+	return indexes
+}
+
+// parseWarmRange parses a -range value of the form first-last, first- or -suffix.
+func parseWarmRange(value string) (warmRange, error) {
+	dash := strings.Index(value, "-")
+	if dash < 0 {
+		return warmRange{}, errors.Errorf("range %q must be of the form first-last, first- or -suffix", value)
+	}
+
+	if dash == 0 {
+		suffix, err := strconv.ParseInt(value[1:], 10, 64)
+		if err != nil || suffix <= 0 {
+			return warmRange{}, errors.Errorf("range %q has an invalid suffix length", value)
+		}
+		return warmRange{first: -suffix, last: -1}, nil
+	}
+
+	first, err := strconv.ParseInt(value[:dash], 10, 64)
+	if err != nil {
+		return warmRange{}, errors.Errorf("range %q has an invalid first byte", value)
+	}
+
+	last := int64(-1)
+	if value[dash+1:] != "" {
+		if last, err = strconv.ParseInt(value[dash+1:], 10, 64); err != nil || last < first {
+			return warmRange{}, errors.Errorf("range %q has an invalid last byte", value)
+		}
+	}
+
+	return warmRange{first: first, last: last}, nil
+}
+
+// tracedObject holds the ranges of one object read in a trace.
+type tracedObject struct {
+	object s3readerat.S3URL
+	ranges []warmRange
+}
+
+// readTrace reads the GetObject ranges recorded in a request log at path, or on stdin if path is "-".
+func readTrace(path string) ([]tracedObject, error) {
+	in := os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		in = f
+	}
+
+	var objects []tracedObject
+	indexes := make(map[s3readerat.S3URL]int)
+
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(nil, 1<<20)
+	for line := 1; scanner.Scan(); line++ {
+		var entry s3readerat.RequestLogEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, errors.Wrapf(err, "line %d", line)
+		}
+		if entry.Operation != "GetObject" || entry.CacheHit || entry.Error != "" {
+			continue
+		}
+
+		r := warmRange{first: 0, last: -1}
+		if entry.Range != "" {
+			var err error
+			if r, err = parseWarmRange(strings.TrimPrefix(entry.Range, "bytes=")); err != nil {
+				return nil, errors.Wrapf(err, "line %d", line)
+			}
+		}
+
+		object := s3readerat.S3URL{Bucket: entry.Bucket, Key: entry.Key}
+		index, ok := indexes[object]
+		if !ok {
+			index = len(objects)
+			indexes[object] = index
+			objects = append(objects, tracedObject{object: object})
+		}
+		objects[index].ranges = append(objects[index].ranges, r)
+	}
+
+	return objects, scanner.Err()
+}
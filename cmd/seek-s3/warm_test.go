@@ -0,0 +1,109 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestParseWarmRange tests that -range values of the forms first-last, first- and -suffix are parsed, and that
+// others are rejected.
+func TestParseWarmRange(t *testing.T) {
+	for value, expected := range map[string]warmRange{
+		"0-9":   {first: 0, last: 9},
+		"5-5":   {first: 5, last: 5},
+		"100-":  {first: 100, last: -1},
+		"-1024": {first: -1024, last: -1},
+	} {
+		r, err := parseWarmRange(value)
+		if err != nil {
+			t.Fatalf("Error calling parseWarmRange for %q: %v", value, err)
+		}
+		if r != expected {
+			t.Fatalf("Expected %+v parsing %q, got %+v", expected, value, r)
+		}
+	}
+
+	for _, value := range []string{"", "10", "-", "-0", "a-9", "0-b", "9-0", "--5"} {
+		if _, err := parseWarmRange(value); err == nil {
+			t.Errorf("Expected an error parsing %q", value)
+		}
+	}
+}
+
+// TestBlockIndexes tests that ranges are mapped to the blocks they fall within, in order and without duplicates, and
+// clamped to the object.
+func TestBlockIndexes(t *testing.T) {
+	for _, test := range []struct {
+		ranges   []warmRange
+		expected []int64
+	}{
+		{[]warmRange{{first: 0, last: -1}}, []int64{0, 1, 2}},
+		{[]warmRange{{first: 4, last: 7}}, []int64{1}},
+		{[]warmRange{{first: 6, last: 100}, {first: 0, last: 1}, {first: 5, last: 5}}, []int64{0, 1, 2}},
+		{[]warmRange{{first: -2, last: -1}}, []int64{2}},
+		{[]warmRange{{first: -100, last: -1}}, []int64{0, 1, 2}},
+		{[]warmRange{{first: 10, last: -1}}, nil},
+	} {
+		if indexes := blockIndexes(test.ranges, 10, 4); !reflect.DeepEqual(indexes, test.expected) {
+			t.Fatalf("Expected blocks %v for %+v, got %v", test.expected, test.ranges, indexes)
+		}
+	}
+}
+
+// runWarm runs seek-s3 with args, which must run the warm subcommand, and stdin, returning its report.
+func runWarm(t *testing.T, stdin string, args ...string) warmReport {
+	t.Helper()
+
+	cmd := seekS3Command(args...)
+	cmd.Stdin = strings.NewReader(stdin)
+	stdout, stderr, code := runCommand(t, cmd)
+	if code != 0 {
+		t.Fatalf("Expected exit code 0, got %d: %s", code, stderr)
+	}
+
+	var report warmReport
+	if err := json.Unmarshal([]byte(stdout), &report); err != nil {
+		t.Fatalf("Error decoding report %q: %v", stdout, err)
+	}
+	return report
+}
+
+// TestWarm tests that the warm subcommand fetches the blocks of the given ranges into the cache, skips blocks already
+// cached, and warms the ranges read in a trace.
+func TestWarm(t *testing.T) {
+	server := newObjectServer(t, map[string]string{
+		"bucket/a": "0123456789",
+		"bucket/b": "abcdefghij",
+	})
+	flags := []string{"warm", "-endpoint", server.URL, "-path-style", "-no-sign-request", "-cache-dir", t.TempDir(),
+		"-block-size", "4"}
+
+	report := runWarm(t, "", append(flags, "-range", "0-5", "s3://bucket/a")...)
+	if report != (warmReport{Objects: 1, Blocks: 2, FetchedBlocks: 2, FetchedBytes: 8}) {
+		t.Fatalf("Expected 2 blocks fetched, got %+v", report)
+	}
+
+	report = runWarm(t, "", append(flags, "s3://bucket/a")...)
+	if report != (warmReport{Objects: 1, Blocks: 3, CachedBlocks: 2, FetchedBlocks: 1, FetchedBytes: 2}) {
+		t.Fatalf("Expected 2 blocks cached and the last fetched, got %+v", report)
+	}
+
+	trace := `{"operation": "HeadObject", "bucket": "bucket", "key": "b"}
+{"operation": "GetObject", "bucket": "bucket", "key": "b", "range": "bytes=8-9"}
+{"operation": "GetObject", "bucket": "bucket", "key": "b", "range": "bytes=0-3", "cache_hit": true}
+{"operation": "GetObject", "bucket": "bucket", "key": "b", "range": "bytes=4-7", "error": "throttled"}
+`
+	report = runWarm(t, trace, append(flags, "-trace", "-")...)
+	if report != (warmReport{Objects: 1, Blocks: 1, FetchedBlocks: 1, FetchedBytes: 2}) {
+		t.Fatalf("Expected the one range read from S3 in the trace fetched, got %+v", report)
+	}
+
+	if _, code := runSeekS3(t, "warm", "-cache-dir", t.TempDir()); code != 2 {
+		t.Fatalf("Expected exit code 2 without URLs or a trace, got %d", code)
+	}
+	if _, code := runSeekS3(t, append(flags, "-range", "5", "s3://bucket/a")...); code != 2 {
+		t.Fatalf("Expected exit code 2 for an invalid range, got %d", code)
+	}
+}
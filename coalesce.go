@@ -0,0 +1,175 @@
+package s3readerat
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// batch is a range to be read from S3 with one request on behalf of several reads, when Options.CoalesceWindow is set.
+// The read that opens it waits CoalesceWindow for others to join, extending its range, then reads it into a buffer of
+// its own, from which each read copies its bytes. A batch that comes within the gap of another as it grows absorbs it,
+// and the absorbed batch is served from the other's buffer.
+type batch struct {
+	first int64
+	last  int64
+	reads int
+	into  *batch
+
+	buf  []byte
+	n    int
+	err  error
+	done chan struct{}
+}
+
+// batches are the batches of an S3ReaderAt that reads may still join.
+type batches struct {
+	mu   sync.Mutex
+	open []*batch
+}
+
+// join adds the inclusive range [first, last] to an open batch whose range lies within gap bytes of it, returning the
+// batch and true, or else opens a new batch for it, which the caller leads, returning false.
+func (bs *batches) join(first int64, last int64, gap int64) (*batch, bool) {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+
+	for _, b := range bs.open {
+		if b.within(first, last, gap) {
+			b.extend(first, last)
+			b.reads++
+			bs.absorb(b, gap)
+			return b, true
+		}
+	}
+
+	b := &batch{first: first, last: last, reads: 1, done: make(chan struct{})}
+	bs.open = append(bs.open, b)
+	return b, false
+}
+
+// absorb merges into b the open batches that lie within gap bytes of it. bs.mu must be held.
+func (bs *batches) absorb(b *batch, gap int64) {
+	for absorbed := true; absorbed; {
+		absorbed = false
+		for i, other := range bs.open {
+			if other != b && b.within(other.first, other.last, gap) {
+				b.extend(other.first, other.last)
+				b.reads += other.reads
+				other.into = b
+				bs.open = append(bs.open[:i], bs.open[i+1:]...)
+				absorbed = true
+				break
+			}
+		}
+	}
+}
+
+// close stops b from accepting reads, returning its final range and the number of reads in it, or else the batch it
+// was absorbed into.
+func (bs *batches) close(b *batch) (int64, int64, int, *batch) {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+
+	if b.into != nil {
+		return 0, 0, 0, b.into
+	}
+	for i, other := range bs.open {
+		if other == b {
+			bs.open = append(bs.open[:i], bs.open[i+1:]...)
+			break
+		}
+	}
+	return b.first, b.last, b.reads, nil
+}
+
+// within reports whether the inclusive range [first, last] lies within gap bytes of b.
+func (b *batch) within(first int64, last int64, gap int64) bool {
+	return first <= b.last+gap+1 && b.first <= last+gap+1
+}
+
+// extend extends b to include the inclusive range [first, last].
+func (b *batch) extend(first int64, last int64) {
+	if first < b.first {
+		b.first = first
+	}
+	if last > b.last {
+		b.last = last
+	}
+}
+
+// readRangeCoalesced reads the inclusive byte range [first, last] into p with the reads that arrive within
+// Options.CoalesceWindow and lie within Options.CoalesceGap bytes of it, or of each other, issuing one request for
+// all of them. If the read that issues it was canceled by its caller, the others read their ranges as usual.
+func (ra *S3ReaderAt) readRangeCoalesced(ctx context.Context, p []byte, first int64, last int64) (int, error) {
+	t := ra.tuning()
+	b, joined := ra.batches.join(first, last, t.CoalesceGap)
+	if !joined {
+		timer := time.NewTimer(t.CoalesceWindow)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+		}
+
+		batchFirst, batchLast, reads, into := ra.batches.close(b)
+		if into != nil {
+			// Serve b's reads from the batch that absorbed it, which may in turn have been absorbed, once it is read.
+			go func() {
+				<-into.done
+				b.first, b.buf, b.n, b.err = into.first, into.buf, into.n, into.err
+				close(b.done)
+			}()
+			return ra.waitForBatch(ctx, b, p, first, last)
+		}
+		if reads > 1 && ra.debug() {
+			log.Printf("Coalesced %d reads of S3 object s3://%s/%s into bytes %d-%d", reads, ra.bucket, ra.key,
+				batchFirst, batchLast)
+		}
+
+		b.buf = make([]byte, batchLast-batchFirst+1)
+		b.n, b.err = ra.readRangeRetrying(ctx, b.buf, batchFirst, batchLast)
+		close(b.done)
+		return ra.copyFromBatch(ctx, b, p, first, last, false)
+	}
+
+	return ra.waitForBatch(ctx, b, p, first, last)
+}
+
+// waitForBatch waits for b, which the read of the inclusive range [first, last] joined, to be read, and copies the
+// read's bytes from it into p.
+func (ra *S3ReaderAt) waitForBatch(ctx context.Context, b *batch, p []byte, first int64, last int64) (int, error) {
+	select {
+	case <-b.done:
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	}
+	return ra.copyFromBatch(ctx, b, p, first, last, true)
+}
+
+// copyFromBatch copies the bytes of the inclusive range [first, last] into p from b once it has been read, recording a
+// cache hit if the read joined b rather than issuing its request. If b was canceled by the read that issued it, the
+// rest of the range is read as usual.
+func (ra *S3ReaderAt) copyFromBatch(ctx context.Context, b *batch, p []byte, first int64, last int64, joined bool) (
+	int, error) {
+	var n int
+	if off := int(first - b.first); off < b.n {
+		n = copy(p, b.buf[off:b.n])
+	}
+	if joined && n > 0 {
+		ra.recordCacheHit(ctx, p[:n], first)
+	}
+
+	leaderGaveUp := errors.Is(b.err, context.Canceled) || errors.Is(b.err, context.DeadlineExceeded)
+	if n == len(p) {
+		return n, nil
+	} else if !joined || !leaderGaveUp || ctx.Err() != nil {
+		return n, b.err
+	}
+
+	read, err := ra.readRangeRetrying(ctx, p[n:], first+int64(n), last)
+	return n + read, err
+}
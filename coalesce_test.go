@@ -0,0 +1,54 @@
+package s3readerat
+
+import (
+	"net/http"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestCoalesceWindow tests that reads arriving within CoalesceWindow of each other and within CoalesceGap bytes of each
+// other are served by one request, and that reads further apart are not.
+func TestCoalesceWindow(t *testing.T) {
+	f := newFakeS3(t)
+	f.put("bucket", "key", []byte("0123456789abcdefghij"))
+
+	s3ReaderAt, err := NewWithOptions(Options{Client: f.client(), Bucket: "bucket", Key: "key",
+		CoalesceWindow: 50 * time.Millisecond, CoalesceGap: 2})
+	if err != nil {
+		t.Fatalf("Error calling NewWithOptions: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	for _, read := range []struct {
+		off      int64
+		expected string
+	}{{0, "01"}, {4, "45"}, {8, "89"}, {15, "fg"}} {
+		wg.Add(1)
+		go func(off int64, expected string) {
+			defer wg.Done()
+			b := make([]byte, len(expected))
+			if n, err := s3ReaderAt.ReadAt(b, off); err != nil || string(b[:n]) != expected {
+				t.Errorf("Expected to read %q, got %q (%v)", expected, b[:n], err)
+			}
+		}(read.off, read.expected)
+	}
+	wg.Wait()
+
+	var ranges []string
+	f.mu.Lock()
+	for _, r := range f.requests {
+		if r.Method == http.MethodGet {
+			ranges = append(ranges, r.Header.Get("Range"))
+		}
+	}
+	f.mu.Unlock()
+	sort.Strings(ranges)
+	if len(ranges) != 2 || ranges[0] != "bytes=0-9" || ranges[1] != "bytes=15-16" {
+		t.Fatalf("Unexpected ranges: %v", ranges)
+	}
+	if stats := s3ReaderAt.Stats(); stats.CacheHits != 2 {
+		t.Fatalf("Expected 2 cache hits, got %d", stats.CacheHits)
+	}
+}
@@ -0,0 +1,29 @@
+package s3readerat
+
+import "github.com/pkg/errors"
+
+// ConcurrencyLimit bounds the number of requests in flight across every S3ReaderAt that shares it in its Options, as
+// MaxConcurrentRequests does for one, so that a process reading hundreds of objects at once neither runs out of file
+// descriptors nor is throttled by S3. As with MaxConcurrentRequests, requests that a caller is waiting for take
+// priority over prefetches, whichever S3ReaderAt makes them. It is safe for concurrent use.
+type ConcurrencyLimit struct {
+	limiter *limiter
+}
+
+// NewConcurrencyLimit returns a ConcurrencyLimit of n requests in flight at once.
+func NewConcurrencyLimit(n int) (*ConcurrencyLimit, error) {
+	if n <= 0 {
+		return nil, errors.Errorf("provided limit is invalid: %d", n)
+	}
+	return &ConcurrencyLimit{limiter: newLimiter(n)}, nil
+}
+
+// SetLimit changes the number of requests allowed in flight to n. Lowering it takes effect as requests in flight
+// finish, and raising it lets waiting requests start at once.
+func (l *ConcurrencyLimit) SetLimit(n int) error {
+	if n <= 0 {
+		return errors.Errorf("provided limit is invalid: %d", n)
+	}
+	l.limiter.resize(n)
+	return nil
+}
@@ -0,0 +1,142 @@
+package s3readerat
+
+import (
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestConcurrencyLimit tests that a ConcurrencyLimit bounds the requests in flight across the S3ReaderAts that share
+// it, and that it cannot be combined with MaxConcurrentRequests.
+func TestConcurrencyLimit(t *testing.T) {
+	f := newFakeS3(t)
+	f.put("bucket", "a", []byte("0123456789"))
+	f.put("bucket", "b", []byte("abcdefghij"))
+
+	limit, err := NewConcurrencyLimit(1)
+	if err != nil {
+		t.Fatalf("Error calling NewConcurrencyLimit: %v", err)
+	}
+
+	var mu sync.Mutex
+	inFlight, maxInFlight := 0, 0
+	f.hook = func(w http.ResponseWriter, r *http.Request) bool {
+		mu.Lock()
+		inFlight++
+		if inFlight > maxInFlight {
+			maxInFlight = inFlight
+		}
+		mu.Unlock()
+
+		time.Sleep(10 * time.Millisecond)
+
+		mu.Lock()
+		inFlight--
+		mu.Unlock()
+		return false
+	}
+
+	client := f.client()
+	var readers []*S3ReaderAt
+	for _, key := range []string{"a", "b"} {
+		s3ReaderAt, err := NewWithOptions(Options{Client: client, Bucket: "bucket", Key: key, ConcurrencyLimit: limit})
+		if err != nil {
+			t.Fatalf("Error calling NewWithOptions: %v", err)
+		}
+		readers = append(readers, s3ReaderAt)
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			b := make([]byte, 4)
+			_, err := readers[i%2].ReadAt(b, int64(i%4))
+			errs <- err
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("Error calling ReadAt: %v", err)
+		}
+	}
+
+	if maxInFlight != 1 {
+		t.Fatalf("Expected at most 1 request in flight, got %d", maxInFlight)
+	}
+
+	_, err = NewWithOptions(Options{Client: client, Bucket: "bucket", Key: "a", ConcurrencyLimit: limit,
+		MaxConcurrentRequests: 2})
+	if err == nil {
+		t.Fatalf("Expected an error combining ConcurrencyLimit and MaxConcurrentRequests")
+	}
+	if _, err = NewConcurrencyLimit(0); err == nil {
+		t.Fatalf("Expected an error for a limit of 0")
+	}
+}
+
+// TestMaxConcurrentRequestsPrioritizesForeground tests that, when the concurrency limit is reached, a ReadAt preempts
+// a PrefetchAt in flight, and that the prefetch is retried once the ReadAt completes.
+func TestMaxConcurrentRequestsPrioritizesForeground(t *testing.T) {
+	f := newFakeS3(t)
+	f.put("bucket", "key", []byte("0123456789"))
+	started := make(chan struct{})
+	var once sync.Once
+	f.hook = func(w http.ResponseWriter, r *http.Request) bool {
+		if r.Header.Get("Range") != "bytes=0-1" {
+			return false
+		}
+		blocked := false
+		once.Do(func() { blocked = true })
+		if !blocked {
+			return false
+		}
+		close(started)
+		<-r.Context().Done()
+		return true
+	}
+
+	size := int64(10)
+	s3ReaderAt, err := NewWithOptions(Options{
+		Client:                f.client(),
+		Bucket:                "bucket",
+		Key:                   "key",
+		Size:                  &size,
+		MaxConcurrentRequests: 1,
+	})
+	if err != nil {
+		t.Fatalf("Error calling NewWithOptions: %v", err)
+	}
+
+	prefetched := make(chan error, 1)
+	p := make([]byte, 2)
+	go func() {
+		_, err := s3ReaderAt.PrefetchAt(p, 0)
+		prefetched <- err
+	}()
+	<-started
+
+	b := make([]byte, 2)
+	if _, err = s3ReaderAt.ReadAt(b, 5); err != nil {
+		t.Fatalf("Error calling ReadAt: %v", err)
+	}
+	if string(b) != "56" {
+		t.Fatalf("Expected %q, got %q", "56", b)
+	}
+
+	if err = <-prefetched; err != nil {
+		t.Fatalf("Error calling PrefetchAt: %v", err)
+	}
+	if string(p) != "01" {
+		t.Fatalf("Expected %q, got %q", "01", p)
+	}
+
+	if count := f.requestCount(http.MethodGet); count != 3 {
+		t.Fatalf("Expected 3 GetObject requests, got %d", count)
+	}
+}
@@ -0,0 +1,321 @@
+package s3readerat
+
+import (
+	"context"
+	"hash"
+	"io"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// copyAttempts is the number of consecutive times copyRange requests data without receiving any before giving up.
+	copyAttempts = 3
+
+	// readIntoFilePartSize is the number of bytes ReadIntoFile fetches per request, unless the object was
+	// multipart-uploaded, until it has measured the network.
+	readIntoFilePartSize = 8 << 20
+
+	// readIntoFileConcurrency is the number of requests ReadIntoFile keeps in flight until it has measured the network.
+	readIntoFileConcurrency = 8
+)
+
+// CopyRange writes n bytes of the object starting at off to w, or the rest of the object if n is negative. It returns
+// the number of bytes written and the error, if any. If the object ends before n bytes are written, the error is
+// io.EOF.
+//
+// Unlike reading through ReadAt, CopyRange issues one GetObject request for the whole range and streams the response
+// body to w, bypassing any prefetched data. If the response body fails partway through, CopyRange resumes with a new
+// request from the last byte written, so w never sees a byte twice. It gives up after copyAttempts consecutive
+// requests that make no progress, or immediately if writing to w fails or the object changes.
+func (ra *S3ReaderAt) CopyRange(w io.Writer, off int64, n int64) (int64, error) {
+	end, err := ra.rangeEnd(off, n)
+	if err != nil {
+		return ra.audit(ra.ctx, "CopyRange", off, n, 0, err)
+	}
+
+	written, err := ra.copyRange(ra.ctx, w, off, end, nil)
+	if err == nil && n >= 0 && written < n {
+		err = io.EOF
+	}
+	return ra.audit(ra.ctx, "CopyRange", off, end-off, written, err)
+}
+
+// ReadIntoFile writes n bytes of the object starting at off to f, or the rest of the object if n is negative. The
+// bytes are written at offsets 0 through n-1 of f, whatever f's current offset. If the object ends before n bytes are
+// written, the error is io.EOF.
+//
+// ReadIntoFile splits the range into parts that are fetched concurrently and written to f with WriteAt as they
+// arrive, in any order, so no part is buffered in memory. The size of the parts and the number fetched at once are
+// tuned as the transfer proceeds: parts are sized to the round-trip time times the throughput of one connection, so
+// that the latency of each request is small compared with its transfer, and connections are added while each one's
+// throughput holds up, then removed once they start competing for bandwidth; see Options.TargetThroughput to aim for
+// a fixed rate instead. Each part resumes after a failed response body as CopyRange does. If any part fails, the
+// others are canceled; f may then contain some parts and not others. f is not truncated, so any existing bytes beyond
+// the range are left in place.
+//
+// If the object was multipart-uploaded, the range is split at the boundaries of the parts it was uploaded in instead.
+// Parts that lie wholly within the range and were uploaded with checksums are checked as they are written, failing
+// with ErrPartChecksumMismatch if they differ; only the concurrency is tuned. If the parts cannot be listed, tuned
+// parts are used.
+func (ra *S3ReaderAt) ReadIntoFile(ctx context.Context, f *os.File, off int64, n int64) error {
+	end, err := ra.rangeEnd(off, n)
+	if err != nil {
+		_, err = ra.audit(ctx, "ReadIntoFile", off, n, 0, err)
+		return err
+	}
+
+	// Parts may be written in any order, so the bytes read are only known if every part succeeds.
+	err = ra.readIntoFile(ctx, f, off, end, n)
+	var read int64
+	if err == nil || err == io.EOF {
+		read = end - off
+	}
+	_, err = ra.audit(ctx, "ReadIntoFile", off, end-off, read, err)
+	return err
+}
+
+// readIntoFile implements ReadIntoFile, given the end of the range.
+func (ra *S3ReaderAt) readIntoFile(ctx context.Context, f *os.File, off int64, end int64, n int64) error {
+	var err error
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	tuner := newTransferTuner(ra.tuning().TargetThroughput)
+	next := ra.readIntoFileChunks(ctx, tuner, off, end)
+	done := make(chan struct{})
+	inFlight := 0
+	var errOnce sync.Once
+	var firstErr error
+	for chunk, ok := next(); ok && ctx.Err() == nil; {
+		if inFlight >= tuner.maxInFlight() {
+			<-done
+			inFlight--
+			continue
+		}
+
+		inFlight++
+		go func(chunk readIntoFileChunk) {
+			defer func() { done <- struct{}{} }()
+
+			var w io.Writer = &offsetWriter{f: f, off: chunk.first - off}
+			var h hash.Hash
+			if chunk.part != nil {
+				h = chunk.part.newHash()
+			}
+			if h != nil {
+				w = io.MultiWriter(w, h)
+			}
+
+			_, err := ra.copyRange(ctx, w, chunk.first, chunk.end, tuner)
+			if err == nil && h != nil {
+				err = chunk.part.verify(h)
+			}
+			if err != nil {
+				errOnce.Do(func() {
+					firstErr = err
+					cancel()
+				})
+			}
+		}(chunk)
+		chunk, ok = next()
+	}
+	for ; inFlight > 0; inFlight-- {
+		<-done
+	}
+
+	if ra.debug() {
+		log.Printf("Read S3 object s3://%s/%s into %s with parts of %d bytes and %d requests in flight", ra.bucket,
+			ra.key, f.Name(), tuner.partSize(), tuner.maxInFlight())
+	}
+
+	if firstErr != nil {
+		return firstErr
+	}
+	if err = ctx.Err(); err != nil {
+		return err
+	}
+	if n >= 0 && end-off < n {
+		return io.EOF
+	}
+	return nil
+}
+
+// readIntoFileChunk is the range of bytes from first up to, but not including, end that ReadIntoFile fetches in one
+// request. part is set if the range is a whole part of a multipart-uploaded object.
+type readIntoFileChunk struct {
+	first int64
+	end   int64
+	part  *PartChecksum
+}
+
+// readIntoFileChunks returns a function that splits the bytes from off up to, but not including, end into chunks,
+// returning one per call until there are none left. Chunks fall at part boundaries if the object was
+// multipart-uploaded, and are otherwise sized by tuner as they are requested.
+func (ra *S3ReaderAt) readIntoFileChunks(ctx context.Context, tuner *transferTuner, off int64,
+	end int64) func() (readIntoFileChunk, bool) {
+	parts, err := ra.objectParts(ctx)
+	if err != nil && ra.debug() {
+		log.Printf("Unable to list parts of S3 object s3://%s/%s, so using tuned parts: %v", ra.bucket, ra.key, err)
+	}
+
+	if err == nil && len(parts) > 0 {
+		i := 0
+		return func() (readIntoFileChunk, bool) {
+			for ; i < len(parts); i++ {
+				part := &parts[i]
+				first, last := part.Offset, part.Offset+part.Size
+				if last <= off || first >= end {
+					continue
+				}
+				i++
+
+				chunk := readIntoFileChunk{first: first, end: last, part: part}
+				if first < off || last > end {
+					// Only part of the part is wanted, so its checksum cannot be checked.
+					chunk.part = nil
+					if chunk.first < off {
+						chunk.first = off
+					}
+					if chunk.end > end {
+						chunk.end = end
+					}
+				}
+				return chunk, true
+			}
+			return readIntoFileChunk{}, false
+		}
+	}
+
+	first := off
+	return func() (readIntoFileChunk, bool) {
+		if first >= end {
+			return readIntoFileChunk{}, false
+		}
+
+		last := first + tuner.partSize()
+		if last > end {
+			last = end
+		}
+		chunk := readIntoFileChunk{first: first, end: last}
+		first = last
+		return chunk, true
+	}
+}
+
+// rangeEnd validates off and returns the offset just past the last byte of the n bytes starting at off, clamped to the
+// size of the object. A negative n means the rest of the object.
+func (ra *S3ReaderAt) rangeEnd(off int64, n int64) (int64, error) {
+	if off < 0 {
+		return 0, ErrInvalidOffset
+	}
+
+	size, err := ra.Size()
+	if err != nil {
+		return 0, err
+	}
+
+	end := size
+	if off > end {
+		end = off
+	}
+	if n >= 0 && n < end-off {
+		end = off + n
+	}
+
+	return end, nil
+}
+
+// copyRange writes the bytes of the object from first up to, but not including, end to w, resuming from the last byte
+// written if a response body fails. It returns the number of bytes written. Each request is reported to tuner, if set.
+// The copy is one read for Options.MaxReadDuration and Options.MaxReadAttempts.
+func (ra *S3ReaderAt) copyRange(ctx context.Context, w io.Writer, first int64, end int64,
+	tuner *transferTuner) (int64, error) {
+	ctx, finish := ra.startReadBudget(ctx)
+	written, err := ra.copyRangeResuming(ctx, w, first, end, tuner)
+	return written, finish(err)
+}
+
+// copyRangeResuming implements copyRange within the read's budget.
+func (ra *S3ReaderAt) copyRangeResuming(ctx context.Context, w io.Writer, first int64, end int64,
+	tuner *transferTuner) (int64, error) {
+	var written int64
+	failures := 0
+	for first+written < end {
+		start := time.Now()
+		resp, err := ra.getRange(ctx, first+written, end-1)
+		if err != nil {
+			return written, err
+		}
+		ttfb := time.Since(start)
+
+		body := &copyBody{r: io.LimitReader(resp.Body, end-first-written)}
+		copied, err := io.Copy(w, body)
+		resp.Body.Close()
+		written += copied
+		if tuner != nil {
+			tuner.observe(copied, ttfb, time.Since(start))
+		}
+
+		if err != nil && body.err == nil {
+			// Writing to w failed, so there is nothing to resume.
+			return written, err
+		}
+		if first+written >= end {
+			break
+		}
+		if err == nil {
+			err = io.ErrUnexpectedEOF
+		}
+
+		if copied > 0 {
+			failures = 0
+		}
+		failures++
+		if failures >= copyAttempts {
+			return written, errors.Wrapf(err, "S3 GetObject response body failed %d times at offset %d", failures,
+				first+written)
+		}
+		if err = ra.tuning().RetryBudget.Spend(err); err != nil {
+			return written, err
+		}
+
+		if ra.debug() {
+			log.Printf("Resuming copy of S3 object s3://%s/%s from offset %d after error: %v", ra.bucket, ra.key,
+				first+written, err)
+		}
+	}
+
+	return written, nil
+}
+
+// copyBody records the error, if any, from reading a response body, so that copyRange can tell read errors, which are
+// worth resuming from, from write errors.
+type copyBody struct {
+	r   io.Reader
+	err error
+}
+
+func (b *copyBody) Read(p []byte) (int, error) {
+	n, err := b.r.Read(p)
+	if err != nil && err != io.EOF {
+		b.err = err
+	}
+	return n, err
+}
+
+// offsetWriter writes sequentially to f starting at off, using WriteAt so that several can share f.
+type offsetWriter struct {
+	f   *os.File
+	off int64
+}
+
+func (w *offsetWriter) Write(p []byte) (int, error) {
+	n, err := w.f.WriteAt(p, w.off)
+	w.off += int64(n)
+	return n, err
+}
@@ -0,0 +1,174 @@
+package s3readerat
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+// TestCopyRangeResumes tests that CopyRange resumes from the last byte written when a response body is truncated.
+func TestCopyRangeResumes(t *testing.T) {
+	f := newFakeS3(t)
+	f.put("bucket", "key", []byte("0123456789"))
+	f.hook = truncateGets(f, 2)
+
+	s3ReaderAt, err := NewWithOptions(Options{Client: f.client(), Bucket: "bucket", Key: "key"})
+	if err != nil {
+		t.Fatalf("Error calling NewWithOptions: %v", err)
+	}
+
+	var buf bytes.Buffer
+	n, err := s3ReaderAt.CopyRange(&buf, 1, -1)
+	if err != nil {
+		t.Fatalf("Error calling CopyRange: %v", err)
+	}
+	if n != 9 || buf.String() != "123456789" {
+		t.Fatalf("Expected to copy \"123456789\", got %q (n = %d)", buf.String(), n)
+	}
+	if count := f.requestCount(http.MethodGet); count != 3 {
+		t.Fatalf("Expected 3 GetObject requests, got %d", count)
+	}
+
+	buf.Reset()
+	n, err = s3ReaderAt.CopyRange(&buf, 8, 4)
+	if err != io.EOF {
+		t.Fatalf("Expected io.EOF copying past the end of the object, got %v", err)
+	}
+	if n != 2 || buf.String() != "89" {
+		t.Fatalf("Expected to copy \"89\", got %q (n = %d)", buf.String(), n)
+	}
+}
+
+// TestCopyRangeGivesUp tests that CopyRange fails once requests stop making progress.
+func TestCopyRangeGivesUp(t *testing.T) {
+	f := newFakeS3(t)
+	f.put("bucket", "key", []byte("0123456789"))
+	f.hook = truncateGets(f, -1)
+
+	s3ReaderAt, err := NewWithOptions(Options{Client: f.client(), Bucket: "bucket", Key: "key"})
+	if err != nil {
+		t.Fatalf("Error calling NewWithOptions: %v", err)
+	}
+
+	var buf bytes.Buffer
+	n, err := s3ReaderAt.CopyRange(&buf, 0, -1)
+	if err == nil {
+		t.Fatalf("Expected an error calling CopyRange")
+	}
+	if n != 9 || buf.String() != "012345678" {
+		t.Fatalf("Expected to copy \"012345678\" before giving up, got %q (n = %d)", buf.String(), n)
+	}
+}
+
+// TestReadIntoFile tests that ReadIntoFile writes a range spanning several parts to the start of a file.
+func TestReadIntoFile(t *testing.T) {
+	data := make([]byte, 2*readIntoFilePartSize+123)
+	for i := range data {
+		data[i] = byte(i % 251)
+	}
+
+	f := newFakeS3(t)
+	f.put("bucket", "key", data)
+
+	s3ReaderAt, err := NewWithOptions(Options{Client: f.client(), Bucket: "bucket", Key: "key"})
+	if err != nil {
+		t.Fatalf("Error calling NewWithOptions: %v", err)
+	}
+
+	file, err := ioutil.TempFile(t.TempDir(), "ReadIntoFile")
+	if err != nil {
+		t.Fatalf("Error creating temporary file: %v", err)
+	}
+	defer file.Close()
+
+	if err = s3ReaderAt.ReadIntoFile(context.Background(), file, 100, -1); err != nil {
+		t.Fatalf("Error calling ReadIntoFile: %v", err)
+	}
+
+	b, err := ioutil.ReadFile(file.Name())
+	if err != nil {
+		t.Fatalf("Error reading temporary file: %v", err)
+	}
+	if !bytes.Equal(b, data[100:]) {
+		t.Fatalf("File contents differ from the object")
+	}
+	if count := f.requestCount(http.MethodGet); count != 3 {
+		t.Fatalf("Expected 3 GetObject requests, got %d", count)
+	}
+
+	if err = s3ReaderAt.ReadIntoFile(context.Background(), file, int64(len(data))-1, 2); err != io.EOF {
+		t.Fatalf("Expected io.EOF reading past the end of the object, got %v", err)
+	}
+}
+
+// TestReadIntoFileMultipart tests that ReadIntoFile splits a multipart-uploaded object at its part boundaries, whether
+// or not the parts were uploaded with checksums, and that a part that does not match its checksum fails.
+func TestReadIntoFileMultipart(t *testing.T) {
+	data := make([]byte, 2500)
+	for i := range data {
+		data[i] = byte(i % 251)
+	}
+
+	for _, checksums := range []bool{true, false} {
+		f := newFakeS3(t)
+		f.putMultipart("bucket", "key", data, 1000, checksums)
+
+		s3ReaderAt, err := NewWithOptions(Options{Client: f.client(), Bucket: "bucket", Key: "key"})
+		if err != nil {
+			t.Fatalf("Error calling NewWithOptions: %v", err)
+		}
+
+		file, err := ioutil.TempFile(t.TempDir(), "ReadIntoFile")
+		if err != nil {
+			t.Fatalf("Error creating temporary file: %v", err)
+		}
+		defer file.Close()
+
+		if err = s3ReaderAt.ReadIntoFile(context.Background(), file, 500, -1); err != nil {
+			t.Fatalf("Error calling ReadIntoFile: %v", err)
+		}
+
+		b, err := ioutil.ReadFile(file.Name())
+		if err != nil {
+			t.Fatalf("Error reading temporary file: %v", err)
+		}
+		if !bytes.Equal(b, data[500:]) {
+			t.Fatalf("File contents differ from the object")
+		}
+
+		f.mu.Lock()
+		var ranges []string
+		for _, r := range f.requests {
+			if rng := r.Header.Get("Range"); rng != "" {
+				ranges = append(ranges, rng)
+			}
+		}
+		f.mu.Unlock()
+
+		sort.Strings(ranges)
+		expected := []string{"bytes=1000-1999", "bytes=2000-2499", "bytes=500-999"}
+		if strings.Join(ranges, ",") != strings.Join(expected, ",") {
+			t.Fatalf("Expected ranges %v, got %v", expected, ranges)
+		}
+
+		if !checksums {
+			continue
+		}
+
+		f.mu.Lock()
+		f.objects["bucket/key"].data[1500] ^= 0xff
+		f.mu.Unlock()
+
+		err = s3ReaderAt.ReadIntoFile(context.Background(), file, 0, -1)
+		if !errors.Is(err, ErrPartChecksumMismatch) {
+			t.Fatalf("Expected ErrPartChecksumMismatch, got %v", err)
+		}
+	}
+}
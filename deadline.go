@@ -0,0 +1,70 @@
+package s3readerat
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// transferEstimate predicts how long a GetObject request will take from the round-trip time and throughput of the
+// requests an S3ReaderAt has completed, so that speculative reads can be fitted to the deadline of the read that
+// prompted them.
+type transferEstimate struct {
+	mu         sync.Mutex
+	measured   bool
+	rtt        float64 // seconds
+	throughput float64 // bytes per second
+}
+
+// observe records a request that transferred n bytes, received its response headers after ttfb and finished after
+// total.
+func (e *transferEstimate) observe(n int64, ttfb time.Duration, total time.Duration) {
+	transfer := (total - ttfb).Seconds()
+	if n <= 0 || transfer <= 0 {
+		return
+	}
+	throughput := float64(n) / transfer
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if !e.measured {
+		e.rtt, e.throughput, e.measured = ttfb.Seconds(), throughput, true
+		return
+	}
+	e.rtt += tunedSmoothing * (ttfb.Seconds() - e.rtt)
+	e.throughput += tunedSmoothing * (throughput - e.throughput)
+}
+
+// bytesWithin returns the number of bytes a single request is expected to transfer within d, and whether any requests
+// have been measured to base the estimate on.
+func (e *transferEstimate) bytesWithin(d time.Duration) (int64, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if !e.measured {
+		return 0, false
+	}
+
+	n := (d.Seconds() - e.rtt) * e.throughput
+	if n < 0 {
+		return 0, true
+	}
+	return int64(n), true
+}
+
+// trimToDeadline returns the number of bytes, up to n, that a request made now is expected to transfer before ctx's
+// deadline. Speculative reads are skipped or shortened to fit, so that they never make a read overrun its deadline. If
+// ctx has no deadline, or no request has completed yet to estimate from, it returns n.
+func (ra *S3ReaderAt) trimToDeadline(ctx context.Context, n int64) int64 {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return n
+	}
+
+	fit, ok := ra.transfers.bytesWithin(time.Until(deadline))
+	if !ok || fit > n {
+		return n
+	}
+	return fit
+}
@@ -0,0 +1,60 @@
+package s3readerat
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// TestDeadlineAwarePrefetching tests that, given the throughput measured so far, prefetches that would miss the
+// deadline of their context are skipped, and that the head is trimmed to fit the deadline of the read that fetches it.
+func TestDeadlineAwarePrefetching(t *testing.T) {
+	f := newFakeS3(t)
+	data := bytes.Repeat([]byte("0123456789"), 1000)
+	f.put("bucket", "key", data)
+
+	size := int64(len(data))
+	s3ReaderAt, err := NewWithOptions(Options{Client: f.client(), Bucket: "bucket", Key: "key", Size: &size,
+		PrefetchHeadBytes: 8192})
+	if err != nil {
+		t.Fatalf("Error calling NewWithOptions: %v", err)
+	}
+	// Pretend that requests have been taking 10ms to respond and transferring 10,000 bytes per second.
+	s3ReaderAt.transfers.observe(10000, 10*time.Millisecond, 1010*time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	// Prefetches with a deadline they cannot meet are skipped without a request.
+	if _, err = s3ReaderAt.PrefetchAtContext(ctx, make([]byte, 8192), 1000); !errors.Is(err, ErrPrefetchCanceled) {
+		t.Fatalf("Expected ErrPrefetchCanceled, got %v", err)
+	}
+	if count := f.requestCount(http.MethodGet); count != 0 {
+		t.Fatalf("Expected no GetObject requests, got %d", count)
+	}
+
+	// The head is trimmed to the bytes expected within the deadline, but covers the read.
+	b := make([]byte, 100)
+	if _, err = s3ReaderAt.ReadAtContext(ctx, b, 0); err != nil {
+		t.Fatalf("Error calling ReadAtContext: %v", err)
+	}
+	if headLen := len(s3ReaderAt.head); headLen < len(b) || headLen >= 8192 {
+		t.Fatalf("Expected the head to be trimmed to between %d and 8192 bytes, got %d", len(b), headLen)
+	}
+
+	// Reads beyond the trimmed head are made directly.
+	requests := f.requestCount(http.MethodGet)
+	if _, err = s3ReaderAt.ReadAt(b, 8000); err != nil {
+		t.Fatalf("Error calling ReadAt: %v", err)
+	}
+	if string(b) != string(data[8000:8100]) {
+		t.Fatalf("Expected %q, got %q", data[8000:8100], b)
+	}
+	if count := f.requestCount(http.MethodGet); count != requests+1 {
+		t.Fatalf("Expected 1 more GetObject request, got %d", count-requests)
+	}
+}
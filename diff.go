@@ -0,0 +1,284 @@
+package s3readerat
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"sort"
+)
+
+const (
+	// DefaultDiffSamples is the number of ranges Diff samples by default.
+	DefaultDiffSamples = 16
+
+	// DefaultDiffSampleSize is the number of bytes per range Diff samples by default.
+	DefaultDiffSampleSize = 64 << 10
+)
+
+// DiffReason says how Diff found a region to differ.
+type DiffReason string
+
+const (
+	// DiffSize marks the bytes past the end of the smaller object.
+	DiffSize DiffReason = "size"
+
+	// DiffChecksum marks a part whose checksum differs between the objects.
+	DiffChecksum DiffReason = "checksum"
+
+	// DiffSample marks a sampled range whose bytes differ between the objects.
+	DiffSample DiffReason = "sample"
+)
+
+// DiffRegion is a range of bytes that differs between two objects.
+type DiffRegion struct {
+	Offset int64
+	Length int64
+	Reason DiffReason
+}
+
+// DiffOptions configures Diff.
+type DiffOptions struct {
+	// Samples is the number of ranges to compare where the objects have no comparable part checksums. The default is
+	// DefaultDiffSamples.
+	Samples int
+
+	// SampleSize is the number of bytes per sampled range. The default is DefaultDiffSampleSize.
+	SampleSize int64
+}
+
+// DiffResult is the outcome of Diff.
+type DiffResult struct {
+	SizeA int64
+	SizeB int64
+	ETagA string
+	ETagB string
+
+	// Regions are the regions found to differ, in order of offset. Adjacent regions with the same reason are merged.
+	Regions []DiffRegion
+
+	// ChecksumBytes is the number of bytes shown to be equal by matching part checksums.
+	ChecksumBytes int64
+
+	// SampledBytes is the number of bytes read from each object and compared.
+	SampledBytes int64
+}
+
+// Equal reports whether no differences were found. Unless Conclusive is also true, the objects may still differ
+// outside the ranges compared.
+func (r *DiffResult) Equal() bool {
+	return r.SizeA == r.SizeB && len(r.Regions) == 0
+}
+
+// Conclusive reports whether the result is certain: either a difference was found, the ETags match, or every byte was
+// compared by checksum or by sampling.
+func (r *DiffResult) Conclusive() bool {
+	if !r.Equal() {
+		return true
+	}
+	if r.ETagA != "" && r.ETagA == r.ETagB {
+		return true
+	}
+	return r.ChecksumBytes+r.SampledBytes >= r.SizeA
+}
+
+// Diff compares the objects read by a and b, which may be in different buckets, without downloading either in full.
+// Objects with equal sizes and ETags are taken to be identical without reading them. Otherwise, parts of
+// multipart-uploaded objects that share boundaries and were uploaded with the same kind of checksum are compared by
+// checksum, and the rest of the bytes the objects have in common are compared by sampling options.Samples evenly
+// spaced ranges. If the sampled ranges cover all of those bytes, the comparison is exact.
+func Diff(ctx context.Context, a *S3ReaderAt, b *S3ReaderAt, options DiffOptions) (*DiffResult, error) {
+	if options.Samples <= 0 {
+		options.Samples = DefaultDiffSamples
+	}
+
+	if options.SampleSize <= 0 {
+		options.SampleSize = DefaultDiffSampleSize
+	}
+
+	result := &DiffResult{}
+	var err error
+	if result.SizeA, err = a.Size(); err != nil {
+		return nil, err
+	}
+	if result.SizeB, err = b.Size(); err != nil {
+		return nil, err
+	}
+	if result.ETagA, err = a.ETag(); err != nil {
+		return nil, err
+	}
+	if result.ETagB, err = b.ETag(); err != nil {
+		return nil, err
+	}
+
+	if result.SizeA == result.SizeB && result.ETagA != "" && result.ETagA == result.ETagB {
+		return result, nil
+	}
+
+	common := result.SizeA
+	if result.SizeB < common {
+		common = result.SizeB
+	}
+	if result.SizeA != result.SizeB {
+		longer := result.SizeA
+		if result.SizeB > longer {
+			longer = result.SizeB
+		}
+		result.Regions = append(result.Regions, DiffRegion{Offset: common, Length: longer - common, Reason: DiffSize})
+	}
+
+	unchecked := diffChecksums(ctx, a, b, common, result)
+	if err = diffSamples(ctx, a, b, unchecked, options, result); err != nil {
+		return nil, err
+	}
+
+	sort.Slice(result.Regions, func(i, j int) bool { return result.Regions[i].Offset < result.Regions[j].Offset })
+	result.Regions = mergeDiffRegions(result.Regions)
+
+	return result, nil
+}
+
+// diffInterval is the range of bytes from first up to, but not including, end.
+type diffInterval struct {
+	first int64
+	end   int64
+}
+
+// diffChecksums compares the checksums of the parts a and b share within the first common bytes, recording the
+// results in result. It returns the intervals of those bytes that were not compared. Failing to list parts is not an
+// error, since sampling can compare the bytes instead.
+func diffChecksums(ctx context.Context, a *S3ReaderAt, b *S3ReaderAt, common int64,
+	result *DiffResult) []diffInterval {
+	if common == 0 {
+		return nil
+	}
+
+	partsA, err := a.objectParts(ctx)
+	if err == nil {
+		var partsB []PartChecksum
+		if partsB, err = b.objectParts(ctx); err == nil && len(partsA) > 0 && len(partsB) > 0 {
+			return compareParts(partsA, partsB, common, result)
+		}
+	}
+	if err != nil && (a.debug() || b.debug()) {
+		log.Printf("Unable to list parts of S3 objects to compare them, so sampling instead: %v", err)
+	}
+
+	return []diffInterval{{first: 0, end: common}}
+}
+
+// compareParts compares the checksums of the parts in partsA and partsB with the same offset, size and checksum
+// algorithm that lie within the first common bytes. It returns the intervals of those bytes not compared.
+func compareParts(partsA []PartChecksum, partsB []PartChecksum, common int64, result *DiffResult) []diffInterval {
+	byOffset := make(map[int64]PartChecksum, len(partsB))
+	for _, part := range partsB {
+		byOffset[part.Offset] = part
+	}
+
+	var unchecked []diffInterval
+	next := int64(0)
+	for _, partA := range partsA {
+		partB, ok := byOffset[partA.Offset]
+		if !ok || partA.Size != partB.Size || partA.Offset+partA.Size > common || partA.Checksum == "" ||
+			partA.Algorithm != partB.Algorithm {
+			continue
+		}
+
+		if partA.Offset > next {
+			unchecked = append(unchecked, diffInterval{first: next, end: partA.Offset})
+		}
+		next = partA.Offset + partA.Size
+
+		if partA.Checksum == partB.Checksum {
+			result.ChecksumBytes += partA.Size
+		} else {
+			result.Regions = append(result.Regions, DiffRegion{Offset: partA.Offset, Length: partA.Size,
+				Reason: DiffChecksum})
+		}
+	}
+	if next < common {
+		unchecked = append(unchecked, diffInterval{first: next, end: common})
+	}
+
+	return unchecked
+}
+
+// diffSamples compares evenly spaced ranges of a and b within intervals, recording the results in result. If the
+// samples can cover every byte of intervals, every byte is compared.
+func diffSamples(ctx context.Context, a *S3ReaderAt, b *S3ReaderAt, intervals []diffInterval, options DiffOptions,
+	result *DiffResult) error {
+	var total int64
+	for _, interval := range intervals {
+		total += interval.end - interval.first
+	}
+	if total == 0 {
+		return nil
+	}
+
+	var samples []diffInterval
+	if total <= int64(options.Samples)*options.SampleSize {
+		// The samples can cover every byte.
+		for _, interval := range intervals {
+			for off := interval.first; off < interval.end; off += options.SampleSize {
+				samples = append(samples, diffInterval{first: off, end: minInt64(off+options.SampleSize, interval.end)})
+			}
+		}
+	} else {
+		// Sample positions are spread over the intervals as if they were contiguous.
+		i, skipped := 0, int64(0)
+		for j := 0; j < options.Samples; j++ {
+			var pos int64
+			if options.Samples > 1 {
+				pos = int64(j) * (total - options.SampleSize) / int64(options.Samples-1)
+			}
+			for pos-skipped >= intervals[i].end-intervals[i].first {
+				skipped += intervals[i].end - intervals[i].first
+				i++
+			}
+
+			off := intervals[i].first + pos - skipped
+			samples = append(samples, diffInterval{first: off, end: minInt64(off+options.SampleSize, intervals[i].end)})
+		}
+	}
+
+	bufA := make([]byte, options.SampleSize)
+	bufB := make([]byte, options.SampleSize)
+	for _, sample := range samples {
+		off, n := sample.first, sample.end-sample.first
+		if _, err := a.readAt(ctx, bufA[:n], off); err != nil {
+			return err
+		}
+		if _, err := b.readAt(ctx, bufB[:n], off); err != nil {
+			return err
+		}
+		result.SampledBytes += n
+
+		if !bytes.Equal(bufA[:n], bufB[:n]) {
+			result.Regions = append(result.Regions, DiffRegion{Offset: off, Length: n, Reason: DiffSample})
+		}
+	}
+
+	return nil
+}
+
+// mergeDiffRegions merges adjacent regions with the same reason in regions, which must be sorted by offset.
+func mergeDiffRegions(regions []DiffRegion) []DiffRegion {
+	var merged []DiffRegion
+	for _, region := range regions {
+		last := len(merged) - 1
+		if last >= 0 && merged[last].Reason == region.Reason &&
+			merged[last].Offset+merged[last].Length == region.Offset {
+			merged[last].Length += region.Length
+			continue
+		}
+		merged = append(merged, region)
+	}
+
+	return merged
+}
+
+func minInt64(a int64, b int64) int64 {
+	if a < b {
+		return a
+	}
+	return b
+}
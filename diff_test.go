@@ -0,0 +1,82 @@
+package s3readerat
+
+import (
+	"context"
+	"net/http"
+	"testing"
+)
+
+// TestDiff tests that Diff finds differences in size, in part checksums and in sampled ranges, and that it only reads
+// ranges that part checksums do not cover.
+func TestDiff(t *testing.T) {
+	data := make([]byte, 3000)
+	for i := range data {
+		data[i] = byte(i % 251)
+	}
+	changed := append([]byte(nil), data...)
+	changed[1500] ^= 0xff
+
+	f := newFakeS3(t)
+	f.put("bucket", "a", data)
+	f.put("bucket", "b", changed)
+	f.put("bucket", "longer", append(append([]byte(nil), data...), "tail"...))
+	f.putMultipart("bucket", "multipart-a", data, 1000, true)
+	f.putMultipart("bucket", "multipart-b", changed, 1000, true)
+
+	open := func(key string) *S3ReaderAt {
+		s3ReaderAt, err := NewWithOptions(Options{Client: f.client(), Bucket: "bucket", Key: key})
+		if err != nil {
+			t.Fatalf("Error calling NewWithOptions: %v", err)
+		}
+		return s3ReaderAt
+	}
+
+	tests := []struct {
+		a, b       string
+		options    DiffOptions
+		expected   []DiffRegion
+		conclusive bool
+	}{
+		{"a", "a", DiffOptions{}, nil, true},
+		{"a", "b", DiffOptions{Samples: 3, SampleSize: 1000}, []DiffRegion{{1000, 1000, DiffSample}}, true},
+		{"a", "b", DiffOptions{Samples: 2, SampleSize: 100}, nil, false},
+		{"a", "longer", DiffOptions{Samples: 3, SampleSize: 1000}, []DiffRegion{{3000, 4, DiffSize}}, true},
+	}
+	for _, test := range tests {
+		result, err := Diff(context.Background(), open(test.a), open(test.b), test.options)
+		if err != nil {
+			t.Fatalf("Error calling Diff on %s and %s: %v", test.a, test.b, err)
+		}
+
+		if len(result.Regions) != len(test.expected) {
+			t.Fatalf("Expected regions %v comparing %s and %s, got %v", test.expected, test.a, test.b, result.Regions)
+		}
+		for i, region := range result.Regions {
+			if region != test.expected[i] {
+				t.Fatalf("Expected regions %v comparing %s and %s, got %v", test.expected, test.a, test.b,
+					result.Regions)
+			}
+		}
+		if result.Conclusive() != test.conclusive {
+			t.Fatalf("Expected Conclusive to be %v comparing %s and %s", test.conclusive, test.a, test.b)
+		}
+	}
+
+	// Multipart objects are compared by their part checksums alone.
+	f.mu.Lock()
+	f.requests = nil
+	f.mu.Unlock()
+
+	result, err := Diff(context.Background(), open("multipart-a"), open("multipart-b"), DiffOptions{})
+	if err != nil {
+		t.Fatalf("Error calling Diff on multipart objects: %v", err)
+	}
+	if len(result.Regions) != 1 || result.Regions[0] != (DiffRegion{1000, 1000, DiffChecksum}) {
+		t.Fatalf("Expected part 2 of the multipart objects to differ, got %v", result.Regions)
+	}
+	for _, r := range f.requests {
+		if r.Method == http.MethodGet && r.Header.Get("Range") != "" {
+			t.Fatalf("Expected no ranged GetObject requests comparing by checksum, got %s", r.Header.Get("Range"))
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package s3readerat
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/pkg/errors"
+)
+
+// clientSettings are the settings of Options that apply to the s3.Clients an S3ReaderAt makes in multi-region mode,
+// including those it makes to follow redirects to other regions.
+type clientSettings struct {
+	accelerate bool
+	dualstack  bool
+	fips       bool
+	endpoint   string
+	pathStyle  bool
+	anonymous  bool
+}
+
+// clientSettings returns the settings of options for the s3.Clients made from options.Options.
+func (options Options) clientSettings() clientSettings {
+	return clientSettings{
+		accelerate: options.UseAccelerate,
+		dualstack:  options.UseDualstack,
+		fips:       options.UseFIPS,
+		endpoint:   options.Endpoint,
+		pathStyle:  options.UsePathStyle,
+		anonymous:  options.Anonymous,
+	}
+}
+
+// apply sets the settings on o, leaving those already set on it.
+func (s clientSettings) apply(o *s3.Options) {
+	if s.accelerate {
+		o.UseAccelerate = true
+	}
+	if s.dualstack {
+		o.EndpointOptions.UseDualStackEndpoint = aws.DualStackEndpointStateEnabled
+	}
+	if s.fips {
+		o.EndpointOptions.UseFIPSEndpoint = aws.FIPSEndpointStateEnabled
+	}
+	if s.endpoint != "" {
+		// The resolver remembers the first region it resolves, so each s3.Client needs its own.
+		o.EndpointResolver = s3.EndpointResolverFromURL(s.endpoint)
+	}
+	if s.pathStyle {
+		o.UsePathStyle = true
+	}
+	if s.anonymous {
+		o.Credentials = aws.AnonymousCredentials{}
+	}
+}
+
+// validate returns an error if the settings cannot be used to read from options.Bucket with the client options names.
+func (s clientSettings) validate(options Options) error {
+	if s == (clientSettings{}) {
+		return nil
+	} else if options.Options == nil {
+		return errors.New("provided UseAccelerate, UseDualstack, UseFIPS, Endpoint, UsePathStyle and Anonymous " +
+			"require Options; set them in the s3.Options of the Client instead")
+	} else if s.endpoint != "" {
+		if u, err := url.Parse(s.endpoint); err != nil || u.Scheme == "" || u.Host == "" {
+			return errors.Errorf("provided Endpoint is invalid: %q", s.endpoint)
+		} else if s.accelerate || s.dualstack || s.fips {
+			return errors.New("provided Endpoint cannot be used with UseAccelerate, UseDualstack or UseFIPS")
+		}
+	}
+
+	if !s.accelerate {
+		return nil
+	} else if s.fips {
+		return errors.New("provided UseAccelerate cannot be used with UseFIPS")
+	} else if isAccessPoint(options.Bucket) || isDirectoryBucket(options.Bucket) ||
+		strings.Contains(options.Bucket, ".") {
+		return errors.Errorf("provided Bucket %q cannot be used with UseAccelerate", options.Bucket)
+	}
+
+	return nil
+}
@@ -0,0 +1,152 @@
+package s3readerat
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// TestUseAccelerate tests that UseAccelerate makes the requests of an S3ReaderAt, and of a Factory's listings, to
+// the Transfer Acceleration endpoint, and that it is rejected without Options.
+func TestUseAccelerate(t *testing.T) {
+	f := newFakeS3(t)
+	f.put("bucket", "key", []byte("0123456789"))
+	f.hook = func(w http.ResponseWriter, r *http.Request) bool {
+		r.URL.Path = "/bucket" + r.URL.Path
+		return false
+	}
+
+	// Resolve the real endpoints, over HTTP, since the accelerate endpoint replaces only them.
+	options := accessPointOptions(f)
+	options.EndpointResolver, options.UsePathStyle = nil, false
+	options.EndpointOptions.DisableHTTPS = true
+	factory := &Factory{Options: Options{Options: &options, Bucket: "bucket", UseAccelerate: true}}
+	if objects, err := factory.OpenPrefix(context.Background(), ""); err != nil || len(objects) != 1 {
+		t.Fatalf("Expected to list 1 object, got %d (%v)", len(objects), err)
+	}
+	s3ReaderAt, err := factory.Open("key")
+	if err != nil {
+		t.Fatalf("Error calling Open: %v", err)
+	}
+	b := make([]byte, 4)
+	if _, err = s3ReaderAt.ReadAt(b, 2); err != nil || string(b) != "2345" {
+		t.Fatalf("Expected to read %q, got %q (%v)", "2345", b, err)
+	}
+
+	f.mu.Lock()
+	for _, r := range f.requests {
+		if r.Host != "bucket.s3-accelerate.amazonaws.com" {
+			t.Fatalf("Expected every request to be made to the accelerate endpoint, got %s %s", r.Host, r.URL)
+		}
+	}
+	f.mu.Unlock()
+
+	for _, o := range []Options{
+		{Client: f.client(), Bucket: "bucket", Key: "key", UseAccelerate: true},
+		{Options: &options, Bucket: "my.bucket", Key: "key", UseAccelerate: true},
+		{Options: &options, Bucket: "bucket", Key: "key", UseAccelerate: true, UseFIPS: true},
+		{API: f.client(), Bucket: "bucket", Key: "key", UseDualstack: true},
+	} {
+		if _, err = NewWithOptions(o); err == nil || !strings.Contains(err.Error(), "UseAccelerate") {
+			t.Fatalf("Expected an error with UseAccelerate and %+v, got %v", o, err)
+		}
+	}
+}
+
+// TestUseDualstackAndFIPS tests that UseDualstack and UseFIPS apply to the s3.Client made in multi-region mode and to
+// the one made to follow a redirect to the bucket's region.
+func TestUseDualstackAndFIPS(t *testing.T) {
+	f := newFakeS3(t)
+	f.put("bucket", "key", []byte("0123456789"))
+	f.hook = func(w http.ResponseWriter, r *http.Request) bool {
+		if strings.Contains(r.Host, ".us-east-1.") {
+			w.Header().Set("X-Amz-Bucket-Region", "us-west-2")
+			w.WriteHeader(http.StatusMovedPermanently)
+			return true
+		}
+		r.URL.Path = "/bucket" + r.URL.Path
+		return false
+	}
+
+	options := accessPointOptions(f)
+	options.EndpointResolver, options.UsePathStyle = nil, false
+	options.EndpointOptions.DisableHTTPS = true
+	s3ReaderAt, err := NewWithOptions(Options{Options: &options, Bucket: "bucket", Key: "key", UseDualstack: true,
+		UseFIPS: true, RegionCache: NewRegionCache()})
+	if err != nil {
+		t.Fatalf("Error calling NewWithOptions: %v", err)
+	}
+	b := make([]byte, 4)
+	if _, err = s3ReaderAt.ReadAt(b, 2); err != nil || string(b) != "2345" {
+		t.Fatalf("Expected to read %q, got %q (%v)", "2345", b, err)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.requests) != 2 || f.requests[0].Host != "bucket.s3-fips.dualstack.us-east-1.amazonaws.com" ||
+		f.requests[1].Host != "bucket.s3-fips.dualstack.us-west-2.amazonaws.com" {
+		t.Fatalf("Expected a request to the FIPS dual-stack endpoint of each region, got %v", f.requests)
+	}
+}
+
+// TestEndpoint tests that Endpoint and UsePathStyle apply to the s3.Client made in multi-region mode and to the one
+// made to follow a redirect to the bucket's region.
+func TestEndpoint(t *testing.T) {
+	f := newFakeS3(t)
+	f.put("bucket", "key", []byte("0123456789"))
+	f.hook = func(w http.ResponseWriter, r *http.Request) bool {
+		if strings.Contains(r.Header.Get("Authorization"), "/us-east-1/") {
+			w.Header().Set("X-Amz-Bucket-Region", "us-west-2")
+			w.WriteHeader(http.StatusMovedPermanently)
+			return true
+		}
+		return false
+	}
+
+	options := f.options()
+	options.EndpointResolver, options.UsePathStyle = nil, false
+	s3ReaderAt, err := NewWithOptions(Options{Options: &options, Bucket: "bucket", Key: "key",
+		Endpoint: f.server.URL, UsePathStyle: true, RegionCache: NewRegionCache()})
+	if err != nil {
+		t.Fatalf("Error calling NewWithOptions: %v", err)
+	}
+	b := make([]byte, 4)
+	if _, err = s3ReaderAt.ReadAt(b, 2); err != nil || string(b) != "2345" {
+		t.Fatalf("Expected to read %q, got %q (%v)", "2345", b, err)
+	}
+	if count := f.requestCount(http.MethodGet); count != 2 {
+		t.Fatalf("Expected 2 GetObject requests, got %d", count)
+	}
+
+	for _, endpoint := range []string{"localhost:9000", "://"} {
+		_, err = NewWithOptions(Options{Options: &options, Bucket: "bucket", Key: "key", Endpoint: endpoint})
+		if err == nil {
+			t.Fatalf("Expected an error with Endpoint %q", endpoint)
+		}
+	}
+}
+
+// TestAnonymous tests that Anonymous makes requests unsigned, whatever the credentials of Options.
+func TestAnonymous(t *testing.T) {
+	f := newFakeS3(t)
+	f.put("bucket", "key", []byte("0123456789"))
+
+	options := f.options()
+	s3ReaderAt, err := NewWithOptions(Options{Options: &options, Bucket: "bucket", Key: "key", Anonymous: true})
+	if err != nil {
+		t.Fatalf("Error calling NewWithOptions: %v", err)
+	}
+	b := make([]byte, 4)
+	if _, err = s3ReaderAt.ReadAt(b, 2); err != nil || string(b) != "2345" {
+		t.Fatalf("Expected to read %q, got %q (%v)", "2345", b, err)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, r := range f.requests {
+		if r.Header.Get("Authorization") != "" {
+			t.Fatalf("Expected every request to be unsigned, got %s", r.Header.Get("Authorization"))
+		}
+	}
+}
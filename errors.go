@@ -0,0 +1,97 @@
+package s3readerat
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/aws/smithy-go"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrNotFound is returned when S3 reports that the object, its version or its bucket does not exist.
+	ErrNotFound = errors.New("S3 object not found")
+
+	// ErrAccessDenied is returned when S3 refuses a request for lack of permission, such as when the credentials
+	// cannot read the object or the bucket policy forbids it.
+	ErrAccessDenied = errors.New("S3 access denied")
+
+	// ErrRestoreRequired is returned when the object is archived in the S3 Glacier Flexible Retrieval or Deep Archive
+	// storage classes, or the archive tiers of S3 Intelligent-Tiering, and must be restored before it can be read.
+	ErrRestoreRequired = errors.New("S3 object must be restored before it can be read")
+
+	// ErrThrottled is returned when S3 asks for requests to slow down, once any retries have been exhausted.
+	ErrThrottled = errors.New("S3 requests throttled")
+)
+
+// S3Error is returned when S3 refuses a request for one of the reasons above. It matches ErrNotFound,
+// ErrAccessDenied, ErrRestoreRequired or ErrThrottled with errors.Is, and wraps the client's error, so errors.As still
+// finds the *smithyhttp.ResponseError and smithy.APIError within it.
+type S3Error struct {
+	// Kind is the sentinel error the failure matches, such as ErrNotFound.
+	Kind error
+
+	// StatusCode is the HTTP status code of S3's response.
+	StatusCode int
+
+	// Code is S3's error code, such as NoSuchKey. Responses to HeadObject requests have no body, so it is only the
+	// status text for them, such as NotFound.
+	Code string
+
+	// Err is the error returned by the client.
+	Err error
+}
+
+func (e *S3Error) Error() string {
+	return fmt.Sprintf("%v: %v", e.Kind, e.Err)
+}
+
+// Is reports whether target is the sentinel error e matches.
+func (e *S3Error) Is(target error) bool {
+	return target == e.Kind
+}
+
+// Unwrap returns the error returned by the client.
+func (e *S3Error) Unwrap() error {
+	return e.Err
+}
+
+// classifyError returns err wrapped in an *S3Error if it is a response from S3 for one of the reasons an S3Error
+// describes. Other errors are returned unchanged.
+func classifyError(err error) error {
+	var responseError *smithyhttp.ResponseError
+	if !errors.As(err, &responseError) {
+		return err
+	}
+
+	var code string
+	var apiError smithy.APIError
+	if errors.As(err, &apiError) {
+		code = apiError.ErrorCode()
+	}
+
+	var kind error
+	status := responseError.HTTPStatusCode()
+	switch {
+	case code == "InvalidObjectState":
+		kind = ErrRestoreRequired
+	case status == http.StatusNotFound:
+		kind = ErrNotFound
+	case status == http.StatusForbidden:
+		kind = ErrAccessDenied
+	case code == "SlowDown" || status == http.StatusServiceUnavailable || status == http.StatusTooManyRequests:
+		kind = ErrThrottled
+	default:
+		return err
+	}
+
+	return &S3Error{Kind: kind, StatusCode: status, Code: code, Err: err}
+}
+
+// isRangeNotSatisfiable reports whether err is S3's response to a range request that starts past the end of the
+// object.
+func isRangeNotSatisfiable(err error) bool {
+	var responseError *smithyhttp.ResponseError
+	return errors.As(err, &responseError) && responseError.HTTPStatusCode() == http.StatusRequestedRangeNotSatisfiable
+}
@@ -0,0 +1,67 @@
+package s3readerat
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+	"github.com/pkg/errors"
+)
+
+// TestS3Errors tests that S3's refusals are reported as S3Errors matching the sentinel errors, which still wrap the
+// client's errors.
+func TestS3Errors(t *testing.T) {
+	f := newFakeS3(t)
+	f.put("bucket", "key", []byte("0123456789"))
+
+	ra, err := NewWithOptions(Options{Client: f.client(), Bucket: "bucket", Key: "missing"})
+	if err != nil {
+		t.Fatalf("Error calling NewWithOptions: %v", err)
+	}
+	if _, err = ra.Size(); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Expected ErrNotFound statting a missing object, got %v", err)
+	}
+
+	ra, err = NewWithOptions(Options{Client: f.client(), Bucket: "bucket", Key: "missing", Size: aws.Int64(10)})
+	if err != nil {
+		t.Fatalf("Error calling NewWithOptions: %v", err)
+	}
+	_, err = ra.ReadAt(make([]byte, 4), 0)
+	var s3Error *S3Error
+	if !errors.As(err, &s3Error) || s3Error.Kind != ErrNotFound || s3Error.Code != "NoSuchKey" ||
+		s3Error.StatusCode != http.StatusNotFound {
+		t.Fatalf("Expected an S3Error for NoSuchKey reading a missing object, got %v", err)
+	}
+	var responseError *smithyhttp.ResponseError
+	if !errors.As(err, &responseError) {
+		t.Fatalf("Expected the S3Error to wrap the client's error, got %v", err)
+	}
+
+	for _, test := range []struct {
+		code string
+		want error
+	}{{"InvalidObjectState", ErrRestoreRequired}, {"AccessDenied", ErrAccessDenied}} {
+		code, want := test.code, test.want
+		f.mu.Lock()
+		f.hook = func(w http.ResponseWriter, r *http.Request) bool {
+			writeFakeError(w, http.StatusForbidden, code, true)
+			return true
+		}
+		f.mu.Unlock()
+
+		ra, err = NewWithOptions(Options{Client: f.client(), Bucket: "bucket", Key: "key", Size: aws.Int64(10)})
+		if err != nil {
+			t.Fatalf("Error calling NewWithOptions: %v", err)
+		}
+		if _, err = ra.ReadAt(make([]byte, 4), 0); !errors.Is(err, want) {
+			t.Fatalf("Expected %v for %s, got %v", want, code, err)
+		}
+	}
+
+	factory := &Factory{Options: Options{Client: f.client(), Bucket: "bucket"}}
+	if _, err = factory.OpenPrefix(context.Background(), ""); !errors.Is(err, ErrAccessDenied) {
+		t.Fatalf("Expected ErrAccessDenied listing a prefix, got %v", err)
+	}
+}
@@ -0,0 +1,224 @@
+package s3readerat
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"log"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// maxUploadParts is the most parts S3 allows in a multipart upload.
+const maxUploadParts = 10000
+
+// commonPartSizes are the part sizes used by popular S3 clients by default, most common first: 8 MiB by the AWS CLI
+// and boto3, 5 MiB, the minimum, by the AWS SDKs for Go and Java and by rclone, 16 MiB by minio-go and 15 MiB by
+// s3cmd.
+var commonPartSizes = []int64{8 << 20, 5 << 20, 16 << 20, 15 << 20}
+
+// MultipartETag returns the ETag S3 gives an object of size bytes, read from r, that was multipart-uploaded in parts
+// of partSize bytes: the MD5 hash of the MD5 hashes of the parts, followed by "-" and the number of parts, in quotes,
+// as returned by S3ReaderAt.ETag. Objects uploaded in one PutObject request instead have the MD5 hash of their bytes
+// as their ETag, and objects encrypted with SSE-KMS or SSE-C have ETags that cannot be computed locally at all.
+func MultipartETag(r io.ReaderAt, size int64, partSize int64) (string, error) {
+	if size < 0 {
+		return "", errors.Errorf("provided size is invalid: %d", size)
+	} else if partSize <= 0 {
+		return "", errors.Errorf("provided part size is invalid: %d", partSize)
+	}
+
+	etags, err := computeETags(r, size, [][]int64{uniformPartSizes(size, partSize)})
+	if err != nil {
+		return "", err
+	}
+
+	return etags[0], nil
+}
+
+// LikelyPartSizes returns the part sizes, most likely first, that an object of size bytes with the given ETag may
+// have been multipart-uploaded in. It returns nil if the ETag is not a multipart ETag. The candidates are the defaults
+// of popular clients, powers of two, and the sizes that clients choosing a part size to fit a number of parts would
+// use, each kept only if it gives the number of parts the ETag names. Try each with MultipartETag, or use
+// S3ReaderAt.LocalCopyMatches, which learns the actual part size from S3.
+func LikelyPartSizes(etag string, size int64) []int64 {
+	count := int64(multipartPartsCount(etag))
+	if count == 0 || size < count {
+		return nil
+	}
+
+	candidates := append([]int64(nil), commonPartSizes...)
+	for partSize := int64(1 << 20); partSize <= 5<<30; partSize <<= 1 {
+		candidates = append(candidates, partSize)
+	}
+	fit := (size + count - 1) / count
+	const mib = 1 << 20
+	candidates = append(candidates, (fit+mib-1)/mib*mib, fit)
+
+	var partSizes []int64
+	seen := make(map[int64]bool)
+	for _, partSize := range candidates {
+		if seen[partSize] || (size+partSize-1)/partSize != count {
+			continue
+		}
+		seen[partSize] = true
+		partSizes = append(partSizes, partSize)
+	}
+
+	return partSizes
+}
+
+// LocalCopyMatches reports whether r, a local copy of size bytes, has the same bytes as the object, by comparing
+// their ETags, without reading the object. If the object was multipart-uploaded, its part sizes are learned as for
+// PartsChecksums, or guessed with LikelyPartSizes if that fails. Objects whose ETags are not MD5 hashes, such as
+// those encrypted with SSE-KMS, never match. The local copy is read once, however many part sizes are tried.
+func (ra *S3ReaderAt) LocalCopyMatches(r io.ReaderAt, size int64) (bool, error) {
+	remoteSize, err := ra.Size()
+	if err != nil {
+		return false, err
+	}
+	if size != remoteSize {
+		return false, nil
+	}
+
+	etag, err := ra.ETag()
+	if err != nil {
+		return false, err
+	}
+
+	var layouts [][]int64
+	if multipartPartsCount(etag) == 0 {
+		layouts = [][]int64{nil}
+	} else if parts, err := ra.objectParts(ra.ctx); err == nil {
+		sizes := make([]int64, len(parts))
+		for i, part := range parts {
+			sizes[i] = part.Size
+		}
+		layouts = [][]int64{sizes}
+	} else {
+		if ra.debug() {
+			log.Printf("Unable to list parts of S3 object s3://%s/%s, so guessing part sizes: %v", ra.bucket, ra.key,
+				err)
+		}
+		for _, partSize := range LikelyPartSizes(etag, size) {
+			layouts = append(layouts, uniformPartSizes(size, partSize))
+		}
+	}
+	if len(layouts) == 0 {
+		return false, nil
+	}
+
+	etags, err := computeETags(r, size, layouts)
+	if err != nil {
+		return false, err
+	}
+
+	for _, candidate := range etags {
+		if strings.EqualFold(strings.Trim(candidate, `"`), strings.Trim(etag, `"`)) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// uniformPartSizes returns the sizes of the parts of partSize bytes, except perhaps the last, that size bytes are
+// uploaded in.
+func uniformPartSizes(size int64, partSize int64) []int64 {
+	var sizes []int64
+	for off := int64(0); off < size || len(sizes) == 0; off += partSize {
+		n := partSize
+		if n > size-off {
+			n = size - off
+		}
+		sizes = append(sizes, n)
+	}
+
+	return sizes
+}
+
+// etagLayout computes the ETag of one way of splitting an object into parts.
+type etagLayout struct {
+	// partSizes are the sizes of the parts, or nil for an object uploaded in one PutObject request.
+	partSizes []int64
+	part      int
+	remaining int64
+	h         hash.Hash
+	sums      []byte
+}
+
+// computeETags reads size bytes from r once and returns the ETag of each layout, a list of part sizes summing to size,
+// or nil for an object uploaded in one PutObject request.
+func computeETags(r io.ReaderAt, size int64, layouts [][]int64) ([]string, error) {
+	states := make([]*etagLayout, len(layouts))
+	for i, partSizes := range layouts {
+		if len(partSizes) > maxUploadParts {
+			return nil, errors.Errorf("%d parts is more than S3 allows", len(partSizes))
+		}
+		states[i] = &etagLayout{partSizes: partSizes, remaining: size, h: md5.New()}
+		if partSizes != nil {
+			states[i].remaining = partSizes[0]
+		}
+	}
+
+	buf := make([]byte, 1<<20)
+	sr := io.NewSectionReader(r, 0, size)
+	for {
+		n, err := io.ReadFull(sr, buf)
+		for _, state := range states {
+			state.write(buf[:n])
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+	}
+	if read, _ := sr.Seek(0, io.SeekCurrent); read != size {
+		return nil, errors.Wrapf(io.ErrUnexpectedEOF, "local copy ended after %d of %d bytes", read, size)
+	}
+
+	etags := make([]string, len(states))
+	for i, state := range states {
+		etags[i] = state.etag()
+	}
+
+	return etags, nil
+}
+
+// write hashes p, moving on to the next part at each part boundary.
+func (l *etagLayout) write(p []byte) {
+	for len(p) > 0 {
+		n := int64(len(p))
+		if n > l.remaining {
+			n = l.remaining
+		}
+		if n == 0 {
+			// The layout accounts for fewer bytes than were read, so its ETag will not match.
+			return
+		}
+		l.h.Write(p[:n])
+		p = p[n:]
+		l.remaining -= n
+
+		if l.remaining == 0 && l.partSizes != nil && l.part+1 < len(l.partSizes) {
+			l.sums = l.h.Sum(l.sums)
+			l.h.Reset()
+			l.part++
+			l.remaining = l.partSizes[l.part]
+		}
+	}
+}
+
+// etag returns the ETag once every part has been written.
+func (l *etagLayout) etag() string {
+	if l.partSizes == nil {
+		return fmt.Sprintf(`"%s"`, hex.EncodeToString(l.h.Sum(nil)))
+	}
+
+	sum := md5.Sum(l.h.Sum(l.sums))
+	return fmt.Sprintf(`"%s-%d"`, hex.EncodeToString(sum[:]), len(l.partSizes))
+}
@@ -0,0 +1,87 @@
+package s3readerat
+
+import (
+	"bytes"
+	"crypto/md5"
+	"fmt"
+	"testing"
+)
+
+// TestMultipartETag tests that MultipartETag computes the MD5 hash of the parts' MD5 hashes, and that LikelyPartSizes
+// only suggests part sizes that give the number of parts an ETag names.
+func TestMultipartETag(t *testing.T) {
+	data := []byte("0123456789abcdefghij")
+
+	var sums []byte
+	for _, part := range [][]byte{data[:8], data[8:16], data[16:]} {
+		sum := md5.Sum(part)
+		sums = append(sums, sum[:]...)
+	}
+	want := fmt.Sprintf(`"%x-3"`, md5.Sum(sums))
+
+	etag, err := MultipartETag(bytes.NewReader(data), int64(len(data)), 8)
+	if err != nil {
+		t.Fatalf("Error calling MultipartETag: %v", err)
+	}
+	if etag != want {
+		t.Fatalf("Expected ETag %s, got %s", want, etag)
+	}
+
+	if _, err = MultipartETag(bytes.NewReader(data), int64(len(data))+1, 8); err == nil {
+		t.Fatalf("Expected an error computing the ETag of a short local copy")
+	}
+	if _, err = MultipartETag(bytes.NewReader(data), int64(len(data)), 0); err == nil {
+		t.Fatalf("Expected an error computing the ETag with an invalid part size")
+	}
+
+	partSizes := LikelyPartSizes(`"abc-3"`, 20<<20)
+	if len(partSizes) == 0 || partSizes[0] != 8<<20 {
+		t.Fatalf("Expected 8 MiB parts to be most likely, got %v", partSizes)
+	}
+	for _, partSize := range partSizes {
+		if count := (20<<20 + partSize - 1) / partSize; count != 3 {
+			t.Fatalf("Expected only part sizes giving 3 parts, got %d bytes giving %d", partSize, count)
+		}
+	}
+	if partSizes = LikelyPartSizes(`"abc"`, 20<<20); partSizes != nil {
+		t.Fatalf("Expected no part sizes for an ETag that is not a multipart ETag, got %v", partSizes)
+	}
+}
+
+// TestLocalCopyMatches tests that LocalCopyMatches compares a local copy with a multipart-uploaded object, using the
+// part size learned from S3, and with an object uploaded in one request.
+func TestLocalCopyMatches(t *testing.T) {
+	f := newFakeS3(t)
+	data := bytes.Repeat([]byte("0123456789"), 10)
+	f.putMultipart("bucket", "multipart", data, 30, false)
+	f.put("bucket", "single", data)
+
+	etag, err := MultipartETag(bytes.NewReader(data), int64(len(data)), 30)
+	if err != nil {
+		t.Fatalf("Error calling MultipartETag: %v", err)
+	}
+	f.mu.Lock()
+	f.objects["bucket/multipart"].etag = etag
+	f.objects["bucket/single"].etag = fmt.Sprintf(`"%x"`, md5.Sum(data))
+	f.mu.Unlock()
+
+	for _, key := range []string{"multipart", "single"} {
+		s3ReaderAt, err := NewWithOptions(Options{Client: f.client(), Bucket: "bucket", Key: key})
+		if err != nil {
+			t.Fatalf("Error calling NewWithOptions: %v", err)
+		}
+
+		if ok, err := s3ReaderAt.LocalCopyMatches(bytes.NewReader(data), int64(len(data))); err != nil || !ok {
+			t.Fatalf("Expected the local copy of %s to match, got %v (%v)", key, ok, err)
+		}
+
+		changed := append([]byte("x"), data[1:]...)
+		if ok, err := s3ReaderAt.LocalCopyMatches(bytes.NewReader(changed), int64(len(data))); err != nil || ok {
+			t.Fatalf("Expected a changed local copy of %s not to match, got %v (%v)", key, ok, err)
+		}
+
+		if ok, err := s3ReaderAt.LocalCopyMatches(bytes.NewReader(data[1:]), int64(len(data)-1)); err != nil || ok {
+			t.Fatalf("Expected a shorter local copy of %s not to match, got %v (%v)", key, ok, err)
+		}
+	}
+}
@@ -0,0 +1,33 @@
+package s3readerat
+
+import (
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/pkg/errors"
+)
+
+// isDirectoryBucket reports whether bucket is the name of an S3 Express One Zone directory bucket, such as
+// bucket--usw2-az1--x-s3. Their listings are not in key order.
+func isDirectoryBucket(bucket string) bool {
+	return strings.HasSuffix(bucket, "--x-s3")
+}
+
+// checkDirectoryBucket returns an error if options name a directory bucket that they cannot read from: with an
+// s3.Client, since package s3 v1.26.0, which this module requires, does not route requests to their zonal endpoints or
+// authenticate them with sessions, or with options they do not support.
+func checkDirectoryBucket(options Options) error {
+	if !isDirectoryBucket(options.Bucket) {
+		return nil
+	}
+
+	if _, ok := options.API.(*s3.Client); options.Client != nil || options.Options != nil || ok {
+		return errors.Errorf("provided Bucket %q is a directory bucket, which an s3.Client does not support; "+
+			"supply a client that does as Options.API", options.Bucket)
+	} else if options.VersionID != "" || options.RequestPayer != "" {
+		return errors.Errorf("provided Bucket %q is a directory bucket, which does not support VersionID or "+
+			"RequestPayer", options.Bucket)
+	}
+
+	return nil
+}
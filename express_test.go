@@ -0,0 +1,60 @@
+package s3readerat
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// reversedLister lists objects in reverse key order, as directory buckets may.
+type reversedLister struct {
+	s3.ListObjectsV2APIClient
+}
+
+func (l reversedLister) ListObjectsV2(ctx context.Context, input *s3.ListObjectsV2Input,
+	optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+	resp, err := l.ListObjectsV2APIClient.ListObjectsV2(ctx, input, optFns...)
+	if err == nil {
+		for i, j := 0, len(resp.Contents)-1; i < j; i, j = i+1, j-1 {
+			resp.Contents[i], resp.Contents[j] = resp.Contents[j], resp.Contents[i]
+		}
+	}
+	return resp, err
+}
+
+// TestDirectoryBucket tests that directory buckets are rejected with an s3.Client and with VersionID, are readable
+// through an API, and that OpenPrefix sorts their listings.
+func TestDirectoryBucket(t *testing.T) {
+	const bucket = "bucket--usw2-az1--x-s3"
+	f := newFakeS3(t)
+	f.put(bucket, "a", []byte("aaaa"))
+	f.put(bucket, "b", []byte("bb"))
+	f.put(bucket, "c", []byte("c"))
+
+	if _, err := NewWithOptions(Options{Client: f.client(), Bucket: bucket, Key: "a"}); err == nil {
+		t.Fatalf("Expected an error opening a directory bucket with an s3.Client")
+	}
+	api := struct{ API }{f.client()}
+	if _, err := NewWithOptions(Options{API: api, Bucket: bucket, Key: "a", VersionID: "v"}); err == nil {
+		t.Fatalf("Expected an error opening a directory bucket with a VersionID")
+	}
+
+	ra, err := NewWithOptions(Options{API: api, Bucket: bucket, Key: "a"})
+	if err != nil {
+		t.Fatalf("Error calling NewWithOptions: %v", err)
+	}
+	b := make([]byte, 4)
+	if _, err = ra.ReadAt(b, 0); err != nil || string(b) != "aaaa" {
+		t.Fatalf("Expected to read %q, got %q (%v)", "aaaa", b, err)
+	}
+
+	factory := &Factory{Options: Options{API: api, Bucket: bucket}, Lister: reversedLister{f.client()}}
+	objects, err := factory.OpenPrefix(context.Background(), "")
+	if err != nil {
+		t.Fatalf("Error calling OpenPrefix: %v", err)
+	}
+	if len(objects) != 3 || objects[0].Key != "a" || objects[1].Key != "b" || objects[2].Key != "c" {
+		t.Fatalf("Expected objects sorted by key, got %+v", objects)
+	}
+}
@@ -0,0 +1,200 @@
+package s3readerat
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/pkg/errors"
+)
+
+// Factory opens S3ReaderAts for the objects of one bucket with shared Options, such as a client, a RetryBudget and
+// an AuditLog.
+type Factory struct {
+	// Options configures each S3ReaderAt opened. Its Bucket names the bucket, and its Client, Options or API the client
+	// to read with. Key and Size are set for each object.
+	Options Options
+
+	// Lister lists the objects for OpenPrefix. The default is Options.Client or, in multi-region mode, an s3.Client
+	// made from Options.Options. It is required with Options.API.
+	Lister s3.ListObjectsV2APIClient
+}
+
+// Open opens the object with the given key, as NewWithOptions does.
+func (f *Factory) Open(key string) (*S3ReaderAt, error) {
+	options := f.Options
+	options.Key = key
+	return NewWithOptions(options)
+}
+
+// PrefixObject is an object listed by OpenPrefix. Its S3ReaderAt is opened the first time Open is called.
+type PrefixObject struct {
+	Key          string
+	Size         int64
+	ETag         string
+	LastModified time.Time
+
+	factory *Factory
+	once    sync.Once
+	ra      *S3ReaderAt
+	err     error
+}
+
+// Open returns the object's S3ReaderAt, opening it the first time it is called. The S3ReaderAt takes the object's
+// size and ETag from the listing, so it makes no HeadObject request, and its reads fail with ErrObjectChanged if the
+// object was overwritten since it was listed. It is safe for concurrent use.
+func (o *PrefixObject) Open() (*S3ReaderAt, error) {
+	o.once.Do(func() {
+		options := o.factory.Options
+		options.Key = o.Key
+		options.Size = aws.Int64(o.Size)
+
+		if o.ra, o.err = NewWithOptions(options); o.err == nil {
+			o.err = o.ra.checkETag(o.ETag)
+		}
+	})
+
+	return o.ra, o.err
+}
+
+// OpenPrefix lists the objects whose keys start with prefix, in key order, returning one PrefixObject for each. No
+// object is opened until its Open method is called, so a batch job can list a prefix once and then process every
+// object under it without a HeadObject request per object. Keys ending in a slash, which S3 consoles create as folder
+// markers, are skipped. The listings of directory buckets are not in key order, so they are sorted.
+func (f *Factory) OpenPrefix(ctx context.Context, prefix string) ([]*PrefixObject, error) {
+	if f.Options.Bucket == "" {
+		return nil, errors.New("a Bucket is required")
+	}
+
+	next, err := newObjectLister(f.Options, f.Lister, prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	var objects []*PrefixObject
+	for {
+		object, ok, err := next(ctx)
+		if err != nil {
+			return nil, err
+		} else if !ok {
+			break
+		}
+
+		key := aws.ToString(object.Key)
+		if strings.HasSuffix(key, "/") {
+			continue
+		}
+		objects = append(objects, &PrefixObject{
+			Key:          key,
+			Size:         object.Size,
+			ETag:         aws.ToString(object.ETag),
+			LastModified: aws.ToTime(object.LastModified),
+			factory:      f,
+		})
+	}
+
+	if isDirectoryBucket(f.Options.Bucket) {
+		sort.Slice(objects, func(i, j int) bool { return objects[i].Key < objects[j].Key })
+	}
+
+	return objects, nil
+}
+
+// newObjectLister returns a function that returns the objects in the bucket of options whose keys start with prefix
+// one at a time, reporting false once there are no more. If lister is nil, options.Client is used or, in
+// multi-region mode, an s3.Client made from options.Options.
+func newObjectLister(options Options, lister s3.ListObjectsV2APIClient, prefix string) (
+	func(ctx context.Context) (types.Object, bool, error), error) {
+	nextPage, err := newPageLister(options, lister, s3.ListObjectsV2Input{Prefix: aws.String(prefix)})
+	if err != nil {
+		return nil, err
+	}
+
+	var objects []types.Object
+	return func(ctx context.Context) (types.Object, bool, error) {
+		for len(objects) == 0 {
+			page, err := nextPage(ctx)
+			if err != nil || page == nil {
+				return types.Object{}, false, err
+			}
+			objects = page.Contents
+		}
+
+		object := objects[0]
+		objects = objects[1:]
+		return object, true, nil
+	}, nil
+}
+
+// newPageLister returns a function that returns the pages of the listing input describes, in the bucket of options,
+// one at a time, reporting nil once there are no more. If lister is nil, options.Client is used or, in multi-region
+// mode, an s3.Client made from options.Options.
+func newPageLister(options Options, lister s3.ListObjectsV2APIClient, input s3.ListObjectsV2Input) (
+	func(ctx context.Context) (*s3.ListObjectsV2Output, error), error) {
+	// In multi-region mode, list in the bucket's region, if the RegionCache knows it.
+	var regionOptions *s3.Options
+	regions := options.regionCache()
+	inRegion := func(region string) {
+		copied := options.Options.Copy()
+		copied.Region = region
+		options.clientSettings().apply(&copied)
+		regionOptions, lister = &copied, s3.New(copied)
+	}
+	if lister == nil {
+		switch {
+		case options.Client != nil:
+			lister = options.Client
+		case options.Options != nil:
+			region, ok := regions.Region(options.Bucket)
+			if !ok || isAccessPoint(options.Bucket) {
+				region = options.Options.Region
+			}
+			inRegion(region)
+		default:
+			return nil, errors.New("a Lister is required with API")
+		}
+	}
+
+	input.Bucket = aws.String(options.Bucket)
+	input.RequestPayer = options.RequestPayer
+	input.ExpectedBucketOwner = optionalString(options.ExpectedBucketOwner)
+	var optFns []func(*s3.Options)
+	if isAccessPoint(options.Bucket) {
+		optFns = append(optFns, useARNRegion)
+	}
+
+	more := true
+	return func(ctx context.Context) (*s3.ListObjectsV2Output, error) {
+		if !more {
+			return nil, nil
+		}
+
+		pageInput := input
+		resp, err := lister.ListObjectsV2(ctx, &pageInput, optFns...)
+		if err != nil && regionOptions != nil && !isAccessPoint(options.Bucket) {
+			// In multi-region mode, follow the bucket to its region, as S3ReaderAt does.
+			region, regionErr := extractRegionFromError(err)
+			if client, ok := lister.(*s3.Client); regionErr != nil && options.DiscoverRegion && isRedirect(err) && ok {
+				region, regionErr = discoverRegion(ctx, client, options.Bucket, options.ExpectedBucketOwner)
+			}
+			if regionErr == nil && region != regionOptions.Region {
+				inRegion(region)
+				regions.SetRegion(options.Bucket, region)
+				pageInput = input
+				resp, err = lister.ListObjectsV2(ctx, &pageInput, optFns...)
+			}
+		}
+		if err != nil {
+			return nil, errors.Wrap(classifyError(err), "S3 ListObjectsV2 failed")
+		}
+
+		input.ContinuationToken = resp.NextContinuationToken
+		more = resp.IsTruncated && resp.NextContinuationToken != nil
+		return resp, nil
+	}, nil
+}
@@ -0,0 +1,65 @@
+package s3readerat
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+// TestFactoryOpenPrefix tests that OpenPrefix lists the objects under a prefix across pages, skipping folder markers,
+// and that the readers it returns are opened lazily with the listed size and ETag, without HeadObject requests.
+func TestFactoryOpenPrefix(t *testing.T) {
+	f := newFakeS3(t)
+	f.put("bucket", "dir/", nil)
+	f.put("bucket", "dir/a", []byte("aaaa"))
+	f.put("bucket", "dir/b", []byte("bbbbbbbb"))
+	f.put("bucket", "dir/c", []byte("cc"))
+	f.put("bucket", "other", []byte("other"))
+
+	factory := &Factory{Options: Options{Client: f.client(), Bucket: "bucket"}}
+	objects, err := factory.OpenPrefix(context.Background(), "dir/")
+	if err != nil {
+		t.Fatalf("Error calling OpenPrefix: %v", err)
+	}
+	if len(objects) != 3 || objects[0].Key != "dir/a" || objects[1].Size != 8 || objects[2].ETag == "" {
+		t.Fatalf("Unexpected objects listed: %+v", objects)
+	}
+	if count := f.requestCount(http.MethodGet); count != 2 {
+		t.Fatalf("Expected 2 ListObjectsV2 requests, got %d", count)
+	}
+
+	ra, err := objects[1].Open()
+	if err != nil {
+		t.Fatalf("Error calling Open: %v", err)
+	}
+	if again, _ := objects[1].Open(); again != ra {
+		t.Fatalf("Expected Open to return the same S3ReaderAt each time")
+	}
+
+	b := make([]byte, 8)
+	if _, err = ra.ReadAt(b, 0); err != nil || string(b) != "bbbbbbbb" {
+		t.Fatalf("Expected to read %q, got %q (%v)", "bbbbbbbb", b, err)
+	}
+	if etag, err := ra.ETag(); err != nil || etag != objects[1].ETag {
+		t.Fatalf("Expected ETag %s, got %s (%v)", objects[1].ETag, etag, err)
+	}
+	if count := f.requestCount(http.MethodHead); count != 0 {
+		t.Fatalf("Expected no HeadObject requests, got %d", count)
+	}
+
+	f.put("bucket", "dir/a", []byte("AAAA"))
+	ra, err = objects[0].Open()
+	if err != nil {
+		t.Fatalf("Error calling Open: %v", err)
+	}
+	if _, err = ra.ReadAt(make([]byte, 4), 0); !errors.Is(err, ErrObjectChanged) {
+		t.Fatalf("Expected reading an object overwritten since it was listed to fail with ErrObjectChanged, got %v",
+			err)
+	}
+
+	if _, err = (&Factory{Options: Options{Client: f.client()}}).OpenPrefix(context.Background(), ""); err == nil {
+		t.Fatalf("Expected an error calling OpenPrefix without a Bucket")
+	}
+}
@@ -0,0 +1,412 @@
+package s3readerat
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// fakeMaxParts is the number of parts fakeS3 lists per GetObjectAttributes response. It is small, so that tests
+// exercise pagination.
+const fakeMaxParts = 2
+
+// fakeMaxKeys is the number of objects fakeS3 lists per ListObjectsV2 response. It is small, so that tests exercise
+// pagination.
+const fakeMaxKeys = 2
+
+// fakeS3 is a minimal, in-memory S3 endpoint that understands path-style HeadObject, ranged GetObject and
+// ListObjectsV2 requests. It lets tests exercise S3ReaderAt without AWS credentials or network access.
+type fakeS3 struct {
+	server *httptest.Server
+
+	mu       sync.Mutex
+	objects  map[string]*fakeObject
+	requests []*http.Request
+
+	// versioning, if set, gives each object put a version ID, and keeps the objects it replaces as earlier versions.
+	versioning bool
+	versions   map[string]*fakeObject
+
+	// owner, if set, is the account ID that owns every bucket. Requests expecting another owner are denied.
+	owner string
+
+	// hook, if set, is called before the default handler. If it returns true, the request is considered handled.
+	hook func(w http.ResponseWriter, r *http.Request) bool
+}
+
+type fakeObject struct {
+	data         []byte
+	etag         string
+	lastModified time.Time
+	versionID    string
+
+	// parts are the parts the object was uploaded in, if it was multipart-uploaded.
+	parts []fakePart
+}
+
+// fakePart is one part of a multipart-uploaded fakeObject.
+type fakePart struct {
+	size int64
+
+	// checksumSHA256 is the base64-encoded SHA-256 checksum of the part, or empty if it was uploaded without one.
+	checksumSHA256 string
+}
+
+// newFakeS3 starts a fakeS3 server that is shut down when the test completes.
+func newFakeS3(t *testing.T) *fakeS3 {
+	t.Helper()
+
+	f := &fakeS3{objects: make(map[string]*fakeObject), versions: make(map[string]*fakeObject)}
+	f.server = httptest.NewServer(http.HandlerFunc(f.serveHTTP))
+	t.Cleanup(f.server.Close)
+
+	return f
+}
+
+// put stores data under bucket and key, replacing any existing object.
+func (f *fakeS3) put(bucket string, key string, data []byte) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	obj := &fakeObject{
+		data:         data,
+		etag:         fmt.Sprintf(`"%x"`, time.Now().UnixNano()),
+		lastModified: time.Now().UTC().Truncate(time.Second),
+	}
+	if f.versioning {
+		obj.versionID = fmt.Sprintf("v%d", time.Now().UnixNano())
+		f.versions[bucket+"/"+key+"\x00"+obj.versionID] = obj
+	}
+	f.objects[bucket+"/"+key] = obj
+}
+
+// putMultipart stores data under bucket and key as if it were multipart-uploaded in parts of partSize bytes, with
+// SHA-256 checksums if checksums is true.
+func (f *fakeS3) putMultipart(bucket string, key string, data []byte, partSize int64, checksums bool) {
+	var partSizes []int64
+	for off := int64(0); off < int64(len(data)); off += partSize {
+		if off+partSize > int64(len(data)) {
+			partSizes = append(partSizes, int64(len(data))-off)
+		} else {
+			partSizes = append(partSizes, partSize)
+		}
+	}
+	f.putParts(bucket, key, data, partSizes, checksums)
+}
+
+// putParts stores data under bucket and key as if it were multipart-uploaded in parts of the given sizes, which need
+// not be equal, with SHA-256 checksums if checksums is true.
+func (f *fakeS3) putParts(bucket string, key string, data []byte, partSizes []int64, checksums bool) {
+	var parts []fakePart
+	var off int64
+	for _, size := range partSizes {
+		part := fakePart{size: size}
+		if checksums {
+			sum := sha256.Sum256(data[off : off+size])
+			part.checksumSHA256 = base64.StdEncoding.EncodeToString(sum[:])
+		}
+		parts = append(parts, part)
+		off += size
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.objects[bucket+"/"+key] = &fakeObject{
+		data:         data,
+		etag:         fmt.Sprintf(`"%x-%d"`, time.Now().UnixNano(), len(parts)),
+		lastModified: time.Now().UTC().Truncate(time.Second),
+		parts:        parts,
+	}
+}
+
+// options returns s3.Options that talk to the fake server.
+func (f *fakeS3) options() s3.Options {
+	// Without a signing region, the resolver sets one the first time it resolves, which races if the first requests
+	// are concurrent.
+	resolver := s3.EndpointResolverFromURL(f.server.URL, func(e *aws.Endpoint) { e.SigningRegion = "us-east-1" })
+	return s3.Options{
+		Region:           "us-east-1",
+		Credentials:      credentials.NewStaticCredentialsProvider("AKID", "SECRET", ""),
+		EndpointResolver: resolver,
+		UsePathStyle:     true,
+		Retryer:          aws.NopRetryer{},
+	}
+}
+
+// client returns an s3.Client that talks to the fake server.
+func (f *fakeS3) client() *s3.Client {
+	return s3.New(f.options())
+}
+
+// requestCount returns the number of requests served with the given HTTP method.
+func (f *fakeS3) requestCount(method string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	count := 0
+	for _, r := range f.requests {
+		if r.Method == method {
+			count++
+		}
+	}
+
+	return count
+}
+
+func (f *fakeS3) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	f.requests = append(f.requests, r)
+	hook := f.hook
+	owner := f.owner
+	f.mu.Unlock()
+
+	if hook != nil && hook(w, r) {
+		return
+	}
+
+	if expected := r.Header.Get("X-Amz-Expected-Bucket-Owner"); expected != "" && expected != owner {
+		writeFakeError(w, http.StatusForbidden, "AccessDenied", r.Method != http.MethodHead)
+		return
+	}
+
+	if r.URL.Query().Get("list-type") == "2" {
+		f.writeList(w, r)
+		return
+	}
+
+	f.mu.Lock()
+	obj, ok := f.objects[strings.TrimPrefix(r.URL.Path, "/")]
+	if versionID := r.URL.Query().Get("versionId"); versionID != "" {
+		obj, ok = f.versions[strings.TrimPrefix(r.URL.Path, "/")+"\x00"+versionID]
+	}
+	f.mu.Unlock()
+
+	if !ok {
+		writeFakeError(w, http.StatusNotFound, "NoSuchKey", r.Method != http.MethodHead)
+		return
+	}
+
+	if ifMatch := r.Header.Get("If-Match"); ifMatch != "" && ifMatch != obj.etag {
+		writeFakeError(w, http.StatusPreconditionFailed, "PreconditionFailed", r.Method != http.MethodHead)
+		return
+	}
+	if obj.versionID != "" {
+		w.Header().Set("X-Amz-Version-Id", obj.versionID)
+	}
+
+	size := int64(len(obj.data))
+	w.Header().Set("ETag", obj.etag)
+	w.Header().Set("Last-Modified", obj.lastModified.Format(http.TimeFormat))
+	w.Header().Set("Accept-Ranges", "bytes")
+
+	switch r.Method {
+	case http.MethodHead:
+		if partNumber := r.URL.Query().Get("partNumber"); partNumber != "" {
+			// An object that was not multipart-uploaded is one part.
+			count := len(obj.parts)
+			if count == 0 {
+				count = 1
+			}
+			n, err := strconv.Atoi(partNumber)
+			if err != nil || n < 1 || n > count {
+				writeFakeError(w, http.StatusRequestedRangeNotSatisfiable, "InvalidPartNumber", false)
+				return
+			}
+			if len(obj.parts) > 0 {
+				size = obj.parts[n-1].size
+				w.Header().Set("X-Amz-Mp-Parts-Count", strconv.Itoa(len(obj.parts)))
+			}
+		}
+		w.Header().Set("Content-Length", strconv.FormatInt(size, 10))
+		w.WriteHeader(http.StatusOK)
+	case http.MethodGet:
+		if _, ok := r.URL.Query()["attributes"]; ok {
+			writeFakeAttributes(w, r, obj)
+			return
+		}
+
+		first, last, ok := parseFakeRange(r.Header.Get("Range"), size)
+		if !ok {
+			writeFakeError(w, http.StatusRequestedRangeNotSatisfiable, "InvalidRange", true)
+			return
+		}
+
+		w.Header().Set("Content-Length", strconv.FormatInt(last-first+1, 10))
+		if r.Header.Get("Range") != "" {
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", first, last, size))
+			w.WriteHeader(http.StatusPartialContent)
+		} else {
+			w.WriteHeader(http.StatusOK)
+		}
+		_, _ = w.Write(obj.data[first : last+1])
+	default:
+		writeFakeError(w, http.StatusMethodNotAllowed, "MethodNotAllowed", true)
+	}
+}
+
+// writeFakeAttributes writes a GetObjectAttributes response listing the object's parts, fakeMaxParts at a time
+// starting after the X-Amz-Part-Number-Marker header. Parts uploaded without checksums are not listed, as in S3.
+func writeFakeAttributes(w http.ResponseWriter, r *http.Request, obj *fakeObject) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "<GetObjectAttributesResponse><ETag>%s</ETag>", strings.Trim(obj.etag, `"`))
+	if len(obj.parts) > 0 {
+		marker, _ := strconv.Atoi(r.Header.Get("X-Amz-Part-Number-Marker"))
+		next := marker + fakeMaxParts
+		if next > len(obj.parts) {
+			next = len(obj.parts)
+		}
+
+		fmt.Fprintf(&b, "<ObjectParts><PartsCount>%d</PartsCount>", len(obj.parts))
+		fmt.Fprintf(&b, "<IsTruncated>%t</IsTruncated><NextPartNumberMarker>%d</NextPartNumberMarker>",
+			next < len(obj.parts), next)
+		for i := marker; i < next; i++ {
+			part := obj.parts[i]
+			if part.checksumSHA256 == "" {
+				continue
+			}
+			fmt.Fprintf(&b, "<Part><PartNumber>%d</PartNumber><Size>%d</Size>", i+1, part.size)
+			fmt.Fprintf(&b, "<ChecksumSHA256>%s</ChecksumSHA256></Part>", part.checksumSHA256)
+		}
+		b.WriteString("</ObjectParts>")
+	}
+	fmt.Fprintf(&b, "<ObjectSize>%d</ObjectSize></GetObjectAttributesResponse>", len(obj.data))
+
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte(b.String()))
+}
+
+// writeList writes a ListObjectsV2 response listing the bucket's objects under the prefix parameter in key order,
+// fakeMaxKeys, or the max-keys parameter if fewer, at a time starting after the continuation-token parameter, which is
+// the last key or common prefix of the previous page. Given the delimiter parameter, keys containing it after the
+// prefix are grouped into common prefixes, as in S3.
+func (f *fakeS3) writeList(w http.ResponseWriter, r *http.Request) {
+	bucket := strings.Trim(r.URL.Path, "/")
+	prefix := r.URL.Query().Get("prefix")
+	delimiter := r.URL.Query().Get("delimiter")
+	after := r.URL.Query().Get("continuation-token")
+	maxKeys := fakeMaxKeys
+	if n, err := strconv.Atoi(r.URL.Query().Get("max-keys")); err == nil && n < maxKeys {
+		maxKeys = n
+	}
+
+	f.mu.Lock()
+	var keys []string
+	commonPrefixes := make(map[string]bool)
+	for name := range f.objects {
+		key := strings.TrimPrefix(name, bucket+"/")
+		if key == name || !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		if i := strings.Index(key[len(prefix):], delimiter); delimiter != "" && i >= 0 {
+			key = key[:len(prefix)+i+len(delimiter)]
+			if commonPrefixes[key] {
+				continue
+			}
+			commonPrefixes[key] = true
+		}
+		if key > after {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	truncated := len(keys) > maxKeys
+	if truncated {
+		keys = keys[:maxKeys]
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "<ListBucketResult><Name>%s</Name><Prefix>%s</Prefix>", bucket, prefix)
+	fmt.Fprintf(&b, "<KeyCount>%d</KeyCount><IsTruncated>%t</IsTruncated>", len(keys), truncated)
+	if truncated {
+		fmt.Fprintf(&b, "<NextContinuationToken>%s</NextContinuationToken>", keys[len(keys)-1])
+	}
+	for _, key := range keys {
+		if commonPrefixes[key] {
+			fmt.Fprintf(&b, "<CommonPrefixes><Prefix>%s</Prefix></CommonPrefixes>", key)
+			continue
+		}
+		obj := f.objects[bucket+"/"+key]
+		fmt.Fprintf(&b, "<Contents><Key>%s</Key><Size>%d</Size><ETag>%s</ETag>", key, len(obj.data), obj.etag)
+		fmt.Fprintf(&b, "<LastModified>%s</LastModified></Contents>", obj.lastModified.Format(time.RFC3339))
+	}
+	b.WriteString("</ListBucketResult>")
+	f.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte(b.String()))
+}
+
+// parseFakeRange parses a "bytes=first-last" header, clamping last to the object size. An empty header selects the
+// whole object.
+func parseFakeRange(header string, size int64) (int64, int64, bool) {
+	if header == "" {
+		return 0, size - 1, true
+	}
+
+	parts := strings.SplitN(strings.TrimPrefix(header, "bytes="), "-", 2)
+	if len(parts) != 2 {
+		return 0, 0, false
+	}
+
+	first, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil || first < 0 || first >= size {
+		return 0, 0, false
+	}
+
+	last := size - 1
+	if parts[1] != "" {
+		last, err = strconv.ParseInt(parts[1], 10, 64)
+		if err != nil || last < first {
+			return 0, 0, false
+		}
+		if last > size-1 {
+			last = size - 1
+		}
+	}
+
+	return first, last, true
+}
+
+func writeFakeError(w http.ResponseWriter, status int, code string, body bool) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	if body {
+		_, _ = fmt.Fprintf(w, "<Error><Code>%s</Code><Message>%s</Message></Error>", code, code)
+	}
+}
+
+// truncateGets returns a fakeS3 hook that truncates the response body of the first n GetObject requests.
+func truncateGets(f *fakeS3, n int) func(w http.ResponseWriter, r *http.Request) bool {
+	remaining := n
+	return func(w http.ResponseWriter, r *http.Request) bool {
+		if r.Method != http.MethodGet || remaining == 0 {
+			return false
+		}
+		remaining--
+
+		f.mu.Lock()
+		obj := f.objects[strings.TrimPrefix(r.URL.Path, "/")]
+		f.mu.Unlock()
+
+		first, last, _ := parseFakeRange(r.Header.Get("Range"), int64(len(obj.data)))
+		w.Header().Set("Content-Length", strconv.FormatInt(last-first+1, 10))
+		w.WriteHeader(http.StatusPartialContent)
+		_, _ = w.Write(obj.data[first : first+(last-first+1)/2])
+		return true
+	}
+}
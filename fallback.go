@@ -0,0 +1,84 @@
+package s3readerat
+
+import (
+	"context"
+	"io"
+	"log"
+	"net"
+
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+	"github.com/pkg/errors"
+)
+
+// Fallback is a local copy of an object, such as a file in a mirror or the blocks of it in a disk cache, from which
+// an S3ReaderAt serves reads while S3 cannot be reached. The copy may be older than the object, so each read it serves
+// is reported to OnStale.
+type Fallback struct {
+	// ReaderAt reads the local copy. For a disk cache, use cache.NewOffline.
+	ReaderAt io.ReaderAt
+
+	// Size is the size of the local copy in bytes. It is returned by S3ReaderAt.Size if the size of the object cannot
+	// be determined.
+	Size int64
+
+	// OnStale, if set, is called after each read served from the local copy, with its offset, the number of bytes read
+	// and the error that prevented reading from S3. It is also called with a length of zero when Size returns the size
+	// of the local copy.
+	OnStale func(off int64, n int, err error)
+}
+
+// isNetworkError reports whether err means S3 could not be reached, as opposed to S3 responding with an error.
+func isNetworkError(err error) bool {
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+
+	var sendErr *smithyhttp.RequestSendError
+	if errors.As(err, &sendErr) {
+		return true
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// fallbackSize returns the size of the local copy, if there is one and err means S3 could not be reached.
+func (ra *S3ReaderAt) fallbackSize(err error) (int64, bool) {
+	if ra.fallback == nil || !isNetworkError(err) {
+		return 0, false
+	}
+
+	if ra.debug() {
+		log.Printf("Using the size of the local copy of S3 object s3://%s/%s after error: %v", ra.bucket, ra.key, err)
+	}
+
+	if ra.fallback.OnStale != nil {
+		ra.fallback.OnStale(0, 0, err)
+	}
+
+	return ra.fallback.Size, true
+}
+
+// readFallback serves a read from the local copy, if there is one and err means S3 could not be reached. If the local
+// copy cannot serve the read either, it returns err.
+func (ra *S3ReaderAt) readFallback(p []byte, off int64, err error) (int, error) {
+	if ra.fallback == nil || !isNetworkError(err) {
+		return 0, err
+	}
+
+	n, fallbackErr := ra.fallback.ReaderAt.ReadAt(p, off)
+	if fallbackErr != nil && fallbackErr != io.EOF {
+		return 0, errors.Wrapf(err, "the local copy also failed (%v)", fallbackErr)
+	}
+
+	if ra.debug() {
+		log.Printf("Served %d bytes at offset %d of S3 object s3://%s/%s from its local copy after error: %v", n, off,
+			ra.bucket, ra.key, err)
+	}
+
+	if ra.fallback.OnStale != nil {
+		ra.fallback.OnStale(off, n, err)
+	}
+
+	return n, fallbackErr
+}
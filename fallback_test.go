@@ -0,0 +1,72 @@
+package s3readerat
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/markandrus/s3readerat/cache"
+)
+
+// TestFallback tests that reads are served from a local copy, and reported as stale, only while S3 cannot be reached.
+func TestFallback(t *testing.T) {
+	data := []byte("0123456789")
+	f := newFakeS3(t)
+	f.put("bucket", "key", data)
+
+	store, err := cache.OpenDiskStore(t.TempDir(), 4)
+	if err != nil {
+		t.Fatalf("Error calling OpenDiskStore: %v", err)
+	}
+	object := cache.Object{Name: "s3://bucket/key", Version: `"v1"`, Size: int64(len(data))}
+	if err = store.Put(object, 0, data[:4]); err != nil {
+		t.Fatalf("Error calling Put: %v", err)
+	}
+
+	latest, ok, err := store.Latest("s3://bucket/key")
+	if err != nil || !ok || latest != object {
+		t.Fatalf("Expected Latest to return %+v, got %+v, %v and %v", object, latest, ok, err)
+	}
+
+	var stale []int
+	newReader := func(key string) *S3ReaderAt {
+		s3ReaderAt, err := NewWithOptions(Options{
+			Client: f.client(),
+			Bucket: "bucket",
+			Key:    key,
+			Fallback: &Fallback{
+				ReaderAt: cache.NewOffline(store, latest),
+				Size:     latest.Size,
+				OnStale:  func(off int64, n int, err error) { stale = append(stale, n) },
+			},
+		})
+		if err != nil {
+			t.Fatalf("Error calling NewWithOptions: %v", err)
+		}
+		return s3ReaderAt
+	}
+
+	// Errors returned by S3 are not worked around.
+	if _, err = newReader("missing").Size(); err == nil {
+		t.Fatalf("Expected an error getting the size of a missing object")
+	}
+
+	f.server.Close()
+	s3ReaderAt := newReader("key")
+
+	size, err := s3ReaderAt.Size()
+	if err != nil || size != int64(len(data)) {
+		t.Fatalf("Expected the size of the local copy, got %d and %v", size, err)
+	}
+
+	b := make([]byte, 4)
+	if n, err := s3ReaderAt.ReadAt(b, 0); err != nil || !bytes.Equal(b[:n], data[:4]) {
+		t.Fatalf("Expected %q from the local copy, got %q and %v", data[:4], b[:n], err)
+	}
+	if _, err = s3ReaderAt.ReadAt(b, 4); err == nil {
+		t.Fatalf("Expected an error reading bytes missing from the local copy")
+	}
+
+	if len(stale) != 2 || stale[0] != 0 || stale[1] != 4 {
+		t.Fatalf("Expected OnStale to report the size and one read, got %v", stale)
+	}
+}
@@ -0,0 +1,107 @@
+package s3readerat
+
+import (
+	"context"
+	"log"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// flight is a range being read from S3 on behalf of one read, the leader, which other reads within the range join
+// rather than issuing their own requests, when Options.DeduplicateReads is set. The leader reads into its caller's
+// buffer, so it waits for the reads that joined to copy from it before returning.
+type flight struct {
+	first  int64
+	last   int64
+	buf    []byte
+	n      int
+	err    error
+	done   chan struct{}
+	joined sync.WaitGroup
+}
+
+// flights are the flights of an S3ReaderAt in progress.
+type flights struct {
+	mu   sync.Mutex
+	list []*flight
+}
+
+// join returns the flight in progress whose range contains [first, last] and true, having counted the caller among the
+// reads that joined it, or else a new flight for [first, last] into buf, which the caller leads, and false.
+func (fs *flights) join(first int64, last int64, buf []byte) (*flight, bool) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	for _, fl := range fs.list {
+		if fl.first <= first && last <= fl.last {
+			fl.joined.Add(1)
+			return fl, true
+		}
+	}
+
+	fl := &flight{first: first, last: last, buf: buf, done: make(chan struct{})}
+	fs.list = append(fs.list, fl)
+	return fl, false
+}
+
+// land removes fl once its read has finished, releases the reads that joined it and waits for them to copy from it.
+func (fs *flights) land(fl *flight, n int, err error) {
+	fs.mu.Lock()
+	for i, other := range fs.list {
+		if other == fl {
+			fs.list = append(fs.list[:i], fs.list[i+1:]...)
+			break
+		}
+	}
+	fs.mu.Unlock()
+
+	fl.n, fl.err = n, err
+	close(fl.done)
+	fl.joined.Wait()
+}
+
+// readRangeShared reads the inclusive byte range [first, last] into p as readRangeRetrying does, unless a read whose
+// range contains it is in progress, in which case it waits for that read and copies from it. If that read was canceled
+// by its caller, the rest of the range is read as usual.
+func (ra *S3ReaderAt) readRangeShared(ctx context.Context, p []byte, first int64, last int64) (int, error) {
+	fl, joined := ra.flights.join(first, last, p)
+	if !joined {
+		n, err := ra.readRangeRetrying(ctx, p, first, last)
+		ra.flights.land(fl, n, err)
+		return n, err
+	}
+
+	if ra.debug() {
+		log.Printf("Joining the read of S3 object s3://%s/%s bytes %d-%d for bytes %d-%d", ra.bucket, ra.key,
+			fl.first, fl.last, first, last)
+	}
+
+	select {
+	case <-fl.done:
+	case <-ctx.Done():
+		fl.joined.Done()
+		return 0, ctx.Err()
+	}
+
+	var n int
+	if off := int(first - fl.first); off < fl.n {
+		n = copy(p, fl.buf[off:fl.n])
+	}
+	err := fl.err
+	fl.joined.Done()
+
+	if n > 0 {
+		ra.recordCacheHit(ctx, p[:n], first)
+	}
+	leaderGaveUp := errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+	if n == len(p) {
+		return n, nil
+	} else if !leaderGaveUp || ctx.Err() != nil {
+		return n, err
+	}
+
+	// The leader gave up, but this read need not.
+	read, err := ra.readRangeRetrying(ctx, p[n:], first+int64(n), last)
+	return n + read, err
+}
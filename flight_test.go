@@ -0,0 +1,88 @@
+package s3readerat
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestDeduplicateReads tests that, with DeduplicateReads, reads within the range of a read in progress wait for it
+// rather than issue their own requests, and that they read for themselves if it is canceled.
+func TestDeduplicateReads(t *testing.T) {
+	f := newFakeS3(t)
+	f.put("bucket", "key", []byte("0123456789"))
+
+	release := make(chan struct{})
+	f.hook = func(w http.ResponseWriter, r *http.Request) bool {
+		if r.Method == http.MethodGet {
+			<-release
+		}
+		return false
+	}
+
+	s3ReaderAt, err := NewWithOptions(Options{Client: f.client(), Bucket: "bucket", Key: "key", DeduplicateReads: true})
+	if err != nil {
+		t.Fatalf("Error calling NewWithOptions: %v", err)
+	}
+
+	read := func(ctx context.Context, wg *sync.WaitGroup, off int64, n int, expected string) {
+		defer wg.Done()
+		b := make([]byte, n)
+		if n, err := s3ReaderAt.ReadAtContext(ctx, b, off); err != nil || string(b[:n]) != expected {
+			t.Errorf("Expected to read %q, got %q (%v)", expected, b[:n], err)
+		}
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(3)
+	go read(context.Background(), &wg, 0, 10, "0123456789")
+	time.Sleep(20 * time.Millisecond)
+	go read(context.Background(), &wg, 2, 4, "2345")
+	go read(context.Background(), &wg, 6, 4, "6789")
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if count := f.requestCount(http.MethodGet); count != 1 {
+		t.Fatalf("Expected 1 GetObject request, got %d", count)
+	}
+	if stats := s3ReaderAt.Stats(); stats.CacheHits != 2 {
+		t.Fatalf("Expected 2 cache hits, got %d", stats.CacheHits)
+	}
+
+	// Only the leader's request stalls, until it is canceled.
+	token := make(chan struct{}, 1)
+	token <- struct{}{}
+	f.mu.Lock()
+	f.hook = func(w http.ResponseWriter, r *http.Request) bool {
+		select {
+		case <-token:
+			<-r.Context().Done()
+			return true
+		default:
+			return false
+		}
+	}
+	f.mu.Unlock()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		if _, err := s3ReaderAt.ReadAtContext(ctx, make([]byte, 10), 0); err == nil {
+			t.Errorf("Expected an error from the canceled read")
+		}
+	}()
+	time.Sleep(20 * time.Millisecond)
+	wg.Add(1)
+	go read(context.Background(), &wg, 4, 4, "4567")
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+	wg.Wait()
+
+	if count := f.requestCount(http.MethodGet); count != 3 {
+		t.Fatalf("Expected 3 GetObject requests, got %d", count)
+	}
+}
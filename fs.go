@@ -0,0 +1,313 @@
+package s3readerat
+
+import (
+	"context"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/pkg/errors"
+)
+
+// FS is an fs.FS of the objects in a bucket whose keys start with a prefix, as returned by Factory.FS. Slashes in keys
+// separate directories, which exist wherever some key has them as a prefix; keys ending in a slash, which S3 consoles
+// create as folder markers, are not listed as files. Files implement io.ReaderAt and io.Seeker, with reads served by
+// an S3ReaderAt, and directories fs.ReadDirFile. Directories have no modification time. Directory buckets, whose
+// listings are not in key order, are not supported.
+type FS struct {
+	factory *Factory
+	prefix  string
+}
+
+var (
+	_ fs.FS        = (*FS)(nil)
+	_ fs.StatFS    = (*FS)(nil)
+	_ fs.ReadDirFS = (*FS)(nil)
+)
+
+// FS returns an FS of the objects whose keys start with prefix, to which a slash is added if it is missing. Opening or
+// statting a file makes one ListObjectsV2 request, which also gives its size and ETag, so no HeadObject request is
+// made, and reading a directory one per 1,000 entries. Requests are made with Options.Context.
+func (f *Factory) FS(prefix string) *FS {
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	return &FS{factory: f, prefix: prefix}
+}
+
+// Open opens the named file or directory.
+func (fsys *FS) Open(name string) (fs.File, error) {
+	object, info, err := fsys.lookup("open", name)
+	if err != nil {
+		return nil, err
+	} else if object == nil {
+		return &fsDir{fsys: fsys, name: name, info: info}, nil
+	}
+
+	ra, err := object.Open()
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	return &fsFile{SectionReader: io.NewSectionReader(ra, 0, object.Size), info: info}, nil
+}
+
+// Stat returns a FileInfo describing the named file or directory.
+func (fsys *FS) Stat(name string) (fs.FileInfo, error) {
+	_, info, err := fsys.lookup("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// ReadDir reads the named directory, returning its entries sorted by name.
+func (fsys *FS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if _, info, err := fsys.lookup("readdir", name); err != nil {
+		return nil, err
+	} else if !info.IsDir() {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: errors.New("not a directory")}
+	}
+
+	entries, err := fsys.readDir(name)
+	if err != nil {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: err}
+	}
+	return entries, nil
+}
+
+// lookup finds the named file or directory for the fs.FS method op, returning errors as *fs.PathError. For a file, it
+// returns a PrefixObject as well. A key that is both an object and the prefix of other keys is a file.
+func (fsys *FS) lookup(op string, name string) (*PrefixObject, fsFileInfo, error) {
+	if !fs.ValidPath(name) {
+		return nil, fsFileInfo{}, &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	} else if isDirectoryBucket(fsys.factory.Options.Bucket) {
+		err := errors.New("directory buckets are not supported")
+		return nil, fsFileInfo{}, &fs.PathError{Op: op, Path: name, Err: err}
+	} else if name == "." {
+		return nil, fsFileInfo{name: ".", dir: true}, nil
+	}
+
+	key := fsys.prefix + name
+	object, isDir, err := fsys.find(key)
+	if err != nil {
+		return nil, fsFileInfo{}, &fs.PathError{Op: op, Path: name, Err: err}
+	} else if object != nil {
+		return object, fsFileInfo{name: path.Base(name), size: object.Size, modTime: object.LastModified}, nil
+	} else if !isDir {
+		return nil, fsFileInfo{}, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+
+	return nil, fsFileInfo{name: path.Base(name), dir: true}, nil
+}
+
+// find returns the object with the given key if there is one, and otherwise whether some key has key and a slash as
+// its prefix. The object, if any, is listed first among the keys with key as their prefix.
+func (fsys *FS) find(key string) (*PrefixObject, bool, error) {
+	page, err := fsys.list(s3.ListObjectsV2Input{Prefix: aws.String(key), MaxKeys: 1})
+	if err != nil {
+		return nil, false, err
+	}
+	if len(page.Contents) > 0 && aws.ToString(page.Contents[0].Key) == key {
+		object := page.Contents[0]
+		return &PrefixObject{
+			Key:          key,
+			Size:         object.Size,
+			ETag:         aws.ToString(object.ETag),
+			LastModified: aws.ToTime(object.LastModified),
+			factory:      fsys.factory,
+		}, false, nil
+	}
+
+	page, err = fsys.list(s3.ListObjectsV2Input{Prefix: aws.String(key + "/"), MaxKeys: 1})
+	if err != nil {
+		return nil, false, err
+	}
+	return nil, len(page.Contents) > 0, nil
+}
+
+// list returns the first page of the listing input describes.
+func (fsys *FS) list(input s3.ListObjectsV2Input) (*s3.ListObjectsV2Output, error) {
+	nextPage, err := newPageLister(fsys.factory.Options, fsys.factory.Lister, input)
+	if err != nil {
+		return nil, err
+	}
+	return nextPage(fsys.context())
+}
+
+// readDir lists the entries of the named directory, sorted by name.
+func (fsys *FS) readDir(name string) ([]fs.DirEntry, error) {
+	prefix := fsys.prefix
+	if name != "." {
+		prefix += name + "/"
+	}
+
+	nextPage, err := newPageLister(fsys.factory.Options, fsys.factory.Lister,
+		s3.ListObjectsV2Input{Prefix: aws.String(prefix), Delimiter: aws.String("/")})
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []fs.DirEntry
+	for {
+		page, err := nextPage(fsys.context())
+		if err != nil {
+			return nil, err
+		} else if page == nil {
+			break
+		}
+
+		for _, object := range page.Contents {
+			entryName := strings.TrimPrefix(aws.ToString(object.Key), prefix)
+			if entryName == "" || strings.HasSuffix(entryName, "/") {
+				continue
+			}
+			entries = append(entries, fsFileInfo{
+				name:    entryName,
+				size:    object.Size,
+				modTime: aws.ToTime(object.LastModified),
+			})
+		}
+		for _, commonPrefix := range page.CommonPrefixes {
+			entryName := strings.TrimSuffix(strings.TrimPrefix(aws.ToString(commonPrefix.Prefix), prefix), "/")
+			if entryName == "" || strings.Contains(entryName, "/") {
+				// A key with consecutive slashes has an empty path element, which fs.FS cannot name.
+				continue
+			}
+			entries = append(entries, fsFileInfo{name: entryName, dir: true})
+		}
+	}
+
+	// A key that is both an object and the prefix of other keys is a file, so drop the directory listed for it.
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Name() != entries[j].Name() {
+			return entries[i].Name() < entries[j].Name()
+		}
+		return !entries[i].IsDir() && entries[j].IsDir()
+	})
+	deduplicated := entries[:0]
+	for i, entry := range entries {
+		if i > 0 && entry.Name() == entries[i-1].Name() {
+			continue
+		}
+		deduplicated = append(deduplicated, entry)
+	}
+
+	return deduplicated, nil
+}
+
+// context returns the context requests are made with.
+func (fsys *FS) context() context.Context {
+	if ctx := fsys.factory.Options.Context; ctx != nil {
+		return ctx
+	}
+	return context.Background()
+}
+
+// fsFileInfo implements fs.FileInfo and fs.DirEntry for a file or directory in an FS.
+type fsFileInfo struct {
+	name    string
+	size    int64
+	modTime time.Time
+	dir     bool
+}
+
+func (fi fsFileInfo) Name() string {
+	return fi.name
+}
+
+func (fi fsFileInfo) Size() int64 {
+	return fi.size
+}
+
+func (fi fsFileInfo) Mode() fs.FileMode {
+	if fi.dir {
+		return fs.ModeDir | 0555
+	}
+	return 0444
+}
+
+func (fi fsFileInfo) ModTime() time.Time {
+	return fi.modTime
+}
+
+func (fi fsFileInfo) IsDir() bool {
+	return fi.dir
+}
+
+func (fi fsFileInfo) Sys() interface{} {
+	return nil
+}
+
+func (fi fsFileInfo) Type() fs.FileMode {
+	return fi.Mode().Type()
+}
+
+func (fi fsFileInfo) Info() (fs.FileInfo, error) {
+	return fi, nil
+}
+
+// fsFile is an open file in an FS.
+type fsFile struct {
+	*io.SectionReader
+	info fsFileInfo
+}
+
+func (f *fsFile) Stat() (fs.FileInfo, error) {
+	return f.info, nil
+}
+
+func (f *fsFile) Close() error {
+	return nil
+}
+
+// fsDir is an open directory in an FS. Its entries are listed by the first call to ReadDir.
+type fsDir struct {
+	fsys    *FS
+	name    string
+	info    fsFileInfo
+	entries []fs.DirEntry
+	listed  bool
+}
+
+func (d *fsDir) Stat() (fs.FileInfo, error) {
+	return d.info, nil
+}
+
+func (d *fsDir) Close() error {
+	return nil
+}
+
+func (d *fsDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.name, Err: errors.New("is a directory")}
+}
+
+// ReadDir reads the directory's entries, which are returned sorted by name.
+func (d *fsDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	if !d.listed {
+		entries, err := d.fsys.readDir(d.name)
+		if err != nil {
+			return nil, &fs.PathError{Op: "readdir", Path: d.name, Err: err}
+		}
+		d.entries, d.listed = entries, true
+	}
+
+	if n <= 0 {
+		entries := d.entries
+		d.entries = nil
+		return entries, nil
+	} else if len(d.entries) == 0 {
+		return nil, io.EOF
+	}
+
+	if n > len(d.entries) {
+		n = len(d.entries)
+	}
+	entries := d.entries[:n]
+	d.entries = d.entries[n:]
+	return entries, nil
+}
@@ -0,0 +1,64 @@
+package s3readerat
+
+import (
+	"io"
+	"io/fs"
+	"net/http"
+	"testing"
+	"testing/fstest"
+
+	"github.com/pkg/errors"
+)
+
+// TestFS tests that FS presents the objects under a prefix as files and directories, across listing pages and with
+// folder markers, serving reads with range requests and without HeadObject requests.
+func TestFS(t *testing.T) {
+	f := newFakeS3(t)
+	f.put("bucket", "root/README.md", []byte("# readme"))
+	f.put("bucket", "root/data/", nil)
+	f.put("bucket", "root/data/a.txt", []byte("0123456789"))
+	f.put("bucket", "root/data/b.txt", []byte("abc"))
+	f.put("bucket", "root/data/nested/c.txt", []byte("nested"))
+	f.put("bucket", "root/empty/", nil)
+	f.put("bucket", "rooted", []byte("outside"))
+
+	fsys := (&Factory{Options: Options{Client: f.client(), Bucket: "bucket"}}).FS("root")
+	if err := fstest.TestFS(fsys, "README.md", "data/a.txt", "data/b.txt", "data/nested/c.txt"); err != nil {
+		t.Fatalf("Error testing FS: %v", err)
+	}
+
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		t.Fatalf("Error calling ReadDir: %v", err)
+	}
+	if len(entries) != 3 || entries[0].Name() != "README.md" || !entries[1].IsDir() || entries[2].Name() != "empty" {
+		t.Fatalf("Unexpected entries: %v", entries)
+	}
+
+	file, err := fsys.Open("data/a.txt")
+	if err != nil {
+		t.Fatalf("Error calling Open: %v", err)
+	}
+	b := make([]byte, 3)
+	if _, err = file.(io.Seeker).Seek(4, io.SeekStart); err != nil {
+		t.Fatalf("Error calling Seek: %v", err)
+	}
+	if _, err = io.ReadFull(file, b); err != nil || string(b) != "456" {
+		t.Fatalf("Expected to read %q, got %q (%v)", "456", b, err)
+	}
+	if count := f.requestCount(http.MethodHead); count != 0 {
+		t.Fatalf("Expected no HeadObject requests, got %d", count)
+	}
+
+	for _, name := range []string{"missing", "data/a", "data/a.txt/x", "rooted"} {
+		if _, err = fsys.Stat(name); !errors.Is(err, fs.ErrNotExist) {
+			t.Fatalf("Expected fs.ErrNotExist statting %s, got %v", name, err)
+		}
+	}
+	if _, err = fsys.Open("/data"); !errors.Is(err, fs.ErrInvalid) {
+		t.Fatalf("Expected fs.ErrInvalid opening an invalid path, got %v", err)
+	}
+	if _, err = fsys.ReadDir("README.md"); err == nil {
+		t.Fatalf("Expected an error reading a file as a directory")
+	}
+}
@@ -3,11 +3,13 @@ module github.com/markandrus/s3readerat
 go 1.16
 
 require (
-	github.com/aws/aws-sdk-go-v2 v1.8.1
-	github.com/aws/aws-sdk-go-v2/config v1.6.1
-	github.com/aws/aws-sdk-go-v2/credentials v1.3.3
-	github.com/aws/aws-sdk-go-v2/service/s3 v1.13.0
-	github.com/aws/smithy-go v1.7.0
+	github.com/aws/aws-sdk-go-v2 v1.15.0
+	github.com/aws/aws-sdk-go-v2/config v1.15.0
+	github.com/aws/aws-sdk-go-v2/credentials v1.10.0
+	github.com/aws/aws-sdk-go-v2/service/s3 v1.26.0
+	github.com/aws/smithy-go v1.11.1
 	github.com/joho/godotenv v1.5.1
+	github.com/klauspost/compress v1.13.6
 	github.com/pkg/errors v0.9.1
+	golang.org/x/sys v0.0.0-20211216021012-1d35b9e2eb4e
 )
@@ -0,0 +1,112 @@
+package s3readerat
+
+import (
+	"context"
+	"io"
+	"log"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// hedgeResult is the outcome of one of the GetObject requests of hedgedGetObject.
+type hedgeResult struct {
+	index int
+	resp  *s3.GetObjectOutput
+	err   error
+}
+
+// hedgedGetObject issues a GetObject request with input and, if Options.HedgeDelay is set and the request has not
+// returned its response headers within it, a second identical request, returning whichever response arrives first and
+// canceling the other. A request that fails is only given up on once the other has failed too. The hedge spends an
+// attempt from the budget of the read, if it has one, and is skipped if none is left. The caller must close the
+// returned Body.
+func (ra *S3ReaderAt) hedgedGetObject(ctx context.Context, input *s3.GetObjectInput) (*s3.GetObjectOutput, error) {
+	delay := ra.tuning().HedgeDelay
+	if delay <= 0 {
+		return ra.getObject(ctx, input)
+	}
+
+	results := make(chan hedgeResult, 2)
+	var cancels []context.CancelFunc
+	issue := func() {
+		reqCtx, cancel := context.WithCancel(ctx)
+		index := len(cancels)
+		cancels = append(cancels, cancel)
+		reqInput := *input
+		go func() {
+			resp, err := ra.getObject(reqCtx, &reqInput)
+			results <- hedgeResult{index: index, resp: resp, err: err}
+		}()
+	}
+	issue()
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	pending := 1
+	var firstErr error
+	for pending > 0 {
+		select {
+		case <-timer.C:
+			if spendAttempt(ctx, nil) != nil {
+				continue
+			}
+			if ra.debug() {
+				log.Printf("Hedging GetObject request for S3 object s3://%s/%s with range %s after %v", ra.bucket,
+					ra.key, *input.Range, delay)
+			}
+			ra.recordHedge()
+			issue()
+			pending++
+
+		case result := <-results:
+			pending--
+			if result.err != nil {
+				cancels[result.index]()
+				if firstErr == nil {
+					firstErr = result.err
+				}
+				continue
+			}
+
+			for i, cancel := range cancels {
+				if i != result.index {
+					cancel()
+				}
+			}
+			if pending > 0 {
+				go func() {
+					if loser := <-results; loser.err == nil {
+						_ = loser.resp.Body.Close()
+					}
+				}()
+			}
+			result.resp.Body = &hedgedBody{ReadCloser: result.resp.Body, cancel: cancels[result.index]}
+			return result.resp, nil
+		}
+	}
+
+	return nil, firstErr
+}
+
+// recordHedge counts a hedged GetObject request in the S3ReaderAt's Stats.
+func (ra *S3ReaderAt) recordHedge() {
+	ra.statsMu.Lock()
+	defer ra.statsMu.Unlock()
+
+	ra.stats.GetObjectRequests++
+	ra.stats.HedgedRequests++
+}
+
+// hedgedBody is the response body of the GetObject request that won a hedge, which releases its context when closed.
+type hedgedBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *hedgedBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
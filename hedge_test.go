@@ -0,0 +1,64 @@
+package s3readerat
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+// TestHedgeDelay tests that a GetObject request that has not responded within HedgeDelay is issued again, that the
+// read is served by the hedge, and that requests that respond in time are not hedged.
+func TestHedgeDelay(t *testing.T) {
+	f := newFakeS3(t)
+	f.put("bucket", "key", []byte("0123456789"))
+
+	stalled := make(chan struct{})
+	// The hedge is served concurrently with the stalled request, so only one may take the token.
+	token := make(chan struct{}, 1)
+	token <- struct{}{}
+	f.hook = func(w http.ResponseWriter, r *http.Request) bool {
+		if r.Method != http.MethodGet {
+			return false
+		}
+		select {
+		case <-token:
+		default:
+			return false
+		}
+
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+		close(stalled)
+		return true
+	}
+
+	s3ReaderAt, err := NewWithOptions(Options{Client: f.client(), Bucket: "bucket", Key: "key",
+		HedgeDelay: 20 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("Error calling NewWithOptions: %v", err)
+	}
+
+	b := make([]byte, 4)
+	if n, err := s3ReaderAt.ReadAt(b, 2); err != nil || string(b[:n]) != "2345" {
+		t.Fatalf("Expected to read %q, got %q (%v)", "2345", b[:n], err)
+	}
+	select {
+	case <-stalled:
+	case <-time.After(time.Second):
+		t.Fatalf("Expected the stalled request to be canceled")
+	}
+
+	if n, err := s3ReaderAt.ReadAt(b, 6); err != nil || string(b[:n]) != "6789" {
+		t.Fatalf("Expected to read %q, got %q (%v)", "6789", b[:n], err)
+	}
+
+	if stats := s3ReaderAt.Stats(); stats.HedgedRequests != 1 || stats.GetObjectRequests != 3 {
+		t.Fatalf("Expected 1 hedged request of 3 GetObject requests, got %d of %d", stats.HedgedRequests,
+			stats.GetObjectRequests)
+	}
+	if count := f.requestCount(http.MethodGet); count != 3 {
+		t.Fatalf("Expected 3 GetObject requests, got %d", count)
+	}
+}
@@ -0,0 +1,192 @@
+package s3readerat
+
+import (
+	"math/bits"
+	"time"
+)
+
+const (
+	// latencySubBucketBits sets the precision of a LatencyHistogram: each power of two is split into
+	// 1<<(latencySubBucketBits-1) buckets, so recorded values are kept to within 1/32 of their true value.
+	latencySubBucketBits = 6
+	latencySubBuckets    = 1 << latencySubBucketBits
+	latencyHalfBuckets   = latencySubBuckets / 2
+
+	// latencyUnit is the resolution of a LatencyHistogram.
+	latencyUnit = time.Microsecond
+)
+
+// LatencyHistogram records request latencies in logarithmic buckets, in the manner of an HDR histogram, so that
+// quantiles can be reported to within about 3% using memory that grows only with the logarithm of the slowest
+// request. The zero value is an empty histogram.
+type LatencyHistogram struct {
+	counts []int64
+	count  int64
+	min    time.Duration
+	max    time.Duration
+}
+
+// Latencies holds a LatencyHistogram for each kind of request an S3ReaderAt makes. Requests that failed are included.
+type Latencies struct {
+	HeadObject          LatencyHistogram
+	GetObject           LatencyHistogram
+	GetObjectAttributes LatencyHistogram
+}
+
+// Add returns the sum of l and other, for example to summarize the requests made by several S3ReaderAts.
+func (l Latencies) Add(other Latencies) Latencies {
+	return Latencies{
+		HeadObject:          l.HeadObject.Add(other.HeadObject),
+		GetObject:           l.GetObject.Add(other.GetObject),
+		GetObjectAttributes: l.GetObjectAttributes.Add(other.GetObjectAttributes),
+	}
+}
+
+// Latencies returns a snapshot of the latencies of the requests made so far, as measured for Stats.RequestTime. Use
+// it to monitor tail latency against an SLO, which Stats.AverageRequestTime hides. It is safe for concurrent use.
+func (ra *S3ReaderAt) Latencies() Latencies {
+	ra.statsMu.Lock()
+	defer ra.statsMu.Unlock()
+
+	return Latencies{
+		HeadObject:          ra.latencies.HeadObject.clone(),
+		GetObject:           ra.latencies.GetObject.clone(),
+		GetObjectAttributes: ra.latencies.GetObjectAttributes.clone(),
+	}
+}
+
+// record adds the latency of a completed request to l.
+func (l *Latencies) record(operation string, d time.Duration) {
+	switch operation {
+	case "HeadObject":
+		l.HeadObject.record(d)
+	case "GetObjectAttributes":
+		l.GetObjectAttributes.record(d)
+	default:
+		l.GetObject.record(d)
+	}
+}
+
+// Count returns the number of latencies recorded.
+func (h LatencyHistogram) Count() int64 {
+	return h.count
+}
+
+// Min returns the smallest latency recorded, or zero if there are none.
+func (h LatencyHistogram) Min() time.Duration {
+	return h.min
+}
+
+// Max returns the largest latency recorded, or zero if there are none.
+func (h LatencyHistogram) Max() time.Duration {
+	return h.max
+}
+
+// Quantile returns the latency at or below which the fraction q of recorded latencies fall, such as 0.99 for the
+// 99th percentile, rounded up to the top of its bucket. It returns zero if there are none.
+func (h LatencyHistogram) Quantile(q float64) time.Duration {
+	if h.count == 0 {
+		return 0
+	}
+	if q <= 0 {
+		return h.min
+	}
+	if q >= 1 {
+		return h.max
+	}
+
+	rank := int64(q*float64(h.count) + 0.5)
+	if rank < 1 {
+		rank = 1
+	}
+
+	var seen int64
+	for i, count := range h.counts {
+		seen += count
+		if seen >= rank {
+			d := time.Duration(latencyBucketMax(i)) * latencyUnit
+			if d > h.max {
+				d = h.max
+			}
+			if d < h.min {
+				d = h.min
+			}
+			return d
+		}
+	}
+
+	return h.max
+}
+
+// Add returns the sum of h and other.
+func (h LatencyHistogram) Add(other LatencyHistogram) LatencyHistogram {
+	sum := h.clone()
+	if other.count == 0 {
+		return sum
+	}
+	if sum.count == 0 || other.min < sum.min {
+		sum.min = other.min
+	}
+	if other.max > sum.max {
+		sum.max = other.max
+	}
+	sum.count += other.count
+
+	if len(other.counts) > len(sum.counts) {
+		sum.counts = append(sum.counts, make([]int64, len(other.counts)-len(sum.counts))...)
+	}
+	for i, count := range other.counts {
+		sum.counts[i] += count
+	}
+
+	return sum
+}
+
+// record adds d to h.
+func (h *LatencyHistogram) record(d time.Duration) {
+	if d < 0 {
+		d = 0
+	}
+
+	i := latencyBucket(int64(d / latencyUnit))
+	if i >= len(h.counts) {
+		h.counts = append(h.counts, make([]int64, i+1-len(h.counts))...)
+	}
+	h.counts[i]++
+
+	if h.count == 0 || d < h.min {
+		h.min = d
+	}
+	if d > h.max {
+		h.max = d
+	}
+	h.count++
+}
+
+// clone returns a copy of h that shares no memory with it.
+func (h LatencyHistogram) clone() LatencyHistogram {
+	h.counts = append([]int64(nil), h.counts...)
+	return h
+}
+
+// latencyBucket returns the index of the bucket holding v. Values below latencySubBuckets each have a bucket; above
+// that, each power of two is split into latencyHalfBuckets buckets.
+func latencyBucket(v int64) int {
+	if v < latencySubBuckets {
+		return int(v)
+	}
+
+	shift := bits.Len64(uint64(v)) - latencySubBucketBits
+	return latencySubBuckets + (shift-1)*latencyHalfBuckets + int(v>>uint(shift)) - latencyHalfBuckets
+}
+
+// latencyBucketMax returns the largest value in bucket i.
+func latencyBucketMax(i int) int64 {
+	if i < latencySubBuckets {
+		return int64(i)
+	}
+
+	shift := (i-latencySubBuckets)/latencyHalfBuckets + 1
+	top := int64((i-latencySubBuckets)%latencyHalfBuckets + latencyHalfBuckets)
+	return (top+1)<<uint(shift) - 1
+}
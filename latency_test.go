@@ -0,0 +1,74 @@
+package s3readerat
+
+import (
+	"testing"
+	"time"
+)
+
+// TestLatencyHistogram tests that quantiles are reported to within the histogram's precision, and that Add combines
+// histograms.
+func TestLatencyHistogram(t *testing.T) {
+	var h LatencyHistogram
+	if q := h.Quantile(0.5); q != 0 {
+		t.Fatalf("Expected an empty histogram's median to be zero, got %v", q)
+	}
+
+	// 1ms through 1000ms, so the p-th percentile is p*10ms.
+	for i := 1; i <= 1000; i++ {
+		h.record(time.Duration(i) * time.Millisecond)
+	}
+
+	if h.Count() != 1000 || h.Min() != time.Millisecond || h.Max() != time.Second {
+		t.Fatalf("Expected 1000 latencies from 1ms to 1s, got %d from %v to %v", h.Count(), h.Min(), h.Max())
+	}
+	for _, q := range []float64{0.5, 0.9, 0.99, 0.999} {
+		expected := time.Duration(q*1000) * time.Millisecond
+		if got := h.Quantile(q); got < expected || got > expected+expected/32 {
+			t.Fatalf("Expected quantile %v to be within 1/32 above %v, got %v", q, expected, got)
+		}
+	}
+	if got := h.Quantile(1); got != time.Second {
+		t.Fatalf("Expected quantile 1 to be the max, got %v", got)
+	}
+
+	var slow LatencyHistogram
+	slow.record(10 * time.Second)
+	sum := h.Add(slow)
+	if sum.Count() != 1001 || sum.Max() != 10*time.Second || sum.Min() != time.Millisecond {
+		t.Fatalf("Expected the sum to hold 1001 latencies from 1ms to 10s, got %d from %v to %v", sum.Count(),
+			sum.Min(), sum.Max())
+	}
+	if h.Count() != 1000 {
+		t.Fatalf("Expected Add not to modify its receiver")
+	}
+}
+
+// TestLatencies tests that an S3ReaderAt records the latency of each request by operation.
+func TestLatencies(t *testing.T) {
+	f := newFakeS3(t)
+	f.put("bucket", "key", []byte("0123456789"))
+
+	s3ReaderAt, err := NewWithOptions(Options{Client: f.client(), Bucket: "bucket", Key: "key"})
+	if err != nil {
+		t.Fatalf("Error calling NewWithOptions: %v", err)
+	}
+
+	if _, err = s3ReaderAt.Size(); err != nil {
+		t.Fatalf("Error calling Size: %v", err)
+	}
+	b := make([]byte, 2)
+	for i := 0; i < 3; i++ {
+		if _, err = s3ReaderAt.ReadAt(b, int64(i)); err != nil {
+			t.Fatalf("Error calling ReadAt: %v", err)
+		}
+	}
+
+	latencies := s3ReaderAt.Latencies()
+	if latencies.HeadObject.Count() != 1 || latencies.GetObject.Count() != 3 {
+		t.Fatalf("Expected 1 HeadObject and 3 GetObject latencies, got %d and %d", latencies.HeadObject.Count(),
+			latencies.GetObject.Count())
+	}
+	if p99 := latencies.GetObject.Quantile(0.99); p99 <= 0 || p99 > latencies.GetObject.Max() {
+		t.Fatalf("Expected a positive p99 no greater than the max, got %v", p99)
+	}
+}
@@ -0,0 +1,456 @@
+package s3readerat
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/pkg/errors"
+)
+
+// LayerFormat identifies the format of a lazily-pullable container image layer.
+type LayerFormat int
+
+const (
+	// LayerEStargz is an eStargz (or legacy stargz) layer: a gzip-compressed tar whose files start new gzip members,
+	// followed by a table of contents.
+	LayerEStargz LayerFormat = iota + 1
+
+	// LayerZstdChunked is a zstd:chunked layer: a zstd-compressed tar whose files start new zstd frames, followed by a
+	// table of contents in a skippable frame.
+	LayerZstdChunked
+)
+
+func (f LayerFormat) String() string {
+	switch f {
+	case LayerEStargz:
+		return "estargz"
+	case LayerZstdChunked:
+		return "zstd:chunked"
+	default:
+		return "unknown"
+	}
+}
+
+const (
+	// estargzFooterLen and stargzFooterLen are the lengths of the eStargz and legacy stargz footers, which are empty
+	// gzip members whose extra field holds the offset of the table of contents.
+	estargzFooterLen = 51
+	stargzFooterLen  = 47
+
+	// estargzTOCName is the name of the tar entry holding the eStargz table of contents.
+	estargzTOCName = "stargz.index.json"
+
+	// zstdSkippableFrameMagic begins the skippable frames that hold zstd:chunked metadata.
+	zstdSkippableFrameMagic = 0x184d2a50
+
+	// zstdChunkedManifestTypeCRFS is the only zstd:chunked table of contents format.
+	zstdChunkedManifestTypeCRFS = 1
+
+	// maxLayerTOCLen bounds the size of a table of contents, compressed or not.
+	maxLayerTOCLen = 50 << 20
+)
+
+// zstdChunkedMagic ends the zstd:chunked footer.
+var zstdChunkedMagic = []byte("GnUlInUx")
+
+// Layer gives access to the files in an eStargz or zstd:chunked container image layer without reading the whole
+// layer: the table of contents is read when the layer is opened, and each file's bytes are fetched and decompressed
+// as they are read.
+type Layer struct {
+	r       io.ReaderAt
+	format  LayerFormat
+	entries []*LayerEntry
+	byName  map[string]*LayerEntry
+}
+
+// LayerEntry describes a file, directory or link in a Layer.
+type LayerEntry struct {
+	// Name is the path of the entry, without a leading "./" or "/".
+	Name string
+
+	// Type is "reg", "dir", "symlink", "hardlink", "char", "block" or "fifo".
+	Type string
+
+	// LinkName is the target of a link.
+	LinkName string
+
+	Mode int64
+	Size int64
+
+	// Digest is the digest of a regular file's contents, if the layer records one.
+	Digest string
+
+	chunks []layerChunk
+}
+
+// layerChunk locates part of a regular file's contents: the compressed bytes [offset, end) of the layer decompress to
+// size bytes of the file starting at chunkOffset.
+type layerChunk struct {
+	chunkOffset int64
+	size        int64
+	offset      int64
+	end         int64
+}
+
+// layerTOCEntry is an entry in either format's JSON table of contents.
+type layerTOCEntry struct {
+	Name        string `json:"name"`
+	Type        string `json:"type"`
+	Size        int64  `json:"size"`
+	LinkName    string `json:"linkName"`
+	Mode        int64  `json:"mode"`
+	Digest      string `json:"digest"`
+	Offset      int64  `json:"offset"`
+	EndOffset   int64  `json:"endOffset"`
+	ChunkOffset int64  `json:"chunkOffset"`
+	ChunkSize   int64  `json:"chunkSize"`
+}
+
+// OpenLayer reads the table of contents of the eStargz or zstd:chunked layer in the first size bytes of r. Both are
+// stored at the end of the layer, so an S3ReaderAt with PrefetchTailBytes set can usually read them with one request.
+func OpenLayer(r io.ReaderAt, size int64) (*Layer, error) {
+	tailLen := int64(estargzFooterLen)
+	if tailLen > size {
+		tailLen = size
+	}
+
+	tail := make([]byte, tailLen)
+	if _, err := r.ReadAt(tail, size-tailLen); err != nil && err != io.EOF {
+		return nil, errors.Wrap(err, "unable to read layer footer")
+	}
+
+	var toc []layerTOCEntry
+	var format LayerFormat
+	var tocOffset int64
+	var err error
+	if zstdOffset, zstdLen, ok := parseZstdChunkedFooter(tail); ok {
+		format = LayerZstdChunked
+		tocOffset = zstdOffset
+		toc, err = readZstdChunkedTOC(r, zstdOffset, zstdLen)
+	} else if gzipOffset, ok := parseEStargzFooter(tail); ok {
+		format = LayerEStargz
+		tocOffset = gzipOffset
+		toc, err = readEStargzTOC(r, gzipOffset, size-gzipOffset)
+	} else {
+		return nil, errors.New("layer is neither eStargz nor zstd:chunked")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return newLayer(r, format, toc, tocOffset)
+}
+
+// parseEStargzFooter returns the offset of the table of contents from an eStargz or legacy stargz footer at the end
+// of tail.
+func parseEStargzFooter(tail []byte) (int64, bool) {
+	for _, footerLen := range []int{estargzFooterLen, stargzFooterLen} {
+		if len(tail) < footerLen {
+			continue
+		}
+
+		zr, err := gzip.NewReader(bytes.NewReader(tail[len(tail)-footerLen:]))
+		if err != nil {
+			continue
+		}
+		zr.Multistream(false)
+		if _, err = ioutil.ReadAll(zr); err != nil {
+			continue
+		}
+
+		extra := zr.Header.Extra
+		if footerLen == estargzFooterLen {
+			// The eStargz footer wraps the legacy payload in an "SG" subfield.
+			if len(extra) != 26 || extra[0] != 'S' || extra[1] != 'G' || binary.LittleEndian.Uint16(extra[2:]) != 22 {
+				continue
+			}
+			extra = extra[4:]
+		}
+		if len(extra) != 22 || string(extra[16:]) != "STARGZ" {
+			continue
+		}
+
+		offset, err := strconv.ParseInt(string(extra[:16]), 16, 64)
+		if err != nil {
+			continue
+		}
+		return offset, true
+	}
+
+	return 0, false
+}
+
+// readEStargzTOC reads the table of contents from the gzip member at off, which holds a tar containing
+// stargz.index.json.
+func readEStargzTOC(r io.ReaderAt, off int64, n int64) ([]layerTOCEntry, error) {
+	zr, err := gzip.NewReader(io.NewSectionReader(r, off, n))
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to read eStargz table of contents")
+	}
+
+	tr := tar.NewReader(zr)
+	header, err := tr.Next()
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to read eStargz table of contents")
+	}
+	if header.Name != estargzTOCName {
+		return nil, errors.Errorf("expected eStargz table of contents, found %q", header.Name)
+	}
+
+	return decodeLayerTOC(io.LimitReader(tr, maxLayerTOCLen))
+}
+
+// parseZstdChunkedFooter returns the offset and length of the compressed table of contents from a zstd:chunked footer
+// at the end of tail. The footer is a skippable frame whose last eight bytes are zstdChunkedMagic.
+func parseZstdChunkedFooter(tail []byte) (int64, int64, bool) {
+	const footerLen = 40
+	if len(tail) < footerLen+8 || !bytes.Equal(tail[len(tail)-8:], zstdChunkedMagic) {
+		return 0, 0, false
+	}
+
+	frame := tail[len(tail)-footerLen-8:]
+	if binary.LittleEndian.Uint32(frame) != zstdSkippableFrameMagic ||
+		binary.LittleEndian.Uint32(frame[4:]) != footerLen {
+		return 0, 0, false
+	}
+
+	footer := frame[8:]
+	offset := binary.LittleEndian.Uint64(footer)
+	length := binary.LittleEndian.Uint64(footer[8:])
+	manifestType := binary.LittleEndian.Uint64(footer[24:])
+	if manifestType != zstdChunkedManifestTypeCRFS || length > maxLayerTOCLen || offset > 1<<62 {
+		return 0, 0, false
+	}
+
+	return int64(offset), int64(length), true
+}
+
+// readZstdChunkedTOC reads the zstd-compressed table of contents of n bytes at off.
+func readZstdChunkedTOC(r io.ReaderAt, off int64, n int64) ([]layerTOCEntry, error) {
+	compressed := make([]byte, n)
+	if _, err := r.ReadAt(compressed, off); err != nil && err != io.EOF {
+		return nil, errors.Wrap(err, "unable to read zstd:chunked table of contents")
+	}
+
+	zr, err := zstd.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to read zstd:chunked table of contents")
+	}
+	defer zr.Close()
+
+	return decodeLayerTOC(io.LimitReader(zr, maxLayerTOCLen))
+}
+
+func decodeLayerTOC(r io.Reader) ([]layerTOCEntry, error) {
+	var toc struct {
+		Version int             `json:"version"`
+		Entries []layerTOCEntry `json:"entries"`
+	}
+	if err := json.NewDecoder(r).Decode(&toc); err != nil {
+		return nil, errors.Wrap(err, "invalid layer table of contents")
+	}
+	if toc.Version != 1 {
+		return nil, errors.Errorf("unsupported layer table of contents version %d", toc.Version)
+	}
+
+	return toc.Entries, nil
+}
+
+// newLayer builds a Layer from its table of contents. Chunks record where their compressed bytes start but not where
+// they end, so each is taken to end where the next one in the layer starts, or at the table of contents.
+func newLayer(r io.ReaderAt, format LayerFormat, toc []layerTOCEntry, tocOffset int64) (*Layer, error) {
+	l := &Layer{r: r, format: format, byName: make(map[string]*LayerEntry)}
+
+	offsets := []int64{tocOffset}
+	for _, t := range toc {
+		if t.Type == "reg" || t.Type == "chunk" {
+			offsets = append(offsets, t.Offset)
+			if t.EndOffset > 0 {
+				offsets = append(offsets, t.EndOffset)
+			}
+		}
+	}
+	sort.Slice(offsets, func(i, j int) bool { return offsets[i] < offsets[j] })
+
+	for _, t := range toc {
+		name := cleanLayerName(t.Name)
+		if t.Type == "chunk" {
+			entry, ok := l.byName[name]
+			if !ok || entry.Type != "reg" {
+				return nil, errors.Errorf("layer chunk for %q does not follow its file", t.Name)
+			}
+			chunk := layerChunk{chunkOffset: t.ChunkOffset, size: t.ChunkSize, offset: t.Offset}
+			entry.chunks = append(entry.chunks, chunk)
+			continue
+		}
+
+		entry := &LayerEntry{
+			Name:     name,
+			Type:     t.Type,
+			LinkName: t.LinkName,
+			Mode:     t.Mode,
+			Size:     t.Size,
+			Digest:   t.Digest,
+		}
+		if t.Type == "reg" && t.Size > 0 {
+			entry.chunks = []layerChunk{{chunkOffset: 0, size: t.ChunkSize, offset: t.Offset}}
+		}
+		l.entries = append(l.entries, entry)
+		l.byName[name] = entry
+	}
+
+	for _, entry := range l.entries {
+		for i := range entry.chunks {
+			chunk := &entry.chunks[i]
+			if chunk.size == 0 {
+				// Only the last chunk may omit its size.
+				chunk.size = entry.Size - chunk.chunkOffset
+			}
+
+			next := sort.Search(len(offsets), func(j int) bool { return offsets[j] > chunk.offset })
+			if next == len(offsets) {
+				return nil, errors.Errorf("layer chunk of %q at offset %d is past the table of contents", entry.Name,
+					chunk.offset)
+			}
+			chunk.end = offsets[next]
+		}
+	}
+
+	return l, nil
+}
+
+func cleanLayerName(name string) string {
+	name = strings.TrimPrefix(path.Clean("/"+name), "/")
+	if name == "" {
+		return "."
+	}
+	return name
+}
+
+// Format returns the format of the layer.
+func (l *Layer) Format() LayerFormat {
+	return l.format
+}
+
+// Entries returns the entries of the layer, in the order they appear in it.
+func (l *Layer) Entries() []*LayerEntry {
+	return l.entries
+}
+
+// Lookup returns the entry with the given name. Leading "./" and "/" are ignored.
+func (l *Layer) Lookup(name string) (*LayerEntry, bool) {
+	entry, ok := l.byName[cleanLayerName(name)]
+	return entry, ok
+}
+
+// OpenFile returns a reader over the contents of the regular file with the given name. Reads fetch and decompress
+// only the chunks of the file they touch; the most recently read chunk is kept, so sequential reads do not fetch it
+// again. The reader is safe for concurrent use.
+func (l *Layer) OpenFile(name string) (*io.SectionReader, error) {
+	entry, ok := l.Lookup(name)
+	if !ok {
+		return nil, errors.Errorf("layer has no file %q", name)
+	}
+	if entry.Type != "reg" {
+		return nil, errors.Errorf("layer entry %q is a %s, not a regular file", name, entry.Type)
+	}
+
+	return io.NewSectionReader(&layerFile{layer: l, entry: entry}, 0, entry.Size), nil
+}
+
+// layerFile is an io.ReaderAt over the contents of a regular file in a Layer.
+type layerFile struct {
+	layer *Layer
+	entry *LayerEntry
+
+	mu        sync.Mutex
+	lastChunk int
+	lastData  []byte
+}
+
+func (f *layerFile) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, ErrInvalidOffset
+	}
+
+	n := 0
+	for n < len(p) {
+		pos := off + int64(n)
+		if pos >= f.entry.Size {
+			return n, io.EOF
+		}
+
+		i := sort.Search(len(f.entry.chunks), func(i int) bool {
+			chunk := f.entry.chunks[i]
+			return chunk.chunkOffset+chunk.size > pos
+		})
+		if i == len(f.entry.chunks) {
+			return n, errors.Errorf("layer file %q has no chunk at offset %d", f.entry.Name, pos)
+		}
+
+		data, err := f.chunk(i)
+		if err != nil {
+			return n, err
+		}
+
+		n += copy(p[n:], data[pos-f.entry.chunks[i].chunkOffset:])
+	}
+
+	return n, nil
+}
+
+// chunk returns the decompressed contents of the chunk at index i.
+func (f *layerFile) chunk(i int) ([]byte, error) {
+	f.mu.Lock()
+	if f.lastData != nil && f.lastChunk == i {
+		data := f.lastData
+		f.mu.Unlock()
+		return data, nil
+	}
+	f.mu.Unlock()
+
+	chunk := f.entry.chunks[i]
+	compressed := make([]byte, chunk.end-chunk.offset)
+	if _, err := f.layer.r.ReadAt(compressed, chunk.offset); err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	var r io.Reader
+	switch f.layer.format {
+	case LayerEStargz:
+		zr, err := gzip.NewReader(bytes.NewReader(compressed))
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to decompress layer file %q", f.entry.Name)
+		}
+		r = zr
+	default:
+		zr, err := zstd.NewReader(bytes.NewReader(compressed))
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to decompress layer file %q", f.entry.Name)
+		}
+		defer zr.Close()
+		r = zr
+	}
+
+	data := make([]byte, chunk.size)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, errors.Wrapf(err, "unable to decompress layer file %q", f.entry.Name)
+	}
+
+	f.mu.Lock()
+	f.lastChunk, f.lastData = i, data
+	f.mu.Unlock()
+
+	return data, nil
+}
@@ -0,0 +1,228 @@
+package s3readerat
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// testLayerFile is a file to write to a test layer.
+type testLayerFile struct {
+	name     string
+	contents string
+}
+
+// layerWriter writes a tar to a sequence of compressed streams, each of which starts when next is called.
+type layerWriter struct {
+	t     *testing.T
+	buf   bytes.Buffer
+	open  io.WriteCloser
+	start func(w io.Writer) io.WriteCloser
+}
+
+func (w *layerWriter) Write(p []byte) (int, error) {
+	if w.open == nil {
+		w.next()
+	}
+	return w.open.Write(p)
+}
+
+// next closes the current stream, if any, and returns the offset at which the next one starts.
+func (w *layerWriter) next() int64 {
+	if w.open != nil {
+		if err := w.open.Close(); err != nil {
+			w.t.Fatalf("Error closing compressed stream: %v", err)
+		}
+	}
+	offset := int64(w.buf.Len())
+	w.open = w.start(&w.buf)
+	return offset
+}
+
+// writeTar writes files to w as a tar, starting a new stream for every chunkSize bytes of file contents, and returns
+// the table of contents.
+func (w *layerWriter) writeTar(files []testLayerFile, chunkSize int) []layerTOCEntry {
+	var toc []layerTOCEntry
+	tw := tar.NewWriter(w)
+	for _, file := range files {
+		header := &tar.Header{Name: file.name, Size: int64(len(file.contents)), Mode: 0644}
+		if err := tw.WriteHeader(header); err != nil {
+			w.t.Fatalf("Error writing tar header: %v", err)
+		}
+		for off := 0; off < len(file.contents); off += chunkSize {
+			end := off + chunkSize
+			if end > len(file.contents) {
+				end = len(file.contents)
+			}
+
+			entry := layerTOCEntry{Name: file.name, Type: "chunk", Offset: w.next(), ChunkOffset: int64(off)}
+			if off == 0 {
+				entry.Type = "reg"
+				entry.Size = int64(len(file.contents))
+			}
+			if end-off == chunkSize {
+				entry.ChunkSize = int64(chunkSize)
+			}
+			toc = append(toc, entry)
+
+			if _, err := io.WriteString(tw, file.contents[off:end]); err != nil {
+				w.t.Fatalf("Error writing tar contents: %v", err)
+			}
+		}
+	}
+	if err := tw.Close(); err != nil {
+		w.t.Fatalf("Error closing tar: %v", err)
+	}
+	return toc
+}
+
+// buildEStargz returns an eStargz layer containing files.
+func buildEStargz(t *testing.T, files []testLayerFile, chunkSize int) []byte {
+	w := &layerWriter{t: t, start: func(w io.Writer) io.WriteCloser { return gzip.NewWriter(w) }}
+	entries := w.writeTar(files, chunkSize)
+
+	tocJSON, err := json.Marshal(map[string]interface{}{"version": 1, "entries": entries})
+	if err != nil {
+		t.Fatalf("Error encoding table of contents: %v", err)
+	}
+	tocOffset := w.next()
+	tw := tar.NewWriter(w)
+	if err = tw.WriteHeader(&tar.Header{Name: estargzTOCName, Size: int64(len(tocJSON)), Mode: 0644}); err != nil {
+		t.Fatalf("Error writing tar header: %v", err)
+	}
+	if _, err = tw.Write(tocJSON); err != nil {
+		t.Fatalf("Error writing table of contents: %v", err)
+	}
+	if err = tw.Close(); err != nil {
+		t.Fatalf("Error closing tar: %v", err)
+	}
+	if err = w.open.Close(); err != nil {
+		t.Fatalf("Error closing gzip member: %v", err)
+	}
+
+	// The footer is an empty gzip member with a stored block, which compress/gzip no longer produces.
+	footer := []byte{0x1f, 0x8b, 8, 4, 0, 0, 0, 0, 0, 0xff, 26, 0, 'S', 'G', 22, 0}
+	footer = append(footer, fmt.Sprintf("%016xSTARGZ", tocOffset)...)
+	footer = append(footer, 1, 0, 0, 0xff, 0xff, 0, 0, 0, 0, 0, 0, 0, 0)
+	if len(footer) != estargzFooterLen {
+		t.Fatalf("Footer is %d bytes, expected %d", len(footer), estargzFooterLen)
+	}
+
+	return append(w.buf.Bytes(), footer...)
+}
+
+// buildZstdChunked returns a zstd:chunked layer containing files.
+func buildZstdChunked(t *testing.T, files []testLayerFile, chunkSize int) []byte {
+	w := &layerWriter{t: t, start: func(w io.Writer) io.WriteCloser {
+		zw, err := zstd.NewWriter(w)
+		if err != nil {
+			t.Fatalf("Error creating zstd writer: %v", err)
+		}
+		return zw
+	}}
+	entries := w.writeTar(files, chunkSize)
+	end := w.next()
+	for i := range entries {
+		if entries[i].Type == "reg" {
+			entries[i].EndOffset = end
+		}
+	}
+	if err := w.open.Close(); err != nil {
+		t.Fatalf("Error closing zstd frame: %v", err)
+	}
+
+	tocJSON, err := json.Marshal(map[string]interface{}{"version": 1, "entries": entries})
+	if err != nil {
+		t.Fatalf("Error encoding table of contents: %v", err)
+	}
+	zw, _ := zstd.NewWriter(nil)
+	compressed := zw.EncodeAll(tocJSON, nil)
+
+	skippable := func(data []byte) {
+		_ = binary.Write(&w.buf, binary.LittleEndian, []uint32{zstdSkippableFrameMagic, uint32(len(data))})
+		w.buf.Write(data)
+	}
+	tocOffset := int64(w.buf.Len()) + 8
+	skippable(compressed)
+
+	footer := make([]byte, 40)
+	binary.LittleEndian.PutUint64(footer, uint64(tocOffset))
+	binary.LittleEndian.PutUint64(footer[8:], uint64(len(compressed)))
+	binary.LittleEndian.PutUint64(footer[16:], uint64(len(tocJSON)))
+	binary.LittleEndian.PutUint64(footer[24:], zstdChunkedManifestTypeCRFS)
+	copy(footer[32:], zstdChunkedMagic)
+	skippable(footer)
+
+	return w.buf.Bytes()
+}
+
+// TestOpenLayer tests that OpenLayer reads the table of contents of eStargz and zstd:chunked layers, and that files
+// split into several chunks can be read in whole or in part.
+func TestOpenLayer(t *testing.T) {
+	files := []testLayerFile{
+		{"./bin/tool", "0123456789abcdefghijklmnopqrstuvwxyz"},
+		{"./etc/config", "key=value\n"},
+	}
+
+	for _, test := range []struct {
+		format LayerFormat
+		build  func(*testing.T, []testLayerFile, int) []byte
+	}{
+		{LayerEStargz, buildEStargz},
+		{LayerZstdChunked, buildZstdChunked},
+	} {
+		blob := test.build(t, files, 10)
+		r := &countingReaderAt{r: bytes.NewReader(blob)}
+
+		layer, err := OpenLayer(r, int64(len(blob)))
+		if err != nil {
+			t.Fatalf("Error calling OpenLayer for %s: %v", test.format, err)
+		}
+		if layer.Format() != test.format {
+			t.Fatalf("Expected format %s, got %s", test.format, layer.Format())
+		}
+		if len(layer.Entries()) != 2 || layer.Entries()[0].Name != "bin/tool" {
+			t.Fatalf("Unexpected %s entries %+v", test.format, layer.Entries())
+		}
+
+		for _, file := range files {
+			f, err := layer.OpenFile(file.name)
+			if err != nil {
+				t.Fatalf("Error calling OpenFile for %s: %v", test.format, err)
+			}
+			b, err := ioutil.ReadAll(f)
+			if err != nil {
+				t.Fatalf("Error reading %s from %s: %v", file.name, test.format, err)
+			}
+			if string(b) != file.contents {
+				t.Fatalf("Expected %s in %s to contain %q, got %q", file.name, test.format, file.contents, b)
+			}
+		}
+
+		f, err := layer.OpenFile("bin/tool")
+		if err != nil {
+			t.Fatalf("Error calling OpenFile for %s: %v", test.format, err)
+		}
+		calls := r.calls
+		b := make([]byte, 4)
+		if _, err = f.ReadAt(b, 24); err != nil {
+			t.Fatalf("Error calling ReadAt for %s: %v", test.format, err)
+		}
+		if string(b) != "opqr" || r.calls != calls+1 {
+			t.Fatalf("Expected to read \"opqr\" from one chunk of %s, got %q with %d reads", test.format, b,
+				r.calls-calls)
+		}
+
+		if _, err = layer.OpenFile("bin"); err == nil {
+			t.Fatalf("Expected an error opening a missing file in %s", test.format)
+		}
+	}
+}
@@ -0,0 +1,212 @@
+package s3readerat
+
+import (
+	"context"
+	"io"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// errPreempted is returned when a prefetch request is canceled to free its slot for a foreground request.
+var errPreempted = errors.New("prefetch request preempted by a foreground request")
+
+// priorityKey is the context key that marks requests as prefetches.
+type priorityKey struct{}
+
+// withPrefetchPriority returns a context whose requests are prefetches.
+func withPrefetchPriority(ctx context.Context) context.Context {
+	return context.WithValue(ctx, priorityKey{}, true)
+}
+
+func isPrefetch(ctx context.Context) bool {
+	prefetch, _ := ctx.Value(priorityKey{}).(bool)
+	return prefetch
+}
+
+// limiter bounds the number of requests in flight. It keeps two queues: foreground requests, made on behalf of a
+// caller that is waiting for the bytes, are always granted a slot before prefetch requests, which are speculative.
+// When a foreground request has to wait, the most recent prefetch request in flight is canceled to free its slot,
+// and is expected to be retried.
+type limiter struct {
+	mu         sync.Mutex
+	slots      int
+	inUse      int
+	foreground []*limiterSlot
+	prefetch   []*limiterSlot
+	inFlight   []*limiterSlot
+}
+
+// limiterSlot is a request's claim on the limiter, from when it starts waiting until it is released.
+type limiterSlot struct {
+	limiter   *limiter
+	prefetch  bool
+	ready     chan struct{}
+	cancel    context.CancelFunc
+	preempted bool
+	released  bool
+}
+
+func newLimiter(slots int) *limiter {
+	return &limiter{slots: slots}
+}
+
+// acquire waits for a slot. For prefetch requests, the returned context is canceled if the request is preempted.
+func (l *limiter) acquire(ctx context.Context) (*limiterSlot, context.Context, error) {
+	slot := &limiterSlot{limiter: l, prefetch: isPrefetch(ctx), ready: make(chan struct{})}
+	if slot.prefetch {
+		ctx, slot.cancel = context.WithCancel(ctx)
+	}
+
+	l.mu.Lock()
+	if l.inUse < l.slots && (!slot.prefetch || len(l.foreground) == 0) {
+		l.inUse++
+		l.grant(slot)
+		l.mu.Unlock()
+		return slot, ctx, nil
+	}
+
+	if slot.prefetch {
+		l.prefetch = append(l.prefetch, slot)
+	} else {
+		l.foreground = append(l.foreground, slot)
+		l.preempt()
+	}
+	l.mu.Unlock()
+
+	select {
+	case <-slot.ready:
+		return slot, ctx, nil
+	case <-ctx.Done():
+		l.mu.Lock()
+		select {
+		case <-slot.ready:
+			// The slot was granted as ctx was canceled.
+			l.mu.Unlock()
+			slot.release()
+		default:
+			l.foreground = removeSlot(l.foreground, slot)
+			l.prefetch = removeSlot(l.prefetch, slot)
+			l.mu.Unlock()
+		}
+		if slot.cancel != nil {
+			slot.cancel()
+		}
+		return nil, nil, ctx.Err()
+	}
+}
+
+// grant gives slot a slot that has already been counted in inUse. l.mu must be held.
+func (l *limiter) grant(slot *limiterSlot) {
+	if slot.prefetch {
+		l.inFlight = append(l.inFlight, slot)
+	}
+	close(slot.ready)
+}
+
+// preempt cancels the most recently granted prefetch request that has not already been preempted. l.mu must be held.
+func (l *limiter) preempt() {
+	for i := len(l.inFlight) - 1; i >= 0; i-- {
+		if slot := l.inFlight[i]; !slot.preempted {
+			slot.preempted = true
+			slot.cancel()
+			return
+		}
+	}
+}
+
+// resize changes the number of requests allowed in flight. If it grows, requests waiting are granted the new slots at
+// once; if it shrinks below the number in use, slots are retired as they are released.
+func (l *limiter) resize(slots int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.slots = slots
+	for l.inUse < l.slots {
+		var next *limiterSlot
+		switch {
+		case len(l.foreground) > 0:
+			next = l.foreground[0]
+			l.foreground = l.foreground[1:]
+		case len(l.prefetch) > 0:
+			next = l.prefetch[0]
+			l.prefetch = l.prefetch[1:]
+		default:
+			return
+		}
+		l.inUse++
+		l.grant(next)
+	}
+}
+
+// release returns the slot to the limiter, handing it to the next foreground request waiting, or else the next
+// prefetch request. It is safe to call more than once.
+func (s *limiterSlot) release() {
+	l := s.limiter
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if s.released {
+		return
+	}
+	s.released = true
+
+	if s.prefetch {
+		l.inFlight = removeSlot(l.inFlight, s)
+		s.cancel()
+	}
+
+	switch {
+	case l.inUse > l.slots:
+		// The limiter was resized, so retire the slot.
+		l.inUse--
+	case len(l.foreground) > 0:
+		next := l.foreground[0]
+		l.foreground = l.foreground[1:]
+		l.grant(next)
+	case len(l.prefetch) > 0:
+		next := l.prefetch[0]
+		l.prefetch = l.prefetch[1:]
+		l.grant(next)
+	default:
+		l.inUse--
+	}
+}
+
+// wasPreempted reports whether the slot's request was canceled for a foreground request.
+func (s *limiterSlot) wasPreempted() bool {
+	s.limiter.mu.Lock()
+	defer s.limiter.mu.Unlock()
+
+	return s.preempted
+}
+
+func removeSlot(slots []*limiterSlot, slot *limiterSlot) []*limiterSlot {
+	for i, s := range slots {
+		if s == slot {
+			return append(slots[:i], slots[i+1:]...)
+		}
+	}
+	return slots
+}
+
+// limitedBody releases a limiter slot when a response body is closed, and reports reads that failed because the
+// request was preempted as errPreempted.
+type limitedBody struct {
+	io.ReadCloser
+	slot *limiterSlot
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err != nil && err != io.EOF && b.slot.wasPreempted() {
+		err = errPreempted
+	}
+	return n, err
+}
+
+func (b *limitedBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.slot.release()
+	return err
+}
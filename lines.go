@@ -0,0 +1,149 @@
+package s3readerat
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultLineReaderReadahead is the number of bytes a LineReader fetches at a time by default.
+const DefaultLineReaderReadahead = 1 << 20
+
+// LineReaderOptions configures a LineReader.
+type LineReaderOptions struct {
+	// Delimiter separates records. The default is '\n'.
+	Delimiter byte
+
+	// Readahead is the number of bytes to fetch at a time, so that scanning many short records does not cost a
+	// request each. The default is DefaultLineReaderReadahead.
+	Readahead int
+}
+
+// LineReader iterates over the delimiter-separated records of a remote object, such as the lines of a log file or
+// JSONL dataset. Its interface resembles bufio.Scanner, with the addition of record offsets and seeking. It is not safe
+// for concurrent use.
+type LineReader struct {
+	r         io.ReaderAt
+	size      int64
+	delimiter byte
+	readahead int
+
+	br     *bufio.Reader
+	next   int64
+	record []byte
+	offset int64
+	err    error
+}
+
+// NewLineReader returns a LineReader over the first size bytes of r, positioned at the start.
+func NewLineReader(r io.ReaderAt, size int64, options LineReaderOptions) *LineReader {
+	if options.Delimiter == 0 {
+		options.Delimiter = '\n'
+	}
+
+	if options.Readahead <= 0 {
+		options.Readahead = DefaultLineReaderReadahead
+	}
+
+	return &LineReader{
+		r:         r,
+		size:      size,
+		delimiter: options.Delimiter,
+		readahead: options.Readahead,
+	}
+}
+
+// Scan advances to the next record, which is then available through Bytes and Offset. It returns false at the end of
+// the object or on error; Err distinguishes the two.
+func (lr *LineReader) Scan() bool {
+	if lr.err != nil {
+		return false
+	}
+
+	if lr.next >= lr.size {
+		lr.record = nil
+		return false
+	}
+
+	if lr.br == nil {
+		lr.br = bufio.NewReaderSize(io.NewSectionReader(lr.r, lr.next, lr.size-lr.next), lr.readahead)
+	}
+
+	record, err := lr.br.ReadBytes(lr.delimiter)
+	if err != nil && err != io.EOF {
+		lr.err = err
+		lr.record = nil
+		return false
+	}
+
+	lr.offset = lr.next
+	lr.next += int64(len(record))
+	lr.record = bytes.TrimSuffix(record, []byte{lr.delimiter})
+
+	return true
+}
+
+// Bytes returns the current record, without its delimiter. The slice is only valid until the next call to Scan.
+func (lr *LineReader) Bytes() []byte {
+	return lr.record
+}
+
+// Text returns the current record as a string, without its delimiter.
+func (lr *LineReader) Text() string {
+	return string(lr.record)
+}
+
+// Offset returns the offset of the current record within the object.
+func (lr *LineReader) Offset() int64 {
+	return lr.offset
+}
+
+// Err returns the first error encountered by Scan or SeekToLineContaining, if any.
+func (lr *LineReader) Err() error {
+	return lr.err
+}
+
+// SetOffset positions the LineReader so that the next call to Scan returns the record starting at offset. The caller
+// is responsible for offset being the start of a record; see SeekToLineContaining.
+func (lr *LineReader) SetOffset(offset int64) {
+	lr.next = offset
+	lr.br = nil
+	lr.record = nil
+}
+
+// SeekToLineContaining positions the LineReader so that the next call to Scan returns the record containing the byte
+// at offset. It searches backwards from offset for the preceding delimiter, which makes it possible to bisect large
+// sorted files or resume scanning from an arbitrary byte offset.
+func (lr *LineReader) SeekToLineContaining(offset int64) error {
+	if offset < 0 || offset > lr.size {
+		return errors.Wrapf(ErrInvalidOffset, "offset %d is outside [0, %d]", offset, lr.size)
+	}
+
+	chunk := make([]byte, lr.readahead)
+
+	end := offset
+	for end > 0 {
+		start := end - int64(len(chunk))
+		if start < 0 {
+			start = 0
+		}
+
+		b := chunk[:end-start]
+		if _, err := lr.r.ReadAt(b, start); err != nil && err != io.EOF {
+			lr.err = err
+			return err
+		}
+
+		if i := bytes.LastIndexByte(b, lr.delimiter); i >= 0 {
+			lr.SetOffset(start + int64(i) + 1)
+			return nil
+		}
+
+		end = start
+	}
+
+	lr.SetOffset(0)
+	return nil
+}
@@ -0,0 +1,58 @@
+package s3readerat
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestLineReaderScan tests that LineReader returns each record and its offset, including a final record without a
+// trailing delimiter.
+func TestLineReaderScan(t *testing.T) {
+	data := "alpha\nbeta\n\ngamma"
+	lr := NewLineReader(strings.NewReader(data), int64(len(data)), LineReaderOptions{Readahead: 16})
+
+	var records []string
+	var offsets []int64
+	for lr.Scan() {
+		records = append(records, lr.Text())
+		offsets = append(offsets, lr.Offset())
+	}
+
+	if err := lr.Err(); err != nil {
+		t.Fatalf("Error calling Scan: %v", err)
+	}
+
+	if strings.Join(records, ",") != "alpha,beta,,gamma" {
+		t.Fatalf("Unexpected records %q", records)
+	}
+
+	if offsets[0] != 0 || offsets[1] != 6 || offsets[2] != 11 || offsets[3] != 12 {
+		t.Fatalf("Unexpected offsets %v", offsets)
+	}
+}
+
+// TestLineReaderSeekToLineContaining tests that SeekToLineContaining positions the LineReader at the start of the
+// record containing an offset, searching back across readahead-sized chunks.
+func TestLineReaderSeekToLineContaining(t *testing.T) {
+	data := "first\n" + strings.Repeat("x", 50) + "\nlast"
+	lr := NewLineReader(strings.NewReader(data), int64(len(data)), LineReaderOptions{Readahead: 16})
+
+	for _, test := range []struct {
+		offset int64
+		want   string
+	}{
+		{0, "first"},
+		{5, "first"},
+		{6, strings.Repeat("x", 50)},
+		{40, strings.Repeat("x", 50)},
+		{int64(len(data)) - 1, "last"},
+	} {
+		if err := lr.SeekToLineContaining(test.offset); err != nil {
+			t.Fatalf("Error calling SeekToLineContaining: %v", err)
+		}
+
+		if !lr.Scan() || lr.Text() != test.want {
+			t.Fatalf("Expected %q at offset %d, got %q", test.want, test.offset, lr.Text())
+		}
+	}
+}
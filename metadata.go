@@ -0,0 +1,94 @@
+package s3readerat
+
+import (
+	"context"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// ObjectMetadata is the metadata of an object, as returned by a HeadObject request.
+type ObjectMetadata struct {
+	// Size is the size of the object in bytes.
+	Size int64
+
+	ContentType     string
+	ContentEncoding string
+	CacheControl    string
+	ETag            string
+	LastModified    time.Time
+
+	// VersionID is the version of the object, if the bucket is versioned.
+	VersionID string
+
+	// StorageClass is the storage class of the object. S3 omits it for objects in the STANDARD storage class, so it is
+	// then types.StorageClassStandard.
+	StorageClass types.StorageClass
+
+	// Metadata is the user metadata of the object, set with x-amz-meta- headers when it was uploaded. Its keys are
+	// lowercase.
+	Metadata map[string]string
+
+	// ChecksumCRC32, ChecksumCRC32C, ChecksumSHA1 and ChecksumSHA256 are the base64-encoded checksums of the object,
+	// for whichever algorithm it was uploaded with, if any. For a multipart-uploaded object, the checksum is of the
+	// checksums of its parts, as for PartsChecksums, followed by "-" and the number of parts.
+	ChecksumCRC32  string
+	ChecksumCRC32C string
+	ChecksumSHA1   string
+	ChecksumSHA256 string
+}
+
+// Metadata returns the metadata of the object, issuing a HeadObject request with ctx if none has been made yet. Size
+// issues the same request when called before the size of the object is known, so calling Metadata as well costs no
+// further request; otherwise, such as once ReadAt has learned the size from a GetObject response, Metadata makes its
+// own.
+func (ra *S3ReaderAt) Metadata(ctx context.Context) (ObjectMetadata, error) {
+	ra.metadataMu.Lock()
+	metadata := ra.metadata
+	ra.metadataMu.Unlock()
+
+	if metadata == nil {
+		if _, err := ra.statHead(ctx); err != nil {
+			return ObjectMetadata{}, err
+		}
+
+		ra.metadataMu.Lock()
+		metadata = ra.metadata
+		ra.metadataMu.Unlock()
+	}
+
+	copied := *metadata
+	copied.Metadata = make(map[string]string, len(metadata.Metadata))
+	for k, v := range metadata.Metadata {
+		copied.Metadata[k] = v
+	}
+	return copied, nil
+}
+
+// recordMetadata records the metadata in resp, the response to a HeadObject request, for Metadata.
+func (ra *S3ReaderAt) recordMetadata(resp *s3.HeadObjectOutput) {
+	metadata := &ObjectMetadata{
+		Size:            resp.ContentLength,
+		ContentType:     aws.ToString(resp.ContentType),
+		ContentEncoding: aws.ToString(resp.ContentEncoding),
+		CacheControl:    aws.ToString(resp.CacheControl),
+		ETag:            aws.ToString(resp.ETag),
+		LastModified:    aws.ToTime(resp.LastModified),
+		VersionID:       aws.ToString(resp.VersionId),
+		StorageClass:    resp.StorageClass,
+		Metadata:        resp.Metadata,
+		ChecksumCRC32:   aws.ToString(resp.ChecksumCRC32),
+		ChecksumCRC32C:  aws.ToString(resp.ChecksumCRC32C),
+		ChecksumSHA1:    aws.ToString(resp.ChecksumSHA1),
+		ChecksumSHA256:  aws.ToString(resp.ChecksumSHA256),
+	}
+	if metadata.StorageClass == "" {
+		metadata.StorageClass = types.StorageClassStandard
+	}
+
+	ra.metadataMu.Lock()
+	ra.metadata = metadata
+	ra.metadataMu.Unlock()
+}
@@ -0,0 +1,53 @@
+package s3readerat
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// TestMetadata tests that Metadata returns the metadata of the object from the HeadObject request Size makes, without
+// a request of its own, and that checksums are requested with checksum mode.
+func TestMetadata(t *testing.T) {
+	f := newFakeS3(t)
+	f.put("bucket", "key", []byte("0123456789"))
+	f.hook = func(w http.ResponseWriter, r *http.Request) bool {
+		if r.Method == http.MethodHead {
+			w.Header().Set("Content-Type", "text/plain")
+			w.Header().Set("X-Amz-Meta-Owner", "pipeline")
+			if r.Header.Get("X-Amz-Checksum-Mode") == "ENABLED" {
+				w.Header().Set("X-Amz-Checksum-Sha256", "checksum")
+			}
+		}
+		return false
+	}
+
+	s3ReaderAt, err := NewWithOptions(Options{Client: f.client(), Bucket: "bucket", Key: "key"})
+	if err != nil {
+		t.Fatalf("Error calling NewWithOptions: %v", err)
+	}
+	if _, err = s3ReaderAt.Size(); err != nil {
+		t.Fatalf("Error calling Size: %v", err)
+	}
+	metadata, err := s3ReaderAt.Metadata(context.Background())
+	if err != nil {
+		t.Fatalf("Error calling Metadata: %v", err)
+	}
+
+	if count := f.requestCount(http.MethodHead); count != 1 {
+		t.Fatalf("Expected 1 HeadObject request, got %d", count)
+	}
+	etag, _ := s3ReaderAt.ETag()
+	if metadata.Size != 10 || metadata.ContentType != "text/plain" || metadata.ETag != etag ||
+		metadata.LastModified.IsZero() || metadata.StorageClass != types.StorageClassStandard ||
+		metadata.Metadata["owner"] != "pipeline" || metadata.ChecksumSHA256 != "checksum" {
+		t.Fatalf("Unexpected metadata: %+v", metadata)
+	}
+
+	metadata.Metadata["owner"] = "changed"
+	if metadata, _ = s3ReaderAt.Metadata(context.Background()); metadata.Metadata["owner"] != "pipeline" {
+		t.Fatalf("Expected modifying the returned metadata to have no effect, got %+v", metadata)
+	}
+}
@@ -0,0 +1,76 @@
+// Package metrics provides an io.ReaderAt that counts the reads, bytes and errors of an underlying io.ReaderAt and
+// times them. See package cache for how decorators compose.
+package metrics
+
+import (
+	"io"
+	"sync"
+	"time"
+)
+
+// Stats summarizes the reads made through a ReaderAt.
+type Stats struct {
+	// Reads is the number of calls to ReadAt.
+	Reads int64
+
+	// Errors is the number of reads that failed with an error other than io.EOF.
+	Errors int64
+
+	// Bytes is the number of bytes read.
+	Bytes int64
+
+	// Time is the total time spent in ReadAt.
+	Time time.Duration
+}
+
+// Options configures a ReaderAt.
+type Options struct {
+	// Observe, if set, is called after each read with its offset, the number of bytes read, how long it took and its
+	// error, for example to feed a histogram.
+	Observe func(off int64, n int, d time.Duration, err error)
+}
+
+// ReaderAt records metrics about reads of an underlying io.ReaderAt. It is safe for concurrent use if the underlying
+// io.ReaderAt is.
+type ReaderAt struct {
+	r       io.ReaderAt
+	observe func(off int64, n int, d time.Duration, err error)
+
+	mu    sync.Mutex
+	stats Stats
+}
+
+// New returns a ReaderAt that records metrics about reads of r.
+func New(r io.ReaderAt, options Options) *ReaderAt {
+	return &ReaderAt{r: r, observe: options.Observe}
+}
+
+// ReadAt reads from the underlying io.ReaderAt, recording the read.
+func (m *ReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	start := time.Now()
+	n, err := m.r.ReadAt(p, off)
+	d := time.Since(start)
+
+	m.mu.Lock()
+	m.stats.Reads++
+	m.stats.Bytes += int64(n)
+	m.stats.Time += d
+	if err != nil && err != io.EOF {
+		m.stats.Errors++
+	}
+	m.mu.Unlock()
+
+	if m.observe != nil {
+		m.observe(off, n, d, err)
+	}
+
+	return n, err
+}
+
+// Stats returns a snapshot of the metrics recorded so far.
+func (m *ReaderAt) Stats() Stats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.stats
+}
@@ -0,0 +1,35 @@
+package metrics
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+)
+
+// TestReaderAt tests that reads, bytes and errors are counted, and that io.EOF is not counted as an error.
+func TestReaderAt(t *testing.T) {
+	observed := 0
+	m := New(bytes.NewReader([]byte("0123456789")), Options{
+		Observe: func(off int64, n int, d time.Duration, err error) { observed++ },
+	})
+
+	b := make([]byte, 4)
+	if _, err := m.ReadAt(b, 0); err != nil {
+		t.Fatalf("Error calling ReadAt: %v", err)
+	}
+	if _, err := m.ReadAt(b, 8); err != io.EOF {
+		t.Fatalf("Expected io.EOF, got %v", err)
+	}
+	if _, err := m.ReadAt(b, -1); err == nil {
+		t.Fatalf("Expected an error calling ReadAt")
+	}
+
+	stats := m.Stats()
+	if stats.Reads != 3 || stats.Bytes != 6 || stats.Errors != 1 {
+		t.Fatalf("Expected 3 reads, 6 bytes and 1 error, got %+v", stats)
+	}
+	if observed != 3 {
+		t.Fatalf("Expected Observe to be called 3 times, got %d", observed)
+	}
+}
@@ -0,0 +1,128 @@
+package s3readerat
+
+import (
+	"context"
+	"log"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	"github.com/aws/smithy-go"
+	smithymiddleware "github.com/aws/smithy-go/middleware"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+	"github.com/pkg/errors"
+)
+
+const (
+	// pacingMinDelay is the delay between requests after the first SlowDown response.
+	pacingMinDelay = 50 * time.Millisecond
+
+	// pacingMaxDelay caps the delay between requests.
+	pacingMaxDelay = 5 * time.Second
+)
+
+// pacer spaces out the requests for an object while S3 is responding with 503 SlowDown, which it does when a key or
+// prefix receives more requests than its partition can serve. Each request that sees a SlowDown, including attempts
+// the SDK retried, doubles the delay between requests; each request that does not shrinks it by a quarter, until it
+// falls below pacingMinDelay and pacing stops. Requests wait for the delay plus up to half as much again of random
+// jitter, so that concurrent readers do not retry in lockstep.
+type pacer struct {
+	mu    sync.Mutex
+	delay time.Duration
+}
+
+// wait blocks until the next request may be issued, or ctx is done.
+func (p *pacer) wait(ctx context.Context) error {
+	p.mu.Lock()
+	delay := p.delay
+	p.mu.Unlock()
+
+	if delay == 0 {
+		return nil
+	}
+
+	delay += time.Duration(rand.Int63n(int64(delay)/2 + 1))
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// observe adjusts the delay after a request that saw slowDowns SlowDown responses, and returns the new delay.
+func (p *pacer) observe(slowDowns int) time.Duration {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if slowDowns == 0 {
+		p.delay -= p.delay / 4
+		if p.delay < pacingMinDelay {
+			p.delay = 0
+		}
+		return p.delay
+	}
+
+	if p.delay == 0 {
+		p.delay = pacingMinDelay
+		slowDowns--
+	}
+	for ; slowDowns > 0 && p.delay < pacingMaxDelay; slowDowns-- {
+		p.delay *= 2
+	}
+	if p.delay > pacingMaxDelay {
+		p.delay = pacingMaxDelay
+	}
+	return p.delay
+}
+
+// countSlowDowns returns the number of SlowDown responses seen by a request, counting the attempts recorded in
+// metadata and the final error, if any.
+func countSlowDowns(metadata smithymiddleware.Metadata, err error) int {
+	n := 0
+	if results, ok := retry.GetAttemptResults(metadata); ok {
+		for _, result := range results.Results {
+			if result.Err != nil && isSlowDown(result.Err) {
+				n++
+			}
+		}
+	}
+
+	if err != nil && isSlowDown(err) {
+		n++
+	}
+
+	return n
+}
+
+// isSlowDown reports whether err is a SlowDown or other 503 response.
+func isSlowDown(err error) bool {
+	var apiError smithy.APIError
+	if errors.As(err, &apiError) && apiError.ErrorCode() == "SlowDown" {
+		return true
+	}
+
+	var responseError *smithyhttp.ResponseError
+	return errors.As(err, &responseError) && responseError.HTTPStatusCode() == http.StatusServiceUnavailable
+}
+
+// observeSlowDowns records the SlowDown responses seen by a request and adjusts the pacing of later requests.
+func (ra *S3ReaderAt) observeSlowDowns(metadata smithymiddleware.Metadata, err error) {
+	slowDowns := countSlowDowns(metadata, err)
+	if slowDowns > 0 {
+		ra.statsMu.Lock()
+		ra.stats.SlowDowns += int64(slowDowns)
+		ra.statsMu.Unlock()
+	}
+
+	delay := ra.pacer.observe(slowDowns)
+	if slowDowns > 0 && ra.debug() {
+		log.Printf("S3 object s3://%s/%s received %d SlowDown responses; pacing requests %v apart", ra.bucket, ra.key,
+			slowDowns, delay)
+	}
+}
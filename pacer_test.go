@@ -0,0 +1,54 @@
+package s3readerat
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+// TestSlowDownPacing tests that SlowDown responses space out later requests, and that the delay shrinks once requests
+// succeed.
+func TestSlowDownPacing(t *testing.T) {
+	f := newFakeS3(t)
+	f.put("bucket", "key", []byte("0123456789"))
+	slowDowns := 2
+	f.hook = func(w http.ResponseWriter, r *http.Request) bool {
+		if r.Method != http.MethodGet || slowDowns == 0 {
+			return false
+		}
+		slowDowns--
+		writeFakeError(w, http.StatusServiceUnavailable, "SlowDown", true)
+		return true
+	}
+
+	size := int64(10)
+	s3ReaderAt, err := NewWithOptions(Options{Client: f.client(), Bucket: "bucket", Key: "key", Size: &size})
+	if err != nil {
+		t.Fatalf("Error calling NewWithOptions: %v", err)
+	}
+
+	b := make([]byte, 2)
+	for i := 0; i < 2; i++ {
+		if _, err = s3ReaderAt.ReadAt(b, 0); err == nil {
+			t.Fatalf("Expected an error calling ReadAt")
+		}
+	}
+	if delay := s3ReaderAt.pacer.delay; delay != 2*pacingMinDelay {
+		t.Fatalf("Expected a delay of %v after two SlowDown responses, got %v", 2*pacingMinDelay, delay)
+	}
+
+	start := time.Now()
+	if _, err = s3ReaderAt.ReadAt(b, 0); err != nil {
+		t.Fatalf("Error calling ReadAt: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 2*pacingMinDelay {
+		t.Fatalf("Expected ReadAt to wait at least %v, waited %v", 2*pacingMinDelay, elapsed)
+	}
+	if delay := s3ReaderAt.pacer.delay; delay != 2*pacingMinDelay*3/4 {
+		t.Fatalf("Expected the delay to shrink to %v after a success, got %v", 2*pacingMinDelay*3/4, delay)
+	}
+
+	if stats := s3ReaderAt.Stats(); stats.SlowDowns != 2 {
+		t.Fatalf("Expected 2 SlowDowns, got %d", stats.SlowDowns)
+	}
+}
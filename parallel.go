@@ -0,0 +1,117 @@
+package s3readerat
+
+import (
+	"context"
+	"io"
+	"log"
+	"sync"
+)
+
+const (
+	// parallelReadPartSize is the default of Options.ParallelReadPartSize.
+	parallelReadPartSize = readIntoFilePartSize
+
+	// parallelReadConcurrency is the default of Options.ParallelReadConcurrency.
+	parallelReadConcurrency = readIntoFileConcurrency
+)
+
+// readRangeParallel reads the inclusive byte range [first, last] into p in parts of Options.ParallelReadPartSize
+// bytes, up to Options.ParallelReadConcurrency of them at once, each into its own slice of p. Unless the size of the
+// object is known, the first part is read alone to learn it, so that the others stop at the end of the object. If a
+// part fails, the others are canceled, and the bytes read before the first part that fell short are returned with the
+// error that failed it.
+func (ra *S3ReaderAt) readRangeParallel(ctx context.Context, p []byte, first int64, last int64) (int, error) {
+	t := ra.tuning()
+	partSize := t.ParallelReadPartSize
+	if partSize == 0 {
+		partSize = parallelReadPartSize
+	}
+	concurrency := t.ParallelReadConcurrency
+	if concurrency == 0 {
+		concurrency = parallelReadConcurrency
+	}
+	if int64(len(p)) <= partSize {
+		return ra.readRangeRetrying(ctx, p, first, last)
+	}
+
+	var n int
+	if ra.objectSize() < 0 {
+		read, err := ra.readRangeRetrying(ctx, p[:partSize], first, first+partSize-1)
+		if err != nil {
+			return read, err
+		}
+		n = read
+	}
+
+	var returnErr error
+	if size := ra.objectSize(); size >= 0 && last > size-1 {
+		last = size - 1
+		returnErr = io.EOF
+		if last < first+int64(n) {
+			return n, returnErr
+		}
+		p = p[:last-first+1]
+	}
+
+	start := first + int64(n)
+	parts := int((last - start + partSize) / partSize)
+	if ra.debug() {
+		log.Printf("Reading S3 object s3://%s/%s bytes %d-%d in %d parts of %d bytes", ra.bucket, ra.key, start, last,
+			parts, partSize)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	counts := make([]int, parts)
+	var wg sync.WaitGroup
+	var errOnce sync.Once
+	var firstErr error
+	fail := func(err error) {
+		errOnce.Do(func() {
+			firstErr = err
+			cancel()
+		})
+	}
+
+	slots := make(chan struct{}, concurrency)
+	for i := 0; i < parts; i++ {
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+			fail(ctx.Err())
+		}
+		if ctx.Err() != nil {
+			break
+		}
+
+		partFirst := start + int64(i)*partSize
+		partLast := partFirst + partSize - 1
+		if partLast > last {
+			partLast = last
+		}
+
+		wg.Add(1)
+		go func(i int, partFirst int64, partLast int64) {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			var err error
+			counts[i], err = ra.readRangeRetrying(ctx, p[partFirst-first:partLast-first+1], partFirst, partLast)
+			if err != nil {
+				fail(err)
+			}
+		}(i, partFirst, partLast)
+	}
+	wg.Wait()
+
+	for i, count := range counts {
+		n += count
+		partFirst := start + int64(i)*partSize
+		if int64(count) < partSize && partFirst+int64(count) <= last {
+			return n, firstErr
+		}
+	}
+
+	return n, returnErr
+}
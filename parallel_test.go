@@ -0,0 +1,48 @@
+package s3readerat
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+// TestParallelRead tests that reads over ParallelReadThreshold are fetched in parts, that the first part reveals the
+// size of the object so the others stop at its end, and that a failed part fails the read.
+func TestParallelRead(t *testing.T) {
+	data := bytes.Repeat([]byte("0123456789"), 10)
+	f := newFakeS3(t)
+	f.put("bucket", "key", data)
+
+	s3ReaderAt, err := NewWithOptions(Options{Client: f.client(), Bucket: "bucket", Key: "key",
+		ParallelReadThreshold: 10, ParallelReadPartSize: 16, ParallelReadConcurrency: 3})
+	if err != nil {
+		t.Fatalf("Error calling NewWithOptions: %v", err)
+	}
+
+	b := make([]byte, 120)
+	n, err := s3ReaderAt.ReadAt(b, 0)
+	if err != io.EOF || n != len(data) || !bytes.Equal(b[:n], data) {
+		t.Fatalf("Expected to read %d bytes with io.EOF, got %d (%v)", len(data), n, err)
+	}
+	if count := f.requestCount(http.MethodGet); count != 7 {
+		t.Fatalf("Expected 7 GetObject requests, got %d", count)
+	}
+	if count := f.requestCount(http.MethodHead); count != 0 {
+		t.Fatalf("Expected no HeadObject requests, got %d", count)
+	}
+
+	f.hook = func(w http.ResponseWriter, r *http.Request) bool {
+		if r.Header.Get("Range") != "bytes=37-52" {
+			return false
+		}
+		writeFakeError(w, http.StatusForbidden, "AccessDenied", true)
+		return true
+	}
+	b = make([]byte, 60)
+	if _, err = s3ReaderAt.ReadAt(b, 5); !errors.Is(err, ErrAccessDenied) {
+		t.Fatalf("Expected ErrAccessDenied from the failed part, got %v", err)
+	}
+}
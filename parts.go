@@ -0,0 +1,332 @@
+package s3readerat
+
+import (
+	"context"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"hash"
+	"hash/crc32"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	smithymiddleware "github.com/aws/smithy-go/middleware"
+	"github.com/pkg/errors"
+)
+
+// maxPartsPerRequest is the number of parts requested per GetObjectAttributes request, which is also the most S3 will
+// return.
+const maxPartsPerRequest = 1000
+
+// ErrPartChecksumMismatch is returned by ReadIntoFile when the bytes of a part of a multipart-uploaded object do not
+// match the checksum S3 recorded for it.
+var ErrPartChecksumMismatch = errors.New("S3 object part checksum mismatch")
+
+// PartChecksum describes one part of a multipart-uploaded object, as uploaded.
+type PartChecksum struct {
+	// Number is the part number, starting from 1.
+	Number int32
+
+	// Offset and Size locate the part's bytes within the object.
+	Offset int64
+	Size   int64
+
+	// Algorithm and Checksum are the checksum S3 recorded for the part, base64-encoded, or empty if the part was
+	// uploaded without one.
+	Algorithm types.ChecksumAlgorithm
+	Checksum  string
+}
+
+// newHash returns a hash.Hash computing the part's checksum, or nil if it has none that can be checked.
+func (p PartChecksum) newHash() hash.Hash {
+	if p.Checksum == "" {
+		return nil
+	}
+
+	switch p.Algorithm {
+	case types.ChecksumAlgorithmCrc32:
+		return crc32.NewIEEE()
+	case types.ChecksumAlgorithmCrc32c:
+		return crc32.New(crc32.MakeTable(crc32.Castagnoli))
+	case types.ChecksumAlgorithmSha1:
+		return sha1.New()
+	case types.ChecksumAlgorithmSha256:
+		return sha256.New()
+	default:
+		return nil
+	}
+}
+
+// verify returns an error wrapping ErrPartChecksumMismatch if h, which must come from newHash, does not match the
+// part's checksum.
+func (p PartChecksum) verify(h hash.Hash) error {
+	sum := base64.StdEncoding.EncodeToString(h.Sum(nil))
+	if sum != p.Checksum {
+		return errors.Wrapf(ErrPartChecksumMismatch, "part %d has %s checksum %s, but S3 recorded %s", p.Number,
+			p.Algorithm, sum, p.Checksum)
+	}
+
+	return nil
+}
+
+// multipartPartsCount returns the number of parts in the multipart ETag etag, or zero if it is not a multipart ETag.
+// S3 gives multipart-uploaded objects ETags of the form "<hash>-<parts>".
+func multipartPartsCount(etag string) int {
+	etag = strings.Trim(etag, `"`)
+	i := strings.LastIndexByte(etag, '-')
+	if i < 0 {
+		return 0
+	}
+
+	n, err := strconv.Atoi(etag[i+1:])
+	if err != nil || n < 1 {
+		return 0
+	}
+
+	return n
+}
+
+// PartsChecksums returns the parts the object was uploaded in, in order, with the checksums S3 recorded for them, or
+// nil if the object was not multipart-uploaded. S3 lists parts, paginating as needed, only for objects uploaded with
+// checksums. For other multipart-uploaded objects, the parts are assumed to be the same size as the first, which is
+// how most clients upload, and have no checksums. The parts are listed once and remembered.
+func (ra *S3ReaderAt) PartsChecksums() ([]PartChecksum, error) {
+	parts, err := ra.objectParts(ra.ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Callers may modify the result, so return a copy.
+	return append([]PartChecksum(nil), parts...), nil
+}
+
+// objectParts implements PartsChecksums, without copying the result. Parts are listed with GetObjectAttributes.
+func (ra *S3ReaderAt) objectParts(ctx context.Context) ([]PartChecksum, error) {
+	ra.partsMu.Lock()
+	defer ra.partsMu.Unlock()
+
+	if ra.partsKnown {
+		return ra.parts, nil
+	}
+
+	size, err := ra.Size()
+	if err != nil {
+		return nil, err
+	}
+
+	etag, err := ra.ETag()
+	if err != nil {
+		return nil, err
+	}
+
+	var parts []PartChecksum
+	if count := multipartPartsCount(etag); count > 0 {
+		if parts, err = ra.listParts(ctx); err != nil {
+			return nil, err
+		}
+
+		if parts == nil {
+			if parts, err = ra.uniformParts(ctx, count, size); err != nil {
+				return nil, err
+			}
+		}
+
+		var total int64
+		for _, part := range parts {
+			total += part.Size
+		}
+		if total != size {
+			return nil, errors.Errorf("S3 object parts total %d bytes, but the object is %d bytes", total, size)
+		}
+	}
+
+	if ra.debug() {
+		log.Printf("S3 object s3://%s/%s has %d parts", ra.bucket, ra.key, len(parts))
+	}
+
+	ra.parts = parts
+	ra.partsKnown = true
+
+	return parts, nil
+}
+
+// listParts lists the object's parts with GetObjectAttributes. It returns nil if S3 does not list them, as happens
+// when the object was uploaded without checksums.
+func (ra *S3ReaderAt) listParts(ctx context.Context) ([]PartChecksum, error) {
+	var parts []PartChecksum
+	var offset int64
+	var marker *string
+	for {
+		resp, err := ra.getObjectAttributesPage(ctx, marker)
+		if err != nil {
+			return nil, err
+		}
+
+		// Unlike other responses, GetObjectAttributes returns the ETag without quotes.
+		etag := aws.ToString(resp.ETag)
+		if etag != "" && !strings.HasPrefix(etag, `"`) {
+			etag = `"` + etag + `"`
+		}
+		if err = ra.checkETag(etag); err != nil {
+			return nil, err
+		}
+
+		if resp.ObjectParts == nil || len(resp.ObjectParts.Parts) == 0 {
+			return nil, nil
+		}
+
+		for _, p := range resp.ObjectParts.Parts {
+			part := PartChecksum{Number: p.PartNumber, Offset: offset, Size: p.Size}
+			switch {
+			case p.ChecksumCRC32 != nil:
+				part.Algorithm, part.Checksum = types.ChecksumAlgorithmCrc32, *p.ChecksumCRC32
+			case p.ChecksumCRC32C != nil:
+				part.Algorithm, part.Checksum = types.ChecksumAlgorithmCrc32c, *p.ChecksumCRC32C
+			case p.ChecksumSHA1 != nil:
+				part.Algorithm, part.Checksum = types.ChecksumAlgorithmSha1, *p.ChecksumSHA1
+			case p.ChecksumSHA256 != nil:
+				part.Algorithm, part.Checksum = types.ChecksumAlgorithmSha256, *p.ChecksumSHA256
+			}
+			parts = append(parts, part)
+			offset += p.Size
+		}
+
+		if !resp.ObjectParts.IsTruncated || resp.ObjectParts.NextPartNumberMarker == nil {
+			return parts, nil
+		}
+		marker = resp.ObjectParts.NextPartNumberMarker
+	}
+}
+
+// getObjectAttributesPage issues a GetObjectAttributes request for the object's parts following marker.
+func (ra *S3ReaderAt) getObjectAttributesPage(ctx context.Context, marker *string) (*s3.GetObjectAttributesOutput,
+	error) {
+	if ra.debug() {
+		log.Printf("Issuing a GetObjectAttributes request for S3 object s3://%s/%s", ra.bucket, ra.key)
+	}
+
+	if limiter := ra.tuning().limiter; limiter != nil {
+		slot, _, err := limiter.acquire(ctx)
+		if err != nil {
+			return nil, err
+		}
+		defer slot.release()
+	}
+
+	if err := ra.pacer.wait(ctx); err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	resp, err := ra.getObjectAttributes(ctx, &s3.GetObjectAttributesInput{
+		Bucket:           aws.String(ra.bucket),
+		Key:              aws.String(ra.key),
+		ObjectAttributes: []types.ObjectAttributes{types.ObjectAttributesEtag, types.ObjectAttributesObjectParts},
+		MaxParts:         maxPartsPerRequest,
+		PartNumberMarker: marker,
+	})
+	if err != nil {
+		ra.observeSlowDowns(smithymiddleware.Metadata{}, err)
+		ra.recordRequest(RequestLogEntry{Operation: "GetObjectAttributes", Tags: tagsFromContext(ctx)}, start, err)
+		return nil, errors.Wrap(classifyError(err), "S3 GetObjectAttributes failed")
+	}
+	ra.observeSlowDowns(resp.ResultMetadata, nil)
+	status := responseStatus(resp.ResultMetadata, nil)
+	entry := RequestLogEntry{Operation: "GetObjectAttributes", Status: status, Tags: tagsFromContext(ctx)}
+	ra.recordRequest(entry, start, nil)
+
+	return resp, nil
+}
+
+// uniformParts returns count parts, each the size of the first except perhaps the last, after checking with a
+// HeadObject request for the first part that this accounts for the whole object.
+func (ra *S3ReaderAt) uniformParts(ctx context.Context, count int, size int64) ([]PartChecksum, error) {
+	if ra.debug() {
+		log.Printf("Issuing a HeadObject request for part 1 of S3 object s3://%s/%s", ra.bucket, ra.key)
+	}
+
+	if limiter := ra.tuning().limiter; limiter != nil {
+		slot, _, err := limiter.acquire(ctx)
+		if err != nil {
+			return nil, err
+		}
+		defer slot.release()
+	}
+
+	if err := ra.pacer.wait(ctx); err != nil {
+		return nil, err
+	}
+
+	deadline := ra.startRequest(ctx)
+	defer deadline.cancel()
+
+	start := time.Now()
+	resp, err := ra.headObject(deadline.ctx, &s3.HeadObjectInput{
+		Bucket:     aws.String(ra.bucket),
+		Key:        aws.String(ra.key),
+		PartNumber: 1,
+	})
+	if err != nil {
+		ra.observeSlowDowns(smithymiddleware.Metadata{}, err)
+		ra.recordRequest(RequestLogEntry{Operation: "HeadObject", Tags: tagsFromContext(ctx)}, start, err)
+		return nil, deadline.wrap(errors.Wrap(classifyError(ra.pinError(err)), "S3 HeadObject failed"))
+	}
+	ra.observeSlowDowns(resp.ResultMetadata, nil)
+	status := responseStatus(resp.ResultMetadata, nil)
+	ra.recordRequest(RequestLogEntry{Operation: "HeadObject", Status: status, Tags: tagsFromContext(ctx)}, start, nil)
+
+	if err = ra.checkETag(aws.ToString(resp.ETag)); err != nil {
+		return nil, err
+	}
+
+	if resp.PartsCount != 0 {
+		count = int(resp.PartsCount)
+	}
+
+	partSize := resp.ContentLength
+	if partSize <= 0 || int64(count-1)*partSize >= size || int64(count)*partSize < size {
+		return nil, errors.Errorf("S3 object of %d bytes is not %d parts of %d bytes", size, count, partSize)
+	}
+
+	parts := make([]PartChecksum, count)
+	for i := range parts {
+		parts[i] = PartChecksum{Number: int32(i + 1), Offset: int64(i) * partSize, Size: partSize}
+	}
+	parts[count-1].Size = size - parts[count-1].Offset
+
+	return parts, nil
+}
+
+func (ra *S3ReaderAt) getObjectAttributes(ctx context.Context, input *s3.GetObjectAttributesInput) (
+	*s3.GetObjectAttributesOutput, error) {
+	if err := ra.checkClosed(); err != nil {
+		return nil, err
+	}
+	input.VersionId, _ = ra.pins()
+	input.RequestPayer = ra.payer
+	input.ExpectedBucketOwner = optionalString(ra.owner)
+	client := ra.regionalClient()
+
+	resp, originalErr := client.GetObjectAttributes(ctx, input, ra.requestOptions()...)
+	if originalErr == nil {
+		return resp, nil
+	}
+
+	region, err := ra.redirectRegion(ctx, originalErr)
+	if err != nil {
+		return nil, err
+	}
+
+	client = ra.s3ClientInRegion(region)
+	if client == nil {
+		return nil, originalErr
+	}
+	ra.rememberRegion(region)
+
+	return client.GetObjectAttributes(ctx, input, ra.requestOptions()...)
+}
@@ -0,0 +1,121 @@
+package s3readerat
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"io/ioutil"
+	"reflect"
+	"testing"
+)
+
+// TestPartsChecksums tests that PartsChecksums lists every part of a multipart-uploaded object across pages, only
+// once, and nothing for other objects.
+func TestPartsChecksums(t *testing.T) {
+	data := make([]byte, 2500)
+	for i := range data {
+		data[i] = byte(i % 251)
+	}
+
+	f := newFakeS3(t)
+	f.putMultipart("bucket", "multipart", data, 1000, true)
+	f.put("bucket", "single", data)
+
+	s3ReaderAt, err := NewWithOptions(Options{Client: f.client(), Bucket: "bucket", Key: "multipart"})
+	if err != nil {
+		t.Fatalf("Error calling NewWithOptions: %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		parts, err := s3ReaderAt.PartsChecksums()
+		if err != nil {
+			t.Fatalf("Error calling PartsChecksums: %v", err)
+		}
+		if len(parts) != 3 {
+			t.Fatalf("Expected 3 parts, got %d", len(parts))
+		}
+		for j, part := range parts {
+			end := int64(j+1) * 1000
+			if end > int64(len(data)) {
+				end = int64(len(data))
+			}
+			sum := sha256.Sum256(data[int64(j)*1000 : end])
+			expected := PartChecksum{
+				Number:    int32(j + 1),
+				Offset:    int64(j) * 1000,
+				Size:      end - int64(j)*1000,
+				Algorithm: "SHA256",
+				Checksum:  base64.StdEncoding.EncodeToString(sum[:]),
+			}
+			if part != expected {
+				t.Fatalf("Expected part %+v, got %+v", expected, part)
+			}
+		}
+	}
+	if stats := s3ReaderAt.Stats(); stats.GetObjectAttributesRequests != 2 {
+		t.Fatalf("Expected 2 GetObjectAttributes requests, got %d", stats.GetObjectAttributesRequests)
+	}
+
+	s3ReaderAt, err = NewWithOptions(Options{Client: f.client(), Bucket: "bucket", Key: "single"})
+	if err != nil {
+		t.Fatalf("Error calling NewWithOptions: %v", err)
+	}
+	parts, err := s3ReaderAt.PartsChecksums()
+	if err != nil {
+		t.Fatalf("Error calling PartsChecksums: %v", err)
+	}
+	if parts != nil {
+		t.Fatalf("Expected no parts for an object that was not multipart-uploaded, got %v", parts)
+	}
+}
+
+// TestPartsChecksumsUnevenParts tests that parts of different sizes are listed at their own offsets when they were
+// uploaded with checksums, and that without checksums, parts that are not all the size of the first are rejected
+// rather than assumed, leaving ReadIntoFile to copy the object in tuned parts.
+func TestPartsChecksumsUnevenParts(t *testing.T) {
+	data := make([]byte, 2500)
+	for i := range data {
+		data[i] = byte(i % 251)
+	}
+
+	f := newFakeS3(t)
+	f.putParts("bucket", "checksums", data, []int64{1000, 1200, 300}, true)
+	f.putParts("bucket", "uneven", data, []int64{500, 1500, 500}, false)
+
+	s3ReaderAt, err := NewWithOptions(Options{Client: f.client(), Bucket: "bucket", Key: "checksums"})
+	if err != nil {
+		t.Fatalf("Error calling NewWithOptions: %v", err)
+	}
+	parts, err := s3ReaderAt.PartsChecksums()
+	if err != nil {
+		t.Fatalf("Error calling PartsChecksums: %v", err)
+	}
+	var offsets, sizes []int64
+	for _, part := range parts {
+		offsets, sizes = append(offsets, part.Offset), append(sizes, part.Size)
+	}
+	if !reflect.DeepEqual(offsets, []int64{0, 1000, 2200}) || !reflect.DeepEqual(sizes, []int64{1000, 1200, 300}) {
+		t.Fatalf("Expected parts at offsets 0, 1000 and 2200 of 1000, 1200 and 300 bytes, got %+v", parts)
+	}
+
+	s3ReaderAt, err = NewWithOptions(Options{Client: f.client(), Bucket: "bucket", Key: "uneven"})
+	if err != nil {
+		t.Fatalf("Error calling NewWithOptions: %v", err)
+	}
+	if parts, err = s3ReaderAt.PartsChecksums(); err == nil {
+		t.Fatalf("Expected an error for parts of 500, 1500 and 500 bytes, got %+v", parts)
+	}
+
+	file, err := ioutil.TempFile(t.TempDir(), "ReadIntoFile")
+	if err != nil {
+		t.Fatalf("Error creating temporary file: %v", err)
+	}
+	defer file.Close()
+	if err = s3ReaderAt.ReadIntoFile(context.Background(), file, 0, -1); err != nil {
+		t.Fatalf("Error calling ReadIntoFile: %v", err)
+	}
+	if b, err := ioutil.ReadFile(file.Name()); err != nil || !bytes.Equal(b, data) {
+		t.Fatalf("File contents differ from the object (%v)", err)
+	}
+}
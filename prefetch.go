@@ -0,0 +1,160 @@
+package s3readerat
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+// prefetchHead fetches the first headLen bytes of the object as its head, unless the head has already been fetched. If
+// the size of the object is not yet known, it is taken from the response's Content-Range header.
+func (ra *S3ReaderAt) prefetchHead(headLen int64) error {
+	ra.headMu.Lock()
+	defer ra.headMu.Unlock()
+
+	if ra.head != nil {
+		return nil
+	}
+
+	if size := ra.objectSize(); size >= 0 && headLen > size {
+		headLen = size
+	}
+	if headLen == 0 {
+		return nil
+	}
+
+	if ra.debug() {
+		log.Printf("Prefetching the first %d bytes of S3 object s3://%s/%s", headLen, ra.bucket, ra.key)
+	}
+
+	resp, err := ra.getRange(ra.ctx, 0, headLen-1)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if ra.objectSize() < 0 {
+		size, err := parseContentRange(aws.ToString(resp.ContentRange))
+		if err != nil {
+			return err
+		}
+
+		if size < headLen {
+			headLen = size
+		}
+
+		ra.setSize(size)
+	}
+
+	head := make([]byte, headLen)
+	if _, err = ra.readBody(resp, head); err != nil {
+		return err
+	}
+	ra.head = head
+
+	return nil
+}
+
+// readFromHead copies the range starting at off into p from the prefetched head of the object, fetching the head first
+// if necessary. It reports whether the range lay entirely within the head; if not, p is left untouched. If ctx has a
+// deadline, the head is trimmed to the bytes expected to arrive before it, but never to fewer than the read needs;
+// later reads beyond a trimmed head are made directly.
+func (ra *S3ReaderAt) readFromHead(ctx context.Context, p []byte, off int64) (bool, error) {
+	end := off + int64(len(p))
+	headBytes := ra.tuning().PrefetchHeadBytes
+	if headBytes <= 0 || end > headBytes {
+		return false, nil
+	}
+
+	headLen := ra.trimToDeadline(ctx, headBytes)
+	if headLen < end {
+		headLen = end
+	}
+	if headLen < headBytes && ra.debug() {
+		log.Printf("Trimming the head of S3 object s3://%s/%s to %d bytes to meet the deadline", ra.bucket, ra.key,
+			headLen)
+	}
+
+	if err := ra.prefetchHead(headLen); err != nil {
+		return false, err
+	}
+
+	ra.headMu.Lock()
+	defer ra.headMu.Unlock()
+
+	if off+int64(len(p)) > int64(len(ra.head)) {
+		return false, nil
+	}
+
+	copy(p, ra.head[off:])
+	ra.recordCacheHit(ctx, p, off)
+	return true, nil
+}
+
+// readFromTail copies the range starting at off into p from the prefetched tail of the object, fetching the tail first
+// if necessary. It reports whether the range lay entirely within the tail; if not, p is left untouched. If ctx has a
+// deadline, the tail is trimmed as readFromHead trims the head.
+func (ra *S3ReaderAt) readFromTail(ctx context.Context, p []byte, off int64) (bool, error) {
+	tailLen := ra.tuning().PrefetchTailBytes
+	if tailLen <= 0 {
+		return false, nil
+	}
+
+	size := ra.objectSize()
+	if tailLen > size {
+		tailLen = size
+	}
+
+	if off < size-tailLen || off+int64(len(p)) > size {
+		return false, nil
+	}
+
+	ra.tailMu.Lock()
+	defer ra.tailMu.Unlock()
+
+	if ra.tail == nil {
+		if trimmed := ra.trimToDeadline(ctx, tailLen); trimmed < tailLen {
+			if trimmed < size-off {
+				trimmed = size - off
+			}
+			if ra.debug() {
+				log.Printf("Trimming the tail of S3 object s3://%s/%s to %d bytes to meet the deadline", ra.bucket,
+					ra.key, trimmed)
+			}
+			tailLen = trimmed
+		}
+
+		if ra.debug() {
+			log.Printf("Prefetching the last %d bytes of S3 object s3://%s/%s", tailLen, ra.bucket, ra.key)
+		}
+
+		tail := make([]byte, tailLen)
+		if _, err := ra.readRange(ra.ctx, tail, size-tailLen, size-1); err != nil {
+			return false, err
+		}
+		ra.tail = tail
+	}
+
+	tailOffset := size - int64(len(ra.tail))
+	if off < tailOffset {
+		return false, nil
+	}
+
+	copy(p, ra.tail[off-tailOffset:])
+	ra.recordCacheHit(ctx, p, off)
+	return true, nil
+}
+
+// recordCacheHit records a read of p at off, made with ctx, that was served from memory.
+func (ra *S3ReaderAt) recordCacheHit(ctx context.Context, p []byte, off int64) {
+	ra.recordRequest(RequestLogEntry{
+		Operation: "GetObject",
+		Range:     fmt.Sprintf("bytes=%d-%d", off, off+int64(len(p))-1),
+		Bytes:     int64(len(p)),
+		CacheHit:  true,
+		Tags:      tagsFromContext(ctx),
+	}, time.Now(), nil)
+}
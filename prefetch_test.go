@@ -0,0 +1,48 @@
+package s3readerat
+
+import (
+	"bytes"
+	"net/http"
+	"testing"
+)
+
+// TestPrefetchHeadDiscoversSize tests that PrefetchHeadBytes determines the size of the object from the head prefetch
+// rather than a HeadObject request, and that reads within the head are served from memory.
+func TestPrefetchHeadDiscoversSize(t *testing.T) {
+	f := newFakeS3(t)
+	data := bytes.Repeat([]byte("0123456789"), 1000)
+	f.put("bucket", "key", data)
+
+	s3ReaderAt, err := NewWithOptions(Options{Client: f.client(), Bucket: "bucket", Key: "key", PrefetchHeadBytes: 512})
+	if err != nil {
+		t.Fatalf("Error calling NewWithOptions: %v", err)
+	}
+
+	size, err := s3ReaderAt.Size()
+	if err != nil {
+		t.Fatalf("Error calling Size: %v", err)
+	}
+
+	if size != int64(len(data)) {
+		t.Fatalf("Expected size %d, got %d", len(data), size)
+	}
+
+	b := make([]byte, 100)
+	for _, off := range []int64{0, 256, 412} {
+		if _, err = s3ReaderAt.ReadAt(b, off); err != nil {
+			t.Fatalf("Error calling ReadAt: %v", err)
+		}
+
+		if !bytes.Equal(b, data[off:off+100]) {
+			t.Fatalf("Read unexpected bytes at offset %d", off)
+		}
+	}
+
+	if count := f.requestCount(http.MethodHead); count != 0 {
+		t.Fatalf("Expected no HeadObject requests, got %d", count)
+	}
+
+	if count := f.requestCount(http.MethodGet); count != 1 {
+		t.Fatalf("Expected 1 GetObject request, got %d", count)
+	}
+}
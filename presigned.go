@@ -0,0 +1,232 @@
+package s3readerat
+
+import (
+	"context"
+	"encoding/xml"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+	"github.com/pkg/errors"
+)
+
+// errPresignedNotSupported is returned for requests that cannot be made with a presigned GetObject URL.
+var errPresignedNotSupported = errors.New("not supported with a presigned URL")
+
+// NewFromPresignedURL creates a new S3ReaderAt that reads the object a presigned GetObject URL names with range
+// requests over plain HTTP, without an s3.Client or AWS credentials, so that a service can hand out presigned URLs
+// and its clients still get random access. Since the URL can only be used for GET requests, the object's size and
+// ETag are taken from a request for its first byte rather than from a HeadObject request. Reads fail with
+// ErrAccessDenied once the URL expires. The parts of multipart-uploaded objects cannot be listed, so PartsChecksums
+// fails, ReadIntoFile uses tuned parts and Diff samples rather than comparing part checksums.
+func NewFromPresignedURL(rawURL string) (*S3ReaderAt, error) {
+	return NewFromPresignedURLWithOptions(rawURL, Options{})
+}
+
+// NewFromPresignedURLWithOptions is like NewFromPresignedURL, but takes Options as NewWithOptions does, other than
+// Client, Options and API, which must not be provided, and Bucket and Key, which are taken from the URL. VersionID,
+// RequestPayer and ExpectedBucketOwner must be signed into the URL instead.
+func NewFromPresignedURLWithOptions(rawURL string, options Options) (*S3ReaderAt, error) {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return nil, errors.Errorf("provided URL is invalid: %q", rawURL)
+	} else if options.Client != nil || options.Options != nil || options.API != nil {
+		return nil, errors.New("Client, Options and API cannot be provided with a presigned URL")
+	} else if options.VersionID != "" || options.RequestPayer != "" || options.ExpectedBucketOwner != "" {
+		return nil, errors.New("provided VersionID, RequestPayer and ExpectedBucketOwner must be signed into the " +
+			"presigned URL instead")
+	}
+
+	options.API = &presignedClient{url: rawURL, httpClient: http.DefaultClient}
+	options.Bucket, options.Key = presignedObject(parsed)
+	return NewWithOptions(options)
+}
+
+// presignedObject returns the bucket and key a presigned URL names, for logging, whether the bucket is named in the
+// host, as in https://bucket.s3.us-west-2.amazonaws.com/key, or in the path, as in
+// https://s3.us-west-2.amazonaws.com/bucket/key.
+func presignedObject(u *url.URL) (string, string) {
+	path := strings.TrimPrefix(u.Path, "/")
+	host := u.Hostname()
+	for _, marker := range []string{".s3.", ".s3-"} {
+		if i := strings.Index(host, marker); i > 0 {
+			return host[:i], path
+		}
+	}
+
+	if i := strings.IndexByte(path, '/'); i >= 0 {
+		return path[:i], path[i+1:]
+	}
+	return path, ""
+}
+
+// presignedClient implements API with GET requests to a presigned GetObject URL. Only the standard conditional and
+// Range headers are sent, since S3 rejects presigned requests with x-amz-* headers that were not signed, so the other
+// fields of each input are ignored, as are the optFns of each call.
+type presignedClient struct {
+	url        string
+	httpClient *http.Client
+}
+
+// HeadObject requests the object's first byte, taking its size from the Content-Range of the response. Requests for
+// a single part fail.
+func (c *presignedClient) HeadObject(ctx context.Context, input *s3.HeadObjectInput, _ ...func(*s3.Options)) (
+	*s3.HeadObjectOutput, error) {
+	if input.PartNumber != 0 {
+		return nil, errors.Wrap(errPresignedNotSupported, "HeadObject with PartNumber")
+	}
+
+	resp, err := c.get(ctx, "bytes=0-0", input.IfMatch)
+	if isRangeNotSatisfiable(err) {
+		// Only an empty object has no first byte.
+		resp, err = c.get(ctx, "", input.IfMatch)
+	}
+	if err != nil {
+		return nil, err
+	}
+	_ = resp.Body.Close()
+
+	size := resp.ContentLength
+	if contentRange := resp.Header.Get("Content-Range"); contentRange != "" {
+		total := contentRange[strings.LastIndexByte(contentRange, '/')+1:]
+		if size, err = strconv.ParseInt(total, 10, 64); err != nil {
+			return nil, errors.Errorf("unable to parse Content-Range %q", contentRange)
+		}
+	}
+
+	output := &s3.HeadObjectOutput{
+		AcceptRanges:    headerString(resp.Header, "Accept-Ranges"),
+		CacheControl:    headerString(resp.Header, "Cache-Control"),
+		ContentEncoding: headerString(resp.Header, "Content-Encoding"),
+		ContentLength:   size,
+		ContentType:     headerString(resp.Header, "Content-Type"),
+		ETag:            headerString(resp.Header, "ETag"),
+		Metadata:        userMetadata(resp.Header),
+		StorageClass:    types.StorageClass(resp.Header.Get("X-Amz-Storage-Class")),
+		VersionId:       headerString(resp.Header, "X-Amz-Version-Id"),
+	}
+	if lastModified, err := http.ParseTime(resp.Header.Get("Last-Modified")); err == nil {
+		output.LastModified = &lastModified
+	}
+	if count, err := strconv.ParseInt(resp.Header.Get("X-Amz-Mp-Parts-Count"), 10, 32); err == nil {
+		output.PartsCount = int32(count)
+	}
+
+	return output, nil
+}
+
+// GetObject requests the range of the object input names. The caller must close the returned Body.
+func (c *presignedClient) GetObject(ctx context.Context, input *s3.GetObjectInput, _ ...func(*s3.Options)) (
+	*s3.GetObjectOutput, error) {
+	if input.PartNumber != 0 {
+		return nil, errors.Wrap(errPresignedNotSupported, "GetObject with PartNumber")
+	}
+
+	var rng string
+	if input.Range != nil {
+		rng = *input.Range
+	}
+	resp, err := c.get(ctx, rng, input.IfMatch)
+	if err != nil {
+		return nil, err
+	}
+
+	output := &s3.GetObjectOutput{
+		AcceptRanges:    headerString(resp.Header, "Accept-Ranges"),
+		Body:            resp.Body,
+		CacheControl:    headerString(resp.Header, "Cache-Control"),
+		ContentEncoding: headerString(resp.Header, "Content-Encoding"),
+		ContentLength:   resp.ContentLength,
+		ContentRange:    headerString(resp.Header, "Content-Range"),
+		ContentType:     headerString(resp.Header, "Content-Type"),
+		ETag:            headerString(resp.Header, "ETag"),
+		Metadata:        userMetadata(resp.Header),
+		StorageClass:    types.StorageClass(resp.Header.Get("X-Amz-Storage-Class")),
+		VersionId:       headerString(resp.Header, "X-Amz-Version-Id"),
+	}
+	if lastModified, err := http.ParseTime(resp.Header.Get("Last-Modified")); err == nil {
+		output.LastModified = &lastModified
+	}
+	if count, err := strconv.ParseInt(resp.Header.Get("X-Amz-Mp-Parts-Count"), 10, 32); err == nil {
+		output.PartsCount = int32(count)
+	}
+
+	return output, nil
+}
+
+// GetObjectAttributes fails, since a presigned GetObject URL cannot be used for it.
+func (c *presignedClient) GetObjectAttributes(context.Context, *s3.GetObjectAttributesInput,
+	...func(*s3.Options)) (*s3.GetObjectAttributesOutput, error) {
+	return nil, errors.Wrap(errPresignedNotSupported, "GetObjectAttributes")
+}
+
+// get makes a GET request to the URL with the given Range and If-Match headers, if set, returning errors in the form
+// aws-sdk-go-v2 would have, so that the S3ReaderAt can tell responses from S3 apart from failures to reach it. The
+// caller must close the body of the returned response.
+func (c *presignedClient) get(ctx context.Context, rng string, ifMatch *string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if rng != "" {
+		req.Header.Set("Range", rng)
+	}
+	if ifMatch != nil {
+		req.Header.Set("If-Match", *ifMatch)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, errors.WithMessage(ctx.Err(), err.Error())
+		}
+		return nil, &smithyhttp.RequestSendError{Err: err}
+	} else if resp.StatusCode/100 == 2 {
+		return resp, nil
+	}
+
+	// S3 describes errors with an XML body, except in response to HEAD requests.
+	var body struct {
+		Code    string
+		Message string
+	}
+	_ = xml.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&body)
+	_, _ = io.Copy(ioutil.Discard, resp.Body)
+	_ = resp.Body.Close()
+	if body.Code == "" {
+		body.Code = http.StatusText(resp.StatusCode)
+	}
+
+	return nil, &awshttp.ResponseError{
+		ResponseError: &smithyhttp.ResponseError{
+			Response: &smithyhttp.Response{Response: resp},
+			Err:      &smithy.GenericAPIError{Code: body.Code, Message: body.Message},
+		},
+		RequestID: resp.Header.Get("X-Amz-Request-Id"),
+	}
+}
+
+// headerString returns a pointer to the value of the named header, or nil if it is missing.
+func headerString(header http.Header, name string) *string {
+	return optionalString(header.Get(name))
+}
+
+// userMetadata returns the user-defined metadata of an object from its x-amz-meta-* headers, keyed by name in lower
+// case, as s3.Client returns it.
+func userMetadata(header http.Header) map[string]string {
+	metadata := make(map[string]string)
+	for name, values := range header {
+		if lower := strings.ToLower(name); strings.HasPrefix(lower, "x-amz-meta-") && len(values) > 0 {
+			metadata[strings.TrimPrefix(lower, "x-amz-meta-")] = values[0]
+		}
+	}
+	return metadata
+}
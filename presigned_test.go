@@ -0,0 +1,93 @@
+package s3readerat
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+// TestNewFromPresignedURL tests that an S3ReaderAt made from a presigned URL reads with unsigned GET requests to the
+// URL, taking the object's size from a request for its first byte, and fails with ErrAccessDenied once the URL
+// expires.
+func TestNewFromPresignedURL(t *testing.T) {
+	f := newFakeS3(t)
+	f.put("bucket", "dir/key", []byte("0123456789"))
+	f.put("bucket", "empty", nil)
+
+	rawURL := f.server.URL + "/bucket/dir/key?X-Amz-Algorithm=AWS4-HMAC-SHA256&X-Amz-Signature=abc"
+	ra, err := NewFromPresignedURL(rawURL)
+	if err != nil {
+		t.Fatalf("Error calling NewFromPresignedURL: %v", err)
+	}
+	if ra.bucket != "bucket" || ra.key != "dir/key" {
+		t.Fatalf("Expected s3://bucket/dir/key, got s3://%s/%s", ra.bucket, ra.key)
+	}
+	if size, err := ra.Size(); err != nil || size != 10 {
+		t.Fatalf("Expected size 10, got %d (%v)", size, err)
+	}
+	b := make([]byte, 4)
+	if _, err = ra.ReadAt(b, 2); err != nil || string(b) != "2345" {
+		t.Fatalf("Expected to read %q, got %q (%v)", "2345", b, err)
+	}
+
+	f.mu.Lock()
+	for _, r := range f.requests {
+		if r.Method != http.MethodGet || r.URL.Query().Get("X-Amz-Signature") != "abc" {
+			t.Fatalf("Expected every request to be a GET request to the presigned URL, got %s %s", r.Method, r.URL)
+		}
+		for name := range r.Header {
+			if strings.HasPrefix(strings.ToLower(name), "x-amz-") || name == "Authorization" {
+				t.Fatalf("Expected no header %s on a presigned request", name)
+			}
+		}
+	}
+	f.mu.Unlock()
+
+	empty, err := NewFromPresignedURL(f.server.URL + "/bucket/empty?X-Amz-Signature=abc")
+	if err != nil {
+		t.Fatalf("Error calling NewFromPresignedURL: %v", err)
+	}
+	f.hook = func(w http.ResponseWriter, r *http.Request) bool {
+		if r.Header.Get("Range") != "" && strings.HasSuffix(r.URL.Path, "/empty") {
+			writeFakeError(w, http.StatusRequestedRangeNotSatisfiable, "InvalidRange", true)
+			return true
+		}
+		return false
+	}
+	if size, err := empty.Size(); err != nil || size != 0 {
+		t.Fatalf("Expected size 0, got %d (%v)", size, err)
+	}
+
+	f.hook = func(w http.ResponseWriter, r *http.Request) bool {
+		writeFakeError(w, http.StatusForbidden, "AccessDenied", true)
+		return true
+	}
+	if _, err = ra.ReadAt(b, 0); !errors.Is(err, ErrAccessDenied) {
+		t.Fatalf("Expected ErrAccessDenied once the URL expires, got %v", err)
+	}
+
+	for _, rawURL := range []string{"s3://bucket/key", "/bucket/key"} {
+		if _, err = NewFromPresignedURL(rawURL); err == nil {
+			t.Fatalf("Expected an error with URL %q", rawURL)
+		}
+	}
+}
+
+// TestPresignedObject tests that presignedObject finds the bucket and key in virtual-hosted and path-style URLs.
+func TestPresignedObject(t *testing.T) {
+	for rawURL, expected := range map[string][2]string{
+		"https://bucket.s3.us-west-2.amazonaws.com/dir/key?X-Amz-Signature=abc": {"bucket", "dir/key"},
+		"https://bucket.s3-us-west-2.amazonaws.com/key":                         {"bucket", "key"},
+		"https://s3.us-west-2.amazonaws.com/bucket/dir/key":                     {"bucket", "dir/key"},
+	} {
+		ra, err := NewFromPresignedURL(rawURL)
+		if err != nil {
+			t.Fatalf("Error calling NewFromPresignedURL: %v", err)
+		}
+		if ra.bucket != expected[0] || ra.key != expected[1] {
+			t.Fatalf("Expected s3://%s/%s for %s, got s3://%s/%s", expected[0], expected[1], rawURL, ra.bucket, ra.key)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package s3readerat
+
+import (
+	"context"
+	"log"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// ErrPrefetchCanceled is returned by PrefetchAt when the prefetch was canceled, or never issued, to relieve memory
+// pressure or because it could not finish before its context's deadline. No bytes were read, so the caller should drop
+// its buffer and read the range with ReadAt once it is needed.
+var ErrPrefetchCanceled = errors.New("prefetch canceled")
+
+// speculativeRead is a call to PrefetchAt in progress.
+type speculativeRead struct {
+	bytes    int64
+	cancel   context.CancelFunc
+	canceled bool
+}
+
+// prefetches tracks the calls to PrefetchAt in progress, so that they can be canceled under memory pressure.
+type prefetches struct {
+	mu       sync.Mutex
+	maxBytes int64
+	bytes    int64
+	reads    map[*speculativeRead]struct{}
+}
+
+// setMaxBytes changes maxBytes. Prefetches already in progress are not canceled.
+func (ps *prefetches) setMaxBytes(n int64) {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	ps.maxBytes = n
+}
+
+// start registers a prefetch of n bytes, returning nil if it would exceed maxBytes. The returned context is canceled
+// by cancelAll.
+func (ps *prefetches) start(ctx context.Context, n int64) (*speculativeRead, context.Context) {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	if ps.maxBytes > 0 && ps.bytes+n > ps.maxBytes {
+		return nil, nil
+	}
+
+	read := &speculativeRead{bytes: n}
+	ctx, read.cancel = context.WithCancel(ctx)
+	if ps.reads == nil {
+		ps.reads = make(map[*speculativeRead]struct{})
+	}
+	ps.reads[read] = struct{}{}
+	ps.bytes += n
+
+	return read, ctx
+}
+
+// finish unregisters read, reporting whether it was canceled.
+func (ps *prefetches) finish(read *speculativeRead) bool {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	delete(ps.reads, read)
+	ps.bytes -= read.bytes
+	read.cancel()
+
+	return read.canceled
+}
+
+// cancelAll cancels every prefetch in progress, returning how many there were.
+func (ps *prefetches) cancelAll() int {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	for read := range ps.reads {
+		read.canceled = true
+		read.cancel()
+	}
+
+	return len(ps.reads)
+}
+
+// CancelPrefetches cancels every call to PrefetchAt in progress, whether its request is waiting for a slot or already
+// receiving bytes, so that its buffer can be dropped rather than filled with bytes that would only be evicted. The
+// canceled calls return ErrPrefetchCanceled. Call it when a cache reaches its budget or a memory limiter signals
+// pressure. It returns the number of prefetches canceled.
+func (ra *S3ReaderAt) CancelPrefetches() int {
+	n := ra.prefetches.cancelAll()
+	if n > 0 && ra.debug() {
+		log.Printf("Canceled %d prefetches of S3 object s3://%s/%s under memory pressure", n, ra.bucket, ra.key)
+	}
+
+	return n
+}
+
+// prefetchAt implements PrefetchAt and PrefetchAtContext.
+func (ra *S3ReaderAt) prefetchAt(ctx context.Context, p []byte, off int64) (int, error) {
+	if ra.trimToDeadline(ctx, int64(len(p))) < int64(len(p)) {
+		if ra.debug() {
+			log.Printf("Skipping prefetch of %d bytes of S3 object s3://%s/%s at offset %d to meet the deadline",
+				len(p), ra.bucket, ra.key, off)
+		}
+		return 0, errors.Wrapf(ErrPrefetchCanceled, "prefetching %d bytes would miss the deadline", len(p))
+	}
+
+	read, ctx := ra.prefetches.start(withPrefetchPriority(ctx), int64(len(p)))
+	if read == nil {
+		return 0, errors.Wrapf(ErrPrefetchCanceled, "prefetching %d bytes would exceed MaxPrefetchBytes", len(p))
+	}
+
+	n, err := ra.readAt(ctx, p, off)
+	if ra.prefetches.finish(read) && err != nil {
+		return 0, ErrPrefetchCanceled
+	}
+
+	return n, err
+}
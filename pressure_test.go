@@ -0,0 +1,54 @@
+package s3readerat
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+// TestCancelPrefetches tests that CancelPrefetches cancels a prefetch in flight, and that prefetches beyond
+// MaxPrefetchBytes are not issued.
+func TestCancelPrefetches(t *testing.T) {
+	f := newFakeS3(t)
+	f.put("bucket", "key", []byte("0123456789"))
+	started := make(chan struct{}, 1)
+	release := make(chan struct{})
+	defer close(release)
+	f.hook = func(w http.ResponseWriter, r *http.Request) bool {
+		started <- struct{}{}
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+		return false
+	}
+
+	size := int64(10)
+	s3ReaderAt, err := NewWithOptions(Options{Client: f.client(), Bucket: "bucket", Key: "key", Size: &size,
+		MaxPrefetchBytes: 4})
+	if err != nil {
+		t.Fatalf("Error calling NewWithOptions: %v", err)
+	}
+
+	if _, err = s3ReaderAt.PrefetchAt(make([]byte, 5), 0); !errors.Is(err, ErrPrefetchCanceled) {
+		t.Fatalf("Expected ErrPrefetchCanceled prefetching more than MaxPrefetchBytes, got %v", err)
+	}
+
+	errs := make(chan error)
+	go func() {
+		_, err := s3ReaderAt.PrefetchAt(make([]byte, 4), 0)
+		errs <- err
+	}()
+	<-started
+
+	if n := s3ReaderAt.CancelPrefetches(); n != 1 {
+		t.Fatalf("Expected 1 prefetch to be canceled, got %d", n)
+	}
+	if err = <-errs; !errors.Is(err, ErrPrefetchCanceled) {
+		t.Fatalf("Expected ErrPrefetchCanceled, got %v", err)
+	}
+	if count := f.requestCount(http.MethodGet); count != 1 {
+		t.Fatalf("Expected 1 GetObject request, got %d", count)
+	}
+}
@@ -0,0 +1,68 @@
+package s3readerat
+
+import "github.com/markandrus/s3readerat/cache"
+
+// Profile bundles option values tuned for an access pattern. Select one with Options.Profile.
+type Profile struct {
+	// Name identifies the profile.
+	Name string
+
+	// PrefetchHeadBytes is used when Options.PrefetchHeadBytes is zero.
+	PrefetchHeadBytes int64
+
+	// PrefetchTailBytes is used when Options.PrefetchTailBytes is zero.
+	PrefetchTailBytes int64
+
+	// MinRequestSize is used when Options.MinRequestSize is zero.
+	MinRequestSize int64
+
+	// ReadAhead is used when Options.ReadAhead is zero.
+	ReadAhead int
+
+	// BlockStore is used when Options.BlockStore is nil. Since a Profile is shared, so is the store.
+	BlockStore cache.Store
+}
+
+var (
+	// ProfileParquet suits Parquet files. Readers start with the 8-byte footer and then read the file metadata that
+	// precedes it, which is usually well under 1 MiB, so both are served by one request.
+	ProfileParquet = &Profile{
+		Name:              "parquet",
+		PrefetchTailBytes: 1 << 20,
+	}
+
+	// ProfileZip suits zip archives. Readers search the last 64 KiB for the end of central directory record and then
+	// read the central directory that precedes it, so a tail of a few hundred KiB covers most archives.
+	ProfileZip = &Profile{
+		Name:              "zip",
+		PrefetchTailBytes: 256 << 10,
+	}
+
+	// ProfileSequential suits reading an object from start to end in small pieces, as through an io.SectionReader, a
+	// bufio.Reader or a decompressor. Reads are rounded out to blocks of 1 MiB, and up to 8 of the blocks that follow
+	// are read ahead, so that the reads keep pace with a streaming GetObject request.
+	ProfileSequential = &Profile{
+		Name:           "sequential",
+		MinRequestSize: 1 << 20,
+		ReadAhead:      8,
+	}
+)
+
+// apply fills in the fields of options left at their zero values with the profile's values.
+func (p *Profile) apply(options *Options) {
+	if options.PrefetchHeadBytes == 0 {
+		options.PrefetchHeadBytes = p.PrefetchHeadBytes
+	}
+	if options.PrefetchTailBytes == 0 {
+		options.PrefetchTailBytes = p.PrefetchTailBytes
+	}
+	if options.MinRequestSize == 0 {
+		options.MinRequestSize = p.MinRequestSize
+	}
+	if options.ReadAhead == 0 {
+		options.ReadAhead = p.ReadAhead
+	}
+	if options.BlockStore == nil {
+		options.BlockStore = p.BlockStore
+	}
+}
@@ -0,0 +1,75 @@
+package s3readerat
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"testing"
+)
+
+// TestProfileParquetPrefetchesTail tests that, with ProfileParquet, reading the footer prefetches the tail of the
+// object so that reading the metadata before it needs no further requests.
+func TestProfileParquetPrefetchesTail(t *testing.T) {
+	f := newFakeS3(t)
+	data := bytes.Repeat([]byte("0123456789"), 1000)
+	f.put("bucket", "key", data)
+
+	s3ReaderAt, err := NewWithOptions(Options{
+		Client:  f.client(),
+		Bucket:  "bucket",
+		Key:     "key",
+		Profile: ProfileParquet,
+	})
+	if err != nil {
+		t.Fatalf("Error calling NewWithOptions: %v", err)
+	}
+
+	footer := make([]byte, 8)
+	if _, err = s3ReaderAt.ReadAt(footer, int64(len(data)-8)); err != nil {
+		t.Fatalf("Error calling ReadAt: %v", err)
+	}
+
+	metadata := make([]byte, 100)
+	if _, err = s3ReaderAt.ReadAt(metadata, int64(len(data)-108)); err != nil {
+		t.Fatalf("Error calling ReadAt: %v", err)
+	}
+
+	if !bytes.Equal(footer, data[len(data)-8:]) || !bytes.Equal(metadata, data[len(data)-108:len(data)-8]) {
+		t.Fatalf("Read unexpected bytes")
+	}
+
+	if count := f.requestCount(http.MethodGet); count != 1 {
+		t.Fatalf("Expected 1 GetObject request, got %d", count)
+	}
+}
+
+// TestProfileSequentialReadsAhead tests that, with ProfileSequential, small sequential reads are rounded out to blocks
+// and the blocks that follow are read ahead, so that reading the object in small pieces takes few requests.
+func TestProfileSequentialReadsAhead(t *testing.T) {
+	f := newFakeS3(t)
+	data := bytes.Repeat([]byte("0123456789"), 300000)
+	f.put("bucket", "key", data)
+
+	s3ReaderAt, err := NewWithOptions(Options{
+		Client:  f.client(),
+		Bucket:  "bucket",
+		Key:     "key",
+		Profile: ProfileSequential,
+	})
+	if err != nil {
+		t.Fatalf("Error calling NewWithOptions: %v", err)
+	}
+
+	read, err := ioutil.ReadAll(io.NewSectionReader(s3ReaderAt, 0, int64(len(data))))
+	if err != nil {
+		t.Fatalf("Error reading object: %v", err)
+	}
+	if !bytes.Equal(read, data) {
+		t.Fatalf("Read unexpected bytes")
+	}
+
+	if count := f.requestCount(http.MethodGet); count > 3 {
+		t.Fatalf("Expected at most 3 GetObject requests, got %d", count)
+	}
+}
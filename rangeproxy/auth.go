@@ -0,0 +1,105 @@
+package rangeproxy
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Credential grants access to a Server, optionally only to some of its objects.
+type Credential struct {
+	// Name identifies the credential in logs.
+	Name string `json:"name"`
+
+	// Username and Password, if set, are accepted with HTTP basic authentication.
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+
+	// Token, if set, is accepted as a bearer token.
+	Token string `json:"token,omitempty"`
+
+	// Prefixes restricts the credential to objects whose "bucket/key" starts with one of them, such as "bucket/" or
+	// "bucket/reports/". Empty means every object.
+	Prefixes []string `json:"prefixes,omitempty"`
+}
+
+// allows reports whether the credential grants access to path, of the form "bucket/key".
+func (c *Credential) allows(path string) bool {
+	if len(c.Prefixes) == 0 {
+		return true
+	}
+
+	for _, prefix := range c.Prefixes {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// RequireAuth returns an http.Handler that serves requests with next only if they carry one of credentials, as HTTP
+// basic authentication or a bearer token, and the credential grants access to the requested object. Requests without
+// a valid credential are refused with 401 Unauthorized, and requests for objects outside a credential's prefixes with
+// 403 Forbidden.
+func RequireAuth(next http.Handler, credentials []Credential) (http.Handler, error) {
+	if len(credentials) == 0 {
+		return nil, errors.New("must provide at least one credential")
+	}
+
+	for i, credential := range credentials {
+		if credential.Token == "" && (credential.Username == "" || credential.Password == "") {
+			return nil, errors.Errorf("credential %d (%q) must have a token, or a username and password", i,
+				credential.Name)
+		}
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		credential := authenticate(r, credentials)
+		if credential == nil {
+			w.Header().Add("WWW-Authenticate", `Basic realm="seek-s3"`)
+			w.Header().Add("WWW-Authenticate", `Bearer realm="seek-s3"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		if !credential.allows(strings.TrimPrefix(r.URL.Path, "/")) {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	}), nil
+}
+
+// authenticate returns the credential r carries, if any. Secrets are compared in constant time.
+func authenticate(r *http.Request, credentials []Credential) *Credential {
+	username, password, basic := r.BasicAuth()
+
+	var token string
+	if header := r.Header.Get("Authorization"); strings.HasPrefix(header, "Bearer ") {
+		token = strings.TrimPrefix(header, "Bearer ")
+	}
+
+	for i := range credentials {
+		credential := &credentials[i]
+		switch {
+		case basic && credential.Username != "":
+			if secureEqual(username, credential.Username) && secureEqual(password, credential.Password) {
+				return credential
+			}
+		case token != "" && credential.Token != "":
+			if secureEqual(token, credential.Token) {
+				return credential
+			}
+		}
+	}
+
+	return nil
+}
+
+func secureEqual(a string, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
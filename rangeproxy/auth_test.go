@@ -0,0 +1,54 @@
+package rangeproxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// TestRequireAuth tests that requests need a valid basic or bearer credential, and that credentials are limited to
+// their prefixes.
+func TestRequireAuth(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	handler, err := RequireAuth(ok, []Credential{
+		{Name: "admin", Username: "admin", Password: "secret"},
+		{Name: "reports", Token: "abc123", Prefixes: []string{"bucket/reports/"}},
+	})
+	if err != nil {
+		t.Fatalf("Error calling RequireAuth: %v", err)
+	}
+
+	tests := []struct {
+		path     string
+		username string
+		password string
+		token    string
+		status   int
+	}{
+		{path: "/bucket/key", status: http.StatusUnauthorized},
+		{path: "/bucket/key", username: "admin", password: "wrong", status: http.StatusUnauthorized},
+		{path: "/bucket/key", username: "admin", password: "secret", status: http.StatusOK},
+		{path: "/bucket/key", token: "wrong", status: http.StatusUnauthorized},
+		{path: "/bucket/key", token: "abc123", status: http.StatusForbidden},
+		{path: "/bucket/reports/q1.csv", token: "abc123", status: http.StatusOK},
+	}
+	for _, test := range tests {
+		r := httptest.NewRequest(http.MethodGet, test.path, nil)
+		if test.username != "" {
+			r.SetBasicAuth(test.username, test.password)
+		}
+		if test.token != "" {
+			r.Header.Set("Authorization", "Bearer "+test.token)
+		}
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		if w.Code != test.status {
+			t.Fatalf("Expected status %d for %+v, got %d", test.status, test, w.Code)
+		}
+	}
+
+	if _, err = RequireAuth(ok, []Credential{{Name: "empty"}}); err == nil {
+		t.Fatalf("Expected an error calling RequireAuth with a credential without secrets")
+	}
+}
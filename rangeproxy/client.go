@@ -0,0 +1,242 @@
+package rangeproxy
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	s3readerat "github.com/markandrus/s3readerat"
+	"github.com/pkg/errors"
+)
+
+// ReaderAtOptions configures a ReaderAt.
+type ReaderAtOptions struct {
+	// URL is the base URL of the Server, such as "http://localhost:7070".
+	URL string
+
+	// HTTPClient makes requests to the Server. The default is http.DefaultClient.
+	HTTPClient *http.Client
+
+	// Bucket is the bucket of the object to read.
+	Bucket string
+
+	// Key is the key of the object to read.
+	Key string
+
+	// Size is the size of the object, if known, which saves a HEAD request.
+	Size *int64
+
+	// Token, if set, is sent as a bearer token, for Servers that require authentication.
+	Token string
+
+	// Username and Password, if set, are sent with HTTP basic authentication.
+	Username string
+	Password string
+}
+
+// ReaderAt reads an object through a Server. Like S3ReaderAt, it returns an *s3readerat.ObjectChangedError if the
+// object's ETag changes between requests. It is safe for concurrent use.
+type ReaderAt struct {
+	url      string
+	client   *http.Client
+	token    string
+	username string
+	password string
+
+	mu   sync.Mutex
+	size int64
+	etag string
+}
+
+// NewReaderAt returns a ReaderAt. No requests are made until it is used.
+func NewReaderAt(options ReaderAtOptions) (*ReaderAt, error) {
+	if options.URL == "" {
+		return nil, errors.New("must provide URL")
+	} else if options.Bucket == "" || options.Key == "" {
+		return nil, errors.New("must provide Bucket and Key")
+	} else if options.Size != nil && *options.Size < 0 {
+		return nil, errors.Errorf("provided size is invalid: %d", *options.Size)
+	}
+
+	if options.HTTPClient == nil {
+		options.HTTPClient = http.DefaultClient
+	}
+
+	path := (&url.URL{Path: "/" + options.Bucket + "/" + options.Key}).EscapedPath()
+
+	r := &ReaderAt{
+		url:      strings.TrimSuffix(options.URL, "/") + path,
+		client:   options.HTTPClient,
+		token:    options.Token,
+		username: options.Username,
+		password: options.Password,
+		size:     -1,
+	}
+	if options.Size != nil {
+		r.size = *options.Size
+	}
+
+	return r, nil
+}
+
+// Size returns the size of the object, issuing a HEAD request if it is not yet known.
+func (r *ReaderAt) Size() (int64, error) {
+	r.mu.Lock()
+	size := r.size
+	r.mu.Unlock()
+
+	if size >= 0 {
+		return size, nil
+	}
+
+	if err := r.head(); err != nil {
+		return -1, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.size, nil
+}
+
+// ETag returns the ETag of the object, issuing a HEAD request if no response has been received yet.
+func (r *ReaderAt) ETag() (string, error) {
+	r.mu.Lock()
+	etag := r.etag
+	r.mu.Unlock()
+
+	if etag != "" {
+		return etag, nil
+	}
+
+	if err := r.head(); err != nil {
+		return "", err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.etag, nil
+}
+
+// head issues a HEAD request, recording the object's size and ETag.
+func (r *ReaderAt) head() error {
+	req, err := r.newRequest(http.MethodHead)
+	if err != nil {
+		return err
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "range proxy HEAD failed")
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return errors.Errorf("range proxy HEAD failed: %s", resp.Status)
+	}
+
+	if err = r.checkETag(resp.Header.Get("ETag")); err != nil {
+		return err
+	}
+
+	if resp.ContentLength < 0 {
+		return errors.New("range proxy did not return the object's size")
+	}
+
+	r.mu.Lock()
+	r.size = resp.ContentLength
+	r.mu.Unlock()
+
+	return nil
+}
+
+// ReadAt reads len(p) bytes starting at off with a single ranged GET. At the end of the object, it returns io.EOF.
+func (r *ReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errors.Wrapf(s3readerat.ErrInvalidOffset, "offset %d is negative", off)
+	} else if len(p) == 0 {
+		return 0, nil
+	}
+
+	req, err := r.newRequest(http.MethodGet)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", off, off+int64(len(p))-1))
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return 0, errors.Wrap(err, "range proxy GET failed")
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+	case http.StatusOK:
+		// The whole object was returned, which only satisfies reads from the start.
+		if off != 0 {
+			return 0, errors.New("range proxy ignored the Range header")
+		}
+	case http.StatusRequestedRangeNotSatisfiable:
+		return 0, io.EOF
+	default:
+		return 0, errors.Errorf("range proxy GET failed: %s", resp.Status)
+	}
+
+	if err = r.checkETag(resp.Header.Get("ETag")); err != nil {
+		return 0, err
+	}
+
+	n, err := io.ReadFull(resp.Body, p)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		// A complete response shorter than p means the range extends past the end of the object.
+		if int64(n) == resp.ContentLength {
+			err = io.EOF
+		} else {
+			err = errors.Wrap(io.ErrUnexpectedEOF, "range proxy response was truncated")
+		}
+	}
+
+	return n, err
+}
+
+// newRequest returns a request for the object, carrying the ReaderAt's credentials.
+func (r *ReaderAt) newRequest(method string) (*http.Request, error) {
+	req, err := http.NewRequest(method, r.url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if r.token != "" {
+		req.Header.Set("Authorization", "Bearer "+r.token)
+	} else if r.username != "" {
+		req.SetBasicAuth(r.username, r.password)
+	}
+
+	return req, nil
+}
+
+// checkETag records the first ETag observed and returns an *s3readerat.ObjectChangedError if etag differs from it.
+func (r *ReaderAt) checkETag(etag string) error {
+	if etag == "" {
+		return nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.etag == "" {
+		r.etag = etag
+		return nil
+	}
+
+	if etag != r.etag {
+		return &s3readerat.ObjectChangedError{OldETag: r.etag, NewETag: etag}
+	}
+
+	return nil
+}
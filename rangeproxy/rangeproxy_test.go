@@ -0,0 +1,239 @@
+package rangeproxy
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	s3readerat "github.com/markandrus/s3readerat"
+	"github.com/markandrus/s3readerat/cache"
+	"github.com/pkg/errors"
+)
+
+// fakeSource is a Source backed by a byte slice that counts its reads.
+type fakeSource struct {
+	*bytes.Reader
+	etag  string
+	mu    sync.Mutex
+	reads int
+}
+
+func (f *fakeSource) ReadAt(p []byte, off int64) (int, error) {
+	f.mu.Lock()
+	f.reads++
+	f.mu.Unlock()
+	return f.Reader.ReadAt(p, off)
+}
+
+func (f *fakeSource) Size() (int64, error) {
+	return f.Reader.Size(), nil
+}
+
+func (f *fakeSource) ETag() (string, error) {
+	return f.etag, nil
+}
+
+// TestServer tests that two ReaderAts reading through a Server share its cache, that the Server counts cache hits
+// and misses, that reads past the end of the object return io.EOF, and that an overwritten object is reported as
+// changed.
+func TestServer(t *testing.T) {
+	source := &fakeSource{Reader: bytes.NewReader([]byte("0123456789abcdefghij")), etag: `"v1"`}
+	server, err := NewServer(ServerOptions{
+		Open: func(bucket string, key string) (Source, error) {
+			if bucket != "bucket" || key != "dir/some key" {
+				t.Errorf("Unexpected object s3://%s/%s", bucket, key)
+			}
+			return source, nil
+		},
+		Store: cache.NewMemoryStore(8, 1<<20),
+	})
+	if err != nil {
+		t.Fatalf("Error calling NewServer: %v", err)
+	}
+	httpServer := httptest.NewServer(server)
+	defer httpServer.Close()
+
+	b := make([]byte, 4)
+	for i := 0; i < 2; i++ {
+		r, err := NewReaderAt(ReaderAtOptions{URL: httpServer.URL, Bucket: "bucket", Key: "dir/some key"})
+		if err != nil {
+			t.Fatalf("Error calling NewReaderAt: %v", err)
+		}
+
+		size, err := r.Size()
+		if err != nil {
+			t.Fatalf("Error calling Size: %v", err)
+		}
+		if size != 20 {
+			t.Fatalf("Expected size 20, got %d", size)
+		}
+
+		if _, err = r.ReadAt(b, 6); err != nil {
+			t.Fatalf("Error calling ReadAt: %v", err)
+		}
+		if string(b) != "6789" {
+			t.Fatalf("Expected %q, got %q", "6789", b)
+		}
+
+		n, err := r.ReadAt(b, 18)
+		if err != io.EOF || string(b[:n]) != "ij" {
+			t.Fatalf("Expected %q and io.EOF, got %q and %v", "ij", b[:n], err)
+		}
+
+		if _, err = r.ReadAt(b, 20); err != io.EOF {
+			t.Fatalf("Expected io.EOF reading at the end, got %v", err)
+		}
+	}
+
+	// The first ReaderAt fetched blocks 0, 1 and 2, and the second was served from the cache.
+	if source.reads != 3 {
+		t.Fatalf("Expected 3 reads of the source, got %d", source.reads)
+	}
+	if stats := server.Stats(); stats.CacheMisses != 3 || stats.CacheHits != 3 || stats.InFlight != 0 {
+		t.Fatalf("Expected 3 cache misses, 3 cache hits and no requests in flight, got %+v", stats)
+	}
+
+	r, err := NewReaderAt(ReaderAtOptions{URL: httpServer.URL, Bucket: "bucket", Key: "dir/some key"})
+	if err != nil {
+		t.Fatalf("Error calling NewReaderAt: %v", err)
+	}
+	if _, err = r.ReadAt(b, 0); err != nil {
+		t.Fatalf("Error calling ReadAt: %v", err)
+	}
+
+	server.mu.Lock()
+	server.objects = make(map[string]*serverObject)
+	server.mu.Unlock()
+	source.etag = `"v2"`
+
+	if _, err = r.ReadAt(b, 0); !errors.Is(err, s3readerat.ErrObjectChanged) {
+		t.Fatalf("Expected ErrObjectChanged, got %v", err)
+	}
+
+	resp, err := http.Get(httpServer.URL + "/bucket")
+	if err != nil {
+		t.Fatalf("Error calling Get: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("Expected a 400 response without a key, got %s", resp.Status)
+	}
+}
+
+// closingSource is a fakeSource that records whether it has been closed.
+type closingSource struct {
+	*fakeSource
+	closed int32
+}
+
+func (c *closingSource) Close() error {
+	atomic.AddInt32(&c.closed, 1)
+	return nil
+}
+
+// TestServerReopens tests that concurrent requests for an object that must be opened open it once, and that the
+// Server closes an object's Source once it has expired and been opened again.
+func TestServerReopens(t *testing.T) {
+	var mu sync.Mutex
+	var sources []*closingSource
+	server, err := NewServer(ServerOptions{
+		Open: func(bucket string, key string) (Source, error) {
+			// Give the concurrent requests time to arrive while the object is being opened.
+			time.Sleep(50 * time.Millisecond)
+			source := &closingSource{fakeSource: &fakeSource{Reader: bytes.NewReader([]byte("0123456789")),
+				etag: `"v1"`}}
+			mu.Lock()
+			sources = append(sources, source)
+			mu.Unlock()
+			return source, nil
+		},
+		ObjectTTL: 100 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("Error calling NewServer: %v", err)
+	}
+	httpServer := httptest.NewServer(server)
+	defer httpServer.Close()
+
+	head := func() {
+		resp, err := http.Head(httpServer.URL + "/bucket/key")
+		if err != nil {
+			t.Errorf("Error calling Head: %v", err)
+			return
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("Expected a 200 response, got %s", resp.Status)
+		}
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			head()
+		}()
+	}
+	wg.Wait()
+	if len(sources) != 1 {
+		t.Fatalf("Expected the object to be opened once, got %d", len(sources))
+	}
+
+	time.Sleep(150 * time.Millisecond)
+	head()
+	if len(sources) != 2 {
+		t.Fatalf("Expected the expired object to be opened again, got %d opens", len(sources))
+	}
+	if atomic.LoadInt32(&sources[0].closed) != 1 || atomic.LoadInt32(&sources[1].closed) != 0 {
+		t.Fatalf("Expected only the expired Source to be closed")
+	}
+
+	if err = server.Close(); err != nil {
+		t.Fatalf("Error calling Close: %v", err)
+	}
+	if atomic.LoadInt32(&sources[1].closed) != 1 {
+		t.Fatalf("Expected Close to close the open Source")
+	}
+}
+
+// TestServerErrorStatus tests that a Server responds to S3's errors with the matching status, without their detail.
+func TestServerErrorStatus(t *testing.T) {
+	for _, test := range []struct {
+		kind   error
+		status int
+	}{
+		{s3readerat.ErrNotFound, http.StatusNotFound},
+		{s3readerat.ErrAccessDenied, http.StatusForbidden},
+		{s3readerat.ErrThrottled, http.StatusServiceUnavailable},
+		{nil, http.StatusBadGateway},
+	} {
+		openErr := errors.New("request signed with AKIAEXAMPLE failed")
+		if test.kind != nil {
+			openErr = &s3readerat.S3Error{Kind: test.kind, Err: openErr}
+		}
+		server, err := NewServer(ServerOptions{
+			Open: func(bucket string, key string) (Source, error) {
+				return nil, openErr
+			},
+		})
+		if err != nil {
+			t.Fatalf("Error calling NewServer: %v", err)
+		}
+
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/bucket/key", nil))
+		if w.Code != test.status {
+			t.Fatalf("Expected status %d for %v, got %d", test.status, openErr, w.Code)
+		}
+		if strings.Contains(w.Body.String(), "AKIAEXAMPLE") {
+			t.Fatalf("Expected the response not to reveal the error, got %q", w.Body.String())
+		}
+	}
+}
@@ -0,0 +1,435 @@
+// Package rangeproxy serves byte ranges of remote objects over HTTP from one shared cache, and reads them back with a
+// ReaderAt that can stand in for an S3ReaderAt, so that many processes on a host or cluster share one cache and one
+// pool of S3 connections.
+//
+// The protocol is plain HTTP. HEAD /bucket/key returns the object's size as Content-Length and its ETag, and GET
+// /bucket/key with a Range header returns the requested bytes, so any HTTP client, such as curl, can also use a
+// Server.
+package rangeproxy
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	s3readerat "github.com/markandrus/s3readerat"
+	"github.com/markandrus/s3readerat/cache"
+	"github.com/pkg/errors"
+)
+
+const (
+	// DefaultCacheBytes is the size of the memory cache a Server uses if it is not given a Store.
+	DefaultCacheBytes = 256 << 20
+
+	// DefaultObjectTTL is how long a Server trusts an object's size and ETag by default.
+	DefaultObjectTTL = time.Minute
+)
+
+// Source is an object a Server serves ranges of. S3ReaderAt and ReaderAt implement it. If a Source is also an
+// io.Closer, as S3ReaderAt is, the Server closes it once it no longer trusts the object and no request is reading it.
+type Source interface {
+	io.ReaderAt
+	Size() (int64, error)
+	ETag() (string, error)
+}
+
+// OpenFunc returns the Source for the object key in bucket.
+type OpenFunc func(bucket string, key string) (Source, error)
+
+// ServerOptions configures a Server.
+type ServerOptions struct {
+	// Open opens the objects the Server serves. It is required.
+	Open OpenFunc
+
+	// Store caches the blocks of every object the Server serves. The default is a cache.MemoryStore of
+	// DefaultCacheBytes.
+	Store cache.Store
+
+	// ObjectTTL is how long the Server trusts an object's size and ETag before opening it again, so that overwritten
+	// objects are noticed. The default is DefaultObjectTTL.
+	ObjectTTL time.Duration
+}
+
+// Server is an http.Handler that serves ranges of objects from a shared cache.
+type Server struct {
+	openFunc  OpenFunc
+	store     *statsStore
+	objectTTL time.Duration
+
+	mu       sync.Mutex
+	objects  map[string]*serverObject
+	opening  map[string]*openCall
+	inFlight map[*Request]struct{}
+	requests int64
+}
+
+// ServerStats summarizes the requests a Server has served.
+type ServerStats struct {
+	// Requests is the number of requests received.
+	Requests int64 `json:"requests"`
+
+	// InFlight is the number of requests being served.
+	InFlight int `json:"in_flight"`
+
+	// Objects is the number of objects whose size and ETag are being trusted.
+	Objects int `json:"objects"`
+
+	// CacheHits is the number of blocks served from the cache.
+	CacheHits int64 `json:"cache_hits"`
+
+	// CacheHitBytes is the number of bytes served from the cache.
+	CacheHitBytes int64 `json:"cache_hit_bytes"`
+
+	// CacheMisses is the number of blocks fetched from the source.
+	CacheMisses int64 `json:"cache_misses"`
+
+	// CacheMissBytes is the number of bytes fetched from the source.
+	CacheMissBytes int64 `json:"cache_miss_bytes"`
+
+	// CorruptBlocks is the number of cached blocks discarded, and fetched again, because they failed to verify, if the
+	// Store counts them, as cache.DiskStore does.
+	CorruptBlocks int64 `json:"corrupt_blocks"`
+}
+
+// Request describes a request being served.
+type Request struct {
+	Method     string    `json:"method"`
+	Path       string    `json:"path"`
+	Range      string    `json:"range,omitempty"`
+	RemoteAddr string    `json:"remote_addr"`
+	Start      time.Time `json:"start"`
+}
+
+// serverObject is an object the Server has opened, along with the size and ETag it had then.
+type serverObject struct {
+	source  Source
+	reader  io.ReaderAt
+	size    int64
+	etag    string
+	expires time.Time
+
+	// refs is the number of requests serving the object, and dropped is set once the Server no longer trusts it, after
+	// which the last request to finish closes the source. They are guarded by the Server's mu.
+	refs    int
+	dropped bool
+}
+
+// openCall is the opening of an object in progress, which other requests for the object wait for rather than opening
+// it again.
+type openCall struct {
+	done    chan struct{}
+	waiters int
+	object  *serverObject
+	err     error
+}
+
+// NewServer returns a Server.
+func NewServer(options ServerOptions) (*Server, error) {
+	if options.Open == nil {
+		return nil, errors.New("must provide Open")
+	} else if options.ObjectTTL < 0 {
+		return nil, errors.Errorf("provided ObjectTTL is invalid: %v", options.ObjectTTL)
+	}
+
+	if options.Store == nil {
+		options.Store = cache.NewMemoryStore(cache.DefaultBlockSize, DefaultCacheBytes)
+	}
+
+	if options.ObjectTTL == 0 {
+		options.ObjectTTL = DefaultObjectTTL
+	}
+
+	return &Server{
+		openFunc:  options.Open,
+		store:     &statsStore{Store: options.Store},
+		objectTTL: options.ObjectTTL,
+		objects:   make(map[string]*serverObject),
+		opening:   make(map[string]*openCall),
+		inFlight:  make(map[*Request]struct{}),
+	}, nil
+}
+
+// Close closes the Sources of the objects the Server has open and its Store, if they are io.Closers, such as an
+// S3ReaderAt or a cache.DiskStore. Call it once the Server has stopped serving requests, for example after
+// http.Server.Shutdown returns.
+func (s *Server) Close() error {
+	s.mu.Lock()
+	var closing []*serverObject
+	for name, object := range s.objects {
+		delete(s.objects, name)
+		if s.drop(object) {
+			closing = append(closing, object)
+		}
+	}
+	s.mu.Unlock()
+	for _, object := range closing {
+		object.close()
+	}
+
+	if closer, ok := s.store.Store.(io.Closer); ok {
+		return closer.Close()
+	}
+
+	return nil
+}
+
+// Stats returns a snapshot of the Server's statistics.
+func (s *Server) Stats() ServerStats {
+	s.mu.Lock()
+	stats := ServerStats{Requests: s.requests, InFlight: len(s.inFlight), Objects: len(s.objects)}
+	s.mu.Unlock()
+
+	s.store.mu.Lock()
+	stats.CacheHits, stats.CacheHitBytes = s.store.hits, s.store.hitBytes
+	stats.CacheMisses, stats.CacheMissBytes = s.store.misses, s.store.missBytes
+	s.store.mu.Unlock()
+
+	if counter, ok := s.store.Store.(interface{ CorruptBlocks() int64 }); ok {
+		stats.CorruptBlocks = counter.CorruptBlocks()
+	}
+
+	return stats
+}
+
+// InFlight returns the requests being served, oldest first.
+func (s *Server) InFlight() []Request {
+	s.mu.Lock()
+	requests := make([]Request, 0, len(s.inFlight))
+	for request := range s.inFlight {
+		requests = append(requests, *request)
+	}
+	s.mu.Unlock()
+
+	sort.Slice(requests, func(i, j int) bool {
+		return requests[i].Start.Before(requests[j].Start)
+	})
+
+	return requests
+}
+
+// ServeHTTP serves a HEAD or GET request for /bucket/key.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	request := &Request{
+		Method:     r.Method,
+		Path:       r.URL.Path,
+		Range:      r.Header.Get("Range"),
+		RemoteAddr: r.RemoteAddr,
+		Start:      time.Now(),
+	}
+
+	s.mu.Lock()
+	s.requests++
+	s.inFlight[request] = struct{}{}
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		delete(s.inFlight, request)
+		s.mu.Unlock()
+	}()
+
+	parts := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/"), "/", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		http.Error(w, "expected a path of the form /bucket/key", http.StatusBadRequest)
+		return
+	}
+
+	object, err := s.object(parts[0], parts[1])
+	if err != nil {
+		// The error may describe the Server's credentials or configuration, so it is only logged.
+		status := errorStatus(err)
+		log.Printf("Unable to open s3://%s/%s: %v", parts[0], parts[1], err)
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+	defer s.release(object)
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	if object.etag != "" {
+		w.Header().Set("ETag", object.etag)
+	}
+
+	// ServeContent handles Range and conditional requests. A failed read truncates the response, which clients detect
+	// from its Content-Length.
+	http.ServeContent(w, r, "", time.Time{}, io.NewSectionReader(object.reader, 0, object.size))
+}
+
+// object returns the object key in bucket, opening it if it has not been opened within the ObjectTTL, and holds it
+// open until release is called. Concurrent requests for an object that must be opened wait for one of them to open it.
+func (s *Server) object(bucket string, key string) (*serverObject, error) {
+	name := "s3://" + bucket + "/" + key
+
+	s.mu.Lock()
+	if object, ok := s.objects[name]; ok && time.Now().Before(object.expires) {
+		object.refs++
+		s.mu.Unlock()
+		return object, nil
+	}
+	call, waiting := s.opening[name]
+	if waiting {
+		call.waiters++
+	} else {
+		call = &openCall{done: make(chan struct{})}
+		s.opening[name] = call
+	}
+	s.mu.Unlock()
+
+	if waiting {
+		<-call.done
+		return call.object, call.err
+	}
+
+	object, err := s.open(bucket, key)
+
+	s.mu.Lock()
+	delete(s.opening, name)
+	var closing []*serverObject
+	if err == nil {
+		// The object is held for this request and every request waiting for it.
+		object.refs = 1 + call.waiters
+		if old, ok := s.objects[name]; ok && s.drop(old) {
+			closing = append(closing, old)
+		}
+		s.objects[name] = object
+	}
+	now := time.Now()
+	for other, stale := range s.objects {
+		if !now.Before(stale.expires) {
+			delete(s.objects, other)
+			if s.drop(stale) {
+				closing = append(closing, stale)
+			}
+		}
+	}
+	call.object, call.err = object, err
+	s.mu.Unlock()
+	close(call.done)
+
+	for _, stale := range closing {
+		stale.close()
+	}
+
+	return object, err
+}
+
+// open opens the object key in bucket, recording its size and ETag.
+func (s *Server) open(bucket string, key string) (*serverObject, error) {
+	now := time.Now()
+
+	source, err := s.openFunc(bucket, key)
+	if err != nil {
+		return nil, err
+	}
+	object := &serverObject{source: source}
+
+	if object.size, err = source.Size(); err != nil {
+		object.close()
+		return nil, err
+	}
+	if object.etag, err = source.ETag(); err != nil {
+		object.close()
+		return nil, err
+	}
+
+	object.reader = cache.New(source, object.size, cache.Options{
+		Store:  s.store,
+		Object: CacheObject(bucket, key, object.etag, object.size),
+	})
+	object.expires = now.Add(s.objectTTL)
+
+	return object, nil
+}
+
+// release ends a request's hold on object, closing its source if the Server no longer trusts it and no other request
+// holds it.
+func (s *Server) release(object *serverObject) {
+	s.mu.Lock()
+	object.refs--
+	closing := object.dropped && object.refs == 0
+	s.mu.Unlock()
+
+	if closing {
+		object.close()
+	}
+}
+
+// drop marks object, which has been removed from s.objects, as no longer trusted, reporting whether its source should
+// be closed, since no request holds it. s.mu must be held.
+func (s *Server) drop(object *serverObject) bool {
+	object.dropped = true
+	return object.refs == 0
+}
+
+// close closes the object's source, if it is an io.Closer.
+func (o *serverObject) close() {
+	if closer, ok := o.source.(io.Closer); ok {
+		_ = closer.Close()
+	}
+}
+
+// CacheObject returns the cache.Object under which a Server stores the blocks of the object key in bucket, so that
+// other tools, such as seek-s3 warm, can populate or inspect the same Store.
+func CacheObject(bucket string, key string, etag string, size int64) cache.Object {
+	return cache.Object{Name: "s3://" + bucket + "/" + key, Version: etag, Size: size}
+}
+
+// errorStatus returns the HTTP status to respond with when an object cannot be opened.
+func errorStatus(err error) int {
+	var s3Error *s3readerat.S3Error
+	if errors.As(err, &s3Error) {
+		switch s3Error.Kind {
+		case s3readerat.ErrNotFound:
+			return http.StatusNotFound
+		case s3readerat.ErrAccessDenied:
+			return http.StatusForbidden
+		case s3readerat.ErrThrottled:
+			return http.StatusServiceUnavailable
+		}
+	}
+
+	return http.StatusBadGateway
+}
+
+// statsStore counts the hits and misses of a cache.Store.
+type statsStore struct {
+	cache.Store
+
+	mu        sync.Mutex
+	hits      int64
+	hitBytes  int64
+	misses    int64
+	missBytes int64
+}
+
+func (s *statsStore) Get(object cache.Object, index int64) ([]byte, bool) {
+	data, ok := s.Store.Get(object, index)
+
+	s.mu.Lock()
+	if ok {
+		s.hits++
+		s.hitBytes += int64(len(data))
+	} else {
+		s.misses++
+	}
+	s.mu.Unlock()
+
+	return data, ok
+}
+
+func (s *statsStore) Put(object cache.Object, index int64, data []byte) error {
+	s.mu.Lock()
+	s.missBytes += int64(len(data))
+	s.mu.Unlock()
+
+	return s.Store.Put(object, index, data)
+}
@@ -0,0 +1,116 @@
+// Package ratelimit provides an io.ReaderAt that limits the rate at which bytes are read from an underlying
+// io.ReaderAt. See package cache for how decorators compose.
+package ratelimit
+
+import (
+	"io"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Options configures a ReaderAt.
+type Options struct {
+	// BytesPerSecond is the sustained rate at which bytes may be read. It must be positive.
+	BytesPerSecond int64
+
+	// Burst is the number of bytes that may be read at once after a pause. The default is BytesPerSecond.
+	Burst int64
+}
+
+// ReaderAt limits the rate of reads of an underlying io.ReaderAt with a token bucket. Each read is charged len(p)
+// bytes before it is made, and waits until the bucket can pay for it; reads larger than the burst are allowed, but
+// delay the reads after them. It is safe for concurrent use if the underlying io.ReaderAt is.
+type ReaderAt struct {
+	r     io.ReaderAt
+	rate  float64
+	burst float64
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+// New returns a ReaderAt that limits the rate of reads of r.
+func New(r io.ReaderAt, options Options) (*ReaderAt, error) {
+	if err := options.validate(); err != nil {
+		return nil, err
+	}
+
+	if options.Burst == 0 {
+		options.Burst = options.BytesPerSecond
+	}
+
+	return &ReaderAt{
+		r:      r,
+		rate:   float64(options.BytesPerSecond),
+		burst:  float64(options.Burst),
+		tokens: float64(options.Burst),
+		last:   time.Now(),
+	}, nil
+}
+
+// validate returns an error if options are invalid.
+func (options Options) validate() error {
+	if options.BytesPerSecond <= 0 {
+		return errors.Errorf("provided BytesPerSecond is invalid: %d", options.BytesPerSecond)
+	} else if options.Burst < 0 {
+		return errors.Errorf("provided Burst is invalid: %d", options.Burst)
+	}
+
+	return nil
+}
+
+// SetOptions changes the rate and burst of the ReaderAt while it is in use. Bytes already charged at the old rate are
+// not recharged; reads waiting for them wait as before. It is safe for concurrent use.
+func (l *ReaderAt) SetOptions(options Options) error {
+	if err := options.validate(); err != nil {
+		return err
+	}
+
+	if options.Burst == 0 {
+		options.Burst = options.BytesPerSecond
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// Refill the bucket at the old rate up to now, then continue at the new one.
+	now := time.Now()
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	l.last = now
+	l.rate = float64(options.BytesPerSecond)
+	l.burst = float64(options.Burst)
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+
+	return nil
+}
+
+// ReadAt waits until len(p) bytes may be read, then reads from the underlying io.ReaderAt.
+func (l *ReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	time.Sleep(l.reserve(len(p)))
+	return l.r.ReadAt(p, off)
+}
+
+// reserve charges n bytes to the bucket and returns how long to wait before reading them.
+func (l *ReaderAt) reserve(n int) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+	l.last = now
+
+	l.tokens -= float64(n)
+	if l.tokens >= 0 {
+		return 0
+	}
+
+	return time.Duration(-l.tokens / l.rate * float64(time.Second))
+}
@@ -0,0 +1,66 @@
+package ratelimit
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+// TestReaderAt tests that reads within the burst are not delayed, and that reads beyond it wait for the bucket to
+// refill.
+func TestReaderAt(t *testing.T) {
+	l, err := New(bytes.NewReader(make([]byte, 1000)), Options{BytesPerSecond: 1000, Burst: 100})
+	if err != nil {
+		t.Fatalf("Error calling New: %v", err)
+	}
+
+	b := make([]byte, 100)
+	start := time.Now()
+	if _, err = l.ReadAt(b, 0); err != nil {
+		t.Fatalf("Error calling ReadAt: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 50*time.Millisecond {
+		t.Fatalf("Expected a read within the burst not to wait, waited %v", elapsed)
+	}
+
+	start = time.Now()
+	if _, err = l.ReadAt(b, 100); err != nil {
+		t.Fatalf("Error calling ReadAt: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 80*time.Millisecond {
+		t.Fatalf("Expected a read beyond the burst to wait about 100ms, waited %v", elapsed)
+	}
+
+	if _, err = New(bytes.NewReader(nil), Options{}); err == nil {
+		t.Fatalf("Expected an error calling New without BytesPerSecond")
+	}
+}
+
+// TestSetOptions tests that SetOptions changes the rate of a ReaderAt in use.
+func TestSetOptions(t *testing.T) {
+	l, err := New(bytes.NewReader(make([]byte, 1000)), Options{BytesPerSecond: 100, Burst: 100})
+	if err != nil {
+		t.Fatalf("Error calling New: %v", err)
+	}
+
+	b := make([]byte, 100)
+	if _, err = l.ReadAt(b, 0); err != nil {
+		t.Fatalf("Error calling ReadAt: %v", err)
+	}
+
+	// At the old rate, the next read would wait a second.
+	if err = l.SetOptions(Options{BytesPerSecond: 1000000}); err != nil {
+		t.Fatalf("Error calling SetOptions: %v", err)
+	}
+	start := time.Now()
+	if _, err = l.ReadAt(b, 100); err != nil {
+		t.Fatalf("Error calling ReadAt: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 50*time.Millisecond {
+		t.Fatalf("Expected a read at the new rate not to wait long, waited %v", elapsed)
+	}
+
+	if err = l.SetOptions(Options{BytesPerSecond: 100, Burst: -1}); err == nil {
+		t.Fatalf("Expected an error calling SetOptions with a negative Burst")
+	}
+}
@@ -0,0 +1,156 @@
+package s3readerat
+
+import (
+	"context"
+	"log"
+	"sync"
+
+	"github.com/markandrus/s3readerat/cache"
+)
+
+// readAhead tracks the reads of an S3ReaderAt that are rounded out to blocks, to detect sequential reads and fetch the
+// blocks that follow them in the background, when Options.ReadAhead is set. The window of blocks fetched ahead starts
+// at one block and doubles with each sequential read, up to ReadAhead, and is reset by a read elsewhere.
+type readAhead struct {
+	mu      sync.Mutex
+	next    int64
+	window  int
+	ahead   int64
+	pending map[int64]chan struct{}
+}
+
+// wait returns a channel that is closed once the block at index has been read ahead, or nil if it is not being read
+// ahead.
+func (r *readAhead) wait(index int64) chan struct{} {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.pending[index]
+}
+
+// observe records a read of the inclusive range [first, last] of an object of size bytes, returning the inclusive
+// range of blocks of blockSize bytes to read ahead, which may be empty, and the channel to close once they have been.
+// The blocks not already pending are registered as pending on the channel.
+func (r *readAhead) observe(first int64, last int64, size int64, blockSize int64, maxWindow int) (int64, int64,
+	chan struct{}) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if first != r.next {
+		r.window, r.ahead = 0, 0
+	} else if r.window == 0 {
+		r.window = 1
+	} else if r.window < maxWindow {
+		r.window *= 2
+		if r.window > maxWindow {
+			r.window = maxWindow
+		}
+	}
+	r.next = last + 1
+
+	startIndex := last/blockSize + 1
+	if r.ahead > startIndex {
+		startIndex = r.ahead
+	}
+	endIndex := last/blockSize + int64(r.window)
+	if lastIndex := (size - 1) / blockSize; endIndex > lastIndex {
+		endIndex = lastIndex
+	}
+	if endIndex < startIndex {
+		return 0, -1, nil
+	}
+
+	r.ahead = endIndex + 1
+	if r.pending == nil {
+		r.pending = make(map[int64]chan struct{})
+	}
+	done := make(chan struct{})
+	for index := startIndex; index <= endIndex; index++ {
+		if _, ok := r.pending[index]; !ok {
+			r.pending[index] = done
+		}
+	}
+	return startIndex, endIndex, done
+}
+
+// finish releases the reads waiting on done for the blocks in the inclusive range [startIndex, endIndex].
+func (r *readAhead) finish(startIndex int64, endIndex int64, done chan struct{}) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for index := startIndex; index <= endIndex; index++ {
+		if r.pending[index] == done {
+			delete(r.pending, index)
+		}
+	}
+	close(done)
+}
+
+// readAheadOf fetches the blocks that follow the read of the inclusive range [first, last] in the background, as
+// PrefetchAt would, if the read continues a sequential run of reads. Blocks already kept are not fetched again. The
+// fetch is registered as a prefetch, so Close and CancelPrefetches stop it.
+func (ra *S3ReaderAt) readAheadOf(store cache.Store, object cache.Object, first int64, last int64) {
+	maxWindow := ra.tuning().ReadAhead
+	size := ra.objectSize()
+	if maxWindow <= 0 || size < 0 || ra.checkClosed() != nil {
+		return
+	}
+
+	blockSize := store.BlockSize()
+	startIndex, endIndex, done := ra.readAhead.observe(first, last, size, blockSize, maxWindow)
+	if endIndex < startIndex {
+		return
+	}
+
+	go func() {
+		defer ra.readAhead.finish(startIndex, endIndex, done)
+
+		index := startIndex
+		for ; index <= endIndex; index++ {
+			if _, ok := store.Get(object, index); !ok {
+				break
+			}
+		}
+		if index > endIndex {
+			return
+		}
+
+		n := (endIndex - index + 1) * blockSize
+		read, ctx := ra.prefetches.start(withPrefetchPriority(ra.ctx), n)
+		if read == nil {
+			return
+		}
+		defer ra.prefetches.finish(read)
+		// Close marks the S3ReaderAt closed before canceling the prefetches, so a fetch registered after it did so is
+		// caught here.
+		if ra.checkClosed() != nil {
+			return
+		}
+
+		if ra.debug() {
+			log.Printf("Reading ahead %d blocks of S3 object s3://%s/%s from offset %d", endIndex-index+1, ra.bucket,
+				ra.key, index*blockSize)
+		}
+		if err := ra.fetchBlocks(ctx, store, object, make([][]byte, endIndex-index+1), index); err != nil &&
+			ra.debug() {
+			log.Printf("Unable to read ahead S3 object s3://%s/%s: %v", ra.bucket, ra.key, err)
+		}
+	}()
+}
+
+// waitForReadAhead waits for the block at index to be read ahead, if it is being read ahead, reporting whether it was
+// then kept in store as a block of object.
+func (ra *S3ReaderAt) waitForReadAhead(ctx context.Context, store cache.Store, object cache.Object, index int64) (
+	[]byte, bool) {
+	done := ra.readAhead.wait(index)
+	if done == nil {
+		return nil, false
+	}
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		return nil, false
+	}
+	return store.Get(object, index)
+}
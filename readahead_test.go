@@ -0,0 +1,112 @@
+package s3readerat
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestReadAhead tests that sequential reads fetch the blocks that follow them in the background, in a window that
+// doubles up to ReadAhead blocks, and that a read elsewhere resets it.
+func TestReadAhead(t *testing.T) {
+	f := newFakeS3(t)
+	data := strings.Repeat("0123456789abcdefghijklmnopqrstuvwxyzABCD", 2)
+	f.put("bucket", "key", []byte(data))
+
+	s3ReaderAt, err := NewWithOptions(Options{Client: f.client(), Bucket: "bucket", Key: "key", MinRequestSize: 8,
+		ReadAhead: 4})
+	if err != nil {
+		t.Fatalf("Error calling NewWithOptions: %v", err)
+	}
+
+	for _, off := range []int64{0, 4, 8, 12, 72} {
+		b := make([]byte, 4)
+		if n, err := s3ReaderAt.ReadAt(b, off); err != nil || string(b[:n]) != data[off:off+4] {
+			t.Fatalf("Expected to read %q at offset %d, got %q (%v)", data[off:off+4], off, b[:n], err)
+		}
+
+		// Let the blocks read ahead arrive, so that the requests are issued in a predictable order.
+		for deadline := time.Now().Add(time.Second); ; time.Sleep(time.Millisecond) {
+			s3ReaderAt.readAhead.mu.Lock()
+			pending := len(s3ReaderAt.readAhead.pending)
+			s3ReaderAt.readAhead.mu.Unlock()
+			if pending == 0 {
+				break
+			} else if time.Now().After(deadline) {
+				t.Fatalf("Timed out waiting for read-ahead")
+			}
+		}
+	}
+
+	var ranges []string
+	f.mu.Lock()
+	for _, r := range f.requests {
+		if r.Method == http.MethodGet {
+			ranges = append(ranges, r.Header.Get("Range"))
+		}
+	}
+	f.mu.Unlock()
+	expected := []string{"bytes=0-7", "bytes=8-15", "bytes=16-23", "bytes=24-47", "bytes=72-79"}
+	if !reflect.DeepEqual(ranges, expected) {
+		t.Fatalf("Expected ranges %v, got %v", expected, ranges)
+	}
+	if stats := s3ReaderAt.Stats(); stats.CacheHits != 3 {
+		t.Fatalf("Expected 3 cache hits, got %d", stats.CacheHits)
+	}
+}
+
+// TestReadAheadStopsOnClose tests that Close cancels a read-ahead in progress and releases the reads waiting for it.
+func TestReadAheadStopsOnClose(t *testing.T) {
+	f := newFakeS3(t)
+	f.put("bucket", "key", []byte(strings.Repeat("0123456789abcdef", 4)))
+
+	started := make(chan struct{})
+	canceled := make(chan struct{})
+	f.hook = func(w http.ResponseWriter, r *http.Request) bool {
+		if r.Method != http.MethodGet || r.Header.Get("Range") != "bytes=8-15" {
+			return false
+		}
+		close(started)
+		<-r.Context().Done()
+		close(canceled)
+		return true
+	}
+
+	s3ReaderAt, err := NewWithOptions(Options{Client: f.client(), Bucket: "bucket", Key: "key", MinRequestSize: 8,
+		ReadAhead: 4})
+	if err != nil {
+		t.Fatalf("Error calling NewWithOptions: %v", err)
+	}
+	for _, off := range []int64{0, 4} {
+		if _, err = s3ReaderAt.ReadAt(make([]byte, 4), off); err != nil {
+			t.Fatalf("Error calling ReadAt: %v", err)
+		}
+	}
+
+	select {
+	case <-started:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Timed out waiting for read-ahead")
+	}
+	if err = s3ReaderAt.Close(); err != nil {
+		t.Fatalf("Error calling Close: %v", err)
+	}
+
+	select {
+	case <-canceled:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Expected Close to cancel the read-ahead")
+	}
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(time.Millisecond) {
+		s3ReaderAt.readAhead.mu.Lock()
+		pending := len(s3ReaderAt.readAhead.pending)
+		s3ReaderAt.readAhead.mu.Unlock()
+		if pending == 0 {
+			break
+		} else if time.Now().After(deadline) {
+			t.Fatalf("Expected Close to release the blocks being read ahead")
+		}
+	}
+}
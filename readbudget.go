@@ -0,0 +1,86 @@
+package s3readerat
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+)
+
+// ErrReadBudgetExhausted is returned when a read exceeds Options.MaxReadDuration or Options.MaxReadAttempts. Use
+// errors.Unwrap, or errors.As, to get the error that ended its last attempt, if there was one.
+var ErrReadBudgetExhausted = errors.New("read budget exhausted")
+
+// readBudgetError is returned when a read exceeds its budget. It matches ErrReadBudgetExhausted with errors.Is and
+// wraps the error of the read's last attempt.
+type readBudgetError struct {
+	reason string
+	err    error
+}
+
+func (e *readBudgetError) Error() string {
+	if e.err == nil {
+		return fmt.Sprintf("%v: %s", ErrReadBudgetExhausted, e.reason)
+	}
+	return fmt.Sprintf("%v: %s: %v", ErrReadBudgetExhausted, e.reason, e.err)
+}
+
+// Is reports whether target is ErrReadBudgetExhausted.
+func (e *readBudgetError) Is(target error) bool {
+	return target == ErrReadBudgetExhausted
+}
+
+// Unwrap returns the error of the read's last attempt.
+func (e *readBudgetError) Unwrap() error {
+	return e.err
+}
+
+// readBudget counts the attempts made by one read. It is carried in the read's context, so that every request made on
+// the read's behalf, and every retry the s3.Client makes of them, spends from it.
+type readBudget struct {
+	// attempts is accessed atomically, so it must stay first for 64-bit alignment on 32-bit platforms.
+	attempts    int64
+	maxAttempts int64
+}
+
+type readBudgetKey struct{}
+
+// startReadBudget returns a context for one read, bounded by Options.MaxReadDuration and Options.MaxReadAttempts, and
+// a function to call with the read's error once it finishes. The function releases the context and, if the read ran
+// out of time, returns its error wrapped so that it matches ErrReadBudgetExhausted.
+func (ra *S3ReaderAt) startReadBudget(ctx context.Context) (context.Context, func(error) error) {
+	t := ra.tuning()
+	if t.MaxReadAttempts > 0 {
+		ctx = context.WithValue(ctx, readBudgetKey{}, &readBudget{maxAttempts: int64(t.MaxReadAttempts)})
+	}
+	if t.MaxReadDuration <= 0 {
+		return ctx, func(err error) error { return err }
+	}
+
+	parent := ctx
+	ctx, cancel := context.WithTimeout(ctx, t.MaxReadDuration)
+	return ctx, func(err error) error {
+		timedOut := ctx.Err() == context.DeadlineExceeded && parent.Err() == nil
+		cancel()
+		if err == nil || err == io.EOF || !timedOut {
+			return err
+		}
+		return &readBudgetError{reason: fmt.Sprintf("read took longer than %v", t.MaxReadDuration), err: err}
+	}
+}
+
+// spendAttempt spends one attempt from the budget of the read ctx belongs to, if it has one. Once the budget is spent,
+// it returns lastErr, the error that ended the previous attempt, wrapped so that it matches ErrReadBudgetExhausted.
+func spendAttempt(ctx context.Context, lastErr error) error {
+	budget, _ := ctx.Value(readBudgetKey{}).(*readBudget)
+	if budget == nil {
+		return nil
+	}
+
+	if attempts := atomic.AddInt64(&budget.attempts, 1); attempts > budget.maxAttempts {
+		return &readBudgetError{reason: fmt.Sprintf("read made %d attempts", budget.maxAttempts), err: lastErr}
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+package s3readerat
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/pkg/errors"
+)
+
+// TestReadBudget tests that a read stops once it has made MaxReadAttempts requests, counting the retries of the
+// s3.Client and resumes of CopyRange, or once it has taken MaxReadDuration, failing with an error that matches
+// ErrReadBudgetExhausted.
+func TestReadBudget(t *testing.T) {
+	f := newFakeS3(t)
+	f.put("bucket", "key", []byte("0123456789"))
+	f.hook = func(w http.ResponseWriter, r *http.Request) bool {
+		writeFakeError(w, http.StatusInternalServerError, "InternalError", true)
+		return true
+	}
+
+	options := f.options()
+	options.Retryer = retry.NewStandard(func(o *retry.StandardOptions) {
+		o.MaxAttempts = 10
+		o.Backoff = retry.BackoffDelayerFunc(func(int, error) (time.Duration, error) {
+			return 0, nil
+		})
+	})
+
+	size := int64(10)
+	s3ReaderAt, err := NewWithOptions(Options{Client: s3.New(options), Bucket: "bucket", Key: "key", Size: &size,
+		MaxReadAttempts: 3})
+	if err != nil {
+		t.Fatalf("Error calling NewWithOptions: %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		_, err = s3ReaderAt.ReadAt(make([]byte, 2), 0)
+		if !errors.Is(err, ErrReadBudgetExhausted) {
+			t.Fatalf("Expected an error matching ErrReadBudgetExhausted, got %v", err)
+		}
+		var responseError *awshttp.ResponseError
+		if !errors.As(err, &responseError) || responseError.HTTPStatusCode() != http.StatusInternalServerError {
+			t.Fatalf("Expected the error to wrap a 500 response, got %v", err)
+		}
+	}
+	// Each ReadAt has its own budget.
+	if count := f.requestCount(http.MethodGet); count != 6 {
+		t.Fatalf("Expected 6 GetObject requests, got %d", count)
+	}
+
+	f.hook = truncateGets(f, -1)
+	s3ReaderAt, err = NewWithOptions(Options{Client: f.client(), Bucket: "bucket", Key: "key", MaxReadAttempts: 2})
+	if err != nil {
+		t.Fatalf("Error calling NewWithOptions: %v", err)
+	}
+
+	var buf bytes.Buffer
+	n, err := s3ReaderAt.CopyRange(&buf, 0, -1)
+	if !errors.Is(err, ErrReadBudgetExhausted) {
+		t.Fatalf("Expected an error matching ErrReadBudgetExhausted, got %v", err)
+	}
+	if n != 7 || buf.String() != "0123456" {
+		t.Fatalf("Expected to copy \"0123456\" before giving up, got %q (n = %d)", buf.String(), n)
+	}
+
+	f.hook = func(w http.ResponseWriter, r *http.Request) bool {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+		}
+		writeFakeError(w, http.StatusInternalServerError, "InternalError", true)
+		return true
+	}
+	s3ReaderAt, err = NewWithOptions(Options{Client: f.client(), Bucket: "bucket", Key: "key", Size: &size,
+		MaxReadDuration: 50 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("Error calling NewWithOptions: %v", err)
+	}
+
+	start := time.Now()
+	_, err = s3ReaderAt.ReadAt(make([]byte, 2), 0)
+	if !errors.Is(err, ErrReadBudgetExhausted) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected an error matching ErrReadBudgetExhausted and context.DeadlineExceeded, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Fatalf("Expected the read to stop after 50ms, but it took %v", elapsed)
+	}
+}
+
+// TestReadBudgetInvalidOptions tests that negative read budgets are rejected.
+func TestReadBudgetInvalidOptions(t *testing.T) {
+	f := newFakeS3(t)
+	for _, options := range []Options{
+		{Client: f.client(), Bucket: "bucket", Key: "key", MaxReadDuration: -time.Second},
+		{Client: f.client(), Bucket: "bucket", Key: "key", MaxReadAttempts: -1},
+	} {
+		if _, err := NewWithOptions(options); err == nil {
+			t.Fatalf("Expected an error calling NewWithOptions with %+v", options)
+		}
+	}
+}
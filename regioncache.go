@@ -0,0 +1,171 @@
+package s3readerat
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsmiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+	"github.com/pkg/errors"
+)
+
+// DefaultRegionCache is the RegionCache that S3ReaderAts and Factories share unless Options.RegionCache is set.
+var DefaultRegionCache = NewRegionCache()
+
+// RegionCache remembers the region of each bucket that S3 has redirected a request to in multi-region mode, so that
+// later requests for the bucket, including those of other S3ReaderAts, go straight to its region rather than
+// rediscovering it with a failed request. A region that becomes stale, because the bucket was recreated in another
+// region, is corrected by the next redirect. It is safe for concurrent use.
+type RegionCache struct {
+	mu      sync.Mutex
+	regions map[string]string
+}
+
+// NewRegionCache returns an empty RegionCache.
+func NewRegionCache() *RegionCache {
+	return &RegionCache{regions: make(map[string]string)}
+}
+
+// Region returns the region of bucket, if it is known.
+func (c *RegionCache) Region(bucket string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	region, ok := c.regions[bucket]
+	return region, ok
+}
+
+// SetRegion records the region of bucket, as when it is known in advance.
+func (c *RegionCache) SetRegion(bucket string, region string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.regions[bucket] = region
+}
+
+// Forget forgets the region of bucket.
+func (c *RegionCache) Forget(bucket string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.regions, bucket)
+}
+
+// regionCache returns the RegionCache of options.
+func (options Options) regionCache() *RegionCache {
+	if options.RegionCache != nil {
+		return options.RegionCache
+	}
+	return DefaultRegionCache
+}
+
+// regionalClient returns the client to make a request with: in multi-region mode, the s3.Client for the region S3
+// last redirected the S3ReaderAt to or, failing that, the bucket's region, if the RegionCache knows it, and otherwise
+// s3Client.
+func (ra *S3ReaderAt) regionalClient() API {
+	ra.clientMu.Lock()
+	region := ra.region
+	ra.clientMu.Unlock()
+	if region == "" {
+		region, _ = ra.regions.Region(ra.bucket)
+	}
+
+	if region != "" {
+		if client := ra.s3ClientInRegion(region); client != nil {
+			return client
+		}
+	}
+	return ra.s3Client()
+}
+
+// redirectRegion returns the region S3 redirected a request that failed with err to, as extractRegionFromError does.
+// If the redirect does not name it, and Options.DiscoverRegion is set, it is looked up with discoverRegion in
+// multi-region mode; if that fails, err is returned.
+func (ra *S3ReaderAt) redirectRegion(ctx context.Context, err error) (string, error) {
+	region, regionErr := extractRegionFromError(err)
+	if regionErr == nil || !ra.discoverRegion || !isRedirect(err) {
+		return region, regionErr
+	}
+
+	ra.clientMu.Lock()
+	multiRegion := ra.options != nil
+	ra.clientMu.Unlock()
+	client, ok := ra.s3Client().(*s3.Client)
+	if !multiRegion || !ok || isAccessPoint(ra.bucket) {
+		return "", err
+	}
+
+	region, discoverErr := discoverRegion(ctx, client, ra.bucket, ra.owner)
+	if discoverErr != nil {
+		if ra.debug() {
+			log.Printf("Unable to discover the region of S3 bucket %s: %v", ra.bucket, discoverErr)
+		}
+		return "", err
+	}
+	if ra.debug() {
+		log.Printf("Discovered the region of S3 bucket %s: %s", ra.bucket, region)
+	}
+
+	return region, nil
+}
+
+// discoverRegion asks the service for the region of bucket, for services that redirect requests without naming it in
+// the x-amz-bucket-region header: first with a HeadBucket request, whose response may name it even so, and then with
+// a GetBucketLocation request.
+func discoverRegion(ctx context.Context, client *s3.Client, bucket string, owner string) (string, error) {
+	var header http.Header
+	resp, err := client.HeadBucket(ctx, &s3.HeadBucketInput{
+		Bucket:              aws.String(bucket),
+		ExpectedBucketOwner: optionalString(owner),
+	})
+	var responseError *smithyhttp.ResponseError
+	if err == nil {
+		if raw, ok := awsmiddleware.GetRawResponse(resp.ResultMetadata).(*smithyhttp.Response); ok {
+			header = raw.Header
+		}
+	} else if errors.As(err, &responseError) {
+		header = responseError.Response.Header
+	}
+	if region := header.Get("X-Amz-Bucket-Region"); region != "" {
+		return region, nil
+	}
+
+	location, err := client.GetBucketLocation(ctx, &s3.GetBucketLocationInput{
+		Bucket:              aws.String(bucket),
+		ExpectedBucketOwner: optionalString(owner),
+	})
+	if err != nil {
+		return "", errors.Wrap(classifyError(err), "S3 GetBucketLocation failed")
+	}
+
+	// Buckets in us-east-1 have no location constraint, and some of those in eu-west-1 have the legacy constraint EU.
+	switch location.LocationConstraint {
+	case "":
+		return "us-east-1", nil
+	case types.BucketLocationConstraintEu:
+		return "eu-west-1", nil
+	default:
+		return string(location.LocationConstraint), nil
+	}
+}
+
+// isRedirect reports whether err is a 3xx response from S3.
+func isRedirect(err error) bool {
+	var responseError *smithyhttp.ResponseError
+	return errors.As(err, &responseError) && responseError.HTTPStatusCode()/100 == 3
+}
+
+// rememberRegion records that S3 redirected a request to region, so that later requests, including those of other
+// S3ReaderAts sharing the RegionCache, are made there directly with the s3.Client s3ClientInRegion made for it.
+func (ra *S3ReaderAt) rememberRegion(region string) {
+	ra.clientMu.Lock()
+	ra.region = region
+	ra.clientMu.Unlock()
+
+	ra.regions.SetRegion(ra.bucket, region)
+}
@@ -0,0 +1,152 @@
+package s3readerat
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// TestRegionCache tests that once a request for a bucket is redirected to its region, the requests of later
+// S3ReaderAts and listings sharing the RegionCache go straight there, and that a stale region is corrected.
+func TestRegionCache(t *testing.T) {
+	f := newFakeS3(t)
+	f.put("bucket", "key", []byte("0123456789"))
+	bucketRegion := "us-west-2"
+	f.hook = func(w http.ResponseWriter, r *http.Request) bool {
+		if !strings.Contains(r.Header.Get("Authorization"), "/"+bucketRegion+"/") {
+			w.Header().Set("X-Amz-Bucket-Region", bucketRegion)
+			w.WriteHeader(http.StatusMovedPermanently)
+			return true
+		}
+		return false
+	}
+
+	options := f.options()
+	options.EndpointResolver = nil
+	regions := NewRegionCache()
+	read := func() {
+		s3ReaderAt, err := NewWithOptions(Options{Options: &options, Bucket: "bucket", Key: "key",
+			Endpoint: f.server.URL, RegionCache: regions})
+		if err != nil {
+			t.Fatalf("Error calling NewWithOptions: %v", err)
+		}
+		b := make([]byte, 4)
+		if _, err = s3ReaderAt.ReadAt(b, 2); err != nil || string(b) != "2345" {
+			t.Fatalf("Expected to read %q, got %q (%v)", "2345", b, err)
+		}
+	}
+
+	read()
+	if region, ok := regions.Region("bucket"); !ok || region != "us-west-2" {
+		t.Fatalf("Expected the bucket's region to be cached as us-west-2, got %q", region)
+	}
+	read()
+	factory := &Factory{Options: Options{Options: &options, Bucket: "bucket", Endpoint: f.server.URL,
+		RegionCache: regions}}
+	if objects, err := factory.OpenPrefix(context.Background(), ""); err != nil || len(objects) != 1 {
+		t.Fatalf("Expected to list 1 object, got %d (%v)", len(objects), err)
+	}
+	if count := f.requestCount(http.MethodGet); count != 4 {
+		t.Fatalf("Expected 4 requests, only the first redirected, got %d", count)
+	}
+
+	bucketRegion = "eu-west-1"
+	read()
+	if region, _ := regions.Region("bucket"); region != "eu-west-1" {
+		t.Fatalf("Expected the bucket's region to be corrected to eu-west-1, got %q", region)
+	}
+}
+
+// TestRedirectedClientReused tests that an S3ReaderAt redirected to its bucket's region makes its later requests there
+// directly, with the one s3.Client it made for the region, even if the RegionCache forgets the region.
+func TestRedirectedClientReused(t *testing.T) {
+	f := newFakeS3(t)
+	f.put("bucket", "key", []byte("0123456789"))
+	f.hook = func(w http.ResponseWriter, r *http.Request) bool {
+		if !strings.Contains(r.Header.Get("Authorization"), "/us-west-2/") {
+			w.Header().Set("X-Amz-Bucket-Region", "us-west-2")
+			w.WriteHeader(http.StatusMovedPermanently)
+			return true
+		}
+		return false
+	}
+
+	options := f.options()
+	options.EndpointResolver = nil
+	regions := NewRegionCache()
+	s3ReaderAt, err := NewWithOptions(Options{Options: &options, Bucket: "bucket", Key: "key",
+		Endpoint: f.server.URL, RegionCache: regions})
+	if err != nil {
+		t.Fatalf("Error calling NewWithOptions: %v", err)
+	}
+
+	b := make([]byte, 4)
+	for i := 0; i < 3; i++ {
+		if _, err = s3ReaderAt.ReadAt(b, 2); err != nil || string(b) != "2345" {
+			t.Fatalf("Expected to read %q, got %q (%v)", "2345", b, err)
+		}
+		regions.Forget("bucket")
+	}
+	if count := f.requestCount(http.MethodGet); count != 4 {
+		t.Fatalf("Expected 4 requests, only the first redirected, got %d", count)
+	}
+
+	s3ReaderAt.clientMu.Lock()
+	defer s3ReaderAt.clientMu.Unlock()
+	if len(s3ReaderAt.httpClients) != 2 || len(s3ReaderAt.regionClients) != 1 {
+		t.Fatalf("Expected one s3.Client for the client's region and one for the bucket's, got %d",
+			len(s3ReaderAt.httpClients))
+	}
+}
+
+// TestDiscoverRegion tests that with DiscoverRegion, a request redirected without the bucket's region is followed to
+// the region that HeadBucket or, failing that, GetBucketLocation gives, and that without it the redirect fails.
+func TestDiscoverRegion(t *testing.T) {
+	f := newFakeS3(t)
+	f.put("bucket", "key", []byte("0123456789"))
+	headBucketRegion := ""
+	f.hook = func(w http.ResponseWriter, r *http.Request) bool {
+		switch {
+		case strings.Contains(r.Header.Get("Authorization"), "/us-west-2/"):
+			return false
+		case r.Method == http.MethodHead && r.URL.Path == "/bucket":
+			if headBucketRegion != "" {
+				w.Header().Set("X-Amz-Bucket-Region", headBucketRegion)
+			}
+			w.WriteHeader(http.StatusOK)
+		case r.URL.Path == "/bucket" && r.URL.RawQuery == "location=":
+			w.Header().Set("Content-Type", "application/xml")
+			_, _ = w.Write([]byte("<LocationConstraint>us-west-2</LocationConstraint>"))
+		default:
+			w.WriteHeader(http.StatusMovedPermanently)
+		}
+		return true
+	}
+
+	options := f.options()
+	options.EndpointResolver = nil
+	b := make([]byte, 4)
+	for _, discover := range []bool{false, true} {
+		s3ReaderAt, err := NewWithOptions(Options{Options: &options, Bucket: "bucket", Key: "key",
+			Endpoint: f.server.URL, RegionCache: NewRegionCache(), DiscoverRegion: discover})
+		if err != nil {
+			t.Fatalf("Error calling NewWithOptions: %v", err)
+		}
+		if _, err = s3ReaderAt.ReadAt(b, 2); discover && (err != nil || string(b) != "2345") {
+			t.Fatalf("Expected to read %q, got %q (%v)", "2345", b, err)
+		} else if !discover && err == nil {
+			t.Fatalf("Expected an error following a redirect without DiscoverRegion")
+		}
+	}
+
+	headBucketRegion = "us-west-2"
+	factory := &Factory{Options: Options{Options: &options, Bucket: "bucket", Endpoint: f.server.URL,
+		RegionCache: NewRegionCache(), DiscoverRegion: true}}
+	if objects, err := factory.OpenPrefix(context.Background(), ""); err != nil || len(objects) != 1 {
+		t.Fatalf("Expected to list 1 object, got %d (%v)", len(objects), err)
+	}
+	if count := f.requestCount(http.MethodHead); count != 2 {
+		t.Fatalf("Expected a HeadBucket request for each discovery, got %d", count)
+	}
+}
@@ -0,0 +1,113 @@
+package s3readerat
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+
+	awsmiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
+	smithymiddleware "github.com/aws/smithy-go/middleware"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+	"github.com/pkg/errors"
+)
+
+// RequestLogEntry describes one S3 request, or one read served from memory, as written to Options.RequestLog.
+type RequestLogEntry struct {
+	// Time is when the request started.
+	Time time.Time `json:"time"`
+
+	// Operation is "HeadObject", "GetObject" or "GetObjectAttributes".
+	Operation string `json:"operation"`
+
+	Bucket string `json:"bucket"`
+	Key    string `json:"key"`
+
+	// Range is the HTTP Range of a GetObject request, if any.
+	Range string `json:"range,omitempty"`
+
+	// DurationMs is the time taken, in milliseconds, including reading the response body.
+	DurationMs float64 `json:"duration_ms"`
+
+	// Status is the HTTP status code of the response, if one was received.
+	Status int `json:"status,omitempty"`
+
+	// Bytes is the number of response body bytes read.
+	Bytes int64 `json:"bytes"`
+
+	// CacheHit indicates whether the read was served from memory rather than S3.
+	CacheHit bool `json:"cache_hit"`
+
+	// Error describes why the request failed, if it did.
+	Error string `json:"error,omitempty"`
+
+	// Tags are the tags the request was attributed to with WithTags, if any.
+	Tags map[string]string `json:"tags,omitempty"`
+}
+
+// requestLog serializes RequestLogEntry values as newline-delimited JSON. Each entry is written with a single call to
+// Write.
+type requestLog struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (l *requestLog) write(entry RequestLogEntry) {
+	b, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	_, _ = l.w.Write(append(b, '\n'))
+}
+
+// responseStatus returns the HTTP status code of a response, given its metadata or the error it produced, or zero if
+// no response was received.
+func responseStatus(metadata smithymiddleware.Metadata, err error) int {
+	var responseError *smithyhttp.ResponseError
+	if errors.As(err, &responseError) {
+		return responseError.HTTPStatusCode()
+	}
+
+	if resp, ok := awsmiddleware.GetRawResponse(metadata).(*smithyhttp.Response); ok {
+		return resp.StatusCode
+	}
+
+	return 0
+}
+
+// recordedBody wraps a GetObject response body, recording the request once the body is closed so that the stats and
+// request log include the bytes read and the time taken to read them.
+type recordedBody struct {
+	io.ReadCloser
+
+	ra    *S3ReaderAt
+	entry RequestLogEntry
+	start time.Time
+	ttfb  time.Duration
+	err   error
+	once  sync.Once
+}
+
+func (b *recordedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.entry.Bytes += int64(n)
+	if err != nil && err != io.EOF {
+		b.err = err
+	}
+	return n, err
+}
+
+func (b *recordedBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(func() {
+		if b.err == nil {
+			b.ra.transfers.observe(b.entry.Bytes, b.ttfb, time.Since(b.start))
+		}
+		b.ra.recordRequest(b.entry, b.start, b.err)
+	})
+	return err
+}
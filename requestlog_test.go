@@ -0,0 +1,74 @@
+package s3readerat
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+// TestRequestLog tests that RequestLog receives one JSON entry per request, including failed requests and reads served
+// from memory.
+func TestRequestLog(t *testing.T) {
+	f := newFakeS3(t)
+	f.put("bucket", "key", []byte("0123456789"))
+
+	var log bytes.Buffer
+	s3ReaderAt, err := NewWithOptions(Options{
+		Client:            f.client(),
+		Bucket:            "bucket",
+		Key:               "key",
+		PrefetchTailBytes: 4,
+		RequestLog:        &log,
+	})
+	if err != nil {
+		t.Fatalf("Error calling NewWithOptions: %v", err)
+	}
+
+	b := make([]byte, 2)
+	for _, off := range []int64{0, 6, 8} {
+		if _, err = s3ReaderAt.ReadAt(b, off); err != nil {
+			t.Fatalf("Error calling ReadAt: %v", err)
+		}
+	}
+
+	missing, err := New(f.client(), "bucket", "missing")
+	if err != nil {
+		t.Fatalf("Error calling New: %v", err)
+	}
+	missing.requestLog = s3ReaderAt.requestLog
+	if _, err = missing.Size(); err == nil {
+		t.Fatalf("Expected an error calling Size for a missing object")
+	}
+
+	var entries []RequestLogEntry
+	decoder := json.NewDecoder(&log)
+	for decoder.More() {
+		var entry RequestLogEntry
+		if err = decoder.Decode(&entry); err != nil {
+			t.Fatalf("Error decoding request log: %v", err)
+		}
+		entries = append(entries, entry)
+	}
+
+	expected := []RequestLogEntry{
+		{Operation: "HeadObject", Status: http.StatusOK},
+		{Operation: "GetObject", Range: "bytes=0-1", Status: http.StatusPartialContent, Bytes: 2},
+		{Operation: "GetObject", Range: "bytes=6-9", Status: http.StatusPartialContent, Bytes: 4},
+		{Operation: "GetObject", Range: "bytes=6-7", Bytes: 2, CacheHit: true},
+		{Operation: "GetObject", Range: "bytes=8-9", Bytes: 2, CacheHit: true},
+		{Operation: "HeadObject", Status: http.StatusNotFound},
+	}
+	if len(entries) != len(expected) {
+		t.Fatalf("Expected %d request log entries, got %d", len(expected), len(entries))
+	}
+
+	for i, entry := range entries {
+		want := expected[i]
+		failed := entry.Error != ""
+		if entry.Operation != want.Operation || entry.Range != want.Range || entry.Status != want.Status ||
+			entry.Bytes != want.Bytes || entry.CacheHit != want.CacheHit || failed != (want.Status >= 400) {
+			t.Fatalf("Request log entry %d is %+v, expected %+v", i, entry, want)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package s3readerat
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ErrRequestTimeout is returned when a HeadObject or GetObject request, including the reading of its response body,
+// takes longer than Options.RequestTimeout. Unlike a deadline of the caller's context, it is retried by RetryPolicy.
+var ErrRequestTimeout = errors.New("S3 request timed out")
+
+// requestDeadline bounds one request by Options.RequestTimeout.
+type requestDeadline struct {
+	parent  context.Context
+	ctx     context.Context
+	cancel  context.CancelFunc
+	timeout time.Duration
+}
+
+// startRequest returns the requestDeadline of a request to be made with ctx. Its ctx is ctx itself if
+// Options.RequestTimeout is not set. The caller must call its cancel method once the request, including the reading
+// of its response body, finishes.
+func (ra *S3ReaderAt) startRequest(ctx context.Context) *requestDeadline {
+	d := &requestDeadline{parent: ctx, ctx: ctx, cancel: func() {}, timeout: ra.tuning().RequestTimeout}
+	if d.timeout > 0 {
+		d.ctx, d.cancel = context.WithTimeout(ctx, d.timeout)
+	}
+	return d
+}
+
+// wrap returns err wrapped so that it matches ErrRequestTimeout if the request ran out of time, rather than the
+// context it was made with.
+func (d *requestDeadline) wrap(err error) error {
+	if err == nil || err == io.EOF || d.ctx.Err() != context.DeadlineExceeded || d.parent.Err() != nil {
+		return err
+	}
+	return errors.Wrapf(ErrRequestTimeout, "request took longer than %v: %v", d.timeout, err)
+}
+
+// deadlineBody is a response body whose request is bounded by a requestDeadline, which it releases when closed.
+type deadlineBody struct {
+	io.ReadCloser
+	deadline *requestDeadline
+}
+
+func (b *deadlineBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	return n, b.deadline.wrap(err)
+}
+
+func (b *deadlineBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.deadline.cancel()
+	return err
+}
@@ -0,0 +1,83 @@
+package s3readerat
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	s3retry "github.com/markandrus/s3readerat/retry"
+	"github.com/pkg/errors"
+)
+
+// stallFirst returns a fakeS3 hook that stalls the first n requests with the given HTTP method until the client gives
+// up on them. GetObject requests stall after their headers, so that only the body is late.
+func stallFirst(method string, n int) func(w http.ResponseWriter, r *http.Request) bool {
+	remaining := n
+	return func(w http.ResponseWriter, r *http.Request) bool {
+		if r.Method != method || remaining == 0 {
+			return false
+		}
+		remaining--
+
+		if method == http.MethodGet {
+			w.Header().Set("Content-Length", strconv.Itoa(8))
+			w.Header().Set("Content-Range", "bytes 0-7/10")
+			w.WriteHeader(http.StatusPartialContent)
+			w.(http.Flusher).Flush()
+		}
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+		return true
+	}
+}
+
+// TestRequestTimeout tests that HeadObject and GetObject requests, including the reading of response bodies, fail
+// with ErrRequestTimeout once they take longer than RequestTimeout, and that Retry retries them.
+func TestRequestTimeout(t *testing.T) {
+	f := newFakeS3(t)
+	f.put("bucket", "key", []byte("0123456789"))
+
+	// Stalled handlers may still be running, so the hook is set with the lock held.
+	setHook := func(hook func(w http.ResponseWriter, r *http.Request) bool) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		f.hook = hook
+	}
+
+	s3ReaderAt, err := NewWithOptions(Options{Client: f.client(), Bucket: "bucket", Key: "key",
+		RequestTimeout: 50 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("Error calling NewWithOptions: %v", err)
+	}
+
+	setHook(stallFirst(http.MethodHead, 1))
+	if _, err = s3ReaderAt.Size(); !errors.Is(err, ErrRequestTimeout) {
+		t.Fatalf("Expected ErrRequestTimeout from Size, got %v", err)
+	}
+
+	setHook(stallFirst(http.MethodGet, 1))
+	b := make([]byte, 8)
+	if _, err = s3ReaderAt.ReadAt(b, 0); !errors.Is(err, ErrRequestTimeout) {
+		t.Fatalf("Expected ErrRequestTimeout from ReadAt, got %v", err)
+	}
+
+	retry := &RetryPolicy{Policy: s3retry.Policy{Backoff: time.Millisecond}}
+	s3ReaderAt, err = NewWithOptions(Options{Client: f.client(), Bucket: "bucket", Key: "key",
+		RequestTimeout: 50 * time.Millisecond, Retry: retry})
+	if err != nil {
+		t.Fatalf("Error calling NewWithOptions: %v", err)
+	}
+
+	setHook(stallFirst(http.MethodGet, 1))
+	if n, err := s3ReaderAt.ReadAt(b, 0); err != nil || string(b[:n]) != "01234567" {
+		t.Fatalf("Expected to read %q, got %q (%v)", "01234567", b[:n], err)
+	}
+
+	_, err = NewWithOptions(Options{Client: f.client(), Bucket: "bucket", Key: "key", RequestTimeout: -1})
+	if err == nil {
+		t.Fatalf("Expected an error for a negative RequestTimeout")
+	}
+}
@@ -0,0 +1,99 @@
+package retry
+
+import (
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ErrBudgetExhausted is matched, with errors.Is, by the errors returned when a retry is refused because a Budget is
+// exhausted. Those errors also wrap the error that would have been retried.
+var ErrBudgetExhausted = errors.New("retry budget exhausted")
+
+// Budget limits the rate of retries made by everything that shares it, so that when a widespread S3 incident makes
+// most requests fail, thousands of readers retrying independently do not multiply the traffic to S3. It is a token
+// bucket: each retry spends a token, and tokens are refilled at the rate given to NewBudget. Share one Budget between
+// the ReaderAts of this package and the S3ReaderAts of package s3readerat, through Options.Budget and
+// s3readerat.Options.RetryBudget. It is safe for concurrent use.
+type Budget struct {
+	max  float64
+	rate float64 // tokens per second
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+	spent  int64
+	denied int64
+}
+
+// NewBudget returns a Budget that allows retries retries per window, in bursts of up to retries.
+func NewBudget(retries int, window time.Duration) (*Budget, error) {
+	if retries <= 0 {
+		return nil, errors.Errorf("provided retries is invalid: %d", retries)
+	} else if window <= 0 {
+		return nil, errors.Errorf("provided window is invalid: %v", window)
+	}
+
+	return &Budget{
+		max:    float64(retries),
+		rate:   float64(retries) / window.Seconds(),
+		tokens: float64(retries),
+		last:   time.Now(),
+	}, nil
+}
+
+// Spend spends a token to retry after err, returning nil if one was available. Otherwise it returns an error that
+// wraps err and matches ErrBudgetExhausted, which the caller should return instead of retrying. A nil Budget allows
+// every retry.
+func (b *Budget) Spend(err error) error {
+	if b == nil {
+		return nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.max {
+		b.tokens = b.max
+	}
+	b.last = now
+
+	if b.tokens < 1 {
+		b.denied++
+		return &exhaustedError{err: err}
+	}
+
+	b.tokens--
+	b.spent++
+	return nil
+}
+
+// Stats returns the number of retries the Budget has allowed and refused.
+func (b *Budget) Stats() (spent int64, denied int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.spent, b.denied
+}
+
+// exhaustedError is returned by Spend when no token is available.
+type exhaustedError struct {
+	err error
+}
+
+func (e *exhaustedError) Error() string {
+	return ErrBudgetExhausted.Error() + ": " + e.err.Error()
+}
+
+// Unwrap returns the error that would have been retried.
+func (e *exhaustedError) Unwrap() error {
+	return e.err
+}
+
+// Is reports whether target is ErrBudgetExhausted.
+func (e *exhaustedError) Is(target error) bool {
+	return target == ErrBudgetExhausted
+}
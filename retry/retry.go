@@ -0,0 +1,141 @@
+// Package retry provides an io.ReaderAt that retries failed reads of an underlying io.ReaderAt, resuming from the
+// last byte read. See package cache for how decorators compose.
+package retry
+
+import (
+	"io"
+	"math/rand"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// DefaultAttempts is the number of times a read is attempted by default.
+	DefaultAttempts = 3
+
+	// DefaultBackoff is the delay before the first retry by default.
+	DefaultBackoff = 100 * time.Millisecond
+
+	// DefaultMaxBackoff is the longest delay between retries by default.
+	DefaultMaxBackoff = 5 * time.Second
+)
+
+// Policy is how many times a read is attempted and how long to wait between attempts. It is shared by ReaderAt and
+// s3readerat.RetryPolicy.
+type Policy struct {
+	// Attempts is the number of times a read is attempted, including the first. The default is DefaultAttempts.
+	Attempts int
+
+	// Backoff is the delay before the first retry. It doubles with each retry, up to MaxBackoff. The default is
+	// DefaultBackoff.
+	Backoff time.Duration
+
+	// MaxBackoff is the longest delay between retries. The default is DefaultMaxBackoff.
+	MaxBackoff time.Duration
+
+	// Jitter is the fraction of each delay, from 0 to 1, that is randomized, so that readers that failed together do
+	// not retry in lockstep. Zero means no jitter.
+	Jitter float64
+}
+
+// Validate returns an error if a field of the Policy is negative, or Jitter is greater than 1.
+func (p Policy) Validate() error {
+	switch {
+	case p.Attempts < 0:
+		return errors.Errorf("provided Attempts is invalid: %d", p.Attempts)
+	case p.Backoff < 0:
+		return errors.Errorf("provided Backoff is invalid: %v", p.Backoff)
+	case p.MaxBackoff < 0:
+		return errors.Errorf("provided MaxBackoff is invalid: %v", p.MaxBackoff)
+	case p.Jitter < 0 || p.Jitter > 1:
+		return errors.Errorf("provided Jitter is invalid: %v", p.Jitter)
+	default:
+		return nil
+	}
+}
+
+// MaxAttempts returns the number of times a read is attempted, including the first.
+func (p Policy) MaxAttempts() int {
+	if p.Attempts <= 0 {
+		return DefaultAttempts
+	}
+	return p.Attempts
+}
+
+// Delay returns the delay before retry number retry, counting from 1.
+func (p Policy) Delay(retry int) time.Duration {
+	backoff, maxBackoff := p.Backoff, p.MaxBackoff
+	if backoff <= 0 {
+		backoff = DefaultBackoff
+	}
+	if maxBackoff <= 0 {
+		maxBackoff = DefaultMaxBackoff
+	}
+	for i := 1; i < retry && backoff < maxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > maxBackoff {
+		backoff = maxBackoff
+	}
+	fraction := p.Jitter
+	if fraction > 1 {
+		fraction = 1
+	}
+	if jitter := int64(float64(backoff) * fraction); jitter > 0 {
+		backoff -= time.Duration(rand.Int63n(jitter + 1))
+	}
+	return backoff
+}
+
+// Options configures a ReaderAt.
+type Options struct {
+	// Policy sets the number of attempts and the delays between them.
+	Policy
+
+	// Retryable reports whether a read that failed with err should be retried. The default retries every error but
+	// io.EOF.
+	Retryable func(err error) bool
+
+	// Budget, if set, limits the rate of retries across everything sharing it. A read whose retry it refuses fails
+	// with an error matching ErrBudgetExhausted.
+	Budget *Budget
+}
+
+// ReaderAt retries failed reads of an underlying io.ReaderAt. It is safe for concurrent use if the underlying
+// io.ReaderAt is.
+type ReaderAt struct {
+	r       io.ReaderAt
+	options Options
+}
+
+// New returns a ReaderAt that retries failed reads of r.
+func New(r io.ReaderAt, options Options) *ReaderAt {
+	if options.Retryable == nil {
+		options.Retryable = func(err error) bool { return err != io.EOF }
+	}
+
+	return &ReaderAt{r: r, options: options}
+}
+
+// ReadAt reads len(p) bytes starting at off. If a read fails with a retryable error, the bytes not yet read are read
+// again after a backoff. The error from the last attempt is returned.
+func (r *ReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	n := 0
+	for attempt := 1; ; attempt++ {
+		read, err := r.r.ReadAt(p[n:], off+int64(n))
+		n += read
+		if err == nil || n == len(p) {
+			return n, err
+		}
+
+		if attempt >= r.options.MaxAttempts() || !r.options.Retryable(err) {
+			return n, err
+		}
+		if err = r.options.Budget.Spend(err); err != nil {
+			return n, err
+		}
+
+		time.Sleep(r.options.Delay(attempt))
+	}
+}
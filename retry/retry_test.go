@@ -0,0 +1,141 @@
+package retry
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// flakyReaderAt returns at most limit bytes from each of its first failures reads, followed by an error.
+type flakyReaderAt struct {
+	r        io.ReaderAt
+	failures int
+	limit    int
+	reads    int
+}
+
+func (f *flakyReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	f.reads++
+	if f.failures == 0 {
+		return f.r.ReadAt(p, off)
+	}
+	f.failures--
+
+	if len(p) > f.limit {
+		p = p[:f.limit]
+	}
+	n, _ := f.r.ReadAt(p, off)
+	return n, errors.New("connection reset")
+}
+
+// TestReaderAt tests that failed reads are retried from the last byte read, and that the last error is returned once
+// the attempts are exhausted.
+func TestReaderAt(t *testing.T) {
+	data := []byte("0123456789")
+	flaky := &flakyReaderAt{r: bytes.NewReader(data), failures: 2, limit: 3}
+	r := New(flaky, Options{Policy: Policy{Backoff: 1}})
+
+	b := make([]byte, 8)
+	n, err := r.ReadAt(b, 1)
+	if err != nil {
+		t.Fatalf("Error calling ReadAt: %v", err)
+	}
+	if string(b[:n]) != "12345678" {
+		t.Fatalf("Expected %q, got %q", "12345678", b[:n])
+	}
+	if flaky.reads != 3 {
+		t.Fatalf("Expected 3 reads, got %d", flaky.reads)
+	}
+
+	flaky.failures, flaky.reads = 5, 0
+	if _, err = r.ReadAt(b, 0); err == nil {
+		t.Fatalf("Expected an error calling ReadAt")
+	}
+	if flaky.reads != DefaultAttempts {
+		t.Fatalf("Expected %d reads, got %d", DefaultAttempts, flaky.reads)
+	}
+
+	flaky.failures, flaky.reads = 0, 0
+	if n, err = r.ReadAt(b, 5); err != io.EOF || n != 5 {
+		t.Fatalf("Expected 5 bytes and io.EOF, got %d bytes and %v", n, err)
+	}
+	if flaky.reads != 1 {
+		t.Fatalf("Expected io.EOF not to be retried, got %d reads", flaky.reads)
+	}
+}
+
+// TestPolicy tests that a Policy's delays double from Backoff up to MaxBackoff, less up to Jitter of each, that zero
+// fields take their defaults, and that invalid policies are rejected.
+func TestPolicy(t *testing.T) {
+	p := Policy{Backoff: 10 * time.Millisecond, MaxBackoff: 50 * time.Millisecond}
+	for retry, expected := range []time.Duration{10, 20, 40, 50, 50} {
+		if delay := p.Delay(retry + 1); delay != expected*time.Millisecond {
+			t.Fatalf("Expected a delay of %v before retry %d, got %v", expected*time.Millisecond, retry+1, delay)
+		}
+	}
+
+	p.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		if delay := p.Delay(2); delay < 10*time.Millisecond || delay > 20*time.Millisecond {
+			t.Fatalf("Expected a delay between 10ms and 20ms with jitter, got %v", delay)
+		}
+	}
+
+	var defaults Policy
+	if defaults.MaxAttempts() != DefaultAttempts || defaults.Delay(1) != DefaultBackoff ||
+		defaults.Delay(100) != DefaultMaxBackoff {
+		t.Fatalf("Expected the default attempts and backoff, got %d, %v and %v", defaults.MaxAttempts(),
+			defaults.Delay(1), defaults.Delay(100))
+	}
+	if err := defaults.Validate(); err != nil {
+		t.Fatalf("Error calling Validate: %v", err)
+	}
+
+	for _, invalid := range []Policy{{Attempts: -1}, {Backoff: -1}, {MaxBackoff: -1}, {Jitter: -0.5}, {Jitter: 2}} {
+		if err := invalid.Validate(); err == nil {
+			t.Fatalf("Expected an error validating %+v", invalid)
+		}
+	}
+}
+
+// TestBudget tests that a Budget shared by ReaderAts refuses retries once its tokens are spent, and that the error
+// returned wraps the error that would have been retried.
+func TestBudget(t *testing.T) {
+	if _, err := NewBudget(0, time.Second); err == nil {
+		t.Fatalf("Expected an error calling NewBudget with no retries")
+	}
+
+	budget, err := NewBudget(3, time.Hour)
+	if err != nil {
+		t.Fatalf("Error calling NewBudget: %v", err)
+	}
+
+	data := []byte("0123456789")
+	first := &flakyReaderAt{r: bytes.NewReader(data), failures: 2, limit: 3}
+	second := &flakyReaderAt{r: bytes.NewReader(data), failures: 2, limit: 3}
+	options := Options{Policy: Policy{Backoff: 1}, Budget: budget}
+
+	b := make([]byte, 8)
+	if _, err = New(first, options).ReadAt(b, 0); err != nil {
+		t.Fatalf("Error calling ReadAt: %v", err)
+	}
+
+	_, err = New(second, options).ReadAt(b, 0)
+	if !errors.Is(err, ErrBudgetExhausted) {
+		t.Fatalf("Expected an error matching ErrBudgetExhausted, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "connection reset") {
+		t.Fatalf("Expected the error to wrap the failed read, got %v", err)
+	}
+	if second.reads != 2 {
+		t.Fatalf("Expected 2 reads, got %d", second.reads)
+	}
+
+	if spent, denied := budget.Stats(); spent != 3 || denied != 1 {
+		t.Fatalf("Expected 3 retries spent and 1 denied, got %d and %d", spent, denied)
+	}
+}
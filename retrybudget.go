@@ -0,0 +1,74 @@
+package s3readerat
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3retry "github.com/markandrus/s3readerat/retry"
+)
+
+// budgetRetryer wraps the Retryer of an s3.Client so that each retry it allows also spends a token from
+// Options.RetryBudget, if set, and an attempt from the budget of the read it is made for, if any. A retry either budget
+// refuses fails the request with the error that would have been retried, wrapped so that it matches
+// retry.ErrBudgetExhausted or ErrReadBudgetExhausted.
+type budgetRetryer struct {
+	aws.RetryerV2
+	budget *s3retry.Budget
+}
+
+// GetRetryToken gets a retry token from the wrapped Retryer, then from the budgets.
+func (r budgetRetryer) GetRetryToken(ctx context.Context, opErr error) (func(error) error, error) {
+	release, err := r.RetryerV2.GetRetryToken(ctx, opErr)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = spendAttempt(ctx, opErr); err == nil {
+		err = r.budget.Spend(opErr)
+	}
+	if err != nil {
+		// No retry will be made, so return the token.
+		_ = release(nil)
+		return nil, err
+	}
+
+	return release, nil
+}
+
+// retryerV2 adapts a Retryer without GetAttemptToken, as the SDK does.
+type retryerV2 struct {
+	aws.Retryer
+}
+
+// GetAttemptToken delegates to GetInitialToken.
+func (r retryerV2) GetAttemptToken(context.Context) (func(error) error, error) {
+	return r.GetInitialToken(), nil
+}
+
+// requestOptions returns the per-request options that apply the S3ReaderAt's retry budget and read budgets, if any,
+// to the retries made by an s3.Client, and that let it reach an access point in another region. Implementations of
+// API other than s3.Client ignore them.
+func (ra *S3ReaderAt) requestOptions() []func(*s3.Options) {
+	var optFns []func(*s3.Options)
+	if isAccessPoint(ra.bucket) {
+		optFns = append(optFns, useARNRegion)
+	}
+
+	t := ra.tuning()
+	if t.RetryBudget == nil && t.MaxReadAttempts == 0 {
+		return optFns
+	}
+
+	return append(optFns, func(o *s3.Options) {
+		if o.Retryer == nil {
+			return
+		}
+
+		retryer, ok := o.Retryer.(aws.RetryerV2)
+		if !ok {
+			retryer = retryerV2{o.Retryer}
+		}
+		o.Retryer = budgetRetryer{RetryerV2: retryer, budget: t.RetryBudget}
+	})
+}
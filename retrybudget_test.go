@@ -0,0 +1,64 @@
+package s3readerat
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3retry "github.com/markandrus/s3readerat/retry"
+	"github.com/pkg/errors"
+)
+
+// TestRetryBudget tests that S3ReaderAts sharing a RetryBudget stop retrying failed requests once it is exhausted,
+// failing with an error that matches retry.ErrBudgetExhausted and wraps the last response.
+func TestRetryBudget(t *testing.T) {
+	f := newFakeS3(t)
+	f.put("bucket", "key", []byte("0123456789"))
+	f.hook = func(w http.ResponseWriter, r *http.Request) bool {
+		writeFakeError(w, http.StatusInternalServerError, "InternalError", true)
+		return true
+	}
+
+	options := f.options()
+	options.Retryer = retry.NewStandard(func(o *retry.StandardOptions) {
+		o.MaxAttempts = 5
+		o.Backoff = retry.BackoffDelayerFunc(func(int, error) (time.Duration, error) {
+			return 0, nil
+		})
+	})
+	client := s3.New(options)
+
+	budget, err := s3retry.NewBudget(2, time.Hour)
+	if err != nil {
+		t.Fatalf("Error calling NewBudget: %v", err)
+	}
+
+	size := int64(10)
+	for i := 0; i < 2; i++ {
+		s3ReaderAt, err := NewWithOptions(Options{Client: client, Bucket: "bucket", Key: "key", Size: &size,
+			RetryBudget: budget})
+		if err != nil {
+			t.Fatalf("Error calling NewWithOptions: %v", err)
+		}
+
+		_, err = s3ReaderAt.ReadAt(make([]byte, 2), 0)
+		if !errors.Is(err, s3retry.ErrBudgetExhausted) {
+			t.Fatalf("Expected an error matching ErrBudgetExhausted, got %v", err)
+		}
+		var responseError *awshttp.ResponseError
+		if !errors.As(err, &responseError) || responseError.HTTPStatusCode() != http.StatusInternalServerError {
+			t.Fatalf("Expected the error to wrap a 500 response, got %v", err)
+		}
+	}
+
+	// The first ReadAt makes its first attempt and two retries, and the second only its first attempt.
+	if count := f.requestCount(http.MethodGet); count != 4 {
+		t.Fatalf("Expected 4 GetObject requests, got %d", count)
+	}
+	if spent, denied := budget.Stats(); spent != 2 || denied != 2 {
+		t.Fatalf("Expected 2 retries spent and 2 denied, got %d and %d", spent, denied)
+	}
+}
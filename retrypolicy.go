@@ -0,0 +1,109 @@
+package s3readerat
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"time"
+
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+	s3retry "github.com/markandrus/s3readerat/retry"
+	"github.com/pkg/errors"
+)
+
+// RetryPolicy configures the retries an S3ReaderAt makes itself of reads, and of the HeadObject requests of Size and
+// the like, that fail with transient errors, on top of those of the s3.Client's Retryer. Unlike the Retryer, it also
+// retries reads whose response body fails part-way, such as when the connection is reset or the body is truncated,
+// resuming from the last byte read. Each retry spends from Options.RetryBudget, if set.
+type RetryPolicy struct {
+	// Policy sets the number of times a read or request is attempted, including the first, and the delays between
+	// them, as for the ReaderAt of package retry.
+	s3retry.Policy
+
+	// Retryable reports whether a read or request that failed with err should be retried. The default is IsRetryable.
+	Retryable func(err error) bool
+}
+
+// validate returns an error if the RetryPolicy is invalid. A nil RetryPolicy is valid.
+func (p *RetryPolicy) validate() error {
+	if p == nil {
+		return nil
+	}
+	return errors.Wrap(p.Policy.Validate(), "provided Retry is invalid")
+}
+
+// attempts returns the number of times a read or request is attempted. A nil RetryPolicy attempts each once.
+func (p *RetryPolicy) attempts() int {
+	if p == nil {
+		return 1
+	}
+	return p.MaxAttempts()
+}
+
+// retryable reports whether a read or request that failed with err should be retried.
+func (p *RetryPolicy) retryable(err error) bool {
+	if p == nil {
+		return false
+	} else if p.Retryable != nil {
+		return p.Retryable(err)
+	}
+	return IsRetryable(err)
+}
+
+// wait blocks for the delay before retry number retry, counting from 1, or until ctx is done.
+func (p *RetryPolicy) wait(ctx context.Context, retry int) error {
+	if p == nil {
+		return nil
+	}
+
+	timer := time.NewTimer(p.Delay(retry))
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// IsRetryable reports whether err is a transient error that RetryPolicy retries by default: a 5xx response, throttling
+// by S3, a failure to reach S3, a request that exceeded Options.RequestTimeout, or a response body that failed or was
+// truncated part-way. Cancellation, deadlines, exhausted budgets and quotas, changed objects and other 4xx responses
+// are not retried.
+func IsRetryable(err error) bool {
+	switch {
+	case err == nil || err == io.EOF:
+		return false
+	case errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded):
+		return false
+	case errors.Is(err, ErrReadBudgetExhausted) || errors.Is(err, s3retry.ErrBudgetExhausted):
+		return false
+	case errors.Is(err, ErrQuotaExceeded) || errors.Is(err, ErrObjectChanged) || errors.Is(err, ErrClosed):
+		return false
+	case errors.Is(err, ErrThrottled) || errors.Is(err, ErrRequestTimeout) ||
+		errors.Is(err, ErrContentLengthMismatch) || errors.Is(err, io.ErrUnexpectedEOF):
+		return true
+	}
+
+	var responseError *smithyhttp.ResponseError
+	if errors.As(err, &responseError) {
+		return responseError.HTTPStatusCode() >= http.StatusInternalServerError
+	}
+
+	var requestSendError *smithyhttp.RequestSendError
+	var netError net.Error
+	return errors.As(err, &requestSendError) || errors.As(err, &netError)
+}
+
+// retryable reports whether a read that failed with err on attempt number attempt, counting from 1, should be
+// retried: in strict mode, if its response body was truncated, and otherwise as Options.Retry decides.
+func (ra *S3ReaderAt) retryable(err error, attempt int) bool {
+	if ra.strict && errors.Is(err, ErrContentLengthMismatch) && attempt < strictAttempts {
+		return true
+	}
+
+	policy := ra.tuning().Retry
+	return attempt < policy.attempts() && policy.retryable(err)
+}
@@ -0,0 +1,126 @@
+package s3readerat
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+	s3retry "github.com/markandrus/s3readerat/retry"
+	"github.com/pkg/errors"
+)
+
+// failFirst returns a fakeS3 hook that fails the first n requests with the given HTTP method with a 500 response.
+func failFirst(method string, n int) func(w http.ResponseWriter, r *http.Request) bool {
+	remaining := n
+	return func(w http.ResponseWriter, r *http.Request) bool {
+		if r.Method != method || remaining == 0 {
+			return false
+		}
+		remaining--
+
+		writeFakeError(w, http.StatusInternalServerError, "InternalError", r.Method != http.MethodHead)
+		return true
+	}
+}
+
+// TestRetryPolicy tests that, with Options.Retry, HeadObject requests and reads that fail with 5xx responses are
+// retried, that truncated response bodies are resumed from the last byte received, and that without it they are not.
+func TestRetryPolicy(t *testing.T) {
+	f := newFakeS3(t)
+	f.put("bucket", "key", []byte("0123456789"))
+	retry := &RetryPolicy{Policy: s3retry.Policy{Attempts: 3, Backoff: time.Millisecond, Jitter: 0.5}}
+
+	f.hook = failFirst(http.MethodHead, 2)
+	s3ReaderAt, err := NewWithOptions(Options{Client: f.client(), Bucket: "bucket", Key: "key", Retry: retry})
+	if err != nil {
+		t.Fatalf("Error calling NewWithOptions: %v", err)
+	}
+	if size, err := s3ReaderAt.Size(); err != nil || size != 10 {
+		t.Fatalf("Expected size 10, got %d (%v)", size, err)
+	}
+	if count := f.requestCount(http.MethodHead); count != 3 {
+		t.Fatalf("Expected 3 HeadObject requests, got %d", count)
+	}
+
+	f.hook = failFirst(http.MethodGet, 1)
+	b := make([]byte, 8)
+	if n, err := s3ReaderAt.ReadAt(b, 0); err != nil || string(b[:n]) != "01234567" {
+		t.Fatalf("Expected to read %q, got %q (%v)", "01234567", b[:n], err)
+	}
+	if count := f.requestCount(http.MethodGet); count != 2 {
+		t.Fatalf("Expected 2 GetObject requests, got %d", count)
+	}
+
+	// Each truncated body halves the remaining range, which the next request resumes.
+	f.hook = truncateGets(f, 2)
+	if n, err := s3ReaderAt.ReadAt(b, 2); err != nil || string(b[:n]) != "23456789" {
+		t.Fatalf("Expected to read %q, got %q (%v)", "23456789", b[:n], err)
+	}
+	var ranges []string
+	f.mu.Lock()
+	for _, r := range f.requests[len(f.requests)-3:] {
+		ranges = append(ranges, r.Header.Get("Range"))
+	}
+	f.mu.Unlock()
+	if ranges[0] != "bytes=2-9" || ranges[1] != "bytes=6-9" || ranges[2] != "bytes=8-9" {
+		t.Fatalf("Unexpected ranges: %v", ranges)
+	}
+
+	f.hook = failFirst(http.MethodGet, 3)
+	if _, err = s3ReaderAt.ReadAt(b, 0); err == nil {
+		t.Fatalf("Expected an error once Attempts were made")
+	}
+
+	s3ReaderAt, err = NewWithOptions(Options{Client: f.client(), Bucket: "bucket", Key: "key", Size: aws.Int64(10)})
+	if err != nil {
+		t.Fatalf("Error calling NewWithOptions: %v", err)
+	}
+	f.hook = failFirst(http.MethodGet, 1)
+	if _, err = s3ReaderAt.ReadAt(b, 0); err == nil {
+		t.Fatalf("Expected an error without Retry")
+	}
+
+	for _, invalid := range []s3retry.Policy{{Attempts: -1}, {Backoff: -1}, {MaxBackoff: -1}, {Jitter: 2}} {
+		_, err = NewWithOptions(Options{Client: f.client(), Bucket: "bucket", Key: "key",
+			Retry: &RetryPolicy{Policy: invalid}})
+		if err == nil {
+			t.Fatalf("Expected an error for Retry %+v", invalid)
+		}
+	}
+}
+
+// TestIsRetryable tests that IsRetryable retries transient errors only.
+func TestIsRetryable(t *testing.T) {
+	serverError := &smithyhttp.ResponseError{
+		Response: &smithyhttp.Response{Response: &http.Response{StatusCode: http.StatusServiceUnavailable}},
+	}
+	notFound := &smithyhttp.ResponseError{
+		Response: &smithyhttp.Response{Response: &http.Response{StatusCode: http.StatusNotFound}},
+	}
+
+	for _, tc := range []struct {
+		err       error
+		retryable bool
+	}{
+		{nil, false},
+		{io.EOF, false},
+		{context.Canceled, false},
+		{ErrQuotaExceeded, false},
+		{&ObjectChangedError{OldETag: "a", NewETag: "b"}, false},
+		{errors.Wrap(notFound, "S3 GetObject error"), false},
+		{errors.Wrap(serverError, "S3 GetObject error"), true},
+		{errors.Wrap(ErrThrottled, "S3 GetObject error"), true},
+		{io.ErrUnexpectedEOF, true},
+		{ErrContentLengthMismatch, true},
+		{&smithyhttp.RequestSendError{Err: syscall.ECONNREFUSED}, true},
+	} {
+		if retryable := IsRetryable(tc.err); retryable != tc.retryable {
+			t.Fatalf("Expected IsRetryable(%v) to be %v", tc.err, tc.retryable)
+		}
+	}
+}
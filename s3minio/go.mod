@@ -0,0 +1,15 @@
+module github.com/markandrus/s3readerat/s3minio
+
+go 1.16
+
+require (
+	github.com/aws/aws-sdk-go-v2 v1.15.0
+	github.com/aws/aws-sdk-go-v2/service/s3 v1.26.0
+	github.com/aws/smithy-go v1.11.1
+	github.com/markandrus/s3readerat v0.0.0-00010101000000-000000000000
+	github.com/minio/minio-go/v7 v7.0.18
+	github.com/pkg/errors v0.9.1
+	golang.org/x/net v0.0.0-20220127200216-cd36cc0744dd // indirect
+)
+
+replace github.com/markandrus/s3readerat => ../
@@ -0,0 +1,204 @@
+// Package s3minio adapts a client from minio-go to s3readerat.API, so that users of self-hosted object stores who
+// prefer minio-go's endpoint and credential handling can read objects through an S3ReaderAt:
+//
+//	client, err := minio.New("play.min.io", &minio.Options{Creds: creds, Secure: true, Region: "us-east-1"})
+//	ra, err := s3readerat.NewWithOptions(s3readerat.Options{API: s3minio.New(client), Bucket: bucket, Key: key})
+//
+// Like Options.Client, the adapter runs in single-region mode. Retries are left to the minio-go client. minio-go does
+// not support GetObjectAttributes or HeadObject requests for a single part, so the parts of multipart-uploaded
+// objects cannot be listed: for such objects, PartsChecksums fails with ErrNotSupported, ReadIntoFile uses tuned parts
+// and Diff samples rather than comparing part checksums.
+package s3minio
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+	"github.com/minio/minio-go/v7"
+	"github.com/pkg/errors"
+)
+
+// ErrNotSupported is returned for requests that minio-go cannot make.
+var ErrNotSupported = errors.New("not supported by minio-go")
+
+// Client implements s3readerat.API by translating each call to the equivalent call of a minio-go client. The optFns
+// of each call are ignored, since they configure aws-sdk-go-v2 clients. It is safe for concurrent use.
+type Client struct {
+	core minio.Core
+}
+
+// New returns a Client that makes its requests with client.
+func New(client *minio.Client) *Client {
+	return &Client{core: minio.Core{Client: client}}
+}
+
+// HeadObject calls StatObject. Requests for a single part fail with ErrNotSupported.
+func (c *Client) HeadObject(ctx context.Context, input *s3.HeadObjectInput, _ ...func(*s3.Options)) (
+	*s3.HeadObjectOutput, error) {
+	if input.PartNumber != 0 {
+		return nil, errors.Wrap(ErrNotSupported, "HeadObject with PartNumber")
+	}
+
+	opts := getObjectOptions(input.VersionId, input.Range, input.IfMatch, input.IfNoneMatch, input.IfModifiedSince,
+		input.IfUnmodifiedSince, input.RequestPayer, input.ExpectedBucketOwner)
+	info, err := c.core.StatObject(ctx, stringValue(input.Bucket), stringValue(input.Key), opts)
+	if err != nil {
+		return nil, convertError(ctx, err)
+	}
+
+	output := &s3.HeadObjectOutput{
+		ContentLength: info.Size,
+		DeleteMarker:  info.IsDeleteMarker,
+		ETag:          quoteETag(info.ETag),
+		Metadata:      info.UserMetadata,
+		StorageClass:  types.StorageClass(info.StorageClass),
+	}
+	if info.ContentType != "" {
+		output.ContentType = &info.ContentType
+	}
+	if !info.LastModified.IsZero() {
+		output.LastModified = &info.LastModified
+	}
+	if info.VersionID != "" {
+		output.VersionId = &info.VersionID
+	}
+
+	return output, nil
+}
+
+// GetObject calls Core.GetObject. The caller must close the returned Body.
+func (c *Client) GetObject(ctx context.Context, input *s3.GetObjectInput, _ ...func(*s3.Options)) (
+	*s3.GetObjectOutput, error) {
+	opts := getObjectOptions(input.VersionId, input.Range, input.IfMatch, input.IfNoneMatch, input.IfModifiedSince,
+		input.IfUnmodifiedSince, input.RequestPayer, input.ExpectedBucketOwner)
+	opts.PartNumber = int(input.PartNumber)
+
+	body, info, header, err := c.core.GetObject(ctx, stringValue(input.Bucket), stringValue(input.Key), opts)
+	if err != nil {
+		return nil, convertError(ctx, err)
+	}
+
+	output := &s3.GetObjectOutput{
+		Body:          body,
+		ContentLength: info.Size,
+		ContentRange:  headerValue(header, "Content-Range"),
+		ContentType:   headerValue(header, "Content-Type"),
+		ETag:          quoteETag(info.ETag),
+		Metadata:      info.UserMetadata,
+		StorageClass:  types.StorageClass(info.StorageClass),
+		VersionId:     headerValue(header, "X-Amz-Version-Id"),
+	}
+	if count, err := strconv.ParseInt(header.Get("X-Amz-Mp-Parts-Count"), 10, 32); err == nil {
+		output.PartsCount = int32(count)
+	}
+	if !info.LastModified.IsZero() {
+		output.LastModified = &info.LastModified
+	}
+
+	return output, nil
+}
+
+// GetObjectAttributes fails with ErrNotSupported.
+func (c *Client) GetObjectAttributes(context.Context, *s3.GetObjectAttributesInput, ...func(*s3.Options)) (
+	*s3.GetObjectAttributesOutput, error) {
+	return nil, errors.Wrap(ErrNotSupported, "GetObjectAttributes")
+}
+
+// getObjectOptions returns the minio-go options for a GetObject or HeadObject request.
+func getObjectOptions(versionID *string, rng *string, ifMatch *string, ifNoneMatch *string,
+	ifModifiedSince *time.Time, ifUnmodifiedSince *time.Time, requestPayer types.RequestPayer,
+	expectedBucketOwner *string) minio.GetObjectOptions {
+	var opts minio.GetObjectOptions
+	if versionID != nil {
+		opts.VersionID = *versionID
+	}
+	if rng != nil {
+		opts.Set("Range", *rng)
+	}
+	if ifMatch != nil {
+		opts.Set("If-Match", *ifMatch)
+	}
+	if ifNoneMatch != nil {
+		opts.Set("If-None-Match", *ifNoneMatch)
+	}
+	if ifModifiedSince != nil {
+		opts.Set("If-Modified-Since", ifModifiedSince.UTC().Format(http.TimeFormat))
+	}
+	if ifUnmodifiedSince != nil {
+		opts.Set("If-Unmodified-Since", ifUnmodifiedSince.UTC().Format(http.TimeFormat))
+	}
+	if requestPayer != "" {
+		opts.Set("X-Amz-Request-Payer", string(requestPayer))
+	}
+	if expectedBucketOwner != nil {
+		opts.Set("X-Amz-Expected-Bucket-Owner", *expectedBucketOwner)
+	}
+	return opts
+}
+
+// convertError converts an error from a minio-go client to the form aws-sdk-go-v2 would have returned, so that the
+// S3ReaderAt can tell responses from S3 apart from failures to reach it.
+func convertError(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		return errors.WithMessage(ctx.Err(), err.Error())
+	}
+
+	var response minio.ErrorResponse
+	if errors.As(err, &response) && response.StatusCode != 0 {
+		header := http.Header{}
+		if response.Region != "" {
+			header.Set("X-Amz-Bucket-Region", response.Region)
+		}
+		return &awshttp.ResponseError{
+			ResponseError: &smithyhttp.ResponseError{
+				Response: &smithyhttp.Response{Response: &http.Response{
+					StatusCode: response.StatusCode,
+					Header:     header,
+				}},
+				Err: &smithy.GenericAPIError{Code: response.Code, Message: response.Message},
+			},
+			RequestID: response.RequestID,
+		}
+	}
+
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return &smithyhttp.RequestSendError{Err: err}
+	}
+
+	return err
+}
+
+// quoteETag restores the quotes that minio-go strips from ETags, since S3 and aws-sdk-go-v2 include them.
+func quoteETag(etag string) *string {
+	if etag == "" {
+		return nil
+	}
+	quoted := `"` + etag + `"`
+	return &quoted
+}
+
+// headerValue returns a pointer to the value of the named header, or nil if it is missing.
+func headerValue(header http.Header, name string) *string {
+	value := header.Get(name)
+	if value == "" {
+		return nil
+	}
+	return &value
+}
+
+// stringValue returns the value of a string pointer, or "" if it is nil.
+func stringValue(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
@@ -0,0 +1,92 @@
+package s3minio
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/aws/smithy-go"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/pkg/errors"
+
+	"github.com/markandrus/s3readerat"
+)
+
+// TestClient tests that an S3ReaderAt reads through a minio-go client, that ETags keep their quotes, and that errors
+// from S3 are converted to the form aws-sdk-go-v2 returns.
+func TestClient(t *testing.T) {
+	data := []byte("hello, world")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/bucket/key" {
+			w.Header().Set("Content-Type", "application/xml")
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`<Error><Code>NoSuchKey</Code><Message>missing</Message></Error>`))
+			return
+		}
+		w.Header().Set("ETag", `"abc-2"`)
+		http.ServeContent(w, r, "key", time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC), bytes.NewReader(data))
+	}))
+	defer server.Close()
+
+	endpoint, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatalf("Error calling Parse: %v", err)
+	}
+	minioClient, err := minio.New(endpoint.Host, &minio.Options{
+		Creds:  credentials.NewStaticV4("", "", ""),
+		Region: "us-east-1",
+	})
+	if err != nil {
+		t.Fatalf("Error calling New: %v", err)
+	}
+	client := New(minioClient)
+
+	ra, err := s3readerat.NewWithOptions(s3readerat.Options{API: client, Bucket: "bucket", Key: "key"})
+	if err != nil {
+		t.Fatalf("Error calling NewWithOptions: %v", err)
+	}
+
+	size, err := ra.Size()
+	if err != nil {
+		t.Fatalf("Error calling Size: %v", err)
+	} else if size != int64(len(data)) {
+		t.Fatalf("Expected size %d, got %d", len(data), size)
+	}
+
+	etag, err := ra.ETag()
+	if err != nil {
+		t.Fatalf("Error calling ETag: %v", err)
+	} else if etag != `"abc-2"` {
+		t.Fatalf("Expected ETag %q, got %q", `"abc-2"`, etag)
+	}
+
+	p := make([]byte, 5)
+	if _, err = ra.ReadAt(p, 7); err != nil {
+		t.Fatalf("Error calling ReadAt: %v", err)
+	} else if string(p) != "world" {
+		t.Fatalf("Expected %q, got %q", "world", p)
+	}
+
+	if _, err = ra.PartsChecksums(); !errors.Is(err, ErrNotSupported) {
+		t.Fatalf("Expected ErrNotSupported, got %v", err)
+	}
+
+	missing, err := s3readerat.NewWithOptions(s3readerat.Options{API: client, Bucket: "bucket", Key: "missing"})
+	if err != nil {
+		t.Fatalf("Error calling NewWithOptions: %v", err)
+	}
+	_, err = missing.ReadAt(p, 0)
+	var responseError *smithyhttp.ResponseError
+	if !errors.As(err, &responseError) || responseError.HTTPStatusCode() != http.StatusNotFound {
+		t.Fatalf("Expected a 404 ResponseError, got %v", err)
+	}
+	var apiError smithy.APIError
+	if !errors.As(err, &apiError) || apiError.ErrorCode() != "NoSuchKey" {
+		t.Fatalf("Expected a NoSuchKey APIError, got %v", err)
+	}
+}
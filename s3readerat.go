@@ -6,11 +6,19 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	smithymiddleware "github.com/aws/smithy-go/middleware"
+	"github.com/markandrus/s3readerat/cache"
+	s3retry "github.com/markandrus/s3readerat/retry"
 	"github.com/pkg/errors"
 )
 
@@ -18,19 +26,102 @@ import (
 // New instances must be created with the New() function.
 // It is safe for concurrent use.
 type S3ReaderAt struct {
-	Debug   bool
-	ctx     context.Context
-	client  *s3.Client
-	options *s3.Options
-	bucket  string
-	key     string
-	size    int64
+	// totalBytes is the number of bytes requested so far. It is accessed atomically, so it must stay first for 64-bit
+	// alignment on 32-bit platforms.
+	totalBytes int64
+
+	Debug    bool
+	strict   bool
+	ctx      context.Context
+	clientMu sync.Mutex
+	client   API
+	options  *s3.Options
+	bucket   string
+	key      string
+	payer    types.RequestPayer
+	owner    string
+	settings clientSettings
+	regions  *RegionCache
+
+	// size is the size of the object, or -1 until it is known. It is guarded by sizeMu; see objectSize.
+	sizeMu sync.Mutex
+	size   int64
+
+	// discoverRegion is Options.DiscoverRegion.
+	discoverRegion bool
+
+	// modifyGet and modifyHead are Options.ModifyGetObject and Options.ModifyHeadObject.
+	modifyGet  func(*s3.GetObjectInput)
+	modifyHead func(*s3.HeadObjectInput)
+
+	// regionClients are the s3.Clients made in multi-region mode for regions other than options.Region, and
+	// httpClients the HTTP clients made for them and client, which Close releases. region is the region S3 last
+	// redirected a request to, which later requests are made in directly. They are guarded by clientMu.
+	regionClients map[string]API
+	httpClients   []s3.HTTPClient
+	region        string
+
+	// debugMode overrides Debug once set by UpdateOptions. It is accessed atomically.
+	debugMode int32
+
+	// closed is set by Close. It is accessed atomically.
+	closed int32
+
+	tuneMu      sync.Mutex
+	tuningValue atomic.Value
+
+	snapshot  bool
+	etagMu    sync.Mutex
+	etag      string
+	versionID string
+
+	// noETag is set by ETagContext once the service is found not to return ETags, so that it is not asked again. It is
+	// guarded by etagMu.
+	noETag bool
+
+	partsMu    sync.Mutex
+	partsKnown bool
+	parts      []PartChecksum
+
+	metadataMu sync.Mutex
+	metadata   *ObjectMetadata
+
+	requestLog *requestLog
+	auditLog   *AuditLog
+
+	statsMu   sync.Mutex
+	stats     Stats
+	tagStats  map[Tag]Stats
+	latencies Latencies
+
+	pacer      pacer
+	prefetches prefetches
+	transfers  transferEstimate
+	flights    flights
+	batches    batches
+
+	memoryBlocks memoryBlocks
+	blockStore   cache.Store
+	readAhead    readAhead
+
+	fallback *Fallback
+
+	headMu sync.Mutex
+	head   []byte
+
+	tailMu sync.Mutex
+	tail   []byte
 }
 
 type Options struct {
 	// Debug indicates whether to enable debug logging.
 	Debug bool
 
+	// Strict indicates whether a GetObject response body that is shorter than its Content-Length is an error. Such
+	// responses usually indicate truncation, so in strict mode the request is retried before failing with
+	// ErrContentLengthMismatch. By default, the mismatch is only logged in debug mode.
+	Strict bool
+
 	// Context is the context.Context to use.
 	Context context.Context
 
@@ -42,16 +133,294 @@ type Options struct {
 	// s3.Client for you in the appropriate region(s). You can instead pass s3.Client to run in single-region mode.
 	Options *s3.Options
 
-	// Bucket is the AWS S3 bucket to use.
+	// API is the client to use in single-region mode when it is not an s3.Client, such as an adapter from package s3v1.
+	// Only one of Client, Options and API can be provided.
+	API API
+
+	// UseAccelerate indicates whether the s3.Clients made in multi-region mode should make their requests to the S3
+	// Transfer Acceleration endpoint, bucket.s3-accelerate.amazonaws.com, which routes them over the AWS network from
+	// the nearest edge location. This speeds up random reads from buckets on another continent, at an extra cost per
+	// byte. Acceleration must be enabled on the bucket, whose name cannot contain dots, and it cannot be used with
+	// access points or directory buckets. It requires Options; to accelerate a Client, set UseAccelerate in its
+	// s3.Options instead.
+	UseAccelerate bool
+
+	// UseDualstack indicates whether the s3.Clients made in multi-region mode, including those made to follow
+	// redirects to other regions, should make their requests to S3's dual-stack endpoints, which are reachable over
+	// IPv6 as well as IPv4. It requires Options, as UseAccelerate does.
+	UseDualstack bool
+
+	// UseFIPS indicates whether the s3.Clients made in multi-region mode, including those made to follow redirects to
+	// other regions, should make their requests to S3's FIPS 140-2 validated endpoints, as in AWS GovCloud (US). It
+	// requires Options, as UseAccelerate does, and cannot be combined with UseAccelerate.
+	UseFIPS bool
+
+	// Endpoint, if set, is the URL of an S3-compatible service, such as MinIO, Ceph RGW or LocalStack, to make the
+	// requests of the s3.Clients made in multi-region mode to, including those made to follow redirects, instead of
+	// AWS. It cannot be combined with UseAccelerate, UseDualstack or UseFIPS. It requires Options, as UseAccelerate
+	// does.
+	Endpoint string
+
+	// UsePathStyle indicates whether the s3.Clients made in multi-region mode should name the bucket in the path of
+	// each request, as in https://endpoint/bucket/key, rather than in its host, as many S3-compatible services
+	// require. It requires Options, as UseAccelerate does.
+	UsePathStyle bool
+
+	// Anonymous indicates whether the s3.Clients made in multi-region mode should make their requests unsigned, with
+	// aws.AnonymousCredentials, whatever the credentials of Options. Public buckets, such as those of open datasets,
+	// can then be read without any AWS credentials configured. It requires Options, as UseAccelerate does.
+	Anonymous bool
+
+	// Bucket is the AWS S3 bucket to use. It may instead be the ARN of an access point, such as
+	// arn:aws:s3:us-west-2:123456789012:accesspoint/name, whose region requests are made to, whatever the region of
+	// the client. The ARN of a Multi-Region Access Point, such as arn:aws:s3::123456789012:accesspoint/name.mrap, has
+	// no region: an s3.Client signs its requests with SigV4A, and S3 routes them to the nearest replica of the object.
+	// A Multi-Region Access Point's alias cannot be used, since requests require its ARN. S3 Express One Zone
+	// directory buckets, such as bucket--usw2-az1--x-s3, require github.com/aws/aws-sdk-go-v2/service/s3 v1.44.0 or
+	// later, which routes their requests to their zonal endpoints, and do not support VersionID or RequestPayer.
 	Bucket string
 
 	// Key is the key to use within the AWS S3 bucket. It should not start with a leading slash.
 	Key string
 
+	// VersionID, if set, pins the S3ReaderAt to that version of the object in a versioned bucket: every request names
+	// it, so reads never mix the bytes of different versions, even if the object is overwritten while being read. The
+	// default is the current version. See also Snapshot, which pins whichever version is current when opened.
+	VersionID string
+
+	// RequestPayer, if set to types.RequestPayerRequester, acknowledges that the requester pays for requests, as
+	// requester-pays buckets, such as those of many public datasets, require. Without it, S3 denies every request to
+	// such buckets.
+	RequestPayer types.RequestPayer
+
+	// ExpectedBucketOwner, if set, is the ID of the AWS account that must own the bucket. It is sent with every
+	// request, and S3 denies requests to buckets owned by any other account, which fail with ErrAccessDenied, so that a
+	// misconfigured or recreated bucket name cannot silently serve another account's data.
+	ExpectedBucketOwner string
+
+	// ModifyGetObject, if set, is called with the input of every GetObject request just before it is made, once the
+	// S3ReaderAt has set its fields, so that it can set fields the S3ReaderAt does not, such as ChecksumMode or the
+	// keys of SSE-C encrypted objects. It must not change Bucket, Key, Range, VersionId or IfMatch, and must be safe
+	// for concurrent use. Headers that no input field models can be added with the APIOptions of s3.Options instead.
+	ModifyGetObject func(*s3.GetObjectInput)
+
+	// ModifyHeadObject is like ModifyGetObject, but for HeadObject requests.
+	ModifyHeadObject func(*s3.HeadObjectInput)
+
 	// Size is the size in bytes to use, if known in advance. This is an optimization that avoids calling "HeadObject".
+	// Without it, ReadAt takes the size from its first GetObject response, unless head or tail prefetching is enabled,
+	// but Size, CopyRange and the like still make a HeadObject request if called first. If Size is larger than the
+	// object, reads past the end of the object return io.EOF, and Size is corrected.
 	Size *int64
+
+	// MaxTotalBytes is the maximum number of bytes the S3ReaderAt may request over its lifetime, including retries.
+	// Once exhausted, reads fail with ErrQuotaExceeded. Zero means unlimited.
+	MaxTotalBytes int64
+
+	// MaxConcurrentRequests is the maximum number of requests the S3ReaderAt may have in flight at once. Requests
+	// made by ReadAt, Size and CopyRange take priority over the speculative requests made by PrefetchAt: a prefetch
+	// waits while any other request is waiting, and a prefetch in flight is canceled, and later retried, to make room
+	// for one. Zero means unlimited.
+	MaxConcurrentRequests int
+
+	// ConcurrencyLimit, if set, bounds the requests in flight across every S3ReaderAt that shares it, as
+	// MaxConcurrentRequests does for one, with which it cannot be combined. See NewConcurrencyLimit.
+	ConcurrencyLimit *ConcurrencyLimit
+
+	// MaxPrefetchBytes is the most bytes that calls to PrefetchAt in progress may read into their buffers at once. A
+	// prefetch that would exceed it fails immediately with ErrPrefetchCanceled, without a request. See also
+	// S3ReaderAt.CancelPrefetches. Zero means unlimited.
+	MaxPrefetchBytes int64
+
+	// TargetThroughput is the rate, in bytes per second, at which ReadIntoFile aims to transfer. Rather than adding
+	// connections for as long as they make the transfer faster, it keeps as many as meet the target at the throughput
+	// each one achieves, without exceeding it, so that other workloads get a fair share of the link. A single
+	// connection may still exceed it. Zero means as fast as possible.
+	TargetThroughput int64
+
+	// RetryBudget, if set, limits the rate of retries made by the S3ReaderAt: the retries of the s3.Client, if it has a
+	// Retryer, and those made in strict mode and by ReadIntoFile and CopyRange. Share one retry.Budget between all of
+	// the S3ReaderAts, and the retry.ReaderAts, in a process so that a widespread S3 incident does not multiply traffic
+	// through thousands of readers retrying independently. A request whose retry it refuses fails with an error
+	// matching retry.ErrBudgetExhausted. Retries made within an API other than s3.Client are not counted.
+	RetryBudget *s3retry.Budget
+
+	// Retry, if set, retries reads and HeadObject requests that fail with transient errors, such as 5xx responses,
+	// throttling and connections reset while reading a response body, which the s3.Client's Retryer does not cover.
+	// Reads resume from the last byte received. See RetryPolicy.
+	Retry *RetryPolicy
+
+	// MaxReadDuration bounds the wall-clock time of each read: each call to ReadAt, ReadAtContext, PrefetchAt and
+	// CopyRange, and each part of ReadIntoFile, including all of its retries and resumes. A read that runs out of time
+	// fails with an error matching ErrReadBudgetExhausted, even if no single request timed out. Zero means unlimited.
+	MaxReadDuration time.Duration
+
+	// RequestTimeout bounds each HeadObject and GetObject request, including the reading of its response body, so that
+	// one stuck request cannot hang a read when the context has no deadline. A request that runs out of time fails
+	// with an error matching ErrRequestTimeout, which Retry retries. Zero means unlimited.
+	RequestTimeout time.Duration
+
+	// HedgeDelay, if set, hedges GetObject requests against S3's tail latency: a request that has not returned its
+	// response headers within HedgeDelay is issued a second time, and whichever response arrives first is read, while
+	// the other request is canceled. A delay around the 95th percentile of Latencies().GetObject costs about 5% more
+	// requests. Hedges share the slot of the request they back up under MaxConcurrentRequests. Zero disables hedging.
+	HedgeDelay time.Duration
+
+	// ParallelReadThreshold, if set, splits reads of more than ParallelReadThreshold bytes into parts of
+	// ParallelReadPartSize bytes, which are fetched concurrently, ParallelReadConcurrency at a time, into their place
+	// in the buffer, so that large reads are not limited to the throughput of one connection. Zero disables parallel
+	// reads.
+	ParallelReadThreshold int64
+
+	// ParallelReadPartSize is the size of the parts of a parallel read. The default is 8 MiB.
+	ParallelReadPartSize int64
+
+	// ParallelReadConcurrency is the number of parts of a parallel read fetched at once. The default is 8.
+	ParallelReadConcurrency int
+
+	// DeduplicateReads indicates whether a read whose range lies within that of a read in progress, as when several
+	// decoders read the same footer at once, should wait for it and copy its bytes rather than issue its own request.
+	// Such reads are logged and counted as cache hits.
+	DeduplicateReads bool
+
+	// CoalesceWindow, if set, merges reads that arrive within CoalesceWindow of each other and lie within CoalesceGap
+	// bytes of each other into one request, from whose response each is served, as suits columnar formats that issue
+	// many small reads a few kilobytes apart. Each read waits up to CoalesceWindow for others to join it before its
+	// request is issued, so keep it to a few milliseconds. Reads that join another are logged and counted as cache
+	// hits. It takes precedence over DeduplicateReads. Zero disables coalescing.
+	CoalesceWindow time.Duration
+
+	// CoalesceGap is the most bytes that may separate reads merged by CoalesceWindow. The bytes between them are
+	// fetched and discarded. Zero merges only reads that overlap or are adjacent.
+	CoalesceGap int64
+
+	// MinRequestSize, if set, rounds reads of fewer than MinRequestSize bytes out to the blocks of MinRequestSize
+	// bytes, aligned to multiples of it, that they fall within, and keeps the 8 most recently used blocks, so that the
+	// many tiny reads of archive and format parsers are served from a few larger requests rather than each issuing
+	// its own. Reads served from kept blocks are counted as cache hits. It takes precedence over CoalesceWindow and
+	// DeduplicateReads. Zero disables rounding.
+	MinRequestSize int64
+
+	// BlockStore, if set, keeps the blocks that reads are rounded out to, as for MinRequestSize, in place of the
+	// S3ReaderAt's own memory, so that they are shared with other S3ReaderAts using it and, with a cache.DiskStore,
+	// with later runs of the process. Its block size takes the place of MinRequestSize. Blocks are kept under the
+	// name s3://bucket/key and the object's ETag, as a rangeproxy.Server keeps them, so a new version of the object is
+	// never served from the blocks of an old one.
+	BlockStore cache.Store
+
+	// ReadAhead, if set, detects sequential reads among those rounded out to blocks, as for MinRequestSize or
+	// BlockStore, and fetches up to ReadAhead of the blocks that follow them in the background, as PrefetchAt does,
+	// so that reading the object through an io.SectionReader in small pieces keeps pace with a streaming GetObject
+	// request. The number of blocks read ahead starts at one and doubles with each sequential read, up to ReadAhead;
+	// a read elsewhere resets it. Reads of blocks being read ahead wait for them. Without MinRequestSize or
+	// BlockStore, it has no effect.
+	ReadAhead int
+
+	// MaxReadAttempts bounds the number of requests each read, as for MaxReadDuration, may make: its first request,
+	// the retries of the s3.Client, if it has a Retryer, and those made in strict mode, with Retry, after a prefetch is
+	// preempted and to resume a failed response body. A read that would exceed it fails with an error matching
+	// ErrReadBudgetExhausted that wraps the error of its last attempt. Zero means unlimited.
+	MaxReadAttempts int
+
+	// PrefetchHeadBytes is the number of bytes at the start of the object to fetch in one request the first time a read
+	// falls within them. Later reads within the head are served from memory. This suits formats that begin with magic
+	// numbers or headers, such as media containers and tar. If the size of the object is not known, it is taken from
+	// the same response rather than from a HeadObject request. Zero disables head prefetching.
+	PrefetchHeadBytes int64
+
+	// PrefetchTailBytes is the number of bytes at the end of the object to fetch in one request the first time a read
+	// falls within them. Later reads within the tail are served from memory. This suits formats that are parsed from a
+	// footer backwards, such as Parquet and zip. Zero disables tail prefetching.
+	PrefetchTailBytes int64
+
+	// RequestLog, if set, receives one JSON object per line for each S3 request made, and for each read served from
+	// memory instead, as described by RequestLogEntry. This makes access patterns easy to analyze with standard tools.
+	RequestLog io.Writer
+
+	// AuditLog, if set, records every read, including reads served from memory and those that fail, in a
+	// tamper-evident log. Reads that cannot be recorded fail. Tag reads with WithTags to record who they were made
+	// for.
+	AuditLog *AuditLog
+
+	// Snapshot pins the S3ReaderAt to the version and ETag of the object when it is opened: NewWithOptions issues a
+	// request at once, as with EagerStat, and every later request names the version ID it returned, if the bucket is
+	// versioned, and requires its ETag with If-Match, as every request does once an ETag has been observed. Reads then
+	// return the bytes of that snapshot or fail, with ErrObjectChanged if the object was overwritten in an unversioned
+	// bucket, even before the first read, which suits reproducible pipelines. See also S3ReaderAt.VersionID.
+	Snapshot bool
+
+	// EagerStat indicates whether NewWithOptions should check that the object exists and is readable, returning any
+	// error, rather than deferring the first request to the first call to Size or ReadAt. The check is made even if
+	// Size is provided, so misconfigured buckets, keys and credentials fail at open.
+	EagerStat bool
+
+	// Fallback, if set, is a local copy of the object from which ReadAt, PrefetchAt and Size are served when S3 cannot
+	// be reached, rather than failing. Errors returned by S3, such as access being denied, still fail. Since the local
+	// copy may be stale, each read it serves is reported to Fallback.OnStale.
+	Fallback *Fallback
+
+	// RegionCache, if set, remembers the regions of buckets for the S3ReaderAt in multi-region mode, in place of
+	// DefaultRegionCache, which every S3ReaderAt and Factory shares by default. Once a request for a bucket has been
+	// redirected to its region, later requests for it are made there directly.
+	RegionCache *RegionCache
+
+	// DiscoverRegion indicates whether, in multi-region mode, a request that is redirected without the bucket's region
+	// in the x-amz-bucket-region header, as by some S3-compatible services, should look the region up with HeadBucket
+	// and GetBucketLocation requests, rather than fail with the redirect.
+	DiscoverRegion bool
+
+	// Profile supplies defaults tuned for an access pattern, such as ProfileParquet. Fields set explicitly in Options
+	// take precedence over the profile's.
+	Profile *Profile
+}
+
+var (
+	// ErrContentLengthMismatch is returned in strict mode, or with Options.Retry, when a GetObject response body is
+	// shorter than its Content-Length.
+	ErrContentLengthMismatch = errors.New("S3 GetObject response body does not match its Content-Length")
+
+	// ErrInvalidOffset is returned by ReadAt when the offset is negative.
+	ErrInvalidOffset = errors.New("invalid offset")
+
+	// ErrInvalidLength is returned by ReadAt when the end of the requested range cannot be represented as an int64.
+	ErrInvalidLength = errors.New("invalid length")
+
+	// ErrQuotaExceeded is returned when a read would exceed Options.MaxTotalBytes.
+	ErrQuotaExceeded = errors.New("download quota exceeded")
+
+	// ErrObjectChanged is returned when the object is overwritten while being read: once its ETag has been observed,
+	// every request requires it with If-Match, so S3 refuses to serve bytes of a newer object, and a response whose
+	// ETag differs anyway, from a service that ignores If-Match, is rejected. Use errors.As with *ObjectChangedError
+	// to get the ETags.
+	ErrObjectChanged = errors.New("S3 object changed")
+)
+
+// ObjectChangedError is returned when a response's ETag differs from the one first observed. It matches
+// ErrObjectChanged with errors.Is. Callers will usually want to discard what they have read and open the object again.
+type ObjectChangedError struct {
+	// OldETag is the ETag first observed.
+	OldETag string
+
+	// NewETag is the ETag of the response that differed. It is empty if S3 refused a request pinned to OldETag with
+	// If-Match, without saying what the ETag had become.
+	NewETag string
 }
 
+func (e *ObjectChangedError) Error() string {
+	if e.NewETag == "" {
+		return fmt.Sprintf("%v: ETag was %s, but no longer matches", ErrObjectChanged, e.OldETag)
+	}
+	return fmt.Sprintf("%v: ETag was %s, but is now %s", ErrObjectChanged, e.OldETag, e.NewETag)
+}
+
+// Is reports whether target is ErrObjectChanged.
+func (e *ObjectChangedError) Is(target error) bool {
+	return target == ErrObjectChanged
+}
+
+// strictAttempts is the number of times a range is requested in strict mode before giving up.
+const strictAttempts = 3
+
 var _ io.ReaderAt = (*S3ReaderAt)(nil)
 
 // New creates a new S3ReaderAt.
@@ -76,14 +445,30 @@ func NewWithSize(client *s3.Client, bucket string, key string, size int64) (*S3R
 }
 
 func NewWithOptions(options Options) (*S3ReaderAt, error) {
-	if options.Client == nil && options.Options == nil {
-		return nil, errors.New("one of Client or Options is required")
+	if options.Profile != nil {
+		options.Profile.apply(&options)
 	}
 
-	if options.Client != nil && options.Options != nil {
-		return nil, errors.New("only one of Client or Options can be provided")
+	if options.Client == nil && options.Options == nil && options.API == nil {
+		return nil, errors.New("one of Client, Options or API is required")
+	}
+
+	if (options.Client != nil && options.Options != nil) || (options.API != nil &&
+		(options.Client != nil || options.Options != nil)) {
+		return nil, errors.New("only one of Client, Options or API can be provided")
 	} else if options.Size != nil && *options.Size < 0 {
 		return nil, errors.Errorf("provided size is invalid: %d", *options.Size)
+	} else if err := options.tunables().validate(); err != nil {
+		return nil, err
+	} else if err := checkDirectoryBucket(options); err != nil {
+		return nil, err
+	} else if err := options.clientSettings().validate(options); err != nil {
+		return nil, err
+	} else if isMultiRegionAccessPointAlias(options.Bucket) {
+		return nil, errors.Errorf("provided Bucket %q is a Multi-Region Access Point alias, but its ARN is required",
+			options.Bucket)
+	} else if options.Fallback != nil && (options.Fallback.ReaderAt == nil || options.Fallback.Size < 0) {
+		return nil, errors.New("provided Fallback requires a ReaderAt and a valid Size")
 	}
 
 	ctx := options.Context
@@ -92,12 +477,36 @@ func NewWithOptions(options Options) (*S3ReaderAt, error) {
 	}
 
 	ra := &S3ReaderAt{
-		Debug:   options.Debug,
-		ctx:     ctx,
-		client:  options.Client,
-		options: options.Options,
-		bucket:  options.Bucket,
-		key:     options.Key,
+		Debug:      options.Debug,
+		strict:     options.Strict,
+		snapshot:   options.Snapshot,
+		ctx:        ctx,
+		client:     options.API,
+		options:    options.Options,
+		bucket:     options.Bucket,
+		key:        options.Key,
+		versionID:  options.VersionID,
+		payer:      options.RequestPayer,
+		owner:      options.ExpectedBucketOwner,
+		settings:   options.clientSettings(),
+		regions:    options.regionCache(),
+		modifyGet:  options.ModifyGetObject,
+		modifyHead: options.ModifyHeadObject,
+		auditLog:   options.AuditLog,
+		fallback:   options.Fallback,
+		blockStore: options.BlockStore,
+
+		discoverRegion: options.DiscoverRegion,
+		prefetches:     prefetches{maxBytes: options.MaxPrefetchBytes},
+	}
+	ra.tuningValue.Store(newTuning(options.tunables(), nil))
+
+	if options.Client != nil {
+		ra.client = options.Client
+	}
+
+	if options.RequestLog != nil {
+		ra.requestLog = &requestLog{w: options.RequestLog}
 	}
 
 	if options.Size != nil {
@@ -105,41 +514,175 @@ func NewWithOptions(options Options) (*S3ReaderAt, error) {
 	} else {
 		ra.size = -1
 	}
+
+	if options.EagerStat || options.Snapshot {
+		if _, err := ra.stat(ra.ctx); err != nil {
+			if _, ok := ra.fallbackSize(err); !ok {
+				return nil, err
+			}
+		}
+	}
+
 	return ra, nil
 }
 
+// WithContext replaces the context the S3ReaderAt makes requests with, other than those of ReadAtContext and
+// SizeContext, and returns the S3ReaderAt. It is not safe to call while the S3ReaderAt is in use; to give a read its
+// own deadline or tags, use ReadAtContext or SizeContext instead.
 func (ra *S3ReaderAt) WithContext(ctx context.Context) *S3ReaderAt {
 	ra.ctx = ctx
 	return ra
 }
 
+// Size returns the size of the object, issuing a HeadObject request with the S3ReaderAt's context if it is not yet
+// known.
 func (ra *S3ReaderAt) Size() (int64, error) {
-	if ra.size >= 0 {
-		return ra.size, nil
+	return ra.SizeContext(ra.ctx)
+}
+
+// SizeContext is like Size, but makes its request with ctx rather than the S3ReaderAt's context, as ReadAtContext
+// does. Once the size is known, ctx is not used.
+func (ra *S3ReaderAt) SizeContext(ctx context.Context) (int64, error) {
+	if size := ra.objectSize(); size >= 0 {
+		return size, nil
 	}
 
-	if ra.Debug {
-		log.Printf("Issuing a HeadObject request for S3 object s3://%s/%s", ra.bucket, ra.key)
+	size, err := ra.stat(ctx)
+	if err != nil {
+		if fallbackSize, ok := ra.fallbackSize(err); ok {
+			return fallbackSize, nil
+		}
 	}
 
-	resp, err := ra.headObject(ra.ctx, &s3.HeadObjectInput{
-		Bucket: aws.String(ra.bucket),
-		Key:    aws.String(ra.key),
-	})
+	return size, err
+}
+
+// ETag returns the ETag of the object, issuing a HeadObject request if no response has been received yet. It returns
+// an empty string if the service does not return ETags.
+func (ra *S3ReaderAt) ETag() (string, error) {
+	return ra.ETagContext(ra.ctx)
+}
+
+// ETagContext is like ETag, but makes its request with ctx rather than the S3ReaderAt's context, as SizeContext does.
+// Once the ETag is known, or the HeadObject response is found not to have one, ctx is not used.
+func (ra *S3ReaderAt) ETagContext(ctx context.Context) (string, error) {
+	ra.etagMu.Lock()
+	etag, noETag := ra.etag, ra.noETag
+	ra.etagMu.Unlock()
+
+	if etag != "" || noETag {
+		return etag, nil
+	}
+
+	if _, err := ra.stat(ctx); err != nil {
+		return "", err
+	}
+
+	ra.etagMu.Lock()
+	defer ra.etagMu.Unlock()
+
+	ra.noETag = ra.etag == ""
+	return ra.etag, nil
+}
+
+// stat checks that the object exists and is readable, recording its size. It fetches the head of the object if head
+// prefetching is enabled, and otherwise issues a HeadObject request with ctx, as statHead does. The head is shared by
+// all reads, so it is fetched with the S3ReaderAt's context.
+func (ra *S3ReaderAt) stat(ctx context.Context) (int64, error) {
+	if headBytes := ra.tuning().PrefetchHeadBytes; headBytes > 0 {
+		// Fetching the head of the object also reveals its size, which saves a HeadObject request.
+		err := ra.prefetchHead(headBytes)
+		if size := ra.objectSize(); err == nil && size >= 0 {
+			return size, nil
+		}
+
+		if ra.debug() {
+			log.Printf("Unable to determine size of S3 object s3://%s/%s from its head: %v", ra.bucket, ra.key, err)
+		}
+	}
+
+	resp, err := ra.statHead(ctx)
 	if err != nil {
-		return -1, errors.Wrap(err, "S3 HeadObject failed")
+		return -1, err
 	}
 
 	if resp.ContentLength < 0 {
 		return -1, errors.Errorf("S3 object size is invalid: %d", resp.ContentLength)
 	}
 
-	ra.size = resp.ContentLength
-	if ra.Debug {
-		log.Printf("S3 object s3://%s/%s has size %d", ra.bucket, ra.key, ra.size)
+	ra.setSize(resp.ContentLength)
+	return resp.ContentLength, nil
+}
+
+// statHead issues a HeadObject request with ctx, checking the response's ETag and recording the object's metadata for
+// Metadata. It enables checksum mode, so that the response includes the object's checksums, if it has any. Transient
+// errors are retried as Options.Retry says.
+func (ra *S3ReaderAt) statHead(ctx context.Context) (*s3.HeadObjectOutput, error) {
+	for attempt := 1; ; attempt++ {
+		resp, err := ra.statHeadOnce(ctx)
+		policy := ra.tuning().Retry
+		if err == nil || attempt >= policy.attempts() || !policy.retryable(err) {
+			return resp, err
+		}
+		if err = ra.tuning().RetryBudget.Spend(err); err != nil {
+			return nil, err
+		}
+
+		if ra.debug() {
+			log.Printf("Retrying HeadObject request for S3 object s3://%s/%s after attempt %d: %v", ra.bucket, ra.key,
+				attempt, err)
+		}
+		if err = policy.wait(ctx, attempt); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// statHeadOnce issues a single HeadObject request for statHead.
+func (ra *S3ReaderAt) statHeadOnce(ctx context.Context) (*s3.HeadObjectOutput, error) {
+	if ra.debug() {
+		log.Printf("Issuing a HeadObject request for S3 object s3://%s/%s", ra.bucket, ra.key)
+	}
+
+	if limiter := ra.tuning().limiter; limiter != nil {
+		slot, _, err := limiter.acquire(ctx)
+		if err != nil {
+			return nil, err
+		}
+		defer slot.release()
+	}
+
+	if err := ra.pacer.wait(ctx); err != nil {
+		return nil, err
+	}
+
+	deadline := ra.startRequest(ctx)
+	defer deadline.cancel()
+
+	start := time.Now()
+	resp, err := ra.headObject(deadline.ctx, &s3.HeadObjectInput{
+		Bucket:       aws.String(ra.bucket),
+		Key:          aws.String(ra.key),
+		ChecksumMode: types.ChecksumModeEnabled,
+	})
+	if err != nil {
+		ra.observeSlowDowns(smithymiddleware.Metadata{}, err)
+		ra.recordRequest(RequestLogEntry{Operation: "HeadObject", Tags: tagsFromContext(ctx)}, start, err)
+		return nil, deadline.wrap(errors.Wrap(classifyError(ra.pinError(err)), "S3 HeadObject failed"))
 	}
+	ra.observeSlowDowns(resp.ResultMetadata, nil)
+	status := responseStatus(resp.ResultMetadata, nil)
+	entry := RequestLogEntry{Operation: "HeadObject", Status: status, Tags: tagsFromContext(ctx)}
+	ra.recordRequest(entry, start, nil)
 
-	return ra.size, nil
+	if err = ra.checkETag(aws.ToString(resp.ETag)); err != nil {
+		return nil, err
+	}
+	ra.recordVersion(resp.VersionId)
+
+	ra.recordMetadata(resp)
+
+	return resp, nil
 }
 
 // ReadAt reads len(b) bytes from the remote file starting at byte offset
@@ -148,22 +691,71 @@ func (ra *S3ReaderAt) Size() (int64, error) {
 // error is io.EOF. It is safe for concurrent use.
 func (ra *S3ReaderAt) ReadAt(p []byte, off int64) (int, error) {
 	// fmt.Printf("readat off=%d len=%d\n", off, len(p))
+	return ra.readAt(ra.ctx, p, off)
+}
+
+// PrefetchAt is like ReadAt, but for speculative reads, such as readahead, that no caller is waiting on yet. When
+// Options.MaxConcurrentRequests is set, its requests yield to those of ReadAt; see MaxConcurrentRequests. Under
+// memory pressure, it fails with ErrPrefetchCanceled; see MaxPrefetchBytes and CancelPrefetches. It also fails with
+// ErrPrefetchCanceled, without a request, if the S3ReaderAt's context has a deadline that the requests made so far
+// suggest the prefetch would miss.
+func (ra *S3ReaderAt) PrefetchAt(p []byte, off int64) (int, error) {
+	return ra.prefetchAt(ra.ctx, p, off)
+}
+
+// PrefetchAtContext is like PrefetchAt, but makes its requests with ctx, as ReadAtContext does, and skips prefetches
+// that would miss ctx's deadline.
+func (ra *S3ReaderAt) PrefetchAtContext(ctx context.Context, p []byte, off int64) (int, error) {
+	return ra.prefetchAt(ctx, p, off)
+}
+
+func (ra *S3ReaderAt) readAt(ctx context.Context, p []byte, off int64) (int, error) {
+	if err := ra.checkClosed(); err != nil {
+		_, err = ra.audit(ctx, "ReadAt", off, int64(len(p)), 0, err)
+		return 0, err
+	}
+
+	budgetCtx, finish := ra.startReadBudget(ctx)
+	n, err := ra.readAtS3(budgetCtx, p, off)
+	err = finish(err)
+	if err != nil && err != io.EOF {
+		n, err = ra.readFallback(p, off, err)
+	}
+
+	audited, err := ra.audit(ctx, "ReadAt", off, int64(len(p)), int64(n), err)
+	return int(audited), err
+}
+
+// readAtS3 implements readAt, reading only from S3 or the prefetched head and tail.
+func (ra *S3ReaderAt) readAtS3(ctx context.Context, p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errors.Wrapf(ErrInvalidOffset, "offset %d is negative", off)
+	}
+
 	if len(p) == 0 {
 		return 0, nil
 	}
 
+	if int64(len(p)) > math.MaxInt64-off {
+		return 0, errors.Wrapf(ErrInvalidLength, "reading %d bytes at offset %d overflows", len(p), off)
+	}
+
 	reqFirst := off
 	reqLast := off + int64(len(p)) - 1
 
-	_, err := ra.Size()
-	if err != nil {
-		return 0, err
+	// Unlike Size, this does not fall back to the size of the local copy, since readAt falls back for the whole read.
+	// Without head or tail prefetching, the size is instead taken from the first GetObject response, which saves a
+	// HeadObject request.
+	if t := ra.tuning(); ra.objectSize() < 0 && (t.PrefetchHeadBytes > 0 || t.PrefetchTailBytes > 0) {
+		if _, err := ra.stat(ctx); err != nil {
+			return 0, err
+		}
 	}
 
 	var returnErr error
-	if ra.size != -1 && reqLast > ra.size-1 {
+	if size := ra.objectSize(); size != -1 && reqLast > size-1 {
 		// Clamp down the requested range.
-		reqLast = ra.size - 1
+		reqLast = size - 1
 		returnErr = io.EOF
 
 		if reqLast < reqFirst {
@@ -173,22 +765,290 @@ func (ra *S3ReaderAt) ReadAt(p []byte, off int64) (int, error) {
 		p = p[:reqLast-reqFirst+1]
 	}
 
-	rng := fmt.Sprintf("bytes=%d-%d", reqFirst, reqLast)
+	if ok, err := ra.readFromHead(ctx, p, reqFirst); ok || err != nil {
+		if err == nil {
+			err = returnErr
+		}
+		return len(p), err
+	}
+
+	if ok, err := ra.readFromTail(ctx, p, reqFirst); ok || err != nil {
+		if err == nil {
+			err = returnErr
+		}
+		return len(p), err
+	}
+
+	var n int
+	var err error
+	switch t := ra.tuning(); {
+	case t.ParallelReadThreshold > 0 && int64(len(p)) > t.ParallelReadThreshold:
+		n, err = ra.readRangeParallel(ctx, p, reqFirst, reqLast)
+	case ra.blockSize() > 0 && int64(len(p)) < ra.blockSize():
+		n, err = ra.readRangeBlocks(ctx, p, reqFirst, reqLast)
+	case t.CoalesceWindow > 0:
+		n, err = ra.readRangeCoalesced(ctx, p, reqFirst, reqLast)
+	case t.DeduplicateReads:
+		n, err = ra.readRangeShared(ctx, p, reqFirst, reqLast)
+	default:
+		n, err = ra.readRangeRetrying(ctx, p, reqFirst, reqLast)
+	}
+
+	if err == nil && returnErr != nil {
+		err = returnErr
+	}
+
+	return n, err
+}
+
+// readRangeRetrying reads the inclusive byte range [first, last] into p as readRange does, retrying truncated response
+// bodies in strict mode and transient errors as Options.Retry says. Retries resume from the last byte received.
+func (ra *S3ReaderAt) readRangeRetrying(ctx context.Context, p []byte, first int64, last int64) (int, error) {
+	var n int
+	var err error
+	for attempt := 1; ; attempt++ {
+		var read int
+		read, err = ra.readRange(ctx, p[n:], first+int64(n), last)
+		n += read
+		if err == nil || !ra.retryable(err, attempt) {
+			return n, err
+		}
+		if err = ra.tuning().RetryBudget.Spend(err); err != nil {
+			return n, err
+		}
+
+		if ra.debug() {
+			log.Printf("Retrying GetObject request for S3 object s3://%s/%s after attempt %d: %v", ra.bucket, ra.key,
+				attempt, err)
+		}
+		if err = ra.tuning().Retry.wait(ctx, attempt); err != nil {
+			return n, err
+		}
+	}
+}
+
+// readRange issues a GetObject request for the inclusive byte range [first, last] and reads the response body into p.
+// Prefetches that are preempted are retried once they are granted a slot again.
+func (ra *S3ReaderAt) readRange(ctx context.Context, p []byte, first int64, last int64) (int, error) {
+	for {
+		n, err := ra.readRangeOnce(ctx, p, first, last)
+		if !errors.Is(err, errPreempted) {
+			return n, err
+		}
+
+		if ra.debug() {
+			log.Printf("Retrying preempted prefetch of S3 object s3://%s/%s with range bytes=%d-%d", ra.bucket, ra.key,
+				first, last)
+		}
+	}
+}
+
+// readRangeOnce issues a single GetObject request for the inclusive byte range [first, last] and reads the response
+// body into p. If the size of the object is not yet known, it is taken from the response. If the object turns out to
+// be shorter than its size, because Options.Size was too large, the size is corrected. Either way, a range that
+// extends past the end of the object is read up to the end, returning io.EOF.
+func (ra *S3ReaderAt) readRangeOnce(ctx context.Context, p []byte, first int64, last int64) (int, error) {
+	resp, err := ra.getRange(ctx, first, last)
+	if err != nil {
+		if isRangeNotSatisfiable(err) {
+			// The range starts past the end of the object, if its size is not yet known or too large, which a
+			// HeadObject request can confirm.
+			head, headErr := ra.statHead(ctx)
+			if headErr != nil {
+				return 0, headErr
+			}
+			ra.correctSize(head.ContentLength)
+			if first >= ra.objectSize() {
+				return 0, io.EOF
+			}
+		}
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if ra.objectSize() < 0 {
+		if err = ra.sizeFromResponse(ctx, resp); err != nil {
+			return 0, err
+		}
+	} else if size, err := parseContentRange(aws.ToString(resp.ContentRange)); err == nil {
+		ra.correctSize(size)
+	}
+
+	if size := ra.objectSize(); last > size-1 {
+		if first >= size {
+			return 0, io.EOF
+		}
+		n, err := ra.readBody(resp, p[:size-first])
+		if err == nil {
+			err = io.EOF
+		}
+		return n, err
+	}
+
+	return ra.readBody(resp, p)
+}
+
+// correctSize records size, the size of the object according to S3, if the size is not yet known or is larger, as it
+// is when Options.Size was too large. A larger size is ignored, since the caller may be limiting reads on purpose.
+func (ra *S3ReaderAt) correctSize(size int64) {
+	ra.sizeMu.Lock()
+	defer ra.sizeMu.Unlock()
+
+	if size < 0 || (ra.size >= 0 && size >= ra.size) {
+		return
+	}
+
+	if ra.size >= 0 && ra.debug() {
+		log.Printf("S3 object s3://%s/%s is shorter than its given size %d, so correcting it", ra.bucket, ra.key,
+			ra.size)
+	}
+	ra.size = size
+	if ra.debug() {
+		log.Printf("S3 object s3://%s/%s has size %d", ra.bucket, ra.key, ra.size)
+	}
+}
+
+// objectSize returns the size of the object, or -1 if it is not yet known. The size may be corrected by a concurrent
+// read, so callers should use one result throughout.
+func (ra *S3ReaderAt) objectSize() int64 {
+	ra.sizeMu.Lock()
+	defer ra.sizeMu.Unlock()
+
+	return ra.size
+}
 
-	if ra.Debug {
+// setSize records size as the size of the object, as reported by a HeadObject request or the head of the object.
+func (ra *S3ReaderAt) setSize(size int64) {
+	ra.sizeMu.Lock()
+	ra.size = size
+	ra.sizeMu.Unlock()
+
+	if ra.debug() {
+		log.Printf("S3 object s3://%s/%s has size %d", ra.bucket, ra.key, size)
+	}
+}
+
+// sizeFromResponse records the size of the object from the Content-Range header of resp, a response to a range
+// request. Some S3-compatible services omit it, in which case a HeadObject request is made with ctx instead.
+func (ra *S3ReaderAt) sizeFromResponse(ctx context.Context, resp *s3.GetObjectOutput) error {
+	size, err := parseContentRange(aws.ToString(resp.ContentRange))
+	if err != nil {
+		if ra.debug() {
+			log.Printf("Unable to determine size of S3 object s3://%s/%s from its response: %v", ra.bucket, ra.key,
+				err)
+		}
+		_, err = ra.stat(ctx)
+		return err
+	}
+
+	ra.correctSize(size)
+	return nil
+}
+
+// getRange issues a single GetObject request for the inclusive byte range [first, last]. The caller must close the
+// response body.
+func (ra *S3ReaderAt) getRange(ctx context.Context, first int64, last int64) (*s3.GetObjectOutput, error) {
+	if err := spendAttempt(ctx, nil); err != nil {
+		return nil, err
+	}
+
+	if err := ra.reserveBytes(last - first + 1); err != nil {
+		return nil, err
+	}
+
+	rng := fmt.Sprintf("bytes=%d-%d", first, last)
+
+	if ra.debug() {
 		log.Printf("Issuing a GetObject request for S3 object s3://%s/%s with range %s", ra.bucket, ra.key, rng)
 	}
 
-	resp, err := ra.getObject(ra.ctx, &s3.GetObjectInput{
+	var slot *limiterSlot
+	if limiter := ra.tuning().limiter; limiter != nil {
+		var err error
+		if slot, ctx, err = limiter.acquire(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := ra.pacer.wait(ctx); err != nil {
+		if slot != nil {
+			slot.release()
+			if slot.wasPreempted() {
+				return nil, errPreempted
+			}
+		}
+		return nil, err
+	}
+
+	// The deadline of Options.RequestTimeout, if set, covers the reading of the body as well, so it is released when
+	// the body is closed.
+	deadline := ra.startRequest(ctx)
+	start := time.Now()
+	resp, err := ra.hedgedGetObject(deadline.ctx, &s3.GetObjectInput{
 		Bucket: aws.String(ra.bucket),
 		Key:    aws.String(ra.key),
 		Range:  aws.String(rng),
 	})
 	if err != nil {
-		return 0, errors.Wrap(err, "S3 GetObject error")
+		deadline.cancel()
+		ra.observeSlowDowns(smithymiddleware.Metadata{}, err)
+		ra.recordRequest(RequestLogEntry{Operation: "GetObject", Range: rng, Tags: tagsFromContext(ctx)}, start, err)
+		if slot != nil {
+			slot.release()
+			if slot.wasPreempted() {
+				return nil, errPreempted
+			}
+		}
+		return nil, deadline.wrap(errors.Wrap(classifyError(ra.pinError(err)), "S3 GetObject error"))
 	}
-	defer resp.Body.Close()
 
+	ra.observeSlowDowns(resp.ResultMetadata, nil)
+	resp.Body = &deadlineBody{ReadCloser: resp.Body, deadline: deadline}
+	if slot != nil {
+		resp.Body = &limitedBody{ReadCloser: resp.Body, slot: slot}
+	}
+
+	entry := RequestLogEntry{
+		Operation: "GetObject",
+		Range:     rng,
+		Status:    responseStatus(resp.ResultMetadata, nil),
+		Tags:      tagsFromContext(ctx),
+	}
+	resp.Body = &recordedBody{ReadCloser: resp.Body, ra: ra, entry: entry, start: start, ttfb: time.Since(start)}
+
+	if err = ra.checkETag(aws.ToString(resp.ETag)); err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	ra.recordVersion(resp.VersionId)
+
+	return resp, nil
+}
+
+// checkETag records the first ETag observed and returns an *ObjectChangedError if etag differs from it. Responses
+// without an ETag, as returned by some S3-compatible services, are not checked.
+func (ra *S3ReaderAt) checkETag(etag string) error {
+	if etag == "" {
+		return nil
+	}
+
+	ra.etagMu.Lock()
+	defer ra.etagMu.Unlock()
+
+	if ra.etag == "" {
+		ra.etag = etag
+		return nil
+	}
+
+	if etag != ra.etag {
+		return &ObjectChangedError{OldETag: ra.etag, NewETag: etag}
+	}
+
+	return nil
+}
+
+// readBody reads a GetObject response body into p, checking that it matches the response's Content-Length.
+func (ra *S3ReaderAt) readBody(resp *s3.GetObjectOutput, p []byte) (int, error) {
 	n, err := io.ReadFull(resp.Body, p)
 
 	if err == io.ErrUnexpectedEOF {
@@ -196,58 +1056,104 @@ func (ra *S3ReaderAt) ReadAt(p []byte, off int64) (int, error) {
 	}
 
 	if (err == nil || err == io.EOF) && int64(n) != resp.ContentLength {
-		if ra.Debug {
+		if ra.debug() {
 			log.Printf("We read %d bytes, but the content-length was %d\n", n, resp.ContentLength)
 		}
-	}
 
-	if err == nil && returnErr != nil {
-		err = returnErr
+		if ra.strict || ra.tuning().Retry != nil {
+			return n, errors.Wrapf(ErrContentLengthMismatch, "read %d bytes, but the content-length was %d", n,
+				resp.ContentLength)
+		}
 	}
 
 	return n, err
 }
 
-func (ra *S3ReaderAt) s3Client() *s3.Client {
-	if ra.client != nil {
-		return ra.client
+// parseContentRange parses a Content-Range header of the form "bytes first-last/total", returning the total size of
+// the object.
+func parseContentRange(contentRange string) (int64, error) {
+	var first, last, total int64
+	if _, err := fmt.Sscanf(contentRange, "bytes %d-%d/%d", &first, &last, &total); err != nil {
+		return -1, errors.Wrapf(err, "unable to parse Content-Range %q", contentRange)
 	}
 
-	if ra.options == nil {
-		return nil
+	if first < 0 || last < first || total <= last {
+		return -1, errors.Errorf("Content-Range %q is invalid", contentRange)
+	}
+
+	return total, nil
+}
+
+// reserveBytes counts n bytes against the download quota, failing with ErrQuotaExceeded if they do not fit.
+func (ra *S3ReaderAt) reserveBytes(n int64) error {
+	maxTotalBytes := ra.tuning().MaxTotalBytes
+	total := atomic.AddInt64(&ra.totalBytes, n)
+	if maxTotalBytes > 0 && total > maxTotalBytes {
+		atomic.AddInt64(&ra.totalBytes, -n)
+		return errors.Wrapf(ErrQuotaExceeded, "reading %d more bytes would exceed the quota of %d bytes", n,
+			maxTotalBytes)
 	}
 
-	ra.client = s3.New(*ra.options)
+	return nil
+}
+
+func (ra *S3ReaderAt) s3Client() API {
+	ra.clientMu.Lock()
+	defer ra.clientMu.Unlock()
+
+	// Multi-region mode. Concurrent reads may be the first to need a client.
+	if ra.client == nil && ra.options != nil {
+		ra.client = ra.newClient(*ra.options)
+	}
 
 	return ra.client
 }
 
-func (ra *S3ReaderAt) s3ClientInRegion(region string) *s3.Client {
-	// Single-region mode.
-	if ra.options == nil {
+func (ra *S3ReaderAt) s3ClientInRegion(region string) API {
+	ra.clientMu.Lock()
+	defer ra.clientMu.Unlock()
+
+	// Single-region mode, or an access point, whose ARN names its region.
+	if ra.options == nil || isAccessPoint(ra.bucket) {
 		return nil
 	}
 
 	// Multi-region mode. Already have s3.Client.
 	if ra.options.Region == region && ra.client != nil {
 		return ra.client
+	} else if client, ok := ra.regionClients[region]; ok {
+		return client
 	}
 
 	// Multi-region mode. Need a new s3.Client.
-	options := (*ra.options).Copy()
-	options.Region = region
-	return s3.New(options)
+	regionOptions := ra.options.Copy()
+	regionOptions.Region = region
+	client := ra.newClient(regionOptions)
+	if ra.regionClients == nil {
+		ra.regionClients = make(map[string]API)
+	}
+	ra.regionClients[region] = client
+	return client
 }
 
 func (ra *S3ReaderAt) headObject(ctx context.Context, input *s3.HeadObjectInput) (*s3.HeadObjectOutput, error) {
-	client := ra.s3Client()
+	if err := ra.checkClosed(); err != nil {
+		return nil, err
+	}
+	input.VersionId, input.IfMatch = ra.pins()
+	input.RequestPayer = ra.payer
+	input.ExpectedBucketOwner = optionalString(ra.owner)
+	if ra.modifyHead != nil {
+		ra.modifyHead(input)
+	}
+	client := ra.regionalClient()
 
-	resp, originalErr := client.HeadObject(ctx, input)
+	resp, originalErr := client.HeadObject(ctx, input, ra.requestOptions()...)
 	if originalErr == nil {
 		return resp, nil
 	}
 
-	region, err := extractRegionFromError(originalErr)
+	region, err := ra.redirectRegion(ctx, originalErr)
 	if err != nil {
 		return nil, err
 	}
@@ -256,19 +1162,29 @@ func (ra *S3ReaderAt) headObject(ctx context.Context, input *s3.HeadObjectInput)
 	if client == nil {
 		return nil, originalErr
 	}
+	ra.rememberRegion(region)
 
-	return client.HeadObject(ctx, input)
+	return client.HeadObject(ctx, input, ra.requestOptions()...)
 }
 
 func (ra *S3ReaderAt) getObject(ctx context.Context, input *s3.GetObjectInput) (*s3.GetObjectOutput, error) {
-	client := ra.s3Client()
+	if err := ra.checkClosed(); err != nil {
+		return nil, err
+	}
+	input.VersionId, input.IfMatch = ra.pins()
+	input.RequestPayer = ra.payer
+	input.ExpectedBucketOwner = optionalString(ra.owner)
+	if ra.modifyGet != nil {
+		ra.modifyGet(input)
+	}
+	client := ra.regionalClient()
 
-	resp, originalErr := client.GetObject(ctx, input)
+	resp, originalErr := client.GetObject(ctx, input, ra.requestOptions()...)
 	if originalErr == nil {
 		return resp, nil
 	}
 
-	region, err := extractRegionFromError(originalErr)
+	region, err := ra.redirectRegion(ctx, originalErr)
 	if err != nil {
 		return nil, err
 	}
@@ -277,8 +1193,9 @@ func (ra *S3ReaderAt) getObject(ctx context.Context, input *s3.GetObjectInput) (
 	if client == nil {
 		return nil, originalErr
 	}
+	ra.rememberRegion(region)
 
-	return client.GetObject(ctx, input)
+	return client.GetObject(ctx, input, ra.requestOptions()...)
 }
 
 // extractRegionFromError returns the value of the x-amz-bucket-region header included in any 3xx response from S3. If
@@ -302,3 +1219,11 @@ func extractRegionFromError(err error) (string, error) {
 
 	return "", err
 }
+
+// optionalString returns s as an optional input field, which is nil if s is empty.
+func optionalString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return aws.String(s)
+}
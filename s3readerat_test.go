@@ -1,15 +1,21 @@
 package s3readerat
 
 import (
+	"context"
+	"io"
+	"math"
+	"net/http"
 	"os"
+	"sync"
 	"testing"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
 	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
-	"github.com/pkg/errors"
-
 	"github.com/aws/aws-sdk-go-v2/credentials"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
 	"github.com/joho/godotenv"
+	"github.com/pkg/errors"
 )
 
 // TestNewSingleRegionSize tests that, using a single-region S3ReaderAt to access an S3 bucket in another region fails
@@ -122,3 +128,484 @@ func TestNewMultiRegion(t *testing.T) {
 		t.Fatalf("Error calling ReadAt: %v", err)
 	}
 }
+
+// TestStrictRetriesTruncatedBody tests that, in strict mode, a truncated GetObject response body is retried.
+func TestStrictRetriesTruncatedBody(t *testing.T) {
+	f := newFakeS3(t)
+	f.put("bucket", "key", []byte("0123456789"))
+	f.hook = truncateGets(f, strictAttempts-1)
+
+	s3ReaderAt, err := NewWithOptions(Options{Client: f.client(), Bucket: "bucket", Key: "key", Strict: true})
+	if err != nil {
+		t.Fatalf("Error calling NewWithOptions: %v", err)
+	}
+
+	b := make([]byte, 8)
+	n, err := s3ReaderAt.ReadAt(b, 0)
+	if err != nil {
+		t.Fatalf("Error calling ReadAt: %v", err)
+	}
+
+	if n != 8 || string(b) != "01234567" {
+		t.Fatalf("Expected to read %q, got %q", "01234567", b[:n])
+	}
+
+	if count := f.requestCount(http.MethodGet); count != strictAttempts {
+		t.Fatalf("Expected %d GetObject requests, got %d", strictAttempts, count)
+	}
+}
+
+// TestStrictFailsOnPersistentTruncation tests that, in strict mode, a GetObject response body that is always truncated
+// results in ErrContentLengthMismatch, whereas outside of strict mode it results in io.EOF.
+func TestStrictFailsOnPersistentTruncation(t *testing.T) {
+	f := newFakeS3(t)
+	f.put("bucket", "key", []byte("0123456789"))
+	f.hook = truncateGets(f, -1)
+
+	s3ReaderAt, err := NewWithOptions(Options{Client: f.client(), Bucket: "bucket", Key: "key", Strict: true})
+	if err != nil {
+		t.Fatalf("Error calling NewWithOptions: %v", err)
+	}
+
+	b := make([]byte, 8)
+	if _, err = s3ReaderAt.ReadAt(b, 0); !errors.Is(err, ErrContentLengthMismatch) {
+		t.Fatalf("Expected ErrContentLengthMismatch, got %v", err)
+	}
+
+	s3ReaderAt.strict = false
+	if _, err = s3ReaderAt.ReadAt(b, 0); err != io.EOF {
+		t.Fatalf("Expected io.EOF, got %v", err)
+	}
+}
+
+// TestReadAtRejectsInvalidRanges tests that ReadAt rejects negative offsets and overflowing lengths without issuing any
+// requests.
+func TestReadAtRejectsInvalidRanges(t *testing.T) {
+	f := newFakeS3(t)
+	f.put("bucket", "key", []byte("0123456789"))
+
+	s3ReaderAt, err := NewWithSize(f.client(), "bucket", "key", 10)
+	if err != nil {
+		t.Fatalf("Error calling NewWithSize: %v", err)
+	}
+
+	b := make([]byte, 8)
+	if _, err = s3ReaderAt.ReadAt(b, -5); !errors.Is(err, ErrInvalidOffset) {
+		t.Fatalf("Expected ErrInvalidOffset, got %v", err)
+	}
+
+	if _, err = s3ReaderAt.ReadAt(b, math.MaxInt64-4); !errors.Is(err, ErrInvalidLength) {
+		t.Fatalf("Expected ErrInvalidLength, got %v", err)
+	}
+
+	if count := f.requestCount(http.MethodGet); count != 0 {
+		t.Fatalf("Expected no GetObject requests, got %d", count)
+	}
+}
+
+// TestMaxTotalBytes tests that reads fail with ErrQuotaExceeded once Options.MaxTotalBytes is exhausted.
+func TestMaxTotalBytes(t *testing.T) {
+	f := newFakeS3(t)
+	f.put("bucket", "key", []byte("0123456789"))
+
+	size := int64(10)
+	s3ReaderAt, err := NewWithOptions(Options{
+		Client:        f.client(),
+		Bucket:        "bucket",
+		Key:           "key",
+		Size:          &size,
+		MaxTotalBytes: 6,
+	})
+	if err != nil {
+		t.Fatalf("Error calling NewWithOptions: %v", err)
+	}
+
+	b := make([]byte, 4)
+	if _, err = s3ReaderAt.ReadAt(b, 0); err != nil {
+		t.Fatalf("Error calling ReadAt: %v", err)
+	}
+
+	if _, err = s3ReaderAt.ReadAt(b, 4); !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("Expected ErrQuotaExceeded, got %v", err)
+	}
+
+	if _, err = s3ReaderAt.ReadAt(b[:2], 4); err != nil {
+		t.Fatalf("Error calling ReadAt within the remaining quota: %v", err)
+	}
+}
+
+// TestEagerStat tests that EagerStat makes NewWithOptions fail for a missing object, even when the size is provided.
+func TestEagerStat(t *testing.T) {
+	f := newFakeS3(t)
+	f.put("bucket", "key", []byte("0123456789"))
+
+	_, err := NewWithOptions(Options{Client: f.client(), Bucket: "bucket", Key: "key", EagerStat: true})
+	if err != nil {
+		t.Fatalf("Error calling NewWithOptions: %v", err)
+	}
+
+	size := int64(10)
+	_, err = NewWithOptions(Options{
+		Client:    f.client(),
+		Bucket:    "bucket",
+		Key:       "missing",
+		Size:      &size,
+		EagerStat: true,
+	})
+	if err == nil {
+		t.Fatalf("Expected an error calling NewWithOptions for a missing object")
+	}
+}
+
+// TestObjectChanged tests that ReadAt returns an *ObjectChangedError when the object is overwritten between reads.
+func TestObjectChanged(t *testing.T) {
+	f := newFakeS3(t)
+	f.put("bucket", "key", []byte("0123456789"))
+
+	s3ReaderAt, err := New(f.client(), "bucket", "key")
+	if err != nil {
+		t.Fatalf("Error calling New: %v", err)
+	}
+
+	b := make([]byte, 4)
+	if _, err = s3ReaderAt.ReadAt(b, 0); err != nil {
+		t.Fatalf("Error calling ReadAt: %v", err)
+	}
+
+	f.put("bucket", "key", []byte("9876543210"))
+
+	_, err = s3ReaderAt.ReadAt(b, 4)
+	if !errors.Is(err, ErrObjectChanged) {
+		t.Fatalf("Expected ErrObjectChanged, got %v", err)
+	}
+
+	var objectChangedError *ObjectChangedError
+	if !errors.As(err, &objectChangedError) || objectChangedError.OldETag == objectChangedError.NewETag {
+		t.Fatalf("Expected an ObjectChangedError with differing ETags, got %v", err)
+	}
+
+	f.mu.Lock()
+	r := f.requests[len(f.requests)-1]
+	f.mu.Unlock()
+	if r.Header.Get("If-Match") != objectChangedError.OldETag {
+		t.Fatalf("Expected the request to require ETag %s with If-Match, got %q", objectChangedError.OldETag,
+			r.Header.Get("If-Match"))
+	}
+}
+
+// TestSizeContext tests that SizeContext makes its request with the given context, leaving the size unknown if it is
+// canceled, and attributes the request to the context's tags.
+func TestSizeContext(t *testing.T) {
+	f := newFakeS3(t)
+	f.put("bucket", "key", []byte("0123456789"))
+
+	s3ReaderAt, err := NewWithOptions(Options{Client: f.client(), Bucket: "bucket", Key: "key"})
+	if err != nil {
+		t.Fatalf("Error calling NewWithOptions: %v", err)
+	}
+
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err = s3ReaderAt.SizeContext(canceled); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected context.Canceled calling SizeContext with a canceled context, got %v", err)
+	}
+
+	ctx := WithTags(context.Background(), Tag{Key: "tenant", Value: "a"})
+	if size, err := s3ReaderAt.SizeContext(ctx); err != nil || size != 10 {
+		t.Fatalf("Expected size 10, got %d (%v)", size, err)
+	}
+	if s := s3ReaderAt.StatsByTag()[Tag{Key: "tenant", Value: "a"}]; s.HeadObjectRequests != 1 {
+		t.Fatalf("Expected the HeadObject request to be tagged, got %+v", s)
+	}
+	if size, err := s3ReaderAt.SizeContext(canceled); err != nil || size != 10 {
+		t.Fatalf("Expected the known size without a request, got %d (%v)", size, err)
+	}
+}
+
+// TestVersionID tests that Options.VersionID pins every request to the given version, and that a missing version is
+// reported as ErrNotFound.
+func TestVersionID(t *testing.T) {
+	f := newFakeS3(t)
+	f.versioning = true
+	f.put("bucket", "key", []byte("0123456789"))
+	version := f.objects["bucket/key"].versionID
+	f.put("bucket", "key", []byte("abcdefghijklmnop"))
+
+	s3ReaderAt, err := NewWithOptions(Options{Client: f.client(), Bucket: "bucket", Key: "key", VersionID: version})
+	if err != nil {
+		t.Fatalf("Error calling NewWithOptions: %v", err)
+	}
+	if size, err := s3ReaderAt.Size(); err != nil || size != 10 {
+		t.Fatalf("Expected the size of the pinned version, 10, got %d (%v)", size, err)
+	}
+
+	f.put("bucket", "key", []byte("ABCDEFGHIJ"))
+	b := make([]byte, 4)
+	if _, err = s3ReaderAt.ReadAt(b, 2); err != nil || string(b) != "2345" {
+		t.Fatalf("Expected to read %q from the pinned version, got %q (%v)", "2345", b, err)
+	}
+	if s3ReaderAt.VersionID() != version {
+		t.Fatalf("Expected version %q, got %q", version, s3ReaderAt.VersionID())
+	}
+
+	f.mu.Lock()
+	for _, r := range f.requests {
+		if r.URL.Query().Get("versionId") != version {
+			f.mu.Unlock()
+			t.Fatalf("Expected every request to name version %q, got %s", version, r.URL)
+		}
+	}
+	f.mu.Unlock()
+
+	if s3ReaderAt, err = NewWithOptions(Options{Client: f.client(), Bucket: "bucket", Key: "key",
+		VersionID: "missing"}); err != nil {
+		t.Fatalf("Error calling NewWithOptions: %v", err)
+	}
+	if _, err = s3ReaderAt.ReadAt(b, 0); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Expected ErrNotFound reading a missing version, got %v", err)
+	}
+}
+
+// TestRequestPayer tests that Options.RequestPayer is sent with every request, including those listing objects.
+func TestRequestPayer(t *testing.T) {
+	f := newFakeS3(t)
+	f.putMultipart("bucket", "dir/key", []byte("0123456789"), 5, true)
+
+	options := Options{Client: f.client(), Bucket: "bucket", RequestPayer: types.RequestPayerRequester}
+	factory := &Factory{Options: options}
+	objects, err := factory.OpenPrefix(context.Background(), "dir/")
+	if err != nil || len(objects) != 1 {
+		t.Fatalf("Expected to list 1 object, got %d (%v)", len(objects), err)
+	}
+	s3ReaderAt, err := factory.Open("dir/key")
+	if err != nil {
+		t.Fatalf("Error calling Open: %v", err)
+	}
+	if _, err = s3ReaderAt.ReadAt(make([]byte, 4), 0); err != nil {
+		t.Fatalf("Error calling ReadAt: %v", err)
+	}
+	if _, err = s3ReaderAt.Metadata(context.Background()); err != nil {
+		t.Fatalf("Error calling Metadata: %v", err)
+	}
+	if _, err = s3ReaderAt.PartsChecksums(); err != nil {
+		t.Fatalf("Error calling PartsChecksums: %v", err)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.requests) < 4 {
+		t.Fatalf("Expected at least 4 requests, got %d", len(f.requests))
+	}
+	for _, r := range f.requests {
+		if r.Header.Get("X-Amz-Request-Payer") != "requester" {
+			t.Fatalf("Expected every request to acknowledge that the requester pays, got %s %s", r.Method, r.URL)
+		}
+	}
+}
+
+// TestExpectedBucketOwner tests that Options.ExpectedBucketOwner is sent with every request, and that reads from a
+// bucket owned by another account fail with ErrAccessDenied.
+func TestExpectedBucketOwner(t *testing.T) {
+	f := newFakeS3(t)
+	f.owner = "111122223333"
+	f.putMultipart("bucket", "dir/key", []byte("0123456789"), 5, true)
+
+	factory := &Factory{Options: Options{Client: f.client(), Bucket: "bucket", ExpectedBucketOwner: f.owner}}
+	objects, err := factory.OpenPrefix(context.Background(), "dir/")
+	if err != nil || len(objects) != 1 {
+		t.Fatalf("Expected to list 1 object, got %d (%v)", len(objects), err)
+	}
+	s3ReaderAt, err := factory.Open("dir/key")
+	if err != nil {
+		t.Fatalf("Error calling Open: %v", err)
+	}
+	if _, err = s3ReaderAt.ReadAt(make([]byte, 4), 0); err != nil {
+		t.Fatalf("Error calling ReadAt: %v", err)
+	}
+	if _, err = s3ReaderAt.Metadata(context.Background()); err != nil {
+		t.Fatalf("Error calling Metadata: %v", err)
+	}
+	if _, err = s3ReaderAt.PartsChecksums(); err != nil {
+		t.Fatalf("Error calling PartsChecksums: %v", err)
+	}
+
+	f.mu.Lock()
+	if len(f.requests) < 4 {
+		t.Fatalf("Expected at least 4 requests, got %d", len(f.requests))
+	}
+	for _, r := range f.requests {
+		if r.Header.Get("X-Amz-Expected-Bucket-Owner") != f.owner {
+			t.Fatalf("Expected every request to name the expected bucket owner, got %s %s", r.Method, r.URL)
+		}
+	}
+	f.mu.Unlock()
+
+	s3ReaderAt, err = NewWithOptions(Options{Client: f.client(), Bucket: "bucket", Key: "dir/key",
+		ExpectedBucketOwner: "444455556666"})
+	if err != nil {
+		t.Fatalf("Error calling NewWithOptions: %v", err)
+	}
+	if _, err = s3ReaderAt.ReadAt(make([]byte, 4), 0); !errors.Is(err, ErrAccessDenied) {
+		t.Fatalf("Expected ErrAccessDenied reading from a bucket owned by another account, got %v", err)
+	}
+}
+
+// TestModifyObjectInputs tests that Options.ModifyGetObject and Options.ModifyHeadObject are called with the inputs of
+// GetObject and HeadObject requests once the S3ReaderAt has set its fields, and that the fields they set are sent.
+func TestModifyObjectInputs(t *testing.T) {
+	f := newFakeS3(t)
+	f.put("bucket", "key", []byte("0123456789"))
+
+	var ranges []string
+	s3ReaderAt, err := NewWithOptions(Options{
+		Client: f.client(),
+		Bucket: "bucket",
+		Key:    "key",
+		ModifyGetObject: func(input *s3.GetObjectInput) {
+			ranges = append(ranges, aws.ToString(input.Range))
+			input.ChecksumMode = types.ChecksumModeEnabled
+		},
+		ModifyHeadObject: func(input *s3.HeadObjectInput) {
+			input.ChecksumMode = types.ChecksumModeEnabled
+		},
+	})
+	if err != nil {
+		t.Fatalf("Error calling NewWithOptions: %v", err)
+	}
+	if _, err = s3ReaderAt.ReadAt(make([]byte, 4), 2); err != nil {
+		t.Fatalf("Error calling ReadAt: %v", err)
+	}
+	if _, err = s3ReaderAt.Metadata(context.Background()); err != nil {
+		t.Fatalf("Error calling Metadata: %v", err)
+	}
+
+	if len(ranges) != 1 || ranges[0] != "bytes=2-5" {
+		t.Fatalf("Expected ModifyGetObject to be called once with the range set, got %q", ranges)
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.requests) != 2 {
+		t.Fatalf("Expected 2 requests, got %d", len(f.requests))
+	}
+	for _, r := range f.requests {
+		if r.Header.Get("X-Amz-Checksum-Mode") != "ENABLED" {
+			t.Fatalf("Expected every request to enable checksum mode, got %s %s", r.Method, r.URL)
+		}
+	}
+}
+
+// TestSizeFromContentRange tests that, when the size is not known, ReadAt takes it from the Content-Range of its first
+// GetObject response rather than making a HeadObject request, and that reads past the end still return io.EOF.
+func TestSizeFromContentRange(t *testing.T) {
+	f := newFakeS3(t)
+	f.put("bucket", "key", []byte("0123456789"))
+	f.put("bucket", "empty", nil)
+
+	s3ReaderAt, err := NewWithOptions(Options{Client: f.client(), Bucket: "bucket", Key: "key"})
+	if err != nil {
+		t.Fatalf("Error calling NewWithOptions: %v", err)
+	}
+	b := make([]byte, 4)
+	if n, err := s3ReaderAt.ReadAt(b, 8); err != io.EOF || string(b[:n]) != "89" {
+		t.Fatalf("Expected to read %q and io.EOF, got %q (%v)", "89", b[:n], err)
+	}
+	if size, err := s3ReaderAt.Size(); err != nil || size != 10 {
+		t.Fatalf("Expected size 10, got %d (%v)", size, err)
+	}
+	if count := f.requestCount(http.MethodHead); count != 0 {
+		t.Fatalf("Expected no HeadObject requests, got %d", count)
+	}
+
+	for _, key := range []string{"key", "empty"} {
+		s3ReaderAt, err = NewWithOptions(Options{Client: f.client(), Bucket: "bucket", Key: key})
+		if err != nil {
+			t.Fatalf("Error calling NewWithOptions: %v", err)
+		}
+		if n, err := s3ReaderAt.ReadAt(b, 10); err != io.EOF || n != 0 {
+			t.Fatalf("Expected io.EOF reading past the end of %s, got %d bytes (%v)", key, n, err)
+		}
+	}
+}
+
+// TestSizeFromContentRangeConcurrent tests that concurrent reads agree on the size taken from their responses when it
+// is not yet known. Run with -race, it checks that they do not race to record it.
+func TestSizeFromContentRangeConcurrent(t *testing.T) {
+	f := newFakeS3(t)
+	f.put("bucket", "key", []byte("0123456789"))
+
+	s3ReaderAt, err := NewWithOptions(Options{Client: f.client(), Bucket: "bucket", Key: "key"})
+	if err != nil {
+		t.Fatalf("Error calling NewWithOptions: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	for off := int64(0); off < 12; off += 2 {
+		wg.Add(1)
+		go func(off int64) {
+			defer wg.Done()
+			b := make([]byte, 4)
+			n, err := s3ReaderAt.ReadAt(b, off)
+			if off+4 <= 10 && (err != nil || n != 4) {
+				t.Errorf("Expected to read 4 bytes at offset %d, got %d (%v)", off, n, err)
+			} else if off+4 > 10 && err != io.EOF {
+				t.Errorf("Expected io.EOF reading at offset %d, got %d bytes (%v)", off, n, err)
+			}
+		}(off)
+	}
+	wg.Wait()
+
+	if size, err := s3ReaderAt.Size(); err != nil || size != 10 {
+		t.Fatalf("Expected size 10, got %d (%v)", size, err)
+	}
+}
+
+// TestSizeTooLarge tests that, when Options.Size is larger than the object, reads past the end of the object return
+// io.EOF rather than S3's 416 response, and that the size is corrected.
+func TestSizeTooLarge(t *testing.T) {
+	f := newFakeS3(t)
+	f.put("bucket", "key", []byte("0123456789"))
+
+	for off, expected := range map[int64]string{8: "89", 15: ""} {
+		s3ReaderAt, err := NewWithOptions(Options{Client: f.client(), Bucket: "bucket", Key: "key",
+			Size: aws.Int64(20)})
+		if err != nil {
+			t.Fatalf("Error calling NewWithOptions: %v", err)
+		}
+
+		b := make([]byte, 4)
+		if n, err := s3ReaderAt.ReadAt(b, off); err != io.EOF || string(b[:n]) != expected {
+			t.Fatalf("Expected to read %q and io.EOF at offset %d, got %q (%v)", expected, off, b[:n], err)
+		}
+		if size, err := s3ReaderAt.Size(); err != nil || size != 10 {
+			t.Fatalf("Expected the size to be corrected to 10, got %d (%v)", size, err)
+		}
+	}
+}
+
+// TestSizeTooLargeConcurrent tests that concurrent reads past the end of an object smaller than Options.Size agree on
+// the corrected size. Run with -race, it checks that they do not race to correct it.
+func TestSizeTooLargeConcurrent(t *testing.T) {
+	f := newFakeS3(t)
+	f.put("bucket", "key", []byte("0123456789"))
+
+	s3ReaderAt, err := NewWithOptions(Options{Client: f.client(), Bucket: "bucket", Key: "key", Size: aws.Int64(20)})
+	if err != nil {
+		t.Fatalf("Error calling NewWithOptions: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	for _, off := range []int64{8, 12, 15, 18} {
+		wg.Add(1)
+		go func(off int64) {
+			defer wg.Done()
+			if n, err := s3ReaderAt.ReadAt(make([]byte, 4), off); err != io.EOF {
+				t.Errorf("Expected io.EOF reading at offset %d, got %d bytes (%v)", off, n, err)
+			}
+		}(off)
+	}
+	wg.Wait()
+
+	if size, err := s3ReaderAt.Size(); err != nil || size != 10 {
+		t.Fatalf("Expected the size to be corrected to 10, got %d (%v)", size, err)
+	}
+}
@@ -0,0 +1,263 @@
+// Package s3v1 adapts an S3 client from aws-sdk-go, v1 of the AWS SDK for Go, to s3readerat.API, so that codebases
+// still on v1 can read objects through an S3ReaderAt without moving the rest of their AWS stack to aws-sdk-go-v2:
+//
+//	sess := session.Must(session.NewSession())
+//	ra, err := s3readerat.NewWithOptions(s3readerat.Options{API: s3v1.New(s3.New(sess)), Bucket: bucket, Key: key})
+//
+// Like Options.Client, the adapter runs in single-region mode, so the v1 client must be configured for the bucket's
+// region. Retries are left to the v1 client. Since its response metadata is not available to the S3ReaderAt, entries
+// in the request log for successful requests have no status, and SlowDown responses that the v1 client retried are not
+// counted in Stats.
+package s3v1
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	s3v1 "github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+	"github.com/aws/smithy-go"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+	"github.com/pkg/errors"
+)
+
+// Client implements s3readerat.API by translating each call to the equivalent call of a v1 client. The optFns of
+// each call are ignored, since they configure v2 clients. It is safe for concurrent use.
+type Client struct {
+	api s3iface.S3API
+}
+
+// New returns a Client that makes its requests with api, which is usually an *s3.S3 from aws-sdk-go.
+func New(api s3iface.S3API) *Client {
+	return &Client{api: api}
+}
+
+// HeadObject calls HeadObjectWithContext.
+func (c *Client) HeadObject(ctx context.Context, input *s3.HeadObjectInput, _ ...func(*s3.Options)) (
+	*s3.HeadObjectOutput, error) {
+	resp, err := c.api.HeadObjectWithContext(ctx, &s3v1.HeadObjectInput{
+		Bucket:               input.Bucket,
+		ChecksumMode:         stringEnum(string(input.ChecksumMode)),
+		ExpectedBucketOwner:  input.ExpectedBucketOwner,
+		IfMatch:              input.IfMatch,
+		IfModifiedSince:      input.IfModifiedSince,
+		IfNoneMatch:          input.IfNoneMatch,
+		IfUnmodifiedSince:    input.IfUnmodifiedSince,
+		Key:                  input.Key,
+		PartNumber:           int64Ptr(input.PartNumber),
+		Range:                input.Range,
+		RequestPayer:         stringEnum(string(input.RequestPayer)),
+		SSECustomerAlgorithm: input.SSECustomerAlgorithm,
+		SSECustomerKey:       input.SSECustomerKey,
+		SSECustomerKeyMD5:    input.SSECustomerKeyMD5,
+		VersionId:            input.VersionId,
+	})
+	if err != nil {
+		return nil, convertError(ctx, err)
+	}
+
+	return &s3.HeadObjectOutput{
+		AcceptRanges:       resp.AcceptRanges,
+		CacheControl:       resp.CacheControl,
+		ChecksumCRC32:      resp.ChecksumCRC32,
+		ChecksumCRC32C:     resp.ChecksumCRC32C,
+		ChecksumSHA1:       resp.ChecksumSHA1,
+		ChecksumSHA256:     resp.ChecksumSHA256,
+		ContentDisposition: resp.ContentDisposition,
+		ContentEncoding:    resp.ContentEncoding,
+		ContentLanguage:    resp.ContentLanguage,
+		ContentLength:      aws.Int64Value(resp.ContentLength),
+		ContentType:        resp.ContentType,
+		DeleteMarker:       aws.BoolValue(resp.DeleteMarker),
+		ETag:               resp.ETag,
+		LastModified:       resp.LastModified,
+		Metadata:           aws.StringValueMap(resp.Metadata),
+		PartsCount:         int32(aws.Int64Value(resp.PartsCount)),
+		StorageClass:       types.StorageClass(aws.StringValue(resp.StorageClass)),
+		VersionId:          resp.VersionId,
+	}, nil
+}
+
+// GetObject calls GetObjectWithContext. The caller must close the returned Body.
+func (c *Client) GetObject(ctx context.Context, input *s3.GetObjectInput, _ ...func(*s3.Options)) (
+	*s3.GetObjectOutput, error) {
+	resp, err := c.api.GetObjectWithContext(ctx, &s3v1.GetObjectInput{
+		Bucket:                     input.Bucket,
+		ChecksumMode:               stringEnum(string(input.ChecksumMode)),
+		ExpectedBucketOwner:        input.ExpectedBucketOwner,
+		IfMatch:                    input.IfMatch,
+		IfModifiedSince:            input.IfModifiedSince,
+		IfNoneMatch:                input.IfNoneMatch,
+		IfUnmodifiedSince:          input.IfUnmodifiedSince,
+		Key:                        input.Key,
+		PartNumber:                 int64Ptr(input.PartNumber),
+		Range:                      input.Range,
+		RequestPayer:               stringEnum(string(input.RequestPayer)),
+		ResponseCacheControl:       input.ResponseCacheControl,
+		ResponseContentDisposition: input.ResponseContentDisposition,
+		ResponseContentEncoding:    input.ResponseContentEncoding,
+		ResponseContentLanguage:    input.ResponseContentLanguage,
+		ResponseContentType:        input.ResponseContentType,
+		ResponseExpires:            input.ResponseExpires,
+		SSECustomerAlgorithm:       input.SSECustomerAlgorithm,
+		SSECustomerKey:             input.SSECustomerKey,
+		SSECustomerKeyMD5:          input.SSECustomerKeyMD5,
+		VersionId:                  input.VersionId,
+	})
+	if err != nil {
+		return nil, convertError(ctx, err)
+	}
+
+	return &s3.GetObjectOutput{
+		AcceptRanges:       resp.AcceptRanges,
+		Body:               resp.Body,
+		CacheControl:       resp.CacheControl,
+		ChecksumCRC32:      resp.ChecksumCRC32,
+		ChecksumCRC32C:     resp.ChecksumCRC32C,
+		ChecksumSHA1:       resp.ChecksumSHA1,
+		ChecksumSHA256:     resp.ChecksumSHA256,
+		ContentDisposition: resp.ContentDisposition,
+		ContentEncoding:    resp.ContentEncoding,
+		ContentLanguage:    resp.ContentLanguage,
+		ContentLength:      aws.Int64Value(resp.ContentLength),
+		ContentRange:       resp.ContentRange,
+		ContentType:        resp.ContentType,
+		DeleteMarker:       aws.BoolValue(resp.DeleteMarker),
+		ETag:               resp.ETag,
+		LastModified:       resp.LastModified,
+		Metadata:           aws.StringValueMap(resp.Metadata),
+		PartsCount:         int32(aws.Int64Value(resp.PartsCount)),
+		StorageClass:       types.StorageClass(aws.StringValue(resp.StorageClass)),
+		TagCount:           int32(aws.Int64Value(resp.TagCount)),
+		VersionId:          resp.VersionId,
+	}, nil
+}
+
+// GetObjectAttributes calls GetObjectAttributesWithContext.
+func (c *Client) GetObjectAttributes(ctx context.Context, input *s3.GetObjectAttributesInput,
+	_ ...func(*s3.Options)) (*s3.GetObjectAttributesOutput, error) {
+	v1Input := &s3v1.GetObjectAttributesInput{
+		Bucket:               input.Bucket,
+		ExpectedBucketOwner:  input.ExpectedBucketOwner,
+		Key:                  input.Key,
+		MaxParts:             int64Ptr(input.MaxParts),
+		RequestPayer:         stringEnum(string(input.RequestPayer)),
+		SSECustomerAlgorithm: input.SSECustomerAlgorithm,
+		SSECustomerKey:       input.SSECustomerKey,
+		SSECustomerKeyMD5:    input.SSECustomerKeyMD5,
+		VersionId:            input.VersionId,
+	}
+	for _, attribute := range input.ObjectAttributes {
+		v1Input.ObjectAttributes = append(v1Input.ObjectAttributes, aws.String(string(attribute)))
+	}
+	if input.PartNumberMarker != nil {
+		marker, err := strconv.ParseInt(*input.PartNumberMarker, 10, 64)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid PartNumberMarker %q", *input.PartNumberMarker)
+		}
+		v1Input.PartNumberMarker = aws.Int64(marker)
+	}
+
+	resp, err := c.api.GetObjectAttributesWithContext(ctx, v1Input)
+	if err != nil {
+		return nil, convertError(ctx, err)
+	}
+
+	output := &s3.GetObjectAttributesOutput{
+		DeleteMarker: aws.BoolValue(resp.DeleteMarker),
+		ETag:         resp.ETag,
+		LastModified: resp.LastModified,
+		ObjectSize:   aws.Int64Value(resp.ObjectSize),
+		StorageClass: types.StorageClass(aws.StringValue(resp.StorageClass)),
+		VersionId:    resp.VersionId,
+	}
+	if resp.Checksum != nil {
+		output.Checksum = &types.Checksum{
+			ChecksumCRC32:  resp.Checksum.ChecksumCRC32,
+			ChecksumCRC32C: resp.Checksum.ChecksumCRC32C,
+			ChecksumSHA1:   resp.Checksum.ChecksumSHA1,
+			ChecksumSHA256: resp.Checksum.ChecksumSHA256,
+		}
+	}
+	if parts := resp.ObjectParts; parts != nil {
+		output.ObjectParts = &types.GetObjectAttributesParts{
+			IsTruncated:          aws.BoolValue(parts.IsTruncated),
+			MaxParts:             int32(aws.Int64Value(parts.MaxParts)),
+			NextPartNumberMarker: int64String(parts.NextPartNumberMarker),
+			PartNumberMarker:     int64String(parts.PartNumberMarker),
+			TotalPartsCount:      int32(aws.Int64Value(parts.TotalPartsCount)),
+		}
+		for _, part := range parts.Parts {
+			output.ObjectParts.Parts = append(output.ObjectParts.Parts, types.ObjectPart{
+				ChecksumCRC32:  part.ChecksumCRC32,
+				ChecksumCRC32C: part.ChecksumCRC32C,
+				ChecksumSHA1:   part.ChecksumSHA1,
+				ChecksumSHA256: part.ChecksumSHA256,
+				PartNumber:     int32(aws.Int64Value(part.PartNumber)),
+				Size:           aws.Int64Value(part.Size),
+			})
+		}
+	}
+
+	return output, nil
+}
+
+// convertError converts an error from a v1 client to the form aws-sdk-go-v2 would have returned, so that the
+// S3ReaderAt can tell responses from S3 apart from failures to reach it.
+func convertError(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		return errors.WithMessage(ctx.Err(), err.Error())
+	}
+
+	var failure awserr.RequestFailure
+	if errors.As(err, &failure) {
+		return &awshttp.ResponseError{
+			ResponseError: &smithyhttp.ResponseError{
+				Response: &smithyhttp.Response{Response: &http.Response{
+					StatusCode: failure.StatusCode(),
+					Header:     http.Header{},
+				}},
+				Err: &smithy.GenericAPIError{Code: failure.Code(), Message: failure.Message()},
+			},
+			RequestID: failure.RequestID(),
+		}
+	}
+
+	var awsErr awserr.Error
+	if errors.As(err, &awsErr) && awsErr.Code() == request.ErrCodeRequestError {
+		return &smithyhttp.RequestSendError{Err: err}
+	}
+
+	return err
+}
+
+// stringEnum returns a pointer to the value of a v2 enum, or nil if it is empty, which v2 clients treat as unset.
+func stringEnum(value string) *string {
+	if value == "" {
+		return nil
+	}
+	return aws.String(value)
+}
+
+// int64Ptr returns a pointer to v, or nil if it is zero, which v2 clients treat as unset.
+func int64Ptr(v int32) *int64 {
+	if v == 0 {
+		return nil
+	}
+	return aws.Int64(int64(v))
+}
+
+// int64String formats a v1 part number marker as v2 does, or returns nil if it is unset.
+func int64String(v *int64) *string {
+	if v == nil {
+		return nil
+	}
+	return aws.String(strconv.FormatInt(*v, 10))
+}
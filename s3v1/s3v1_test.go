@@ -0,0 +1,79 @@
+package s3v1
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	s3v1 "github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/smithy-go"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+	"github.com/pkg/errors"
+
+	"github.com/markandrus/s3readerat"
+)
+
+// TestClient tests that an S3ReaderAt reads through a v1 client, and that errors from S3 are converted to the form
+// aws-sdk-go-v2 returns.
+func TestClient(t *testing.T) {
+	data := []byte("hello, world")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/bucket/key" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("ETag", `"abc"`)
+		http.ServeContent(w, r, "key", time.Time{}, bytes.NewReader(data))
+	}))
+	defer server.Close()
+
+	sess, err := session.NewSession(&aws.Config{
+		Credentials:      credentials.AnonymousCredentials,
+		Endpoint:         aws.String(server.URL),
+		Region:           aws.String("us-east-1"),
+		S3ForcePathStyle: aws.Bool(true),
+		MaxRetries:       aws.Int(0),
+	})
+	if err != nil {
+		t.Fatalf("Error calling NewSession: %v", err)
+	}
+	client := New(s3v1.New(sess))
+
+	ra, err := s3readerat.NewWithOptions(s3readerat.Options{API: client, Bucket: "bucket", Key: "key"})
+	if err != nil {
+		t.Fatalf("Error calling NewWithOptions: %v", err)
+	}
+
+	size, err := ra.Size()
+	if err != nil {
+		t.Fatalf("Error calling Size: %v", err)
+	} else if size != int64(len(data)) {
+		t.Fatalf("Expected size %d, got %d", len(data), size)
+	}
+
+	p := make([]byte, 5)
+	if _, err = ra.ReadAt(p, 7); err != nil {
+		t.Fatalf("Error calling ReadAt: %v", err)
+	} else if string(p) != "world" {
+		t.Fatalf("Expected %q, got %q", "world", p)
+	}
+
+	missing, err := s3readerat.NewWithOptions(s3readerat.Options{API: client, Bucket: "bucket", Key: "missing"})
+	if err != nil {
+		t.Fatalf("Error calling NewWithOptions: %v", err)
+	}
+	_, err = missing.Size()
+	var responseError *smithyhttp.ResponseError
+	if !errors.As(err, &responseError) || responseError.HTTPStatusCode() != http.StatusNotFound {
+		t.Fatalf("Expected a 404 ResponseError, got %v", err)
+	}
+	var apiError smithy.APIError
+	if !errors.As(err, &apiError) || apiError.ErrorCode() != "NotFound" {
+		t.Fatalf("Expected a NotFound APIError, got %v", err)
+	}
+}
@@ -0,0 +1,94 @@
+package s3readerat
+
+import (
+	"io"
+	"os"
+)
+
+// section is the io.ReadSeekCloser returned by OpenSection.
+type section struct {
+	ra     *S3ReaderAt
+	off    int64
+	sr     *io.SectionReader
+	closed bool
+}
+
+var (
+	_ io.ReadSeekCloser = (*section)(nil)
+	_ io.WriterTo       = (*section)(nil)
+)
+
+// WriteTo writes the whole object to w, as CopyRange(w, 0, -1) does, returning the number of bytes written and the
+// error, if any. The object is streamed with one GetObject request, preceded by a HeadObject request if its size is
+// not yet known.
+//
+// io.Copy cannot use WriteTo, since the S3ReaderAt is not an io.Reader: call WriteTo directly, or have io.Copy copy
+// from Reader or OpenSection, whose readers implement io.WriterTo too.
+func (ra *S3ReaderAt) WriteTo(w io.Writer) (int64, error) {
+	n, err := ra.CopyRange(w, 0, -1)
+	if err == io.EOF {
+		err = nil
+	}
+	return n, err
+}
+
+// Reader returns an io.ReadSeekCloser over the whole object, positioned at the start. It is OpenSection(0, -1).
+func (ra *S3ReaderAt) Reader() (io.ReadSeekCloser, error) {
+	return ra.OpenSection(0, -1)
+}
+
+// OpenSection returns an io.ReadSeekCloser over n bytes of the object starting at off, or the rest of the object if n
+// is negative, positioned at off. Offsets passed to Seek are relative to off, and the section ends early if the object
+// does. The object's size is resolved once, when OpenSection is called, making a HeadObject request if it is not yet
+// known. Reads are served by ReadAt, except that the reader implements io.WriterTo, so io.Copy streams the rest of the
+// section with a single GetObject request, as CopyRange does, rather than a request per buffer. After Close, reads and
+// seeks fail with os.ErrClosed; the S3ReaderAt itself stays open. The section is not safe for concurrent use.
+func (ra *S3ReaderAt) OpenSection(off int64, n int64) (io.ReadSeekCloser, error) {
+	end, err := ra.rangeEnd(off, n)
+	if err != nil {
+		return nil, err
+	}
+
+	return &section{ra: ra, off: off, sr: io.NewSectionReader(ra, off, end-off)}, nil
+}
+
+func (s *section) Read(p []byte) (int, error) {
+	if s.closed {
+		return 0, os.ErrClosed
+	}
+	return s.sr.Read(p)
+}
+
+func (s *section) Seek(offset int64, whence int) (int64, error) {
+	if s.closed {
+		return 0, os.ErrClosed
+	}
+	return s.sr.Seek(offset, whence)
+}
+
+func (s *section) Close() error {
+	if s.closed {
+		return os.ErrClosed
+	}
+	s.closed = true
+	return nil
+}
+
+// WriteTo writes the rest of the section to w with a single GetObject request and advances past the bytes written.
+func (s *section) WriteTo(w io.Writer) (int64, error) {
+	if s.closed {
+		return 0, os.ErrClosed
+	}
+
+	pos, _ := s.sr.Seek(0, io.SeekCurrent)
+	if pos >= s.sr.Size() {
+		return 0, nil
+	}
+
+	n, err := s.ra.CopyRange(w, s.off+pos, s.sr.Size()-pos)
+	_, _ = s.sr.Seek(pos+n, io.SeekStart)
+	if err == io.EOF {
+		err = nil
+	}
+	return n, err
+}
@@ -0,0 +1,118 @@
+package s3readerat
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"testing"
+)
+
+// TestOpenSection tests that OpenSection reads and seeks within its section, which ends early with the object, and
+// that the section fails once closed.
+func TestOpenSection(t *testing.T) {
+	f := newFakeS3(t)
+	f.put("bucket", "key", []byte("0123456789"))
+
+	ra, err := NewWithOptions(Options{Client: f.client(), Bucket: "bucket", Key: "key"})
+	if err != nil {
+		t.Fatalf("Error calling NewWithOptions: %v", err)
+	}
+
+	r, err := ra.OpenSection(2, 5)
+	if err != nil {
+		t.Fatalf("Error calling OpenSection: %v", err)
+	}
+	if b, err := ioutil.ReadAll(r); err != nil || string(b) != "23456" {
+		t.Fatalf("Expected to read %q, got %q (%v)", "23456", b, err)
+	}
+	if pos, err := r.Seek(-2, io.SeekEnd); err != nil || pos != 3 {
+		t.Fatalf("Expected to seek to 3, got %d (%v)", pos, err)
+	}
+	if b, err := ioutil.ReadAll(r); err != nil || string(b) != "56" {
+		t.Fatalf("Expected to read %q, got %q (%v)", "56", b, err)
+	}
+	if err = r.Close(); err != nil {
+		t.Fatalf("Error calling Close: %v", err)
+	}
+	if _, err = r.Read(make([]byte, 1)); err != os.ErrClosed {
+		t.Fatalf("Expected os.ErrClosed reading a closed section, got %v", err)
+	}
+
+	r, err = ra.Reader()
+	if err != nil {
+		t.Fatalf("Error calling Reader: %v", err)
+	}
+	if _, err = r.Seek(8, io.SeekStart); err != nil {
+		t.Fatalf("Error calling Seek: %v", err)
+	}
+	if b, err := ioutil.ReadAll(r); err != nil || string(b) != "89" {
+		t.Fatalf("Expected to read %q, got %q (%v)", "89", b, err)
+	}
+
+	if r, err = ra.OpenSection(8, 5); err != nil {
+		t.Fatalf("Error calling OpenSection: %v", err)
+	}
+	if b, err := ioutil.ReadAll(r); err != nil || string(b) != "89" {
+		t.Fatalf("Expected a section past the end of the object to end with it, got %q (%v)", b, err)
+	}
+	if _, err = ra.OpenSection(-1, 5); err != ErrInvalidOffset {
+		t.Fatalf("Expected ErrInvalidOffset opening a section at a negative offset, got %v", err)
+	}
+}
+
+// TestSectionWriteTo tests that io.Copy streams the rest of a section with a single GetObject request, however small
+// its buffer would be, and that WriteTo advances the section.
+func TestSectionWriteTo(t *testing.T) {
+	f := newFakeS3(t)
+	data := bytes.Repeat([]byte("0123456789"), 10000)
+	f.put("bucket", "key", data)
+
+	ra, err := NewWithOptions(Options{Client: f.client(), Bucket: "bucket", Key: "key"})
+	if err != nil {
+		t.Fatalf("Error calling NewWithOptions: %v", err)
+	}
+
+	r, err := ra.OpenSection(5, 90000)
+	if err != nil {
+		t.Fatalf("Error calling OpenSection: %v", err)
+	}
+	if _, err = r.Seek(10, io.SeekStart); err != nil {
+		t.Fatalf("Error calling Seek: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if n, err := io.Copy(&buf, r); err != nil || n != 89990 {
+		t.Fatalf("Expected to copy 89990 bytes, got %d (%v)", n, err)
+	}
+	if !bytes.Equal(buf.Bytes(), data[15:90005]) {
+		t.Fatalf("Expected to copy the rest of the section")
+	}
+	if count := f.requestCount(http.MethodGet); count != 1 {
+		t.Fatalf("Expected 1 GetObject request, got %d", count)
+	}
+	if n, err := r.Read(make([]byte, 1)); n != 0 || err != io.EOF {
+		t.Fatalf("Expected io.EOF reading after WriteTo, got %d bytes (%v)", n, err)
+	}
+
+	buf.Reset()
+	if n, err := ra.WriteTo(&buf); err != nil || n != int64(len(data)) || !bytes.Equal(buf.Bytes(), data) {
+		t.Fatalf("Expected WriteTo to write the whole object, got %d bytes (%v)", n, err)
+	}
+
+	// Without a known size, WriteTo makes a HeadObject request before its GetObject request.
+	ra, err = NewWithOptions(Options{Client: f.client(), Bucket: "bucket", Key: "key"})
+	if err != nil {
+		t.Fatalf("Error calling NewWithOptions: %v", err)
+	}
+	heads, gets := f.requestCount(http.MethodHead), f.requestCount(http.MethodGet)
+	buf.Reset()
+	if n, err := ra.WriteTo(&buf); err != nil || n != int64(len(data)) {
+		t.Fatalf("Expected WriteTo to write the whole object, got %d bytes (%v)", n, err)
+	}
+	if f.requestCount(http.MethodHead) != heads+1 || f.requestCount(http.MethodGet) != gets+1 {
+		t.Fatalf("Expected 1 HeadObject and 1 GetObject request, got %d and %d",
+			f.requestCount(http.MethodHead)-heads, f.requestCount(http.MethodGet)-gets)
+	}
+}
@@ -0,0 +1,67 @@
+package s3readerat
+
+import (
+	"net/http"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+	"github.com/pkg/errors"
+)
+
+// VersionID returns the version ID of the object given by Options.VersionID or pinned in snapshot mode, or an empty
+// string if neither pins a version, the bucket is not versioned, or no response has been received yet. See
+// Options.Snapshot.
+func (ra *S3ReaderAt) VersionID() string {
+	ra.etagMu.Lock()
+	defer ra.etagMu.Unlock()
+
+	return ra.versionID
+}
+
+// recordVersion records the version ID of the first response in snapshot mode, alongside the ETag recorded by
+// checkETag, so that later requests are pinned to it.
+func (ra *S3ReaderAt) recordVersion(versionID *string) {
+	if !ra.snapshot {
+		return
+	}
+
+	ra.etagMu.Lock()
+	defer ra.etagMu.Unlock()
+
+	if ra.versionID == "" {
+		ra.versionID = aws.ToString(versionID)
+	}
+}
+
+// pins returns the version ID and ETag to include in requests, as the versionId parameter and If-Match header. The
+// version ID is that given by Options.VersionID or pinned in snapshot mode, and the ETag is the first observed, so
+// that S3 refuses to serve a request for an object that has since been overwritten. Either is nil if it is not known
+// or not pinned.
+func (ra *S3ReaderAt) pins() (versionID *string, etag *string) {
+	ra.etagMu.Lock()
+	defer ra.etagMu.Unlock()
+
+	if ra.versionID != "" {
+		versionID = aws.String(ra.versionID)
+	}
+	if ra.etag != "" {
+		etag = aws.String(ra.etag)
+	}
+	return versionID, etag
+}
+
+// pinError converts the 412 response to a request whose If-Match header no longer matches, because the object was
+// overwritten, to an *ObjectChangedError. Other errors are returned unchanged.
+func (ra *S3ReaderAt) pinError(err error) error {
+	var responseError *smithyhttp.ResponseError
+	if !errors.As(err, &responseError) {
+		return err
+	} else if responseError.HTTPStatusCode() != http.StatusPreconditionFailed {
+		return err
+	}
+
+	ra.etagMu.Lock()
+	defer ra.etagMu.Unlock()
+
+	return &ObjectChangedError{OldETag: ra.etag}
+}
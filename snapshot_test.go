@@ -0,0 +1,56 @@
+package s3readerat
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+// TestSnapshot tests that, in snapshot mode, reads return the version of the object that was current at open, and
+// that in an unversioned bucket they fail with ErrObjectChanged once the object is overwritten.
+func TestSnapshot(t *testing.T) {
+	f := newFakeS3(t)
+	f.versioning = true
+	f.put("bucket", "key", []byte("0123456789"))
+	version := f.objects["bucket/key"].versionID
+
+	s3ReaderAt, err := NewWithOptions(Options{Client: f.client(), Bucket: "bucket", Key: "key", Snapshot: true})
+	if err != nil {
+		t.Fatalf("Error calling NewWithOptions: %v", err)
+	}
+	if s3ReaderAt.VersionID() != version {
+		t.Fatalf("Expected version %q, got %q", version, s3ReaderAt.VersionID())
+	}
+
+	f.put("bucket", "key", []byte("abcdefghij"))
+	b := make([]byte, 4)
+	if _, err = s3ReaderAt.ReadAt(b, 2); err != nil {
+		t.Fatalf("Error calling ReadAt: %v", err)
+	}
+	if string(b) != "2345" {
+		t.Fatalf("Expected %q, got %q", "2345", b)
+	}
+
+	f.mu.Lock()
+	r := f.requests[len(f.requests)-1]
+	f.mu.Unlock()
+	if r.URL.Query().Get("versionId") != version || r.Header.Get("If-Match") == "" {
+		t.Fatalf("Expected the request to name version %q and an ETag, got %s with If-Match %q", version, r.URL,
+			r.Header.Get("If-Match"))
+	}
+
+	f.versioning = false
+	f.put("bucket", "key", []byte("0123456789"))
+	if s3ReaderAt, err = NewWithOptions(Options{Client: f.client(), Bucket: "bucket", Key: "key",
+		Snapshot: true}); err != nil {
+		t.Fatalf("Error calling NewWithOptions: %v", err)
+	}
+	if s3ReaderAt.VersionID() != "" {
+		t.Fatalf("Expected no version in an unversioned bucket, got %q", s3ReaderAt.VersionID())
+	}
+
+	f.put("bucket", "key", []byte("abcdefghij"))
+	if _, err = s3ReaderAt.ReadAt(b, 2); !errors.Is(err, ErrObjectChanged) {
+		t.Fatalf("Expected ErrObjectChanged, got %v", err)
+	}
+}
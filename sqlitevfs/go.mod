@@ -0,0 +1,9 @@
+module github.com/markandrus/s3readerat/sqlitevfs
+
+go 1.16
+
+require (
+	github.com/google/go-cmp v0.5.7 // indirect
+	github.com/mattn/go-sqlite3 v1.14.8
+	github.com/psanford/sqlite3vfs v0.0.0-20260519004904-f9180fa2acc9
+)
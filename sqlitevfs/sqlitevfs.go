@@ -0,0 +1,113 @@
+// Package sqlitevfs implements a read-only SQLite VFS that serves database pages from S3 using S3ReaderAt, so that
+// remote databases can be queried without downloading them.
+//
+// The VFS is registered with the github.com/mattn/go-sqlite3 driver under a name of your choosing, and databases are
+// opened by passing that name as the "vfs" parameter:
+//
+//	err := sqlitevfs.Register("s3", func(name string) (sqlitevfs.ReaderAt, error) {
+//		return s3readerat.New(client, bucket, name)
+//	})
+//	db, err := sql.Open("sqlite3", "path/to/db.sqlite?vfs=s3&mode=ro")
+package sqlitevfs
+
+import (
+	"io"
+
+	"github.com/psanford/sqlite3vfs"
+
+	// The VFS is registered with, and used through, the go-sqlite3 driver.
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// ReaderAt is the subset of S3ReaderAt needed to serve a database.
+type ReaderAt interface {
+	io.ReaderAt
+	Size() (int64, error)
+}
+
+// OpenFunc returns the ReaderAt for the database with the given name.
+type OpenFunc func(name string) (ReaderAt, error)
+
+// Register registers a read-only VFS with SQLite under vfsName. Databases opened through it are read with open.
+func Register(vfsName string, open OpenFunc) error {
+	return sqlite3vfs.RegisterVFS(vfsName, &vfs{open: open})
+}
+
+type vfs struct {
+	open OpenFunc
+}
+
+func (v *vfs) Open(name string, flags sqlite3vfs.OpenFlag) (sqlite3vfs.File, sqlite3vfs.OpenFlag, error) {
+	// Only the main database exists; SQLite may still probe for journals and temporary files.
+	if flags&sqlite3vfs.OpenMainDB == 0 {
+		return nil, 0, sqlite3vfs.CantOpenError
+	}
+
+	ra, err := v.open(name)
+	if err != nil {
+		return nil, 0, sqlite3vfs.CantOpenError
+	}
+
+	return &file{ra: ra}, sqlite3vfs.OpenReadOnly, nil
+}
+
+func (v *vfs) Delete(name string, dirSync bool) error {
+	return sqlite3vfs.ReadOnlyError
+}
+
+func (v *vfs) Access(name string, flags sqlite3vfs.AccessFlag) (bool, error) {
+	// Report that no journal or WAL files exist, so SQLite reads the database as-is.
+	return false, nil
+}
+
+func (v *vfs) FullPathname(name string) string {
+	return name
+}
+
+type file struct {
+	ra ReaderAt
+}
+
+func (f *file) Close() error {
+	return nil
+}
+
+func (f *file) ReadAt(p []byte, off int64) (int, error) {
+	return f.ra.ReadAt(p, off)
+}
+
+func (f *file) WriteAt(p []byte, off int64) (int, error) {
+	return 0, sqlite3vfs.ReadOnlyError
+}
+
+func (f *file) Truncate(size int64) error {
+	return sqlite3vfs.ReadOnlyError
+}
+
+func (f *file) Sync(flag sqlite3vfs.SyncType) error {
+	return nil
+}
+
+func (f *file) FileSize() (int64, error) {
+	return f.ra.Size()
+}
+
+func (f *file) Lock(elock sqlite3vfs.LockType) error {
+	return nil
+}
+
+func (f *file) Unlock(elock sqlite3vfs.LockType) error {
+	return nil
+}
+
+func (f *file) CheckReservedLock() (bool, error) {
+	return false, nil
+}
+
+func (f *file) SectorSize() int64 {
+	return 0
+}
+
+func (f *file) DeviceCharacteristics() sqlite3vfs.DeviceCharacteristic {
+	return sqlite3vfs.IocapImmutable
+}
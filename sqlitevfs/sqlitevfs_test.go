@@ -0,0 +1,59 @@
+package sqlitevfs
+
+import (
+	"bytes"
+	"database/sql"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+// bytesReaderAt adapts a bytes.Reader to ReaderAt.
+type bytesReaderAt struct {
+	*bytes.Reader
+}
+
+func (b bytesReaderAt) Size() (int64, error) {
+	return b.Reader.Size(), nil
+}
+
+// TestRegister tests that a database registered with the VFS can be queried.
+func TestRegister(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatalf("Error opening database: %v", err)
+	}
+	if _, err = db.Exec("CREATE TABLE t (name TEXT); INSERT INTO t VALUES ('a'), ('b'), ('c');"); err != nil {
+		t.Fatalf("Error populating database: %v", err)
+	}
+	db.Close()
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Error reading database: %v", err)
+	}
+
+	err = Register("sqlitevfs-test", func(name string) (ReaderAt, error) {
+		return bytesReaderAt{bytes.NewReader(data)}, nil
+	})
+	if err != nil {
+		t.Fatalf("Error calling Register: %v", err)
+	}
+
+	db, err = sql.Open("sqlite3", "remote.db?vfs=sqlitevfs-test&mode=ro")
+	if err != nil {
+		t.Fatalf("Error opening database through the VFS: %v", err)
+	}
+	defer db.Close()
+
+	var count int
+	if err = db.QueryRow("SELECT COUNT(*) FROM t").Scan(&count); err != nil {
+		t.Fatalf("Error querying database through the VFS: %v", err)
+	}
+
+	if count != 3 {
+		t.Fatalf("Expected 3 rows, got %d", count)
+	}
+}
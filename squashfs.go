@@ -0,0 +1,1017 @@
+package s3readerat
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/markandrus/s3readerat/cache"
+	"github.com/pkg/errors"
+)
+
+const (
+	// squashBlockSize is the number of bytes SquashFS fetches at a time. Metadata blocks are at most 8 KiB, so reads
+	// are rounded up to blocks of 1 MiB, a few of which are kept.
+	squashBlockSize = 1 << 20
+
+	// squashBlocks is the number of blocks SquashFS keeps.
+	squashBlocks = 8
+
+	// squashMagic begins every squashfs image.
+	squashMagic = 0x73717368
+
+	// squashSuperblockLen is the length of the superblock at the start of the image.
+	squashSuperblockLen = 96
+
+	// squashMetadataBlockLen is the maximum number of bytes a metadata block decompresses to.
+	squashMetadataBlockLen = 8192
+
+	// squashMetadataUncompressed is set in a metadata block's header if the block is stored uncompressed.
+	squashMetadataUncompressed = 0x8000
+
+	// squashDataUncompressed is set in the size of a data or fragment block if the block is stored uncompressed.
+	squashDataUncompressed = 1 << 24
+
+	// squashNoFragment is the fragment index of files whose tails are not packed into a fragment block.
+	squashNoFragment = 0xffffffff
+
+	// squashNoTable is the start of tables an image omits.
+	squashNoTable = 0xffffffffffffffff
+
+	// squashFragmentsPerBlock is the number of 16-byte fragment table entries in a metadata block.
+	squashFragmentsPerBlock = squashMetadataBlockLen / 16
+
+	// squashMaxDirHeaderCount bounds the entries that follow a directory header, which is at most 256 in valid images.
+	squashMaxDirHeaderCount = 256
+
+	// squashMaxSymlinkLen bounds the target of a symlink.
+	squashMaxSymlinkLen = 4096
+
+	// squashMaxSymlinkHops is the number of symlinks that may be followed in resolving a path, as on Linux.
+	squashMaxSymlinkHops = 40
+
+	// squashMetadataCacheBlocks is the number of decompressed metadata blocks a SquashImage keeps, and
+	// squashFragmentCacheBlocks the number of decompressed fragment blocks.
+	squashMetadataCacheBlocks = 1024
+	squashFragmentCacheBlocks = 8
+)
+
+// Compression algorithms identified in the superblock. Only gzip and zstd are supported.
+const (
+	squashCompressionGzip = 1
+	squashCompressionLZMA = 2
+	squashCompressionLZO  = 3
+	squashCompressionXZ   = 4
+	squashCompressionLZ4  = 5
+	squashCompressionZstd = 6
+)
+
+// Inode types. Directory entries use the basic types; inodes may also use the extended types, which are the basic
+// types plus squashExtendedType.
+const (
+	squashTypeDir = iota + 1
+	squashTypeFile
+	squashTypeSymlink
+	squashTypeBlockDevice
+	squashTypeCharDevice
+	squashTypeFIFO
+	squashTypeSocket
+
+	squashExtendedType = 7
+)
+
+// squashSuperblock is the superblock at the start of a squashfs 4.0 image. All fields are little-endian.
+type squashSuperblock struct {
+	Magic               uint32
+	InodeCount          uint32
+	ModTime             uint32
+	BlockSize           uint32
+	FragmentCount       uint32
+	Compression         uint16
+	BlockLog            uint16
+	Flags               uint16
+	IDCount             uint16
+	VersionMajor        uint16
+	VersionMinor        uint16
+	RootInode           uint64
+	BytesUsed           uint64
+	IDTableStart        uint64
+	XattrTableStart     uint64
+	InodeTableStart     uint64
+	DirectoryTableStart uint64
+	FragmentTableStart  uint64
+	ExportTableStart    uint64
+}
+
+// squashInodeHeader begins every inode.
+type squashInodeHeader struct {
+	Type        uint16
+	Permissions uint16
+	UIDIndex    uint16
+	GIDIndex    uint16
+	ModTime     uint32
+	Number      uint32
+}
+
+// squashDirHeader begins each run of directory entries whose inodes share a metadata block.
+type squashDirHeader struct {
+	Count       uint32
+	Start       uint32
+	InodeNumber uint32
+}
+
+// squashDirEntryHeader precedes the name of each directory entry.
+type squashDirEntryHeader struct {
+	Offset      uint16
+	InodeOffset int16
+	Type        uint16
+	NameSize    uint16
+}
+
+// squashFragment locates a fragment block, which packs the tails of several files.
+type squashFragment struct {
+	Start  uint64
+	Size   uint32
+	Unused uint32
+}
+
+// squashMetadataBlock is a decompressed metadata block, along with the position of the block that follows it.
+type squashMetadataBlock struct {
+	data []byte
+	next int64
+}
+
+// SquashImage gives access to the files in a squashfs image without reading the whole image: the superblock and
+// fragment table are read when the image is opened, inodes and directories as paths are resolved, and each file's
+// blocks as they are read. It implements fs.FS and fs.StatFS, and is safe for concurrent use. Symlinks are followed
+// by Open and Stat, and reported by Lstat and ReadLink.
+//
+// Images compressed with gzip or zstd are supported; mksquashfs uses gzip unless told otherwise.
+type SquashImage struct {
+	r         io.ReaderAt
+	sb        squashSuperblock
+	fragments []squashFragment
+	root      *squashInode
+
+	mu            sync.Mutex
+	metadata      map[int64]squashMetadataBlock
+	fragmentCache map[int64][]byte
+}
+
+// squashInode is a parsed inode. Only the fields for its type are set.
+type squashInode struct {
+	typ     int
+	mode    fs.FileMode
+	modTime time.Time
+
+	// Directories are listed by the dirSize bytes of the directory table at dirRef.
+	dirRef  uint64
+	dirSize int64
+
+	// Regular files are stored in blocks starting at blocksStart, with their tail in a fragment unless fragment is
+	// squashNoFragment.
+	size           int64
+	blocksStart    int64
+	blockSizes     []uint32
+	blockOffsets   []int64
+	fragment       uint32
+	fragmentOffset uint32
+
+	target string
+}
+
+// SquashFS opens the squashfs image described by options and returns it as an fs.FS, so that dataset and root
+// filesystem images can be browsed without mounting or downloading them. Reads are rounded up to blocks of 1 MiB, a
+// few of which are kept, since the metadata squashfs reads at a time is much smaller.
+func SquashFS(options Options) (*SquashImage, error) {
+	ra, err := NewWithOptions(options)
+	if err != nil {
+		return nil, err
+	}
+
+	size, err := ra.Size()
+	if err != nil {
+		return nil, err
+	}
+
+	return OpenSquashImage(cache.New(ra, size, cache.Options{BlockSize: squashBlockSize, Blocks: squashBlocks}), size)
+}
+
+// OpenSquashImage reads the superblock and fragment table of the squashfs image in the first size bytes of r.
+func OpenSquashImage(r io.ReaderAt, size int64) (*SquashImage, error) {
+	if size < squashSuperblockLen {
+		return nil, errors.New("too small to be a squashfs image")
+	}
+
+	b, err := readChunk(r.ReadAt, make([]byte, squashSuperblockLen), 0)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to read squashfs superblock")
+	}
+
+	img := &SquashImage{
+		r:             r,
+		metadata:      make(map[int64]squashMetadataBlock),
+		fragmentCache: make(map[int64][]byte),
+	}
+	if err = binary.Read(bytes.NewReader(b), binary.LittleEndian, &img.sb); err != nil {
+		return nil, err
+	}
+
+	sb := img.sb
+	switch {
+	case sb.Magic != squashMagic:
+		return nil, errors.New("not a squashfs image")
+	case sb.VersionMajor != 4 || sb.VersionMinor != 0:
+		return nil, errors.Errorf("unsupported squashfs version %d.%d", sb.VersionMajor, sb.VersionMinor)
+	case sb.Compression != squashCompressionGzip && sb.Compression != squashCompressionZstd:
+		return nil, errors.Errorf("unsupported squashfs compression %s", squashCompressionName(sb.Compression))
+	case sb.BlockLog < 12 || sb.BlockLog > 20 || sb.BlockSize != 1<<sb.BlockLog:
+		return nil, errors.Errorf("squashfs block size is invalid: %d", sb.BlockSize)
+	case sb.BytesUsed > uint64(size):
+		return nil, errors.Errorf("squashfs image of %d bytes is truncated to %d", sb.BytesUsed, size)
+	}
+
+	if sb.FragmentCount > 0 && sb.FragmentTableStart != squashNoTable {
+		if img.fragments, err = img.readFragmentTable(); err != nil {
+			return nil, errors.Wrap(err, "unable to read squashfs fragment table")
+		}
+	}
+
+	if img.root, err = img.inode(sb.RootInode); err != nil {
+		return nil, errors.Wrap(err, "unable to read squashfs root directory")
+	} else if img.root.typ != squashTypeDir {
+		return nil, errors.New("squashfs root inode is not a directory")
+	}
+
+	return img, nil
+}
+
+func squashCompressionName(id uint16) string {
+	switch id {
+	case squashCompressionGzip:
+		return "gzip"
+	case squashCompressionLZMA:
+		return "lzma"
+	case squashCompressionLZO:
+		return "lzo"
+	case squashCompressionXZ:
+		return "xz"
+	case squashCompressionLZ4:
+		return "lz4"
+	case squashCompressionZstd:
+		return "zstd"
+	default:
+		return "unknown"
+	}
+}
+
+// readFragmentTable reads the fragment table: a list of the positions of the metadata blocks holding its entries.
+func (img *SquashImage) readFragmentTable() ([]squashFragment, error) {
+	count := int(img.sb.FragmentCount)
+	lookup, err := readChunk(img.r.ReadAt, make([]byte, 8*((count+squashFragmentsPerBlock-1)/squashFragmentsPerBlock)),
+		int64(img.sb.FragmentTableStart))
+	if err != nil {
+		return nil, err
+	}
+
+	fragments := make([]squashFragment, count)
+	for i := 0; i < len(lookup)/8; i++ {
+		mr := &squashMetadataReader{img: img, next: int64(binary.LittleEndian.Uint64(lookup[8*i:]))}
+		end := (i + 1) * squashFragmentsPerBlock
+		if end > count {
+			end = count
+		}
+		if err = binary.Read(mr, binary.LittleEndian, fragments[i*squashFragmentsPerBlock:end]); err != nil {
+			return nil, err
+		}
+	}
+
+	return fragments, nil
+}
+
+// decompress decompresses a block that decompresses to at most max bytes.
+func (img *SquashImage) decompress(b []byte, max int) ([]byte, error) {
+	var r io.Reader
+	if img.sb.Compression == squashCompressionZstd {
+		zr, err := zstd.NewReader(bytes.NewReader(b))
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		r = zr
+	} else {
+		zr, err := zlib.NewReader(bytes.NewReader(b))
+		if err != nil {
+			return nil, err
+		}
+		r = zr
+	}
+
+	data, err := ioutil.ReadAll(io.LimitReader(r, int64(max)+1))
+	if err != nil {
+		return nil, err
+	} else if len(data) > max {
+		return nil, errors.Errorf("squashfs block decompresses to more than %d bytes", max)
+	}
+	return data, nil
+}
+
+// metadataBlock returns the metadata block at pos, reading and decompressing it if it is not cached.
+func (img *SquashImage) metadataBlock(pos int64) (squashMetadataBlock, error) {
+	img.mu.Lock()
+	block, ok := img.metadata[pos]
+	img.mu.Unlock()
+	if ok {
+		return block, nil
+	}
+
+	header, err := readChunk(img.r.ReadAt, make([]byte, 2), pos)
+	if err != nil {
+		return block, err
+	}
+	n := int64(binary.LittleEndian.Uint16(header) &^ squashMetadataUncompressed)
+	if n == 0 || n > squashMetadataBlockLen {
+		return block, errors.Errorf("squashfs metadata block at offset %d is invalid", pos)
+	}
+
+	b, err := readChunk(img.r.ReadAt, make([]byte, n), pos+2)
+	if err != nil {
+		return block, err
+	}
+	block = squashMetadataBlock{data: b, next: pos + 2 + n}
+	if binary.LittleEndian.Uint16(header)&squashMetadataUncompressed == 0 {
+		if block.data, err = img.decompress(b, squashMetadataBlockLen); err != nil {
+			return block, errors.Wrapf(err, "unable to decompress squashfs metadata block at offset %d", pos)
+		} else if len(block.data) == 0 {
+			return block, errors.Errorf("squashfs metadata block at offset %d is empty", pos)
+		}
+	}
+
+	img.mu.Lock()
+	if len(img.metadata) >= squashMetadataCacheBlocks {
+		img.metadata = make(map[int64]squashMetadataBlock)
+	}
+	img.metadata[pos] = block
+	img.mu.Unlock()
+
+	return block, nil
+}
+
+// squashMetadataReader reads a stream of metadata that may span several metadata blocks.
+type squashMetadataReader struct {
+	img  *SquashImage
+	data []byte
+	next int64
+}
+
+// metadataAt returns a reader of the metadata at ref in the table starting at start. The upper 48 bits of ref are the
+// position of a metadata block relative to the table, and the lower 16 bits an offset into the decompressed block.
+func (img *SquashImage) metadataAt(start uint64, ref uint64) (*squashMetadataReader, error) {
+	mr := &squashMetadataReader{img: img, next: int64(start + ref>>16)}
+	if _, err := mr.Read(nil); err != nil {
+		return nil, err
+	}
+
+	offset := int(ref & 0xffff)
+	if offset > len(mr.data) {
+		return nil, errors.Errorf("squashfs metadata reference %#x is invalid", ref)
+	}
+	mr.data = mr.data[offset:]
+	return mr, nil
+}
+
+func (mr *squashMetadataReader) Read(p []byte) (int, error) {
+	if len(mr.data) == 0 {
+		block, err := mr.img.metadataBlock(mr.next)
+		if err != nil {
+			return 0, err
+		}
+		mr.data, mr.next = block.data, block.next
+	}
+
+	n := copy(p, mr.data)
+	mr.data = mr.data[n:]
+	return n, nil
+}
+
+// inode reads the inode at ref in the inode table.
+func (img *SquashImage) inode(ref uint64) (*squashInode, error) {
+	mr, err := img.metadataAt(img.sb.InodeTableStart, ref)
+	if err != nil {
+		return nil, err
+	}
+
+	var header squashInodeHeader
+	if err = binary.Read(mr, binary.LittleEndian, &header); err != nil {
+		return nil, err
+	}
+
+	inode := &squashInode{typ: squashBasicType(header.Type), modTime: time.Unix(int64(header.ModTime), 0)}
+	inode.mode = squashMode(inode.typ, header.Permissions)
+
+	switch header.Type {
+	case squashTypeDir:
+		var dir struct {
+			BlockIndex  uint32
+			LinkCount   uint32
+			FileSize    uint16
+			BlockOffset uint16
+			Parent      uint32
+		}
+		err = binary.Read(mr, binary.LittleEndian, &dir)
+		inode.setDir(dir.BlockIndex, dir.BlockOffset, int64(dir.FileSize))
+	case squashTypeDir + squashExtendedType:
+		var dir struct {
+			LinkCount   uint32
+			FileSize    uint32
+			BlockIndex  uint32
+			Parent      uint32
+			IndexCount  uint16
+			BlockOffset uint16
+			XattrIndex  uint32
+		}
+		err = binary.Read(mr, binary.LittleEndian, &dir)
+		inode.setDir(dir.BlockIndex, dir.BlockOffset, int64(dir.FileSize))
+	case squashTypeFile:
+		var file struct {
+			BlocksStart    uint32
+			Fragment       uint32
+			FragmentOffset uint32
+			FileSize       uint32
+		}
+		if err = binary.Read(mr, binary.LittleEndian, &file); err == nil {
+			inode.blocksStart, inode.size = int64(file.BlocksStart), int64(file.FileSize)
+			inode.fragment, inode.fragmentOffset = file.Fragment, file.FragmentOffset
+			err = img.readBlockSizes(mr, inode)
+		}
+	case squashTypeFile + squashExtendedType:
+		var file struct {
+			BlocksStart    uint64
+			FileSize       uint64
+			Sparse         uint64
+			LinkCount      uint32
+			Fragment       uint32
+			FragmentOffset uint32
+			XattrIndex     uint32
+		}
+		if err = binary.Read(mr, binary.LittleEndian, &file); err == nil {
+			inode.blocksStart, inode.size = int64(file.BlocksStart), int64(file.FileSize)
+			inode.fragment, inode.fragmentOffset = file.Fragment, file.FragmentOffset
+			err = img.readBlockSizes(mr, inode)
+		}
+	case squashTypeSymlink, squashTypeSymlink + squashExtendedType:
+		var link struct {
+			LinkCount  uint32
+			TargetSize uint32
+		}
+		if err = binary.Read(mr, binary.LittleEndian, &link); err == nil {
+			if link.TargetSize > squashMaxSymlinkLen {
+				return nil, errors.Errorf("squashfs symlink target of %d bytes is too long", link.TargetSize)
+			}
+			target := make([]byte, link.TargetSize)
+			_, err = io.ReadFull(mr, target)
+			inode.target = string(target)
+		}
+	default:
+		if inode.typ < squashTypeBlockDevice || inode.typ > squashTypeSocket {
+			return nil, errors.Errorf("unknown squashfs inode type %d", header.Type)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return inode, nil
+}
+
+// setDir sets the location of a directory's listing. Directory sizes count 3 bytes more than the listing holds, for
+// the implied "." and ".." entries.
+func (inode *squashInode) setDir(blockIndex uint32, blockOffset uint16, fileSize int64) {
+	inode.dirRef = uint64(blockIndex)<<16 | uint64(blockOffset)
+	if fileSize > 3 {
+		inode.dirSize = fileSize - 3
+	}
+}
+
+// readBlockSizes reads the sizes of a regular file's blocks, which follow its inode, and the positions they imply.
+// Files whose tails are in fragments have a block for each full block; others have one for any partial last block.
+func (img *SquashImage) readBlockSizes(mr io.Reader, inode *squashInode) error {
+	count := inode.size / int64(img.sb.BlockSize)
+	if inode.fragment == squashNoFragment && inode.size%int64(img.sb.BlockSize) != 0 {
+		count++
+	}
+	// Bound the allocation by the most block sizes the image's metadata could decompress to.
+	if inode.size < 0 || count*4 > int64(img.sb.BytesUsed)*squashMetadataBlockLen {
+		return errors.Errorf("squashfs file size is invalid: %d", inode.size)
+	}
+
+	inode.blockSizes = make([]uint32, count)
+	if err := binary.Read(mr, binary.LittleEndian, inode.blockSizes); err != nil {
+		return err
+	}
+
+	inode.blockOffsets = make([]int64, count)
+	pos := inode.blocksStart
+	for i, size := range inode.blockSizes {
+		inode.blockOffsets[i] = pos
+		pos += int64(size &^ squashDataUncompressed)
+	}
+	return nil
+}
+
+// squashBasicType returns the basic type corresponding to an inode type.
+func squashBasicType(typ uint16) int {
+	if typ > squashExtendedType {
+		return int(typ) - squashExtendedType
+	}
+	return int(typ)
+}
+
+// squashMode returns the fs.FileMode of an inode of typ with the given permission bits.
+func squashMode(typ int, permissions uint16) fs.FileMode {
+	mode := fs.FileMode(permissions & 0777)
+	if permissions&04000 != 0 {
+		mode |= fs.ModeSetuid
+	}
+	if permissions&02000 != 0 {
+		mode |= fs.ModeSetgid
+	}
+	if permissions&01000 != 0 {
+		mode |= fs.ModeSticky
+	}
+
+	switch typ {
+	case squashTypeDir:
+		mode |= fs.ModeDir
+	case squashTypeSymlink:
+		mode |= fs.ModeSymlink
+	case squashTypeBlockDevice:
+		mode |= fs.ModeDevice
+	case squashTypeCharDevice:
+		mode |= fs.ModeDevice | fs.ModeCharDevice
+	case squashTypeFIFO:
+		mode |= fs.ModeNamedPipe
+	case squashTypeSocket:
+		mode |= fs.ModeSocket
+	}
+	return mode
+}
+
+// squashDirEntry is an entry in a directory listing.
+type squashDirEntry struct {
+	img  *SquashImage
+	name string
+	typ  int
+	ref  uint64
+}
+
+// readDir reads the listing of a directory, which is sorted by name.
+func (img *SquashImage) readDir(dir *squashInode) ([]*squashDirEntry, error) {
+	if dir.dirSize == 0 {
+		return nil, nil
+	}
+
+	mr, err := img.metadataAt(img.sb.DirectoryTableStart, dir.dirRef)
+	if err != nil {
+		return nil, err
+	}
+	r := io.LimitReader(mr, dir.dirSize)
+
+	var entries []*squashDirEntry
+	for {
+		var header squashDirHeader
+		if err = binary.Read(r, binary.LittleEndian, &header); err == io.EOF {
+			return entries, nil
+		} else if err != nil {
+			return nil, err
+		} else if header.Count >= squashMaxDirHeaderCount {
+			return nil, errors.Errorf("squashfs directory header of %d entries is invalid", header.Count+1)
+		}
+
+		for i := uint32(0); i <= header.Count; i++ {
+			var entryHeader squashDirEntryHeader
+			if err = binary.Read(r, binary.LittleEndian, &entryHeader); err != nil {
+				return nil, err
+			}
+
+			name := make([]byte, int(entryHeader.NameSize)+1)
+			if _, err = io.ReadFull(r, name); err != nil {
+				return nil, err
+			} else if !fs.ValidPath(string(name)) || strings.Contains(string(name), "/") || string(name) == "." {
+				return nil, errors.Errorf("squashfs directory entry %q is invalid", name)
+			}
+
+			entries = append(entries, &squashDirEntry{
+				img:  img,
+				name: string(name),
+				typ:  squashBasicType(entryHeader.Type),
+				ref:  uint64(header.Start)<<16 | uint64(entryHeader.Offset),
+			})
+		}
+	}
+}
+
+// lookup returns the inode of the entry named name in dir, or fs.ErrNotExist.
+func (img *SquashImage) lookup(dir *squashInode, name string) (*squashInode, error) {
+	entries, err := img.readDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	i := sort.Search(len(entries), func(i int) bool { return entries[i].name >= name })
+	if i == len(entries) || entries[i].name != name {
+		return nil, fs.ErrNotExist
+	}
+	return img.inode(entries[i].ref)
+}
+
+// resolve returns the inode at name, a path valid for fs.FS. Symlinks are followed, except in the last element of
+// name unless follow is set.
+func (img *SquashImage) resolve(name string, follow bool) (*squashInode, error) {
+	if name == "." {
+		return img.root, nil
+	}
+
+	hops := 0
+	stack, err := img.walk([]*squashInode{img.root}, strings.Split(name, "/"), follow, &hops)
+	if err != nil {
+		return nil, err
+	}
+	return stack[len(stack)-1], nil
+}
+
+// walk resolves the path elements in parts from the directory at the top of stack, which holds the directories
+// leading to it from the root, returning the stack extended by the inodes passed through.
+func (img *SquashImage) walk(stack []*squashInode, parts []string, follow bool, hops *int) ([]*squashInode, error) {
+	for i, part := range parts {
+		switch part {
+		case "", ".":
+			continue
+		case "..":
+			if len(stack) > 1 {
+				stack = stack[:len(stack)-1]
+			}
+			continue
+		}
+
+		dir := stack[len(stack)-1]
+		if dir.typ != squashTypeDir {
+			return nil, errors.New("not a directory")
+		}
+
+		inode, err := img.lookup(dir, part)
+		if err != nil {
+			return nil, err
+		}
+		if inode.typ != squashTypeSymlink || (i == len(parts)-1 && !follow) {
+			stack = append(stack, inode)
+			continue
+		}
+
+		if *hops++; *hops > squashMaxSymlinkHops {
+			return nil, errors.New("too many levels of symbolic links")
+		}
+		if strings.HasPrefix(inode.target, "/") {
+			stack = stack[:1]
+		}
+		if stack, err = img.walk(stack, strings.Split(inode.target, "/"), true, hops); err != nil {
+			return nil, err
+		}
+	}
+
+	return stack, nil
+}
+
+// Open opens the named file, following symlinks. Regular files implement io.ReaderAt and io.Seeker, and directories
+// fs.ReadDirFile.
+func (img *SquashImage) Open(name string) (fs.File, error) {
+	inode, err := img.resolvePath("open", name, true)
+	if err != nil {
+		return nil, err
+	}
+
+	return &squashFile{img: img, info: squashFileInfo{name: path.Base(name), inode: inode}}, nil
+}
+
+// Stat returns a FileInfo describing the named file, following symlinks.
+func (img *SquashImage) Stat(name string) (fs.FileInfo, error) {
+	inode, err := img.resolvePath("stat", name, true)
+	if err != nil {
+		return nil, err
+	}
+
+	return squashFileInfo{name: path.Base(name), inode: inode}, nil
+}
+
+// Lstat returns a FileInfo describing the named file without following a symlink at the end of name.
+func (img *SquashImage) Lstat(name string) (fs.FileInfo, error) {
+	inode, err := img.resolvePath("lstat", name, false)
+	if err != nil {
+		return nil, err
+	}
+
+	return squashFileInfo{name: path.Base(name), inode: inode}, nil
+}
+
+// ReadLink returns the target of the named symlink.
+func (img *SquashImage) ReadLink(name string) (string, error) {
+	inode, err := img.resolvePath("readlink", name, false)
+	if err != nil {
+		return "", err
+	} else if inode.typ != squashTypeSymlink {
+		return "", &fs.PathError{Op: "readlink", Path: name, Err: fs.ErrInvalid}
+	}
+
+	return inode.target, nil
+}
+
+// resolvePath resolves name for the fs.FS method op, returning errors as *fs.PathError.
+func (img *SquashImage) resolvePath(op string, name string, follow bool) (*squashInode, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+
+	inode, err := img.resolve(name, follow)
+	if err != nil {
+		return nil, &fs.PathError{Op: op, Path: name, Err: err}
+	}
+	return inode, nil
+}
+
+// squashFileInfo implements fs.FileInfo for an inode.
+type squashFileInfo struct {
+	name  string
+	inode *squashInode
+}
+
+func (fi squashFileInfo) Name() string {
+	return fi.name
+}
+
+func (fi squashFileInfo) Size() int64 {
+	switch fi.inode.typ {
+	case squashTypeFile:
+		return fi.inode.size
+	case squashTypeSymlink:
+		return int64(len(fi.inode.target))
+	default:
+		return 0
+	}
+}
+
+func (fi squashFileInfo) Mode() fs.FileMode {
+	return fi.inode.mode
+}
+
+func (fi squashFileInfo) ModTime() time.Time {
+	return fi.inode.modTime
+}
+
+func (fi squashFileInfo) IsDir() bool {
+	return fi.inode.typ == squashTypeDir
+}
+
+func (fi squashFileInfo) Sys() interface{} {
+	return nil
+}
+
+func (e *squashDirEntry) Name() string {
+	return e.name
+}
+
+func (e *squashDirEntry) IsDir() bool {
+	return e.typ == squashTypeDir
+}
+
+func (e *squashDirEntry) Type() fs.FileMode {
+	return squashMode(e.typ, 0).Type()
+}
+
+func (e *squashDirEntry) Info() (fs.FileInfo, error) {
+	inode, err := e.img.inode(e.ref)
+	if err != nil {
+		return nil, err
+	}
+	return squashFileInfo{name: e.name, inode: inode}, nil
+}
+
+// squashFile is an open file in a SquashImage. Files other than regular files and directories read as empty.
+type squashFile struct {
+	img  *SquashImage
+	info squashFileInfo
+	off  int64
+
+	entries []fs.DirEntry
+	listed  bool
+
+	mu        sync.Mutex
+	lastBlock int64
+	lastData  []byte
+}
+
+func (f *squashFile) Stat() (fs.FileInfo, error) {
+	return f.info, nil
+}
+
+func (f *squashFile) Close() error {
+	return nil
+}
+
+func (f *squashFile) Read(p []byte) (int, error) {
+	if f.info.IsDir() {
+		return 0, &fs.PathError{Op: "read", Path: f.info.name, Err: errors.New("is a directory")}
+	}
+
+	n, err := f.ReadAt(p, f.off)
+	f.off += int64(n)
+	if n > 0 && err == io.EOF {
+		err = nil
+	}
+	return n, err
+}
+
+func (f *squashFile) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += f.off
+	case io.SeekEnd:
+		offset += f.info.Size()
+	default:
+		return f.off, &fs.PathError{Op: "seek", Path: f.info.name, Err: fs.ErrInvalid}
+	}
+	if offset < 0 {
+		return f.off, &fs.PathError{Op: "seek", Path: f.info.name, Err: ErrInvalidOffset}
+	}
+
+	f.off = offset
+	return f.off, nil
+}
+
+// ReadAt reads the file's contents, fetching and decompressing only the blocks it touches. The most recently read
+// block is kept, so sequential reads do not fetch it again.
+func (f *squashFile) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, ErrInvalidOffset
+	}
+
+	size := f.info.Size()
+	if f.info.inode.typ != squashTypeFile {
+		size = 0
+	}
+
+	blockSize := int64(f.img.sb.BlockSize)
+	n := 0
+	for n < len(p) {
+		pos := off + int64(n)
+		if pos >= size {
+			return n, io.EOF
+		}
+
+		data, err := f.block(pos / blockSize)
+		if err != nil {
+			return n, &fs.PathError{Op: "read", Path: f.info.name, Err: err}
+		}
+		n += copy(p[n:], data[pos%blockSize:])
+	}
+
+	return n, nil
+}
+
+// block returns the decompressed contents of the block at index i, which is read from the file's tail in its fragment
+// if the file has no such block.
+func (f *squashFile) block(i int64) ([]byte, error) {
+	f.mu.Lock()
+	if f.lastData != nil && f.lastBlock == i {
+		data := f.lastData
+		f.mu.Unlock()
+		return data, nil
+	}
+	f.mu.Unlock()
+
+	inode := f.info.inode
+	blockSize := int64(f.img.sb.BlockSize)
+	expected := inode.size - i*blockSize
+	if expected > blockSize {
+		expected = blockSize
+	}
+
+	var data []byte
+	var err error
+	if i < int64(len(inode.blockSizes)) {
+		data, err = f.img.dataBlock(inode.blockOffsets[i], inode.blockSizes[i], expected)
+	} else {
+		data, err = f.img.fragmentBlock(inode.fragment)
+		if err == nil {
+			if int64(inode.fragmentOffset)+expected > int64(len(data)) {
+				return nil, errors.Errorf("squashfs fragment %d is too short", inode.fragment)
+			}
+			data = data[inode.fragmentOffset : int64(inode.fragmentOffset)+expected]
+		}
+	}
+	if err != nil {
+		return nil, err
+	} else if int64(len(data)) != expected {
+		return nil, errors.Errorf("squashfs block %d holds %d bytes rather than %d", i, len(data), expected)
+	}
+
+	f.mu.Lock()
+	f.lastBlock, f.lastData = i, data
+	f.mu.Unlock()
+
+	return data, nil
+}
+
+// dataBlock reads and decompresses the data block at pos whose size is given by size. A size of zero denotes a sparse
+// block of n zero bytes.
+func (img *SquashImage) dataBlock(pos int64, size uint32, n int64) ([]byte, error) {
+	stored := int64(size &^ squashDataUncompressed)
+	if stored == 0 {
+		return make([]byte, n), nil
+	} else if stored > int64(img.sb.BlockSize) {
+		return nil, errors.Errorf("squashfs block at offset %d is invalid", pos)
+	}
+
+	b, err := readChunk(img.r.ReadAt, make([]byte, stored), pos)
+	if err != nil || size&squashDataUncompressed != 0 {
+		return b, err
+	}
+
+	data, err := img.decompress(b, int(img.sb.BlockSize))
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to decompress squashfs block at offset %d", pos)
+	}
+	return data, nil
+}
+
+// fragmentBlock returns the decompressed fragment block at index i of the fragment table.
+func (img *SquashImage) fragmentBlock(i uint32) ([]byte, error) {
+	if int(i) >= len(img.fragments) {
+		return nil, errors.Errorf("squashfs fragment %d is invalid", i)
+	}
+	fragment := img.fragments[i]
+
+	img.mu.Lock()
+	data, ok := img.fragmentCache[int64(fragment.Start)]
+	img.mu.Unlock()
+	if ok {
+		return data, nil
+	}
+
+	data, err := img.dataBlock(int64(fragment.Start), fragment.Size, int64(img.sb.BlockSize))
+	if err != nil {
+		return nil, err
+	}
+
+	img.mu.Lock()
+	if len(img.fragmentCache) >= squashFragmentCacheBlocks {
+		img.fragmentCache = make(map[int64][]byte)
+	}
+	img.fragmentCache[int64(fragment.Start)] = data
+	img.mu.Unlock()
+
+	return data, nil
+}
+
+// ReadDir reads the directory's entries, which are returned sorted by name.
+func (f *squashFile) ReadDir(n int) ([]fs.DirEntry, error) {
+	if !f.info.IsDir() {
+		return nil, &fs.PathError{Op: "readdir", Path: f.info.name, Err: errors.New("not a directory")}
+	}
+
+	if !f.listed {
+		entries, err := f.img.readDir(f.info.inode)
+		if err != nil {
+			return nil, &fs.PathError{Op: "readdir", Path: f.info.name, Err: err}
+		}
+		f.entries = make([]fs.DirEntry, len(entries))
+		for i, entry := range entries {
+			f.entries[i] = entry
+		}
+		f.listed = true
+	}
+
+	if n <= 0 {
+		entries := f.entries
+		f.entries = nil
+		return entries, nil
+	} else if len(f.entries) == 0 {
+		return nil, io.EOF
+	}
+
+	if n > len(f.entries) {
+		n = len(f.entries)
+	}
+	entries := f.entries[:n]
+	f.entries = f.entries[n:]
+	return entries, nil
+}
@@ -0,0 +1,433 @@
+package s3readerat
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"io/fs"
+	"math/rand"
+	"net/http"
+	"sort"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// squashTestBlockSize is the block size of the images built by writeSquashImage, the smallest squashfs allows.
+const squashTestBlockSize = 4096
+
+// squashTestNode is a file, directory or symlink to write to a squashfs image.
+type squashTestNode struct {
+	name     string
+	data     []byte
+	target   string
+	children []*squashTestNode
+	dir      bool
+
+	// extended selects the extended inode type.
+	extended bool
+
+	parent      *squashTestNode
+	number      uint32
+	inodePos    int
+	blocksStart uint64
+	blockSizes  []uint32
+	fragment    uint32
+	fragOffset  uint32
+	dirPos      int
+	dirSize     int
+}
+
+func (n *squashTestNode) inodeLen() int {
+	switch {
+	case n.dir && n.extended:
+		return 16 + 24
+	case n.dir:
+		return 16 + 16
+	case n.target != "":
+		return 16 + 8 + len(n.target)
+	case n.extended:
+		return 16 + 40 + 4*len(n.blockSizes)
+	default:
+		return 16 + 16 + 4*len(n.blockSizes)
+	}
+}
+
+func (n *squashTestNode) basicType() uint16 {
+	switch {
+	case n.dir:
+		return squashTypeDir
+	case n.target != "":
+		return squashTypeSymlink
+	default:
+		return squashTypeFile
+	}
+}
+
+// squashTestRef returns the reference to the uncompressed metadata at pos, as stored by writeSquashMetadata without
+// compression.
+func squashTestRef(pos int) uint64 {
+	return uint64(pos/squashMetadataBlockLen*(squashMetadataBlockLen+2))<<16 | uint64(pos%squashMetadataBlockLen)
+}
+
+// writeSquashMetadata writes data to out as metadata blocks, compressed with compress unless it is nil, returning the
+// position of each block.
+func writeSquashMetadata(out *bytes.Buffer, data []byte, compress func([]byte) []byte) []uint64 {
+	var positions []uint64
+	for len(data) > 0 {
+		n := len(data)
+		if n > squashMetadataBlockLen {
+			n = squashMetadataBlockLen
+		}
+
+		positions = append(positions, uint64(out.Len()))
+		block, header := data[:n], uint16(n)|squashMetadataUncompressed
+		if compress != nil {
+			block = compress(data[:n])
+			header = uint16(len(block))
+		}
+		_ = binary.Write(out, binary.LittleEndian, header)
+		out.Write(block)
+		data = data[n:]
+	}
+	return positions
+}
+
+// writeSquashImage builds a squashfs image of root. Data and fragment blocks, the fragment table and the ID table are
+// compressed with compression; inodes and directories are stored uncompressed, so that references to them can be
+// computed before they are written.
+func writeSquashImage(t *testing.T, root *squashTestNode, compression uint16) []byte {
+	t.Helper()
+
+	compress := func(b []byte) []byte {
+		var buf bytes.Buffer
+		if compression == squashCompressionZstd {
+			zw, err := zstd.NewWriter(&buf)
+			if err != nil {
+				t.Fatalf("Error creating zstd writer: %v", err)
+			}
+			_, _ = zw.Write(b)
+			_ = zw.Close()
+		} else {
+			zw := zlib.NewWriter(&buf)
+			_, _ = zw.Write(b)
+			_ = zw.Close()
+		}
+		return buf.Bytes()
+	}
+
+	var nodes []*squashTestNode
+	var visit func(n *squashTestNode)
+	visit = func(n *squashTestNode) {
+		nodes = append(nodes, n)
+		n.number = uint32(len(nodes))
+		sort.Slice(n.children, func(i, j int) bool { return n.children[i].name < n.children[j].name })
+		for _, child := range n.children {
+			child.parent = n
+			visit(child)
+		}
+	}
+	visit(root)
+
+	out := bytes.NewBuffer(make([]byte, squashSuperblockLen))
+
+	// Write full blocks of regular files as data blocks, storing all-zero blocks as sparse, and pack their tails into
+	// fragment blocks.
+	var fragment []byte
+	var fragments []squashFragment
+	flush := func() {
+		if len(fragment) > 0 {
+			fragments = append(fragments, squashFragment{Start: uint64(out.Len()), Size: uint32(len(fragment))})
+			if compressed := compress(fragment); len(compressed) < len(fragment) {
+				fragments[len(fragments)-1].Size = uint32(len(compressed))
+				out.Write(compressed)
+			} else {
+				fragments[len(fragments)-1].Size |= squashDataUncompressed
+				out.Write(fragment)
+			}
+			fragment = nil
+		}
+	}
+	for _, n := range nodes {
+		if n.dir || n.target != "" {
+			continue
+		}
+
+		n.blocksStart, n.fragment = uint64(out.Len()), squashNoFragment
+		full := len(n.data) / squashTestBlockSize * squashTestBlockSize
+		for off := 0; off < full; off += squashTestBlockSize {
+			block := n.data[off : off+squashTestBlockSize]
+			switch compressed := compress(block); {
+			case bytes.Equal(block, make([]byte, squashTestBlockSize)):
+				n.blockSizes = append(n.blockSizes, 0)
+			case len(compressed) < len(block):
+				n.blockSizes = append(n.blockSizes, uint32(len(compressed)))
+				out.Write(compressed)
+			default:
+				n.blockSizes = append(n.blockSizes, uint32(len(block))|squashDataUncompressed)
+				out.Write(block)
+			}
+		}
+
+		if tail := n.data[full:]; len(tail) > 0 {
+			if len(fragment)+len(tail) > squashTestBlockSize {
+				flush()
+			}
+			n.fragment, n.fragOffset = uint32(len(fragments)), uint32(len(fragment))
+			fragment = append(fragment, tail...)
+		}
+	}
+	flush()
+
+	inodeLen := 0
+	for _, n := range nodes {
+		n.inodePos = inodeLen
+		inodeLen += n.inodeLen()
+	}
+
+	// Write directory listings, starting a new header whenever the entries' inodes move to another metadata block.
+	var dirs bytes.Buffer
+	for _, n := range nodes {
+		if !n.dir {
+			continue
+		}
+
+		n.dirPos = dirs.Len()
+		for i := 0; i < len(n.children); {
+			start := squashTestRef(n.children[i].inodePos) >> 16
+			j := i
+			for j < len(n.children) && squashTestRef(n.children[j].inodePos)>>16 == start && j-i < 256 {
+				j++
+			}
+
+			header := squashDirHeader{Count: uint32(j - i - 1), Start: uint32(start), InodeNumber: n.children[i].number}
+			_ = binary.Write(&dirs, binary.LittleEndian, header)
+			for _, child := range n.children[i:j] {
+				_ = binary.Write(&dirs, binary.LittleEndian, squashDirEntryHeader{
+					Offset:      uint16(child.inodePos % squashMetadataBlockLen),
+					InodeOffset: int16(child.number - header.InodeNumber),
+					Type:        child.basicType(),
+					NameSize:    uint16(len(child.name) - 1),
+				})
+				dirs.WriteString(child.name)
+			}
+			i = j
+		}
+		n.dirSize = dirs.Len() - n.dirPos
+	}
+
+	var inodes bytes.Buffer
+	for _, n := range nodes {
+		typ, permissions := n.basicType(), uint16(0644)
+		if n.dir {
+			permissions = 0755
+		} else if n.target != "" {
+			permissions = 0777
+		}
+		if n.extended {
+			typ += squashExtendedType
+		}
+		_ = binary.Write(&inodes, binary.LittleEndian, squashInodeHeader{
+			Type:        typ,
+			Permissions: permissions,
+			ModTime:     1700000000,
+			Number:      n.number,
+		})
+
+		switch {
+		case n.dir:
+			parent := uint32(len(nodes) + 1)
+			if n.parent != nil {
+				parent = n.parent.number
+			}
+			links := uint32(2)
+			for _, child := range n.children {
+				if child.dir {
+					links++
+				}
+			}
+			dirRef := squashTestRef(n.dirPos)
+			if n.extended {
+				_ = binary.Write(&inodes, binary.LittleEndian, []uint32{links, uint32(n.dirSize + 3),
+					uint32(dirRef >> 16), parent})
+				_ = binary.Write(&inodes, binary.LittleEndian, []uint16{0, uint16(dirRef)})
+				_ = binary.Write(&inodes, binary.LittleEndian, uint32(0xffffffff))
+			} else {
+				_ = binary.Write(&inodes, binary.LittleEndian, []uint32{uint32(dirRef >> 16), links})
+				_ = binary.Write(&inodes, binary.LittleEndian, []uint16{uint16(n.dirSize + 3), uint16(dirRef)})
+				_ = binary.Write(&inodes, binary.LittleEndian, parent)
+			}
+		case n.target != "":
+			_ = binary.Write(&inodes, binary.LittleEndian, []uint32{1, uint32(len(n.target))})
+			inodes.WriteString(n.target)
+		case n.extended:
+			_ = binary.Write(&inodes, binary.LittleEndian, []uint64{n.blocksStart, uint64(len(n.data)), 0})
+			_ = binary.Write(&inodes, binary.LittleEndian, []uint32{1, n.fragment, n.fragOffset, 0xffffffff})
+			_ = binary.Write(&inodes, binary.LittleEndian, n.blockSizes)
+		default:
+			_ = binary.Write(&inodes, binary.LittleEndian, []uint32{uint32(n.blocksStart), n.fragment, n.fragOffset,
+				uint32(len(n.data))})
+			_ = binary.Write(&inodes, binary.LittleEndian, n.blockSizes)
+		}
+	}
+
+	sb := squashSuperblock{
+		Magic:            squashMagic,
+		InodeCount:       uint32(len(nodes)),
+		ModTime:          1700000000,
+		BlockSize:        squashTestBlockSize,
+		FragmentCount:    uint32(len(fragments)),
+		Compression:      compression,
+		BlockLog:         12,
+		IDCount:          1,
+		VersionMajor:     4,
+		RootInode:        squashTestRef(root.inodePos),
+		XattrTableStart:  squashNoTable,
+		InodeTableStart:  uint64(out.Len()),
+		ExportTableStart: squashNoTable,
+	}
+	writeSquashMetadata(out, inodes.Bytes(), nil)
+	sb.DirectoryTableStart = uint64(out.Len())
+	writeSquashMetadata(out, dirs.Bytes(), nil)
+
+	var fragmentEntries bytes.Buffer
+	_ = binary.Write(&fragmentEntries, binary.LittleEndian, fragments)
+	fragmentBlocks := writeSquashMetadata(out, fragmentEntries.Bytes(), compress)
+	sb.FragmentTableStart = uint64(out.Len())
+	_ = binary.Write(out, binary.LittleEndian, fragmentBlocks)
+
+	idBlocks := writeSquashMetadata(out, []byte{0xe8, 0x03, 0, 0}, compress)
+	sb.IDTableStart = uint64(out.Len())
+	_ = binary.Write(out, binary.LittleEndian, idBlocks)
+
+	sb.BytesUsed = uint64(out.Len())
+	image := out.Bytes()
+	var header bytes.Buffer
+	_ = binary.Write(&header, binary.LittleEndian, sb)
+	copy(image, header.Bytes())
+
+	return image
+}
+
+// squashTestTree returns a tree exercising data blocks stored compressed, uncompressed and sparse, fragments,
+// extended inodes, symlinks, and inodes and directory listings that span metadata blocks.
+func squashTestTree() (*squashTestNode, map[string]string) {
+	random := make([]byte, 3*squashTestBlockSize+100)
+	rand.New(rand.NewSource(1)).Read(random)
+
+	files := map[string]string{
+		"README.md":        "hello",
+		"data/big.bin":     string(random),
+		"data/empty":       "",
+		"data/sparse.bin":  string(make([]byte, squashTestBlockSize)) + "tail",
+		"data/text.txt":    strings.Repeat("compressible ", 2000),
+		"data/nested/x.js": "console.log(1)",
+	}
+	for i := 0; i < 300; i++ {
+		files[fmt.Sprintf("many/f%03d", i)] = fmt.Sprintf("file %d", i)
+	}
+
+	root := &squashTestNode{dir: true, extended: true}
+	dirs := map[string]*squashTestNode{".": root}
+	var mkdir func(name string) *squashTestNode
+	mkdir = func(name string) *squashTestNode {
+		if dir, ok := dirs[name]; ok {
+			return dir
+		}
+		parent := mkdir(pathDir(name))
+		dir := &squashTestNode{name: name[strings.LastIndex(name, "/")+1:], dir: true}
+		parent.children = append(parent.children, dir)
+		dirs[name] = dir
+		return dir
+	}
+	for name, contents := range files {
+		parent := mkdir(pathDir(name))
+		node := &squashTestNode{name: name[strings.LastIndex(name, "/")+1:], data: []byte(contents)}
+		node.extended = name == "data/big.bin"
+		parent.children = append(parent.children, node)
+	}
+
+	root.children = append(root.children,
+		&squashTestNode{name: "link", target: "data/text.txt"},
+		&squashTestNode{name: "dirlink", target: "/data/nested"},
+	)
+	data := dirs["data"]
+	data.children = append(data.children, &squashTestNode{name: "up", target: "../README.md"})
+
+	files["link"] = files["data/text.txt"]
+	files["dirlink/x.js"] = files["data/nested/x.js"]
+	files["data/up"] = files["README.md"]
+
+	return root, files
+}
+
+func pathDir(name string) string {
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		return name[:i]
+	}
+	return "."
+}
+
+// TestSquashFS tests that SquashFS serves the files of a remote squashfs image, compressed with gzip or zstd.
+func TestSquashFS(t *testing.T) {
+	for _, compression := range []uint16{squashCompressionGzip, squashCompressionZstd} {
+		t.Run(squashCompressionName(compression), func(t *testing.T) {
+			root, files := squashTestTree()
+			image := writeSquashImage(t, root, compression)
+
+			f := newFakeS3(t)
+			f.put("bucket", "image.sqfs", image)
+
+			fsys, err := SquashFS(Options{Client: f.client(), Bucket: "bucket", Key: "image.sqfs"})
+			if err != nil {
+				t.Fatalf("Error calling SquashFS: %v", err)
+			}
+
+			for name, contents := range files {
+				b, err := fs.ReadFile(fsys, name)
+				if err != nil {
+					t.Fatalf("Error reading %s: %v", name, err)
+				}
+				if string(b) != contents {
+					t.Fatalf("Unexpected contents of %s", name)
+				}
+			}
+			if count := f.requestCount(http.MethodGet); count != 1 {
+				t.Fatalf("Expected 1 GetObject request to read the image, got %d", count)
+			}
+
+			if target, err := fsys.ReadLink("dirlink"); err != nil || target != "/data/nested" {
+				t.Fatalf("Expected dirlink to point to /data/nested, got %q (%v)", target, err)
+			}
+			if info, err := fsys.Lstat("link"); err != nil || info.Mode()&fs.ModeSymlink == 0 {
+				t.Fatalf("Expected link to be a symlink, got %v (%v)", info, err)
+			}
+			if _, err = fs.ReadFile(fsys, "data/missing"); !strings.Contains(fmt.Sprint(err), "not exist") {
+				t.Fatalf("Expected a missing file to not exist, got %v", err)
+			}
+
+			if err = fstest.TestFS(fsys, "README.md", "data/big.bin", "data/sparse.bin", "many/f299"); err != nil {
+				t.Fatalf("SquashFS does not behave as an fs.FS: %v", err)
+			}
+		})
+	}
+}
+
+// TestOpenSquashImageInvalid tests that OpenSquashImage rejects data that is not a squashfs image, and images
+// compressed with unsupported algorithms.
+func TestOpenSquashImageInvalid(t *testing.T) {
+	if _, err := OpenSquashImage(bytes.NewReader(make([]byte, 4096)), 4096); err == nil {
+		t.Fatalf("Expected OpenSquashImage to reject zeroes")
+	}
+
+	root, _ := squashTestTree()
+	image := writeSquashImage(t, root, squashCompressionGzip)
+	binary.LittleEndian.PutUint16(image[20:], squashCompressionXZ)
+	_, err := OpenSquashImage(bytes.NewReader(image), int64(len(image)))
+	if err == nil || !strings.Contains(err.Error(), "xz") {
+		t.Fatalf("Expected OpenSquashImage to reject xz compression, got %v", err)
+	}
+}
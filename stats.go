@@ -0,0 +1,135 @@
+package s3readerat
+
+import (
+	"time"
+
+	smithymiddleware "github.com/aws/smithy-go/middleware"
+)
+
+// Stats summarizes the S3 requests made by an S3ReaderAt, and the reads it served from memory instead.
+type Stats struct {
+	// HeadObjectRequests, GetObjectRequests and GetObjectAttributesRequests count requests issued, including those that
+	// failed.
+	HeadObjectRequests          int64
+	GetObjectRequests           int64
+	GetObjectAttributesRequests int64
+
+	// FailedRequests counts requests that returned an error, or whose response body could not be read.
+	FailedRequests int64
+
+	// HedgedRequests counts the GetObject requests issued a second time because of Options.HedgeDelay. They are
+	// included in GetObjectRequests.
+	HedgedRequests int64
+
+	// SlowDowns counts 503 SlowDown responses, including those to attempts the SDK retried.
+	SlowDowns int64
+
+	// BytesDownloaded is the number of GetObject response body bytes read.
+	BytesDownloaded int64
+
+	// CacheHits counts reads served from memory, and CacheHitBytes the bytes they returned.
+	CacheHits     int64
+	CacheHitBytes int64
+
+	// RequestTime is the total time spent in requests, including reading response bodies. See S3ReaderAt.Latencies
+	// for their distribution.
+	RequestTime time.Duration
+}
+
+// Requests returns the total number of requests issued.
+func (s Stats) Requests() int64 {
+	return s.HeadObjectRequests + s.GetObjectRequests + s.GetObjectAttributesRequests
+}
+
+// AverageRequestTime returns the mean time spent per request, or zero if none were issued.
+func (s Stats) AverageRequestTime() time.Duration {
+	if s.Requests() == 0 {
+		return 0
+	}
+	return s.RequestTime / time.Duration(s.Requests())
+}
+
+// Add returns the sum of s and other, for example to summarize the requests made by several S3ReaderAts.
+func (s Stats) Add(other Stats) Stats {
+	return Stats{
+		HeadObjectRequests:          s.HeadObjectRequests + other.HeadObjectRequests,
+		GetObjectRequests:           s.GetObjectRequests + other.GetObjectRequests,
+		GetObjectAttributesRequests: s.GetObjectAttributesRequests + other.GetObjectAttributesRequests,
+		FailedRequests:              s.FailedRequests + other.FailedRequests,
+		HedgedRequests:              s.HedgedRequests + other.HedgedRequests,
+		SlowDowns:                   s.SlowDowns + other.SlowDowns,
+		BytesDownloaded:             s.BytesDownloaded + other.BytesDownloaded,
+		CacheHits:                   s.CacheHits + other.CacheHits,
+		CacheHitBytes:               s.CacheHitBytes + other.CacheHitBytes,
+		RequestTime:                 s.RequestTime + other.RequestTime,
+	}
+}
+
+// Stats returns a snapshot of the requests made so far. It is safe for concurrent use.
+func (ra *S3ReaderAt) Stats() Stats {
+	ra.statsMu.Lock()
+	defer ra.statsMu.Unlock()
+
+	return ra.stats
+}
+
+// record adds a completed request, or a read served from memory, to s.
+func (s *Stats) record(entry RequestLogEntry, duration time.Duration, err error) {
+	switch {
+	case entry.CacheHit:
+		s.CacheHits++
+		s.CacheHitBytes += entry.Bytes
+	case entry.Operation == "HeadObject":
+		s.HeadObjectRequests++
+	case entry.Operation == "GetObjectAttributes":
+		s.GetObjectAttributesRequests++
+	default:
+		s.GetObjectRequests++
+		s.BytesDownloaded += entry.Bytes
+	}
+	if !entry.CacheHit {
+		s.RequestTime += duration
+		if err != nil {
+			s.FailedRequests++
+		}
+	}
+}
+
+// recordRequest adds a completed request, or a read served from memory, to the stats and writes it to the request
+// log, if there is one.
+func (ra *S3ReaderAt) recordRequest(entry RequestLogEntry, start time.Time, err error) {
+	duration := time.Since(start)
+
+	ra.statsMu.Lock()
+	ra.stats.record(entry, duration, err)
+	if !entry.CacheHit {
+		ra.latencies.record(entry.Operation, duration)
+	}
+	for key, value := range entry.Tags {
+		if ra.tagStats == nil {
+			ra.tagStats = make(map[Tag]Stats)
+		}
+		tag := Tag{Key: key, Value: value}
+		s := ra.tagStats[tag]
+		s.record(entry, duration, err)
+		ra.tagStats[tag] = s
+	}
+	ra.statsMu.Unlock()
+
+	if ra.requestLog == nil {
+		return
+	}
+
+	entry.Time = start
+	entry.Bucket = ra.bucket
+	entry.Key = ra.key
+	entry.DurationMs = float64(duration) / float64(time.Millisecond)
+	if err != nil {
+		entry.Error = err.Error()
+		if entry.Status == 0 {
+			entry.Status = responseStatus(smithymiddleware.Metadata{}, err)
+		}
+	}
+
+	ra.requestLog.write(entry)
+}
@@ -0,0 +1,43 @@
+package s3readerat
+
+import (
+	"testing"
+)
+
+// TestStats tests that Stats counts requests, bytes downloaded and reads served from memory.
+func TestStats(t *testing.T) {
+	f := newFakeS3(t)
+	f.put("bucket", "key", []byte("0123456789"))
+
+	s3ReaderAt, err := NewWithOptions(Options{
+		Client:            f.client(),
+		Bucket:            "bucket",
+		Key:               "key",
+		PrefetchTailBytes: 4,
+	})
+	if err != nil {
+		t.Fatalf("Error calling NewWithOptions: %v", err)
+	}
+
+	b := make([]byte, 2)
+	for _, off := range []int64{0, 6, 8} {
+		if _, err = s3ReaderAt.ReadAt(b, off); err != nil {
+			t.Fatalf("Error calling ReadAt: %v", err)
+		}
+	}
+
+	stats := s3ReaderAt.Stats()
+	if stats.HeadObjectRequests != 1 || stats.GetObjectRequests != 2 || stats.FailedRequests != 0 {
+		t.Fatalf("Expected 1 HeadObject and 2 GetObject requests, got %+v", stats)
+	}
+	if stats.BytesDownloaded != 6 {
+		t.Fatalf("Expected 6 bytes downloaded, got %d", stats.BytesDownloaded)
+	}
+	if stats.CacheHits != 2 || stats.CacheHitBytes != 4 {
+		t.Fatalf("Expected 2 cache hits totalling 4 bytes, got %d totalling %d", stats.CacheHits, stats.CacheHitBytes)
+	}
+	if stats.Requests() != 3 || stats.AverageRequestTime() != stats.RequestTime/3 {
+		t.Fatalf("Expected 3 requests averaging %v, got %d averaging %v", stats.RequestTime/3, stats.Requests(),
+			stats.AverageRequestTime())
+	}
+}
@@ -0,0 +1,199 @@
+package s3readerat
+
+import (
+	"context"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// DefaultStreamReaderChunkSize is the number of bytes a StreamReader fetches per request by default.
+	DefaultStreamReaderChunkSize = 8 << 20
+
+	// DefaultStreamReaderDepth is the number of chunks a StreamReader keeps in flight by default.
+	DefaultStreamReaderDepth = 4
+)
+
+// ErrStreamReaderClosed is returned by reads from a StreamReader after Close.
+var ErrStreamReaderClosed = errors.New("read from closed StreamReader")
+
+// StreamReaderOptions configures a StreamReader.
+type StreamReaderOptions struct {
+	// ChunkSize is the number of bytes to fetch per ReadAt call. The default is DefaultStreamReaderChunkSize.
+	ChunkSize int
+
+	// Depth is the number of chunks to fetch concurrently ahead of the reader, including the chunk being read. The
+	// default is DefaultStreamReaderDepth.
+	Depth int
+}
+
+// StreamReader reads a remote object sequentially, keeping several chunk fetches in flight ahead of the consumer so
+// that network latency overlaps with processing. Memory use is bounded by ChunkSize times Depth. It is not safe for
+// concurrent use. If the underlying reader has a PrefetchAt method, as S3ReaderAt does, chunks beyond the next one
+// are fetched with it, and any whose prefetch fails with ErrPrefetchCanceled are fetched again once needed.
+type StreamReader struct {
+	r         io.ReaderAt
+	size      int64
+	chunkSize int
+	depth     int
+
+	next    int64
+	pending []*streamChunk
+	current []byte
+	err     error
+}
+
+// prefetcher is implemented by readers, such as S3ReaderAt, that can issue speculative reads at a lower priority.
+type prefetcher interface {
+	PrefetchAt(p []byte, off int64) (int, error)
+}
+
+// streamChunk is a fetch of the n bytes at off. done is closed once buf and err are set.
+type streamChunk struct {
+	off  int64
+	n    int64
+	buf  []byte
+	err  error
+	done chan struct{}
+}
+
+// NewStreamReader returns a StreamReader over the first size bytes of r. To stream part of an object, pass an
+// io.SectionReader. No fetches are issued until the first Read.
+func NewStreamReader(r io.ReaderAt, size int64, options StreamReaderOptions) *StreamReader {
+	if options.ChunkSize <= 0 {
+		options.ChunkSize = DefaultStreamReaderChunkSize
+	}
+
+	if options.Depth <= 0 {
+		options.Depth = DefaultStreamReaderDepth
+	}
+
+	return &StreamReader{
+		r:         r,
+		size:      size,
+		chunkSize: options.ChunkSize,
+		depth:     options.Depth,
+	}
+}
+
+// Read reads up to len(p) bytes, waiting for the next chunk if the current one is exhausted.
+func (sr *StreamReader) Read(p []byte) (int, error) {
+	for len(sr.current) == 0 {
+		if sr.err != nil {
+			return 0, sr.err
+		}
+
+		sr.fill()
+		if len(sr.pending) == 0 {
+			sr.err = io.EOF
+			return 0, sr.err
+		}
+
+		chunk := sr.pending[0]
+		sr.pending = sr.pending[1:]
+		<-chunk.done
+		sr.refetch(chunk)
+
+		if chunk.err != nil {
+			sr.err = chunk.err
+		}
+		sr.current = chunk.buf
+
+		// Replace the chunk just consumed before handing any of it to the caller.
+		sr.fill()
+	}
+
+	n := copy(p, sr.current)
+	sr.current = sr.current[n:]
+	return n, nil
+}
+
+// WaitForPrefetch blocks until the chunks holding the next n bytes of the stream have been fetched, starting their
+// fetches if need be, so that a latency-critical section can be sure its data is in memory before it starts. Only
+// Depth chunks are fetched ahead, so at most that many are waited for. Chunks whose prefetches were canceled are
+// fetched again. It returns the error of the first chunk that failed, which Read will also return once it reaches
+// that chunk, or ctx's error if ctx is done first.
+func (sr *StreamReader) WaitForPrefetch(ctx context.Context, n int64) error {
+	if sr.err == ErrStreamReaderClosed {
+		return sr.err
+	}
+
+	sr.fill()
+	n -= int64(len(sr.current))
+	for _, chunk := range sr.pending {
+		if n <= 0 {
+			break
+		}
+
+		select {
+		case <-chunk.done:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+
+		sr.refetch(chunk)
+		if chunk.err != nil {
+			return chunk.err
+		}
+		n -= chunk.n
+	}
+
+	return nil
+}
+
+// refetch fetches a chunk whose prefetch was dropped under memory pressure, now that it is needed.
+func (sr *StreamReader) refetch(chunk *streamChunk) {
+	if errors.Is(chunk.err, ErrPrefetchCanceled) {
+		chunk.buf = make([]byte, chunk.n)
+		chunk.buf, chunk.err = readChunk(sr.r.ReadAt, chunk.buf, chunk.off)
+	}
+}
+
+// fill starts fetching chunks until depth are in flight or the end of the object is reached.
+func (sr *StreamReader) fill() {
+	for len(sr.pending) < sr.depth && sr.next < sr.size && sr.err == nil {
+		n := int64(sr.chunkSize)
+		if n > sr.size-sr.next {
+			n = sr.size - sr.next
+		}
+
+		// Only the chunk the consumer will read next is needed now; fetches further ahead are speculative.
+		readAt := sr.r.ReadAt
+		if p, ok := sr.r.(prefetcher); ok && len(sr.pending) > 0 {
+			readAt = p.PrefetchAt
+		}
+
+		chunk := &streamChunk{off: sr.next, n: n, buf: make([]byte, n), done: make(chan struct{})}
+		sr.pending = append(sr.pending, chunk)
+		sr.next += n
+
+		go func() {
+			defer close(chunk.done)
+			chunk.buf, chunk.err = readChunk(readAt, chunk.buf, chunk.off)
+			if errors.Is(chunk.err, ErrPrefetchCanceled) {
+				// Drop the buffer until the chunk is needed.
+				chunk.buf = nil
+			}
+		}()
+	}
+}
+
+// readChunk fills buf from off with readAt, returning the bytes read and an error if buf could not be filled.
+func readChunk(readAt func(p []byte, off int64) (int, error), buf []byte, off int64) ([]byte, error) {
+	read, err := readAt(buf, off)
+	if read == len(buf) {
+		err = nil
+	} else if err == nil || err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return buf[:read], err
+}
+
+// Close stops issuing fetches. Fetches already in flight complete in the background and their results are discarded.
+func (sr *StreamReader) Close() error {
+	sr.pending = nil
+	sr.current = nil
+	sr.err = ErrStreamReaderClosed
+	return nil
+}
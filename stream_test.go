@@ -0,0 +1,169 @@
+package s3readerat
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// trackingReaderAt wraps an io.ReaderAt, recording the most ReadAt calls in flight at once. Calls block until release
+// is closed.
+type trackingReaderAt struct {
+	r       io.ReaderAt
+	release chan struct{}
+
+	mu          sync.Mutex
+	inFlight    int
+	maxInFlight int
+}
+
+func (t *trackingReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	t.mu.Lock()
+	t.inFlight++
+	if t.inFlight > t.maxInFlight {
+		t.maxInFlight = t.inFlight
+	}
+	t.mu.Unlock()
+
+	<-t.release
+
+	t.mu.Lock()
+	t.inFlight--
+	t.mu.Unlock()
+
+	return t.r.ReadAt(p, off)
+}
+
+// cancelingReaderAt is an io.ReaderAt whose prefetches are always canceled under memory pressure.
+type cancelingReaderAt struct {
+	io.ReaderAt
+}
+
+func (c cancelingReaderAt) PrefetchAt(p []byte, off int64) (int, error) {
+	return 0, ErrPrefetchCanceled
+}
+
+// TestStreamReaderPrefetchCanceled tests that StreamReader fetches chunks whose prefetches were canceled once they are
+// needed.
+func TestStreamReaderPrefetchCanceled(t *testing.T) {
+	data := strings.Repeat("0123456789", 10)
+	sr := NewStreamReader(cancelingReaderAt{strings.NewReader(data)}, int64(len(data)),
+		StreamReaderOptions{ChunkSize: 7, Depth: 3})
+
+	b, err := ioutil.ReadAll(sr)
+	if err != nil {
+		t.Fatalf("Error calling Read: %v", err)
+	}
+	if string(b) != data {
+		t.Fatalf("Expected %q, got %q", data, b)
+	}
+}
+
+// TestStreamReader tests that StreamReader returns the whole object, keeping at most Depth chunks in flight.
+func TestStreamReader(t *testing.T) {
+	data := strings.Repeat("0123456789", 10)
+	r := &trackingReaderAt{r: strings.NewReader(data), release: make(chan struct{})}
+	close(r.release)
+
+	sr := NewStreamReader(r, int64(len(data)), StreamReaderOptions{ChunkSize: 7, Depth: 3})
+	b, err := ioutil.ReadAll(sr)
+	if err != nil {
+		t.Fatalf("Error calling Read: %v", err)
+	}
+
+	if string(b) != data {
+		t.Fatalf("Expected %q, got %q", data, b)
+	}
+
+	if r.maxInFlight > 3 {
+		t.Fatalf("Expected at most 3 chunks in flight, got %d", r.maxInFlight)
+	}
+
+	if err = sr.Close(); err != nil {
+		t.Fatalf("Error calling Close: %v", err)
+	}
+
+	if _, err = sr.Read(make([]byte, 1)); err != ErrStreamReaderClosed {
+		t.Fatalf("Expected ErrStreamReaderClosed reading after Close, got %v", err)
+	}
+}
+
+// TestStreamReaderPrefetches tests that StreamReader starts fetching Depth chunks on the first Read.
+func TestStreamReaderPrefetches(t *testing.T) {
+	data := strings.Repeat("x", 100)
+	r := &trackingReaderAt{r: strings.NewReader(data), release: make(chan struct{})}
+
+	sr := NewStreamReader(r, int64(len(data)), StreamReaderOptions{ChunkSize: 10, Depth: 4})
+	done := make(chan error)
+	go func() {
+		_, err := sr.Read(make([]byte, 1))
+		done <- err
+	}()
+
+	for {
+		r.mu.Lock()
+		inFlight := r.inFlight
+		r.mu.Unlock()
+		if inFlight == 4 {
+			break
+		}
+		runtime.Gosched()
+	}
+
+	close(r.release)
+	if err := <-done; err != nil {
+		t.Fatalf("Error calling Read: %v", err)
+	}
+}
+
+// TestStreamReaderShortObject tests that StreamReader returns io.ErrUnexpectedEOF, after the bytes it did read, if
+// the object is shorter than expected.
+func TestStreamReaderShortObject(t *testing.T) {
+	data := "0123456789"
+	sr := NewStreamReader(strings.NewReader(data), 15, StreamReaderOptions{ChunkSize: 4})
+
+	b, err := ioutil.ReadAll(sr)
+	if err != io.ErrUnexpectedEOF {
+		t.Fatalf("Expected io.ErrUnexpectedEOF, got %v", err)
+	}
+
+	if string(b) != data {
+		t.Fatalf("Expected %q, got %q", data, b)
+	}
+}
+
+// TestStreamReaderWaitForPrefetch tests that WaitForPrefetch returns once the chunks holding the requested bytes have
+// been fetched, including chunks whose prefetches were canceled.
+func TestStreamReaderWaitForPrefetch(t *testing.T) {
+	data := strings.Repeat("0123456789", 10)
+	r := &trackingReaderAt{r: strings.NewReader(data), release: make(chan struct{})}
+	sr := NewStreamReader(cancelingReaderAt{r}, int64(len(data)), StreamReaderOptions{ChunkSize: 10, Depth: 4})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := sr.WaitForPrefetch(ctx, 25); err != context.Canceled {
+		t.Fatalf("Expected context.Canceled, got %v", err)
+	}
+
+	close(r.release)
+	if err := sr.WaitForPrefetch(context.Background(), 25); err != nil {
+		t.Fatalf("Error calling WaitForPrefetch: %v", err)
+	}
+	for i, chunk := range sr.pending[:3] {
+		if string(chunk.buf) != data[i*10:(i+1)*10] {
+			t.Fatalf("Expected chunk %d to hold %q, got %q", i, data[i*10:(i+1)*10], chunk.buf)
+		}
+	}
+
+	b, err := ioutil.ReadAll(sr)
+	if err != nil {
+		t.Fatalf("Error calling Read: %v", err)
+	}
+	if string(b) != data {
+		t.Fatalf("Expected %q, got %q", data, b)
+	}
+}
@@ -0,0 +1,63 @@
+package s3readerat
+
+import (
+	"context"
+)
+
+// Tag attributes requests to a party, such as a tenant or a job, so that services reading on behalf of many can
+// break down their S3 usage with StatsByTag.
+type Tag struct {
+	Key   string
+	Value string
+}
+
+// tagsKey is the context key under which WithTags stores tags.
+type tagsKey struct{}
+
+// WithTags returns a copy of ctx whose requests are attributed to tags, as well as to any tags ctx already carries. A
+// later tag with the same Key replaces an earlier one. Pass the result to ReadAtContext or ReadIntoFile, or to
+// Options.Context or WithContext to tag every request.
+func WithTags(ctx context.Context, tags ...Tag) context.Context {
+	existing := tagsFromContext(ctx)
+	merged := make(map[string]string, len(existing)+len(tags))
+	for key, value := range existing {
+		merged[key] = value
+	}
+	for _, tag := range tags {
+		merged[tag.Key] = tag.Value
+	}
+
+	return context.WithValue(ctx, tagsKey{}, merged)
+}
+
+// tagsFromContext returns the tags ctx carries, keyed by Key. The result must not be modified.
+func tagsFromContext(ctx context.Context) map[string]string {
+	if ctx == nil {
+		return nil
+	}
+
+	tags, _ := ctx.Value(tagsKey{}).(map[string]string)
+	return tags
+}
+
+// ReadAtContext is like ReadAt, but makes its requests with ctx rather than the S3ReaderAt's context, so that they can
+// be canceled, or attributed to tags with WithTags, separately from other reads.
+func (ra *S3ReaderAt) ReadAtContext(ctx context.Context, p []byte, off int64) (int, error) {
+	return ra.readAt(ctx, p, off)
+}
+
+// StatsByTag returns a snapshot of the stats of the requests attributed to each tag, as described by Stats. A request
+// with several tags counts toward each of them. Requests for state shared by all reads, such as the prefetched head
+// and tail, are attributed to the tags of the S3ReaderAt's own context, and the request for the size of the object to
+// those of the read, or the call to SizeContext, that needed it. It is safe for concurrent use.
+func (ra *S3ReaderAt) StatsByTag() map[Tag]Stats {
+	ra.statsMu.Lock()
+	defer ra.statsMu.Unlock()
+
+	stats := make(map[Tag]Stats, len(ra.tagStats))
+	for tag, s := range ra.tagStats {
+		stats[tag] = s
+	}
+
+	return stats
+}
@@ -0,0 +1,40 @@
+package s3readerat
+
+import (
+	"context"
+	"testing"
+)
+
+// TestStatsByTag tests that requests made with tagged contexts are counted toward each of their tags.
+func TestStatsByTag(t *testing.T) {
+	f := newFakeS3(t)
+	f.put("bucket", "key", []byte("0123456789"))
+
+	size := int64(10)
+	s3ReaderAt, err := NewWithOptions(Options{Client: f.client(), Bucket: "bucket", Key: "key", Size: &size})
+	if err != nil {
+		t.Fatalf("Error calling NewWithOptions: %v", err)
+	}
+
+	tenantA := WithTags(context.Background(), Tag{Key: "tenant", Value: "a"})
+	tenantB := WithTags(context.Background(), Tag{Key: "tenant", Value: "b"}, Tag{Key: "job", Value: "1"})
+	b := make([]byte, 2)
+	for _, ctx := range []context.Context{tenantA, tenantA, tenantB, context.Background()} {
+		if _, err = s3ReaderAt.ReadAtContext(ctx, b, 0); err != nil {
+			t.Fatalf("Error calling ReadAtContext: %v", err)
+		}
+	}
+
+	stats := s3ReaderAt.StatsByTag()
+	if len(stats) != 3 {
+		t.Fatalf("Expected stats for 3 tags, got %v", stats)
+	}
+	for tag, requests := range map[Tag]int64{{"tenant", "a"}: 2, {"tenant", "b"}: 1, {"job", "1"}: 1} {
+		if s := stats[tag]; s.GetObjectRequests != requests || s.BytesDownloaded != 2*requests {
+			t.Fatalf("Expected %d requests tagged %v, got %+v", requests, tag, s)
+		}
+	}
+	if total := s3ReaderAt.Stats(); total.GetObjectRequests != 4 {
+		t.Fatalf("Expected 4 requests in total, got %d", total.GetObjectRequests)
+	}
+}
@@ -0,0 +1,199 @@
+package s3readerat
+
+import (
+	"archive/tar"
+	"context"
+	"io"
+	"log"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/pkg/errors"
+)
+
+const (
+	// DefaultTarDepth is the number of upcoming objects WriteTar prefetches by default.
+	DefaultTarDepth = 8
+
+	// DefaultTarPrefetchBytes is the number of bytes at the start of each upcoming object WriteTar prefetches by
+	// default.
+	DefaultTarPrefetchBytes = 1 << 20
+)
+
+// TarOptions configures WriteTar.
+type TarOptions struct {
+	// Options configures the S3ReaderAt opened for each object. Its Bucket names the bucket to list, and its Client,
+	// Options or API the client to read with. Key and Size are set for each object.
+	Options Options
+
+	// Prefix selects the objects to export: those whose keys start with it. Each is named in the archive after the
+	// part of its key following the last slash in Prefix.
+	Prefix string
+
+	// Lister lists the objects. The default is Options.Client or, in multi-region mode, an s3.Client made from
+	// Options.Options. It is required with Options.API.
+	Lister s3.ListObjectsV2APIClient
+
+	// Depth is the number of upcoming objects to prefetch while an object is written. The default is DefaultTarDepth.
+	Depth int
+
+	// PrefetchBytes is the number of bytes at the start of each upcoming object to prefetch, so that memory use is
+	// bounded by Depth times PrefetchBytes. Objects no larger are written entirely from memory; the rest of larger
+	// objects is streamed once they are reached. The default is DefaultTarPrefetchBytes.
+	PrefetchBytes int64
+
+	// OnOpen, if set, is called with the S3ReaderAt opened for each object, for example to sum their Stats.
+	OnOpen func(ra *S3ReaderAt)
+}
+
+// tarEntry is an object to write to the archive, along with the prefetch of its first bytes. done is closed once
+// prefix and err are set.
+type tarEntry struct {
+	object types.Object
+	ra     *S3ReaderAt
+	prefix []byte
+	err    error
+	done   chan struct{}
+}
+
+// WriteTar lists the objects under options.Prefix and writes them, in key order, to w as a tar archive, returning the
+// number written. While each object is written, the first bytes of the next few are fetched concurrently, so that
+// exporting many small objects is not bound by the latency of each request. Keys ending in a slash, which S3 consoles
+// create as folder markers, are skipped. The archive is incomplete if an error is returned.
+func WriteTar(ctx context.Context, w io.Writer, options TarOptions) (int, error) {
+	if options.Options.Bucket == "" {
+		return 0, errors.New("a Bucket is required")
+	} else if options.Depth < 0 {
+		return 0, errors.Errorf("provided Depth is invalid: %d", options.Depth)
+	} else if options.PrefetchBytes < 0 {
+		return 0, errors.Errorf("provided PrefetchBytes is invalid: %d", options.PrefetchBytes)
+	}
+
+	if options.Depth == 0 {
+		options.Depth = DefaultTarDepth
+	}
+	if options.PrefetchBytes == 0 {
+		options.PrefetchBytes = DefaultTarPrefetchBytes
+	}
+
+	next, err := newObjectLister(options.Options, options.Lister, options.Prefix)
+	if err != nil {
+		return 0, err
+	}
+
+	// Prefetches still in flight when WriteTar returns are canceled.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	options.Options.Context = ctx
+
+	dir := options.Prefix[:strings.LastIndex(options.Prefix, "/")+1]
+	tw := tar.NewWriter(w)
+	written := 0
+
+	var pending []*tarEntry
+	listed := true
+	for {
+		for listed && len(pending) < options.Depth {
+			object, ok, err := next(ctx)
+			if err != nil {
+				return written, err
+			} else if !ok {
+				listed = false
+				break
+			}
+			if strings.HasSuffix(aws.ToString(object.Key), "/") {
+				continue
+			}
+
+			entry, err := startTarEntry(object, options)
+			if err != nil {
+				return written, err
+			}
+			pending = append(pending, entry)
+		}
+		if len(pending) == 0 {
+			break
+		}
+
+		entry := pending[0]
+		pending = pending[1:]
+		if err = writeTarEntry(tw, entry, strings.TrimPrefix(aws.ToString(entry.object.Key), dir)); err != nil {
+			return written, err
+		}
+		written++
+	}
+
+	return written, tw.Close()
+}
+
+// startTarEntry opens object and starts prefetching its first bytes.
+func startTarEntry(object types.Object, options TarOptions) (*tarEntry, error) {
+	readerOptions := options.Options
+	readerOptions.Key = aws.ToString(object.Key)
+	readerOptions.Size = aws.Int64(object.Size)
+
+	ra, err := NewWithOptions(readerOptions)
+	if err != nil {
+		return nil, err
+	}
+	if options.OnOpen != nil {
+		options.OnOpen(ra)
+	}
+
+	n := options.PrefetchBytes
+	if n > object.Size {
+		n = object.Size
+	}
+
+	entry := &tarEntry{object: object, ra: ra, prefix: make([]byte, n), done: make(chan struct{})}
+	go func() {
+		defer close(entry.done)
+		if n == 0 {
+			return
+		}
+		if ra.debug() {
+			log.Printf("Prefetching the first %d bytes of S3 object s3://%s/%s", n, ra.bucket, ra.key)
+		}
+		entry.prefix, entry.err = readChunk(ra.ReadAt, entry.prefix, 0)
+	}()
+
+	return entry, nil
+}
+
+// writeTarEntry writes entry to tw as a regular file named name, once its prefetch completes, streaming the rest of
+// the object after the prefetched bytes.
+func writeTarEntry(tw *tar.Writer, entry *tarEntry, name string) error {
+	<-entry.done
+	if entry.err != nil {
+		return errors.Wrapf(entry.err, "unable to read s3://%s/%s", entry.ra.bucket, entry.ra.key)
+	}
+
+	header := &tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     name,
+		Size:     entry.object.Size,
+		Mode:     0644,
+		ModTime:  aws.ToTime(entry.object.LastModified),
+	}
+	if err := tw.WriteHeader(header); err != nil {
+		return err
+	}
+	if _, err := tw.Write(entry.prefix); err != nil {
+		return err
+	}
+
+	rest := entry.object.Size - int64(len(entry.prefix))
+	if rest == 0 {
+		return nil
+	}
+
+	sr := NewStreamReader(io.NewSectionReader(entry.ra, int64(len(entry.prefix)), rest), rest, StreamReaderOptions{})
+	defer sr.Close()
+	if _, err := io.Copy(tw, sr); err != nil {
+		return errors.Wrapf(err, "unable to read s3://%s/%s", entry.ra.bucket, entry.ra.key)
+	}
+
+	return nil
+}
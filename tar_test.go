@@ -0,0 +1,84 @@
+package s3readerat
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+// TestWriteTar tests that WriteTar writes the objects under a prefix as a tar archive, across pages of the listing,
+// streaming objects larger than PrefetchBytes and skipping folder markers.
+func TestWriteTar(t *testing.T) {
+	f := newFakeS3(t)
+	objects := map[string]string{
+		"logs/2024/a.txt":       "hello",
+		"logs/2024/b.bin":       strings.Repeat("0123456789", 1000),
+		"logs/2024/c/d.txt":     "nested",
+		"logs/2024/e.txt":       "",
+		"logs/2025/f.txt":       "excluded",
+		"logs/2024-backup.json": "excluded",
+	}
+	for key, contents := range objects {
+		f.put("bucket", key, []byte(contents))
+	}
+	f.put("bucket", "logs/2024/", nil)
+
+	var archive bytes.Buffer
+	var opened int
+	n, err := WriteTar(context.Background(), &archive, TarOptions{
+		Options:       Options{Client: f.client(), Bucket: "bucket"},
+		Prefix:        "logs/2024/",
+		Depth:         2,
+		PrefetchBytes: 1024,
+		OnOpen:        func(*S3ReaderAt) { opened++ },
+	})
+	if err != nil {
+		t.Fatalf("Error calling WriteTar: %v", err)
+	}
+	if n != 4 || opened != 4 {
+		t.Fatalf("Expected 4 objects to be written, got %d written and %d opened", n, opened)
+	}
+
+	var names []string
+	tr := tar.NewReader(&archive)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatalf("Error reading tar archive: %v", err)
+		}
+
+		b, err := ioutil.ReadAll(tr)
+		if err != nil {
+			t.Fatalf("Error reading %s: %v", header.Name, err)
+		}
+		if string(b) != objects["logs/2024/"+header.Name] {
+			t.Fatalf("Unexpected contents of %s", header.Name)
+		}
+		names = append(names, header.Name)
+	}
+
+	if strings.Join(names, ",") != "a.txt,b.bin,c/d.txt,e.txt" {
+		t.Fatalf("Unexpected archive members: %v", names)
+	}
+}
+
+// TestWriteTarInvalidOptions tests that WriteTar rejects invalid options.
+func TestWriteTarInvalidOptions(t *testing.T) {
+	f := newFakeS3(t)
+	for _, options := range []TarOptions{
+		{},
+		{Options: Options{Bucket: "bucket"}, Depth: -1},
+		{Options: Options{Bucket: "bucket"}, PrefetchBytes: -1},
+		{Options: Options{Bucket: "bucket", API: f.client()}},
+	} {
+		if _, err := WriteTar(context.Background(), ioutil.Discard, options); err == nil {
+			t.Fatalf("Expected WriteTar to fail with %+v", options)
+		}
+	}
+}
@@ -0,0 +1,208 @@
+package s3readerat
+
+import (
+	"log"
+	"math"
+	"sync/atomic"
+	"time"
+
+	s3retry "github.com/markandrus/s3readerat/retry"
+	"github.com/pkg/errors"
+)
+
+// Values of S3ReaderAt.debugMode.
+const (
+	debugFromField int32 = iota
+	debugOff
+	debugOn
+)
+
+// Tunables are the options of an S3ReaderAt that UpdateOptions can change while it is in use. Each has the meaning of
+// the field of Options with the same name.
+type Tunables struct {
+	Debug                   bool
+	MaxTotalBytes           int64
+	MaxConcurrentRequests   int
+	ConcurrencyLimit        *ConcurrencyLimit
+	MaxPrefetchBytes        int64
+	TargetThroughput        int64
+	RetryBudget             *s3retry.Budget
+	Retry                   *RetryPolicy
+	MaxReadDuration         time.Duration
+	RequestTimeout          time.Duration
+	HedgeDelay              time.Duration
+	ParallelReadThreshold   int64
+	ParallelReadPartSize    int64
+	ParallelReadConcurrency int
+	DeduplicateReads        bool
+	CoalesceWindow          time.Duration
+	CoalesceGap             int64
+	MinRequestSize          int64
+	ReadAhead               int
+	MaxReadAttempts         int
+	PrefetchHeadBytes       int64
+	PrefetchTailBytes       int64
+}
+
+// validate returns an error if any of the Tunables is invalid.
+func (t Tunables) validate() error {
+	switch {
+	case t.MaxTotalBytes < 0:
+		return errors.Errorf("provided MaxTotalBytes is invalid: %d", t.MaxTotalBytes)
+	case t.MaxConcurrentRequests < 0:
+		return errors.Errorf("provided MaxConcurrentRequests is invalid: %d", t.MaxConcurrentRequests)
+	case t.MaxConcurrentRequests > 0 && t.ConcurrencyLimit != nil:
+		return errors.New("MaxConcurrentRequests and ConcurrencyLimit cannot both be provided")
+	case t.MaxPrefetchBytes < 0:
+		return errors.Errorf("provided MaxPrefetchBytes is invalid: %d", t.MaxPrefetchBytes)
+	case t.TargetThroughput < 0:
+		return errors.Errorf("provided TargetThroughput is invalid: %d", t.TargetThroughput)
+	case t.MaxReadDuration < 0:
+		return errors.Errorf("provided MaxReadDuration is invalid: %v", t.MaxReadDuration)
+	case t.RequestTimeout < 0:
+		return errors.Errorf("provided RequestTimeout is invalid: %v", t.RequestTimeout)
+	case t.HedgeDelay < 0:
+		return errors.Errorf("provided HedgeDelay is invalid: %v", t.HedgeDelay)
+	case t.ParallelReadThreshold < 0:
+		return errors.Errorf("provided ParallelReadThreshold is invalid: %d", t.ParallelReadThreshold)
+	case t.ParallelReadPartSize < 0:
+		return errors.Errorf("provided ParallelReadPartSize is invalid: %d", t.ParallelReadPartSize)
+	case t.ParallelReadConcurrency < 0:
+		return errors.Errorf("provided ParallelReadConcurrency is invalid: %d", t.ParallelReadConcurrency)
+	case t.CoalesceWindow < 0:
+		return errors.Errorf("provided CoalesceWindow is invalid: %v", t.CoalesceWindow)
+	case t.CoalesceGap < 0:
+		return errors.Errorf("provided CoalesceGap is invalid: %d", t.CoalesceGap)
+	case t.MinRequestSize < 0:
+		return errors.Errorf("provided MinRequestSize is invalid: %d", t.MinRequestSize)
+	case t.ReadAhead < 0:
+		return errors.Errorf("provided ReadAhead is invalid: %d", t.ReadAhead)
+	case t.MaxReadAttempts < 0:
+		return errors.Errorf("provided MaxReadAttempts is invalid: %d", t.MaxReadAttempts)
+	case t.PrefetchHeadBytes < 0:
+		return errors.Errorf("provided PrefetchHeadBytes is invalid: %d", t.PrefetchHeadBytes)
+	case t.PrefetchTailBytes < 0:
+		return errors.Errorf("provided PrefetchTailBytes is invalid: %d", t.PrefetchTailBytes)
+	default:
+		return t.Retry.validate()
+	}
+}
+
+// tunables returns the Tunables given in options.
+func (options Options) tunables() Tunables {
+	return Tunables{
+		Debug:                   options.Debug,
+		MaxTotalBytes:           options.MaxTotalBytes,
+		MaxConcurrentRequests:   options.MaxConcurrentRequests,
+		ConcurrencyLimit:        options.ConcurrencyLimit,
+		MaxPrefetchBytes:        options.MaxPrefetchBytes,
+		TargetThroughput:        options.TargetThroughput,
+		RetryBudget:             options.RetryBudget,
+		Retry:                   options.Retry,
+		MaxReadDuration:         options.MaxReadDuration,
+		RequestTimeout:          options.RequestTimeout,
+		HedgeDelay:              options.HedgeDelay,
+		ParallelReadThreshold:   options.ParallelReadThreshold,
+		ParallelReadPartSize:    options.ParallelReadPartSize,
+		ParallelReadConcurrency: options.ParallelReadConcurrency,
+		DeduplicateReads:        options.DeduplicateReads,
+		CoalesceWindow:          options.CoalesceWindow,
+		CoalesceGap:             options.CoalesceGap,
+		MinRequestSize:          options.MinRequestSize,
+		ReadAhead:               options.ReadAhead,
+		MaxReadAttempts:         options.MaxReadAttempts,
+		PrefetchHeadBytes:       options.PrefetchHeadBytes,
+		PrefetchTailBytes:       options.PrefetchTailBytes,
+	}
+}
+
+// tuning is the S3ReaderAt's Tunables in effect, with the limiter enforcing MaxConcurrentRequests or ConcurrencyLimit,
+// if any. It is replaced as a whole by UpdateOptions, never modified, so that each request sees a consistent set.
+type tuning struct {
+	Tunables
+	limiter *limiter
+
+	// own is the S3ReaderAt's own limiter, enforcing MaxConcurrentRequests, as opposed to that of a ConcurrencyLimit
+	// it shares with others.
+	own *limiter
+}
+
+// newTuning returns the tuning for t, reusing the S3ReaderAt's own limiter of the previous tuning, if any, so that
+// requests waiting on it are not forgotten.
+func newTuning(t Tunables, previous *limiter) *tuning {
+	next := &tuning{Tunables: t}
+	switch {
+	case t.MaxConcurrentRequests == 0 && previous != nil:
+		// Let requests waiting on the previous limiter go.
+		previous.resize(math.MaxInt32)
+	case previous != nil:
+		previous.resize(t.MaxConcurrentRequests)
+		next.own = previous
+	case t.MaxConcurrentRequests > 0:
+		next.own = newLimiter(t.MaxConcurrentRequests)
+	}
+
+	next.limiter = next.own
+	if t.ConcurrencyLimit != nil {
+		next.limiter = t.ConcurrencyLimit.limiter
+	}
+
+	return next
+}
+
+// tuning returns the Tunables in effect.
+func (ra *S3ReaderAt) tuning() *tuning {
+	return ra.tuningValue.Load().(*tuning)
+}
+
+// debug reports whether debug logging is enabled: by UpdateOptions, if it has been called, or else by Debug.
+func (ra *S3ReaderAt) debug() bool {
+	switch atomic.LoadInt32(&ra.debugMode) {
+	case debugOn:
+		return true
+	case debugOff:
+		return false
+	default:
+		return ra.Debug
+	}
+}
+
+// Tunables returns the Tunables in effect.
+func (ra *S3ReaderAt) Tunables() Tunables {
+	t := ra.tuning().Tunables
+	t.Debug = ra.debug()
+	return t
+}
+
+// UpdateOptions changes the S3ReaderAt's Tunables while it is in use, such as from a service's admin endpoint, without
+// reopening the object and losing its prefetched head and tail, its stats and the object's size and parts. update is
+// called with the Tunables in effect to modify; if the result is invalid, nothing changes. Reads and requests already
+// in progress finish with the old values, except that lowering MaxConcurrentRequests takes effect as requests in
+// flight finish, and raising it lets waiting requests start at once. Requests in flight when MaxConcurrentRequests is
+// first set are not counted against it. Prefer UpdateOptions to setting the Debug field of an S3ReaderAt in use. It is
+// safe for concurrent use.
+func (ra *S3ReaderAt) UpdateOptions(update func(*Tunables)) error {
+	ra.tuneMu.Lock()
+	defer ra.tuneMu.Unlock()
+
+	t := ra.Tunables()
+	update(&t)
+	if err := t.validate(); err != nil {
+		return err
+	}
+
+	mode := debugOff
+	if t.Debug {
+		mode = debugOn
+	}
+	atomic.StoreInt32(&ra.debugMode, mode)
+
+	ra.prefetches.setMaxBytes(t.MaxPrefetchBytes)
+	ra.tuningValue.Store(newTuning(t, ra.tuning().own))
+
+	if ra.debug() {
+		log.Printf("Updated the options of S3 object s3://%s/%s to %+v", ra.bucket, ra.key, t)
+	}
+
+	return nil
+}
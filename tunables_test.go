@@ -0,0 +1,79 @@
+package s3readerat
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// TestUpdateOptions tests that UpdateOptions changes the quota and concurrency limit of an S3ReaderAt in use, letting
+// a waiting request start, and that an invalid update changes nothing.
+func TestUpdateOptions(t *testing.T) {
+	f := newFakeS3(t)
+	f.put("bucket", "key", []byte("0123456789"))
+	release := make(chan struct{})
+	f.hook = func(w http.ResponseWriter, r *http.Request) bool {
+		if r.Header.Get("Range") == "bytes=0-1" {
+			<-release
+		}
+		return false
+	}
+
+	size := int64(10)
+	s3ReaderAt, err := NewWithOptions(Options{Client: f.client(), Bucket: "bucket", Key: "key", Size: &size,
+		MaxTotalBytes: 4, MaxConcurrentRequests: 1})
+	if err != nil {
+		t.Fatalf("Error calling NewWithOptions: %v", err)
+	}
+
+	if _, err = s3ReaderAt.ReadAt(make([]byte, 8), 0); !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("Expected an error matching ErrQuotaExceeded, got %v", err)
+	}
+	if err = s3ReaderAt.UpdateOptions(func(t *Tunables) { t.MaxTotalBytes = 0 }); err != nil {
+		t.Fatalf("Error calling UpdateOptions: %v", err)
+	}
+
+	blocked := make(chan error, 1)
+	go func() {
+		_, err := s3ReaderAt.ReadAt(make([]byte, 2), 0)
+		blocked <- err
+	}()
+	waiting := make(chan error, 1)
+	go func() {
+		for f.requestCount(http.MethodGet) == 0 {
+			time.Sleep(time.Millisecond)
+		}
+		_, err := s3ReaderAt.ReadAt(make([]byte, 2), 5)
+		waiting <- err
+	}()
+
+	select {
+	case err = <-waiting:
+		t.Fatalf("Expected ReadAt to wait for the concurrency limit, but it returned %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	if err = s3ReaderAt.UpdateOptions(func(t *Tunables) { t.MaxConcurrentRequests = 2 }); err != nil {
+		t.Fatalf("Error calling UpdateOptions: %v", err)
+	}
+	if err = <-waiting; err != nil {
+		t.Fatalf("Error calling ReadAt: %v", err)
+	}
+	close(release)
+	if err = <-blocked; err != nil {
+		t.Fatalf("Error calling ReadAt: %v", err)
+	}
+
+	if err = s3ReaderAt.UpdateOptions(func(t *Tunables) {
+		t.Debug = true
+		t.MaxPrefetchBytes = -1
+	}); err == nil {
+		t.Fatalf("Expected an error calling UpdateOptions with a negative MaxPrefetchBytes")
+	}
+	if tunables := s3ReaderAt.Tunables(); tunables.Debug || tunables.MaxConcurrentRequests != 2 ||
+		tunables.MaxTotalBytes != 0 {
+		t.Fatalf("Unexpected Tunables after an invalid update: %+v", tunables)
+	}
+}
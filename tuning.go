@@ -0,0 +1,119 @@
+package s3readerat
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// tunedMinPartSize and tunedMaxPartSize bound the part sizes chosen by a transferTuner.
+	tunedMinPartSize = 1 << 20
+	tunedMaxPartSize = 64 << 20
+
+	// tunedRoundTrips is the number of round trips' worth of transfer in a tuned part, so that waiting for the
+	// response costs little compared with receiving it.
+	tunedRoundTrips = 8
+
+	// tunedMaxConcurrency is the most requests a transferTuner keeps in flight.
+	tunedMaxConcurrency = 32
+
+	// tunedSaturation is the fraction of the best per-connection throughput below which a transferTuner concludes
+	// that connections are competing for bandwidth, and stops adding them.
+	tunedSaturation = 0.8
+
+	// tunedSmoothing is the weight of each new measurement in a transferTuner's moving averages.
+	tunedSmoothing = 0.3
+)
+
+// transferTuner chooses the part size and concurrency of a parallel download from the round-trip time and
+// per-connection throughput it measures. Parts are sized to the bandwidth-delay product times tunedRoundTrips, and
+// connections are added while each one's throughput holds up, which means the network is not yet saturated, and
+// removed once it falls. Until the first request finishes, it uses readIntoFilePartSize and readIntoFileConcurrency.
+//
+// Given a target throughput, it instead starts with one connection and keeps as many as the target calls for at the
+// measured per-connection throughput, without exceeding it, so that the transfer leaves the rest of the link to other
+// workloads.
+type transferTuner struct {
+	target float64 // bytes per second, or zero for as fast as possible
+
+	mu          sync.Mutex
+	measured    bool
+	rtt         float64 // seconds
+	throughput  float64 // bytes per second per connection
+	best        float64
+	concurrency int
+}
+
+func newTransferTuner(target int64) *transferTuner {
+	if target > 0 {
+		return &transferTuner{target: float64(target), concurrency: 1}
+	}
+	return &transferTuner{concurrency: readIntoFileConcurrency}
+}
+
+// partSize returns the number of bytes to fetch in the next request.
+func (t *transferTuner) partSize() int64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if !t.measured {
+		return readIntoFilePartSize
+	}
+
+	size := int64(t.throughput * t.rtt * tunedRoundTrips)
+	if size < tunedMinPartSize {
+		size = tunedMinPartSize
+	} else if size > tunedMaxPartSize {
+		size = tunedMaxPartSize
+	}
+	return size
+}
+
+// maxInFlight returns the number of requests to keep in flight.
+func (t *transferTuner) maxInFlight() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.concurrency
+}
+
+// observe records a request that transferred n bytes, received its response headers after ttfb and finished after
+// total.
+func (t *transferTuner) observe(n int64, ttfb time.Duration, total time.Duration) {
+	transfer := (total - ttfb).Seconds()
+	if n <= 0 || transfer <= 0 {
+		return
+	}
+	throughput := float64(n) / transfer
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if !t.measured {
+		t.rtt, t.throughput, t.measured = ttfb.Seconds(), throughput, true
+	} else {
+		t.rtt += tunedSmoothing * (ttfb.Seconds() - t.rtt)
+		t.throughput += tunedSmoothing * (throughput - t.throughput)
+	}
+
+	if t.throughput > t.best {
+		t.best = t.throughput
+	}
+
+	want := tunedMaxConcurrency
+	if t.target > 0 {
+		want = int(t.target / t.throughput)
+		if want < 1 {
+			want = 1
+		} else if want > tunedMaxConcurrency {
+			want = tunedMaxConcurrency
+		}
+	}
+
+	saturated := t.throughput < tunedSaturation*t.best
+	if t.concurrency > want || (saturated && t.concurrency > 1) {
+		t.concurrency--
+	} else if t.concurrency < want && !saturated {
+		t.concurrency++
+	}
+}
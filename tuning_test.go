@@ -0,0 +1,66 @@
+package s3readerat
+
+import (
+	"testing"
+	"time"
+)
+
+// TestTransferTuner tests that a transferTuner sizes parts to the bandwidth-delay product, adds connections while
+// per-connection throughput holds up and removes them once it falls.
+func TestTransferTuner(t *testing.T) {
+	tuner := newTransferTuner(0)
+	if size := tuner.partSize(); size != readIntoFilePartSize {
+		t.Fatalf("Expected an initial part size of %d, got %d", readIntoFilePartSize, size)
+	}
+	if concurrency := tuner.maxInFlight(); concurrency != readIntoFileConcurrency {
+		t.Fatalf("Expected an initial concurrency of %d, got %d", readIntoFileConcurrency, concurrency)
+	}
+
+	// 4 MiB in 100ms after a 50ms round trip is 40 MiB/s, so the bandwidth-delay product is 2 MiB.
+	for i := 0; i < 4; i++ {
+		tuner.observe(4<<20, 50*time.Millisecond, 150*time.Millisecond)
+	}
+	if size, expected := tuner.partSize(), int64(tunedRoundTrips*2<<20); size < expected-1 || size > expected+1 {
+		t.Fatalf("Expected a part size of %d, got %d", expected, size)
+	}
+	if concurrency := tuner.maxInFlight(); concurrency != readIntoFileConcurrency+4 {
+		t.Fatalf("Expected a concurrency of %d, got %d", readIntoFileConcurrency+4, concurrency)
+	}
+
+	// Per-connection throughput falling to a tenth means the connections are competing.
+	for i := 0; i < 10; i++ {
+		tuner.observe(4<<20, 50*time.Millisecond, 1050*time.Millisecond)
+	}
+	if concurrency := tuner.maxInFlight(); concurrency >= readIntoFileConcurrency+4 {
+		t.Fatalf("Expected the concurrency to fall below %d, got %d", readIntoFileConcurrency+4, concurrency)
+	}
+
+	// Tiny throughput is clamped to the smallest part size.
+	if size := tuner.partSize(); size < tunedMinPartSize {
+		t.Fatalf("Expected a part size of at least %d, got %d", tunedMinPartSize, size)
+	}
+}
+
+// TestTransferTunerTarget tests that a transferTuner with a target throughput keeps as many connections as meet the
+// target without exceeding it.
+func TestTransferTunerTarget(t *testing.T) {
+	// Each connection achieves 10 MiB/s, so a target of 25 MiB/s calls for two.
+	tuner := newTransferTuner(25 << 20)
+	if concurrency := tuner.maxInFlight(); concurrency != 1 {
+		t.Fatalf("Expected an initial concurrency of 1, got %d", concurrency)
+	}
+	for i := 0; i < 10; i++ {
+		tuner.observe(1<<20, 10*time.Millisecond, 110*time.Millisecond)
+	}
+	if concurrency := tuner.maxInFlight(); concurrency != 2 {
+		t.Fatalf("Expected a concurrency of 2, got %d", concurrency)
+	}
+
+	// Faster connections call for fewer of them.
+	for i := 0; i < 20; i++ {
+		tuner.observe(3<<20, 10*time.Millisecond, 110*time.Millisecond)
+	}
+	if concurrency := tuner.maxInFlight(); concurrency != 1 {
+		t.Fatalf("Expected a concurrency of 1, got %d", concurrency)
+	}
+}
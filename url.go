@@ -0,0 +1,126 @@
+package s3readerat
+
+import (
+	"context"
+	"net/url"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/pkg/errors"
+)
+
+// KeyEncoding controls how ParseURL interprets the key portion of an S3 URL.
+type KeyEncoding int
+
+const (
+	// KeyRaw treats everything after the bucket as the literal key, so spaces, "+", "#", "?" and "%" are all part of
+	// the key. This matches how the AWS CLI interprets s3:// URLs.
+	KeyRaw KeyEncoding = iota
+
+	// KeyEncoded treats the key as a percent-encoded URL path, so "%XX" sequences are decoded and "?" and "#" begin the
+	// query and fragment. "+" is not decoded, since it only means a space in query strings. The query may name a
+	// version of the object with versionId and the region of the bucket with region, as in
+	// s3://bucket/key?versionId=abc&region=eu-west-1; other parameters are ignored.
+	KeyEncoded
+)
+
+// S3URL identifies an S3 object by bucket and key.
+type S3URL struct {
+	Bucket string
+	Key    string
+
+	// VersionID and Region are given by the versionId and region query parameters of URLs parsed with KeyEncoded.
+	VersionID string
+	Region    string
+}
+
+// String returns the URL in s3://bucket/key form, with the key unencoded.
+func (u *S3URL) String() string {
+	return "s3://" + u.Bucket + "/" + u.Key
+}
+
+// ParseURL parses an S3 URL of the form s3://bucket/key, interpreting the key according to encoding. The bucket may be
+// the ARN of an access point, as in s3://arn:aws:s3:us-west-2:123456789012:accesspoint/name/key.
+func ParseURL(rawURL string, encoding KeyEncoding) (*S3URL, error) {
+	const scheme = "s3://"
+	if len(rawURL) < len(scheme) || !strings.EqualFold(rawURL[:len(scheme)], scheme) {
+		return nil, errors.Errorf("S3 URL %q must start with %s", rawURL, scheme)
+	}
+
+	rest := rawURL[len(scheme):]
+
+	// An access point ARN has colons and slashes of its own, so parse the rest of the URL as though it were a bucket.
+	var accessPoint string
+	if end := accessPointEnd(rest); end >= 0 {
+		accessPoint, rest = rest[:end], "access-point"+rest[end:]
+	}
+
+	var bucket, key string
+	var query url.Values
+	switch encoding {
+	case KeyRaw:
+		if i := strings.IndexByte(rest, '/'); i >= 0 {
+			bucket, key = rest[:i], rest[i+1:]
+		} else {
+			bucket = rest
+		}
+	case KeyEncoded:
+		parsed, err := url.Parse(scheme + rest)
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to parse S3 URL %q", rawURL)
+		}
+		bucket, key = parsed.Host, strings.TrimPrefix(parsed.Path, "/")
+		query = parsed.Query()
+	default:
+		return nil, errors.Errorf("unknown key encoding %d", encoding)
+	}
+
+	if bucket == "" {
+		return nil, errors.Errorf("S3 URL %q is missing a bucket", rawURL)
+	} else if accessPoint != "" {
+		bucket = accessPoint
+	}
+
+	return &S3URL{Bucket: bucket, Key: key, VersionID: query.Get("versionId"), Region: query.Get("region")}, nil
+}
+
+// NewFromURL creates a new S3ReaderAt for the object an S3 URL names, parsed with KeyEncoded, so that it may name a
+// version and region. It loads the default AWS config with ctx, and runs in multi-region mode, starting in the URL's
+// region, if it names one, and otherwise the config's.
+func NewFromURL(ctx context.Context, rawURL string) (*S3ReaderAt, error) {
+	return NewFromURLWithOptions(ctx, rawURL, Options{})
+}
+
+// NewFromURLWithOptions is like NewFromURL, but takes Options as NewWithOptions does, whose Bucket and Key, and
+// VersionID if the URL names one, are replaced by the URL's. The default AWS config is only loaded if none of Client,
+// Options and API is provided, and the URL's region only applies in multi-region mode. Options.Context defaults to
+// ctx.
+func NewFromURLWithOptions(ctx context.Context, rawURL string, options Options) (*S3ReaderAt, error) {
+	parsed, err := ParseURL(rawURL, KeyEncoded)
+	if err != nil {
+		return nil, err
+	}
+
+	options.Bucket, options.Key = parsed.Bucket, parsed.Key
+	if parsed.VersionID != "" {
+		options.VersionID = parsed.VersionID
+	}
+	if options.Context == nil {
+		options.Context = ctx
+	}
+
+	if options.Client == nil && options.Options == nil && options.API == nil {
+		cfg, err := config.LoadDefaultConfig(ctx)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to load AWS config")
+		}
+		options.Options = OptionsFromConfig(cfg)
+	}
+	if parsed.Region != "" && options.Options != nil {
+		copied := options.Options.Copy()
+		copied.Region = parsed.Region
+		options.Options = &copied
+	}
+
+	return NewWithOptions(options)
+}
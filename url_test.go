@@ -0,0 +1,95 @@
+package s3readerat
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// TestParseURL tests that ParseURL handles keys containing special characters in both raw and encoded modes.
+func TestParseURL(t *testing.T) {
+	for _, test := range []struct {
+		rawURL    string
+		encoding  KeyEncoding
+		bucket    string
+		key       string
+		versionID string
+	}{
+		{"s3://bucket/key", KeyRaw, "bucket", "key", ""},
+		{"S3://bucket/dir/key", KeyRaw, "bucket", "dir/key", ""},
+		{"s3://bucket/a b+c#d?e=f", KeyRaw, "bucket", "a b+c#d?e=f", ""},
+		{"s3://bucket/café/%20", KeyRaw, "bucket", "café/%20", ""},
+		{"s3://bucket/key?versionId=1", KeyRaw, "bucket", "key?versionId=1", ""},
+		{"s3://bucket", KeyRaw, "bucket", "", ""},
+		{"s3://bucket/a%20b+c%23d%3F", KeyEncoded, "bucket", "a b+c#d?", ""},
+		{"s3://bucket/caf%C3%A9?versionId=1#fragment", KeyEncoded, "bucket", "café", "1"},
+		{"s3://arn:aws:s3:us-west-2:123456789012:accesspoint/ap/dir/key", KeyRaw,
+			"arn:aws:s3:us-west-2:123456789012:accesspoint/ap", "dir/key", ""},
+		{"s3://arn:aws:s3:us-west-2:123456789012:accesspoint/ap/a%20b?versionId=1", KeyEncoded,
+			"arn:aws:s3:us-west-2:123456789012:accesspoint/ap", "a b", "1"},
+		{"s3://arn:aws:s3::123456789012:accesspoint/mfzwi23gnjvgw.mrap/key", KeyRaw,
+			"arn:aws:s3::123456789012:accesspoint/mfzwi23gnjvgw.mrap", "key", ""},
+		{"s3://arn:aws:s3-outposts:us-west-2:123456789012:outpost/op-1/accesspoint/ap", KeyRaw,
+			"arn:aws:s3-outposts:us-west-2:123456789012:outpost/op-1/accesspoint/ap", "", ""},
+	} {
+		parsed, err := ParseURL(test.rawURL, test.encoding)
+		if err != nil {
+			t.Fatalf("Error calling ParseURL(%q): %v", test.rawURL, err)
+		}
+
+		if parsed.Bucket != test.bucket || parsed.Key != test.key || parsed.VersionID != test.versionID {
+			t.Fatalf("ParseURL(%q) returned bucket %q, key %q and version %q, expected %q, %q and %q", test.rawURL,
+				parsed.Bucket, parsed.Key, parsed.VersionID, test.bucket, test.key, test.versionID)
+		}
+	}
+
+	for _, rawURL := range []string{"https://bucket/key", "s3:///key", "s3"} {
+		if _, err := ParseURL(rawURL, KeyRaw); err == nil {
+			t.Fatalf("Expected an error calling ParseURL(%q)", rawURL)
+		}
+	}
+}
+
+// TestNewFromURL tests that NewFromURLWithOptions reads the object, and the version of it, that a URL names, and that
+// NewFromURL starts in the URL's region.
+func TestNewFromURL(t *testing.T) {
+	f := newFakeS3(t)
+	f.versioning = true
+	f.put("bucket", "dir/a key", []byte("old"))
+	f.put("bucket", "dir/a key", []byte("new"))
+
+	var versionID string
+	for k, v := range f.versions {
+		if string(v.data) == "old" {
+			versionID = k[len("bucket/dir/a key\x00"):]
+		}
+	}
+
+	s3ReaderAt, err := NewFromURLWithOptions(context.Background(), "s3://bucket/dir/a%20key?versionId="+versionID,
+		Options{Client: f.client()})
+	if err != nil {
+		t.Fatalf("Error calling NewFromURLWithOptions: %v", err)
+	}
+	b := make([]byte, 3)
+	if _, err = s3ReaderAt.ReadAt(b, 0); err != nil || string(b) != "old" {
+		t.Fatalf("Expected to read %q, got %q (%v)", "old", b, err)
+	}
+	if count := f.requestCount(http.MethodGet); count != 1 {
+		t.Fatalf("Expected 1 GetObject request, got %d", count)
+	}
+
+	s3ReaderAt, err = NewFromURLWithOptions(context.Background(), "s3://bucket/key?region=eu-west-1",
+		Options{Options: &s3.Options{Region: "us-east-1"}})
+	if err != nil {
+		t.Fatalf("Error calling NewFromURLWithOptions: %v", err)
+	}
+	if region := s3ReaderAt.options.Region; region != "eu-west-1" {
+		t.Fatalf("Expected to start in region eu-west-1, got %s", region)
+	}
+
+	if _, err = NewFromURL(context.Background(), "https://bucket/key"); err == nil {
+		t.Fatalf("Expected an error calling NewFromURL with an invalid URL")
+	}
+}
@@ -0,0 +1,47 @@
+package s3readerat
+
+import (
+	"archive/zip"
+	"io/fs"
+
+	"github.com/markandrus/s3readerat/cache"
+	"github.com/pkg/errors"
+)
+
+const (
+	// zipBlockSize is the number of bytes ZipFS fetches at a time when reading members.
+	zipBlockSize = 1 << 20
+
+	// zipBlocks is the number of blocks ZipFS keeps, so that a few members can be read concurrently.
+	zipBlocks = 8
+)
+
+// ZipFS opens the zip archive described by options and returns an fs.FS of its members, suitable for http.FS or
+// template.ParseFS. The central directory is read once, when ZipFS is called, and kept in memory; members are read
+// with ranged GETs as they are opened.
+//
+// Unless options sets a Profile or prefetching, ProfileZip is used, so that the end of central directory record and
+// the central directory are usually fetched with a single request. Decompressors read members a few KiB at a time, so
+// member reads are rounded up to blocks of 1 MiB, a few of which are kept.
+func ZipFS(options Options) (fs.FS, error) {
+	if options.Profile == nil && options.PrefetchHeadBytes == 0 && options.PrefetchTailBytes == 0 {
+		options.Profile = ProfileZip
+	}
+
+	ra, err := NewWithOptions(options)
+	if err != nil {
+		return nil, err
+	}
+
+	size, err := ra.Size()
+	if err != nil {
+		return nil, err
+	}
+
+	zr, err := zip.NewReader(cache.New(ra, size, cache.Options{BlockSize: zipBlockSize, Blocks: zipBlocks}), size)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to read zip central directory")
+	}
+
+	return zr, nil
+}
@@ -0,0 +1,59 @@
+package s3readerat
+
+import (
+	"archive/zip"
+	"bytes"
+	"io/fs"
+	"net/http"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+// TestZipFS tests that ZipFS serves the members of a remote zip archive, fetching the central directory once.
+func TestZipFS(t *testing.T) {
+	var archive bytes.Buffer
+	zw := zip.NewWriter(&archive)
+	members := map[string]string{
+		"README.md":        "hello",
+		"data/big.txt":     strings.Repeat("compressible ", 100000),
+		"data/nested/x.js": "console.log(1)",
+	}
+	for name, contents := range members {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatalf("Error creating zip member: %v", err)
+		}
+		if _, err = w.Write([]byte(contents)); err != nil {
+			t.Fatalf("Error writing zip member: %v", err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("Error writing zip archive: %v", err)
+	}
+
+	f := newFakeS3(t)
+	f.put("bucket", "archive.zip", archive.Bytes())
+
+	fsys, err := ZipFS(Options{Client: f.client(), Bucket: "bucket", Key: "archive.zip"})
+	if err != nil {
+		t.Fatalf("Error calling ZipFS: %v", err)
+	}
+	if count := f.requestCount(http.MethodGet); count != 1 {
+		t.Fatalf("Expected 1 GetObject request to read the central directory, got %d", count)
+	}
+
+	for name, contents := range members {
+		b, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			t.Fatalf("Error reading %s: %v", name, err)
+		}
+		if string(b) != contents {
+			t.Fatalf("Unexpected contents of %s", name)
+		}
+	}
+
+	if err = fstest.TestFS(fsys, "README.md", "data/big.txt", "data/nested/x.js"); err != nil {
+		t.Fatalf("ZipFS does not behave as an fs.FS: %v", err)
+	}
+}